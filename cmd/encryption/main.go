@@ -0,0 +1,69 @@
+// Command encryption runs the board content encryption jobs against the
+// configured database:
+//
+//	go run ./cmd/encryption rotate            re-wrap data keys with ENCRYPTION_KEY
+//	go run ./cmd/encryption encrypt-existing  encrypt content stored as plaintext
+//
+// To rotate, set ENCRYPTION_KEY to the new key and ENCRYPTION_PREVIOUS_KEYS
+// to the old one, run rotate, then drop the old key.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"draw/internal/db/encrypted"
+	"draw/pkg/config"
+	"draw/pkg/database"
+	"draw/pkg/encryption"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if len(os.Args) != 2 || (os.Args[1] != "rotate" && os.Args[1] != "encrypt-existing") {
+		fmt.Fprintln(os.Stderr, "usage: encryption rotate|encrypt-existing")
+		os.Exit(2)
+	}
+
+	// The environment may be set without a .env file.
+	_ = godotenv.Load()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load config:", err)
+		os.Exit(1)
+	}
+	keyring, err := encryption.ParseKeyring(cfg.Encryption.Key, cfg.Encryption.PreviousKeys)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	db := database.NewPostgresDB(ctx, &cfg.DB)
+	if err := db.Connect(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	encryptedDB := encrypted.New(db.GetDB(), encrypted.NewKeys(keyring))
+	switch os.Args[1] {
+	case "rotate":
+		rotated, err := encrypted.Rotate(ctx, encryptedDB)
+		fmt.Printf("Re-wrapped %d data key(s)\n", rotated)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Rotation failed:", err)
+			os.Exit(1)
+		}
+	case "encrypt-existing":
+		count, err := encrypted.EncryptExisting(ctx, encryptedDB)
+		fmt.Printf("Encrypted %d row(s)\n", count)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Encryption failed:", err)
+			os.Exit(1)
+		}
+	}
+}
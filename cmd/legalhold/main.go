@@ -0,0 +1,125 @@
+// Command legalhold places and releases legal holds on boards against the
+// configured database:
+//
+//	go run ./cmd/legalhold place <board-id> <reason>  preserve a board and its history
+//	go run ./cmd/legalhold release <board-id>         resume normal retention
+//	go run ./cmd/legalhold list                       show boards under hold
+//
+// While a board is held, retention skips its instructions and timings, and
+// the database refuses to delete it, its comments or its instructions,
+// including through deleting its owner. There are no admin roles in the API,
+// so holds are managed here rather than over HTTP.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/pkg/config"
+	"draw/pkg/database"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+)
+
+const usage = "usage: legalhold place <board-id> <reason> | release <board-id> | list"
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var boardID uuid.UUID
+	var reason string
+	switch os.Args[1] {
+	case "place", "release":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		id, err := uuid.Parse(os.Args[2])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid board ID:", err)
+			os.Exit(2)
+		}
+		boardID = id
+		reason = strings.TrimSpace(strings.Join(os.Args[3:], " "))
+		if os.Args[1] == "place" && reason == "" {
+			fmt.Fprintln(os.Stderr, "A reason is required to place a hold")
+			os.Exit(2)
+		}
+	case "list":
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// The environment may be set without a .env file.
+	_ = godotenv.Load()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load config:", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	db := database.NewPostgresDB(ctx, &cfg.DB)
+	if err := db.Connect(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	// Only hold columns are read and written, so content stays encrypted.
+	queries := repo.New(db.GetDB())
+	switch os.Args[1] {
+	case "place":
+		rows, err := queries.PlaceLegalHold(ctx, repo.PlaceLegalHoldParams{
+			ID:              boardID,
+			LegalHoldReason: &reason,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to place hold:", err)
+			os.Exit(1)
+		}
+		if rows == 0 {
+			fmt.Fprintln(os.Stderr, "No board with ID", boardID)
+			os.Exit(1)
+		}
+		fmt.Println("Placed legal hold on board", boardID)
+	case "release":
+		rows, err := queries.ReleaseLegalHold(ctx, boardID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to release hold:", err)
+			os.Exit(1)
+		}
+		if rows == 0 {
+			fmt.Fprintln(os.Stderr, "Board", boardID, "is not under legal hold")
+			os.Exit(1)
+		}
+		fmt.Println("Released legal hold on board", boardID, "- retention resumes on the next purge run")
+	case "list":
+		holds, err := queries.GetLegalHolds(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to list holds:", err)
+			os.Exit(1)
+		}
+		for _, hold := range holds {
+			placed, reason := "", ""
+			if hold.LegalHoldAt != nil {
+				placed = hold.LegalHoldAt.UTC().Format(time.RFC3339)
+			}
+			if hold.LegalHoldReason != nil {
+				reason = *hold.LegalHoldReason
+			}
+			fmt.Printf("%s\t%s\towner %s\tsince %s\t%s\n", hold.ID, hold.Name, hold.OwnerID, placed, reason)
+		}
+		fmt.Printf("%d board(s) under legal hold\n", len(holds))
+	}
+}
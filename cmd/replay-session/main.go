@@ -0,0 +1,81 @@
+// Command replay-session replays recorded voice sessions against the current
+// pipeline and reports where the outcome diverges from the recording.
+//
+//	go run ./cmd/replay-session [-board fixture.json] [-json] recording.json...
+//
+// It exits with status 1 if any recording diverged.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"draw/pkg/llm"
+	"draw/pkg/replay"
+)
+
+func main() {
+	boardPath := flag.String("board", "", "replace the recording's starting board with this fixture")
+	asJSON := flag.Bool("json", false, "print reports as JSON")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: replay-session [-board fixture.json] [-json] recording.json...")
+		os.Exit(2)
+	}
+
+	var fixture []llm.Element
+	if *boardPath != "" {
+		data, err := os.ReadFile(*boardPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read board fixture:", err)
+			os.Exit(2)
+		}
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid board fixture:", err)
+			os.Exit(2)
+		}
+	}
+
+	diverged := false
+	for _, path := range flag.Args() {
+		rec, err := replay.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read recording:", err)
+			os.Exit(2)
+		}
+		if *boardPath != "" {
+			rec.Board = fixture
+		}
+
+		report, err := replay.Replay(context.Background(), rec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to replay %s: %v\n", path, err)
+			os.Exit(2)
+		}
+		if !report.OK() {
+			diverged = true
+		}
+
+		if *asJSON {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(data))
+			continue
+		}
+		if report.OK() {
+			fmt.Printf("%s: %d utterance(s) replayed, no divergences\n", path, report.Utterances)
+			continue
+		}
+		fmt.Printf("%s: %d divergence(s) in %d utterance(s)\n", path, len(report.Divergences), report.Utterances)
+		for _, d := range report.Divergences {
+			fmt.Println(d.String())
+		}
+	}
+
+	if diverged {
+		os.Exit(1)
+	}
+}
@@ -1,5 +1,7 @@
 export interface CreateBoardResponse {
   boardId: string;
+  name: string;
+  createdAt: string;
 }
 
 export interface Board {
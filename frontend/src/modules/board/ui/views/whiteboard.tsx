@@ -42,6 +42,71 @@ export function convertFromExcalidrawElements(
   return activeElements.map((element) => JSON.parse(JSON.stringify(element)));
 }
 
+type ReorderPosition = "front" | "back" | "forward" | "backward";
+
+// reorderElements moves each target, with the text bound to it, in the
+// stacking order the way the server's whiteboard.Reorder does. Excalidraw
+// gives moved elements new fractional indices when the scene is updated.
+function reorderElements(
+  elements: readonly ExcalidrawElement[],
+  targetIds: string[],
+  position: ReorderPosition
+): ExcalidrawElement[] {
+  let result = [...elements];
+  const isBoundText = (el: ExcalidrawElement) =>
+    el.type === "text" && !!el.containerId;
+  const targets = targetIds.filter((id) =>
+    result.some((el) => el.id === id && !el.isDeleted)
+  );
+  const skip = new Set(targets);
+  const at = (id: string) => result.findIndex((el) => el.id === id);
+  // Move the target nearest the destination first for forward and back,
+  // and the furthest first otherwise, so they keep their relative order.
+  const descending = position === "back" || position === "forward";
+  targets.sort((a, b) => (descending ? at(b) - at(a) : at(a) - at(b)));
+
+  for (const id of targets) {
+    const index = at(id);
+    const block = result.filter(
+      (el, i) =>
+        i === index || (isBoundText(el) && (el as any).containerId === id)
+    );
+    const rest = result.filter((el) => !block.includes(el));
+    const below = result
+      .slice(0, index)
+      .filter((el) => !block.includes(el)).length;
+    const stacked = (el: ExcalidrawElement) =>
+      !el.isDeleted && !skip.has(el.id) && !isBoundText(el);
+
+    let insert = below;
+    if (position === "front") {
+      insert = rest.length;
+    } else if (position === "back") {
+      insert = 0;
+    } else if (position === "forward") {
+      const passed = rest.findIndex((el, i) => i >= below && stacked(el));
+      if (passed >= 0) {
+        insert = passed + 1;
+        while (
+          insert < rest.length &&
+          (rest[insert] as any).containerId === rest[passed].id
+        ) {
+          insert++;
+        }
+      }
+    } else {
+      for (let i = below - 1; i >= 0; i--) {
+        if (stacked(rest[i])) {
+          insert = i;
+          break;
+        }
+      }
+    }
+    result = [...rest.slice(0, insert), ...block, ...rest.slice(insert)];
+  }
+  return result;
+}
+
 export const Whiteboard = ({
   board,
   onStateChange,
@@ -166,9 +231,11 @@ export const Whiteboard = ({
 
     try {
       let response: {
-        action: "add" | "update" | "delete";
+        action: "add" | "update" | "delete" | "clear" | "replace" | "reorder";
         elements?: ExcalidrawElementSkeleton[];
         delete_ids?: string[];
+        target_ids?: string[];
+        position?: ReorderPosition;
       };
 
       if (typeof llmResponse === "string") {
@@ -188,16 +255,61 @@ export const Whiteboard = ({
         });
         excalidrawAPI.current.updateScene({ elements: updatedElements });
         setElements(updatedElements);
+      } else if (
+        response.action === "reorder" &&
+        response.target_ids &&
+        response.position
+      ) {
+        const updatedElements = reorderElements(
+          currentElements,
+          response.target_ids,
+          response.position
+        );
+        excalidrawAPI.current.updateScene({ elements: updatedElements });
+        setElements(updatedElements);
+      } else if (response.action === "clear" || response.action === "replace") {
+        const clearedElements = currentElements.map((el) => ({
+          ...el,
+          isDeleted: true,
+        }));
+        const newElements =
+          response.action === "replace" && response.elements
+            ? convertToExcalidrawElements(response.elements, {
+                regenerateIds: false,
+              })
+            : [];
+        const updatedElements = [...clearedElements, ...newElements];
+        excalidrawAPI.current.updateScene({ elements: updatedElements });
+        setElements(updatedElements);
       } else if (
         (response.action === "add" || response.action === "update") &&
         response.elements
       ) {
-        const newElements = convertToExcalidrawElements(response.elements, {
+        // Frames list the board elements they take in as children, which
+        // the converter only knows how to find among the new elements.
+        const existingIds = new Set(currentElements.map((el) => el.id));
+        const adopted = new Map<string, string>();
+        const skeletons = response.elements.map((el) => {
+          if (el.type !== "frame" || !el.children || !el.id) return el;
+          el.children
+            .filter((id) => existingIds.has(id))
+            .forEach((id) => adopted.set(id, el.id!));
+          return {
+            ...el,
+            children: el.children.filter((id) => !existingIds.has(id)),
+          };
+        });
+        const newElements = convertToExcalidrawElements(skeletons, {
           regenerateIds: false,
         });
 
         if (response.action === "add") {
-          const updatedElements = [...currentElements, ...newElements];
+          const updatedElements = [
+            ...currentElements.map((el) =>
+              adopted.has(el.id) ? { ...el, frameId: adopted.get(el.id)! } : el
+            ),
+            ...newElements,
+          ];
           excalidrawAPI.current.updateScene({ elements: updatedElements });
           setElements(updatedElements);
         } else if (response.action === "update") {
@@ -4,24 +4,43 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"strings"
 	"time"
 
+	"draw/internal/db/encrypted"
 	"draw/internal/db/repo"
 	"draw/internal/service"
+	"draw/internal/worker"
 	"draw/pkg/config"
 	"draw/pkg/database"
+	"draw/pkg/encryption"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/llm/prompts"
+	"draw/pkg/llmdebug"
 	"draw/pkg/logger"
+	"draw/pkg/replay"
+	"draw/pkg/slo"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 )
 
 type App struct {
-	Config  *config.AppConfig
-	DB      database.DB
-	Service *service.Service
-	Log     *logger.Logger
+	Config       *config.AppConfig
+	DB           database.DB
+	Service      *service.Service
+	Sessions     *livekit.SessionManager
+	SLO          *slo.Tracker
+	Models       *llm.Models
+	Prompts      *prompts.Registry
+	LLMMetrics   *llm.Metrics
+	PurgeWorker  *worker.PurgeWorker
+	RollupWorker *worker.RollupWorker
+	TimingWriter *worker.TimingWriter
+	Log          *logger.Logger
 }
 
 func NewApp(ctx context.Context, cfg *config.AppConfig) (*App, error) {
@@ -37,9 +56,86 @@ func NewApp(ctx context.Context, cfg *config.AppConfig) (*App, error) {
 		return nil, fmt.Errorf("database not initialize")
 	}
 
-	queries := repo.New(dbInstance)
+	keyring, err := encryption.ParseKeyring(cfg.Encryption.Key, cfg.Encryption.PreviousKeys)
+	if err != nil {
+		return nil, err
+	}
+	encryptedDB := encrypted.New(dbInstance, encrypted.NewKeys(keyring))
+	queries := repo.New(encryptedDB)
+
+	budget, err := llm.NewBudget(&cfg.LLM, queries.SumInstructionCostSince)
+	if err != nil {
+		return nil, err
+	}
 
-	services := service.NewService(dbInstance, queries, cfg)
+	aliases, err := llm.ParseModelAliases(cfg.LLM.ModelAliases)
+	if err != nil {
+		return nil, err
+	}
+	models := llm.NewModels(aliases)
+	limiter := llm.NewRateLimiter(map[llm.RateLimitClass]llm.RateLimit{
+		llm.RateLimitUser:           {PerMinute: cfg.LLM.RateLimitPerMinute, Burst: cfg.LLM.RateLimitBurst},
+		llm.RateLimitServiceAccount: {PerMinute: cfg.LLM.ServiceAccountRateLimitPerMinute, Burst: cfg.LLM.ServiceAccountRateLimitBurst},
+	})
+
+	registry, err := prompts.NewRegistry(cfg.LLM.PromptsDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := registry.System(cfg.LLM.PromptVersion, prompts.ModeDiagram); err != nil {
+		return nil, fmt.Errorf("LLM_PROMPT_VERSION: %w; available: %s", err, strings.Join(registry.Versions(), ", "))
+	}
+
+	if cfg.LLM.StartupCheck {
+		if err := checkLLMProvider(ctx, &cfg.LLM, models); err != nil {
+			return nil, err
+		}
+	}
+
+	tracker := slo.NewTracker(time.Duration(cfg.SLO.LatencyTargetMs)*time.Millisecond, cfg.SLO.Objective)
+
+	var hooks []llm.GenerateHook
+	if cfg.LLM.LogRequests {
+		hooks = append(hooks, llm.NewLogHook(slog.Default()))
+	}
+	var metrics *llm.Metrics
+	if cfg.LLM.Metrics {
+		metrics = llm.NewMetrics()
+		hooks = append(hooks, metrics.Hook())
+	}
+
+	var recorders livekit.InstructionRecorders
+	if cfg.Recording.Enabled {
+		// Recordings hold board content and transcripts verbatim.
+		if cfg.Env == "production" {
+			return nil, fmt.Errorf("session recording is not allowed in production")
+		}
+		sessionRecorder, err := replay.NewRecorder(cfg.Recording.Dir)
+		if err != nil {
+			return nil, err
+		}
+		recorders = append(recorders, sessionRecorder)
+	}
+	var history *llmdebug.History
+	if cfg.LLMHistory.Enabled {
+		history = llmdebug.NewHistory(
+			cfg.LLMHistory.Size,
+			time.Duration(cfg.LLMHistory.TTLSec)*time.Second,
+			cfg.LLMHistory.MaxBytes,
+			cfg.Retention.StoreRawLLMOutput,
+		)
+		recorders = append(recorders, history)
+	}
+	var recorder livekit.InstructionRecorder
+	if len(recorders) > 0 {
+		recorder = recorders
+	}
+
+	if cfg.Demo.Enabled && cfg.Demo.SessionSecret == "" {
+		return nil, fmt.Errorf("DEMO_SESSION_SECRET is required when DEMO_ENABLED is set")
+	}
+
+	sessions := livekit.NewSessionManager(cfg, budget, models, limiter, registry, hooks, tracker, recorder)
 
 	traceIDFn := func(ctx context.Context) string {
 		return uuid.New().String()
@@ -53,10 +149,55 @@ func NewApp(ctx context.Context, cfg *config.AppConfig) (*App, error) {
 	}
 	log := logger.NewLogger(logConfig)
 
+	for _, deprecation := range cfg.Deprecations {
+		log.Warn(ctx, "Deprecated configuration", "detail", deprecation)
+	}
+
+	timingWriter := worker.NewTimingWriter(queries, log)
+	timingWriter.Start()
+
+	services := service.NewService(dbInstance, queries, encryptedDB, cfg, sessions, timingWriter, history, registry)
+
+	// Nothing has run yet, so every pending instruction belongs to a process
+	// that is gone.
+	if err := services.InstructionService.InterruptPending(ctx, time.Now()); err != nil {
+		log.Error(ctx, "Failed to recover interrupted instructions", "error", err)
+	}
+
+	purgeWorker := worker.NewPurgeWorker(queries, &cfg.Retention, log)
+	purgeWorker.Start()
+
+	rollupWorker := worker.NewRollupWorker(queries, &cfg.Analytics, log)
+	rollupWorker.Start()
+
 	return &App{
-		Config:  cfg,
-		DB:      db,
-		Service: services,
-		Log:     log,
+		Config:       cfg,
+		DB:           db,
+		Service:      services,
+		Sessions:     sessions,
+		SLO:          tracker,
+		Models:       models,
+		Prompts:      registry,
+		LLMMetrics:   metrics,
+		PurgeWorker:  purgeWorker,
+		RollupWorker: rollupWorker,
+		TimingWriter: timingWriter,
+		Log:          log,
 	}, nil
 }
+
+// checkLLMProvider pings the configured provider, so a wrong host, model or
+// key stops the server at startup instead of failing the first voice
+// command.
+func checkLLMProvider(ctx context.Context, cfg *config.LLMConfig, models *llm.Models) error {
+	client, err := llm.NewLLMClient(cfg, nil, models, nil, nil)
+	if err != nil {
+		return fmt.Errorf("invalid LLM configuration: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("LLM provider %q with model %q is not usable; check LLM_HOST, LLM_MODEL and LLM_API_KEY, or set LLM_STARTUP_CHECK=false to skip this check: %w", cfg.Provider, cfg.Model, err)
+	}
+	return nil
+}
@@ -0,0 +1,412 @@
+// Package encrypted wraps the connection the repository queries run on, so
+// board content is encrypted as it is written and decrypted as it is read
+// without the services having to know.
+package encrypted
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"draw/internal/db/repo"
+	"draw/pkg/encryption"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// sealedArgs lists, per sqlc query name, which argument holds the board ID
+// and which hold content to encrypt. Every query that writes board
+// elements, comments, instructions or pending changes must be listed here.
+var sealedArgs = map[string]struct {
+	board   int
+	content []int
+}{
+	"UpdateBoard":             {board: 0, content: []int{2}},
+	"EncryptBoardElements":    {board: 0, content: []int{1}},
+	"UpdateBoardElements":     {board: 0, content: []int{1}},
+	"CreateComment":           {board: 0, content: []int{3}},
+	"UpdateComment":           {board: 1, content: []int{2}},
+	"ImportComment":           {board: 0, content: []int{3}},
+	"EncryptCommentText":      {board: 1, content: []int{2}},
+	"CreateInstruction":       {board: 0, content: []int{2, 3, 12}},
+	"EncryptInstruction":      {board: 1, content: []int{2, 3, 4}},
+	"CreatePendingChange":     {board: 0, content: []int{2, 3}},
+	"EncryptPendingChange":    {board: 1, content: []int{2, 3}},
+	"CreateInstructionIntent": {board: 0, content: []int{3}},
+}
+
+// Keys caches unwrapped data keys. It is shared by every DB using the same
+// keyring.
+type Keys struct {
+	keyring *encryption.Keyring
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+// NewKeys creates the cache. A nil keyring disables encryption: content is
+// written as it is, and reading encrypted content fails with
+// encryption.ErrUnavailable.
+func NewKeys(keyring *encryption.Keyring) *Keys {
+	return &Keys{
+		keyring: keyring,
+		cache:   make(map[string][]byte),
+	}
+}
+
+// Enabled reports whether new content is encrypted.
+func (k *Keys) Enabled() bool {
+	return k.keyring != nil
+}
+
+// DB implements repo.DBTX on top of another DBTX.
+type DB struct {
+	inner repo.DBTX
+	keys  *Keys
+	// txKeys caches data keys within a transaction. A key created in a
+	// transaction that is later rolled back must not outlive it.
+	txKeys map[string][]byte
+}
+
+func New(inner repo.DBTX, keys *Keys) *DB {
+	return &DB{inner: inner, keys: keys}
+}
+
+// WithTx wraps a transaction the same way, for use with repo.New.
+func (d *DB) WithTx(tx pgx.Tx) *DB {
+	return &DB{inner: tx, keys: d.keys, txKeys: make(map[string][]byte)}
+}
+
+func (d *DB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	args, err := d.sealArgs(ctx, sql, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return d.inner.Exec(ctx, sql, args...)
+}
+
+func (d *DB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	args, err := d.sealArgs(ctx, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.inner.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingRows{Rows: rows, db: d, ctx: ctx}, nil
+}
+
+// QueryRow runs as a query, so the row's columns are known when it is
+// decrypted.
+func (d *DB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	rows, err := d.Query(ctx, sql, args...)
+	if err != nil {
+		return errorRow{err: err}
+	}
+	return &decryptingRow{rows: rows}
+}
+
+// queryName extracts the sqlc query name from the "-- name: X :kind" header
+// sqlc puts on every query.
+func queryName(sql string) string {
+	rest, ok := strings.CutPrefix(sql, "-- name: ")
+	if !ok {
+		return ""
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+func (d *DB) sealArgs(ctx context.Context, sql string, args []interface{}) ([]interface{}, error) {
+	spec, ok := sealedArgs[queryName(sql)]
+	if !ok {
+		return args, nil
+	}
+	// Content that looks like an envelope would be read back as one, with
+	// encryption on or off, so it is refused. Everything the services write
+	// was decrypted when read, so only user input can look like this.
+	for _, i := range spec.content {
+		if looksSealed(args[i]) {
+			return nil, encryption.ErrReservedPrefix
+		}
+	}
+	if !d.keys.Enabled() {
+		return args, nil
+	}
+
+	boardID, ok := args[spec.board].(uuid.UUID)
+	if !ok {
+		return nil, fmt.Errorf("encrypted query %s: board ID argument is %T", queryName(sql), args[spec.board])
+	}
+	var dataKey []byte
+	sealed := make([]interface{}, len(args))
+	copy(sealed, args)
+	for _, i := range spec.content {
+		plaintext, kind := contentBytes(args[i])
+		if plaintext == nil {
+			continue
+		}
+		if dataKey == nil {
+			var err error
+			if dataKey, err = d.dataKey(ctx, boardID, true); err != nil {
+				return nil, err
+			}
+		}
+		envelope, err := encryption.Seal(dataKey, boardID.String(), plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt content: %w", err)
+		}
+		switch kind {
+		case contentJSON:
+			// Stored as a JSON string so the column stays valid JSONB.
+			data, _ := json.Marshal(envelope)
+			sealed[i] = json.RawMessage(data)
+		case contentStringPtr:
+			sealed[i] = &envelope
+		default:
+			sealed[i] = envelope
+		}
+	}
+	return sealed, nil
+}
+
+type contentKind int
+
+const (
+	contentString contentKind = iota
+	contentStringPtr
+	contentJSON
+)
+
+// contentBytes returns the plaintext to encrypt, or nil for values that are
+// empty or null.
+func contentBytes(arg interface{}) ([]byte, contentKind) {
+	switch v := arg.(type) {
+	case string:
+		if v == "" {
+			return nil, contentString
+		}
+		return []byte(v), contentString
+	case *string:
+		if v == nil || *v == "" {
+			return nil, contentStringPtr
+		}
+		return []byte(*v), contentStringPtr
+	case json.RawMessage:
+		if len(v) == 0 || string(v) == "null" {
+			return nil, contentJSON
+		}
+		return v, contentJSON
+	}
+	return nil, contentString
+}
+
+// looksSealed reports whether a content argument would be read back as an
+// envelope.
+func looksSealed(arg interface{}) bool {
+	switch v := arg.(type) {
+	case string:
+		return encryption.IsSealed(v)
+	case *string:
+		return v != nil && encryption.IsSealed(*v)
+	case json.RawMessage:
+		return sealedJSON(v) != ""
+	}
+	return false
+}
+
+// sealedJSON returns the envelope held by a JSON string value, or "".
+func sealedJSON(data []byte) string {
+	if len(data) == 0 || data[0] != '"' {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(data, &s) != nil || !encryption.IsSealed(s) {
+		return ""
+	}
+	return s
+}
+
+// dataKey returns the board's data key, creating one on first write.
+func (d *DB) dataKey(ctx context.Context, boardID uuid.UUID, create bool) ([]byte, error) {
+	if !d.keys.Enabled() {
+		return nil, encryption.ErrUnavailable
+	}
+
+	id := boardID.String()
+	if cached, ok := d.txKeys[id]; ok {
+		return cached, nil
+	}
+	d.keys.mu.Lock()
+	cached, ok := d.keys.cache[id]
+	d.keys.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	queries := repo.New(d.inner)
+	stored, err := queries.GetBoardDataKey(ctx, boardID)
+	if errors.Is(err, pgx.ErrNoRows) && create {
+		_, wrapped, masterKeyID, keyErr := d.keys.keyring.NewDataKey()
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		if err := queries.CreateBoardDataKey(ctx, repo.CreateBoardDataKeyParams{
+			BoardID:     boardID,
+			WrappedKey:  wrapped,
+			MasterKeyID: masterKeyID,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to store data key: %w", err)
+		}
+		// Read it back in case a concurrent writer created one first.
+		stored, err = queries.GetBoardDataKey(ctx, boardID)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: board %s has no data key", encryption.ErrUnavailable, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+
+	dataKey, err := d.keys.keyring.Unwrap(stored.WrappedKey, stored.MasterKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if d.txKeys != nil {
+		d.txKeys[id] = dataKey
+		return dataKey, nil
+	}
+	d.keys.mu.Lock()
+	d.keys.cache[id] = dataKey
+	d.keys.mu.Unlock()
+	return dataKey, nil
+}
+
+// Forget drops a cached data key, e.g. after it has been re-wrapped.
+func (k *Keys) Forget(boardID uuid.UUID) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.cache, boardID.String())
+}
+
+// open decrypts the scanned destinations that hold envelopes, with the data
+// key of the row's board.
+func (d *DB) open(ctx context.Context, fields []pgconn.FieldDescription, dest []interface{}) error {
+	boardID, known := rowBoardID(fields, dest)
+	openEnvelope := func(envelope string) ([]byte, error) {
+		if !known {
+			return nil, fmt.Errorf("encrypted value read without its board ID")
+		}
+		return d.openEnvelope(ctx, boardID, envelope)
+	}
+	for _, target := range dest {
+		switch v := target.(type) {
+		case *string:
+			if encryption.IsSealed(*v) {
+				plaintext, err := openEnvelope(*v)
+				if err != nil {
+					return err
+				}
+				*v = string(plaintext)
+			}
+		case **string:
+			if *v != nil && encryption.IsSealed(**v) {
+				plaintext, err := openEnvelope(**v)
+				if err != nil {
+					return err
+				}
+				s := string(plaintext)
+				*v = &s
+			}
+		case *json.RawMessage:
+			if envelope := sealedJSON(*v); envelope != "" {
+				plaintext, err := openEnvelope(envelope)
+				if err != nil {
+					return err
+				}
+				*v = plaintext
+			}
+		}
+	}
+	return nil
+}
+
+// rowBoardID returns the board a scanned row belongs to: its board_id
+// column or, for boards themselves, its id. Queries that read content must
+// select one of them.
+func rowBoardID(fields []pgconn.FieldDescription, dest []interface{}) (uuid.UUID, bool) {
+	column := -1
+	for i, field := range fields {
+		switch field.Name {
+		case "board_id":
+			column = i
+		case "id":
+			if column < 0 {
+				column = i
+			}
+		}
+	}
+	if column < 0 || column >= len(dest) {
+		return uuid.UUID{}, false
+	}
+	id, ok := dest[column].(*uuid.UUID)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+	return *id, true
+}
+
+func (d *DB) openEnvelope(ctx context.Context, boardID uuid.UUID, envelope string) ([]byte, error) {
+	dataKey, err := d.dataKey(ctx, boardID, false)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.Open(dataKey, boardID.String(), envelope)
+}
+
+type decryptingRows struct {
+	pgx.Rows
+	db  *DB
+	ctx context.Context
+}
+
+func (r *decryptingRows) Scan(dest ...interface{}) error {
+	if err := r.Rows.Scan(dest...); err != nil {
+		return err
+	}
+	return r.db.open(r.ctx, r.Rows.FieldDescriptions(), dest)
+}
+
+// decryptingRow reads the first of its rows, as pgx's own QueryRow does.
+type decryptingRow struct {
+	rows pgx.Rows
+}
+
+func (r *decryptingRow) Scan(dest ...interface{}) error {
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+type errorRow struct {
+	err error
+}
+
+func (r errorRow) Scan(dest ...interface{}) error {
+	return r.err
+}
@@ -0,0 +1,270 @@
+package encrypted
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"draw/internal/db/repo"
+	"draw/pkg/encryption"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB keeps data keys in memory, records content writes and answers
+// every other query with the rows a test sets.
+type fakeDB struct {
+	keys    map[uuid.UUID]repo.BoardDataKey
+	written []interface{}
+	columns []string
+	rows    [][]interface{}
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{keys: make(map[uuid.UUID]repo.BoardDataKey)}
+}
+
+func (f *fakeDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	switch queryName(sql) {
+	case "CreateBoardDataKey":
+		boardID := args[0].(uuid.UUID)
+		if _, ok := f.keys[boardID]; !ok {
+			f.keys[boardID] = repo.BoardDataKey{BoardID: boardID, WrappedKey: args[1].([]byte), MasterKeyID: args[2].(string)}
+		}
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+	case "RewrapBoardDataKey":
+		key, ok := f.keys[args[2].(uuid.UUID)]
+		if !ok || key.MasterKeyID != args[3].(string) {
+			return pgconn.NewCommandTag("UPDATE 0"), nil
+		}
+		key.WrappedKey, key.MasterKeyID = args[0].([]byte), args[1].(string)
+		f.keys[key.BoardID] = key
+		return pgconn.NewCommandTag("UPDATE 1"), nil
+	}
+	f.written = args
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (f *fakeDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	keyColumns := []string{"board_id", "wrapped_key", "master_key_id", "created_at", "rotated_at"}
+	keyRow := func(key repo.BoardDataKey) []interface{} {
+		return []interface{}{key.BoardID, key.WrappedKey, key.MasterKeyID, key.CreatedAt, key.RotatedAt}
+	}
+	switch queryName(sql) {
+	case "GetBoardDataKey":
+		rows := &fakeRows{columns: keyColumns}
+		if key, ok := f.keys[args[0].(uuid.UUID)]; ok {
+			rows.rows = append(rows.rows, keyRow(key))
+		}
+		return rows, nil
+	case "GetBoardDataKeysToRotate":
+		rows := &fakeRows{columns: keyColumns}
+		for _, key := range f.keys {
+			if key.MasterKeyID != args[0].(string) {
+				rows.rows = append(rows.rows, keyRow(key))
+			}
+		}
+		return rows, nil
+	}
+	return &fakeRows{columns: f.columns, rows: f.rows}, nil
+}
+
+func (f *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	rows, err := f.Query(ctx, sql, args...)
+	if err != nil {
+		return errorRow{err: err}
+	}
+	return &decryptingRow{rows: rows}
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]interface{}
+	current []interface{}
+}
+
+func (r *fakeRows) Close()                        {}
+func (r *fakeRows) Err() error                    { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag { return pgconn.NewCommandTag("SELECT") }
+func (r *fakeRows) Values() ([]interface{}, error) {
+	return r.current, nil
+}
+func (r *fakeRows) RawValues() [][]byte { return nil }
+func (r *fakeRows) Conn() *pgx.Conn     { return nil }
+
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := make([]pgconn.FieldDescription, len(r.columns))
+	for i, column := range r.columns {
+		fields[i].Name = column
+	}
+	return fields
+}
+
+func (r *fakeRows) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	r.current, r.rows = r.rows[0], r.rows[1:]
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	for i, value := range r.current {
+		target := reflect.ValueOf(dest[i]).Elem()
+		if value == nil || (reflect.ValueOf(value).Kind() == reflect.Pointer && reflect.ValueOf(value).IsNil()) {
+			target.Set(reflect.Zero(target.Type()))
+			continue
+		}
+		target.Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func newKeyring(t *testing.T, current string, previous ...string) *encryption.Keyring {
+	t.Helper()
+	keyring, err := encryption.ParseKeyring(current, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func newMasterKey(t *testing.T) string {
+	t.Helper()
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// writeComment stores text as a comment of the board and returns what
+// reached the database.
+func writeComment(t *testing.T, fake *fakeDB, db *DB, boardID uuid.UUID, text string) string {
+	t.Helper()
+	err := repo.New(db).EncryptCommentText(context.Background(), repo.EncryptCommentTextParams{
+		ID:      uuid.New(),
+		BoardID: boardID,
+		Text:    text,
+	})
+	if err != nil {
+		t.Fatalf("writing comment: %v", err)
+	}
+	return fake.written[2].(string)
+}
+
+// readComment reads a comment row of the board holding text.
+func readComment(fake *fakeDB, db *DB, boardID uuid.UUID, text string) (string, error) {
+	fake.columns = []string{"id", "board_id", "text"}
+	fake.rows = [][]interface{}{{uuid.New(), boardID, text}}
+	comments, err := repo.New(db).GetPlaintextComments(context.Background(), 1)
+	if err != nil {
+		return "", err
+	}
+	return comments[0].Text, nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	fake := newFakeDB()
+	db := New(fake, NewKeys(newKeyring(t, newMasterKey(t))))
+	boardID := uuid.New()
+
+	stored := writeComment(t, fake, db, boardID, "the plan")
+	if !encryption.IsSealed(stored) || strings.Contains(stored, "the plan") {
+		t.Fatalf("stored %q, want it sealed", stored)
+	}
+	text, err := readComment(fake, db, boardID, stored)
+	if err != nil || text != "the plan" {
+		t.Fatalf("read %q, %v", text, err)
+	}
+}
+
+func TestReadFromAnotherBoard(t *testing.T) {
+	fake := newFakeDB()
+	db := New(fake, NewKeys(newKeyring(t, newMasterKey(t))))
+	board, other := uuid.New(), uuid.New()
+	stored := writeComment(t, fake, db, board, "the plan")
+	writeComment(t, fake, db, other, "something else")
+
+	if _, err := readComment(fake, db, other, stored); !errors.Is(err, encryption.ErrWrongBoard) {
+		t.Errorf("comment copied to another board: got %v, want ErrWrongBoard", err)
+	}
+
+	// Boards hold their ID in id rather than board_id.
+	if err := repo.New(db).EncryptBoardElements(context.Background(), repo.EncryptBoardElementsParams{
+		ID:       board,
+		Elements: json.RawMessage(`[{"id":"a"}]`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	elements := fake.written[1].(json.RawMessage)
+	fake.columns = []string{"id", "elements"}
+	fake.rows = [][]interface{}{{other, elements}}
+	if _, err := repo.New(db).GetPlaintextBoards(context.Background(), 1); !errors.Is(err, encryption.ErrWrongBoard) {
+		t.Errorf("elements copied to another board: got %v, want ErrWrongBoard", err)
+	}
+	fake.rows = [][]interface{}{{board, elements}}
+	boards, err := repo.New(db).GetPlaintextBoards(context.Background(), 1)
+	if err != nil || string(boards[0].Elements) != `[{"id":"a"}]` {
+		t.Errorf("reading elements: got %s, %v", boards[0].Elements, err)
+	}
+}
+
+func TestReservedPrefix(t *testing.T) {
+	for _, keys := range []*Keys{NewKeys(newKeyring(t, newMasterKey(t))), NewKeys(nil)} {
+		db := New(newFakeDB(), keys)
+		err := repo.New(db).EncryptCommentText(context.Background(), repo.EncryptCommentTextParams{
+			ID:      uuid.New(),
+			BoardID: uuid.New(),
+			Text:    "enc:v1:looks like an envelope",
+		})
+		if !errors.Is(err, encryption.ErrReservedPrefix) {
+			t.Errorf("encryption enabled %v: got %v, want ErrReservedPrefix", keys.Enabled(), err)
+		}
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	fake := newFakeDB()
+	boardID := uuid.New()
+	stored := writeComment(t, fake, New(fake, NewKeys(newKeyring(t, newMasterKey(t)))), boardID, "the plan")
+
+	wrong := New(fake, NewKeys(newKeyring(t, newMasterKey(t))))
+	if _, err := readComment(fake, wrong, boardID, stored); !errors.Is(err, encryption.ErrUnavailable) {
+		t.Errorf("wrong master key: got %v, want ErrUnavailable", err)
+	}
+	missing := New(fake, NewKeys(nil))
+	if _, err := readComment(fake, missing, boardID, stored); !errors.Is(err, encryption.ErrUnavailable) {
+		t.Errorf("no master key: got %v, want ErrUnavailable", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	fake := newFakeDB()
+	oldKey, newKey := newMasterKey(t), newMasterKey(t)
+	boardID := uuid.New()
+	stored := writeComment(t, fake, New(fake, NewKeys(newKeyring(t, oldKey))), boardID, "the plan")
+
+	rotating := New(fake, NewKeys(newKeyring(t, newKey, oldKey)))
+	rotated, err := Rotate(context.Background(), rotating)
+	if err != nil || rotated != 1 {
+		t.Fatalf("Rotate: got %d, %v; want 1", rotated, err)
+	}
+	if again, err := Rotate(context.Background(), rotating); err != nil || again != 0 {
+		t.Errorf("second Rotate: got %d, %v; want 0", again, err)
+	}
+
+	// With the old key removed, content written under it still reads.
+	after := New(fake, NewKeys(newKeyring(t, newKey)))
+	text, err := readComment(fake, after, boardID, stored)
+	if err != nil || text != "the plan" {
+		t.Errorf("read after rotation: got %q, %v", text, err)
+	}
+}
@@ -0,0 +1,155 @@
+package encrypted
+
+import (
+	"context"
+	"fmt"
+
+	"draw/internal/db/repo"
+	"draw/pkg/encryption"
+)
+
+// jobBatchSize is how many rows the jobs handle per query.
+const jobBatchSize = 100
+
+// Rotate re-wraps every data key that isn't wrapped by the current master
+// key. Content itself is not touched. The previous master keys must still be
+// configured; once Rotate has finished they can be removed.
+func Rotate(ctx context.Context, db *DB) (int, error) {
+	if !db.keys.Enabled() {
+		return 0, fmt.Errorf("%w: ENCRYPTION_KEY is not set", encryption.ErrUnavailable)
+	}
+	keyring := db.keys.keyring
+	queries := repo.New(db.inner)
+
+	rotated := 0
+	for {
+		keys, err := queries.GetBoardDataKeysToRotate(ctx, repo.GetBoardDataKeysToRotateParams{
+			MasterKeyID: keyring.CurrentID(),
+			Limit:       jobBatchSize,
+		})
+		if err != nil {
+			return rotated, fmt.Errorf("failed to list data keys: %w", err)
+		}
+		if len(keys) == 0 {
+			return rotated, nil
+		}
+
+		for _, key := range keys {
+			dataKey, err := keyring.Unwrap(key.WrappedKey, key.MasterKeyID)
+			if err != nil {
+				// Stop rather than skip, or the same key would be listed forever.
+				return rotated, fmt.Errorf("failed to unwrap data key for board %s: %w", key.BoardID, err)
+			}
+			wrapped, masterKeyID, err := keyring.Wrap(dataKey)
+			if err != nil {
+				return rotated, err
+			}
+			// A key rotated concurrently no longer matches and is left alone.
+			if _, err := queries.RewrapBoardDataKey(ctx, repo.RewrapBoardDataKeyParams{
+				BoardID:        key.BoardID,
+				OldMasterKeyID: key.MasterKeyID,
+				WrappedKey:     wrapped,
+				NewMasterKeyID: masterKeyID,
+			}); err != nil {
+				return rotated, fmt.Errorf("failed to store data key for board %s: %w", key.BoardID, err)
+			}
+			db.keys.Forget(key.BoardID)
+			rotated++
+		}
+	}
+}
+
+// EncryptExisting encrypts content written before encryption was enabled.
+// It is safe to interrupt and run again.
+func EncryptExisting(ctx context.Context, db *DB) (int, error) {
+	if !db.keys.Enabled() {
+		return 0, fmt.Errorf("%w: ENCRYPTION_KEY is not set", encryption.ErrUnavailable)
+	}
+	// Writes go through db, which encrypts them.
+	queries := repo.New(db)
+
+	encrypted := 0
+	for {
+		boards, err := queries.GetPlaintextBoards(ctx, jobBatchSize)
+		if err != nil {
+			return encrypted, fmt.Errorf("failed to list boards: %w", err)
+		}
+		if len(boards) == 0 {
+			break
+		}
+		for _, board := range boards {
+			if err := queries.EncryptBoardElements(ctx, repo.EncryptBoardElementsParams{
+				ID:       board.ID,
+				Elements: board.Elements,
+			}); err != nil {
+				return encrypted, fmt.Errorf("failed to encrypt board %s: %w", board.ID, err)
+			}
+			encrypted++
+		}
+	}
+
+	for {
+		comments, err := queries.GetPlaintextComments(ctx, jobBatchSize)
+		if err != nil {
+			return encrypted, fmt.Errorf("failed to list comments: %w", err)
+		}
+		if len(comments) == 0 {
+			break
+		}
+		for _, comment := range comments {
+			if err := queries.EncryptCommentText(ctx, repo.EncryptCommentTextParams{
+				ID:      comment.ID,
+				BoardID: comment.BoardID,
+				Text:    comment.Text,
+			}); err != nil {
+				return encrypted, fmt.Errorf("failed to encrypt comment %s: %w", comment.ID, err)
+			}
+			encrypted++
+		}
+	}
+
+	for {
+		instructions, err := queries.GetPlaintextInstructions(ctx, jobBatchSize)
+		if err != nil {
+			return encrypted, fmt.Errorf("failed to list instructions: %w", err)
+		}
+		if len(instructions) == 0 {
+			break
+		}
+		for _, instruction := range instructions {
+			if err := queries.EncryptInstruction(ctx, repo.EncryptInstructionParams{
+				ID:            instruction.ID,
+				BoardID:       instruction.BoardID,
+				Instruction:   instruction.Instruction,
+				RawResponse:   instruction.RawResponse,
+				InverseAction: instruction.InverseAction,
+			}); err != nil {
+				return encrypted, fmt.Errorf("failed to encrypt instruction %s: %w", instruction.ID, err)
+			}
+			encrypted++
+		}
+	}
+
+	for {
+		changes, err := queries.GetPlaintextPendingChanges(ctx, jobBatchSize)
+		if err != nil {
+			return encrypted, fmt.Errorf("failed to list pending changes: %w", err)
+		}
+		if len(changes) == 0 {
+			break
+		}
+		for _, change := range changes {
+			if err := queries.EncryptPendingChange(ctx, repo.EncryptPendingChangeParams{
+				ID:          change.ID,
+				BoardID:     change.BoardID,
+				Instruction: change.Instruction,
+				Response:    change.Response,
+			}); err != nil {
+				return encrypted, fmt.Errorf("failed to encrypt pending change %s: %w", change.ID, err)
+			}
+			encrypted++
+		}
+	}
+
+	return encrypted, nil
+}
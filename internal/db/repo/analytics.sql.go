@@ -0,0 +1,195 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: analytics.sql
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteFailurePatterns = `-- name: DeleteFailurePatterns :exec
+DELETE FROM "instruction_failure_pattern_daily" WHERE day = $1
+`
+
+func (q *Queries) DeleteFailurePatterns(ctx context.Context, day time.Time) error {
+	_, err := q.db.Exec(ctx, deleteFailurePatterns, day)
+	return err
+}
+
+const getFailedInstructionsBetween = `-- name: GetFailedInstructionsBetween :many
+SELECT board_id, intent, instruction FROM "board_instruction"
+WHERE created_at >= $1 AND created_at < $2 AND outcome <> 'success' AND instruction <> ''
+ORDER BY created_at
+LIMIT $3
+`
+
+type GetFailedInstructionsBetweenParams struct {
+	Since   time.Time `db:"since" json:"since"`
+	Until   time.Time `db:"until" json:"until"`
+	MaxRows int32     `db:"max_rows" json:"maxRows"`
+}
+
+type GetFailedInstructionsBetweenRow struct {
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	Intent      string    `db:"intent" json:"intent"`
+	Instruction string    `db:"instruction" json:"instruction"`
+}
+
+func (q *Queries) GetFailedInstructionsBetween(ctx context.Context, arg GetFailedInstructionsBetweenParams) ([]GetFailedInstructionsBetweenRow, error) {
+	rows, err := q.db.Query(ctx, getFailedInstructionsBetween, arg.Since, arg.Until, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetFailedInstructionsBetweenRow{}
+	for rows.Next() {
+		var i GetFailedInstructionsBetweenRow
+		if err := rows.Scan(&i.BoardID, &i.Intent, &i.Instruction); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getIntentRollups = `-- name: GetIntentRollups :many
+SELECT intent, outcome, provider, SUM(count)::bigint AS count, SUM(latency_ms_sum)::bigint AS latency_ms_sum, MAX(latency_ms_max)::integer AS latency_ms_max
+FROM "instruction_intent_daily"
+WHERE day >= $1 AND day <= $2
+GROUP BY intent, outcome, provider
+ORDER BY intent, outcome, provider
+`
+
+type GetIntentRollupsParams struct {
+	FromDay time.Time `db:"from_day" json:"fromDay"`
+	ToDay   time.Time `db:"to_day" json:"toDay"`
+}
+
+type GetIntentRollupsRow struct {
+	Intent       string `db:"intent" json:"intent"`
+	Outcome      string `db:"outcome" json:"outcome"`
+	Provider     string `db:"provider" json:"provider"`
+	Count        int64  `db:"count" json:"count"`
+	LatencyMsSum int64  `db:"latency_ms_sum" json:"latencyMsSum"`
+	LatencyMsMax int32  `db:"latency_ms_max" json:"latencyMsMax"`
+}
+
+func (q *Queries) GetIntentRollups(ctx context.Context, arg GetIntentRollupsParams) ([]GetIntentRollupsRow, error) {
+	rows, err := q.db.Query(ctx, getIntentRollups, arg.FromDay, arg.ToDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetIntentRollupsRow{}
+	for rows.Next() {
+		var i GetIntentRollupsRow
+		if err := rows.Scan(
+			&i.Intent,
+			&i.Outcome,
+			&i.Provider,
+			&i.Count,
+			&i.LatencyMsSum,
+			&i.LatencyMsMax,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTopFailurePatterns = `-- name: GetTopFailurePatterns :many
+SELECT intent, pattern, SUM(count)::bigint AS count
+FROM "instruction_failure_pattern_daily"
+WHERE day >= $1 AND day <= $2
+GROUP BY intent, pattern
+ORDER BY count DESC, intent, pattern
+LIMIT $3
+`
+
+type GetTopFailurePatternsParams struct {
+	FromDay     time.Time `db:"from_day" json:"fromDay"`
+	ToDay       time.Time `db:"to_day" json:"toDay"`
+	MaxPatterns int32     `db:"max_patterns" json:"maxPatterns"`
+}
+
+type GetTopFailurePatternsRow struct {
+	Intent  string `db:"intent" json:"intent"`
+	Pattern string `db:"pattern" json:"pattern"`
+	Count   int64  `db:"count" json:"count"`
+}
+
+func (q *Queries) GetTopFailurePatterns(ctx context.Context, arg GetTopFailurePatternsParams) ([]GetTopFailurePatternsRow, error) {
+	rows, err := q.db.Query(ctx, getTopFailurePatterns, arg.FromDay, arg.ToDay, arg.MaxPatterns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetTopFailurePatternsRow{}
+	for rows.Next() {
+		var i GetTopFailurePatternsRow
+		if err := rows.Scan(&i.Intent, &i.Pattern, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rollupInstructionIntents = `-- name: RollupInstructionIntents :exec
+INSERT INTO "instruction_intent_daily" (day, intent, outcome, provider, count, latency_ms_sum, latency_ms_max)
+SELECT (created_at AT TIME ZONE 'UTC')::date, intent, outcome, COALESCE(provider, ''), COUNT(*), COALESCE(SUM(latency_ms), 0), COALESCE(MAX(latency_ms), 0)
+FROM "board_instruction"
+WHERE created_at >= $1 AND created_at < $2
+GROUP BY 1, 2, 3, 4
+ON CONFLICT (day, intent, outcome, provider) DO UPDATE SET
+	count = EXCLUDED.count,
+	latency_ms_sum = EXCLUDED.latency_ms_sum,
+	latency_ms_max = EXCLUDED.latency_ms_max
+`
+
+type RollupInstructionIntentsParams struct {
+	Since time.Time `db:"since" json:"since"`
+	Until time.Time `db:"until" json:"until"`
+}
+
+func (q *Queries) RollupInstructionIntents(ctx context.Context, arg RollupInstructionIntentsParams) error {
+	_, err := q.db.Exec(ctx, rollupInstructionIntents, arg.Since, arg.Until)
+	return err
+}
+
+const upsertFailurePattern = `-- name: UpsertFailurePattern :exec
+INSERT INTO "instruction_failure_pattern_daily" (day, intent, pattern, count) VALUES ($1, $2, $3, $4)
+ON CONFLICT (day, intent, pattern) DO UPDATE SET count = EXCLUDED.count
+`
+
+type UpsertFailurePatternParams struct {
+	Day     time.Time `db:"day" json:"day"`
+	Intent  string    `db:"intent" json:"intent"`
+	Pattern string    `db:"pattern" json:"pattern"`
+	Count   int64     `db:"count" json:"count"`
+}
+
+func (q *Queries) UpsertFailurePattern(ctx context.Context, arg UpsertFailurePatternParams) error {
+	_, err := q.db.Exec(ctx, upsertFailurePattern,
+		arg.Day,
+		arg.Intent,
+		arg.Pattern,
+		arg.Count,
+	)
+	return err
+}
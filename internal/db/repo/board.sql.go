@@ -12,17 +12,56 @@ import (
 	"github.com/google/uuid"
 )
 
+const archiveBoard = `-- name: ArchiveBoard :one
+UPDATE "board" SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP) WHERE id = $1 AND owner_id = $2 RETURNING id, name, owner_id, elements, created_at, updated_at, icon, color, protected, timezone, locale, arrow_repair, legal_hold_reason, legal_hold_at, archived_at
+`
+
+type ArchiveBoardParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	OwnerID string    `db:"owner_id" json:"ownerId"`
+}
+
+func (q *Queries) ArchiveBoard(ctx context.Context, arg ArchiveBoardParams) (Board, error) {
+	row := q.db.QueryRow(ctx, archiveBoard, arg.ID, arg.OwnerID)
+	var i Board
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.OwnerID,
+		&i.Elements,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Protected,
+		&i.Timezone,
+		&i.Locale,
+		&i.ArrowRepair,
+		&i.LegalHoldReason,
+		&i.LegalHoldAt,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
 const createBoard = `-- name: CreateBoard :one
-INSERT INTO "board" (name, owner_id) VALUES ($1, $2) RETURNING id, name, owner_id, elements, created_at, updated_at
+INSERT INTO "board" (name, owner_id, icon, color) VALUES ($1, $2, $3, $4) RETURNING id, name, owner_id, elements, created_at, updated_at, icon, color, protected, timezone, locale, arrow_repair, legal_hold_reason, legal_hold_at, archived_at
 `
 
 type CreateBoardParams struct {
-	Name    string `db:"name" json:"name"`
-	OwnerID string `db:"owner_id" json:"ownerId"`
+	Name    string  `db:"name" json:"name"`
+	OwnerID string  `db:"owner_id" json:"ownerId"`
+	Icon    *string `db:"icon" json:"icon"`
+	Color   *string `db:"color" json:"color"`
 }
 
 func (q *Queries) CreateBoard(ctx context.Context, arg CreateBoardParams) (Board, error) {
-	row := q.db.QueryRow(ctx, createBoard, arg.Name, arg.OwnerID)
+	row := q.db.QueryRow(ctx, createBoard,
+		arg.Name,
+		arg.OwnerID,
+		arg.Icon,
+		arg.Color,
+	)
 	var i Board
 	err := row.Scan(
 		&i.ID,
@@ -31,6 +70,15 @@ func (q *Queries) CreateBoard(ctx context.Context, arg CreateBoardParams) (Board
 		&i.Elements,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Protected,
+		&i.Timezone,
+		&i.Locale,
+		&i.ArrowRepair,
+		&i.LegalHoldReason,
+		&i.LegalHoldAt,
+		&i.ArchivedAt,
 	)
 	return i, err
 }
@@ -49,8 +97,19 @@ func (q *Queries) DeleteBoard(ctx context.Context, arg DeleteBoardParams) error
 	return err
 }
 
+const getBoardArrowRepair = `-- name: GetBoardArrowRepair :one
+SELECT arrow_repair FROM "board" WHERE id = $1
+`
+
+func (q *Queries) GetBoardArrowRepair(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRow(ctx, getBoardArrowRepair, id)
+	var arrow_repair string
+	err := row.Scan(&arrow_repair)
+	return arrow_repair, err
+}
+
 const getBoardByID = `-- name: GetBoardByID :one
-SELECT id, name, owner_id, elements, created_at, updated_at FROM "board" WHERE id = $1 AND owner_id = $2
+SELECT id, name, owner_id, elements, created_at, updated_at, icon, color, protected, timezone, locale, arrow_repair, legal_hold_reason, legal_hold_at, archived_at FROM "board" WHERE id = $1 AND owner_id = $2
 `
 
 type GetBoardByIDParams struct {
@@ -68,16 +127,84 @@ func (q *Queries) GetBoardByID(ctx context.Context, arg GetBoardByIDParams) (Boa
 		&i.Elements,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Protected,
+		&i.Timezone,
+		&i.Locale,
+		&i.ArrowRepair,
+		&i.LegalHoldReason,
+		&i.LegalHoldAt,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
+const getBoardForUpdate = `-- name: GetBoardForUpdate :one
+SELECT id, name, owner_id, elements, created_at, updated_at, icon, color, protected, timezone, locale, arrow_repair, legal_hold_reason, legal_hold_at, archived_at FROM "board" WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetBoardForUpdate(ctx context.Context, id uuid.UUID) (Board, error) {
+	row := q.db.QueryRow(ctx, getBoardForUpdate, id)
+	var i Board
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.OwnerID,
+		&i.Elements,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Protected,
+		&i.Timezone,
+		&i.Locale,
+		&i.ArrowRepair,
+		&i.LegalHoldReason,
+		&i.LegalHoldAt,
+		&i.ArchivedAt,
 	)
 	return i, err
 }
 
+const getBoardLocale = `-- name: GetBoardLocale :one
+SELECT timezone, locale FROM "board" WHERE id = $1
+`
+
+type GetBoardLocaleRow struct {
+	Timezone *string `db:"timezone" json:"timezone"`
+	Locale   *string `db:"locale" json:"locale"`
+}
+
+func (q *Queries) GetBoardLocale(ctx context.Context, id uuid.UUID) (GetBoardLocaleRow, error) {
+	row := q.db.QueryRow(ctx, getBoardLocale, id)
+	var i GetBoardLocaleRow
+	err := row.Scan(&i.Timezone, &i.Locale)
+	return i, err
+}
+
+const getBoardProtection = `-- name: GetBoardProtection :one
+SELECT protected FROM "board" WHERE id = $1
+`
+
+func (q *Queries) GetBoardProtection(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRow(ctx, getBoardProtection, id)
+	var protected bool
+	err := row.Scan(&protected)
+	return protected, err
+}
+
 const getBoardsByUserID = `-- name: GetBoardsByUserID :many
-SELECT id, name, owner_id, elements, created_at, updated_at FROM "board" WHERE owner_id = $1
+SELECT id, name, owner_id, elements, created_at, updated_at, icon, color, protected, timezone, locale, arrow_repair, legal_hold_reason, legal_hold_at, archived_at FROM "board" WHERE owner_id = $1 AND (archived_at IS NULL OR $2::boolean)
 `
 
-func (q *Queries) GetBoardsByUserID(ctx context.Context, ownerID string) ([]Board, error) {
-	rows, err := q.db.Query(ctx, getBoardsByUserID, ownerID)
+type GetBoardsByUserIDParams struct {
+	OwnerID         string `db:"owner_id" json:"ownerId"`
+	IncludeArchived bool   `db:"include_archived" json:"includeArchived"`
+}
+
+func (q *Queries) GetBoardsByUserID(ctx context.Context, arg GetBoardsByUserIDParams) ([]Board, error) {
+	rows, err := q.db.Query(ctx, getBoardsByUserID, arg.OwnerID, arg.IncludeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +219,15 @@ func (q *Queries) GetBoardsByUserID(ctx context.Context, ownerID string) ([]Boar
 			&i.Elements,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Icon,
+			&i.Color,
+			&i.Protected,
+			&i.Timezone,
+			&i.Locale,
+			&i.ArrowRepair,
+			&i.LegalHoldReason,
+			&i.LegalHoldAt,
+			&i.ArchivedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -103,15 +239,53 @@ func (q *Queries) GetBoardsByUserID(ctx context.Context, ownerID string) ([]Boar
 	return items, nil
 }
 
+const unarchiveBoard = `-- name: UnarchiveBoard :one
+UPDATE "board" SET archived_at = NULL WHERE id = $1 AND owner_id = $2 RETURNING id, name, owner_id, elements, created_at, updated_at, icon, color, protected, timezone, locale, arrow_repair, legal_hold_reason, legal_hold_at, archived_at
+`
+
+type UnarchiveBoardParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	OwnerID string    `db:"owner_id" json:"ownerId"`
+}
+
+func (q *Queries) UnarchiveBoard(ctx context.Context, arg UnarchiveBoardParams) (Board, error) {
+	row := q.db.QueryRow(ctx, unarchiveBoard, arg.ID, arg.OwnerID)
+	var i Board
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.OwnerID,
+		&i.Elements,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Protected,
+		&i.Timezone,
+		&i.Locale,
+		&i.ArrowRepair,
+		&i.LegalHoldReason,
+		&i.LegalHoldAt,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
 const updateBoard = `-- name: UpdateBoard :one
-UPDATE "board" SET name = $2, elements = $3 WHERE id = $1 AND owner_id = $4 RETURNING id, name, owner_id, elements, created_at, updated_at
+UPDATE "board" SET name = $2, elements = $3, icon = $5, color = $6, protected = $7, timezone = $8, locale = $9, arrow_repair = $10 WHERE id = $1 AND owner_id = $4 RETURNING id, name, owner_id, elements, created_at, updated_at, icon, color, protected, timezone, locale, arrow_repair, legal_hold_reason, legal_hold_at, archived_at
 `
 
 type UpdateBoardParams struct {
-	ID       uuid.UUID       `db:"id" json:"id"`
-	Name     string          `db:"name" json:"name"`
-	Elements json.RawMessage `db:"elements" json:"elements"`
-	OwnerID  string          `db:"owner_id" json:"ownerId"`
+	ID          uuid.UUID       `db:"id" json:"id"`
+	Name        string          `db:"name" json:"name"`
+	Elements    json.RawMessage `db:"elements" json:"elements"`
+	OwnerID     string          `db:"owner_id" json:"ownerId"`
+	Icon        *string         `db:"icon" json:"icon"`
+	Color       *string         `db:"color" json:"color"`
+	Protected   bool            `db:"protected" json:"protected"`
+	Timezone    *string         `db:"timezone" json:"timezone"`
+	Locale      *string         `db:"locale" json:"locale"`
+	ArrowRepair string          `db:"arrow_repair" json:"arrowRepair"`
 }
 
 func (q *Queries) UpdateBoard(ctx context.Context, arg UpdateBoardParams) (Board, error) {
@@ -120,7 +294,45 @@ func (q *Queries) UpdateBoard(ctx context.Context, arg UpdateBoardParams) (Board
 		arg.Name,
 		arg.Elements,
 		arg.OwnerID,
+		arg.Icon,
+		arg.Color,
+		arg.Protected,
+		arg.Timezone,
+		arg.Locale,
+		arg.ArrowRepair,
+	)
+	var i Board
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.OwnerID,
+		&i.Elements,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Protected,
+		&i.Timezone,
+		&i.Locale,
+		&i.ArrowRepair,
+		&i.LegalHoldReason,
+		&i.LegalHoldAt,
+		&i.ArchivedAt,
 	)
+	return i, err
+}
+
+const updateBoardElements = `-- name: UpdateBoardElements :one
+UPDATE "board" SET elements = $2 WHERE id = $1 RETURNING id, name, owner_id, elements, created_at, updated_at, icon, color, protected, timezone, locale, arrow_repair, legal_hold_reason, legal_hold_at, archived_at
+`
+
+type UpdateBoardElementsParams struct {
+	ID       uuid.UUID       `db:"id" json:"id"`
+	Elements json.RawMessage `db:"elements" json:"elements"`
+}
+
+func (q *Queries) UpdateBoardElements(ctx context.Context, arg UpdateBoardElementsParams) (Board, error) {
+	row := q.db.QueryRow(ctx, updateBoardElements, arg.ID, arg.Elements)
 	var i Board
 	err := row.Scan(
 		&i.ID,
@@ -129,6 +341,15 @@ func (q *Queries) UpdateBoard(ctx context.Context, arg UpdateBoardParams) (Board
 		&i.Elements,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Protected,
+		&i.Timezone,
+		&i.Locale,
+		&i.ArrowRepair,
+		&i.LegalHoldReason,
+		&i.LegalHoldAt,
+		&i.ArchivedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,235 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: comment.sql
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createComment = `-- name: CreateComment :one
+INSERT INTO "board_comment" (board_id, element_id, author_id, text) VALUES ($1, $2, $3, $4) RETURNING id, board_id, element_id, author_id, text, resolved, orphaned, created_at, updated_at
+`
+
+type CreateCommentParams struct {
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	ElementID *string   `db:"element_id" json:"elementId"`
+	AuthorID  string    `db:"author_id" json:"authorId"`
+	Text      string    `db:"text" json:"text"`
+}
+
+func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (BoardComment, error) {
+	row := q.db.QueryRow(ctx, createComment,
+		arg.BoardID,
+		arg.ElementID,
+		arg.AuthorID,
+		arg.Text,
+	)
+	var i BoardComment
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.ElementID,
+		&i.AuthorID,
+		&i.Text,
+		&i.Resolved,
+		&i.Orphaned,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteComment = `-- name: DeleteComment :execrows
+DELETE FROM "board_comment" WHERE id = $1 AND board_id = $2
+`
+
+type DeleteCommentParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+}
+
+func (q *Queries) DeleteComment(ctx context.Context, arg DeleteCommentParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteComment, arg.ID, arg.BoardID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getCommentByID = `-- name: GetCommentByID :one
+SELECT id, board_id, element_id, author_id, text, resolved, orphaned, created_at, updated_at FROM "board_comment" WHERE id = $1 AND board_id = $2
+`
+
+type GetCommentByIDParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+}
+
+func (q *Queries) GetCommentByID(ctx context.Context, arg GetCommentByIDParams) (BoardComment, error) {
+	row := q.db.QueryRow(ctx, getCommentByID, arg.ID, arg.BoardID)
+	var i BoardComment
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.ElementID,
+		&i.AuthorID,
+		&i.Text,
+		&i.Resolved,
+		&i.Orphaned,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getCommentsByBoardID = `-- name: GetCommentsByBoardID :many
+SELECT id, board_id, element_id, author_id, text, resolved, orphaned, created_at, updated_at FROM "board_comment" WHERE board_id = $1 ORDER BY created_at ASC
+`
+
+func (q *Queries) GetCommentsByBoardID(ctx context.Context, boardID uuid.UUID) ([]BoardComment, error) {
+	rows, err := q.db.Query(ctx, getCommentsByBoardID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BoardComment{}
+	for rows.Next() {
+		var i BoardComment
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.ElementID,
+			&i.AuthorID,
+			&i.Text,
+			&i.Resolved,
+			&i.Orphaned,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const importComment = `-- name: ImportComment :one
+INSERT INTO "board_comment" (board_id, element_id, author_id, text, resolved, orphaned, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, board_id, element_id, author_id, text, resolved, orphaned, created_at, updated_at
+`
+
+type ImportCommentParams struct {
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	ElementID *string   `db:"element_id" json:"elementId"`
+	AuthorID  string    `db:"author_id" json:"authorId"`
+	Text      string    `db:"text" json:"text"`
+	Resolved  bool      `db:"resolved" json:"resolved"`
+	Orphaned  bool      `db:"orphaned" json:"orphaned"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+func (q *Queries) ImportComment(ctx context.Context, arg ImportCommentParams) (BoardComment, error) {
+	row := q.db.QueryRow(ctx, importComment,
+		arg.BoardID,
+		arg.ElementID,
+		arg.AuthorID,
+		arg.Text,
+		arg.Resolved,
+		arg.Orphaned,
+		arg.CreatedAt,
+	)
+	var i BoardComment
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.ElementID,
+		&i.AuthorID,
+		&i.Text,
+		&i.Resolved,
+		&i.Orphaned,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const orphanComments = `-- name: OrphanComments :many
+UPDATE "board_comment" SET orphaned = true, updated_at = CURRENT_TIMESTAMP
+WHERE board_id = $1 AND element_id IS NOT NULL AND NOT orphaned AND NOT (element_id = ANY($2::text[])) RETURNING id, board_id, element_id, author_id, text, resolved, orphaned, created_at, updated_at
+`
+
+type OrphanCommentsParams struct {
+	BoardID    uuid.UUID `db:"board_id" json:"boardId"`
+	ElementIds []string  `db:"element_ids" json:"elementIds"`
+}
+
+func (q *Queries) OrphanComments(ctx context.Context, arg OrphanCommentsParams) ([]BoardComment, error) {
+	rows, err := q.db.Query(ctx, orphanComments, arg.BoardID, arg.ElementIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BoardComment{}
+	for rows.Next() {
+		var i BoardComment
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.ElementID,
+			&i.AuthorID,
+			&i.Text,
+			&i.Resolved,
+			&i.Orphaned,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateComment = `-- name: UpdateComment :one
+UPDATE "board_comment" SET text = $3, resolved = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND board_id = $2 RETURNING id, board_id, element_id, author_id, text, resolved, orphaned, created_at, updated_at
+`
+
+type UpdateCommentParams struct {
+	ID       uuid.UUID `db:"id" json:"id"`
+	BoardID  uuid.UUID `db:"board_id" json:"boardId"`
+	Text     string    `db:"text" json:"text"`
+	Resolved bool      `db:"resolved" json:"resolved"`
+}
+
+func (q *Queries) UpdateComment(ctx context.Context, arg UpdateCommentParams) (BoardComment, error) {
+	row := q.db.QueryRow(ctx, updateComment,
+		arg.ID,
+		arg.BoardID,
+		arg.Text,
+		arg.Resolved,
+	)
+	var i BoardComment
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.ElementID,
+		&i.AuthorID,
+		&i.Text,
+		&i.Resolved,
+		&i.Orphaned,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,181 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: demo.sql
+
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const countDemoBoardsBySession = `-- name: CountDemoBoardsBySession :one
+SELECT COUNT(*) FROM "demo_board" WHERE session_id = $1 AND expires_at > CURRENT_TIMESTAMP
+`
+
+func (q *Queries) CountDemoBoardsBySession(ctx context.Context, sessionID string) (int64, error) {
+	row := q.db.QueryRow(ctx, countDemoBoardsBySession, sessionID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createDemoBoard = `-- name: CreateDemoBoard :one
+INSERT INTO "demo_board" (session_id, name, expires_at, max_expires_at) VALUES ($1, $2, $3, $4) RETURNING id, session_id, name, elements, instruction_count, created_at, expires_at, max_expires_at
+`
+
+type CreateDemoBoardParams struct {
+	SessionID    string    `db:"session_id" json:"sessionId"`
+	Name         string    `db:"name" json:"name"`
+	ExpiresAt    time.Time `db:"expires_at" json:"expiresAt"`
+	MaxExpiresAt time.Time `db:"max_expires_at" json:"maxExpiresAt"`
+}
+
+func (q *Queries) CreateDemoBoard(ctx context.Context, arg CreateDemoBoardParams) (DemoBoard, error) {
+	row := q.db.QueryRow(ctx, createDemoBoard,
+		arg.SessionID,
+		arg.Name,
+		arg.ExpiresAt,
+		arg.MaxExpiresAt,
+	)
+	var i DemoBoard
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Name,
+		&i.Elements,
+		&i.InstructionCount,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.MaxExpiresAt,
+	)
+	return i, err
+}
+
+const deleteDemoBoard = `-- name: DeleteDemoBoard :exec
+DELETE FROM "demo_board" WHERE id = $1
+`
+
+func (q *Queries) DeleteDemoBoard(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteDemoBoard, id)
+	return err
+}
+
+const deleteExpiredDemoBoards = `-- name: DeleteExpiredDemoBoards :execrows
+DELETE FROM "demo_board" WHERE expires_at <= CURRENT_TIMESTAMP
+`
+
+func (q *Queries) DeleteExpiredDemoBoards(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredDemoBoards)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getDemoBoard = `-- name: GetDemoBoard :one
+SELECT id, session_id, name, elements, instruction_count, created_at, expires_at, max_expires_at FROM "demo_board" WHERE id = $1 AND session_id = $2 AND expires_at > CURRENT_TIMESTAMP
+`
+
+type GetDemoBoardParams struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	SessionID string    `db:"session_id" json:"sessionId"`
+}
+
+func (q *Queries) GetDemoBoard(ctx context.Context, arg GetDemoBoardParams) (DemoBoard, error) {
+	row := q.db.QueryRow(ctx, getDemoBoard, arg.ID, arg.SessionID)
+	var i DemoBoard
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Name,
+		&i.Elements,
+		&i.InstructionCount,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.MaxExpiresAt,
+	)
+	return i, err
+}
+
+const getDemoBoardForClaim = `-- name: GetDemoBoardForClaim :one
+SELECT id, session_id, name, elements, instruction_count, created_at, expires_at, max_expires_at FROM "demo_board" WHERE id = $1 AND session_id = $2 AND expires_at > CURRENT_TIMESTAMP FOR UPDATE
+`
+
+type GetDemoBoardForClaimParams struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	SessionID string    `db:"session_id" json:"sessionId"`
+}
+
+func (q *Queries) GetDemoBoardForClaim(ctx context.Context, arg GetDemoBoardForClaimParams) (DemoBoard, error) {
+	row := q.db.QueryRow(ctx, getDemoBoardForClaim, arg.ID, arg.SessionID)
+	var i DemoBoard
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Name,
+		&i.Elements,
+		&i.InstructionCount,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.MaxExpiresAt,
+	)
+	return i, err
+}
+
+const takeDemoInstruction = `-- name: TakeDemoInstruction :one
+UPDATE "demo_board" SET instruction_count = instruction_count + 1
+WHERE id = $1 AND session_id = $2 AND expires_at > CURRENT_TIMESTAMP AND instruction_count < $3::integer
+RETURNING instruction_count
+`
+
+type TakeDemoInstructionParams struct {
+	ID              uuid.UUID `db:"id" json:"id"`
+	SessionID       string    `db:"session_id" json:"sessionId"`
+	MaxInstructions int32     `db:"max_instructions" json:"maxInstructions"`
+}
+
+func (q *Queries) TakeDemoInstruction(ctx context.Context, arg TakeDemoInstructionParams) (int32, error) {
+	row := q.db.QueryRow(ctx, takeDemoInstruction, arg.ID, arg.SessionID, arg.MaxInstructions)
+	var instruction_count int32
+	err := row.Scan(&instruction_count)
+	return instruction_count, err
+}
+
+const updateDemoBoardElements = `-- name: UpdateDemoBoardElements :one
+UPDATE "demo_board" SET elements = $1, expires_at = LEAST(max_expires_at, $2)
+WHERE id = $3 AND session_id = $4 AND expires_at > CURRENT_TIMESTAMP
+RETURNING id, session_id, name, elements, instruction_count, created_at, expires_at, max_expires_at
+`
+
+type UpdateDemoBoardElementsParams struct {
+	Elements  json.RawMessage `db:"elements" json:"elements"`
+	ExpiresAt time.Time       `db:"expires_at" json:"expiresAt"`
+	ID        uuid.UUID       `db:"id" json:"id"`
+	SessionID string          `db:"session_id" json:"sessionId"`
+}
+
+func (q *Queries) UpdateDemoBoardElements(ctx context.Context, arg UpdateDemoBoardElementsParams) (DemoBoard, error) {
+	row := q.db.QueryRow(ctx, updateDemoBoardElements,
+		arg.Elements,
+		arg.ExpiresAt,
+		arg.ID,
+		arg.SessionID,
+	)
+	var i DemoBoard
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Name,
+		&i.Elements,
+		&i.InstructionCount,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.MaxExpiresAt,
+	)
+	return i, err
+}
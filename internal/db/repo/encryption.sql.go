@@ -0,0 +1,318 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: encryption.sql
+
+package repo
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const createBoardDataKey = `-- name: CreateBoardDataKey :exec
+INSERT INTO "board_data_key" (board_id, wrapped_key, master_key_id) VALUES ($1, $2, $3) ON CONFLICT (board_id) DO NOTHING
+`
+
+type CreateBoardDataKeyParams struct {
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	WrappedKey  []byte    `db:"wrapped_key" json:"wrappedKey"`
+	MasterKeyID string    `db:"master_key_id" json:"masterKeyId"`
+}
+
+func (q *Queries) CreateBoardDataKey(ctx context.Context, arg CreateBoardDataKeyParams) error {
+	_, err := q.db.Exec(ctx, createBoardDataKey, arg.BoardID, arg.WrappedKey, arg.MasterKeyID)
+	return err
+}
+
+const encryptBoardElements = `-- name: EncryptBoardElements :exec
+UPDATE "board" SET elements = $2 WHERE id = $1
+`
+
+type EncryptBoardElementsParams struct {
+	ID       uuid.UUID       `db:"id" json:"id"`
+	Elements json.RawMessage `db:"elements" json:"elements"`
+}
+
+func (q *Queries) EncryptBoardElements(ctx context.Context, arg EncryptBoardElementsParams) error {
+	_, err := q.db.Exec(ctx, encryptBoardElements, arg.ID, arg.Elements)
+	return err
+}
+
+const encryptCommentText = `-- name: EncryptCommentText :exec
+UPDATE "board_comment" SET text = $3 WHERE id = $1 AND board_id = $2
+`
+
+type EncryptCommentTextParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+	Text    string    `db:"text" json:"text"`
+}
+
+func (q *Queries) EncryptCommentText(ctx context.Context, arg EncryptCommentTextParams) error {
+	_, err := q.db.Exec(ctx, encryptCommentText, arg.ID, arg.BoardID, arg.Text)
+	return err
+}
+
+const encryptInstruction = `-- name: EncryptInstruction :exec
+UPDATE "board_instruction" SET instruction = $3, raw_response = $4, inverse_action = $5 WHERE id = $1 AND board_id = $2
+`
+
+type EncryptInstructionParams struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	BoardID       uuid.UUID `db:"board_id" json:"boardId"`
+	Instruction   string    `db:"instruction" json:"instruction"`
+	RawResponse   *string   `db:"raw_response" json:"rawResponse"`
+	InverseAction *string   `db:"inverse_action" json:"inverseAction"`
+}
+
+func (q *Queries) EncryptInstruction(ctx context.Context, arg EncryptInstructionParams) error {
+	_, err := q.db.Exec(ctx, encryptInstruction,
+		arg.ID,
+		arg.BoardID,
+		arg.Instruction,
+		arg.RawResponse,
+		arg.InverseAction,
+	)
+	return err
+}
+
+const encryptPendingChange = `-- name: EncryptPendingChange :exec
+UPDATE "board_pending_change" SET instruction = $3, response = $4 WHERE id = $1 AND board_id = $2
+`
+
+type EncryptPendingChangeParams struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	Instruction string    `db:"instruction" json:"instruction"`
+	Response    string    `db:"response" json:"response"`
+}
+
+func (q *Queries) EncryptPendingChange(ctx context.Context, arg EncryptPendingChangeParams) error {
+	_, err := q.db.Exec(ctx, encryptPendingChange,
+		arg.ID,
+		arg.BoardID,
+		arg.Instruction,
+		arg.Response,
+	)
+	return err
+}
+
+const getBoardDataKey = `-- name: GetBoardDataKey :one
+SELECT board_id, wrapped_key, master_key_id, created_at, rotated_at FROM "board_data_key" WHERE board_id = $1
+`
+
+func (q *Queries) GetBoardDataKey(ctx context.Context, boardID uuid.UUID) (BoardDataKey, error) {
+	row := q.db.QueryRow(ctx, getBoardDataKey, boardID)
+	var i BoardDataKey
+	err := row.Scan(
+		&i.BoardID,
+		&i.WrappedKey,
+		&i.MasterKeyID,
+		&i.CreatedAt,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const getBoardDataKeysToRotate = `-- name: GetBoardDataKeysToRotate :many
+SELECT board_id, wrapped_key, master_key_id, created_at, rotated_at FROM "board_data_key" WHERE master_key_id <> $1 ORDER BY board_id LIMIT $2
+`
+
+type GetBoardDataKeysToRotateParams struct {
+	MasterKeyID string `db:"master_key_id" json:"masterKeyId"`
+	Limit       int32  `db:"limit" json:"limit"`
+}
+
+func (q *Queries) GetBoardDataKeysToRotate(ctx context.Context, arg GetBoardDataKeysToRotateParams) ([]BoardDataKey, error) {
+	rows, err := q.db.Query(ctx, getBoardDataKeysToRotate, arg.MasterKeyID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BoardDataKey{}
+	for rows.Next() {
+		var i BoardDataKey
+		if err := rows.Scan(
+			&i.BoardID,
+			&i.WrappedKey,
+			&i.MasterKeyID,
+			&i.CreatedAt,
+			&i.RotatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPlaintextBoards = `-- name: GetPlaintextBoards :many
+SELECT id, elements FROM "board"
+WHERE elements IS NOT NULL AND elements <> 'null'::jsonb AND NOT (jsonb_typeof(elements) = 'string' AND elements #>> '{}' LIKE 'enc:v1:%')
+ORDER BY id LIMIT $1
+`
+
+type GetPlaintextBoardsRow struct {
+	ID       uuid.UUID       `db:"id" json:"id"`
+	Elements json.RawMessage `db:"elements" json:"elements"`
+}
+
+func (q *Queries) GetPlaintextBoards(ctx context.Context, limit int32) ([]GetPlaintextBoardsRow, error) {
+	rows, err := q.db.Query(ctx, getPlaintextBoards, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetPlaintextBoardsRow{}
+	for rows.Next() {
+		var i GetPlaintextBoardsRow
+		if err := rows.Scan(&i.ID, &i.Elements); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPlaintextComments = `-- name: GetPlaintextComments :many
+SELECT id, board_id, text FROM "board_comment" WHERE text <> '' AND text NOT LIKE 'enc:v1:%' ORDER BY id LIMIT $1
+`
+
+type GetPlaintextCommentsRow struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+	Text    string    `db:"text" json:"text"`
+}
+
+func (q *Queries) GetPlaintextComments(ctx context.Context, limit int32) ([]GetPlaintextCommentsRow, error) {
+	rows, err := q.db.Query(ctx, getPlaintextComments, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetPlaintextCommentsRow{}
+	for rows.Next() {
+		var i GetPlaintextCommentsRow
+		if err := rows.Scan(&i.ID, &i.BoardID, &i.Text); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPlaintextInstructions = `-- name: GetPlaintextInstructions :many
+SELECT id, board_id, instruction, raw_response, inverse_action FROM "board_instruction"
+WHERE (instruction <> '' AND instruction NOT LIKE 'enc:v1:%') OR (raw_response IS NOT NULL AND raw_response <> '' AND raw_response NOT LIKE 'enc:v1:%')
+OR (inverse_action IS NOT NULL AND inverse_action NOT LIKE 'enc:v1:%')
+ORDER BY id LIMIT $1
+`
+
+type GetPlaintextInstructionsRow struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	BoardID       uuid.UUID `db:"board_id" json:"boardId"`
+	Instruction   string    `db:"instruction" json:"instruction"`
+	RawResponse   *string   `db:"raw_response" json:"rawResponse"`
+	InverseAction *string   `db:"inverse_action" json:"inverseAction"`
+}
+
+func (q *Queries) GetPlaintextInstructions(ctx context.Context, limit int32) ([]GetPlaintextInstructionsRow, error) {
+	rows, err := q.db.Query(ctx, getPlaintextInstructions, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetPlaintextInstructionsRow{}
+	for rows.Next() {
+		var i GetPlaintextInstructionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.Instruction,
+			&i.RawResponse,
+			&i.InverseAction,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPlaintextPendingChanges = `-- name: GetPlaintextPendingChanges :many
+SELECT id, board_id, instruction, response FROM "board_pending_change"
+WHERE (instruction <> '' AND instruction NOT LIKE 'enc:v1:%') OR (response <> '' AND response NOT LIKE 'enc:v1:%')
+ORDER BY id LIMIT $1
+`
+
+type GetPlaintextPendingChangesRow struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	Instruction string    `db:"instruction" json:"instruction"`
+	Response    string    `db:"response" json:"response"`
+}
+
+func (q *Queries) GetPlaintextPendingChanges(ctx context.Context, limit int32) ([]GetPlaintextPendingChangesRow, error) {
+	rows, err := q.db.Query(ctx, getPlaintextPendingChanges, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetPlaintextPendingChangesRow{}
+	for rows.Next() {
+		var i GetPlaintextPendingChangesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.Instruction,
+			&i.Response,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rewrapBoardDataKey = `-- name: RewrapBoardDataKey :execrows
+UPDATE "board_data_key" SET wrapped_key = $1, master_key_id = $2, rotated_at = CURRENT_TIMESTAMP
+WHERE board_id = $3 AND master_key_id = $4
+`
+
+type RewrapBoardDataKeyParams struct {
+	WrappedKey     []byte    `db:"wrapped_key" json:"wrappedKey"`
+	NewMasterKeyID string    `db:"new_master_key_id" json:"newMasterKeyId"`
+	BoardID        uuid.UUID `db:"board_id" json:"boardId"`
+	OldMasterKeyID string    `db:"old_master_key_id" json:"oldMasterKeyId"`
+}
+
+func (q *Queries) RewrapBoardDataKey(ctx context.Context, arg RewrapBoardDataKeyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rewrapBoardDataKey,
+		arg.WrappedKey,
+		arg.NewMasterKeyID,
+		arg.BoardID,
+		arg.OldMasterKeyID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,71 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: image.sql
+
+package repo
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createImage = `-- name: CreateImage :one
+INSERT INTO "board_image" (id, board_id, object_key, mime_type, size, created_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, board_id, object_key, mime_type, size, created_by, created_at
+`
+
+type CreateImageParams struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	ObjectKey string    `db:"object_key" json:"objectKey"`
+	MimeType  string    `db:"mime_type" json:"mimeType"`
+	Size      int64     `db:"size" json:"size"`
+	CreatedBy string    `db:"created_by" json:"createdBy"`
+}
+
+func (q *Queries) CreateImage(ctx context.Context, arg CreateImageParams) (BoardImage, error) {
+	row := q.db.QueryRow(ctx, createImage,
+		arg.ID,
+		arg.BoardID,
+		arg.ObjectKey,
+		arg.MimeType,
+		arg.Size,
+		arg.CreatedBy,
+	)
+	var i BoardImage
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.ObjectKey,
+		&i.MimeType,
+		&i.Size,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getImageByID = `-- name: GetImageByID :one
+SELECT id, board_id, object_key, mime_type, size, created_by, created_at FROM "board_image" WHERE id = $1 AND board_id = $2
+`
+
+type GetImageByIDParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+}
+
+func (q *Queries) GetImageByID(ctx context.Context, arg GetImageByIDParams) (BoardImage, error) {
+	row := q.db.QueryRow(ctx, getImageByID, arg.ID, arg.BoardID)
+	var i BoardImage
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.ObjectKey,
+		&i.MimeType,
+		&i.Size,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,243 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: instruction.sql
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const countHeldInstructionsBefore = `-- name: CountHeldInstructionsBefore :one
+SELECT COUNT(*) FROM "board_instruction" i JOIN "board" b ON b.id = i.board_id
+WHERE i.created_at < $1 AND i.redacted_at IS NULL AND b.legal_hold_at IS NOT NULL
+`
+
+func (q *Queries) CountHeldInstructionsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	row := q.db.QueryRow(ctx, countHeldInstructionsBefore, createdAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createInstruction = `-- name: CreateInstruction :one
+INSERT INTO "board_instruction" (board_id, user_id, instruction, raw_response, error, provider, prompt_tokens, completion_tokens, cost_usd, intent, outcome, latency_ms, inverse_action) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, board_id, user_id, instruction, raw_response, error, redacted_at, created_at, provider, prompt_tokens, completion_tokens, cost_usd, intent, outcome, latency_ms, inverse_action, undone_at
+`
+
+type CreateInstructionParams struct {
+	BoardID          uuid.UUID `db:"board_id" json:"boardId"`
+	UserID           string    `db:"user_id" json:"userId"`
+	Instruction      string    `db:"instruction" json:"instruction"`
+	RawResponse      *string   `db:"raw_response" json:"rawResponse"`
+	Error            *string   `db:"error" json:"error"`
+	Provider         *string   `db:"provider" json:"provider"`
+	PromptTokens     int32     `db:"prompt_tokens" json:"promptTokens"`
+	CompletionTokens int32     `db:"completion_tokens" json:"completionTokens"`
+	CostUsd          float64   `db:"cost_usd" json:"costUsd"`
+	Intent           string    `db:"intent" json:"intent"`
+	Outcome          string    `db:"outcome" json:"outcome"`
+	LatencyMs        int32     `db:"latency_ms" json:"latencyMs"`
+	InverseAction    *string   `db:"inverse_action" json:"inverseAction"`
+}
+
+func (q *Queries) CreateInstruction(ctx context.Context, arg CreateInstructionParams) (BoardInstruction, error) {
+	row := q.db.QueryRow(ctx, createInstruction,
+		arg.BoardID,
+		arg.UserID,
+		arg.Instruction,
+		arg.RawResponse,
+		arg.Error,
+		arg.Provider,
+		arg.PromptTokens,
+		arg.CompletionTokens,
+		arg.CostUsd,
+		arg.Intent,
+		arg.Outcome,
+		arg.LatencyMs,
+		arg.InverseAction,
+	)
+	var i BoardInstruction
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.UserID,
+		&i.Instruction,
+		&i.RawResponse,
+		&i.Error,
+		&i.RedactedAt,
+		&i.CreatedAt,
+		&i.Provider,
+		&i.PromptTokens,
+		&i.CompletionTokens,
+		&i.CostUsd,
+		&i.Intent,
+		&i.Outcome,
+		&i.LatencyMs,
+		&i.InverseAction,
+		&i.UndoneAt,
+	)
+	return i, err
+}
+
+const getInstructionsByBoardID = `-- name: GetInstructionsByBoardID :many
+SELECT id, board_id, user_id, instruction, raw_response, error, redacted_at, created_at, provider, prompt_tokens, completion_tokens, cost_usd, intent, outcome, latency_ms, inverse_action, undone_at FROM "board_instruction" WHERE board_id = $1 ORDER BY created_at DESC LIMIT $2
+`
+
+type GetInstructionsByBoardIDParams struct {
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+	Limit   int32     `db:"limit" json:"limit"`
+}
+
+func (q *Queries) GetInstructionsByBoardID(ctx context.Context, arg GetInstructionsByBoardIDParams) ([]BoardInstruction, error) {
+	rows, err := q.db.Query(ctx, getInstructionsByBoardID, arg.BoardID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BoardInstruction{}
+	for rows.Next() {
+		var i BoardInstruction
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.UserID,
+			&i.Instruction,
+			&i.RawResponse,
+			&i.Error,
+			&i.RedactedAt,
+			&i.CreatedAt,
+			&i.Provider,
+			&i.PromptTokens,
+			&i.CompletionTokens,
+			&i.CostUsd,
+			&i.Intent,
+			&i.Outcome,
+			&i.LatencyMs,
+			&i.InverseAction,
+			&i.UndoneAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLastUndoableInstruction = `-- name: GetLastUndoableInstruction :one
+SELECT id, board_id, user_id, instruction, raw_response, error, redacted_at, created_at, provider, prompt_tokens, completion_tokens, cost_usd, intent, outcome, latency_ms, inverse_action, undone_at FROM "board_instruction" WHERE board_id = $1 AND inverse_action IS NOT NULL AND undone_at IS NULL
+ORDER BY created_at DESC LIMIT 1 FOR UPDATE
+`
+
+func (q *Queries) GetLastUndoableInstruction(ctx context.Context, boardID uuid.UUID) (BoardInstruction, error) {
+	row := q.db.QueryRow(ctx, getLastUndoableInstruction, boardID)
+	var i BoardInstruction
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.UserID,
+		&i.Instruction,
+		&i.RawResponse,
+		&i.Error,
+		&i.RedactedAt,
+		&i.CreatedAt,
+		&i.Provider,
+		&i.PromptTokens,
+		&i.CompletionTokens,
+		&i.CostUsd,
+		&i.Intent,
+		&i.Outcome,
+		&i.LatencyMs,
+		&i.InverseAction,
+		&i.UndoneAt,
+	)
+	return i, err
+}
+
+const getUndoableInstructionsByBoardID = `-- name: GetUndoableInstructionsByBoardID :many
+SELECT id, board_id, user_id, instruction, raw_response, error, redacted_at, created_at, provider, prompt_tokens, completion_tokens, cost_usd, intent, outcome, latency_ms, inverse_action, undone_at FROM "board_instruction" WHERE board_id = $1 AND inverse_action IS NOT NULL AND undone_at IS NULL
+ORDER BY created_at DESC LIMIT $2
+`
+
+type GetUndoableInstructionsByBoardIDParams struct {
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+	Limit   int32     `db:"limit" json:"limit"`
+}
+
+func (q *Queries) GetUndoableInstructionsByBoardID(ctx context.Context, arg GetUndoableInstructionsByBoardIDParams) ([]BoardInstruction, error) {
+	rows, err := q.db.Query(ctx, getUndoableInstructionsByBoardID, arg.BoardID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BoardInstruction{}
+	for rows.Next() {
+		var i BoardInstruction
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.UserID,
+			&i.Instruction,
+			&i.RawResponse,
+			&i.Error,
+			&i.RedactedAt,
+			&i.CreatedAt,
+			&i.Provider,
+			&i.PromptTokens,
+			&i.CompletionTokens,
+			&i.CostUsd,
+			&i.Intent,
+			&i.Outcome,
+			&i.LatencyMs,
+			&i.InverseAction,
+			&i.UndoneAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markInstructionUndone = `-- name: MarkInstructionUndone :exec
+UPDATE "board_instruction" SET undone_at = CURRENT_TIMESTAMP WHERE id = $1
+`
+
+func (q *Queries) MarkInstructionUndone(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, markInstructionUndone, id)
+	return err
+}
+
+const redactInstructionsBefore = `-- name: RedactInstructionsBefore :execrows
+UPDATE "board_instruction" i SET instruction = '', redacted_at = CURRENT_TIMESTAMP
+WHERE i.created_at < $1 AND i.redacted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM "board" b WHERE b.id = i.board_id AND b.legal_hold_at IS NOT NULL)
+`
+
+func (q *Queries) RedactInstructionsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, redactInstructionsBefore, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const sumInstructionCostSince = `-- name: SumInstructionCostSince :one
+SELECT COALESCE(SUM(cost_usd), 0)::float8 AS total FROM "board_instruction" WHERE created_at >= $1
+`
+
+func (q *Queries) SumInstructionCostSince(ctx context.Context, createdAt time.Time) (float64, error) {
+	row := q.db.QueryRow(ctx, sumInstructionCostSince, createdAt)
+	var total float64
+	err := row.Scan(&total)
+	return total, err
+}
@@ -0,0 +1,135 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: intent.sql
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const abandonStaleIntents = `-- name: AbandonStaleIntents :execrows
+UPDATE "instruction_intent" SET state = 'failed', updated_at = CURRENT_TIMESTAMP
+WHERE state IN ('pending', 'interrupted', 'resuming') AND created_at < $1
+`
+
+func (q *Queries) AbandonStaleIntents(ctx context.Context, createdAt time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, abandonStaleIntents, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const claimInterruptedIntents = `-- name: ClaimInterruptedIntents :many
+UPDATE "instruction_intent" SET state = 'resuming', updated_at = CURRENT_TIMESTAMP
+WHERE board_id = $1 AND state = 'interrupted' AND created_at >= $2
+RETURNING request_id, board_id, user_id, instruction, state, created_at, updated_at
+`
+
+type ClaimInterruptedIntentsParams struct {
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+	Since   time.Time `db:"since" json:"since"`
+}
+
+func (q *Queries) ClaimInterruptedIntents(ctx context.Context, arg ClaimInterruptedIntentsParams) ([]InstructionIntent, error) {
+	rows, err := q.db.Query(ctx, claimInterruptedIntents, arg.BoardID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []InstructionIntent{}
+	for rows.Next() {
+		var i InstructionIntent
+		if err := rows.Scan(
+			&i.RequestID,
+			&i.BoardID,
+			&i.UserID,
+			&i.Instruction,
+			&i.State,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createInstructionIntent = `-- name: CreateInstructionIntent :exec
+INSERT INTO "instruction_intent" (board_id, request_id, user_id, instruction) VALUES ($1, $2, $3, $4)
+ON CONFLICT (request_id) DO NOTHING
+`
+
+type CreateInstructionIntentParams struct {
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	RequestID   string    `db:"request_id" json:"requestId"`
+	UserID      string    `db:"user_id" json:"userId"`
+	Instruction string    `db:"instruction" json:"instruction"`
+}
+
+func (q *Queries) CreateInstructionIntent(ctx context.Context, arg CreateInstructionIntentParams) error {
+	_, err := q.db.Exec(ctx, createInstructionIntent,
+		arg.BoardID,
+		arg.RequestID,
+		arg.UserID,
+		arg.Instruction,
+	)
+	return err
+}
+
+const deleteIntentsBefore = `-- name: DeleteIntentsBefore :execrows
+DELETE FROM "instruction_intent" WHERE updated_at < $1
+`
+
+func (q *Queries) DeleteIntentsBefore(ctx context.Context, updatedAt time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteIntentsBefore, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const interruptPendingIntents = `-- name: InterruptPendingIntents :execrows
+UPDATE "instruction_intent" SET state = 'interrupted', updated_at = CURRENT_TIMESTAMP
+WHERE state = 'pending' AND created_at >= $1 AND created_at < $2
+`
+
+type InterruptPendingIntentsParams struct {
+	Since  time.Time `db:"since" json:"since"`
+	Before time.Time `db:"before" json:"before"`
+}
+
+func (q *Queries) InterruptPendingIntents(ctx context.Context, arg InterruptPendingIntentsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, interruptPendingIntents, arg.Since, arg.Before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const settleInstructionIntent = `-- name: SettleInstructionIntent :execrows
+UPDATE "instruction_intent" SET state = $1, updated_at = CURRENT_TIMESTAMP
+WHERE request_id = $2 AND state IN ('pending', 'interrupted', 'resuming')
+`
+
+type SettleInstructionIntentParams struct {
+	State     string `db:"state" json:"state"`
+	RequestID string `db:"request_id" json:"requestId"`
+}
+
+func (q *Queries) SettleInstructionIntent(ctx context.Context, arg SettleInstructionIntentParams) (int64, error) {
+	result, err := q.db.Exec(ctx, settleInstructionIntent, arg.State, arg.RequestID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,80 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: legal_hold.sql
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getLegalHolds = `-- name: GetLegalHolds :many
+SELECT id, owner_id, name, legal_hold_reason, legal_hold_at FROM "board" WHERE legal_hold_at IS NOT NULL ORDER BY legal_hold_at
+`
+
+type GetLegalHoldsRow struct {
+	ID              uuid.UUID  `db:"id" json:"id"`
+	OwnerID         string     `db:"owner_id" json:"ownerId"`
+	Name            string     `db:"name" json:"name"`
+	LegalHoldReason *string    `db:"legal_hold_reason" json:"legalHoldReason"`
+	LegalHoldAt     *time.Time `db:"legal_hold_at" json:"legalHoldAt"`
+}
+
+func (q *Queries) GetLegalHolds(ctx context.Context) ([]GetLegalHoldsRow, error) {
+	rows, err := q.db.Query(ctx, getLegalHolds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetLegalHoldsRow{}
+	for rows.Next() {
+		var i GetLegalHoldsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.LegalHoldReason,
+			&i.LegalHoldAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const placeLegalHold = `-- name: PlaceLegalHold :execrows
+UPDATE "board" SET legal_hold_reason = $2, legal_hold_at = CURRENT_TIMESTAMP WHERE id = $1
+`
+
+type PlaceLegalHoldParams struct {
+	ID              uuid.UUID `db:"id" json:"id"`
+	LegalHoldReason *string   `db:"legal_hold_reason" json:"legalHoldReason"`
+}
+
+func (q *Queries) PlaceLegalHold(ctx context.Context, arg PlaceLegalHoldParams) (int64, error) {
+	result, err := q.db.Exec(ctx, placeLegalHold, arg.ID, arg.LegalHoldReason)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const releaseLegalHold = `-- name: ReleaseLegalHold :execrows
+UPDATE "board" SET legal_hold_reason = NULL, legal_hold_at = NULL WHERE id = $1 AND legal_hold_at IS NOT NULL
+`
+
+func (q *Queries) ReleaseLegalHold(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, releaseLegalHold, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -12,12 +12,183 @@ import (
 )
 
 type Board struct {
-	ID        uuid.UUID       `db:"id" json:"id"`
-	Name      string          `db:"name" json:"name"`
-	OwnerID   string          `db:"owner_id" json:"ownerId"`
-	Elements  json.RawMessage `db:"elements" json:"elements"`
-	CreatedAt time.Time       `db:"created_at" json:"createdAt"`
-	UpdatedAt time.Time       `db:"updated_at" json:"updatedAt"`
+	ID              uuid.UUID       `db:"id" json:"id"`
+	Name            string          `db:"name" json:"name"`
+	OwnerID         string          `db:"owner_id" json:"ownerId"`
+	Elements        json.RawMessage `db:"elements" json:"elements"`
+	CreatedAt       time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time       `db:"updated_at" json:"updatedAt"`
+	Icon            *string         `db:"icon" json:"icon"`
+	Color           *string         `db:"color" json:"color"`
+	Protected       bool            `db:"protected" json:"protected"`
+	Timezone        *string         `db:"timezone" json:"timezone"`
+	Locale          *string         `db:"locale" json:"locale"`
+	ArrowRepair     string          `db:"arrow_repair" json:"arrowRepair"`
+	LegalHoldReason *string         `db:"legal_hold_reason" json:"legalHoldReason"`
+	LegalHoldAt     *time.Time      `db:"legal_hold_at" json:"legalHoldAt"`
+	ArchivedAt      *time.Time      `db:"archived_at" json:"archivedAt"`
+}
+
+type BoardComment struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	ElementID *string   `db:"element_id" json:"elementId"`
+	AuthorID  string    `db:"author_id" json:"authorId"`
+	Text      string    `db:"text" json:"text"`
+	Resolved  bool      `db:"resolved" json:"resolved"`
+	Orphaned  bool      `db:"orphaned" json:"orphaned"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+type BoardDataKey struct {
+	BoardID     uuid.UUID  `db:"board_id" json:"boardId"`
+	WrappedKey  []byte     `db:"wrapped_key" json:"wrappedKey"`
+	MasterKeyID string     `db:"master_key_id" json:"masterKeyId"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+	RotatedAt   *time.Time `db:"rotated_at" json:"rotatedAt"`
+}
+
+type BoardImage struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	ObjectKey string    `db:"object_key" json:"objectKey"`
+	MimeType  string    `db:"mime_type" json:"mimeType"`
+	Size      int64     `db:"size" json:"size"`
+	CreatedBy string    `db:"created_by" json:"createdBy"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+type BoardInstruction struct {
+	ID               uuid.UUID  `db:"id" json:"id"`
+	BoardID          uuid.UUID  `db:"board_id" json:"boardId"`
+	UserID           string     `db:"user_id" json:"userId"`
+	Instruction      string     `db:"instruction" json:"instruction"`
+	RawResponse      *string    `db:"raw_response" json:"rawResponse"`
+	Error            *string    `db:"error" json:"error"`
+	RedactedAt       *time.Time `db:"redacted_at" json:"redactedAt"`
+	CreatedAt        time.Time  `db:"created_at" json:"createdAt"`
+	Provider         *string    `db:"provider" json:"provider"`
+	PromptTokens     int32      `db:"prompt_tokens" json:"promptTokens"`
+	CompletionTokens int32      `db:"completion_tokens" json:"completionTokens"`
+	CostUsd          float64    `db:"cost_usd" json:"costUsd"`
+	Intent           string     `db:"intent" json:"intent"`
+	Outcome          string     `db:"outcome" json:"outcome"`
+	LatencyMs        int32      `db:"latency_ms" json:"latencyMs"`
+	InverseAction    *string    `db:"inverse_action" json:"inverseAction"`
+	UndoneAt         *time.Time `db:"undone_at" json:"undoneAt"`
+}
+
+type BoardPendingChange struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	BoardID     uuid.UUID  `db:"board_id" json:"boardId"`
+	UserID      string     `db:"user_id" json:"userId"`
+	Instruction string     `db:"instruction" json:"instruction"`
+	Response    string     `db:"response" json:"response"`
+	Status      string     `db:"status" json:"status"`
+	Reason      *string    `db:"reason" json:"reason"`
+	DecidedBy   *string    `db:"decided_by" json:"decidedBy"`
+	DecidedAt   *time.Time `db:"decided_at" json:"decidedAt"`
+	ExpiresAt   time.Time  `db:"expires_at" json:"expiresAt"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+}
+
+type BoardPresentationToken struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	BoardID   uuid.UUID  `db:"board_id" json:"boardId"`
+	TokenHash string     `db:"token_hash" json:"tokenHash"`
+	Label     string     `db:"label" json:"label"`
+	CreatedBy string     `db:"created_by" json:"createdBy"`
+	ExpiresAt time.Time  `db:"expires_at" json:"expiresAt"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revokedAt"`
+	CreatedAt time.Time  `db:"created_at" json:"createdAt"`
+}
+
+type BoardView struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	Name      string    `db:"name" json:"name"`
+	X         *float64  `db:"x" json:"x"`
+	Y         *float64  `db:"y" json:"y"`
+	Zoom      *float64  `db:"zoom" json:"zoom"`
+	Width     *float64  `db:"width" json:"width"`
+	Height    *float64  `db:"height" json:"height"`
+	FrameID   *string   `db:"frame_id" json:"frameId"`
+	CreatedBy string    `db:"created_by" json:"createdBy"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+type DemoBoard struct {
+	ID               uuid.UUID       `db:"id" json:"id"`
+	SessionID        string          `db:"session_id" json:"sessionId"`
+	Name             string          `db:"name" json:"name"`
+	Elements         json.RawMessage `db:"elements" json:"elements"`
+	InstructionCount int32           `db:"instruction_count" json:"instructionCount"`
+	CreatedAt        time.Time       `db:"created_at" json:"createdAt"`
+	ExpiresAt        time.Time       `db:"expires_at" json:"expiresAt"`
+	MaxExpiresAt     time.Time       `db:"max_expires_at" json:"maxExpiresAt"`
+}
+
+type InstructionFailurePatternDaily struct {
+	Day     time.Time `db:"day" json:"day"`
+	Intent  string    `db:"intent" json:"intent"`
+	Pattern string    `db:"pattern" json:"pattern"`
+	Count   int64     `db:"count" json:"count"`
+}
+
+type InstructionIntent struct {
+	RequestID   string    `db:"request_id" json:"requestId"`
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	UserID      string    `db:"user_id" json:"userId"`
+	Instruction string    `db:"instruction" json:"instruction"`
+	State       string    `db:"state" json:"state"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+type InstructionIntentDaily struct {
+	Day          time.Time `db:"day" json:"day"`
+	Intent       string    `db:"intent" json:"intent"`
+	Outcome      string    `db:"outcome" json:"outcome"`
+	Provider     string    `db:"provider" json:"provider"`
+	Count        int64     `db:"count" json:"count"`
+	LatencyMsSum int64     `db:"latency_ms_sum" json:"latencyMsSum"`
+	LatencyMsMax int32     `db:"latency_ms_max" json:"latencyMsMax"`
+}
+
+type InstructionTiming struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	RequestID   string    `db:"request_id" json:"requestId"`
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	UserID      string    `db:"user_id" json:"userId"`
+	Provider    string    `db:"provider" json:"provider"`
+	Model       string    `db:"model" json:"model"`
+	Failed      bool      `db:"failed" json:"failed"`
+	QueueMs     int32     `db:"queue_ms" json:"queueMs"`
+	FirstByteMs int32     `db:"first_byte_ms" json:"firstByteMs"`
+	LlmMs       int32     `db:"llm_ms" json:"llmMs"`
+	ValidateMs  int32     `db:"validate_ms" json:"validateMs"`
+	BroadcastMs int32     `db:"broadcast_ms" json:"broadcastMs"`
+	TotalMs     int32     `db:"total_ms" json:"totalMs"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+type ServiceAccount struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	OwnerID     string     `db:"owner_id" json:"ownerId"`
+	Name        string     `db:"name" json:"name"`
+	Description string     `db:"description" json:"description"`
+	DisabledAt  *time.Time `db:"disabled_at" json:"disabledAt"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+}
+
+type ServiceAccountKey struct {
+	ID               uuid.UUID  `db:"id" json:"id"`
+	ServiceAccountID uuid.UUID  `db:"service_account_id" json:"serviceAccountId"`
+	KeyHash          string     `db:"key_hash" json:"keyHash"`
+	Scopes           []string   `db:"scopes" json:"scopes"`
+	RevokedAt        *time.Time `db:"revoked_at" json:"revokedAt"`
+	CreatedAt        time.Time  `db:"created_at" json:"createdAt"`
 }
 
 type User struct {
@@ -0,0 +1,136 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: pending_change.sql
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createPendingChange = `-- name: CreatePendingChange :one
+INSERT INTO "board_pending_change" (board_id, user_id, instruction, response, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, board_id, user_id, instruction, response, status, reason, decided_by, decided_at, expires_at, created_at
+`
+
+type CreatePendingChangeParams struct {
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	UserID      string    `db:"user_id" json:"userId"`
+	Instruction string    `db:"instruction" json:"instruction"`
+	Response    string    `db:"response" json:"response"`
+	ExpiresAt   time.Time `db:"expires_at" json:"expiresAt"`
+}
+
+func (q *Queries) CreatePendingChange(ctx context.Context, arg CreatePendingChangeParams) (BoardPendingChange, error) {
+	row := q.db.QueryRow(ctx, createPendingChange,
+		arg.BoardID,
+		arg.UserID,
+		arg.Instruction,
+		arg.Response,
+		arg.ExpiresAt,
+	)
+	var i BoardPendingChange
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.UserID,
+		&i.Instruction,
+		&i.Response,
+		&i.Status,
+		&i.Reason,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const decidePendingChange = `-- name: DecidePendingChange :one
+UPDATE "board_pending_change" SET status = $3, reason = $4, decided_by = $5, decided_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND board_id = $2 AND status = 'pending' AND expires_at > CURRENT_TIMESTAMP RETURNING id, board_id, user_id, instruction, response, status, reason, decided_by, decided_at, expires_at, created_at
+`
+
+type DecidePendingChangeParams struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	Status    string    `db:"status" json:"status"`
+	Reason    *string   `db:"reason" json:"reason"`
+	DecidedBy *string   `db:"decided_by" json:"decidedBy"`
+}
+
+func (q *Queries) DecidePendingChange(ctx context.Context, arg DecidePendingChangeParams) (BoardPendingChange, error) {
+	row := q.db.QueryRow(ctx, decidePendingChange,
+		arg.ID,
+		arg.BoardID,
+		arg.Status,
+		arg.Reason,
+		arg.DecidedBy,
+	)
+	var i BoardPendingChange
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.UserID,
+		&i.Instruction,
+		&i.Response,
+		&i.Status,
+		&i.Reason,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const expirePendingChanges = `-- name: ExpirePendingChanges :execrows
+UPDATE "board_pending_change" SET status = 'expired', decided_at = CURRENT_TIMESTAMP WHERE status = 'pending' AND expires_at <= CURRENT_TIMESTAMP
+`
+
+func (q *Queries) ExpirePendingChanges(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, expirePendingChanges)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getPendingChangesByBoardID = `-- name: GetPendingChangesByBoardID :many
+SELECT id, board_id, user_id, instruction, response, status, reason, decided_by, decided_at, expires_at, created_at FROM "board_pending_change" WHERE board_id = $1 AND status = 'pending' AND expires_at > CURRENT_TIMESTAMP ORDER BY created_at ASC
+`
+
+func (q *Queries) GetPendingChangesByBoardID(ctx context.Context, boardID uuid.UUID) ([]BoardPendingChange, error) {
+	rows, err := q.db.Query(ctx, getPendingChangesByBoardID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BoardPendingChange{}
+	for rows.Next() {
+		var i BoardPendingChange
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.UserID,
+			&i.Instruction,
+			&i.Response,
+			&i.Status,
+			&i.Reason,
+			&i.DecidedBy,
+			&i.DecidedAt,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
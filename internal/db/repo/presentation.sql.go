@@ -0,0 +1,135 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: presentation.sql
+
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createPresentationToken = `-- name: CreatePresentationToken :one
+INSERT INTO "board_presentation_token" (board_id, token_hash, label, created_by, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, board_id, token_hash, label, created_by, expires_at, revoked_at, created_at
+`
+
+type CreatePresentationTokenParams struct {
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	TokenHash string    `db:"token_hash" json:"tokenHash"`
+	Label     string    `db:"label" json:"label"`
+	CreatedBy string    `db:"created_by" json:"createdBy"`
+	ExpiresAt time.Time `db:"expires_at" json:"expiresAt"`
+}
+
+func (q *Queries) CreatePresentationToken(ctx context.Context, arg CreatePresentationTokenParams) (BoardPresentationToken, error) {
+	row := q.db.QueryRow(ctx, createPresentationToken,
+		arg.BoardID,
+		arg.TokenHash,
+		arg.Label,
+		arg.CreatedBy,
+		arg.ExpiresAt,
+	)
+	var i BoardPresentationToken
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.TokenHash,
+		&i.Label,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getActivePresentationToken = `-- name: GetActivePresentationToken :one
+SELECT id, board_id, token_hash, label, created_by, expires_at, revoked_at, created_at FROM "board_presentation_token" WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+`
+
+func (q *Queries) GetActivePresentationToken(ctx context.Context, tokenHash string) (BoardPresentationToken, error) {
+	row := q.db.QueryRow(ctx, getActivePresentationToken, tokenHash)
+	var i BoardPresentationToken
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.TokenHash,
+		&i.Label,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getBoardForPresentation = `-- name: GetBoardForPresentation :one
+SELECT id, name, elements FROM "board" WHERE id = $1
+`
+
+type GetBoardForPresentationRow struct {
+	ID       uuid.UUID       `db:"id" json:"id"`
+	Name     string          `db:"name" json:"name"`
+	Elements json.RawMessage `db:"elements" json:"elements"`
+}
+
+func (q *Queries) GetBoardForPresentation(ctx context.Context, id uuid.UUID) (GetBoardForPresentationRow, error) {
+	row := q.db.QueryRow(ctx, getBoardForPresentation, id)
+	var i GetBoardForPresentationRow
+	err := row.Scan(&i.ID, &i.Name, &i.Elements)
+	return i, err
+}
+
+const getPresentationTokensByBoardID = `-- name: GetPresentationTokensByBoardID :many
+SELECT id, board_id, token_hash, label, created_by, expires_at, revoked_at, created_at FROM "board_presentation_token" WHERE board_id = $1 ORDER BY created_at DESC
+`
+
+func (q *Queries) GetPresentationTokensByBoardID(ctx context.Context, boardID uuid.UUID) ([]BoardPresentationToken, error) {
+	rows, err := q.db.Query(ctx, getPresentationTokensByBoardID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BoardPresentationToken{}
+	for rows.Next() {
+		var i BoardPresentationToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.TokenHash,
+			&i.Label,
+			&i.CreatedBy,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokePresentationToken = `-- name: RevokePresentationToken :execrows
+UPDATE "board_presentation_token" SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND board_id = $2 AND revoked_at IS NULL
+`
+
+type RevokePresentationTokenParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+}
+
+func (q *Queries) RevokePresentationToken(ctx context.Context, arg RevokePresentationTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokePresentationToken, arg.ID, arg.BoardID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,200 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: service_account.sql
+
+package repo
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createServiceAccount = `-- name: CreateServiceAccount :one
+INSERT INTO "service_account" (owner_id, name, description) VALUES ($1, $2, $3) RETURNING id, owner_id, name, description, disabled_at, created_at
+`
+
+type CreateServiceAccountParams struct {
+	OwnerID     string `db:"owner_id" json:"ownerId"`
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+}
+
+func (q *Queries) CreateServiceAccount(ctx context.Context, arg CreateServiceAccountParams) (ServiceAccount, error) {
+	row := q.db.QueryRow(ctx, createServiceAccount, arg.OwnerID, arg.Name, arg.Description)
+	var i ServiceAccount
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Description,
+		&i.DisabledAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createServiceAccountKey = `-- name: CreateServiceAccountKey :one
+INSERT INTO "service_account_key" (service_account_id, key_hash, scopes) VALUES ($1, $2, $3) RETURNING id, service_account_id, key_hash, scopes, revoked_at, created_at
+`
+
+type CreateServiceAccountKeyParams struct {
+	ServiceAccountID uuid.UUID `db:"service_account_id" json:"serviceAccountId"`
+	KeyHash          string    `db:"key_hash" json:"keyHash"`
+	Scopes           []string  `db:"scopes" json:"scopes"`
+}
+
+func (q *Queries) CreateServiceAccountKey(ctx context.Context, arg CreateServiceAccountKeyParams) (ServiceAccountKey, error) {
+	row := q.db.QueryRow(ctx, createServiceAccountKey, arg.ServiceAccountID, arg.KeyHash, arg.Scopes)
+	var i ServiceAccountKey
+	err := row.Scan(
+		&i.ID,
+		&i.ServiceAccountID,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const disableServiceAccount = `-- name: DisableServiceAccount :execrows
+UPDATE "service_account" SET disabled_at = CURRENT_TIMESTAMP WHERE id = $1 AND owner_id = $2 AND disabled_at IS NULL
+`
+
+type DisableServiceAccountParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	OwnerID string    `db:"owner_id" json:"ownerId"`
+}
+
+func (q *Queries) DisableServiceAccount(ctx context.Context, arg DisableServiceAccountParams) (int64, error) {
+	result, err := q.db.Exec(ctx, disableServiceAccount, arg.ID, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getActiveServiceAccountKey = `-- name: GetActiveServiceAccountKey :one
+SELECT k.id, k.service_account_id, k.scopes, a.owner_id, a.name FROM "service_account_key" k
+JOIN "service_account" a ON a.id = k.service_account_id
+WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND a.disabled_at IS NULL
+`
+
+type GetActiveServiceAccountKeyRow struct {
+	ID               uuid.UUID `db:"id" json:"id"`
+	ServiceAccountID uuid.UUID `db:"service_account_id" json:"serviceAccountId"`
+	Scopes           []string  `db:"scopes" json:"scopes"`
+	OwnerID          string    `db:"owner_id" json:"ownerId"`
+	Name             string    `db:"name" json:"name"`
+}
+
+func (q *Queries) GetActiveServiceAccountKey(ctx context.Context, keyHash string) (GetActiveServiceAccountKeyRow, error) {
+	row := q.db.QueryRow(ctx, getActiveServiceAccountKey, keyHash)
+	var i GetActiveServiceAccountKeyRow
+	err := row.Scan(
+		&i.ID,
+		&i.ServiceAccountID,
+		&i.Scopes,
+		&i.OwnerID,
+		&i.Name,
+	)
+	return i, err
+}
+
+const getActiveServiceAccountKeysByOwnerID = `-- name: GetActiveServiceAccountKeysByOwnerID :many
+SELECT k.id, k.service_account_id, k.key_hash, k.scopes, k.revoked_at, k.created_at FROM "service_account_key" k
+JOIN "service_account" a ON a.id = k.service_account_id
+WHERE a.owner_id = $1 AND k.revoked_at IS NULL
+`
+
+func (q *Queries) GetActiveServiceAccountKeysByOwnerID(ctx context.Context, ownerID string) ([]ServiceAccountKey, error) {
+	rows, err := q.db.Query(ctx, getActiveServiceAccountKeysByOwnerID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ServiceAccountKey{}
+	for rows.Next() {
+		var i ServiceAccountKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.ServiceAccountID,
+			&i.KeyHash,
+			&i.Scopes,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getServiceAccount = `-- name: GetServiceAccount :one
+SELECT id, owner_id, name, description, disabled_at, created_at FROM "service_account" WHERE id = $1 AND owner_id = $2
+`
+
+type GetServiceAccountParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	OwnerID string    `db:"owner_id" json:"ownerId"`
+}
+
+func (q *Queries) GetServiceAccount(ctx context.Context, arg GetServiceAccountParams) (ServiceAccount, error) {
+	row := q.db.QueryRow(ctx, getServiceAccount, arg.ID, arg.OwnerID)
+	var i ServiceAccount
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Description,
+		&i.DisabledAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getServiceAccountsByOwnerID = `-- name: GetServiceAccountsByOwnerID :many
+SELECT id, owner_id, name, description, disabled_at, created_at FROM "service_account" WHERE owner_id = $1 ORDER BY created_at DESC
+`
+
+func (q *Queries) GetServiceAccountsByOwnerID(ctx context.Context, ownerID string) ([]ServiceAccount, error) {
+	rows, err := q.db.Query(ctx, getServiceAccountsByOwnerID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ServiceAccount{}
+	for rows.Next() {
+		var i ServiceAccount
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Description,
+			&i.DisabledAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeServiceAccountKeys = `-- name: RevokeServiceAccountKeys :exec
+UPDATE "service_account_key" SET revoked_at = CURRENT_TIMESTAMP WHERE service_account_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeServiceAccountKeys(ctx context.Context, serviceAccountID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, revokeServiceAccountKeys, serviceAccountID)
+	return err
+}
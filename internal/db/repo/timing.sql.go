@@ -0,0 +1,125 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: timing.sql
+
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const countHeldInstructionTimingsBefore = `-- name: CountHeldInstructionTimingsBefore :one
+SELECT COUNT(*) FROM "instruction_timing" t JOIN "board" b ON b.id = t.board_id
+WHERE t.created_at < $1 AND b.legal_hold_at IS NOT NULL
+`
+
+func (q *Queries) CountHeldInstructionTimingsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	row := q.db.QueryRow(ctx, countHeldInstructionTimingsBefore, createdAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createInstructionTiming = `-- name: CreateInstructionTiming :exec
+INSERT INTO "instruction_timing" (request_id, board_id, user_id, provider, model, failed, queue_ms, first_byte_ms, llm_ms, validate_ms, broadcast_ms, total_ms)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+`
+
+type CreateInstructionTimingParams struct {
+	RequestID   string    `db:"request_id" json:"requestId"`
+	BoardID     uuid.UUID `db:"board_id" json:"boardId"`
+	UserID      string    `db:"user_id" json:"userId"`
+	Provider    string    `db:"provider" json:"provider"`
+	Model       string    `db:"model" json:"model"`
+	Failed      bool      `db:"failed" json:"failed"`
+	QueueMs     int32     `db:"queue_ms" json:"queueMs"`
+	FirstByteMs int32     `db:"first_byte_ms" json:"firstByteMs"`
+	LlmMs       int32     `db:"llm_ms" json:"llmMs"`
+	ValidateMs  int32     `db:"validate_ms" json:"validateMs"`
+	BroadcastMs int32     `db:"broadcast_ms" json:"broadcastMs"`
+	TotalMs     int32     `db:"total_ms" json:"totalMs"`
+}
+
+func (q *Queries) CreateInstructionTiming(ctx context.Context, arg CreateInstructionTimingParams) error {
+	_, err := q.db.Exec(ctx, createInstructionTiming,
+		arg.RequestID,
+		arg.BoardID,
+		arg.UserID,
+		arg.Provider,
+		arg.Model,
+		arg.Failed,
+		arg.QueueMs,
+		arg.FirstByteMs,
+		arg.LlmMs,
+		arg.ValidateMs,
+		arg.BroadcastMs,
+		arg.TotalMs,
+	)
+	return err
+}
+
+const deleteInstructionTimingsBefore = `-- name: DeleteInstructionTimingsBefore :execrows
+DELETE FROM "instruction_timing" t
+WHERE t.created_at < $1
+AND NOT EXISTS (SELECT 1 FROM "board" b WHERE b.id = t.board_id AND b.legal_hold_at IS NOT NULL)
+`
+
+func (q *Queries) DeleteInstructionTimingsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteInstructionTimingsBefore, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getSlowInstructionTimings = `-- name: GetSlowInstructionTimings :many
+SELECT id, request_id, board_id, user_id, provider, model, failed, queue_ms, first_byte_ms, llm_ms, validate_ms, broadcast_ms, total_ms, created_at FROM "instruction_timing"
+WHERE created_at >= $1 AND total_ms >= $2::integer
+ORDER BY total_ms DESC
+LIMIT $3
+`
+
+type GetSlowInstructionTimingsParams struct {
+	Since       time.Time `db:"since" json:"since"`
+	ThresholdMs int32     `db:"threshold_ms" json:"thresholdMs"`
+	MaxRows     int32     `db:"max_rows" json:"maxRows"`
+}
+
+func (q *Queries) GetSlowInstructionTimings(ctx context.Context, arg GetSlowInstructionTimingsParams) ([]InstructionTiming, error) {
+	rows, err := q.db.Query(ctx, getSlowInstructionTimings, arg.Since, arg.ThresholdMs, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []InstructionTiming{}
+	for rows.Next() {
+		var i InstructionTiming
+		if err := rows.Scan(
+			&i.ID,
+			&i.RequestID,
+			&i.BoardID,
+			&i.UserID,
+			&i.Provider,
+			&i.Model,
+			&i.Failed,
+			&i.QueueMs,
+			&i.FirstByteMs,
+			&i.LlmMs,
+			&i.ValidateMs,
+			&i.BroadcastMs,
+			&i.TotalMs,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
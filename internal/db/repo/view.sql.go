@@ -0,0 +1,183 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: view.sql
+
+package repo
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createView = `-- name: CreateView :one
+INSERT INTO "board_view" (board_id, name, x, y, zoom, width, height, frame_id, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, board_id, name, x, y, zoom, width, height, frame_id, created_by, created_at
+`
+
+type CreateViewParams struct {
+	BoardID   uuid.UUID `db:"board_id" json:"boardId"`
+	Name      string    `db:"name" json:"name"`
+	X         *float64  `db:"x" json:"x"`
+	Y         *float64  `db:"y" json:"y"`
+	Zoom      *float64  `db:"zoom" json:"zoom"`
+	Width     *float64  `db:"width" json:"width"`
+	Height    *float64  `db:"height" json:"height"`
+	FrameID   *string   `db:"frame_id" json:"frameId"`
+	CreatedBy string    `db:"created_by" json:"createdBy"`
+}
+
+func (q *Queries) CreateView(ctx context.Context, arg CreateViewParams) (BoardView, error) {
+	row := q.db.QueryRow(ctx, createView,
+		arg.BoardID,
+		arg.Name,
+		arg.X,
+		arg.Y,
+		arg.Zoom,
+		arg.Width,
+		arg.Height,
+		arg.FrameID,
+		arg.CreatedBy,
+	)
+	var i BoardView
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.Name,
+		&i.X,
+		&i.Y,
+		&i.Zoom,
+		&i.Width,
+		&i.Height,
+		&i.FrameID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteView = `-- name: DeleteView :execrows
+DELETE FROM "board_view" WHERE id = $1 AND board_id = $2
+`
+
+type DeleteViewParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+}
+
+func (q *Queries) DeleteView(ctx context.Context, arg DeleteViewParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteView, arg.ID, arg.BoardID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getViewByID = `-- name: GetViewByID :one
+SELECT id, board_id, name, x, y, zoom, width, height, frame_id, created_by, created_at FROM "board_view" WHERE id = $1 AND board_id = $2
+`
+
+type GetViewByIDParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+}
+
+func (q *Queries) GetViewByID(ctx context.Context, arg GetViewByIDParams) (BoardView, error) {
+	row := q.db.QueryRow(ctx, getViewByID, arg.ID, arg.BoardID)
+	var i BoardView
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.Name,
+		&i.X,
+		&i.Y,
+		&i.Zoom,
+		&i.Width,
+		&i.Height,
+		&i.FrameID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getViewsByBoardID = `-- name: GetViewsByBoardID :many
+SELECT id, board_id, name, x, y, zoom, width, height, frame_id, created_by, created_at FROM "board_view" WHERE board_id = $1 ORDER BY name ASC
+`
+
+func (q *Queries) GetViewsByBoardID(ctx context.Context, boardID uuid.UUID) ([]BoardView, error) {
+	rows, err := q.db.Query(ctx, getViewsByBoardID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BoardView{}
+	for rows.Next() {
+		var i BoardView
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoardID,
+			&i.Name,
+			&i.X,
+			&i.Y,
+			&i.Zoom,
+			&i.Width,
+			&i.Height,
+			&i.FrameID,
+			&i.CreatedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateView = `-- name: UpdateView :one
+UPDATE "board_view" SET name = $3, x = $4, y = $5, zoom = $6, width = $7, height = $8, frame_id = $9 WHERE id = $1 AND board_id = $2 RETURNING id, board_id, name, x, y, zoom, width, height, frame_id, created_by, created_at
+`
+
+type UpdateViewParams struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BoardID uuid.UUID `db:"board_id" json:"boardId"`
+	Name    string    `db:"name" json:"name"`
+	X       *float64  `db:"x" json:"x"`
+	Y       *float64  `db:"y" json:"y"`
+	Zoom    *float64  `db:"zoom" json:"zoom"`
+	Width   *float64  `db:"width" json:"width"`
+	Height  *float64  `db:"height" json:"height"`
+	FrameID *string   `db:"frame_id" json:"frameId"`
+}
+
+func (q *Queries) UpdateView(ctx context.Context, arg UpdateViewParams) (BoardView, error) {
+	row := q.db.QueryRow(ctx, updateView,
+		arg.ID,
+		arg.BoardID,
+		arg.Name,
+		arg.X,
+		arg.Y,
+		arg.Zoom,
+		arg.Width,
+		arg.Height,
+		arg.FrameID,
+	)
+	var i BoardView
+	err := row.Scan(
+		&i.ID,
+		&i.BoardID,
+		&i.Name,
+		&i.X,
+		&i.Y,
+		&i.Zoom,
+		&i.Width,
+		&i.Height,
+		&i.FrameID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,38 @@
+-- name: RollupInstructionIntents :exec
+INSERT INTO "instruction_intent_daily" (day, intent, outcome, provider, count, latency_ms_sum, latency_ms_max)
+SELECT (created_at AT TIME ZONE 'UTC')::date, intent, outcome, COALESCE(provider, ''), COUNT(*), COALESCE(SUM(latency_ms), 0), COALESCE(MAX(latency_ms), 0)
+FROM "board_instruction"
+WHERE created_at >= sqlc.arg(since) AND created_at < sqlc.arg(until)
+GROUP BY 1, 2, 3, 4
+ON CONFLICT (day, intent, outcome, provider) DO UPDATE SET
+	count = EXCLUDED.count,
+	latency_ms_sum = EXCLUDED.latency_ms_sum,
+	latency_ms_max = EXCLUDED.latency_ms_max;
+
+-- name: GetFailedInstructionsBetween :many
+SELECT board_id, intent, instruction FROM "board_instruction"
+WHERE created_at >= sqlc.arg(since) AND created_at < sqlc.arg(until) AND outcome <> 'success' AND instruction <> ''
+ORDER BY created_at
+LIMIT sqlc.arg(max_rows);
+
+-- name: DeleteFailurePatterns :exec
+DELETE FROM "instruction_failure_pattern_daily" WHERE day = $1;
+
+-- name: UpsertFailurePattern :exec
+INSERT INTO "instruction_failure_pattern_daily" (day, intent, pattern, count) VALUES ($1, $2, $3, $4)
+ON CONFLICT (day, intent, pattern) DO UPDATE SET count = EXCLUDED.count;
+
+-- name: GetIntentRollups :many
+SELECT intent, outcome, provider, SUM(count)::bigint AS count, SUM(latency_ms_sum)::bigint AS latency_ms_sum, MAX(latency_ms_max)::integer AS latency_ms_max
+FROM "instruction_intent_daily"
+WHERE day >= sqlc.arg(from_day) AND day <= sqlc.arg(to_day)
+GROUP BY intent, outcome, provider
+ORDER BY intent, outcome, provider;
+
+-- name: GetTopFailurePatterns :many
+SELECT intent, pattern, SUM(count)::bigint AS count
+FROM "instruction_failure_pattern_daily"
+WHERE day >= sqlc.arg(from_day) AND day <= sqlc.arg(to_day)
+GROUP BY intent, pattern
+ORDER BY count DESC, intent, pattern
+LIMIT sqlc.arg(max_patterns);
@@ -1,14 +1,35 @@
 -- name: CreateBoard :one
-INSERT INTO "board" (name, owner_id) VALUES ($1, $2) RETURNING *;
+INSERT INTO "board" (name, owner_id, icon, color) VALUES ($1, $2, $3, $4) RETURNING *;
 
 -- name: GetBoardByID :one
 SELECT * FROM "board" WHERE id = $1 AND owner_id = $2;
 
 -- name: GetBoardsByUserID :many
-SELECT * FROM "board" WHERE owner_id = $1;
+SELECT * FROM "board" WHERE owner_id = $1 AND (archived_at IS NULL OR sqlc.arg(include_archived)::boolean);
 
 -- name: UpdateBoard :one
-UPDATE "board" SET name = $2, elements = $3 WHERE id = $1 AND owner_id = $4 RETURNING *;
+UPDATE "board" SET name = $2, elements = $3, icon = $5, color = $6, protected = $7, timezone = $8, locale = $9, arrow_repair = $10 WHERE id = $1 AND owner_id = $4 RETURNING *;
 
 -- name: DeleteBoard :exec
-DELETE FROM "board" WHERE id = $1 AND owner_id = $2;
\ No newline at end of file
+DELETE FROM "board" WHERE id = $1 AND owner_id = $2;
+
+-- name: GetBoardProtection :one
+SELECT protected FROM "board" WHERE id = $1;
+
+-- name: GetBoardLocale :one
+SELECT timezone, locale FROM "board" WHERE id = $1;
+
+-- name: GetBoardArrowRepair :one
+SELECT arrow_repair FROM "board" WHERE id = $1;
+
+-- name: ArchiveBoard :one
+UPDATE "board" SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP) WHERE id = $1 AND owner_id = $2 RETURNING *;
+
+-- name: UnarchiveBoard :one
+UPDATE "board" SET archived_at = NULL WHERE id = $1 AND owner_id = $2 RETURNING *;
+
+-- name: GetBoardForUpdate :one
+SELECT * FROM "board" WHERE id = $1 FOR UPDATE;
+
+-- name: UpdateBoardElements :one
+UPDATE "board" SET elements = $2 WHERE id = $1 RETURNING *;
@@ -0,0 +1,21 @@
+-- name: CreateComment :one
+INSERT INTO "board_comment" (board_id, element_id, author_id, text) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetCommentsByBoardID :many
+SELECT * FROM "board_comment" WHERE board_id = $1 ORDER BY created_at ASC;
+
+-- name: UpdateComment :one
+UPDATE "board_comment" SET text = $3, resolved = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND board_id = $2 RETURNING *;
+
+-- name: GetCommentByID :one
+SELECT * FROM "board_comment" WHERE id = $1 AND board_id = $2;
+
+-- name: DeleteComment :execrows
+DELETE FROM "board_comment" WHERE id = $1 AND board_id = $2;
+
+-- name: OrphanComments :many
+UPDATE "board_comment" SET orphaned = true, updated_at = CURRENT_TIMESTAMP
+WHERE board_id = $1 AND element_id IS NOT NULL AND NOT orphaned AND NOT (element_id = ANY(sqlc.arg(element_ids)::text[])) RETURNING *;
+
+-- name: ImportComment :one
+INSERT INTO "board_comment" (board_id, element_id, author_id, text, resolved, orphaned, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
@@ -0,0 +1,27 @@
+-- name: CreateDemoBoard :one
+INSERT INTO "demo_board" (session_id, name, expires_at, max_expires_at) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetDemoBoard :one
+SELECT * FROM "demo_board" WHERE id = $1 AND session_id = $2 AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: GetDemoBoardForClaim :one
+SELECT * FROM "demo_board" WHERE id = $1 AND session_id = $2 AND expires_at > CURRENT_TIMESTAMP FOR UPDATE;
+
+-- name: CountDemoBoardsBySession :one
+SELECT COUNT(*) FROM "demo_board" WHERE session_id = $1 AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: UpdateDemoBoardElements :one
+UPDATE "demo_board" SET elements = sqlc.arg(elements), expires_at = LEAST(max_expires_at, sqlc.arg(expires_at))
+WHERE id = sqlc.arg(id) AND session_id = sqlc.arg(session_id) AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: TakeDemoInstruction :one
+UPDATE "demo_board" SET instruction_count = instruction_count + 1
+WHERE id = sqlc.arg(id) AND session_id = sqlc.arg(session_id) AND expires_at > CURRENT_TIMESTAMP AND instruction_count < sqlc.arg(max_instructions)::integer
+RETURNING instruction_count;
+
+-- name: DeleteDemoBoard :exec
+DELETE FROM "demo_board" WHERE id = $1;
+
+-- name: DeleteExpiredDemoBoards :execrows
+DELETE FROM "demo_board" WHERE expires_at <= CURRENT_TIMESTAMP;
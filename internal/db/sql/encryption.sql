@@ -0,0 +1,43 @@
+-- name: GetBoardDataKey :one
+SELECT * FROM "board_data_key" WHERE board_id = $1;
+
+-- name: CreateBoardDataKey :exec
+INSERT INTO "board_data_key" (board_id, wrapped_key, master_key_id) VALUES ($1, $2, $3) ON CONFLICT (board_id) DO NOTHING;
+
+-- name: GetBoardDataKeysToRotate :many
+SELECT * FROM "board_data_key" WHERE master_key_id <> $1 ORDER BY board_id LIMIT $2;
+
+-- name: RewrapBoardDataKey :execrows
+UPDATE "board_data_key" SET wrapped_key = sqlc.arg(wrapped_key), master_key_id = sqlc.arg(new_master_key_id), rotated_at = CURRENT_TIMESTAMP
+WHERE board_id = sqlc.arg(board_id) AND master_key_id = sqlc.arg(old_master_key_id);
+
+-- name: GetPlaintextBoards :many
+SELECT id, elements FROM "board"
+WHERE elements IS NOT NULL AND elements <> 'null'::jsonb AND NOT (jsonb_typeof(elements) = 'string' AND elements #>> '{}' LIKE 'enc:v1:%')
+ORDER BY id LIMIT $1;
+
+-- name: EncryptBoardElements :exec
+UPDATE "board" SET elements = $2 WHERE id = $1;
+
+-- name: GetPlaintextComments :many
+SELECT id, board_id, text FROM "board_comment" WHERE text <> '' AND text NOT LIKE 'enc:v1:%' ORDER BY id LIMIT $1;
+
+-- name: EncryptCommentText :exec
+UPDATE "board_comment" SET text = $3 WHERE id = $1 AND board_id = $2;
+
+-- name: GetPlaintextInstructions :many
+SELECT id, board_id, instruction, raw_response, inverse_action FROM "board_instruction"
+WHERE (instruction <> '' AND instruction NOT LIKE 'enc:v1:%') OR (raw_response IS NOT NULL AND raw_response <> '' AND raw_response NOT LIKE 'enc:v1:%')
+OR (inverse_action IS NOT NULL AND inverse_action NOT LIKE 'enc:v1:%')
+ORDER BY id LIMIT $1;
+
+-- name: EncryptInstruction :exec
+UPDATE "board_instruction" SET instruction = $3, raw_response = $4, inverse_action = $5 WHERE id = $1 AND board_id = $2;
+
+-- name: GetPlaintextPendingChanges :many
+SELECT id, board_id, instruction, response FROM "board_pending_change"
+WHERE (instruction <> '' AND instruction NOT LIKE 'enc:v1:%') OR (response <> '' AND response NOT LIKE 'enc:v1:%')
+ORDER BY id LIMIT $1;
+
+-- name: EncryptPendingChange :exec
+UPDATE "board_pending_change" SET instruction = $3, response = $4 WHERE id = $1 AND board_id = $2;
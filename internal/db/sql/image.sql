@@ -0,0 +1,5 @@
+-- name: CreateImage :one
+INSERT INTO "board_image" (id, board_id, object_key, mime_type, size, created_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: GetImageByID :one
+SELECT * FROM "board_image" WHERE id = $1 AND board_id = $2;
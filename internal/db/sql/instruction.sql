@@ -0,0 +1,28 @@
+-- name: CreateInstruction :one
+INSERT INTO "board_instruction" (board_id, user_id, instruction, raw_response, error, provider, prompt_tokens, completion_tokens, cost_usd, intent, outcome, latency_ms, inverse_action) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING *;
+
+-- name: GetInstructionsByBoardID :many
+SELECT * FROM "board_instruction" WHERE board_id = $1 ORDER BY created_at DESC LIMIT $2;
+
+-- name: GetLastUndoableInstruction :one
+SELECT * FROM "board_instruction" WHERE board_id = $1 AND inverse_action IS NOT NULL AND undone_at IS NULL
+ORDER BY created_at DESC LIMIT 1 FOR UPDATE;
+
+-- name: GetUndoableInstructionsByBoardID :many
+SELECT * FROM "board_instruction" WHERE board_id = $1 AND inverse_action IS NOT NULL AND undone_at IS NULL
+ORDER BY created_at DESC LIMIT $2;
+
+-- name: MarkInstructionUndone :exec
+UPDATE "board_instruction" SET undone_at = CURRENT_TIMESTAMP WHERE id = $1;
+
+-- name: RedactInstructionsBefore :execrows
+UPDATE "board_instruction" i SET instruction = '', redacted_at = CURRENT_TIMESTAMP
+WHERE i.created_at < $1 AND i.redacted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM "board" b WHERE b.id = i.board_id AND b.legal_hold_at IS NOT NULL);
+
+-- name: CountHeldInstructionsBefore :one
+SELECT COUNT(*) FROM "board_instruction" i JOIN "board" b ON b.id = i.board_id
+WHERE i.created_at < $1 AND i.redacted_at IS NULL AND b.legal_hold_at IS NOT NULL;
+
+-- name: SumInstructionCostSince :one
+SELECT COALESCE(SUM(cost_usd), 0)::float8 AS total FROM "board_instruction" WHERE created_at >= $1;
@@ -0,0 +1,23 @@
+-- name: CreateInstructionIntent :exec
+INSERT INTO "instruction_intent" (board_id, request_id, user_id, instruction) VALUES ($1, $2, $3, $4)
+ON CONFLICT (request_id) DO NOTHING;
+
+-- name: SettleInstructionIntent :execrows
+UPDATE "instruction_intent" SET state = sqlc.arg(state), updated_at = CURRENT_TIMESTAMP
+WHERE request_id = sqlc.arg(request_id) AND state IN ('pending', 'interrupted', 'resuming');
+
+-- name: InterruptPendingIntents :execrows
+UPDATE "instruction_intent" SET state = 'interrupted', updated_at = CURRENT_TIMESTAMP
+WHERE state = 'pending' AND created_at >= sqlc.arg(since) AND created_at < sqlc.arg(before);
+
+-- name: AbandonStaleIntents :execrows
+UPDATE "instruction_intent" SET state = 'failed', updated_at = CURRENT_TIMESTAMP
+WHERE state IN ('pending', 'interrupted', 'resuming') AND created_at < $1;
+
+-- name: ClaimInterruptedIntents :many
+UPDATE "instruction_intent" SET state = 'resuming', updated_at = CURRENT_TIMESTAMP
+WHERE board_id = sqlc.arg(board_id) AND state = 'interrupted' AND created_at >= sqlc.arg(since)
+RETURNING *;
+
+-- name: DeleteIntentsBefore :execrows
+DELETE FROM "instruction_intent" WHERE updated_at < $1;
@@ -0,0 +1,8 @@
+-- name: PlaceLegalHold :execrows
+UPDATE "board" SET legal_hold_reason = $2, legal_hold_at = CURRENT_TIMESTAMP WHERE id = $1;
+
+-- name: ReleaseLegalHold :execrows
+UPDATE "board" SET legal_hold_reason = NULL, legal_hold_at = NULL WHERE id = $1 AND legal_hold_at IS NOT NULL;
+
+-- name: GetLegalHolds :many
+SELECT id, owner_id, name, legal_hold_reason, legal_hold_at FROM "board" WHERE legal_hold_at IS NOT NULL ORDER BY legal_hold_at;
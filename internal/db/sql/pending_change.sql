@@ -0,0 +1,12 @@
+-- name: CreatePendingChange :one
+INSERT INTO "board_pending_change" (board_id, user_id, instruction, response, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: GetPendingChangesByBoardID :many
+SELECT * FROM "board_pending_change" WHERE board_id = $1 AND status = 'pending' AND expires_at > CURRENT_TIMESTAMP ORDER BY created_at ASC;
+
+-- name: DecidePendingChange :one
+UPDATE "board_pending_change" SET status = $3, reason = $4, decided_by = $5, decided_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND board_id = $2 AND status = 'pending' AND expires_at > CURRENT_TIMESTAMP RETURNING *;
+
+-- name: ExpirePendingChanges :execrows
+UPDATE "board_pending_change" SET status = 'expired', decided_at = CURRENT_TIMESTAMP WHERE status = 'pending' AND expires_at <= CURRENT_TIMESTAMP;
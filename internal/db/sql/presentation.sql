@@ -0,0 +1,14 @@
+-- name: CreatePresentationToken :one
+INSERT INTO "board_presentation_token" (board_id, token_hash, label, created_by, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: GetPresentationTokensByBoardID :many
+SELECT * FROM "board_presentation_token" WHERE board_id = $1 ORDER BY created_at DESC;
+
+-- name: GetActivePresentationToken :one
+SELECT * FROM "board_presentation_token" WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: RevokePresentationToken :execrows
+UPDATE "board_presentation_token" SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND board_id = $2 AND revoked_at IS NULL;
+
+-- name: GetBoardForPresentation :one
+SELECT id, name, elements FROM "board" WHERE id = $1;
@@ -0,0 +1,27 @@
+-- name: CreateServiceAccount :one
+INSERT INTO "service_account" (owner_id, name, description) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetServiceAccountsByOwnerID :many
+SELECT * FROM "service_account" WHERE owner_id = $1 ORDER BY created_at DESC;
+
+-- name: GetServiceAccount :one
+SELECT * FROM "service_account" WHERE id = $1 AND owner_id = $2;
+
+-- name: DisableServiceAccount :execrows
+UPDATE "service_account" SET disabled_at = CURRENT_TIMESTAMP WHERE id = $1 AND owner_id = $2 AND disabled_at IS NULL;
+
+-- name: CreateServiceAccountKey :one
+INSERT INTO "service_account_key" (service_account_id, key_hash, scopes) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: RevokeServiceAccountKeys :exec
+UPDATE "service_account_key" SET revoked_at = CURRENT_TIMESTAMP WHERE service_account_id = $1 AND revoked_at IS NULL;
+
+-- name: GetActiveServiceAccountKeysByOwnerID :many
+SELECT k.* FROM "service_account_key" k
+JOIN "service_account" a ON a.id = k.service_account_id
+WHERE a.owner_id = $1 AND k.revoked_at IS NULL;
+
+-- name: GetActiveServiceAccountKey :one
+SELECT k.id, k.service_account_id, k.scopes, a.owner_id, a.name FROM "service_account_key" k
+JOIN "service_account" a ON a.id = k.service_account_id
+WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND a.disabled_at IS NULL;
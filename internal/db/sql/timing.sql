@@ -0,0 +1,18 @@
+-- name: CreateInstructionTiming :exec
+INSERT INTO "instruction_timing" (request_id, board_id, user_id, provider, model, failed, queue_ms, first_byte_ms, llm_ms, validate_ms, broadcast_ms, total_ms)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+
+-- name: GetSlowInstructionTimings :many
+SELECT * FROM "instruction_timing"
+WHERE created_at >= sqlc.arg(since) AND total_ms >= sqlc.arg(threshold_ms)::integer
+ORDER BY total_ms DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: DeleteInstructionTimingsBefore :execrows
+DELETE FROM "instruction_timing" t
+WHERE t.created_at < $1
+AND NOT EXISTS (SELECT 1 FROM "board" b WHERE b.id = t.board_id AND b.legal_hold_at IS NOT NULL);
+
+-- name: CountHeldInstructionTimingsBefore :one
+SELECT COUNT(*) FROM "instruction_timing" t JOIN "board" b ON b.id = t.board_id
+WHERE t.created_at < $1 AND b.legal_hold_at IS NOT NULL;
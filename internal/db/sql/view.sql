@@ -0,0 +1,14 @@
+-- name: CreateView :one
+INSERT INTO "board_view" (board_id, name, x, y, zoom, width, height, frame_id, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;
+
+-- name: GetViewsByBoardID :many
+SELECT * FROM "board_view" WHERE board_id = $1 ORDER BY name ASC;
+
+-- name: GetViewByID :one
+SELECT * FROM "board_view" WHERE id = $1 AND board_id = $2;
+
+-- name: UpdateView :one
+UPDATE "board_view" SET name = $3, x = $4, y = $5, zoom = $6, width = $7, height = $8, frame_id = $9 WHERE id = $1 AND board_id = $2 RETURNING *;
+
+-- name: DeleteView :execrows
+DELETE FROM "board_view" WHERE id = $1 AND board_id = $2;
@@ -0,0 +1,75 @@
+package dto
+
+import "github.com/google/uuid"
+
+// IntentStats summarises the instructions classified as one intent.
+type IntentStats struct {
+	Intent string `json:"intent"`
+	Total  int64  `json:"total"`
+	// Outcomes counts instructions per outcome: success, not_actionable,
+	// validation_failed, filtered or error.
+	Outcomes     map[string]int64 `json:"outcomes"`
+	Providers    map[string]int64 `json:"providers"`
+	FailureRate  float64          `json:"failureRate"`
+	AvgLatencyMs float64          `json:"avgLatencyMs"`
+	MaxLatencyMs int32            `json:"maxLatencyMs"`
+}
+
+// FailurePattern is a normalized n-gram shared by failing instructions.
+type FailurePattern struct {
+	Intent  string   `json:"intent"`
+	Pattern string   `json:"pattern"`
+	Count   int64    `json:"count"`
+	Samples []string `json:"samples,omitempty"`
+}
+
+// InstructionTiming is where one instruction's time went, in milliseconds.
+// FirstByteMs is 0 when the LLM response wasn't streamed.
+type InstructionTiming struct {
+	RequestID   string    `json:"requestId"`
+	BoardID     uuid.UUID `json:"boardId"`
+	UserID      string    `json:"userId"`
+	Provider    string    `json:"provider"`
+	Model       string    `json:"model"`
+	Failed      bool      `json:"failed"`
+	QueueMs     int32     `json:"queueMs"`
+	FirstByteMs int32     `json:"firstByteMs"`
+	LLMMs       int32     `json:"llmMs"`
+	ValidateMs  int32     `json:"validateMs"`
+	BroadcastMs int32     `json:"broadcastMs"`
+	TotalMs     int32     `json:"totalMs"`
+	CreatedAt   Timestamp `json:"createdAt"`
+}
+
+// Request
+
+type IntentAnalyticsRequest struct {
+	From           string `form:"from"`
+	To             string `form:"to"`
+	Org            string `form:"org"`
+	IncludeSamples bool   `form:"include_samples"`
+}
+
+// SlowInstructionsRequest filters the slow instruction report. Since is an
+// RFC 3339 time and defaults to a day ago; ThresholdMs defaults to the SLO
+// latency target.
+type SlowInstructionsRequest struct {
+	Since       string `form:"since"`
+	ThresholdMs *int   `form:"threshold_ms"`
+	Limit       int    `form:"limit"`
+}
+
+// Response
+
+type IntentAnalyticsResponse struct {
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Intents  []IntentStats    `json:"intents"`
+	Patterns []FailurePattern `json:"topFailingPatterns"`
+}
+
+type SlowInstructionsResponse struct {
+	Since        Timestamp           `json:"since"`
+	ThresholdMs  int                 `json:"thresholdMs"`
+	Instructions []InstructionTiming `json:"instructions"`
+}
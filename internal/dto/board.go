@@ -2,6 +2,9 @@ package dto
 
 import (
 	"encoding/json"
+	"io"
+
+	"draw/pkg/llm"
 
 	"github.com/google/uuid"
 )
@@ -12,6 +15,18 @@ type Board struct {
 	Name string `json:"name"`
 	OwnerID string `json:"ownerId"`
 	Elements json.RawMessage `json:"elements"`
+	Icon *string `json:"icon"`
+	Color *string `json:"color"`
+	Protected bool `json:"protected"`
+	Timezone *string `json:"timezone"`
+	Locale *string `json:"locale"`
+	ArrowRepair string `json:"arrowRepair"`
+	// LegalHold is set while the board is preserved for legal reasons;
+	// deleting it or its comments is refused until the hold is released.
+	LegalHold bool `json:"legalHold,omitempty"`
+	// Archived boards are read-only and left out of the board list unless
+	// it asks for them.
+	Archived bool `json:"archived,omitempty"`
 }
 
 // Request
@@ -19,22 +34,75 @@ type Board struct {
 type GetBoardRequest struct {
 	BoardID string `json:"-"`
 	UserID string `json:"-"`
+	// ServiceAccountID is the service account opening the board for its
+	// owner, if any. It joins the room under an identity of its own, shown
+	// as ServiceAccount, its name, and may only speak with the instructions
+	// scope among ServiceAccountScopes.
+	ServiceAccountID string `json:"-"`
+	ServiceAccount string `json:"-"`
+	ServiceAccountScopes []string `json:"-"`
 }
 
 type GetBoardsByUserIDRequest struct {
 	UserID string `json:"-"`
+	IncludeArchived bool `form:"include_archived"`
 }
 
 type CreateBoardRequest struct {
 	UserID string `json:"-"`
-	Name string `json:"name" binding:"required"`
+	// Name is trimmed; boards created without one are named "Untitled
+	// board".
+	Name string `json:"name"`
+	Icon *string `json:"icon,omitempty"`
+	Color *string `json:"color,omitempty"`
 }
 
 type UpdateBoardRequest struct {
 	BoardID string `json:"-"`
 	UserID string `json:"-"`
+	MutationID string `json:"-"`
 	Name string `json:"name,omitempty"`
 	Elements json.RawMessage `json:"elements,omitempty"`
+	// Icon and Color are left unchanged when omitted and cleared when empty.
+	Icon *string `json:"icon,omitempty"`
+	Color *string `json:"color,omitempty"`
+	// Protected boards hold every voice change for approval.
+	Protected *bool `json:"protected,omitempty"`
+	// Timezone (IANA name) and Locale (e.g. "en-GB") are used to resolve
+	// dates spoken in voice instructions. Empty clears them.
+	Timezone *string `json:"timezone,omitempty"`
+	Locale *string `json:"locale,omitempty"`
+	// ArrowRepair decides what happens to arrows bound to deleted elements:
+	// "unbind" or "cascade".
+	ArrowRepair *string `json:"arrowRepair,omitempty"`
+}
+
+type ApplyPartialRequest struct {
+	BoardID string `json:"-"`
+	UserID string `json:"-"`
+	Token string `json:"-"`
+}
+
+type ConfirmActionRequest struct {
+	BoardID string `json:"-"`
+	UserID string `json:"-"`
+	Token string `json:"-"`
+}
+
+type UndoRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+// StreamSpeechRequest carries raw 16 kHz mono PCM16 audio, read as it
+// arrives.
+type StreamSpeechRequest struct {
+	BoardID string `json:"-"`
+	UserID string `json:"-"`
+	// ServiceAccountID is the service account uploading for its owner, if
+	// any; the instructions count against its quota rather than the owner's.
+	ServiceAccountID string `json:"-"`
+	Audio io.Reader `json:"-"`
 }
 
 type DeleteBoardRequest struct {
@@ -42,9 +110,16 @@ type DeleteBoardRequest struct {
 	UserID string `json:"-"`
 }
 
+type ArchiveBoardRequest struct {
+	BoardID string `json:"-"`
+	UserID string `json:"-"`
+}
+
 // Response
 type CreateBoardResponse struct {
 	BoardID uuid.UUID `json:"boardId"`
+	Name string `json:"name"`
+	CreatedAt Timestamp `json:"createdAt"`
 }
 
 type GetBoardResponse struct {
@@ -54,4 +129,56 @@ type GetBoardResponse struct {
 
 type GetBoardsByUserIDResponse struct {
 	Boards []Board `json:"boards"`
-}
\ No newline at end of file
+}
+type ExportBoardRequest struct {
+	BoardID string `json:"-"`
+	UserID string `json:"-"`
+	// Format is "bundle", the default, "mermaid", "excalidraw" or "svg".
+	Format string `json:"-"`
+}
+
+// ExportBoardResponse describes what ExportBoard wrote.
+type ExportBoardResponse struct {
+	ContentType string `json:"-"`
+	// Filename is the name to suggest saving the export as, if any.
+	Filename string `json:"-"`
+}
+
+type ImportBoardRequest struct {
+	UserID string `json:"-"`
+	Bundle []byte `json:"-"`
+}
+
+type ImportBoardResponse struct {
+	BoardID uuid.UUID `json:"boardId"`
+	// Skipped lists bundle parts or values that were not imported, with why.
+	Skipped []string `json:"skipped"`
+}
+
+type ImportMermaidRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+	// Source is a Mermaid flowchart, starting "graph" or "flowchart".
+	Source string `json:"source" binding:"required"`
+}
+
+type ImportMermaidResponse struct {
+	// Elements are the elements added to the board.
+	Elements json.RawMessage `json:"elements"`
+}
+
+type InstantiateTemplateRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+	Name    string `json:"-"`
+	// Origin is where the template's top-left corner goes; without one it
+	// goes below what is on the board.
+	Origin *llm.Point `json:"origin"`
+	// Params fill in the template's text; the rest keep their defaults.
+	Params map[string]string `json:"params"`
+}
+
+type InstantiateTemplateResponse struct {
+	// Elements are the elements added to the board.
+	Elements json.RawMessage `json:"elements"`
+}
@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+)
+
+// Comment is a note on a board. ElementID is nil for board-level comments.
+// Orphaned comments refer to an element that has since been deleted.
+type Comment struct {
+	ID        uuid.UUID `json:"id"`
+	BoardID   uuid.UUID `json:"boardId"`
+	ElementID *string   `json:"elementId"`
+	AuthorID  string    `json:"authorId"`
+	Text      string    `json:"text"`
+	Resolved  bool      `json:"resolved"`
+	Orphaned  bool      `json:"orphaned"`
+	CreatedAt Timestamp `json:"createdAt"`
+	UpdatedAt Timestamp `json:"updatedAt"`
+}
+
+// CommentEvent is broadcast on the board when a comment changes.
+type CommentEvent struct {
+	Action  string  `json:"action"`
+	Comment Comment `json:"comment"`
+}
+
+// Request
+
+type GetCommentsRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+type CreateCommentRequest struct {
+	BoardID    string  `json:"-"`
+	UserID     string  `json:"-"`
+	ElementID  *string `json:"elementId,omitempty"`
+	Text       string  `json:"text" binding:"required"`
+	MutationID string  `json:"-"`
+}
+
+type UpdateCommentRequest struct {
+	BoardID    string  `json:"-"`
+	CommentID  string  `json:"-"`
+	UserID     string  `json:"-"`
+	Text       *string `json:"text,omitempty"`
+	Resolved   *bool   `json:"resolved,omitempty"`
+	MutationID string  `json:"-"`
+}
+
+type DeleteCommentRequest struct {
+	BoardID    string `json:"-"`
+	CommentID  string `json:"-"`
+	UserID     string `json:"-"`
+	MutationID string `json:"-"`
+}
+
+// Response
+
+type GetCommentsResponse struct {
+	Comments []Comment `json:"comments"`
+}
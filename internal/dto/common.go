@@ -1,8 +1,19 @@
 package dto
 
 type ErrorResponse struct {
-	Message string `json:"message"`
-	Error   string `json:"error,omitempty"`
+	Message string         `json:"message"`
+	Error   string         `json:"error,omitempty"`
+	Retry   *RetryGuidance `json:"retry,omitempty"`
+}
+
+// RetryGuidance tells clients how to back off from a 429 or 503.
+// JitteredAfterMs is AfterMs plus server-side jitter, so clients that use it
+// don't all retry at the same moment.
+type RetryGuidance struct {
+	AfterMs         int64  `json:"afterMs"`
+	JitteredAfterMs int64  `json:"jitteredAfterMs"`
+	Retryable       bool   `json:"retryable"`
+	Resource        string `json:"resource"`
 }
 
 type SuccessResponse struct {
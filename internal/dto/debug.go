@@ -0,0 +1,17 @@
+package dto
+
+import "draw/pkg/llmdebug"
+
+type GetLLMHistoryRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+// GetLLMHistoryResponse lists the board's recent model interactions, newest
+// first. Entries only live in the memory of the server that ran them.
+type GetLLMHistoryResponse struct {
+	Entries []llmdebug.Entry `json:"entries"`
+	// RawOutput is false when raw model output may not be stored, in which
+	// case attempts carry only how they failed.
+	RawOutput bool `json:"rawOutput"`
+}
@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"draw/pkg/llm"
+
+	"github.com/google/uuid"
+)
+
+// DemoBoard is an anonymous board from the public demo. It is deleted at
+// ExpiresAt unless it is claimed first.
+type DemoBoard struct {
+	ID               uuid.UUID       `json:"id"`
+	Name             string          `json:"name"`
+	Elements         json.RawMessage `json:"elements"`
+	ExpiresAt        Timestamp       `json:"expiresAt"`
+	InstructionsLeft int             `json:"instructionsLeft"`
+}
+
+// Request
+
+type CreateDemoBoardRequest struct {
+	SessionID string `json:"-"`
+	Name      string `json:"name,omitempty"`
+}
+
+type GetDemoBoardRequest struct {
+	SessionID string `json:"-"`
+	BoardID   string `json:"-"`
+}
+
+type UpdateDemoBoardRequest struct {
+	SessionID string          `json:"-"`
+	BoardID   string          `json:"-"`
+	Elements  json.RawMessage `json:"elements" binding:"required"`
+}
+
+// DemoInstructionRequest runs a typed instruction on a demo board. The demo
+// has no voice room, so there is no audio. Mode is "diagram" (the default),
+// "notes" or "mindmap".
+type DemoInstructionRequest struct {
+	SessionID   string `json:"-"`
+	BoardID     string `json:"-"`
+	Instruction string `json:"instruction" binding:"required"`
+	Mode        string `json:"mode,omitempty"`
+}
+
+// ClaimDemoBoardRequest turns a demo board into a board owned by the
+// signed-in user. Session is the demo session cookie the board was made with.
+type ClaimDemoBoardRequest struct {
+	UserID  string `json:"-"`
+	BoardID string `json:"-"`
+	Session string `json:"-"`
+}
+
+// Response
+
+// DemoInstructionResponse carries the action applied to the board, if any.
+// Issues are the validation problems of the model's last answer: the errors
+// that got it rejected, or the warnings it was applied despite.
+type DemoInstructionResponse struct {
+	Board   DemoBoard             `json:"board"`
+	Action  *llm.WhiteboardAction `json:"action,omitempty"`
+	Warning string                `json:"warning,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Issues  []llm.ValidationIssue `json:"issues,omitempty"`
+}
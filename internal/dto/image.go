@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Image is a picture stored in S3 for a board's image elements, which refer
+// to it by FileID.
+type Image struct {
+	FileID    uuid.UUID `json:"fileId"`
+	BoardID   uuid.UUID `json:"boardId"`
+	MimeType  string    `json:"mimeType"`
+	Size      int64     `json:"size"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt Timestamp `json:"createdAt"`
+}
+
+// Request
+
+type CreateImageRequest struct {
+	BoardID  string `json:"-"`
+	UserID   string `json:"-"`
+	MimeType string `json:"mimeType" binding:"required"`
+	Size     int64  `json:"size" binding:"required"`
+}
+
+type GetImageRequest struct {
+	BoardID string `json:"-"`
+	FileID  string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+// Response
+
+// CreateImageResponse says where to upload the image: a PUT of exactly Size
+// bytes to UploadURL with UploadHeaders, before ExpiresAt.
+type CreateImageResponse struct {
+	Image         Image       `json:"image"`
+	UploadURL     string      `json:"uploadUrl"`
+	UploadHeaders http.Header `json:"uploadHeaders"`
+	ExpiresAt     Timestamp   `json:"expiresAt"`
+}
+
+// GetImageResponse carries a URL clients can load the image from until
+// ExpiresAt.
+type GetImageResponse struct {
+	Image     Image     `json:"image"`
+	URL       string    `json:"url"`
+	ExpiresAt Timestamp `json:"expiresAt"`
+}
@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+)
+
+type Instruction struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      string    `json:"userId"`
+	Instruction string    `json:"instruction"`
+	RawResponse *string   `json:"rawResponse,omitempty"`
+	Error       *string   `json:"error,omitempty"`
+	Redacted    bool      `json:"redacted"`
+	CreatedAt   Timestamp `json:"createdAt"`
+}
+
+// Request
+
+type GetBoardInstructionsRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+type GetBoardHistoryRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+// Response
+
+type GetBoardInstructionsResponse struct {
+	Instructions []Instruction `json:"instructions"`
+}
+
+// HistoryEntry is an instruction that can still be undone, with what it
+// changed on the board.
+type HistoryEntry struct {
+	Instruction
+	Diff whiteboard.BoardDiff `json:"diff"`
+}
+
+// GetBoardHistoryResponse lists the board's undoable instructions, newest
+// first.
+type GetBoardHistoryResponse struct {
+	Entries []HistoryEntry `json:"entries"`
+}
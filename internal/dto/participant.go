@@ -0,0 +1,11 @@
+package dto
+
+// Request
+
+type ModerateParticipantRequest struct {
+	BoardID  string `json:"-"`
+	UserID   string `json:"-"`
+	Identity string `json:"-"`
+	// Role is "speaker" or "listener"; only used when changing roles.
+	Role string `json:"role,omitempty"`
+}
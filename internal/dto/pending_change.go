@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+)
+
+type PendingChange struct {
+	ID          uuid.UUID  `json:"id"`
+	BoardID     uuid.UUID  `json:"boardId"`
+	UserID      string     `json:"userId"`
+	Instruction string     `json:"instruction"`
+	Response    string     `json:"response"`
+	Status      string     `json:"status"`
+	Reason      *string    `json:"reason,omitempty"`
+	DecidedBy   *string    `json:"decidedBy,omitempty"`
+	DecidedAt   *Timestamp `json:"decidedAt,omitempty"`
+	ExpiresAt   Timestamp  `json:"expiresAt"`
+	CreatedAt   Timestamp  `json:"createdAt"`
+}
+
+// Request
+
+type GetPendingChangesRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+type DecidePendingChangeRequest struct {
+	BoardID  string `json:"-"`
+	ChangeID string `json:"-"`
+	UserID   string `json:"-"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Response
+
+type GetPendingChangesResponse struct {
+	PendingChanges []PendingChange `json:"pendingChanges"`
+}
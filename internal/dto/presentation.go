@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// PresentationToken is a read-only credential for projecting one board on a
+// display without signing in. The token itself is only returned when it is
+// created.
+type PresentationToken struct {
+	ID        uuid.UUID  `json:"id"`
+	BoardID   uuid.UUID  `json:"boardId"`
+	Label     string     `json:"label"`
+	Token     string     `json:"token,omitempty"`
+	CreatedBy string     `json:"createdBy"`
+	ExpiresAt Timestamp  `json:"expiresAt"`
+	RevokedAt *Timestamp `json:"revokedAt,omitempty"`
+	CreatedAt Timestamp  `json:"createdAt"`
+}
+
+// Presentation is everything a display needs: the board content and a
+// subscribe-only LiveKit token for receiving navigate events.
+type Presentation struct {
+	BoardID  uuid.UUID       `json:"boardId"`
+	Name     string          `json:"name"`
+	Elements json.RawMessage `json:"elements"`
+	Token    string          `json:"token"`
+}
+
+// Request
+
+type CreatePresentationTokenRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+	Label   string `json:"label"`
+	// ExpiresInSec defaults to a day.
+	ExpiresInSec int `json:"expiresInSec"`
+}
+
+type GetPresentationTokensRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+type RevokePresentationTokenRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+	TokenID string `json:"-"`
+}
+
+type GetPresentationRequest struct {
+	BoardID string `json:"-"`
+	TokenID string `json:"-"`
+}
+
+// Response
+
+type GetPresentationTokensResponse struct {
+	Tokens []PresentationToken `json:"tokens"`
+}
@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"draw/pkg/llm"
+	"draw/pkg/llm/prompts"
+)
+
+// Request
+
+// SandboxInstructionRequest runs an instruction against an ad-hoc board
+// state. Provider, Model and PromptVersion override the configured LLM for
+// this request. Mode is "diagram" (the default), "notes" or "mindmap".
+type SandboxInstructionRequest struct {
+	UserID        string          `json:"-"`
+	BoardState    json.RawMessage `json:"boardState"`
+	Instruction   string          `json:"instruction" binding:"required"`
+	Provider      string          `json:"provider,omitempty"`
+	Model         string          `json:"model,omitempty"`
+	PromptVersion string          `json:"promptVersion,omitempty"`
+	Mode          string          `json:"mode,omitempty"`
+	Seed          *int64          `json:"seed,omitempty"`
+	Timezone      string          `json:"timezone,omitempty"`
+	Locale        string          `json:"locale,omitempty"`
+	ArrowRepair   string          `json:"arrowRepair,omitempty"`
+}
+
+// Response
+
+// SandboxAttempt is one try at the instruction: the raw model output and, if
+// it failed, the stage and error, with the validation issues when it failed
+// validation.
+type SandboxAttempt struct {
+	Output string                `json:"output,omitempty"`
+	Stage  string                `json:"stage,omitempty"`
+	Error  string                `json:"error,omitempty"`
+	Issues []llm.ValidationIssue `json:"issues,omitempty"`
+}
+
+// SandboxPrompt is the rendered user prompt and how each section fared
+// against the token budget.
+type SandboxPrompt struct {
+	Version  string                  `json:"version"`
+	Mode     string                  `json:"mode"`
+	System   string                  `json:"system"`
+	User     string                  `json:"user"`
+	Tokens   int                     `json:"tokens"`
+	Sections []prompts.SectionReport `json:"sections"`
+	Dropped  []string                `json:"dropped"`
+	// CompactionLevel is how far the board state was compacted to fit the
+	// model's context window.
+	CompactionLevel int `json:"compactionLevel"`
+}
+
+type SandboxInstructionResponse struct {
+	Provider      string                `json:"provider"`
+	Model         string                `json:"model"`
+	BoardState    json.RawMessage       `json:"boardState"`
+	Referents     []llm.Referent        `json:"referents"`
+	Substitutions []llm.Substitution    `json:"substitutions"`
+	Prompt        SandboxPrompt         `json:"prompt"`
+	Attempts      []SandboxAttempt      `json:"attempts"`
+	Action        *llm.WhiteboardAction `json:"action,omitempty"`
+	Warning       string                `json:"warning,omitempty"`
+	Error         string                `json:"error,omitempty"`
+	// AppliedState is the board after the action, as a client would apply
+	// it. Nothing is saved.
+	AppliedState []llm.Element `json:"appliedState,omitempty"`
+	DurationMs   int64         `json:"durationMs"`
+}
@@ -0,0 +1,49 @@
+package dto
+
+import "github.com/google/uuid"
+
+// ServiceAccount is a non-human identity, such as a meeting bot, acting on
+// its owner's boards with a scoped API key. The key itself is only returned
+// when it is issued.
+type ServiceAccount struct {
+	ID          uuid.UUID  `json:"id"`
+	OwnerID     string     `json:"ownerId"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Scopes      []string   `json:"scopes"`
+	Key         string     `json:"key,omitempty"`
+	DisabledAt  *Timestamp `json:"disabledAt,omitempty"`
+	CreatedAt   Timestamp  `json:"createdAt"`
+}
+
+// Request
+
+type CreateServiceAccountRequest struct {
+	UserID      string   `json:"-"`
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes" binding:"required"`
+}
+
+type GetServiceAccountsRequest struct {
+	UserID string `json:"-"`
+}
+
+// RotateServiceAccountKeyRequest issues a new key and revokes the old ones.
+// Scopes default to those of the current key.
+type RotateServiceAccountKeyRequest struct {
+	UserID           string   `json:"-"`
+	ServiceAccountID string   `json:"-"`
+	Scopes           []string `json:"scopes"`
+}
+
+type DisableServiceAccountRequest struct {
+	UserID           string `json:"-"`
+	ServiceAccountID string `json:"-"`
+}
+
+// Response
+
+type GetServiceAccountsResponse struct {
+	ServiceAccounts []ServiceAccount `json:"serviceAccounts"`
+}
@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Timestamp is the time type used in every DTO. It always serializes as
+// RFC3339Nano in UTC, regardless of the server's time zone.
+type Timestamp time.Time
+
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp(t.UTC())
+}
+
+func (t Timestamp) Time() time.Time {
+	return time.Time(t).UTC()
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Time().Format(time.RFC3339Nano))
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return err
+	}
+	*t = NewTimestamp(parsed)
+	return nil
+}
@@ -0,0 +1,145 @@
+package dto
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inZone runs the rest of the test with the process in a non-UTC zone.
+func inZone(t *testing.T) {
+	t.Helper()
+	local := time.Local
+	time.Local = time.FixedZone("UTC-7", -7*60*60)
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestTimestampJSON(t *testing.T) {
+	inZone(t)
+	tests := []struct {
+		name string
+		time time.Time
+		want string
+	}{
+		{name: "local", time: time.Date(2026, time.October, 14, 5, 30, 0, 0, time.Local), want: `"2026-10-14T12:30:00Z"`},
+		{name: "offset", time: time.Date(2026, time.October, 14, 14, 30, 0, 0, time.FixedZone("CEST", 2*60*60)), want: `"2026-10-14T12:30:00Z"`},
+		{name: "nanoseconds", time: time.Date(2026, time.October, 14, 12, 30, 0, 123456789, time.UTC), want: `"2026-10-14T12:30:00.123456789Z"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(NewTimestamp(tt.time))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+
+			var got Timestamp
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !got.Time().Equal(tt.time) || got.Time().Location() != time.UTC {
+				t.Errorf("round trip = %v, want %v in UTC", got.Time(), tt.time)
+			}
+		})
+	}
+
+	var got Timestamp
+	if err := json.Unmarshal([]byte(`"2026-10-14T14:30:00+02:00"`), &got); err != nil {
+		t.Fatalf("unmarshal with an offset: %v", err)
+	}
+	if want := `"2026-10-14T12:30:00Z"`; mustMarshal(t, got) != want {
+		t.Errorf("offset input re-serializes as %s, want %s", mustMarshal(t, got), want)
+	}
+	if err := json.Unmarshal([]byte(`"14/10/2026"`), &got); err == nil {
+		t.Errorf("unmarshal of a non-RFC3339 time succeeded, want an error")
+	}
+}
+
+func TestTimestampOrdering(t *testing.T) {
+	inZone(t)
+	// Instructions read back in different zones still order by instant.
+	base := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+	times := []Timestamp{
+		NewTimestamp(base.Add(2 * time.Hour).In(time.FixedZone("UTC-10", -10*60*60))),
+		NewTimestamp(base.In(time.FixedZone("UTC+14", 14*60*60))),
+		NewTimestamp(base.Add(time.Hour).In(time.Local)),
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Time().Before(times[j].Time())
+	})
+	want := []string{`"2026-10-14T12:00:00Z"`, `"2026-10-14T13:00:00Z"`, `"2026-10-14T14:00:00Z"`}
+	for i, ts := range times {
+		if got := mustMarshal(t, ts); got != want[i] {
+			t.Errorf("time %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data)
+}
+
+// TestNoRawTimeFields flags DTO fields typed time.Time, which would
+// serialize in the server's zone; use Timestamp instead.
+func TestNoRawTimeFields(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		parsed, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatalf("parse %s: %v", file, err)
+		}
+		ast.Inspect(parsed, func(n ast.Node) bool {
+			st, ok := n.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, field := range st.Fields.List {
+				if !isTimeType(field.Type) {
+					continue
+				}
+				for _, name := range field.Names {
+					t.Errorf("%s: field %s is a time.Time; use dto.Timestamp", fset.Position(field.Pos()), name.Name)
+				}
+				if len(field.Names) == 0 {
+					t.Errorf("%s: embedded time.Time; use dto.Timestamp", fset.Position(field.Pos()))
+				}
+			}
+			return true
+		})
+	}
+}
+
+func isTimeType(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return isTimeType(e.X)
+	case *ast.ArrayType:
+		return isTimeType(e.Elt)
+	case *ast.MapType:
+		return isTimeType(e.Value)
+	case *ast.SelectorExpr:
+		pkg, ok := e.X.(*ast.Ident)
+		return ok && pkg.Name == "time" && e.Sel.Name == "Time"
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package dto
+
+import (
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+)
+
+// View is a saved camera position on a board. A view either stores a point
+// (X, Y and optional Zoom), a bounding box (X, Y, Width, Height), or follows
+// a frame element by FrameID.
+type View struct {
+	ID        uuid.UUID `json:"id"`
+	BoardID   uuid.UUID `json:"boardId"`
+	Name      string    `json:"name"`
+	X         *float64  `json:"x,omitempty"`
+	Y         *float64  `json:"y,omitempty"`
+	Zoom      *float64  `json:"zoom,omitempty"`
+	Width     *float64  `json:"width,omitempty"`
+	Height    *float64  `json:"height,omitempty"`
+	FrameID   *string   `json:"frameId,omitempty"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt Timestamp `json:"createdAt"`
+}
+
+// NavigateEvent is broadcast to ask clients to move their camera. Clients
+// decide locally whether to follow.
+type NavigateEvent struct {
+	ViewID   uuid.UUID           `json:"viewId"`
+	Name     string              `json:"name"`
+	Viewport whiteboard.Viewport `json:"viewport"`
+}
+
+// Request
+
+type GetViewsRequest struct {
+	BoardID string `json:"-"`
+	UserID  string `json:"-"`
+}
+
+type SaveViewRequest struct {
+	BoardID string   `json:"-"`
+	ViewID  string   `json:"-"`
+	UserID  string   `json:"-"`
+	Name    string   `json:"name" binding:"required"`
+	X       *float64 `json:"x,omitempty"`
+	Y       *float64 `json:"y,omitempty"`
+	Zoom    *float64 `json:"zoom,omitempty"`
+	Width   *float64 `json:"width,omitempty"`
+	Height  *float64 `json:"height,omitempty"`
+	FrameID *string  `json:"frameId,omitempty"`
+}
+
+type ViewRequest struct {
+	BoardID    string `json:"-"`
+	ViewID     string `json:"-"`
+	UserID     string `json:"-"`
+	MutationID string `json:"-"`
+}
+
+// Response
+
+type GetViewsResponse struct {
+	Views []View `json:"views"`
+}
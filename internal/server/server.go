@@ -32,7 +32,9 @@ func NewServer(ctx context.Context, app *app.App) (*Server, error) {
 	}
 
 	// register routes here
-	http.RegisterRoutes(engine, authKeys, app )
+	if err := http.RegisterRoutes(engine, authKeys, app); err != nil {
+		return nil, fmt.Errorf("failed to register routes: %w", err)
+	}
 
 	srv := &httpSrv.Server{
 		Addr:    fmt.Sprintf(":%d", app.Config.Server.Port),
@@ -71,6 +73,12 @@ func (s *Server) gracefulShutdown(done chan bool) {
 
 	s.App.Log.Info(s.ctx, "Shutting down gracefully, press Ctrl+C again to force")
 	defer s.App.DB.Close()
+	// Closed after the sessions, which may still be reporting timings.
+	defer s.App.TimingWriter.Close()
+	defer s.App.Sessions.Close()
+	defer s.App.Service.Close()
+	defer s.App.PurgeWorker.Close()
+	defer s.App.RollupWorker.Close()
 	stop()
 
 	timeout := time.Duration(s.App.Config.Server.GracefulShutdownSec) * time.Second
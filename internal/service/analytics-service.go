@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/whiteboard"
+)
+
+const (
+	// defaultAnalyticsDays is the range reported when no dates are given.
+	defaultAnalyticsDays = 7
+	// maxAnalyticsDays caps the range of one report.
+	maxAnalyticsDays = 366
+	// topFailurePatterns is how many failing patterns are reported.
+	topFailurePatterns = 20
+	// samplesPerPattern is how many raw instructions accompany a pattern.
+	samplesPerPattern = 3
+	// maxSampledInstructions caps the failed instructions scanned for samples.
+	maxSampledInstructions = 5000
+	// defaultSlowInstructions and maxSlowInstructions bound the slow
+	// instruction report.
+	defaultSlowInstructions = 50
+	maxSlowInstructions     = 500
+)
+
+// AnalyticsService reports what users ask for and where it fails, from the
+// daily rollups kept by the rollup worker, and which recent instructions
+// were slowest.
+type AnalyticsService interface {
+	GetIntentAnalytics(ctx context.Context, req dto.IntentAnalyticsRequest) (*dto.IntentAnalyticsResponse, error)
+	GetSlowInstructions(ctx context.Context, req dto.SlowInstructionsRequest) (*dto.SlowInstructionsResponse, error)
+}
+
+type analyticsService struct {
+	queries *repo.Queries
+	config  *config.AppConfig
+}
+
+func NewAnalyticsService(queries *repo.Queries, config *config.AppConfig) AnalyticsService {
+	return &analyticsService{
+		queries: queries,
+		config:  config,
+	}
+}
+
+func (s *analyticsService) GetIntentAnalytics(ctx context.Context, req dto.IntentAnalyticsRequest) (*dto.IntentAnalyticsResponse, error) {
+	if req.Org != "" {
+		return nil, fmt.Errorf("%w: boards don't belong to organizations, so there is nothing to filter by org", ErrInvalidInput)
+	}
+	if req.IncludeSamples && !s.config.Retention.AllowAnalyticsSamples {
+		return nil, fmt.Errorf("%w: the retention policy doesn't allow instruction samples", ErrInvalidInput)
+	}
+	from, to, err := analyticsRange(req.From, req.To, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	rollups, err := s.queries.GetIntentRollups(ctx, repo.GetIntentRollupsParams{
+		FromDay: from,
+		ToDay:   to,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get intent rollups: %w", err)
+	}
+	patterns, err := s.queries.GetTopFailurePatterns(ctx, repo.GetTopFailurePatternsParams{
+		FromDay:     from,
+		ToDay:       to,
+		MaxPatterns: topFailurePatterns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failure patterns: %w", err)
+	}
+
+	resp := &dto.IntentAnalyticsResponse{
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		Intents:  intentStats(rollups),
+		Patterns: make([]dto.FailurePattern, 0, len(patterns)),
+	}
+	for _, pattern := range patterns {
+		resp.Patterns = append(resp.Patterns, dto.FailurePattern{
+			Intent:  pattern.Intent,
+			Pattern: pattern.Pattern,
+			Count:   pattern.Count,
+		})
+	}
+
+	if req.IncludeSamples && len(resp.Patterns) > 0 {
+		if err := s.addSamples(ctx, resp.Patterns, from, to.AddDate(0, 0, 1)); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// GetSlowInstructions lists the slowest instructions since req.Since that
+// took at least the threshold, with their stage breakdown.
+func (s *analyticsService) GetSlowInstructions(ctx context.Context, req dto.SlowInstructionsRequest) (*dto.SlowInstructionsResponse, error) {
+	since := time.Now().UTC().Add(-24 * time.Hour)
+	if req.Since != "" {
+		parsed, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			return nil, fmt.Errorf("%w: since must be an RFC 3339 time", ErrInvalidInput)
+		}
+		since = parsed
+	}
+	threshold := s.config.SLO.LatencyTargetMs
+	if req.ThresholdMs != nil {
+		if *req.ThresholdMs < 0 {
+			return nil, fmt.Errorf("%w: threshold_ms must not be negative", ErrInvalidInput)
+		}
+		threshold = *req.ThresholdMs
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSlowInstructions
+	}
+	limit = min(limit, maxSlowInstructions)
+
+	timings, err := s.queries.GetSlowInstructionTimings(ctx, repo.GetSlowInstructionTimingsParams{
+		Since:       since,
+		ThresholdMs: int32(threshold),
+		MaxRows:     int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instruction timings: %w", err)
+	}
+
+	resp := &dto.SlowInstructionsResponse{
+		Since:        dto.NewTimestamp(since),
+		ThresholdMs:  threshold,
+		Instructions: make([]dto.InstructionTiming, 0, len(timings)),
+	}
+	for _, timing := range timings {
+		resp.Instructions = append(resp.Instructions, dto.InstructionTiming{
+			RequestID:   timing.RequestID,
+			BoardID:     timing.BoardID,
+			UserID:      timing.UserID,
+			Provider:    timing.Provider,
+			Model:       timing.Model,
+			Failed:      timing.Failed,
+			QueueMs:     timing.QueueMs,
+			FirstByteMs: timing.FirstByteMs,
+			LLMMs:       timing.LlmMs,
+			ValidateMs:  timing.ValidateMs,
+			BroadcastMs: timing.BroadcastMs,
+			TotalMs:     timing.TotalMs,
+			CreatedAt:   dto.NewTimestamp(timing.CreatedAt),
+		})
+	}
+	return resp, nil
+}
+
+// addSamples attaches failed instructions that contain each pattern. Only
+// instructions still within the retention window have text to sample.
+func (s *analyticsService) addSamples(ctx context.Context, patterns []dto.FailurePattern, since time.Time, until time.Time) error {
+	failed, err := s.queries.GetFailedInstructionsBetween(ctx, repo.GetFailedInstructionsBetweenParams{
+		Since:   since,
+		Until:   until,
+		MaxRows: maxSampledInstructions,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get failed instructions: %w", err)
+	}
+
+	for _, row := range failed {
+		normalized := " " + strings.Join(whiteboard.NormalizeInstruction(row.Instruction), " ") + " "
+		for i := range patterns {
+			pattern := &patterns[i]
+			if pattern.Intent != row.Intent || len(pattern.Samples) >= samplesPerPattern {
+				continue
+			}
+			if strings.Contains(normalized, " "+pattern.Pattern+" ") {
+				pattern.Samples = append(pattern.Samples, row.Instruction)
+			}
+		}
+	}
+	return nil
+}
+
+// analyticsRange parses the inclusive YYYY-MM-DD range, defaulting to the
+// last week.
+func analyticsRange(fromParam string, toParam string, now time.Time) (time.Time, time.Time, error) {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if toParam != "" {
+		parsed, err := time.Parse(time.DateOnly, toParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be a YYYY-MM-DD date", ErrInvalidInput)
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(defaultAnalyticsDays - 1))
+	if fromParam != "" {
+		parsed, err := time.Parse(time.DateOnly, fromParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be a YYYY-MM-DD date", ErrInvalidInput)
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from is after to", ErrInvalidInput)
+	}
+	if to.Sub(from) >= maxAnalyticsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: the range may span at most %d days", ErrInvalidInput, maxAnalyticsDays)
+	}
+	return from, to, nil
+}
+
+// intentStats folds the per-outcome, per-provider rollups into one entry per
+// intent, most requested first.
+func intentStats(rollups []repo.GetIntentRollupsRow) []dto.IntentStats {
+	byIntent := make(map[string]*dto.IntentStats)
+	latencySum := make(map[string]int64)
+	for _, rollup := range rollups {
+		stats, ok := byIntent[rollup.Intent]
+		if !ok {
+			stats = &dto.IntentStats{
+				Intent:    rollup.Intent,
+				Outcomes:  make(map[string]int64),
+				Providers: make(map[string]int64),
+			}
+			byIntent[rollup.Intent] = stats
+		}
+		stats.Total += rollup.Count
+		stats.Outcomes[rollup.Outcome] += rollup.Count
+		if rollup.Provider != "" {
+			stats.Providers[rollup.Provider] += rollup.Count
+		}
+		stats.MaxLatencyMs = max(stats.MaxLatencyMs, rollup.LatencyMsMax)
+		latencySum[rollup.Intent] += rollup.LatencyMsSum
+	}
+
+	resp := make([]dto.IntentStats, 0, len(byIntent))
+	for intent, stats := range byIntent {
+		if stats.Total > 0 {
+			stats.FailureRate = float64(stats.Total-stats.Outcomes[OutcomeSuccess]) / float64(stats.Total)
+			stats.AvgLatencyMs = float64(latencySum[intent]) / float64(stats.Total)
+		}
+		resp = append(resp, *stats)
+	}
+	sort.Slice(resp, func(i, j int) bool {
+		if resp[i].Total != resp[j].Total {
+			return resp[i].Total > resp[j].Total
+		}
+		return resp[i].Intent < resp[j].Intent
+	})
+	return resp
+}
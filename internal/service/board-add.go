@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+)
+
+// addElements adds the elements build returns for the board's elements to
+// the board, logs the addition as an instruction, so it can be undone like
+// one, and sends it to the board's live session. It returns the elements
+// added, with the IDs the server gave them.
+func (s *boardService) addElements(ctx context.Context, boardID, userID, instruction string, build func(board []llm.Element) ([]llm.Element, error)) ([]llm.Element, error) {
+	id, err := uuid.Parse(boardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := repo.New(s.encrypted.WithTx(tx))
+
+	board, err = queries.GetBoardForUpdate(ctx, board.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+	var elements []llm.Element
+	if len(board.Elements) > 0 {
+		if err := json.Unmarshal(board.Elements, &elements); err != nil {
+			return nil, fmt.Errorf("failed to parse board elements: %w", err)
+		}
+	}
+
+	added, err := build(elements)
+	if err != nil {
+		return nil, err
+	}
+	action := &llm.WhiteboardAction{
+		Action:   llm.ActionAdd,
+		Elements: added,
+	}
+	whiteboard.AssignElementIDs(action, elements, uuid.NewString())
+	applied, err := whiteboard.ApplyAction(elements, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply elements: %w", err)
+	}
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode elements: %w", err)
+	}
+	if _, err := queries.UpdateBoardElements(ctx, repo.UpdateBoardElementsParams{
+		ID:       board.ID,
+		Elements: data,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to update board: %w", err)
+	}
+
+	actionJSON, err := json.Marshal(action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode action: %w", err)
+	}
+	var inverseJSON *string
+	if inverse, err := whiteboard.Invert(action, elements); err == nil {
+		if data, err := json.Marshal(inverse); err == nil {
+			encoded := string(data)
+			inverseJSON = &encoded
+		}
+	}
+	if _, err := queries.CreateInstruction(ctx, repo.CreateInstructionParams{
+		BoardID:       board.ID,
+		UserID:        userID,
+		Instruction:   instruction,
+		Intent:        whiteboard.IntentAdd,
+		Outcome:       OutcomeSuccess,
+		InverseAction: inverseJSON,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record instruction: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.sessions.Publish(board.ID.String(), livekit.StreamTextData{
+		Type: "canvas_update",
+		Data: &llm.LLMResponse{
+			Response:  string(actionJSON),
+			Timestamp: time.Now().UTC(),
+		},
+	})
+	return action.Elements, nil
+}
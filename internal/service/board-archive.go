@@ -0,0 +1,26 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"draw/internal/db/repo"
+)
+
+// archivedTokenTTL is how long a spectator token for an archived board is
+// valid, the same as a regular board token.
+const archivedTokenTTL = time.Hour
+
+// ErrBoardArchived is returned for changes to an archived board. Archived
+// boards stay readable and exportable but are read-only, for their owner
+// too, until they are unarchived. Clients match on the code at the start of
+// the message.
+var ErrBoardArchived = fmt.Errorf("board_archived: the board is archived and read-only until it is unarchived: %w", ErrConflict)
+
+// checkNotArchived refuses changes to archived boards.
+func checkNotArchived(board repo.Board) error {
+	if board.ArchivedAt != nil {
+		return ErrBoardArchived
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/storage"
+	"draw/pkg/whiteboard/templates"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestArchivedBoardIsReadOnly runs every mutation on a board while it is
+// archived, then again after it is unarchived. Mutations on an archived
+// board must be refused before they write anything; once unarchived they
+// must get past the check, to fail only on whatever the fake database
+// doesn't keep.
+func TestArchivedBoardIsReadOnly(t *testing.T) {
+	ctx := context.Background()
+	archivedAt := time.Now()
+	board := repo.Board{ID: uuid.New(), OwnerID: "u1", Elements: []byte(`[]`), ArchivedAt: &archivedAt}
+	comment := repo.BoardComment{ID: uuid.New(), BoardID: board.ID, AuthorID: "u1", Text: "note"}
+
+	fake := newFakeDB(board)
+	fake.comments[comment.ID] = comment
+	queries := repo.New(fake)
+	// Nothing listens on the pool's address, so transactions fail to begin
+	// rather than reaching a database.
+	pool, err := pgxpool.New(ctx, "postgres://draw@127.0.0.1:1/draw?connect_timeout=1")
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	defer pool.Close()
+	cfg := &config.AppConfig{
+		Board:  config.BoardConfig{ImageMaxBytes: 1 << 20},
+		Speech: config.SpeechConfig{MaxUtteranceSec: 30},
+	}
+	sessions := livekit.NewSessionManager(cfg, nil, nil, nil, nil, nil, nil, nil)
+	defer sessions.Close()
+
+	comments := &commentService{queries: queries, db: pool, config: cfg, sessions: sessions}
+	boards := &boardService{queries: queries, db: pool, config: cfg, sessions: sessions, comments: comments}
+	images := &imageService{queries: queries, db: pool, config: cfg, storage: storage.NewS3(config.AWSConfig{Bucket: "boards", Region: "us-east-1"})}
+	views := &viewService{queries: queries, db: pool, config: cfg, sessions: sessions}
+	pending := &pendingChangeService{queries: queries, db: pool, config: cfg, sessions: sessions, comments: comments}
+
+	boardID := board.ID.String()
+	otherID := uuid.NewString()
+	x, y := 0.0, 0.0
+	text := "edited"
+	mutations := []struct {
+		name   string
+		mutate func() error
+	}{
+		{"UpdateBoard", func() error {
+			_, err := boards.UpdateBoard(ctx, dto.UpdateBoardRequest{BoardID: boardID, UserID: "u1", Name: "Renamed"})
+			return err
+		}},
+		{"ApplyPartial", func() error {
+			return boards.ApplyPartial(ctx, dto.ApplyPartialRequest{BoardID: boardID, UserID: "u1", Token: "t"})
+		}},
+		{"ConfirmAction", func() error {
+			return boards.ConfirmAction(ctx, dto.ConfirmActionRequest{BoardID: boardID, UserID: "u1", Token: "t"})
+		}},
+		{"UndoLast", func() error {
+			return boards.UndoLast(ctx, dto.UndoRequest{BoardID: boardID, UserID: "u1"})
+		}},
+		{"StreamSpeech", func() error {
+			_, err := boards.StreamSpeech(ctx, dto.StreamSpeechRequest{BoardID: boardID, UserID: "u1", Audio: strings.NewReader("")})
+			return err
+		}},
+		{"InstantiateTemplate", func() error {
+			_, err := boards.InstantiateTemplate(ctx, dto.InstantiateTemplateRequest{BoardID: boardID, UserID: "u1", Name: templates.Names()[0]})
+			return err
+		}},
+		{"ImportMermaid", func() error {
+			_, err := boards.ImportMermaid(ctx, dto.ImportMermaidRequest{BoardID: boardID, UserID: "u1", Source: "graph TD\n  A --> B"})
+			return err
+		}},
+		{"CreateComment", func() error {
+			_, err := comments.CreateComment(ctx, dto.CreateCommentRequest{BoardID: boardID, UserID: "u1", Text: "hello"})
+			return err
+		}},
+		{"UpdateComment", func() error {
+			_, err := comments.UpdateComment(ctx, dto.UpdateCommentRequest{BoardID: boardID, CommentID: comment.ID.String(), UserID: "u1", Text: &text})
+			return err
+		}},
+		{"DeleteComment", func() error {
+			return comments.DeleteComment(ctx, dto.DeleteCommentRequest{BoardID: boardID, CommentID: otherID, UserID: "u1"})
+		}},
+		{"CreateImage", func() error {
+			_, err := images.CreateImage(ctx, dto.CreateImageRequest{BoardID: boardID, UserID: "u1", MimeType: "image/png", Size: 100})
+			return err
+		}},
+		{"CreateView", func() error {
+			_, err := views.CreateView(ctx, dto.SaveViewRequest{BoardID: boardID, UserID: "u1", Name: "Overview", X: &x, Y: &y})
+			return err
+		}},
+		{"UpdateView", func() error {
+			_, err := views.UpdateView(ctx, dto.SaveViewRequest{BoardID: boardID, ViewID: otherID, UserID: "u1", Name: "Overview", X: &x, Y: &y})
+			return err
+		}},
+		{"DeleteView", func() error {
+			return views.DeleteView(ctx, dto.ViewRequest{BoardID: boardID, ViewID: otherID, UserID: "u1"})
+		}},
+		{"ApprovePendingChange", func() error {
+			_, err := pending.ApprovePendingChange(ctx, dto.DecidePendingChangeRequest{BoardID: boardID, ChangeID: otherID, UserID: "u1"})
+			return err
+		}},
+		{"RejectPendingChange", func() error {
+			_, err := pending.RejectPendingChange(ctx, dto.DecidePendingChangeRequest{BoardID: boardID, ChangeID: otherID, UserID: "u1"})
+			return err
+		}},
+		// Deleting comes last, as it succeeds once the board is unarchived.
+		{"DeleteBoard", func() error {
+			return boards.DeleteBoard(ctx, dto.DeleteBoardRequest{BoardID: boardID, UserID: "u1"})
+		}},
+	}
+
+	for _, archived := range []bool{true, false} {
+		state := "archived"
+		if !archived {
+			state = "unarchived"
+			resp, err := boards.UnarchiveBoard(ctx, dto.ArchiveBoardRequest{BoardID: boardID, UserID: "u1"})
+			if err != nil {
+				t.Fatalf("UnarchiveBoard: %v", err)
+			}
+			if resp.Archived {
+				t.Fatalf("board is still archived after unarchiving")
+			}
+		}
+		for _, tt := range mutations {
+			t.Run(state+"/"+tt.name, func(t *testing.T) {
+				fake.ran = nil
+				err := tt.mutate()
+				if !archived {
+					if errors.Is(err, ErrBoardArchived) {
+						t.Errorf("got %v after unarchiving, want the mutation let through", err)
+					}
+					return
+				}
+				if !errors.Is(err, ErrBoardArchived) || !errors.Is(err, ErrConflict) {
+					t.Fatalf("got %v, want ErrBoardArchived", err)
+				}
+				for _, query := range fake.ran {
+					if query != "GetBoardByID" {
+						t.Errorf("ran %s on an archived board", query)
+					}
+				}
+			})
+		}
+	}
+
+	if _, ok := fake.boards[board.ID]; ok {
+		t.Errorf("the board wasn't deleted once unarchived")
+	}
+}
+
+func TestCheckNotArchived(t *testing.T) {
+	archivedAt := time.Now()
+	if err := checkNotArchived(repo.Board{}); err != nil {
+		t.Errorf("board never archived: got %v, want nil", err)
+	}
+	err := checkNotArchived(repo.Board{ArchivedAt: &archivedAt})
+	if !errors.Is(err, ErrBoardArchived) {
+		t.Fatalf("archived board: got %v, want ErrBoardArchived", err)
+	}
+	if !strings.HasPrefix(err.Error(), "board_archived: ") {
+		t.Errorf("got %q, want it to start with the board_archived code", err)
+	}
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/bundle"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+	"draw/pkg/whiteboard/render"
+
+	"github.com/google/uuid"
+)
+
+// Formats ExportBoard writes boards in.
+const (
+	ExportFormatBundle     = "bundle"
+	ExportFormatMermaid    = "mermaid"
+	ExportFormatExcalidraw = "excalidraw"
+	ExportFormatSVG        = "svg"
+)
+
+// ExportBoard writes the board, its settings and its comments as a bundle.
+// The other formats hold only its elements: ExportFormatMermaid writes its
+// flowchart as Mermaid text, ExportFormatExcalidraw a file the Excalidraw
+// app opens and ExportFormatSVG a picture of it.
+func (s *boardService) ExportBoard(ctx context.Context, req dto.ExportBoardRequest, w io.Writer) (*dto.ExportBoardResponse, error) {
+	switch req.Format {
+	case "", ExportFormatBundle, ExportFormatMermaid, ExportFormatExcalidraw, ExportFormatSVG:
+	default:
+		return nil, fmt.Errorf("%w: format must be %s, %s, %s or %s", ErrInvalidInput, ExportFormatBundle, ExportFormatMermaid, ExportFormatExcalidraw, ExportFormatSVG)
+	}
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+
+	elements := []llm.Element{}
+	if board.Elements != nil {
+		if err := json.Unmarshal(board.Elements, &elements); err != nil {
+			return nil, fmt.Errorf("failed to parse board elements: %w", err)
+		}
+	}
+
+	switch req.Format {
+	case ExportFormatMermaid:
+		chart, err := whiteboard.ToMermaid(elements)
+		if errors.Is(err, whiteboard.ErrNoFlowchart) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to export flowchart: %w", err)
+		}
+		if _, err := io.WriteString(w, chart); err != nil {
+			return nil, err
+		}
+		return &dto.ExportBoardResponse{ContentType: "text/plain; charset=utf-8"}, nil
+
+	case ExportFormatExcalidraw:
+		file, err := whiteboard.ToExcalidrawFile(elements, board.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export excalidraw file: %w", err)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file); err != nil {
+			return nil, fmt.Errorf("failed to write excalidraw file: %w", err)
+		}
+		return &dto.ExportBoardResponse{
+			ContentType: "application/json",
+			Filename:    exportFilename(board.Name, ".excalidraw"),
+		}, nil
+
+	case ExportFormatSVG:
+		svg, err := render.SVG(elements)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render board: %w", err)
+		}
+		if _, err := w.Write(svg); err != nil {
+			return nil, err
+		}
+		return &dto.ExportBoardResponse{ContentType: "image/svg+xml"}, nil
+	}
+
+	comments, err := s.queries.GetCommentsByBoardID(ctx, board.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	bundleComments := make([]bundle.Comment, 0, len(comments))
+	for _, comment := range comments {
+		bundleComments = append(bundleComments, bundle.Comment{
+			ElementID: comment.ElementID,
+			AuthorID:  comment.AuthorID,
+			Text:      comment.Text,
+			Resolved:  comment.Resolved,
+			Orphaned:  comment.Orphaned,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+
+	err = bundle.Write(w, &bundle.Bundle{
+		Board: bundle.Board{
+			Name:     board.Name,
+			Elements: elements,
+		},
+		Settings: &bundle.Settings{
+			Icon:        board.Icon,
+			Color:       board.Color,
+			Protected:   board.Protected,
+			Timezone:    board.Timezone,
+			Locale:      board.Locale,
+			ArrowRepair: board.ArrowRepair,
+		},
+		Comments: bundleComments,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ExportBoardResponse{
+		ContentType: "application/zip",
+		Filename:    "board-" + board.ID.String() + ".zip",
+	}, nil
+}
+
+// ImportBoard recreates a bundled board for the caller. Elements get new IDs
+// with references between them preserved, and comments follow their
+// elements. Comments are authored by the caller: the bundle's author IDs
+// name users of the board it came from, who aren't on the new one. Parts and
+// settings this server can't take are skipped and reported rather than
+// failing the import.
+func (s *boardService) ImportBoard(ctx context.Context, req dto.ImportBoardRequest) (*dto.ImportBoardResponse, error) {
+	b, err := bundle.Read(req.Bundle)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	b.Board.Name = strings.TrimSpace(b.Board.Name)
+	if b.Board.Name == "" {
+		return nil, fmt.Errorf("%w: bundled board has no name", ErrInvalidInput)
+	}
+	if err := whiteboard.ValidateBoardName(b.Board.Name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	skipped := append([]string{}, b.Skipped...)
+	ids := whiteboard.RemapElementIDs(b.Board.Elements)
+	elements, err := json.Marshal(b.Board.Elements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode board elements: %w", err)
+	}
+
+	params := repo.UpdateBoardParams{
+		Name:        b.Board.Name,
+		Elements:    elements,
+		OwnerID:     req.UserID,
+		ArrowRepair: whiteboard.ArrowRepairUnbind,
+	}
+	if settings := b.Settings; settings != nil {
+		params.Protected = settings.Protected
+		if err := s.validateBoardMetadata(settings.Icon, nil); err != nil {
+			skipped = append(skipped, "icon: "+err.Error())
+		} else {
+			params.Icon = emptyToNil(settings.Icon)
+		}
+		if err := s.validateBoardMetadata(nil, settings.Color); err != nil {
+			skipped = append(skipped, "color: "+err.Error())
+		} else {
+			params.Color = emptyToNil(settings.Color)
+		}
+		if err := validateBoardLocale(settings.Timezone, nil); err != nil {
+			skipped = append(skipped, "timezone: "+err.Error())
+		} else {
+			params.Timezone = emptyToNil(settings.Timezone)
+		}
+		if err := validateBoardLocale(nil, settings.Locale); err != nil {
+			skipped = append(skipped, "locale: "+err.Error())
+		} else {
+			params.Locale = emptyToNil(settings.Locale)
+		}
+		switch {
+		case settings.ArrowRepair == "":
+		case whiteboard.ValidArrowRepair(settings.ArrowRepair):
+			params.ArrowRepair = settings.ArrowRepair
+		default:
+			skipped = append(skipped, fmt.Sprintf("arrowRepair: unknown policy %q", settings.ArrowRepair))
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := repo.New(s.encrypted.WithTx(tx))
+
+	created, err := queries.CreateBoard(ctx, repo.CreateBoardParams{
+		Name:    params.Name,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create board: %w", err)
+	}
+	params.ID = created.ID
+	if _, err := queries.UpdateBoard(ctx, params); err != nil {
+		return nil, fmt.Errorf("failed to update board: %w", err)
+	}
+
+	for _, comment := range b.Comments {
+		elementID := comment.ElementID
+		orphaned := comment.Orphaned
+		if elementID != nil {
+			if newID, ok := ids[*elementID]; ok {
+				elementID = &newID
+			} else {
+				// The element's old ID means nothing on the new board.
+				elementID = nil
+				orphaned = true
+			}
+		}
+		createdAt := comment.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now().UTC()
+		}
+		if _, err := queries.ImportComment(ctx, repo.ImportCommentParams{
+			BoardID:   created.ID,
+			ElementID: elementID,
+			AuthorID:  req.UserID,
+			Text:      comment.Text,
+			Resolved:  comment.Resolved,
+			Orphaned:  orphaned,
+			CreatedAt: createdAt,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to import comment: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	return &dto.ImportBoardResponse{
+		BoardID: created.ID,
+		Skipped: skipped,
+	}, nil
+}
+
+// exportFilename turns a board name into a file name with ext, dropping
+// characters file systems and Content-Disposition headers don't take.
+func exportFilename(name string, ext string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	cleaned = strings.Trim(strings.TrimSpace(cleaned), ".")
+	if cleaned == "" {
+		cleaned = "board"
+	}
+	return cleaned + ext
+}
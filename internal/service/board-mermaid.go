@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"draw/internal/dto"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+	"draw/pkg/whiteboard/mermaid"
+)
+
+// ImportMermaid converts a Mermaid flowchart into elements and adds them
+// below what is already on the board. The import is logged as an
+// instruction, so it can be undone like one.
+func (s *boardService) ImportMermaid(ctx context.Context, req dto.ImportMermaidRequest) (*dto.ImportMermaidResponse, error) {
+	flowchart, err := mermaid.ParseFlowchart(req.Source)
+	if errors.Is(err, mermaid.ErrInvalidFlowchart) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flowchart: %w", err)
+	}
+
+	elements, err := s.addElements(ctx, req.BoardID, req.UserID, "import mermaid flowchart", func(board []llm.Element) ([]llm.Element, error) {
+		return whiteboard.PlaceBelow(flowchart, board), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	added, err := json.Marshal(elements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode elements: %w", err)
+	}
+	return &dto.ImportMermaidResponse{Elements: added}, nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"draw/internal/dto"
+)
+
+func TestImportMermaidInvalidInput(t *testing.T) {
+	s := &boardService{}
+	tests := []struct {
+		name    string
+		boardID string
+		source  string
+	}{
+		{name: "malformed board id", boardID: "not-a-uuid", source: "graph TD\n  A --> B"},
+		{name: "empty board id", boardID: "", source: "flowchart LR\n  A[Start] --> B[End]"},
+		{name: "not a flowchart", boardID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", source: "sequenceDiagram\n  A->>B: hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ImportMermaid(context.Background(), dto.ImportMermaidRequest{
+				BoardID: tt.boardID,
+				UserID:  "u1",
+				Source:  tt.source,
+			})
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("got %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
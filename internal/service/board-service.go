@@ -3,47 +3,108 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
 
+	"draw/internal/db/encrypted"
 	"draw/internal/db/repo"
 	"draw/internal/dto"
 	"draw/pkg/config"
 	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/llmdebug"
+	"draw/pkg/whiteboard"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// defaultBoardName names boards created without one.
+const defaultBoardName = "Untitled board"
+
+// ErrNothingToUndo is returned by UndoLast when every instruction that can be
+// undone has been.
+var ErrNothingToUndo = fmt.Errorf("%w: nothing to undo", ErrNotFound)
+
 type BoardService interface {
 	CreateBoard(ctx context.Context, req dto.CreateBoardRequest) (*dto.CreateBoardResponse, error)
 	GetBoard(ctx context.Context, req dto.GetBoardRequest) (*dto.GetBoardResponse, error)
 	GetBoardsByUserID(ctx context.Context, req dto.GetBoardsByUserIDRequest) (*dto.GetBoardsByUserIDResponse, error)
 	UpdateBoard(ctx context.Context, req dto.UpdateBoardRequest) (*dto.GetBoardResponse, error)
 	DeleteBoard(ctx context.Context, req dto.DeleteBoardRequest) error
+	ArchiveBoard(ctx context.Context, req dto.ArchiveBoardRequest) (*dto.Board, error)
+	UnarchiveBoard(ctx context.Context, req dto.ArchiveBoardRequest) (*dto.Board, error)
+	ApplyPartial(ctx context.Context, req dto.ApplyPartialRequest) error
+	ConfirmAction(ctx context.Context, req dto.ConfirmActionRequest) error
+	UndoLast(ctx context.Context, req dto.UndoRequest) error
+	StreamSpeech(ctx context.Context, req dto.StreamSpeechRequest) (*livekit.AudioUpload, error)
+	ExportBoard(ctx context.Context, req dto.ExportBoardRequest, w io.Writer) (*dto.ExportBoardResponse, error)
+	ImportBoard(ctx context.Context, req dto.ImportBoardRequest) (*dto.ImportBoardResponse, error)
+	ImportMermaid(ctx context.Context, req dto.ImportMermaidRequest) (*dto.ImportMermaidResponse, error)
+	InstantiateTemplate(ctx context.Context, req dto.InstantiateTemplateRequest) (*dto.InstantiateTemplateResponse, error)
 }
 
 type boardService struct {
-	queries *repo.Queries
-	db      *pgxpool.Pool
-	config  *config.AppConfig
+	queries        *repo.Queries
+	db             *pgxpool.Pool
+	encrypted      *encrypted.DB
+	config         *config.AppConfig
+	sessions       *livekit.SessionManager
+	instructions   InstructionService
+	pendingChanges PendingChangeService
+	comments       CommentService
+	views          ViewService
+	history        *llmdebug.History
 }
 
 func NewBoardService(
 	db *pgxpool.Pool,
 	queries *repo.Queries,
+	encryptedDB *encrypted.DB,
 	config *config.AppConfig,
+	sessions *livekit.SessionManager,
+	instructions InstructionService,
+	pendingChanges PendingChangeService,
+	comments CommentService,
+	views ViewService,
+	history *llmdebug.History,
 ) BoardService {
 	return &boardService{
-		db:      db,
-		queries: queries,
-		config:  config,
+		db:             db,
+		queries:        queries,
+		encrypted:      encryptedDB,
+		config:         config,
+		sessions:       sessions,
+		instructions:   instructions,
+		pendingChanges: pendingChanges,
+		comments:       comments,
+		views:          views,
+		history:        history,
 	}
 }
 
 func (s *boardService) CreateBoard(ctx context.Context, req dto.CreateBoardRequest) (*dto.CreateBoardResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultBoardName
+	}
+	if err := whiteboard.ValidateBoardName(name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if err := s.validateBoardMetadata(req.Icon, req.Color); err != nil {
+		return nil, err
+	}
+
 	board, err := s.queries.CreateBoard(ctx, repo.CreateBoardParams{
-		Name:    req.Name,
+		Name:    name,
 		OwnerID: req.UserID,
+		Icon:    emptyToNil(req.Icon),
+		Color:   emptyToNil(req.Color),
 	})
 
 	if err != nil {
@@ -51,7 +112,9 @@ func (s *boardService) CreateBoard(ctx context.Context, req dto.CreateBoardReque
 	}
 
 	return &dto.CreateBoardResponse{
-		BoardID: board.ID,
+		BoardID:   board.ID,
+		Name:      board.Name,
+		CreatedAt: dto.NewTimestamp(board.CreatedAt),
 	}, nil
 }
 
@@ -63,16 +126,18 @@ func (s *boardService) GetBoard(ctx context.Context, req dto.GetBoardRequest) (*
 	if err != nil {
 		return nil, fmt.Errorf("failed to get board: %w", err)
 	}
+	if board.ArchivedAt != nil {
+		return s.getArchivedBoard(board, req)
+	}
 
 	userDetails, err := s.queries.GetUserByID(ctx, req.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	session, err := livekit.NewLiveKitSession(
+	session, err := s.sessions.GetOrCreate(
 		&userDetails,
 		board.ID.String(),
-		s.config,
 		livekit.SessionCallbacks{
 			GetBoardState: func(boardID string, userID string) (json.RawMessage, error) {
 				fmt.Println("Getting board state for board ID", boardID, "and user ID", userID)
@@ -85,27 +150,146 @@ func (s *boardService) GetBoard(ctx context.Context, req dto.GetBoardRequest) (*
 				}
 				return board.Elements, nil
 			},
+			OnLLMResponse: func(boardID string, userID string, instruction string, response *llm.LLMResponse, err error) {
+				if recordErr := s.instructions.RecordInstruction(context.Background(), boardID, userID, instruction, response, err); recordErr != nil {
+					fmt.Println("Failed to record instruction for board ID", boardID, recordErr)
+				}
+			},
+			HoldForApproval: func(boardID string, userID string, instruction string, response *llm.LLMResponse) (any, error) {
+				pending, err := s.pendingChanges.HoldIfProtected(context.Background(), boardID, userID, instruction, response)
+				if err != nil || pending == nil {
+					return nil, err
+				}
+				return pending, nil
+			},
+			GetBoardLocale: func(boardID string) (string, string, error) {
+				settings, err := s.queries.GetBoardLocale(context.Background(), uuid.MustParse(boardID))
+				if err != nil {
+					return "", "", fmt.Errorf("failed to get board locale: %w", err)
+				}
+				var timezone, locale string
+				if settings.Timezone != nil {
+					timezone = *settings.Timezone
+				}
+				if settings.Locale != nil {
+					locale = *settings.Locale
+				}
+				return timezone, locale, nil
+			},
+			GetArrowRepair: func(boardID string) (string, error) {
+				return s.queries.GetBoardArrowRepair(context.Background(), uuid.MustParse(boardID))
+			},
+			OnComment: func(boardID string, userID string, intent whiteboard.CommentIntent) error {
+				_, err := s.comments.CreateComment(context.Background(), dto.CreateCommentRequest{
+					BoardID:   boardID,
+					UserID:    userID,
+					ElementID: intent.ElementID,
+					Text:      intent.Text,
+				})
+				return err
+			},
+			OnNavigate: func(boardID string, userID string, phrase string) (bool, error) {
+				return s.views.NavigateByName(context.Background(), boardID, userID, phrase)
+			},
+			OnInstructionTiming: func(boardID string, userID string, timing livekit.InstructionTiming) {
+				s.instructions.RecordTiming(boardID, userID, timing)
+			},
+			OnInstructionState: func(boardID string, userID string, requestID string, instruction string, state string) {
+				if err := s.instructions.TrackIntent(context.Background(), boardID, userID, requestID, instruction, state); err != nil {
+					fmt.Println("Failed to track instruction for board ID", boardID, err)
+				}
+			},
+			OnBoardReset: func(boardID string, action *llm.WhiteboardAction) error {
+				return s.resetBoard(context.Background(), uuid.MustParse(boardID), action)
+			},
+			OnUndo: func(boardID string, userID string) error {
+				return s.UndoLast(context.Background(), dto.UndoRequest{
+					BoardID: boardID,
+					UserID:  userID,
+				})
+			},
+			OnBoardMetadata: func(boardID string, userID string, intent whiteboard.BoardMetadataIntent) error {
+				_, err := s.UpdateBoard(context.Background(), dto.UpdateBoardRequest{
+					BoardID: boardID,
+					UserID:  userID,
+					Icon:    intent.Icon,
+					Color:   intent.Color,
+				})
+				return err
+			},
 		},
 	)
-
-	if err := session.Start(); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("failed to start session: %w", err)
 	}
 
-	token, err := session.GenerateUserToken()
+	identity, name, role := userDetails.Name, userDetails.Name, livekit.RoleSpeaker
+	// The board owner can moderate the room; service accounts acting for
+	// them can't.
+	admin := userDetails.ID == board.OwnerID
+	if req.ServiceAccountID != "" {
+		identity = session.JoinServiceAccount(&userDetails, req.ServiceAccountID)
+		name = req.ServiceAccount
+		admin = false
+		// Speaking to the board gives instructions, so accounts without the
+		// scope for them may only listen.
+		if !slices.Contains(req.ServiceAccountScopes, ScopeInstructions) {
+			role = livekit.RoleListener
+		}
+	}
+	token, err := session.GenerateUserToken(identity, name, role, admin)
 	if err != nil {
-		session.Stop()
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
+	s.resumeInstructions(ctx, board.ID, session)
+
+	return &dto.GetBoardResponse{
+		Board: toBoardResponse(board),
+		Token: token,
+	}, nil
+}
+
+// getArchivedBoard opens an archived board read-only: no voice session is
+// started, and the token only lets the client watch the room.
+func (s *boardService) getArchivedBoard(board repo.Board, req dto.GetBoardRequest) (*dto.GetBoardResponse, error) {
+	identity := "archive-" + req.UserID
+	if req.ServiceAccountID != "" {
+		identity = "archive-" + livekit.ServiceAccountIdentity(req.ServiceAccountID)
+	}
+	token, err := s.sessions.SpectatorToken(board.ID.String(), identity, archivedTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
 	return &dto.GetBoardResponse{
 		Board: toBoardResponse(board),
 		Token: token,
 	}, nil
 }
 
+// resumeInstructions re-runs the instructions a restart cut off on this
+// board. Their outcome is broadcast under the original request ID, so a
+// reconnected client can match it to what it sent.
+func (s *boardService) resumeInstructions(ctx context.Context, boardID uuid.UUID, session *livekit.LiveKitSession) {
+	intents, err := s.instructions.ClaimInterrupted(ctx, boardID)
+	if err != nil {
+		fmt.Println("Failed to resume instructions for board ID", boardID, err)
+		return
+	}
+	for _, intent := range intents {
+		if !session.ResumeInstruction(intent.RequestID, intent.Instruction) {
+			if err := s.instructions.TrackIntent(ctx, boardID.String(), intent.UserID, intent.RequestID, intent.Instruction, livekit.InstructionFailed); err != nil {
+				fmt.Println("Failed to settle instruction for board ID", boardID, err)
+			}
+		}
+	}
+}
+
 func (s *boardService) GetBoardsByUserID(ctx context.Context, req dto.GetBoardsByUserIDRequest) (*dto.GetBoardsByUserIDResponse, error) {
-	boards, err := s.queries.GetBoardsByUserID(ctx, req.UserID)
+	boards, err := s.queries.GetBoardsByUserID(ctx, repo.GetBoardsByUserIDParams{
+		OwnerID:         req.UserID,
+		IncludeArchived: req.IncludeArchived,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get boards: %w", err)
 	}
@@ -119,6 +303,22 @@ func (s *boardService) GetBoardsByUserID(ctx context.Context, req dto.GetBoardsB
 }
 
 func (s *boardService) UpdateBoard(ctx context.Context, req dto.UpdateBoardRequest) (*dto.GetBoardResponse, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name != "" {
+		if err := whiteboard.ValidateBoardName(req.Name); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+	if err := s.validateBoardMetadata(req.Icon, req.Color); err != nil {
+		return nil, err
+	}
+	if err := validateBoardLocale(req.Timezone, req.Locale); err != nil {
+		return nil, err
+	}
+	if req.ArrowRepair != nil && !whiteboard.ValidArrowRepair(*req.ArrowRepair) {
+		return nil, fmt.Errorf("%w: arrowRepair must be %s or %s", ErrInvalidInput, whiteboard.ArrowRepairUnbind, whiteboard.ArrowRepairCascade)
+	}
+
 	currentBoard, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
 		ID:      uuid.MustParse(req.BoardID),
 		OwnerID: req.UserID,
@@ -126,6 +326,9 @@ func (s *boardService) UpdateBoard(ctx context.Context, req dto.UpdateBoardReque
 	if err != nil {
 		return nil, fmt.Errorf("failed to get board: %w", err)
 	}
+	if err := checkNotArchived(currentBoard); err != nil {
+		return nil, err
+	}
 
 	if req.Name != "" {
 		currentBoard.Name = req.Name
@@ -133,38 +336,467 @@ func (s *boardService) UpdateBoard(ctx context.Context, req dto.UpdateBoardReque
 	if req.Elements != nil {
 		currentBoard.Elements = req.Elements
 	}
+	if req.Icon != nil {
+		currentBoard.Icon = emptyToNil(req.Icon)
+	}
+	if req.Color != nil {
+		currentBoard.Color = emptyToNil(req.Color)
+	}
+	if req.Protected != nil {
+		currentBoard.Protected = *req.Protected
+	}
+	if req.Timezone != nil {
+		currentBoard.Timezone = emptyToNil(req.Timezone)
+	}
+	if req.Locale != nil {
+		currentBoard.Locale = emptyToNil(req.Locale)
+	}
+	if req.ArrowRepair != nil {
+		currentBoard.ArrowRepair = *req.ArrowRepair
+	}
+
+	var repair whiteboard.ArrowRepair
+	if req.Elements != nil {
+		currentBoard.Elements, repair = repairBoardArrows(currentBoard.Elements, currentBoard.ArrowRepair)
+	}
 
 	board, err := s.queries.UpdateBoard(ctx, repo.UpdateBoardParams{
-		ID:       currentBoard.ID,
-		Name:     currentBoard.Name,
-		Elements: currentBoard.Elements,
-		OwnerID:  req.UserID,
+		ID:          currentBoard.ID,
+		Name:        currentBoard.Name,
+		Elements:    currentBoard.Elements,
+		OwnerID:     req.UserID,
+		Icon:        currentBoard.Icon,
+		Color:       currentBoard.Color,
+		Protected:   currentBoard.Protected,
+		Timezone:    currentBoard.Timezone,
+		Locale:      currentBoard.Locale,
+		ArrowRepair: currentBoard.ArrowRepair,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to update board: %w", err)
 	}
 
+	if req.Elements != nil {
+		if err := s.comments.OrphanComments(ctx, board.ID, board.Elements); err != nil {
+			fmt.Println("Failed to orphan comments for board ID", board.ID, err)
+		}
+	}
+	if len(repair.Deleted) > 0 {
+		// The saving client still shows the arrows the repair removed.
+		s.sessions.Publish(board.ID.String(), livekit.StreamTextData{
+			Type:       "canvas_update",
+			MutationID: req.MutationID,
+			Data: &llm.LLMResponse{
+				Response:  deleteActionJSON(repair.Deleted),
+				Timestamp: time.Now().UTC(),
+				Warning:   repair.Warning(),
+			},
+		})
+	}
+
 	return &dto.GetBoardResponse{
 		Board: toBoardResponse(board),
 	}, nil
 }
 
+// resetBoard applies a clear, replace or reorder action to the stored board
+// in one transaction, so a concurrent save cannot interleave with it.
+func (s *boardService) resetBoard(ctx context.Context, boardID uuid.UUID, action *llm.WhiteboardAction) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := repo.New(s.encrypted.WithTx(tx))
+
+	board, err := queries.GetBoardForUpdate(ctx, boardID)
+	if err != nil {
+		return fmt.Errorf("failed to get board: %w", err)
+	}
+	if err := checkNotArchived(board); err != nil {
+		return err
+	}
+	var elements []llm.Element
+	if len(board.Elements) > 0 {
+		if err := json.Unmarshal(board.Elements, &elements); err != nil {
+			return fmt.Errorf("failed to parse board elements: %w", err)
+		}
+	}
+	applied, err := whiteboard.ApplyAction(elements, action)
+	if err != nil {
+		return fmt.Errorf("failed to apply action: %w", err)
+	}
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return fmt.Errorf("failed to encode elements: %w", err)
+	}
+	board, err = queries.UpdateBoardElements(ctx, repo.UpdateBoardElementsParams{
+		ID:       boardID,
+		Elements: data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update board: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if err := s.comments.OrphanComments(ctx, board.ID, board.Elements); err != nil {
+		fmt.Println("Failed to orphan comments for board ID", board.ID, err)
+	}
+	return nil
+}
+
 func (s *boardService) DeleteBoard(ctx context.Context, req dto.DeleteBoardRequest) error {
-	err := s.queries.DeleteBoard(ctx, repo.DeleteBoardParams{
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
 		ID:      uuid.MustParse(req.BoardID),
 		OwnerID: req.UserID,
 	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Deleting a board that is already gone succeeds, as it always has.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get board: %w", err)
+	}
+	if err := checkNotArchived(board); err != nil {
+		return err
+	}
+
+	err = s.queries.DeleteBoard(ctx, repo.DeleteBoardParams{
+		ID:      board.ID,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return legalHoldError("delete board", err)
+	}
+	s.history.Clear(board.ID.String())
+	return nil
+}
+
+// ArchiveBoard makes the board read-only and hides it from the default board
+// list. Its room is closed, disconnecting everyone on it; they can reopen the
+// board to view it. Archiving an archived board changes nothing.
+func (s *boardService) ArchiveBoard(ctx context.Context, req dto.ArchiveBoardRequest) (*dto.Board, error) {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.ArchiveBoard(ctx, repo.ArchiveBoardParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("board %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive board: %w", err)
+	}
+
+	if err := s.sessions.Release(board.ID.String()); err != nil {
+		fmt.Println("Failed to release room for archived board ID", board.ID, err)
+	}
+
+	resp := toBoardResponse(board)
+	return &resp, nil
+}
+
+// UnarchiveBoard makes an archived board editable again. Its instructions,
+// comments and views were kept, so it carries on where it left off.
+func (s *boardService) UnarchiveBoard(ctx context.Context, req dto.ArchiveBoardRequest) (*dto.Board, error) {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.UnarchiveBoard(ctx, repo.UnarchiveBoardParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("board %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unarchive board: %w", err)
+	}
+	resp := toBoardResponse(board)
+	return &resp, nil
+}
+
+// ApplyPartial applies the valid elements of a failed instruction, as long as
+// the board hasn't changed since the instruction ran.
+func (s *boardService) ApplyPartial(ctx context.Context, req dto.ApplyPartialRequest) error {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get board: %w", err)
+	}
+	if err := checkNotArchived(board); err != nil {
+		return err
+	}
+
+	elements := board.Elements
+	if elements == nil {
+		elements = json.RawMessage("[]")
+	}
+
+	err = s.sessions.ApplyPartial(board.ID.String(), req.Token, elements)
+	switch {
+	case errors.Is(err, livekit.ErrPartialNotFound):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case errors.Is(err, livekit.ErrPartialStale):
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	case err != nil:
+		return fmt.Errorf("failed to apply partial action: %w", err)
+	}
+	return nil
+}
+
+// ConfirmAction applies a voice action held because it deletes or rewrites
+// too much of the board, as long as the board hasn't changed since.
+func (s *boardService) ConfirmAction(ctx context.Context, req dto.ConfirmActionRequest) error {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete board: %w", err)
+		return fmt.Errorf("failed to get board: %w", err)
+	}
+	if err := checkNotArchived(board); err != nil {
+		return err
+	}
+
+	elements := board.Elements
+	if elements == nil {
+		elements = json.RawMessage("[]")
+	}
+
+	err = s.sessions.ConfirmAction(board.ID.String(), req.Token, elements)
+	switch {
+	case errors.Is(err, livekit.ErrConfirmationNotFound):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case errors.Is(err, livekit.ErrConfirmationStale):
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	case err != nil:
+		return fmt.Errorf("failed to confirm action: %w", err)
 	}
 	return nil
 }
 
+// UndoLast undoes the board's most recent instruction that hasn't been undone
+// yet: its inverse is applied to the stored board and broadcast, and the
+// undo is recorded as an instruction of its own.
+func (s *boardService) UndoLast(ctx context.Context, req dto.UndoRequest) error {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get board: %w", err)
+	}
+	if err := checkNotArchived(board); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := repo.New(s.encrypted.WithTx(tx))
+
+	board, err = queries.GetBoardForUpdate(ctx, board.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get board: %w", err)
+	}
+	instruction, err := queries.GetLastUndoableInstruction(ctx, board.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNothingToUndo
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get instruction to undo: %w", err)
+	}
+	var inverse llm.WhiteboardAction
+	if err := json.Unmarshal([]byte(*instruction.InverseAction), &inverse); err != nil {
+		return fmt.Errorf("failed to parse inverse action: %w", err)
+	}
+
+	var elements []llm.Element
+	if len(board.Elements) > 0 {
+		if err := json.Unmarshal(board.Elements, &elements); err != nil {
+			return fmt.Errorf("failed to parse board elements: %w", err)
+		}
+	}
+	applied, err := whiteboard.ApplyAction(elements, &inverse)
+	if err != nil {
+		return fmt.Errorf("failed to apply inverse action: %w", err)
+	}
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return fmt.Errorf("failed to encode elements: %w", err)
+	}
+	board, err = queries.UpdateBoardElements(ctx, repo.UpdateBoardElementsParams{
+		ID:       board.ID,
+		Elements: data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update board: %w", err)
+	}
+	if err := queries.MarkInstructionUndone(ctx, instruction.ID); err != nil {
+		return fmt.Errorf("failed to mark instruction undone: %w", err)
+	}
+	if _, err := queries.CreateInstruction(ctx, repo.CreateInstructionParams{
+		BoardID:     board.ID,
+		UserID:      req.UserID,
+		Instruction: "undo",
+		Intent:      whiteboard.IntentUndo,
+		Outcome:     OutcomeSuccess,
+	}); err != nil {
+		return fmt.Errorf("failed to record undo: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if err := s.comments.OrphanComments(ctx, board.ID, board.Elements); err != nil {
+		fmt.Println("Failed to orphan comments for board ID", board.ID, err)
+	}
+	s.sessions.Publish(board.ID.String(), livekit.StreamTextData{
+		Type: "canvas_update",
+		Data: &llm.LLMResponse{
+			Response:  *instruction.InverseAction,
+			Timestamp: time.Now().UTC(),
+		},
+	})
+	return nil
+}
+
+// StreamSpeech transcribes uploaded audio while it streams in, running each
+// transcription as an instruction on the board's session.
+func (s *boardService) StreamSpeech(ctx context.Context, req dto.StreamSpeechRequest) (*livekit.AudioUpload, error) {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+
+	maxDuration := time.Duration(s.config.Speech.MaxUtteranceSec) * time.Second
+	var quota livekit.Quota
+	if req.ServiceAccountID != "" {
+		quota = livekit.ServiceAccountQuota(req.ServiceAccountID)
+	}
+	upload, err := s.sessions.StreamAudio(ctx, board.ID.String(), req.Audio, maxDuration, quota)
+	switch {
+	case errors.Is(err, livekit.ErrNoVoiceSession):
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+	case errors.Is(err, livekit.ErrUtteranceTooLong):
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	case err != nil:
+		return nil, classifyDependencyError("speech", fmt.Errorf("failed to stream speech: %w", err))
+	}
+	return upload, nil
+}
+
+// validateBoardMetadata checks icon and color; nil and empty values (which
+// leave or clear the field) are always accepted.
+func (s *boardService) validateBoardMetadata(icon *string, color *string) error {
+	if icon != nil && *icon != "" {
+		if err := whiteboard.ValidateBoardIcon(*icon); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+	if color != nil && *color != "" {
+		if err := whiteboard.ValidateBoardColor(*color, s.config.Board.AllowCustomColors); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+	return nil
+}
+
+// validateBoardLocale checks the time zone and locale; nil and empty values
+// are always accepted.
+func validateBoardLocale(timezone *string, locale *string) error {
+	if timezone != nil && *timezone != "" {
+		if err := whiteboard.ValidateTimezone(*timezone); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+	if locale != nil && *locale != "" {
+		if err := whiteboard.ValidateLocale(*locale); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+	return nil
+}
+
+// repairBoardArrows applies the board's arrow repair policy to saved
+// elements. Elements that don't parse are stored as they are.
+func repairBoardArrows(data json.RawMessage, policy string) (json.RawMessage, whiteboard.ArrowRepair) {
+	var elements []llm.Element
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return data, whiteboard.ArrowRepair{}
+	}
+	repaired, repair := whiteboard.RepairArrows(elements, policy)
+	if repair.Empty() {
+		return data, repair
+	}
+	repairedData, err := json.Marshal(repaired)
+	if err != nil {
+		return data, whiteboard.ArrowRepair{}
+	}
+	return repairedData, repair
+}
+
+func deleteActionJSON(ids []string) string {
+	data, _ := json.Marshal(llm.WhiteboardAction{
+		Action:    llm.ActionDelete,
+		DeleteIDs: ids,
+	})
+	return string(data)
+}
+
+func emptyToNil(value *string) *string {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return value
+}
+
 func toBoardResponse(board repo.Board) dto.Board {
 	return dto.Board{
-		ID:       board.ID,
-		Name:     board.Name,
-		OwnerID:  board.OwnerID,
-		Elements: board.Elements,
+		ID:          board.ID,
+		Name:        board.Name,
+		OwnerID:     board.OwnerID,
+		Elements:    board.Elements,
+		Icon:        board.Icon,
+		Color:       board.Color,
+		Protected:   board.Protected,
+		Timezone:    board.Timezone,
+		Locale:      board.Locale,
+		ArrowRepair: board.ArrowRepair,
+		LegalHold:   board.LegalHoldAt != nil,
+		Archived:    board.ArchivedAt != nil,
 	}
 }
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"draw/internal/dto"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+	"draw/pkg/whiteboard/templates"
+)
+
+// InstantiateTemplate adds the template called req.Name to the board, at
+// req.Origin or below what is already there. Its labels are fitted and its
+// arrows routed as the model's would be. It is logged as an instruction, so
+// it can be undone like one.
+func (s *boardService) InstantiateTemplate(ctx context.Context, req dto.InstantiateTemplateRequest) (*dto.InstantiateTemplateResponse, error) {
+	if _, ok := templates.Lookup(req.Name); !ok {
+		return nil, fmt.Errorf("%w: template %q", ErrNotFound, req.Name)
+	}
+
+	elements, err := s.addElements(ctx, req.BoardID, req.UserID, "insert "+req.Name+" template", func(board []llm.Element) ([]llm.Element, error) {
+		action, err := whiteboard.ExpandTemplate(&llm.WhiteboardAction{
+			Action: llm.ActionTemplate,
+			Name:   req.Name,
+			Origin: req.Origin,
+			Params: req.Params,
+		}, board)
+		if errors.Is(err, templates.ErrUnknownParam) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand template: %w", err)
+		}
+		action = whiteboard.FitLabels(action, board)
+		action = whiteboard.RouteArrows(action, board)
+		return action.Elements, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	added, err := json.Marshal(elements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode elements: %w", err)
+	}
+	return &dto.InstantiateTemplateResponse{Elements: added}, nil
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"draw/internal/dto"
+)
+
+func TestInstantiateTemplateInvalidBoardID(t *testing.T) {
+	s := &boardService{}
+	for _, boardID := range []string{"", "not-a-uuid", "1234"} {
+		_, err := s.InstantiateTemplate(context.Background(), dto.InstantiateTemplateRequest{
+			BoardID: boardID,
+			UserID:  "u1",
+			Name:    "flowchart",
+		})
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("board %q: got %v, want ErrInvalidInput", boardID, err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	commentCreated  = "created"
+	commentUpdated  = "updated"
+	commentDeleted  = "deleted"
+	commentOrphaned = "orphaned"
+)
+
+// ErrCommentNotFound is returned when a comment doesn't exist on the board.
+var ErrCommentNotFound = fmt.Errorf("comment %w", ErrNotFound)
+
+// CommentService manages notes left on a board or on one of its elements.
+// Every change is broadcast to the board's clients.
+type CommentService interface {
+	GetComments(ctx context.Context, req dto.GetCommentsRequest) (*dto.GetCommentsResponse, error)
+	CreateComment(ctx context.Context, req dto.CreateCommentRequest) (*dto.Comment, error)
+	UpdateComment(ctx context.Context, req dto.UpdateCommentRequest) (*dto.Comment, error)
+	DeleteComment(ctx context.Context, req dto.DeleteCommentRequest) error
+	OrphanComments(ctx context.Context, boardID uuid.UUID, elements json.RawMessage) error
+}
+
+type commentService struct {
+	queries  *repo.Queries
+	db       *pgxpool.Pool
+	config   *config.AppConfig
+	sessions *livekit.SessionManager
+}
+
+func NewCommentService(
+	db *pgxpool.Pool,
+	queries *repo.Queries,
+	config *config.AppConfig,
+	sessions *livekit.SessionManager,
+) CommentService {
+	return &commentService{
+		db:       db,
+		queries:  queries,
+		config:   config,
+		sessions: sessions,
+	}
+}
+
+func (s *commentService) GetComments(ctx context.Context, req dto.GetCommentsRequest) (*dto.GetCommentsResponse, error) {
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := s.queries.GetCommentsByBoardID(ctx, board.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	resp := make([]dto.Comment, 0, len(comments))
+	for _, comment := range comments {
+		resp = append(resp, toCommentResponse(comment))
+	}
+	return &dto.GetCommentsResponse{
+		Comments: resp,
+	}, nil
+}
+
+func (s *commentService) CreateComment(ctx context.Context, req dto.CreateCommentRequest) (*dto.Comment, error) {
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return nil, fmt.Errorf("%w: comment text is empty", ErrInvalidInput)
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+	if req.ElementID != nil && !boardHasElement(board.Elements, *req.ElementID) {
+		return nil, fmt.Errorf("%w: element %q is not on the board", ErrInvalidInput, *req.ElementID)
+	}
+
+	comment, err := s.queries.CreateComment(ctx, repo.CreateCommentParams{
+		BoardID:   board.ID,
+		ElementID: emptyToNil(req.ElementID),
+		AuthorID:  req.UserID,
+		Text:      text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+
+	resp := toCommentResponse(comment)
+	s.publish(board.ID, commentCreated, resp, req.MutationID)
+	return &resp, nil
+}
+
+func (s *commentService) UpdateComment(ctx context.Context, req dto.UpdateCommentRequest) (*dto.Comment, error) {
+	commentID, err := uuid.Parse(req.CommentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid comment id", ErrInvalidInput)
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+
+	current, err := s.queries.GetCommentByID(ctx, repo.GetCommentByIDParams{
+		ID:      commentID,
+		BoardID: board.ID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+
+	if req.Text != nil {
+		current.Text = strings.TrimSpace(*req.Text)
+		if current.Text == "" {
+			return nil, fmt.Errorf("%w: comment text is empty", ErrInvalidInput)
+		}
+	}
+	if req.Resolved != nil {
+		current.Resolved = *req.Resolved
+	}
+
+	comment, err := s.queries.UpdateComment(ctx, repo.UpdateCommentParams{
+		ID:       current.ID,
+		BoardID:  board.ID,
+		Text:     current.Text,
+		Resolved: current.Resolved,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	resp := toCommentResponse(comment)
+	s.publish(board.ID, commentUpdated, resp, req.MutationID)
+	return &resp, nil
+}
+
+func (s *commentService) DeleteComment(ctx context.Context, req dto.DeleteCommentRequest) error {
+	commentID, err := uuid.Parse(req.CommentID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid comment id", ErrInvalidInput)
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotArchived(board); err != nil {
+		return err
+	}
+
+	comment, err := s.queries.GetCommentByID(ctx, repo.GetCommentByIDParams{
+		ID:      commentID,
+		BoardID: board.ID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get comment: %w", err)
+	}
+	if board.LegalHoldAt != nil {
+		return ErrLegalHold
+	}
+
+	rows, err := s.queries.DeleteComment(ctx, repo.DeleteCommentParams{
+		ID:      commentID,
+		BoardID: board.ID,
+	})
+	if err != nil {
+		return legalHoldError("delete comment", err)
+	}
+	if rows == 0 {
+		return ErrCommentNotFound
+	}
+
+	s.publish(board.ID, commentDeleted, toCommentResponse(comment), req.MutationID)
+	return nil
+}
+
+// OrphanComments flags comments whose element is no longer in elements.
+// They stay visible so the discussion isn't lost with the shape.
+func (s *commentService) OrphanComments(ctx context.Context, boardID uuid.UUID, elements json.RawMessage) error {
+	var board []llm.Element
+	if err := json.Unmarshal(elements, &board); err != nil {
+		return fmt.Errorf("failed to parse board elements: %w", err)
+	}
+	ids := make([]string, 0, len(board))
+	for _, element := range board {
+		if element.ID != "" {
+			ids = append(ids, element.ID)
+		}
+	}
+
+	comments, err := s.queries.OrphanComments(ctx, repo.OrphanCommentsParams{
+		BoardID:    boardID,
+		ElementIds: ids,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to orphan comments: %w", err)
+	}
+	for _, comment := range comments {
+		s.publish(boardID, commentOrphaned, toCommentResponse(comment), "")
+	}
+	return nil
+}
+
+// getBoard loads the board, which also checks that the user owns it.
+func (s *commentService) getBoard(ctx context.Context, boardID string, userID string) (repo.Board, error) {
+	id, err := uuid.Parse(boardID)
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: userID,
+	})
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("failed to get board: %w", err)
+	}
+	return board, nil
+}
+
+func (s *commentService) publish(boardID uuid.UUID, action string, comment dto.Comment, mutationID string) {
+	s.sessions.Publish(boardID.String(), livekit.StreamTextData{
+		Type: "comment",
+		Data: dto.CommentEvent{
+			Action:  action,
+			Comment: comment,
+		},
+		MutationID: mutationID,
+	})
+}
+
+func boardHasElement(elements json.RawMessage, elementID string) bool {
+	var board []llm.Element
+	if err := json.Unmarshal(elements, &board); err != nil {
+		return false
+	}
+	for _, element := range board {
+		if element.ID == elementID {
+			return true
+		}
+	}
+	return false
+}
+
+func toCommentResponse(comment repo.BoardComment) dto.Comment {
+	return dto.Comment{
+		ID:        comment.ID,
+		BoardID:   comment.BoardID,
+		ElementID: comment.ElementID,
+		AuthorID:  comment.AuthorID,
+		Text:      comment.Text,
+		Resolved:  comment.Resolved,
+		Orphaned:  comment.Orphaned,
+		CreatedAt: dto.NewTimestamp(comment.CreatedAt),
+		UpdatedAt: dto.NewTimestamp(comment.UpdatedAt),
+	}
+}
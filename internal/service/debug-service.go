@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/llmdebug"
+
+	"github.com/google/uuid"
+)
+
+// DebugService exposes what support needs to look into odd results on a
+// board.
+type DebugService interface {
+	GetLLMHistory(ctx context.Context, req dto.GetLLMHistoryRequest) (*dto.GetLLMHistoryResponse, error)
+}
+
+type debugService struct {
+	queries *repo.Queries
+	config  *config.AppConfig
+	history *llmdebug.History
+}
+
+// NewDebugService creates the service. history is nil when it is disabled.
+func NewDebugService(queries *repo.Queries, config *config.AppConfig, history *llmdebug.History) DebugService {
+	return &debugService{
+		queries: queries,
+		config:  config,
+		history: history,
+	}
+}
+
+func (s *debugService) GetLLMHistory(ctx context.Context, req dto.GetLLMHistoryRequest) (*dto.GetLLMHistoryResponse, error) {
+	if s.history == nil {
+		return nil, fmt.Errorf("%w: LLM history is disabled", ErrNotFound)
+	}
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+
+	entries := s.history.Entries(board.ID.String())
+	if entries == nil {
+		entries = []llmdebug.Entry{}
+	}
+	return &dto.GetLLMHistoryResponse{
+		Entries:   entries,
+		RawOutput: s.config.Retention.StoreRawLLMOutput,
+	}, nil
+}
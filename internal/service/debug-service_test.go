@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llmdebug"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestGetLLMHistory(t *testing.T) {
+	board := repo.Board{ID: uuid.New(), OwnerID: "u1", Elements: []byte(`[]`)}
+	history := llmdebug.NewHistory(10, time.Hour, 0, false)
+	history.RecordInstruction("s1", board.ID.String(), livekit.InstructionTrace{RequestID: "r1", At: time.Now(), Transcription: "add a box"})
+	queries := repo.New(newFakeDB(board))
+	cfg := &config.AppConfig{Retention: config.RetentionConfig{StoreRawLLMOutput: false}}
+
+	tests := []struct {
+		name    string
+		history *llmdebug.History
+		boardID string
+		userID  string
+		entries int
+		err     error
+	}{
+		{name: "owner", history: history, boardID: board.ID.String(), userID: "u1", entries: 1},
+		{name: "other user", history: history, boardID: board.ID.String(), userID: "u2", err: pgx.ErrNoRows},
+		{name: "invalid board id", history: history, boardID: "nope", userID: "u1", err: ErrInvalidInput},
+		{name: "history disabled", boardID: board.ID.String(), userID: "u1", err: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewDebugService(queries, cfg, tt.history)
+			resp, err := s.GetLLMHistory(context.Background(), dto.GetLLMHistoryRequest{BoardID: tt.boardID, UserID: tt.userID})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if len(resp.Entries) != tt.entries {
+				t.Errorf("got %d entries, want %d", len(resp.Entries), tt.entries)
+			}
+			if resp.RawOutput {
+				t.Error("RawOutput = true, want the retention policy reported")
+			}
+		})
+	}
+}
+
+func TestDeleteBoardClearsLLMHistory(t *testing.T) {
+	board := repo.Board{ID: uuid.New(), OwnerID: "u1", Elements: []byte(`[]`)}
+	history := llmdebug.NewHistory(10, time.Hour, 0, true)
+	history.RecordInstruction("s1", board.ID.String(), livekit.InstructionTrace{RequestID: "r1", At: time.Now(), Transcription: "add a box"})
+	boards := &boardService{queries: repo.New(newFakeDB(board)), history: history}
+
+	if err := boards.DeleteBoard(context.Background(), dto.DeleteBoardRequest{BoardID: board.ID.String(), UserID: "u1"}); err != nil {
+		t.Fatalf("DeleteBoard: %v", err)
+	}
+	if entries := history.Entries(board.ID.String()); len(entries) != 0 {
+		t.Errorf("history kept %d entries of the deleted board", len(entries))
+	}
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"draw/internal/db/encrypted"
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/auth"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/llm/prompts"
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// defaultDemoBoardName names demo boards created without one.
+const defaultDemoBoardName = "Demo board"
+
+// ErrDemoBoardNotFound is returned for demo boards that don't exist, have
+// expired, or belong to another demo session.
+var ErrDemoBoardNotFound = fmt.Errorf("%w: demo board not found", ErrNotFound)
+
+// DemoService serves the public demo: anonymous boards tied to a signed
+// session cookie that expire unless they are claimed after sign-up. Demo
+// boards live in their own table, so board listings, sharing and the voice
+// rooms never see them. Instructions always run on the local fallback model
+// and bypass the LLM budget; the per-board instruction cap limits them
+// instead.
+type DemoService interface {
+	CreateBoard(ctx context.Context, req dto.CreateDemoBoardRequest) (*dto.DemoBoard, error)
+	GetBoard(ctx context.Context, req dto.GetDemoBoardRequest) (*dto.DemoBoard, error)
+	UpdateBoard(ctx context.Context, req dto.UpdateDemoBoardRequest) (*dto.DemoBoard, error)
+	RunInstruction(ctx context.Context, req dto.DemoInstructionRequest) (*dto.DemoInstructionResponse, error)
+	ClaimBoard(ctx context.Context, req dto.ClaimDemoBoardRequest) (*dto.Board, error)
+	Close() error
+}
+
+type demoService struct {
+	db        *pgxpool.Pool
+	queries   *repo.Queries
+	encrypted *encrypted.DB
+	config    *config.AppConfig
+	prompts   *prompts.Registry
+	// llm is the local model every demo instruction shares, so its worker
+	// pool bounds how many run at once; llmErr says why it couldn't be
+	// created.
+	llm    llm.LLMClient
+	llmErr error
+}
+
+func NewDemoService(db *pgxpool.Pool, queries *repo.Queries, encryptedDB *encrypted.DB, config *config.AppConfig, registry *prompts.Registry) DemoService {
+	s := &demoService{
+		db:        db,
+		queries:   queries,
+		encrypted: encryptedDB,
+		config:    config,
+		prompts:   registry,
+	}
+	// The demo always runs on the local model, whatever the LLM router or
+	// budget would pick.
+	settings := llm.NewRequestSettings(&config.LLM)
+	settings.Prompts = registry
+	client, err := llm.NewOllamaLLMClient(config.LLM.FallbackHost, config.LLM.FallbackModel, settings, llm.NewWorkerPool(&config.LLM))
+	if err != nil {
+		s.llmErr = err
+	} else {
+		s.llm = client
+	}
+	return s
+}
+
+// Close stops the demo's model client once the requests using it are done.
+func (s *demoService) Close() error {
+	if s.llm == nil {
+		return nil
+	}
+	return s.llm.Close()
+}
+
+func (s *demoService) CreateBoard(ctx context.Context, req dto.CreateDemoBoardRequest) (*dto.DemoBoard, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultDemoBoardName
+	}
+
+	count, err := s.queries.CountDemoBoardsBySession(ctx, req.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count demo boards: %w", err)
+	}
+	if count >= int64(s.config.Demo.MaxBoardsPerSession) {
+		return nil, fmt.Errorf("%w: a demo session may have at most %d boards", ErrConflict, s.config.Demo.MaxBoardsPerSession)
+	}
+
+	now := time.Now().UTC()
+	board, err := s.queries.CreateDemoBoard(ctx, repo.CreateDemoBoardParams{
+		SessionID:    req.SessionID,
+		Name:         name,
+		ExpiresAt:    now.Add(s.ttl()),
+		MaxExpiresAt: now.Add(time.Duration(s.config.Demo.MaxTTLMin) * time.Minute),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create demo board: %w", err)
+	}
+	resp := s.toDemoBoardResponse(board)
+	return &resp, nil
+}
+
+func (s *demoService) GetBoard(ctx context.Context, req dto.GetDemoBoardRequest) (*dto.DemoBoard, error) {
+	board, err := s.getBoard(ctx, req.BoardID, req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	resp := s.toDemoBoardResponse(board)
+	return &resp, nil
+}
+
+func (s *demoService) UpdateBoard(ctx context.Context, req dto.UpdateDemoBoardRequest) (*dto.DemoBoard, error) {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, ErrDemoBoardNotFound
+	}
+	elements, err := s.checkElements(req.Elements)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(elements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode elements: %w", err)
+	}
+
+	board, err := s.queries.UpdateDemoBoardElements(ctx, repo.UpdateDemoBoardElementsParams{
+		Elements:  data,
+		ExpiresAt: time.Now().UTC().Add(s.ttl()),
+		ID:        id,
+		SessionID: req.SessionID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDemoBoardNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update demo board: %w", err)
+	}
+	resp := s.toDemoBoardResponse(board)
+	return &resp, nil
+}
+
+func (s *demoService) RunInstruction(ctx context.Context, req dto.DemoInstructionRequest) (*dto.DemoInstructionResponse, error) {
+	req.Instruction = strings.TrimSpace(req.Instruction)
+	if req.Instruction == "" {
+		return nil, fmt.Errorf("%w: instruction is required", ErrInvalidInput)
+	}
+	if len(req.Instruction) > maxSandboxInstructionLen {
+		return nil, fmt.Errorf("%w: instruction may be at most %d characters", ErrInvalidInput, maxSandboxInstructionLen)
+	}
+	mode, err := prompts.ParseMode(req.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	if s.llmErr != nil {
+		return nil, &RetryError{Kind: ErrUnavailable, Resource: "llm", After: time.Minute, Retryable: true, Err: s.llmErr}
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.queries.TakeDemoInstruction(ctx, repo.TakeDemoInstructionParams{
+		ID:              board.ID,
+		SessionID:       req.SessionID,
+		MaxInstructions: int32(s.config.Demo.MaxInstructions),
+	}); errors.Is(err, pgx.ErrNoRows) {
+		return nil, &RetryError{Kind: ErrThrottled, Resource: "demo", Err: fmt.Errorf("this demo board has used its %d instructions; sign up to keep going", s.config.Demo.MaxInstructions)}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to count demo instruction: %w", err)
+	}
+	board.InstructionCount++
+
+	pipeline := &livekit.Pipeline{
+		LLMClient:          s.llm,
+		MaxAttempts:        s.config.LLM.MaxAttempts,
+		PromptTokenBudget:  s.config.LLM.PromptTokenBudget,
+		BoardStateMaxBytes: s.config.LLM.BoardStateMaxBytes,
+		FastPath:           s.config.LLM.FastPath,
+		LenientValidation:  !s.config.LLM.StrictValidation,
+		NormalizeColors:    s.config.LLM.NormalizeColors,
+		AutoLayout:         s.config.LLM.AutoLayout,
+	}
+	inst := pipeline.Prepare(uuid.NewString(), req.Instruction, string(board.Elements), time.Now(), "", "", whiteboard.ArrowRepairUnbind)
+	inst.Options.Mode = mode
+	result := pipeline.Run(ctx, inst)
+
+	resp := &dto.DemoInstructionResponse{}
+	if result.Response != nil {
+		resp.Warning = result.Response.Warning
+		resp.Issues = result.Response.ValidationWarnings
+	}
+	if result.Err != nil {
+		resp.Error = result.Err.Error()
+		if n := len(result.Attempts); n > 0 {
+			resp.Issues = result.Attempts[n-1].Issues
+		}
+	}
+	if result.Action != nil {
+		var elements []llm.Element
+		if err := json.Unmarshal(board.Elements, &elements); err != nil {
+			return nil, fmt.Errorf("failed to parse demo board: %w", err)
+		}
+		applied, err := whiteboard.ApplyAction(elements, result.Action)
+		if err != nil {
+			resp.Error = fmt.Sprintf("failed to apply action: %v", err)
+		} else if len(applied) > s.config.Demo.MaxElements {
+			resp.Error = fmt.Sprintf("demo boards may hold at most %d elements", s.config.Demo.MaxElements)
+		} else {
+			data, err := json.Marshal(applied)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode elements: %w", err)
+			}
+			board, err = s.queries.UpdateDemoBoardElements(ctx, repo.UpdateDemoBoardElementsParams{
+				Elements:  data,
+				ExpiresAt: time.Now().UTC().Add(s.ttl()),
+				ID:        board.ID,
+				SessionID: req.SessionID,
+			})
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, ErrDemoBoardNotFound
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to update demo board: %w", err)
+			}
+			resp.Action = result.Action
+		}
+	}
+	resp.Board = s.toDemoBoardResponse(board)
+	return resp, nil
+}
+
+// ClaimBoard copies a demo board into a new board owned by the user and
+// deletes the demo board. The caller must still hold the demo session the
+// board was created in.
+func (s *demoService) ClaimBoard(ctx context.Context, req dto.ClaimDemoBoardRequest) (*dto.Board, error) {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, ErrDemoBoardNotFound
+	}
+	sessionID, err := auth.VerifyDemoSession([]byte(s.config.Demo.SessionSecret), req.Session)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no valid demo session", ErrInvalidInput)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := repo.New(s.encrypted.WithTx(tx))
+
+	demo, err := queries.GetDemoBoardForClaim(ctx, repo.GetDemoBoardForClaimParams{
+		ID:        id,
+		SessionID: sessionID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDemoBoardNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get demo board: %w", err)
+	}
+
+	created, err := queries.CreateBoard(ctx, repo.CreateBoardParams{
+		Name:    demo.Name,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create board: %w", err)
+	}
+	board, err := queries.UpdateBoard(ctx, repo.UpdateBoardParams{
+		ID:          created.ID,
+		Name:        created.Name,
+		Elements:    demo.Elements,
+		OwnerID:     req.UserID,
+		ArrowRepair: whiteboard.ArrowRepairUnbind,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update board: %w", err)
+	}
+	if err := queries.DeleteDemoBoard(ctx, demo.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete demo board: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit claim: %w", err)
+	}
+
+	resp := toBoardResponse(board)
+	return &resp, nil
+}
+
+func (s *demoService) getBoard(ctx context.Context, boardID string, sessionID string) (repo.DemoBoard, error) {
+	id, err := uuid.Parse(boardID)
+	if err != nil {
+		return repo.DemoBoard{}, ErrDemoBoardNotFound
+	}
+	board, err := s.queries.GetDemoBoard(ctx, repo.GetDemoBoardParams{
+		ID:        id,
+		SessionID: sessionID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return repo.DemoBoard{}, ErrDemoBoardNotFound
+	}
+	if err != nil {
+		return repo.DemoBoard{}, fmt.Errorf("failed to get demo board: %w", err)
+	}
+	return board, nil
+}
+
+// checkElements parses saved elements and enforces the demo element cap.
+func (s *demoService) checkElements(data json.RawMessage) ([]llm.Element, error) {
+	var elements []llm.Element
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("%w: elements must be an array of elements: %v", ErrInvalidInput, err)
+	}
+	if elements == nil {
+		elements = []llm.Element{}
+	}
+	if len(elements) > s.config.Demo.MaxElements {
+		return nil, fmt.Errorf("%w: demo boards may hold at most %d elements", ErrInvalidInput, s.config.Demo.MaxElements)
+	}
+	return elements, nil
+}
+
+func (s *demoService) ttl() time.Duration {
+	return time.Duration(s.config.Demo.TTLMin) * time.Minute
+}
+
+func (s *demoService) toDemoBoardResponse(board repo.DemoBoard) dto.DemoBoard {
+	left := s.config.Demo.MaxInstructions - int(board.InstructionCount)
+	if left < 0 {
+		left = 0
+	}
+	return dto.DemoBoard{
+		ID:               board.ID,
+		Name:             board.Name,
+		Elements:         board.Elements,
+		ExpiresAt:        dto.NewTimestamp(board.ExpiresAt),
+		InstructionsLeft: left,
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"draw/internal/dto"
+	"draw/pkg/config"
+)
+
+func TestDemoServiceSharesOneClient(t *testing.T) {
+	cfg := &config.AppConfig{LLM: config.LLMConfig{FallbackHost: "localhost:11434", FallbackModel: "llama3", Concurrency: 2, QueueSize: 4}}
+	s := NewDemoService(nil, nil, nil, cfg, nil).(*demoService)
+	if s.llmErr != nil {
+		t.Fatalf("NewDemoService: %v", s.llmErr)
+	}
+	client := s.llm
+	if _, err := s.RunInstruction(context.Background(), dto.DemoInstructionRequest{Instruction: " "}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("got %v, want ErrInvalidInput", err)
+	}
+	if s.llm != client {
+		t.Errorf("RunInstruction replaced the demo's client")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestDemoServiceUnavailableModel(t *testing.T) {
+	cfg := &config.AppConfig{LLM: config.LLMConfig{FallbackHost: "http://"}}
+	s := NewDemoService(nil, nil, nil, cfg, nil)
+	defer s.Close()
+
+	_, err := s.RunInstruction(context.Background(), dto.DemoInstructionRequest{Instruction: "add a box"})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	var retry *RetryError
+	if !errors.As(err, &retry) || !retry.Retryable {
+		t.Errorf("got %v, want a retryable RetryError", err)
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"draw/internal/db/repo"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// errUnhandledQuery is what fakeDB answers queries it doesn't keep data for.
+var errUnhandledQuery = errors.New("unhandled query")
+
+// fakeDB keeps boards, comments and instructions in memory and refuses to
+// delete comments and boards of held boards, as the database's legal hold
+// triggers do. It records the name of every query it runs.
+type fakeDB struct {
+	boards   map[uuid.UUID]repo.Board
+	comments map[uuid.UUID]repo.BoardComment
+	// instructions are kept newest first, as the history queries list them.
+	instructions []repo.BoardInstruction
+	intents      map[string]repo.InstructionIntent
+	ran          []string
+}
+
+func newFakeDB(boards ...repo.Board) *fakeDB {
+	f := &fakeDB{
+		boards:   make(map[uuid.UUID]repo.Board),
+		comments: make(map[uuid.UUID]repo.BoardComment),
+		intents:  make(map[string]repo.InstructionIntent),
+	}
+	for _, board := range boards {
+		f.boards[board.ID] = board
+	}
+	return f
+}
+
+func (f *fakeDB) held(boardID uuid.UUID) bool {
+	return f.boards[boardID].LegalHoldAt != nil
+}
+
+func (f *fakeDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	name := queryName(sql)
+	f.ran = append(f.ran, name)
+	switch name {
+	case "DeleteBoard":
+		id := args[0].(uuid.UUID)
+		board, ok := f.boards[id]
+		if !ok || board.OwnerID != args[1].(string) {
+			return pgconn.NewCommandTag("DELETE 0"), nil
+		}
+		if f.held(id) {
+			return pgconn.CommandTag{}, &pgconn.PgError{Code: legalHoldViolation, Message: "board is under legal hold"}
+		}
+		delete(f.boards, id)
+		return pgconn.NewCommandTag("DELETE 1"), nil
+	case "DeleteComment":
+		comment, ok := f.comments[args[0].(uuid.UUID)]
+		if !ok || comment.BoardID != args[1].(uuid.UUID) {
+			return pgconn.NewCommandTag("DELETE 0"), nil
+		}
+		if f.held(comment.BoardID) {
+			return pgconn.CommandTag{}, &pgconn.PgError{Code: legalHoldViolation, Message: "board is under legal hold"}
+		}
+		delete(f.comments, comment.ID)
+		return pgconn.NewCommandTag("DELETE 1"), nil
+	case "CreateInstructionIntent":
+		requestID := args[1].(string)
+		if _, ok := f.intents[requestID]; ok {
+			return pgconn.NewCommandTag("INSERT 0 0"), nil
+		}
+		now := time.Now()
+		f.intents[requestID] = repo.InstructionIntent{
+			RequestID:   requestID,
+			BoardID:     args[0].(uuid.UUID),
+			UserID:      args[2].(string),
+			Instruction: args[3].(string),
+			State:       "pending",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+	case "SettleInstructionIntent":
+		return updated(f.updateIntents(args[0].(string), func(intent repo.InstructionIntent) bool {
+			return intent.RequestID == args[1].(string) && intent.State != "applied" && intent.State != "failed"
+		})), nil
+	case "InterruptPendingIntents":
+		return updated(f.updateIntents("interrupted", func(intent repo.InstructionIntent) bool {
+			return intent.State == "pending" && !intent.CreatedAt.Before(args[0].(time.Time)) && intent.CreatedAt.Before(args[1].(time.Time))
+		})), nil
+	case "AbandonStaleIntents":
+		return updated(f.updateIntents("failed", func(intent repo.InstructionIntent) bool {
+			return intent.State != "applied" && intent.State != "failed" && intent.CreatedAt.Before(args[0].(time.Time))
+		})), nil
+	}
+	return pgconn.CommandTag{}, errUnhandledQuery
+}
+
+func (f *fakeDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	name := queryName(sql)
+	f.ran = append(f.ran, name)
+	switch name {
+	case "GetInstructionsByBoardID", "GetUndoableInstructionsByBoardID":
+		var rows structRows
+		for _, instruction := range f.instructions {
+			if instruction.BoardID != args[0].(uuid.UUID) {
+				continue
+			}
+			if name == "GetUndoableInstructionsByBoardID" && (instruction.InverseAction == nil || instruction.UndoneAt != nil) {
+				continue
+			}
+			rows.values = append(rows.values, instruction)
+		}
+		return &rows, nil
+	case "ClaimInterruptedIntents":
+		var rows structRows
+		for _, intent := range f.updateIntents("resuming", func(intent repo.InstructionIntent) bool {
+			return intent.BoardID == args[0].(uuid.UUID) && intent.State == "interrupted" && !intent.CreatedAt.Before(args[1].(time.Time))
+		}) {
+			rows.values = append(rows.values, intent)
+		}
+		return &rows, nil
+	}
+	return nil, errUnhandledQuery
+}
+
+func (f *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	name := queryName(sql)
+	f.ran = append(f.ran, name)
+	switch name {
+	case "GetBoardByID", "GetBoardForUpdate":
+		board, ok := f.boards[args[0].(uuid.UUID)]
+		if !ok || (name == "GetBoardByID" && board.OwnerID != args[1].(string)) {
+			return structRow{err: pgx.ErrNoRows}
+		}
+		return structRow{value: board}
+	case "ArchiveBoard", "UnarchiveBoard":
+		board, ok := f.boards[args[0].(uuid.UUID)]
+		if !ok || board.OwnerID != args[1].(string) {
+			return structRow{err: pgx.ErrNoRows}
+		}
+		if name == "UnarchiveBoard" {
+			board.ArchivedAt = nil
+		} else if board.ArchivedAt == nil {
+			now := time.Now()
+			board.ArchivedAt = &now
+		}
+		f.boards[board.ID] = board
+		return structRow{value: board}
+	case "CreateInstruction":
+		instruction := repo.BoardInstruction{
+			ID:               uuid.New(),
+			BoardID:          args[0].(uuid.UUID),
+			UserID:           args[1].(string),
+			Instruction:      args[2].(string),
+			RawResponse:      args[3].(*string),
+			Error:            args[4].(*string),
+			CreatedAt:        time.Now(),
+			Provider:         args[5].(*string),
+			PromptTokens:     args[6].(int32),
+			CompletionTokens: args[7].(int32),
+			CostUsd:          args[8].(float64),
+			Intent:           args[9].(string),
+			Outcome:          args[10].(string),
+			LatencyMs:        args[11].(int32),
+			InverseAction:    args[12].(*string),
+		}
+		f.instructions = append([]repo.BoardInstruction{instruction}, f.instructions...)
+		return structRow{value: instruction}
+	case "GetCommentByID":
+		comment, ok := f.comments[args[0].(uuid.UUID)]
+		if !ok || comment.BoardID != args[1].(uuid.UUID) {
+			return structRow{err: pgx.ErrNoRows}
+		}
+		return structRow{value: comment}
+	}
+	return structRow{err: errUnhandledQuery}
+}
+
+// updateIntents moves the intents match picks to state, returning them.
+func (f *fakeDB) updateIntents(state string, match func(intent repo.InstructionIntent) bool) []repo.InstructionIntent {
+	var changed []repo.InstructionIntent
+	for requestID, intent := range f.intents {
+		if !match(intent) {
+			continue
+		}
+		intent.State = state
+		intent.UpdatedAt = time.Now()
+		f.intents[requestID] = intent
+		changed = append(changed, intent)
+	}
+	return changed
+}
+
+func updated(intents []repo.InstructionIntent) pgconn.CommandTag {
+	return pgconn.NewCommandTag(fmt.Sprintf("UPDATE %d", len(intents)))
+}
+
+// structRow scans the fields of value in order, as sqlc's queries list
+// the columns of a table.
+type structRow struct {
+	value interface{}
+	err   error
+}
+
+func (r structRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	v := reflect.ValueOf(r.value)
+	for i := range dest {
+		reflect.ValueOf(dest[i]).Elem().Set(v.Field(i))
+	}
+	return nil
+}
+
+// structRows returns each of values as a structRow.
+type structRows struct {
+	values []interface{}
+	next   int
+}
+
+func (r *structRows) Next() bool {
+	r.next++
+	return r.next <= len(r.values)
+}
+
+func (r *structRows) Scan(dest ...interface{}) error {
+	return structRow{value: r.values[r.next-1]}.Scan(dest...)
+}
+
+func (r *structRows) Close()                                       {}
+func (r *structRows) Err() error                                   { return nil }
+func (r *structRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *structRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *structRows) Values() ([]interface{}, error)               { return nil, errUnhandledQuery }
+func (r *structRows) RawValues() [][]byte                          { return nil }
+func (r *structRows) Conn() *pgx.Conn                              { return nil }
+
+func queryName(sql string) string {
+	rest, _ := strings.CutPrefix(sql, "-- name: ")
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
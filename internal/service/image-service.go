@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrImageNotFound is returned when an image doesn't exist on the board.
+var ErrImageNotFound = fmt.Errorf("image %w", ErrNotFound)
+
+// imageMimeTypes are the image types boards accept. SVG is left out: it can
+// carry scripts, and S3 would serve it as a document.
+var imageMimeTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ImageService stores the pictures a board's image elements show. The bytes
+// go straight between clients and S3 through presigned URLs; the database
+// only records each image's metadata, under the fileId elements use.
+type ImageService interface {
+	CreateImage(ctx context.Context, req dto.CreateImageRequest) (*dto.CreateImageResponse, error)
+	GetImage(ctx context.Context, req dto.GetImageRequest) (*dto.GetImageResponse, error)
+}
+
+type imageService struct {
+	queries *repo.Queries
+	db      *pgxpool.Pool
+	config  *config.AppConfig
+	storage *storage.S3
+}
+
+func NewImageService(
+	db *pgxpool.Pool,
+	queries *repo.Queries,
+	config *config.AppConfig,
+	storage *storage.S3,
+) ImageService {
+	return &imageService{
+		db:      db,
+		queries: queries,
+		config:  config,
+		storage: storage,
+	}
+}
+
+func (s *imageService) CreateImage(ctx context.Context, req dto.CreateImageRequest) (*dto.CreateImageResponse, error) {
+	if !imageMimeTypes[req.MimeType] {
+		return nil, fmt.Errorf("%w: mimeType must be image/png, image/jpeg, image/gif or image/webp", ErrInvalidInput)
+	}
+	if req.Size <= 0 || req.Size > int64(s.config.Board.ImageMaxBytes) {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d bytes", ErrInvalidInput, s.config.Board.ImageMaxBytes)
+	}
+	if s.storage == nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, storage.ErrNotConfigured)
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+
+	fileID := uuid.New()
+	key := imageObjectKey(board.ID, fileID)
+	ttl := s.urlTTL()
+	uploadURL, headers, err := s.storage.PresignPut(ctx, key, req.MimeType, req.Size, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := s.queries.CreateImage(ctx, repo.CreateImageParams{
+		ID:        fileID,
+		BoardID:   board.ID,
+		ObjectKey: key,
+		MimeType:  req.MimeType,
+		Size:      req.Size,
+		CreatedBy: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image: %w", err)
+	}
+
+	return &dto.CreateImageResponse{
+		Image:         toImageResponse(image),
+		UploadURL:     uploadURL,
+		UploadHeaders: headers,
+		ExpiresAt:     dto.NewTimestamp(time.Now().Add(ttl)),
+	}, nil
+}
+
+func (s *imageService) GetImage(ctx context.Context, req dto.GetImageRequest) (*dto.GetImageResponse, error) {
+	fileID, err := uuid.Parse(req.FileID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid file id", ErrInvalidInput)
+	}
+	if s.storage == nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, storage.ErrNotConfigured)
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	image, err := s.queries.GetImageByID(ctx, repo.GetImageByIDParams{
+		ID:      fileID,
+		BoardID: board.ID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+
+	ttl := s.urlTTL()
+	url, err := s.storage.PresignGet(ctx, image.ObjectKey, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.GetImageResponse{
+		Image:     toImageResponse(image),
+		URL:       url,
+		ExpiresAt: dto.NewTimestamp(time.Now().Add(ttl)),
+	}, nil
+}
+
+func (s *imageService) urlTTL() time.Duration {
+	return time.Duration(s.config.Board.ImageURLTTLSec) * time.Second
+}
+
+func (s *imageService) getBoard(ctx context.Context, boardID string, userID string) (repo.Board, error) {
+	id, err := uuid.Parse(boardID)
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: userID,
+	})
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("failed to get board: %w", err)
+	}
+	return board, nil
+}
+
+// imageObjectKey keeps each board's images under their own prefix.
+func imageObjectKey(boardID uuid.UUID, fileID uuid.UUID) string {
+	return fmt.Sprintf("boards/%s/images/%s", boardID, fileID)
+}
+
+func toImageResponse(image repo.BoardImage) dto.Image {
+	return dto.Image{
+		FileID:    image.ID,
+		BoardID:   image.BoardID,
+		MimeType:  image.MimeType,
+		Size:      image.Size,
+		CreatedBy: image.CreatedBy,
+		CreatedAt: dto.NewTimestamp(image.CreatedAt),
+	}
+}
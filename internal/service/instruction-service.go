@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// instructionHistoryLimit caps how many instructions are returned per board.
+const instructionHistoryLimit = 100
+
+// Outcomes recorded with each instruction for analytics.
+const (
+	OutcomeSuccess          = "success"
+	OutcomeNotActionable    = "not_actionable"
+	OutcomeValidationFailed = "validation_failed"
+	OutcomeFiltered         = "filtered"
+	OutcomeError            = "error"
+)
+
+// TimingQueue writes instruction timing records in the background.
+type TimingQueue interface {
+	Enqueue(params repo.CreateInstructionTimingParams) bool
+}
+
+type InstructionService interface {
+	RecordInstruction(ctx context.Context, boardID string, userID string, instruction string, response *llm.LLMResponse, llmErr error) error
+	// RecordTiming queues the instruction's stage timing for the slow
+	// instruction report. It never blocks.
+	RecordTiming(boardID string, userID string, timing livekit.InstructionTiming)
+	// TrackIntent records that an instruction is about to reach the LLM, or
+	// how it settled, so one cut off by a restart can be resumed.
+	TrackIntent(ctx context.Context, boardID string, userID string, requestID string, instruction string, state string) error
+	// InterruptPending marks the instructions left pending by a previous
+	// process as interrupted, or as failed once they are too old to resume.
+	InterruptPending(ctx context.Context, startedAt time.Time) error
+	// ClaimInterrupted returns the board's interrupted instructions that may
+	// still be resumed. Each is returned to one caller only.
+	ClaimInterrupted(ctx context.Context, boardID uuid.UUID) ([]repo.InstructionIntent, error)
+	GetBoardInstructions(ctx context.Context, req dto.GetBoardInstructionsRequest) (*dto.GetBoardInstructionsResponse, error)
+	// GetBoardHistory returns the board's undoable instructions with what
+	// each changed.
+	GetBoardHistory(ctx context.Context, req dto.GetBoardHistoryRequest) (*dto.GetBoardHistoryResponse, error)
+}
+
+type instructionService struct {
+	queries *repo.Queries
+	db      *pgxpool.Pool
+	config  *config.AppConfig
+	timings TimingQueue
+}
+
+func NewInstructionService(
+	db *pgxpool.Pool,
+	queries *repo.Queries,
+	config *config.AppConfig,
+	timings TimingQueue,
+) InstructionService {
+	return &instructionService{
+		db:      db,
+		queries: queries,
+		config:  config,
+		timings: timings,
+	}
+}
+
+func (s *instructionService) RecordInstruction(ctx context.Context, boardID string, userID string, instruction string, response *llm.LLMResponse, llmErr error) error {
+	boardUUID, err := uuid.Parse(boardID)
+	if err != nil {
+		return fmt.Errorf("invalid board id: %w", err)
+	}
+
+	params := repo.CreateInstructionParams{
+		BoardID:     boardUUID,
+		UserID:      userID,
+		Instruction: instruction,
+		Intent:      whiteboard.ClassifyIntent(instruction),
+		Outcome:     instructionOutcome(response, llmErr),
+	}
+	if response != nil {
+		if s.config.Retention.StoreRawLLMOutput {
+			params.RawResponse = &response.Response
+		}
+		if response.Provider != "" {
+			params.Provider = &response.Provider
+		}
+		params.PromptTokens = int32(response.Usage.PromptTokens)
+		params.CompletionTokens = int32(response.Usage.CompletionTokens)
+		params.CostUsd = response.CostUSD
+		params.LatencyMs = int32(response.Latency.Milliseconds())
+		// Held actions aren't on the board until they are confirmed.
+		if llmErr == nil && response.Inverse != nil && !response.RequiresConfirmation {
+			inverse, err := json.Marshal(response.Inverse)
+			if err != nil {
+				return fmt.Errorf("failed to encode inverse action: %w", err)
+			}
+			inverseAction := string(inverse)
+			params.InverseAction = &inverseAction
+		}
+	}
+	if llmErr != nil {
+		errMsg := llmErr.Error()
+		params.Error = &errMsg
+
+		var failure *livekit.InstructionFailure
+		if response == nil && errors.As(llmErr, &failure) {
+			if failure.Provider != "" {
+				params.Provider = &failure.Provider
+			}
+			params.PromptTokens = int32(failure.Usage.PromptTokens)
+			params.CompletionTokens = int32(failure.Usage.CompletionTokens)
+			params.CostUsd = failure.CostUSD
+			params.LatencyMs = int32(failure.Latency.Milliseconds())
+		}
+	}
+
+	if _, err := s.queries.CreateInstruction(ctx, params); err != nil {
+		return fmt.Errorf("failed to record instruction: %w", err)
+	}
+	return nil
+}
+
+func (s *instructionService) RecordTiming(boardID string, userID string, timing livekit.InstructionTiming) {
+	boardUUID, err := uuid.Parse(boardID)
+	if err != nil {
+		return
+	}
+	if !s.timings.Enqueue(repo.CreateInstructionTimingParams{
+		RequestID:   timing.RequestID,
+		BoardID:     boardUUID,
+		UserID:      userID,
+		Provider:    timing.Provider,
+		Model:       timing.Model,
+		Failed:      timing.Failed,
+		QueueMs:     int32(timing.Queue.Milliseconds()),
+		FirstByteMs: int32(timing.FirstByte.Milliseconds()),
+		LlmMs:       int32(timing.LLM.Milliseconds()),
+		ValidateMs:  int32(timing.Validate.Milliseconds()),
+		BroadcastMs: int32(timing.Broadcast.Milliseconds()),
+		TotalMs:     int32(timing.Total.Milliseconds()),
+	}) {
+		fmt.Println("Dropped instruction timing for board ID", boardID, "request ID", timing.RequestID)
+	}
+}
+
+func (s *instructionService) TrackIntent(ctx context.Context, boardID string, userID string, requestID string, instruction string, state string) error {
+	boardUUID, err := uuid.Parse(boardID)
+	if err != nil {
+		return fmt.Errorf("invalid board id: %w", err)
+	}
+	if state == livekit.InstructionPending {
+		// A resumed instruction already has its row.
+		if err := s.queries.CreateInstructionIntent(ctx, repo.CreateInstructionIntentParams{
+			BoardID:     boardUUID,
+			RequestID:   requestID,
+			UserID:      userID,
+			Instruction: instruction,
+		}); err != nil {
+			return fmt.Errorf("failed to record instruction intent: %w", err)
+		}
+		return nil
+	}
+	if _, err := s.queries.SettleInstructionIntent(ctx, repo.SettleInstructionIntentParams{
+		State:     state,
+		RequestID: requestID,
+	}); err != nil {
+		return fmt.Errorf("failed to settle instruction intent: %w", err)
+	}
+	return nil
+}
+
+func (s *instructionService) InterruptPending(ctx context.Context, startedAt time.Time) error {
+	cutoff := startedAt.Add(-s.resumeWindow())
+	abandoned, err := s.queries.AbandonStaleIntents(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to abandon stale instructions: %w", err)
+	}
+	interrupted, err := s.queries.InterruptPendingIntents(ctx, repo.InterruptPendingIntentsParams{
+		Since:  cutoff,
+		Before: startedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark interrupted instructions: %w", err)
+	}
+	if abandoned > 0 || interrupted > 0 {
+		fmt.Println("Recovered instructions cut off by a restart", "interrupted", interrupted, "abandoned", abandoned)
+	}
+	return nil
+}
+
+func (s *instructionService) ClaimInterrupted(ctx context.Context, boardID uuid.UUID) ([]repo.InstructionIntent, error) {
+	if s.resumeWindow() <= 0 {
+		return nil, nil
+	}
+	intents, err := s.queries.ClaimInterruptedIntents(ctx, repo.ClaimInterruptedIntentsParams{
+		BoardID: boardID,
+		Since:   time.Now().Add(-s.resumeWindow()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim interrupted instructions: %w", err)
+	}
+	return intents, nil
+}
+
+func (s *instructionService) resumeWindow() time.Duration {
+	return time.Duration(s.config.LLM.ResumeWindowSec) * time.Second
+}
+
+// instructionOutcome classifies how an instruction ended: applied, answered
+// with an error action because the model couldn't act on it, rejected because
+// the output failed validation, refused because the LLM budget is spent, or
+// failed outright.
+func instructionOutcome(response *llm.LLMResponse, llmErr error) string {
+	if llmErr == nil {
+		if response != nil {
+			action := response.ParsedAction
+			if action == nil {
+				action, _ = llm.ParseWhiteboardAction(response.Response)
+			}
+			if action != nil && action.Action == llm.ActionError {
+				return OutcomeNotActionable
+			}
+		}
+		return OutcomeSuccess
+	}
+	if errors.Is(llmErr, llm.ErrBudgetExhausted) {
+		return OutcomeFiltered
+	}
+	var failure *livekit.InstructionFailure
+	if errors.As(llmErr, &failure) && len(failure.Attempts) > 0 {
+		last := failure.Attempts[len(failure.Attempts)-1]
+		switch {
+		case last.Stage == livekit.StageParse || last.Stage == livekit.StageResolve || last.Stage == livekit.StageValidate:
+			return OutcomeValidationFailed
+		case last.Error == llm.ErrBudgetExhausted.Error():
+			return OutcomeFiltered
+		}
+	}
+	return OutcomeError
+}
+
+func (s *instructionService) GetBoardInstructions(ctx context.Context, req dto.GetBoardInstructionsRequest) (*dto.GetBoardInstructionsResponse, error) {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+
+	instructions, err := s.queries.GetInstructionsByBoardID(ctx, repo.GetInstructionsByBoardIDParams{
+		BoardID: board.ID,
+		Limit:   instructionHistoryLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instructions: %w", err)
+	}
+
+	resp := make([]dto.Instruction, 0, len(instructions))
+	for _, instruction := range instructions {
+		resp = append(resp, s.toInstructionResponse(instruction))
+	}
+	return &dto.GetBoardInstructionsResponse{
+		Instructions: resp,
+	}, nil
+}
+
+// GetBoardHistory works back from the current board, undoing each
+// instruction on a copy to find the board it was applied to. An
+// instruction's diff is between that board and the one after it, so it
+// covers only the elements the instruction touched, whatever else was edited
+// by hand since.
+func (s *instructionService) GetBoardHistory(ctx context.Context, req dto.GetBoardHistoryRequest) (*dto.GetBoardHistoryResponse, error) {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+
+	instructions, err := s.queries.GetUndoableInstructionsByBoardID(ctx, repo.GetUndoableInstructionsByBoardIDParams{
+		BoardID: board.ID,
+		Limit:   instructionHistoryLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instructions: %w", err)
+	}
+
+	var after []llm.Element
+	if len(board.Elements) > 0 {
+		if err := json.Unmarshal(board.Elements, &after); err != nil {
+			return nil, fmt.Errorf("failed to parse board elements: %w", err)
+		}
+	}
+	entries := make([]dto.HistoryEntry, 0, len(instructions))
+	for _, instruction := range instructions {
+		var inverse llm.WhiteboardAction
+		if err := json.Unmarshal([]byte(*instruction.InverseAction), &inverse); err != nil {
+			return nil, fmt.Errorf("failed to parse inverse action: %w", err)
+		}
+		before, err := whiteboard.ApplyAction(after, &inverse)
+		if err != nil {
+			// Later hand edits can leave nothing to undo against; the
+			// history stops where it can no longer be worked out.
+			break
+		}
+		diff, err := whiteboard.Diff(before, after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff board: %w", err)
+		}
+		entries = append(entries, dto.HistoryEntry{
+			Instruction: s.toInstructionResponse(instruction),
+			Diff:        diff,
+		})
+		after = before
+	}
+	return &dto.GetBoardHistoryResponse{
+		Entries: entries,
+	}, nil
+}
+
+func (s *instructionService) toInstructionResponse(instruction repo.BoardInstruction) dto.Instruction {
+	resp := dto.Instruction{
+		ID:          instruction.ID,
+		UserID:      instruction.UserID,
+		Instruction: instruction.Instruction,
+		RawResponse: instruction.RawResponse,
+		Error:       instruction.Error,
+		CreatedAt:   dto.NewTimestamp(instruction.CreatedAt),
+	}
+	if instruction.RedactedAt != nil {
+		resp.Redacted = true
+		resp.Instruction = redactedPlaceholder(s.config.Retention.InstructionDays)
+	}
+	return resp
+}
+
+func redactedPlaceholder(days int) string {
+	if days <= 0 {
+		return "[redacted]"
+	}
+	return fmt.Sprintf("[redacted after %d days]", days)
+}
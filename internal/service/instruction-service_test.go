@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+
+	"github.com/google/uuid"
+)
+
+func TestInstructionResponse(t *testing.T) {
+	redactedAt := time.Now()
+	response := `{"action":"add"}`
+	tests := []struct {
+		name     string
+		days     int
+		redacted *time.Time
+		want     string
+	}{
+		{name: "kept", days: 30, want: "add a box"},
+		{name: "redacted", days: 30, redacted: &redactedAt, want: "[redacted after 30 days]"},
+		{name: "redacted with retention since disabled", redacted: &redactedAt, want: "[redacted]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &instructionService{config: &config.AppConfig{Retention: config.RetentionConfig{InstructionDays: tt.days}}}
+			got := s.toInstructionResponse(repo.BoardInstruction{
+				Instruction: "add a box",
+				RawResponse: &response,
+				RedactedAt:  tt.redacted,
+			})
+			if got.Instruction != tt.want {
+				t.Errorf("instruction = %q, want %q", got.Instruction, tt.want)
+			}
+			if got.Redacted != (tt.redacted != nil) {
+				t.Errorf("redacted = %v, want %v", got.Redacted, tt.redacted != nil)
+			}
+			if got.RawResponse == nil || *got.RawResponse != response {
+				t.Errorf("raw response = %v, want it kept", got.RawResponse)
+			}
+		})
+	}
+}
+
+func TestRedactedInstructionsStayUndoable(t *testing.T) {
+	// Two instructions each added an element; the older one has since been
+	// redacted. Redaction keeps the inverse action, so it still undoes.
+	board := repo.Board{
+		ID:       uuid.New(),
+		OwnerID:  "u1",
+		Elements: []byte(`[{"id":"a","type":"rectangle","x":0,"y":0},{"id":"b","type":"ellipse","x":10,"y":10}]`),
+	}
+	inverse := func(id string) *string {
+		data, err := json.Marshal(llm.WhiteboardAction{Action: llm.ActionDelete, DeleteIDs: []string{id}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(data)
+		return &s
+	}
+	redactedAt := time.Now()
+	db := newFakeDB(board)
+	db.instructions = []repo.BoardInstruction{
+		{ID: uuid.New(), BoardID: board.ID, UserID: "u1", Instruction: "add an ellipse", InverseAction: inverse("b")},
+		{ID: uuid.New(), BoardID: board.ID, UserID: "u1", Instruction: "", RedactedAt: &redactedAt, InverseAction: inverse("a")},
+	}
+	s := &instructionService{
+		queries: repo.New(db),
+		config:  &config.AppConfig{Retention: config.RetentionConfig{InstructionDays: 30}},
+	}
+
+	list, err := s.GetBoardInstructions(context.Background(), dto.GetBoardInstructionsRequest{BoardID: board.ID.String(), UserID: "u1"})
+	if err != nil {
+		t.Fatalf("GetBoardInstructions: %v", err)
+	}
+	history, err := s.GetBoardHistory(context.Background(), dto.GetBoardHistoryRequest{BoardID: board.ID.String(), UserID: "u1"})
+	if err != nil {
+		t.Fatalf("GetBoardHistory: %v", err)
+	}
+	if len(list.Instructions) != 2 || len(history.Entries) != 2 {
+		t.Fatalf("got %d instructions and %d history entries, want 2 of each", len(list.Instructions), len(history.Entries))
+	}
+
+	tests := []struct {
+		name        string
+		instruction string
+		redacted    bool
+		added       string
+	}{
+		{name: "kept", instruction: "add an ellipse", added: "b"},
+		{name: "redacted", instruction: "[redacted after 30 days]", redacted: true, added: "a"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, got := range []dto.Instruction{list.Instructions[i], history.Entries[i].Instruction} {
+				if got.Instruction != tt.instruction || got.Redacted != tt.redacted {
+					t.Errorf("got %q (redacted %v), want %q (redacted %v)", got.Instruction, got.Redacted, tt.instruction, tt.redacted)
+				}
+			}
+			diff := history.Entries[i].Diff
+			if len(diff.Added) != 1 || diff.Added[0].ID != tt.added || len(diff.Removed) != 0 || len(diff.Modified) != 0 {
+				t.Errorf("diff = %+v, want only %s added", diff, tt.added)
+			}
+		})
+	}
+}
+
+// TestInstructionsRecordedAsTheyArrive checks that nothing a session says is
+// held in memory: after every instruction, a service started afresh on the
+// same database, as after a crash, lists all of them.
+func TestInstructionsRecordedAsTheyArrive(t *testing.T) {
+	board := repo.Board{ID: uuid.New(), OwnerID: "u1", Elements: []byte(`[]`)}
+	db := newFakeDB(board)
+	cfg := &config.AppConfig{Retention: config.RetentionConfig{InstructionDays: 30}}
+	session := &instructionService{queries: repo.New(db), config: cfg}
+
+	tests := []struct {
+		instruction string
+		response    *llm.LLMResponse
+		err         error
+		wantErr     bool
+	}{
+		{instruction: "add a box", response: &llm.LLMResponse{Response: `{"action":"add","elements":[]}`, Provider: "ollama"}},
+		{instruction: "make it blue", err: errors.New("provider unavailable"), wantErr: true},
+		{instruction: "clear the board", response: &llm.LLMResponse{Response: `{"action":"clear"}`}},
+	}
+	for i, tt := range tests {
+		if err := session.RecordInstruction(context.Background(), board.ID.String(), "u1", tt.instruction, tt.response, tt.err); err != nil {
+			t.Fatalf("RecordInstruction(%q): %v", tt.instruction, err)
+		}
+
+		restarted := &instructionService{queries: repo.New(db), config: cfg}
+		list, err := restarted.GetBoardInstructions(context.Background(), dto.GetBoardInstructionsRequest{BoardID: board.ID.String(), UserID: "u1"})
+		if err != nil {
+			t.Fatalf("GetBoardInstructions: %v", err)
+		}
+		if len(list.Instructions) != i+1 {
+			t.Fatalf("after %q a restart lists %d instructions, want %d", tt.instruction, len(list.Instructions), i+1)
+		}
+		// Listed newest first.
+		got := list.Instructions[0]
+		if got.Instruction != tt.instruction || (got.Error != nil) != tt.wantErr {
+			t.Errorf("latest = %q (error %v), want %q (error: %v)", got.Instruction, got.Error, tt.instruction, tt.wantErr)
+		}
+	}
+}
+
+// TestInterruptedInstructionsResume simulates a restart with instructions in
+// every state. Only those cut off before their outcome was broadcast, and
+// recent enough, are resumed, and only once.
+func TestInterruptedInstructionsResume(t *testing.T) {
+	ctx := context.Background()
+	board := repo.Board{ID: uuid.New(), OwnerID: "u1", Elements: []byte(`[]`)}
+	db := newFakeDB(board)
+	cfg := &config.AppConfig{LLM: config.LLMConfig{ResumeWindowSec: 120}}
+	before := &instructionService{queries: repo.New(db), config: cfg}
+
+	startedAt := time.Now()
+	tests := []struct {
+		requestID string
+		// age is how long before the restart the instruction was said.
+		age time.Duration
+		// settled is the state it reached before the crash, if any.
+		settled string
+		want    string
+	}{
+		// Cut off between the LLM's answer and the broadcast.
+		{requestID: "cut-off", age: time.Minute, want: "resuming"},
+		{requestID: "applied", age: time.Minute, settled: livekit.InstructionApplied, want: livekit.InstructionApplied},
+		{requestID: "failed", age: time.Minute, settled: livekit.InstructionFailed, want: livekit.InstructionFailed},
+		{requestID: "too old", age: 10 * time.Minute, want: livekit.InstructionFailed},
+		// Said on another replica after this one started.
+		{requestID: "after restart", age: -time.Second, want: livekit.InstructionPending},
+	}
+	for _, tt := range tests {
+		if err := before.TrackIntent(ctx, board.ID.String(), "u1", tt.requestID, "add a box", livekit.InstructionPending); err != nil {
+			t.Fatalf("TrackIntent(%s): %v", tt.requestID, err)
+		}
+		intent := db.intents[tt.requestID]
+		intent.CreatedAt = startedAt.Add(-tt.age)
+		db.intents[tt.requestID] = intent
+		if tt.settled != "" {
+			if err := before.TrackIntent(ctx, board.ID.String(), "u1", tt.requestID, "add a box", tt.settled); err != nil {
+				t.Fatalf("TrackIntent(%s): %v", tt.requestID, err)
+			}
+		}
+	}
+
+	after := &instructionService{queries: repo.New(db), config: cfg}
+	if err := after.InterruptPending(ctx, startedAt); err != nil {
+		t.Fatalf("InterruptPending: %v", err)
+	}
+	claimed, err := after.ClaimInterrupted(ctx, board.ID)
+	if err != nil {
+		t.Fatalf("ClaimInterrupted: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].RequestID != "cut-off" {
+		t.Errorf("claimed %+v, want only cut-off", claimed)
+	}
+	for _, tt := range tests {
+		if got := db.intents[tt.requestID].State; got != tt.want {
+			t.Errorf("%s is %s, want %s", tt.requestID, got, tt.want)
+		}
+	}
+
+	// Another replica opening the board finds nothing left to claim.
+	if again, err := after.ClaimInterrupted(ctx, board.ID); err != nil || len(again) != 0 {
+		t.Errorf("second claim got %+v (%v), want nothing", again, err)
+	}
+
+	// The resumed run tracks the same request ID, which keeps its row, and
+	// settles it once its outcome is broadcast.
+	for _, state := range []string{livekit.InstructionPending, livekit.InstructionApplied} {
+		if err := after.TrackIntent(ctx, board.ID.String(), "u1", "cut-off", "add a box", state); err != nil {
+			t.Fatalf("TrackIntent(cut-off, %s): %v", state, err)
+		}
+	}
+	if got := db.intents["cut-off"].State; got != livekit.InstructionApplied {
+		t.Errorf("resumed instruction is %s, want %s", got, livekit.InstructionApplied)
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// legalHoldViolation is the SQLSTATE the database raises when something
+// tries to delete a held board or its history.
+const legalHoldViolation = "LH001"
+
+// ErrLegalHold is returned for deletions refused because the board is under
+// legal hold. Holds are placed and released with cmd/legalhold.
+var ErrLegalHold = fmt.Errorf("%w: the board is under legal hold and its content can't be deleted; contact support", ErrConflict)
+
+// legalHoldError turns the database's legal hold refusal into ErrLegalHold,
+// and wraps anything else as a failure to op.
+func legalHoldError(op string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == legalHoldViolation {
+		return ErrLegalHold
+	}
+	return fmt.Errorf("failed to %s: %w", op, err)
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestLegalHoldError(t *testing.T) {
+	other := errors.New("connection reset")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "legal hold violation", err: &pgconn.PgError{Code: legalHoldViolation}, want: ErrLegalHold},
+		{name: "other database error", err: &pgconn.PgError{Code: "23503"}},
+		{name: "other error", err: other, want: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := legalHoldError("delete board", tt.err)
+			if tt.want == ErrLegalHold {
+				if err != ErrLegalHold {
+					t.Fatalf("got %v, want ErrLegalHold", err)
+				}
+				if !errors.Is(err, ErrConflict) || !strings.Contains(err.Error(), "legal hold") {
+					t.Errorf("got %v, want a conflict explaining the hold", err)
+				}
+				return
+			}
+			if errors.Is(err, ErrLegalHold) {
+				t.Fatalf("got %v, want it not taken for a legal hold", err)
+			}
+			if !errors.Is(err, tt.err) || !strings.HasPrefix(err.Error(), "failed to delete board: ") {
+				t.Errorf("got %v, want %v wrapped as a failure to delete board", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDeleteHeldBoard(t *testing.T) {
+	heldAt := time.Now()
+	reason := "litigation"
+	held := repo.Board{ID: uuid.New(), OwnerID: "u1", Elements: []byte(`[]`), LegalHoldReason: &reason, LegalHoldAt: &heldAt}
+	free := repo.Board{ID: uuid.New(), OwnerID: "u1", Elements: []byte(`[]`)}
+	comment := repo.BoardComment{ID: uuid.New(), BoardID: held.ID, AuthorID: "u1", Text: "keep this"}
+	freeComment := repo.BoardComment{ID: uuid.New(), BoardID: free.ID, AuthorID: "u1", Text: "drop this"}
+
+	db := newFakeDB(held, free)
+	db.comments[comment.ID] = comment
+	db.comments[freeComment.ID] = freeComment
+	queries := repo.New(db)
+	sessions := livekit.NewSessionManager(&config.AppConfig{}, nil, nil, nil, nil, nil, nil, nil)
+	defer sessions.Close()
+	boards := &boardService{queries: queries}
+	comments := &commentService{queries: queries, sessions: sessions}
+
+	tests := []struct {
+		name   string
+		delete func() error
+		held   bool
+	}{
+		{
+			name: "held board",
+			delete: func() error {
+				return boards.DeleteBoard(context.Background(), dto.DeleteBoardRequest{BoardID: held.ID.String(), UserID: "u1"})
+			},
+			held: true,
+		},
+		{
+			name: "comment on a held board",
+			delete: func() error {
+				return comments.DeleteComment(context.Background(), dto.DeleteCommentRequest{BoardID: held.ID.String(), CommentID: comment.ID.String(), UserID: "u1"})
+			},
+			held: true,
+		},
+		{
+			name: "comment on a board without a hold",
+			delete: func() error {
+				return comments.DeleteComment(context.Background(), dto.DeleteCommentRequest{BoardID: free.ID.String(), CommentID: freeComment.ID.String(), UserID: "u1"})
+			},
+		},
+		{
+			name: "board without a hold",
+			delete: func() error {
+				return boards.DeleteBoard(context.Background(), dto.DeleteBoardRequest{BoardID: free.ID.String(), UserID: "u1"})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.delete()
+			if tt.held {
+				if !errors.Is(err, ErrLegalHold) || !errors.Is(err, ErrConflict) {
+					t.Fatalf("got %v, want ErrLegalHold", err)
+				}
+				if !strings.Contains(err.Error(), "legal hold") {
+					t.Errorf("got %q, want it to explain the hold", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got %v, want the deletion to succeed", err)
+			}
+		})
+	}
+
+	if _, ok := db.boards[held.ID]; !ok {
+		t.Errorf("the held board was deleted")
+	}
+	if _, ok := db.comments[comment.ID]; !ok {
+		t.Errorf("the held board's comment was deleted")
+	}
+	if _, ok := db.boards[free.ID]; ok {
+		t.Errorf("the board without a hold wasn't deleted")
+	}
+	if _, ok := db.comments[freeComment.ID]; ok {
+		t.Errorf("the comment without a hold wasn't deleted")
+	}
+}
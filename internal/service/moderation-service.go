@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/livekit"
+
+	"github.com/google/uuid"
+)
+
+// ModerationService lets board owners mute, remove, and change the role of
+// participants in the board's room.
+type ModerationService interface {
+	MuteParticipant(ctx context.Context, req dto.ModerateParticipantRequest) error
+	RemoveParticipant(ctx context.Context, req dto.ModerateParticipantRequest) error
+	SetParticipantRole(ctx context.Context, req dto.ModerateParticipantRequest) error
+}
+
+type moderationService struct {
+	queries  *repo.Queries
+	sessions *livekit.SessionManager
+}
+
+func NewModerationService(queries *repo.Queries, sessions *livekit.SessionManager) ModerationService {
+	return &moderationService{
+		queries:  queries,
+		sessions: sessions,
+	}
+}
+
+func (s *moderationService) MuteParticipant(ctx context.Context, req dto.ModerateParticipantRequest) error {
+	board, err := s.getBoard(ctx, req)
+	if err != nil {
+		return err
+	}
+	return moderationError(s.sessions.MuteParticipant(ctx, board.ID.String(), req.Identity))
+}
+
+func (s *moderationService) RemoveParticipant(ctx context.Context, req dto.ModerateParticipantRequest) error {
+	board, err := s.getBoard(ctx, req)
+	if err != nil {
+		return err
+	}
+	return moderationError(s.sessions.RemoveParticipant(ctx, board.ID.String(), req.Identity))
+}
+
+func (s *moderationService) SetParticipantRole(ctx context.Context, req dto.ModerateParticipantRequest) error {
+	board, err := s.getBoard(ctx, req)
+	if err != nil {
+		return err
+	}
+	return moderationError(s.sessions.SetParticipantRole(ctx, board.ID.String(), req.Identity, req.Role))
+}
+
+// getBoard loads the board, which also checks that the user owns it.
+func (s *moderationService) getBoard(ctx context.Context, req dto.ModerateParticipantRequest) (repo.Board, error) {
+	id, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("failed to get board: %w", err)
+	}
+	return board, nil
+}
+
+func moderationError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, livekit.ErrParticipantNotFound):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case errors.Is(err, livekit.ErrRoomNotFound):
+		return fmt.Errorf("%w: %v", ErrGone, err)
+	case errors.Is(err, livekit.ErrCannotModerateBot), errors.Is(err, livekit.ErrUnknownRole):
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return fmt.Errorf("failed to moderate participant: %w", err)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/livekit"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/twitchtv/twirp"
+)
+
+func TestModerationError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "participant left", err: livekit.ErrParticipantNotFound, want: ErrNotFound},
+		{name: "room gone", err: livekit.ErrRoomNotFound, want: ErrGone},
+		{name: "bot", err: livekit.ErrCannotModerateBot, want: ErrInvalidInput},
+		{name: "unknown role", err: livekit.ErrUnknownRole, want: ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := moderationError(tt.err)
+			if !errors.Is(err, tt.want) || !strings.Contains(err.Error(), tt.err.Error()) {
+				t.Errorf("got %v, want %v saying %q", err, tt.want, tt.err)
+			}
+		})
+	}
+
+	if err := moderationError(nil); err != nil {
+		t.Errorf("got %v for success, want nil", err)
+	}
+	failure := twirp.InternalError("boom")
+	err := moderationError(failure)
+	for _, kind := range []error{ErrNotFound, ErrGone, ErrInvalidInput} {
+		if errors.Is(err, kind) {
+			t.Errorf("LiveKit failure mapped to %v, want it passed on", kind)
+		}
+	}
+	if !errors.Is(err, failure) {
+		t.Errorf("got %v, want it to wrap %v", err, failure)
+	}
+}
+
+// TestModerationOwnerOnly checks that only the board's owner gets as far as
+// the LiveKit room; the service has no session manager to reach it with.
+func TestModerationOwnerOnly(t *testing.T) {
+	board := repo.Board{ID: uuid.New(), OwnerID: "owner", Elements: []byte(`[]`)}
+	s := &moderationService{queries: repo.New(newFakeDB(board))}
+
+	actions := map[string]func(ctx context.Context, req dto.ModerateParticipantRequest) error{
+		"mute":   s.MuteParticipant,
+		"remove": s.RemoveParticipant,
+		"role":   s.SetParticipantRole,
+	}
+	tests := []struct {
+		name    string
+		boardID string
+		userID  string
+		want    error
+	}{
+		{name: "not the owner", boardID: board.ID.String(), userID: "guest", want: pgx.ErrNoRows},
+		{name: "no such board", boardID: uuid.NewString(), userID: "owner", want: pgx.ErrNoRows},
+		{name: "invalid board id", boardID: "board", userID: "owner", want: ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		for action, moderate := range actions {
+			t.Run(tt.name+"/"+action, func(t *testing.T) {
+				err := moderate(context.Background(), dto.ModerateParticipantRequest{
+					BoardID:  tt.boardID,
+					UserID:   tt.userID,
+					Identity: "bob",
+					Role:     livekit.RoleListener,
+				})
+				if !errors.Is(err, tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"draw/internal/db/encrypted"
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	pendingStatusApproved = "approved"
+	pendingStatusRejected = "rejected"
+)
+
+// ErrPendingChangeNotFound is returned when a pending change doesn't exist,
+// has already been decided, or has expired.
+var ErrPendingChangeNotFound = fmt.Errorf("pending change %w", ErrNotFound)
+
+// PendingChangeService holds voice changes to protected boards until an
+// editor approves or rejects them.
+type PendingChangeService interface {
+	HoldIfProtected(ctx context.Context, boardID string, userID string, instruction string, response *llm.LLMResponse) (*dto.PendingChange, error)
+	GetPendingChanges(ctx context.Context, req dto.GetPendingChangesRequest) (*dto.GetPendingChangesResponse, error)
+	ApprovePendingChange(ctx context.Context, req dto.DecidePendingChangeRequest) (*dto.PendingChange, error)
+	RejectPendingChange(ctx context.Context, req dto.DecidePendingChangeRequest) (*dto.PendingChange, error)
+}
+
+type pendingChangeService struct {
+	queries   *repo.Queries
+	db        *pgxpool.Pool
+	encrypted *encrypted.DB
+	config    *config.AppConfig
+	sessions  *livekit.SessionManager
+	comments  CommentService
+}
+
+func NewPendingChangeService(
+	db *pgxpool.Pool,
+	queries *repo.Queries,
+	encryptedDB *encrypted.DB,
+	config *config.AppConfig,
+	sessions *livekit.SessionManager,
+	comments CommentService,
+) PendingChangeService {
+	return &pendingChangeService{
+		db:        db,
+		queries:   queries,
+		encrypted: encryptedDB,
+		config:    config,
+		sessions:  sessions,
+		comments:  comments,
+	}
+}
+
+// HoldIfProtected stores the response as a pending change when the board is
+// protected. It returns nil for unprotected boards, which apply immediately.
+func (s *pendingChangeService) HoldIfProtected(ctx context.Context, boardID string, userID string, instruction string, response *llm.LLMResponse) (*dto.PendingChange, error) {
+	boardUUID, err := uuid.Parse(boardID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid board id: %w", err)
+	}
+
+	protected, err := s.queries.GetBoardProtection(ctx, boardUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board protection: %w", err)
+	}
+	if !protected {
+		return nil, nil
+	}
+
+	ttl := time.Duration(s.config.Board.PendingChangeTTLSec) * time.Second
+	change, err := s.queries.CreatePendingChange(ctx, repo.CreatePendingChangeParams{
+		BoardID:     boardUUID,
+		UserID:      userID,
+		Instruction: instruction,
+		Response:    response.Response,
+		ExpiresAt:   time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pending change: %w", err)
+	}
+
+	resp := toPendingChangeResponse(change)
+	return &resp, nil
+}
+
+func (s *pendingChangeService) GetPendingChanges(ctx context.Context, req dto.GetPendingChangesRequest) (*dto.GetPendingChangesResponse, error) {
+	boardID, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      boardID,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+
+	changes, err := s.queries.GetPendingChangesByBoardID(ctx, board.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending changes: %w", err)
+	}
+
+	resp := make([]dto.PendingChange, 0, len(changes))
+	for _, change := range changes {
+		resp = append(resp, toPendingChangeResponse(change))
+	}
+	return &dto.GetPendingChangesResponse{
+		PendingChanges: resp,
+	}, nil
+}
+
+// ApprovePendingChange applies the change to the board as it is now and
+// broadcasts it so connected clients do the same. The change is marked
+// approved in the same transaction, so one that fails to apply stays pending.
+func (s *pendingChangeService) ApprovePendingChange(ctx context.Context, req dto.DecidePendingChangeRequest) (*dto.PendingChange, error) {
+	board, changeID, err := s.getBoard(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// Checked again under the lock; this just spares archived boards one.
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := repo.New(s.encrypted.WithTx(tx))
+
+	board, err = queries.GetBoardForUpdate(ctx, board.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+	change, err := s.decide(ctx, queries, board.ID, changeID, req, pendingStatusApproved)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := applyPendingChange(board.Elements, change.Response)
+	if err != nil {
+		return nil, err
+	}
+	board, err = queries.UpdateBoardElements(ctx, repo.UpdateBoardElementsParams{
+		ID:       board.ID,
+		Elements: elements,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update board: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if err := s.comments.OrphanComments(ctx, board.ID, board.Elements); err != nil {
+		fmt.Println("Failed to orphan comments for board ID", board.ID, err)
+	}
+	resp := s.publishDecision(change)
+	s.sessions.Publish(board.ID.String(), livekit.StreamTextData{
+		Type: "canvas_update",
+		Data: &llm.LLMResponse{
+			Response:  change.Response,
+			Timestamp: time.Now().UTC(),
+		},
+	})
+	return &resp, nil
+}
+
+// applyPendingChange applies the actions of a held response to the board's
+// elements the way clients merge a canvas update: updates and deletes of
+// elements removed since the change was held are skipped.
+func applyPendingChange(board json.RawMessage, response string) (json.RawMessage, error) {
+	actions, err := llm.ParseWhiteboardActions(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pending change: %w", err)
+	}
+	var elements []llm.Element
+	if len(board) > 0 {
+		if err := json.Unmarshal(board, &elements); err != nil {
+			return nil, fmt.Errorf("failed to parse board elements: %w", err)
+		}
+	}
+	for _, action := range actions {
+		elements, err = whiteboard.ApplyAction(elements, action)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply pending change: %w", err)
+		}
+	}
+	data, err := json.Marshal(elements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode elements: %w", err)
+	}
+	return data, nil
+}
+
+func (s *pendingChangeService) RejectPendingChange(ctx context.Context, req dto.DecidePendingChangeRequest) (*dto.PendingChange, error) {
+	board, changeID, err := s.getBoard(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+	change, err := s.decide(ctx, s.queries, board.ID, changeID, req, pendingStatusRejected)
+	if err != nil {
+		return nil, err
+	}
+	resp := s.publishDecision(change)
+	return &resp, nil
+}
+
+// getBoard returns the board a decision is made on and the ID of the change.
+func (s *pendingChangeService) getBoard(ctx context.Context, req dto.DecidePendingChangeRequest) (repo.Board, uuid.UUID, error) {
+	changeID, err := uuid.Parse(req.ChangeID)
+	if err != nil {
+		return repo.Board{}, uuid.Nil, fmt.Errorf("%w: invalid change id", ErrInvalidInput)
+	}
+
+	boardID, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return repo.Board{}, uuid.Nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      boardID,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return repo.Board{}, uuid.Nil, fmt.Errorf("failed to get board: %w", err)
+	}
+	return board, changeID, nil
+}
+
+func (s *pendingChangeService) decide(ctx context.Context, queries *repo.Queries, boardID uuid.UUID, changeID uuid.UUID, req dto.DecidePendingChangeRequest, status string) (repo.BoardPendingChange, error) {
+	params := repo.DecidePendingChangeParams{
+		ID:        changeID,
+		BoardID:   boardID,
+		Status:    status,
+		DecidedBy: &req.UserID,
+	}
+	if req.Reason != "" {
+		params.Reason = &req.Reason
+	}
+	change, err := queries.DecidePendingChange(ctx, params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return repo.BoardPendingChange{}, ErrPendingChangeNotFound
+	}
+	if err != nil {
+		return repo.BoardPendingChange{}, fmt.Errorf("failed to update pending change: %w", err)
+	}
+	return change, nil
+}
+
+// publishDecision broadcasts the decided change to the board.
+func (s *pendingChangeService) publishDecision(change repo.BoardPendingChange) dto.PendingChange {
+	resp := toPendingChangeResponse(change)
+	s.sessions.Publish(change.BoardID.String(), livekit.StreamTextData{
+		Type: "pending_change",
+		Data: resp,
+	})
+	return resp
+}
+
+func toPendingChangeResponse(change repo.BoardPendingChange) dto.PendingChange {
+	resp := dto.PendingChange{
+		ID:          change.ID,
+		BoardID:     change.BoardID,
+		UserID:      change.UserID,
+		Instruction: change.Instruction,
+		Response:    change.Response,
+		Status:      change.Status,
+		Reason:      change.Reason,
+		DecidedBy:   change.DecidedBy,
+		ExpiresAt:   dto.NewTimestamp(change.ExpiresAt),
+		CreatedAt:   dto.NewTimestamp(change.CreatedAt),
+	}
+	if change.DecidedAt != nil {
+		decidedAt := dto.NewTimestamp(*change.DecidedAt)
+		resp.DecidedAt = &decidedAt
+	}
+	return resp
+}
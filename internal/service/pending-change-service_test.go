@@ -0,0 +1,103 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+func TestApplyPendingChange(t *testing.T) {
+	board := json.RawMessage(`[
+		{"id":"rect-1","type":"rectangle","x":0,"y":0,"width":100,"height":60},
+		{"id":"rect-2","type":"rectangle","x":200,"y":0,"width":100,"height":60}
+	]`)
+	tests := []struct {
+		name     string
+		board    json.RawMessage
+		response string
+		// want maps the IDs the board ends up with to whether they are
+		// deleted.
+		want    map[string]bool
+		wantErr bool
+	}{
+		{
+			name:     "add",
+			board:    board,
+			response: `{"action":"add","elements":[{"id":"rect-3","type":"rectangle","x":400,"y":0,"width":100,"height":60}]}`,
+			want:     map[string]bool{"rect-1": false, "rect-2": false, "rect-3": false},
+		},
+		{
+			name:     "add to an empty board",
+			response: `{"action":"add","elements":[{"id":"rect-3","type":"rectangle","x":0,"y":0}]}`,
+			want:     map[string]bool{"rect-3": false},
+		},
+		{
+			// rect-9 was removed while the change was held.
+			name:     "update of a removed element",
+			board:    board,
+			response: `{"action":"update","elements":[{"id":"rect-1","type":"rectangle","x":50,"y":0},{"id":"rect-9","type":"rectangle","x":0,"y":0}]}`,
+			want:     map[string]bool{"rect-1": false, "rect-2": false},
+		},
+		{
+			name:     "delete",
+			board:    board,
+			response: `{"action":"delete","delete_ids":["rect-2","rect-9"]}`,
+			want:     map[string]bool{"rect-1": false, "rect-2": true},
+		},
+		{
+			name:     "actions in order",
+			board:    board,
+			response: `[{"action":"delete","delete_ids":["rect-1"]},{"action":"add","elements":[{"id":"rect-3","type":"rectangle","x":0,"y":0}]}]`,
+			want:     map[string]bool{"rect-1": true, "rect-2": false, "rect-3": false},
+		},
+		{
+			name:     "not an action",
+			board:    board,
+			response: `sorry, I can't do that`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := applyPendingChange(tt.board, tt.response)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %s, want an error", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPendingChange: %v", err)
+			}
+			var elements []llm.Element
+			if err := json.Unmarshal(data, &elements); err != nil {
+				t.Fatalf("failed to parse elements: %v", err)
+			}
+			got := make(map[string]bool, len(elements))
+			for _, element := range elements {
+				got[element.ID] = string(element.Extra["isDeleted"]) == "true"
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got elements %v, want %v", got, tt.want)
+			}
+			for id, deleted := range tt.want {
+				if d, ok := got[id]; !ok || d != deleted {
+					t.Errorf("%s: got present %v deleted %v, want deleted %v", id, ok, d, deleted)
+				}
+			}
+		})
+	}
+
+	data, err := applyPendingChange(board, `{"action":"update","elements":[{"id":"rect-1","type":"rectangle","x":50,"y":0}]}`)
+	if err != nil {
+		t.Fatalf("applyPendingChange: %v", err)
+	}
+	var elements []llm.Element
+	if err := json.Unmarshal(data, &elements); err != nil {
+		t.Fatalf("failed to parse elements: %v", err)
+	}
+	if elements[0].X != 50 || elements[0].Width != 100 {
+		t.Errorf("rect-1 = x %v width %v, want the update merged into x 50 width 100", elements[0].X, elements[0].Width)
+	}
+}
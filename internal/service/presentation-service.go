@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/livekit"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultPresentationTTL = 24 * time.Hour
+	maxPresentationTTL     = 30 * 24 * time.Hour
+	// spectatorTokenTTL bounds how long a display stays in the room after
+	// its presentation token is revoked.
+	spectatorTokenTTL = 15 * time.Minute
+)
+
+var (
+	// ErrPresentationTokenNotFound is returned for unknown token IDs.
+	ErrPresentationTokenNotFound = fmt.Errorf("presentation token %w", ErrNotFound)
+	// ErrInvalidPresentationToken is returned for tokens that are unknown,
+	// expired or revoked.
+	ErrInvalidPresentationToken = errors.New("presentation token is invalid, expired or revoked")
+)
+
+// PresentationPrincipal is who a presentation token authenticates: a display
+// allowed to view exactly one board.
+type PresentationPrincipal struct {
+	TokenID uuid.UUID
+	BoardID uuid.UUID
+}
+
+// PresentationService manages read-only presentation tokens and serves the
+// board to displays that hold one.
+type PresentationService interface {
+	CreateToken(ctx context.Context, req dto.CreatePresentationTokenRequest) (*dto.PresentationToken, error)
+	GetTokens(ctx context.Context, req dto.GetPresentationTokensRequest) (*dto.GetPresentationTokensResponse, error)
+	RevokeToken(ctx context.Context, req dto.RevokePresentationTokenRequest) error
+	Authenticate(ctx context.Context, token string) (*PresentationPrincipal, error)
+	GetPresentation(ctx context.Context, req dto.GetPresentationRequest) (*dto.Presentation, error)
+}
+
+type presentationService struct {
+	queries  *repo.Queries
+	sessions *livekit.SessionManager
+}
+
+func NewPresentationService(queries *repo.Queries, sessions *livekit.SessionManager) PresentationService {
+	return &presentationService{
+		queries:  queries,
+		sessions: sessions,
+	}
+}
+
+func (s *presentationService) CreateToken(ctx context.Context, req dto.CreatePresentationTokenRequest) (*dto.PresentationToken, error) {
+	ttl := defaultPresentationTTL
+	if req.ExpiresInSec < 0 {
+		return nil, fmt.Errorf("%w: expiresInSec must be positive", ErrInvalidInput)
+	}
+	if req.ExpiresInSec > 0 {
+		ttl = time.Duration(req.ExpiresInSec) * time.Second
+	}
+	if ttl > maxPresentationTTL {
+		return nil, fmt.Errorf("%w: presentation tokens expire within %d days", ErrInvalidInput, int(maxPresentationTTL.Hours()/24))
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate presentation token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	created, err := s.queries.CreatePresentationToken(ctx, repo.CreatePresentationTokenParams{
+		BoardID:   board.ID,
+		TokenHash: hashPresentationToken(token),
+		Label:     req.Label,
+		CreatedBy: req.UserID,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create presentation token: %w", err)
+	}
+
+	resp := toPresentationTokenResponse(created)
+	resp.Token = token
+	return &resp, nil
+}
+
+func (s *presentationService) GetTokens(ctx context.Context, req dto.GetPresentationTokensRequest) (*dto.GetPresentationTokensResponse, error) {
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.queries.GetPresentationTokensByBoardID(ctx, board.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get presentation tokens: %w", err)
+	}
+
+	resp := make([]dto.PresentationToken, 0, len(tokens))
+	for _, token := range tokens {
+		resp = append(resp, toPresentationTokenResponse(token))
+	}
+	return &dto.GetPresentationTokensResponse{
+		Tokens: resp,
+	}, nil
+}
+
+func (s *presentationService) RevokeToken(ctx context.Context, req dto.RevokePresentationTokenRequest) error {
+	tokenID, err := uuid.Parse(req.TokenID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid token id", ErrInvalidInput)
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.queries.RevokePresentationToken(ctx, repo.RevokePresentationTokenParams{
+		ID:      tokenID,
+		BoardID: board.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke presentation token: %w", err)
+	}
+	if rows == 0 {
+		return ErrPresentationTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate resolves a presentation token to the board it grants.
+func (s *presentationService) Authenticate(ctx context.Context, token string) (*PresentationPrincipal, error) {
+	if token == "" {
+		return nil, ErrInvalidPresentationToken
+	}
+	record, err := s.queries.GetActivePresentationToken(ctx, hashPresentationToken(token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidPresentationToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up presentation token: %w", err)
+	}
+	return &PresentationPrincipal{
+		TokenID: record.ID,
+		BoardID: record.BoardID,
+	}, nil
+}
+
+// GetPresentation returns the board content and a subscribe-only room token.
+// The board comes from the authenticated token, never from the request path.
+func (s *presentationService) GetPresentation(ctx context.Context, req dto.GetPresentationRequest) (*dto.Presentation, error) {
+	boardID, err := uuid.Parse(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+
+	board, err := s.queries.GetBoardForPresentation(ctx, boardID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("board %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get board: %w", err)
+	}
+
+	token, err := s.sessions.SpectatorToken(board.ID.String(), "presentation-"+req.TokenID, spectatorTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return &dto.Presentation{
+		BoardID:  board.ID,
+		Name:     board.Name,
+		Elements: board.Elements,
+		Token:    token,
+	}, nil
+}
+
+// getBoard loads the board, which also checks that the user owns it.
+func (s *presentationService) getBoard(ctx context.Context, boardID string, userID string) (repo.Board, error) {
+	id, err := uuid.Parse(boardID)
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: userID,
+	})
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("failed to get board: %w", err)
+	}
+	return board, nil
+}
+
+// hashPresentationToken is what is stored, so a database leak doesn't leak
+// working tokens.
+func hashPresentationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func toPresentationTokenResponse(token repo.BoardPresentationToken) dto.PresentationToken {
+	resp := dto.PresentationToken{
+		ID:        token.ID,
+		BoardID:   token.BoardID,
+		Label:     token.Label,
+		CreatedBy: token.CreatedBy,
+		ExpiresAt: dto.NewTimestamp(token.ExpiresAt),
+		CreatedAt: dto.NewTimestamp(token.CreatedAt),
+	}
+	if token.RevokedAt != nil {
+		revokedAt := dto.NewTimestamp(*token.RevokedAt)
+		resp.RevokedAt = &revokedAt
+	}
+	return resp
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"draw/pkg/llm"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrThrottled marks requests refused because a limit was reached.
+var ErrThrottled = errors.New("throttled")
+
+// ErrUnavailable marks requests refused because a dependency is down or busy.
+var ErrUnavailable = errors.New("unavailable")
+
+// RetryError carries backoff guidance for a throttled or unavailable
+// request. Kind is ErrThrottled or ErrUnavailable.
+type RetryError struct {
+	Kind      error
+	Resource  string
+	After     time.Duration
+	Retryable bool
+	Err       error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s %v: %v", e.Resource, e.Kind, e.Err)
+}
+
+func (e *RetryError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// classifyDependencyError turns errors from a busy or unreachable dependency
+// into a *RetryError; anything else is returned unchanged.
+func classifyDependencyError(resource string, err error) error {
+	var rateLimited *llm.RateLimitError
+	if errors.As(err, &rateLimited) {
+		return &RetryError{Kind: ErrThrottled, Resource: "llm", After: rateLimited.RetryAfter, Retryable: true, Err: err}
+	}
+	if errors.Is(err, llm.ErrQueueFull) {
+		return &RetryError{Kind: ErrThrottled, Resource: "llm", After: 2 * time.Second, Retryable: true, Err: err}
+	}
+	if errors.Is(err, llm.ErrProviderThrottled) {
+		return &RetryError{Kind: ErrThrottled, Resource: "llm", After: 5 * time.Second, Retryable: true, Err: err}
+	}
+	if errors.Is(err, llm.ErrProviderUnavailable) {
+		return &RetryError{Kind: ErrUnavailable, Resource: "llm", After: 5 * time.Second, Retryable: true, Err: err}
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return &RetryError{Kind: ErrUnavailable, Resource: resource, After: time.Second, Retryable: true, Err: err}
+	case codes.ResourceExhausted:
+		return &RetryError{Kind: ErrThrottled, Resource: resource, After: 2 * time.Second, Retryable: true, Err: err}
+	case codes.Unimplemented:
+		return &RetryError{Kind: ErrUnavailable, Resource: resource, Retryable: false, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"draw/pkg/llm"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyDependencyError(t *testing.T) {
+	other := errors.New("boom")
+	tests := []struct {
+		name      string
+		err       error
+		kind      error
+		resource  string
+		after     time.Duration
+		retryable bool
+	}{
+		{name: "rate limited", err: &llm.RateLimitError{RetryAfter: 7 * time.Second}, kind: ErrThrottled, resource: "llm", after: 7 * time.Second, retryable: true},
+		{name: "queue full", err: fmt.Errorf("generate: %w", llm.ErrQueueFull), kind: ErrThrottled, resource: "llm", after: 2 * time.Second, retryable: true},
+		{name: "provider throttled", err: llm.ErrProviderThrottled, kind: ErrThrottled, resource: "llm", after: 5 * time.Second, retryable: true},
+		{name: "provider unavailable", err: llm.ErrProviderUnavailable, kind: ErrUnavailable, resource: "llm", after: 5 * time.Second, retryable: true},
+		{name: "speech unavailable", err: status.Error(codes.Unavailable, "down"), kind: ErrUnavailable, resource: "speech", after: time.Second, retryable: true},
+		{name: "speech exhausted", err: status.Error(codes.ResourceExhausted, "busy"), kind: ErrThrottled, resource: "speech", after: 2 * time.Second, retryable: true},
+		{name: "speech unsupported", err: status.Error(codes.Unimplemented, "no"), kind: ErrUnavailable, resource: "speech"},
+		{name: "other error", err: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyDependencyError("speech", tt.err)
+			var retryErr *RetryError
+			if !errors.As(err, &retryErr) {
+				if tt.kind != nil {
+					t.Fatalf("got %v, want a RetryError", err)
+				}
+				if err != tt.err {
+					t.Errorf("got %v, want the error unchanged", err)
+				}
+				return
+			}
+			if tt.kind == nil {
+				t.Fatalf("got %v, want the error unchanged", err)
+			}
+			if !errors.Is(err, tt.kind) || !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want it to match %v and the original error", err, tt.kind)
+			}
+			if retryErr.Resource != tt.resource || retryErr.After != tt.after || retryErr.Retryable != tt.retryable {
+				t.Errorf("got resource %q after %v retryable %v, want %q after %v retryable %v",
+					retryErr.Resource, retryErr.After, retryErr.Retryable, tt.resource, tt.after, tt.retryable)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/llm/prompts"
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+)
+
+// maxSandboxInstructionLen caps the instruction text, well above anything a
+// spoken instruction produces.
+const maxSandboxInstructionLen = 2000
+
+// SandboxService runs instructions through the voice pipeline against
+// ad-hoc board states and reports every stage. Nothing is persisted or
+// broadcast, and LLM calls bypass the monthly budget, so requests are held
+// to a separate per-user quota.
+type SandboxService interface {
+	RunInstruction(ctx context.Context, req dto.SandboxInstructionRequest) (*dto.SandboxInstructionResponse, error)
+}
+
+type sandboxService struct {
+	config  *config.AppConfig
+	quota   *sandboxQuota
+	prompts *prompts.Registry
+}
+
+func NewSandboxService(config *config.AppConfig, registry *prompts.Registry) SandboxService {
+	return &sandboxService{
+		config:  config,
+		quota:   newSandboxQuota(config.Sandbox.RequestsPerMin, config.Sandbox.RequestsPerDay),
+		prompts: registry,
+	}
+}
+
+func (s *sandboxService) RunInstruction(ctx context.Context, req dto.SandboxInstructionRequest) (*dto.SandboxInstructionResponse, error) {
+	req.Instruction = strings.TrimSpace(req.Instruction)
+	if req.Instruction == "" {
+		return nil, fmt.Errorf("%w: instruction is required", ErrInvalidInput)
+	}
+	if len(req.Instruction) > maxSandboxInstructionLen {
+		return nil, fmt.Errorf("%w: instruction may be at most %d characters", ErrInvalidInput, maxSandboxInstructionLen)
+	}
+	if len(req.BoardState) > s.config.Sandbox.MaxBoardBytes {
+		return nil, fmt.Errorf("%w: board state may be at most %d bytes", ErrInvalidInput, s.config.Sandbox.MaxBoardBytes)
+	}
+	if req.ArrowRepair != "" && !whiteboard.ValidArrowRepair(req.ArrowRepair) {
+		return nil, fmt.Errorf("%w: unknown arrow repair policy %q", ErrInvalidInput, req.ArrowRepair)
+	}
+	if req.Locale != "" {
+		if err := whiteboard.ValidateLocale(req.Locale); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+	if req.Timezone != "" {
+		if err := whiteboard.ValidateTimezone(req.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+
+	// Parse and re-encode the board the way a stored board reaches the
+	// pipeline, so the prompt sees the same normalized elements.
+	board := []llm.Element{}
+	if len(req.BoardState) > 0 && string(req.BoardState) != "null" {
+		if err := json.Unmarshal(req.BoardState, &board); err != nil {
+			return nil, fmt.Errorf("%w: board state must be an array of elements: %v", ErrInvalidInput, err)
+		}
+	}
+	boardState, err := json.Marshal(board)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode board state: %w", err)
+	}
+
+	llmConfig := s.config.LLM
+	if req.Provider != "" {
+		llmConfig.Provider = req.Provider
+	}
+	if req.Model != "" {
+		llmConfig.Model = req.Model
+	}
+	if req.PromptVersion != "" {
+		llmConfig.PromptVersion = req.PromptVersion
+	}
+	mode, err := prompts.ParseMode(req.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	systemPrompt, err := s.systemPrompt(llmConfig.PromptVersion, mode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	// No budget or rate limiter: sandbox calls are limited by the quota
+	// instead.
+	client, err := llm.NewLLMClient(&llmConfig, nil, nil, nil, s.prompts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	defer client.Close()
+
+	if retryAfter, ok := s.quota.allow(req.UserID, time.Now()); !ok {
+		return nil, &RetryError{Kind: ErrThrottled, Resource: "sandbox", After: retryAfter, Retryable: true, Err: fmt.Errorf("sandbox quota reached")}
+	}
+
+	pipeline := &livekit.Pipeline{
+		LLMClient:          client,
+		MaxAttempts:        s.config.LLM.MaxAttempts,
+		PromptTokenBudget:  s.config.LLM.PromptTokenBudget,
+		BoardStateMaxBytes: s.config.LLM.BoardStateMaxBytes,
+		FastPath:           s.config.LLM.FastPath,
+		LenientValidation:  !s.config.LLM.StrictValidation,
+		NormalizeColors:    s.config.LLM.NormalizeColors,
+		AutoLayout:         s.config.LLM.AutoLayout,
+	}
+	started := time.Now()
+	inst := pipeline.Prepare(uuid.NewString(), req.Instruction, string(boardState), started, req.Timezone, req.Locale, req.ArrowRepair)
+	inst.Options.Seed = req.Seed
+	inst.Options.Mode = mode
+	inst.Options.ContextWindow = llm.ContextWindow(llm.LLMProvider(llmConfig.Provider), llmConfig.Model)
+	prompt := inst.Options.BuildPrompt(inst.Transcription, inst.BoardState)
+
+	result := pipeline.Run(ctx, inst)
+
+	resp := &dto.SandboxInstructionResponse{
+		Provider:      llmConfig.Provider,
+		Model:         llmConfig.Model,
+		BoardState:    boardState,
+		Referents:     inst.Options.Referents,
+		Substitutions: inst.Options.Substitutions,
+		Prompt: dto.SandboxPrompt{
+			Version:         llmConfig.PromptVersion,
+			Mode:            string(mode),
+			System:          systemPrompt,
+			User:            prompt.Text,
+			Tokens:          prompt.Tokens,
+			Sections:        prompt.Sections,
+			Dropped:         prompt.Dropped,
+			CompactionLevel: prompt.CompactionLevel,
+		},
+		Attempts:   make([]dto.SandboxAttempt, 0, len(result.Attempts)),
+		Action:     result.Action,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if resp.Referents == nil {
+		resp.Referents = []llm.Referent{}
+	}
+	if resp.Substitutions == nil {
+		resp.Substitutions = []llm.Substitution{}
+	}
+	for _, attempt := range result.Attempts {
+		resp.Attempts = append(resp.Attempts, dto.SandboxAttempt{
+			Output: attempt.Output,
+			Stage:  attempt.Stage,
+			Error:  attempt.Error,
+			Issues: attempt.Issues,
+		})
+	}
+	if result.Response != nil {
+		resp.Warning = result.Response.Warning
+	}
+	if result.Err != nil {
+		resp.Error = result.Err.Error()
+	}
+	if result.Action != nil {
+		applied, err := whiteboard.ApplyAction(board, result.Action)
+		if err != nil {
+			resp.Error = fmt.Sprintf("failed to apply action: %v", err)
+		} else {
+			resp.AppliedState = applied
+		}
+	}
+	return resp, nil
+}
+
+// sandboxQuota counts sandbox requests per user in fixed per-minute and
+// per-day (UTC) windows. It is kept in memory, so each replica enforces it
+// separately.
+type sandboxQuota struct {
+	perMinute int
+	perDay    int
+
+	mu          sync.Mutex
+	minute      time.Time
+	day         time.Time
+	minuteCount map[string]int
+	dayCount    map[string]int
+}
+
+func newSandboxQuota(perMinute int, perDay int) *sandboxQuota {
+	return &sandboxQuota{
+		perMinute:   perMinute,
+		perDay:      perDay,
+		minuteCount: make(map[string]int),
+		dayCount:    make(map[string]int),
+	}
+}
+
+// allow counts a request for userID, or reports how long until the user may
+// try again.
+func (q *sandboxQuota) allow(userID string, now time.Time) (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now = now.UTC()
+	if minute := now.Truncate(time.Minute); !minute.Equal(q.minute) {
+		q.minute = minute
+		clear(q.minuteCount)
+	}
+	if day := now.Truncate(24 * time.Hour); !day.Equal(q.day) {
+		q.day = day
+		clear(q.dayCount)
+	}
+
+	if q.dayCount[userID] >= q.perDay {
+		return q.day.Add(24 * time.Hour).Sub(now), false
+	}
+	if q.minuteCount[userID] >= q.perMinute {
+		return q.minute.Add(time.Minute).Sub(now), false
+	}
+	q.minuteCount[userID]++
+	q.dayCount[userID]++
+	return 0, true
+}
+
+// systemPrompt returns the system prompt the pipeline sends for version and
+// mode.
+func (s *sandboxService) systemPrompt(version string, mode prompts.Mode) (string, error) {
+	if s.prompts == nil {
+		return prompts.SystemPrompt(mode), nil
+	}
+	return s.prompts.System(version, mode)
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Scopes a service account key can hold. Routes that service accounts may
+// call declare the scope they need; every other route rejects them.
+const (
+	ScopeBoardsRead   = "boards:read"
+	ScopeBoardsWrite  = "boards:write"
+	ScopeInstructions = "instructions:write"
+	ScopeComments     = "comments:write"
+	ScopeDebug        = "debug:read"
+)
+
+var serviceAccountScopes = []string{ScopeBoardsRead, ScopeBoardsWrite, ScopeInstructions, ScopeComments, ScopeDebug}
+
+// serviceAccountKeyPrefix marks service account keys, so a leaked key is
+// recognisable in logs and secret scanners.
+const serviceAccountKeyPrefix = "vpsa_"
+
+var (
+	// ErrServiceAccountNotFound is returned for unknown service account IDs.
+	ErrServiceAccountNotFound = fmt.Errorf("service account %w", ErrNotFound)
+	// ErrInvalidServiceAccountKey is returned for keys that are unknown,
+	// revoked or belong to a disabled service account.
+	ErrInvalidServiceAccountKey = errors.New("service account key is invalid, revoked or disabled")
+)
+
+// ServiceAccountPrincipal is who a service account key authenticates. It
+// acts on its owner's boards, within its scopes.
+type ServiceAccountPrincipal struct {
+	ServiceAccountID uuid.UUID
+	OwnerID          string
+	Name             string
+	Scopes           []string
+}
+
+// ServiceAccountService manages service accounts and their API keys. There
+// are no organisations, so an account belongs to the user who created it.
+type ServiceAccountService interface {
+	CreateServiceAccount(ctx context.Context, req dto.CreateServiceAccountRequest) (*dto.ServiceAccount, error)
+	GetServiceAccounts(ctx context.Context, req dto.GetServiceAccountsRequest) (*dto.GetServiceAccountsResponse, error)
+	RotateKey(ctx context.Context, req dto.RotateServiceAccountKeyRequest) (*dto.ServiceAccount, error)
+	DisableServiceAccount(ctx context.Context, req dto.DisableServiceAccountRequest) error
+	Authenticate(ctx context.Context, key string) (*ServiceAccountPrincipal, error)
+}
+
+type serviceAccountService struct {
+	db      *pgxpool.Pool
+	queries *repo.Queries
+}
+
+func NewServiceAccountService(db *pgxpool.Pool, queries *repo.Queries) ServiceAccountService {
+	return &serviceAccountService{
+		db:      db,
+		queries: queries,
+	}
+}
+
+func (s *serviceAccountService) CreateServiceAccount(ctx context.Context, req dto.CreateServiceAccountRequest) (*dto.ServiceAccount, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	scopes, err := validateScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := s.queries.WithTx(tx)
+
+	account, err := queries.CreateServiceAccount(ctx, repo.CreateServiceAccountParams{
+		OwnerID:     req.UserID,
+		Name:        name,
+		Description: req.Description,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service account: %w", err)
+	}
+	key, err := issueServiceAccountKey(ctx, queries, account.ID, scopes)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	resp := toServiceAccountResponse(account, scopes)
+	resp.Key = key
+	return &resp, nil
+}
+
+func (s *serviceAccountService) GetServiceAccounts(ctx context.Context, req dto.GetServiceAccountsRequest) (*dto.GetServiceAccountsResponse, error) {
+	accounts, err := s.queries.GetServiceAccountsByOwnerID(ctx, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service accounts: %w", err)
+	}
+	keys, err := s.queries.GetActiveServiceAccountKeysByOwnerID(ctx, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account keys: %w", err)
+	}
+	scopes := make(map[uuid.UUID][]string, len(keys))
+	for _, key := range keys {
+		scopes[key.ServiceAccountID] = key.Scopes
+	}
+
+	resp := make([]dto.ServiceAccount, 0, len(accounts))
+	for _, account := range accounts {
+		resp = append(resp, toServiceAccountResponse(account, scopes[account.ID]))
+	}
+	return &dto.GetServiceAccountsResponse{
+		ServiceAccounts: resp,
+	}, nil
+}
+
+// RotateKey issues a new key and revokes the account's previous keys at
+// once, so a leaked key stops working as soon as it is replaced.
+func (s *serviceAccountService) RotateKey(ctx context.Context, req dto.RotateServiceAccountKeyRequest) (*dto.ServiceAccount, error) {
+	id, err := uuid.Parse(req.ServiceAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid service account id", ErrInvalidInput)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := s.queries.WithTx(tx)
+
+	account, err := queries.GetServiceAccount(ctx, repo.GetServiceAccountParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrServiceAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+	if account.DisabledAt != nil {
+		return nil, fmt.Errorf("%w: service account is disabled", ErrConflict)
+	}
+
+	scopes := req.Scopes
+	if scopes == nil {
+		keys, err := queries.GetActiveServiceAccountKeysByOwnerID(ctx, req.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get service account keys: %w", err)
+		}
+		for _, key := range keys {
+			if key.ServiceAccountID == account.ID {
+				scopes = key.Scopes
+			}
+		}
+	}
+	if scopes, err = validateScopes(scopes); err != nil {
+		return nil, err
+	}
+
+	if err := queries.RevokeServiceAccountKeys(ctx, account.ID); err != nil {
+		return nil, fmt.Errorf("failed to revoke service account keys: %w", err)
+	}
+	key, err := issueServiceAccountKey(ctx, queries, account.ID, scopes)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	resp := toServiceAccountResponse(account, scopes)
+	resp.Key = key
+	return &resp, nil
+}
+
+// DisableServiceAccount stops every key of the account from authenticating.
+// The account is kept so what it did stays attributable.
+func (s *serviceAccountService) DisableServiceAccount(ctx context.Context, req dto.DisableServiceAccountRequest) error {
+	id, err := uuid.Parse(req.ServiceAccountID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid service account id", ErrInvalidInput)
+	}
+	rows, err := s.queries.DisableServiceAccount(ctx, repo.DisableServiceAccountParams{
+		ID:      id,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable service account: %w", err)
+	}
+	if rows == 0 {
+		return ErrServiceAccountNotFound
+	}
+	return nil
+}
+
+// Authenticate resolves a service account key to the account it belongs to.
+func (s *serviceAccountService) Authenticate(ctx context.Context, key string) (*ServiceAccountPrincipal, error) {
+	if !strings.HasPrefix(key, serviceAccountKeyPrefix) {
+		return nil, ErrInvalidServiceAccountKey
+	}
+	record, err := s.queries.GetActiveServiceAccountKey(ctx, hashServiceAccountKey(key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidServiceAccountKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up service account key: %w", err)
+	}
+	return &ServiceAccountPrincipal{
+		ServiceAccountID: record.ServiceAccountID,
+		OwnerID:          record.OwnerID,
+		Name:             record.Name,
+		Scopes:           record.Scopes,
+	}, nil
+}
+
+// issueServiceAccountKey stores a new key for the account and returns it.
+// Only its hash is kept.
+func issueServiceAccountKey(ctx context.Context, queries *repo.Queries, accountID uuid.UUID, scopes []string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate service account key: %w", err)
+	}
+	key := serviceAccountKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	if _, err := queries.CreateServiceAccountKey(ctx, repo.CreateServiceAccountKeyParams{
+		ServiceAccountID: accountID,
+		KeyHash:          hashServiceAccountKey(key),
+		Scopes:           scopes,
+	}); err != nil {
+		return "", fmt.Errorf("failed to create service account key: %w", err)
+	}
+	return key, nil
+}
+
+// hashServiceAccountKey is what is stored, so a database leak doesn't leak
+// working keys.
+func hashServiceAccountKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateScopes rejects unknown scopes and returns the rest without
+// duplicates.
+func validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required (%s)", ErrInvalidInput, strings.Join(serviceAccountScopes, ", "))
+	}
+	valid := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(serviceAccountScopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidInput, scope)
+		}
+		if !slices.Contains(valid, scope) {
+			valid = append(valid, scope)
+		}
+	}
+	return valid, nil
+}
+
+func toServiceAccountResponse(account repo.ServiceAccount, scopes []string) dto.ServiceAccount {
+	if scopes == nil {
+		scopes = []string{}
+	}
+	resp := dto.ServiceAccount{
+		ID:          account.ID,
+		OwnerID:     account.OwnerID,
+		Name:        account.Name,
+		Description: account.Description,
+		Scopes:      scopes,
+		CreatedAt:   dto.NewTimestamp(account.CreatedAt),
+	}
+	if account.DisabledAt != nil {
+		disabledAt := dto.NewTimestamp(*account.DisabledAt)
+		resp.DisabledAt = &disabledAt
+	}
+	return resp
+}
@@ -1,21 +1,76 @@
 package service
 
 import (
+	"errors"
+
+	"draw/internal/db/encrypted"
 	"draw/internal/db/repo"
 	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llm/prompts"
+	"draw/pkg/llmdebug"
+	"draw/pkg/storage"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrInvalidInput marks errors caused by bad request data rather than a
+// failure on our side.
+var ErrInvalidInput = errors.New("invalid input")
+
+// ErrNotFound marks lookups of things that don't exist (or no longer do).
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict marks requests that no longer apply to the current state.
+var ErrConflict = errors.New("conflict")
+
+// ErrGone marks requests for something that existed but has ended, such as a
+// board's room after everyone left.
+var ErrGone = errors.New("gone")
+
 type Service struct {
-	UserService  UserService
-	BoardService BoardService
+	UserService           UserService
+	BoardService          BoardService
+	InstructionService    InstructionService
+	PendingChangeService  PendingChangeService
+	CommentService        CommentService
+	ViewService           ViewService
+	ImageService          ImageService
+	ModerationService     ModerationService
+	PresentationService   PresentationService
+	AnalyticsService      AnalyticsService
+	SandboxService        SandboxService
+	DemoService           DemoService
+	ServiceAccountService ServiceAccountService
+	DebugService          DebugService
 }
 
-func NewService(db *pgxpool.Pool, queries *repo.Queries, cfg *config.AppConfig) *Service {
+func NewService(db *pgxpool.Pool, queries *repo.Queries, encryptedDB *encrypted.DB, cfg *config.AppConfig, sessions *livekit.SessionManager, timings TimingQueue, history *llmdebug.History, registry *prompts.Registry) *Service {
+	instructionService := NewInstructionService(db, queries, cfg, timings)
+	commentService := NewCommentService(db, queries, cfg, sessions)
+	pendingChangeService := NewPendingChangeService(db, queries, encryptedDB, cfg, sessions, commentService)
+	viewService := NewViewService(db, queries, cfg, sessions)
 	return &Service{
-		UserService:  NewUserService(db, queries),
-		BoardService: NewBoardService(db, queries, cfg),
+		UserService:           NewUserService(db, queries),
+		BoardService:          NewBoardService(db, queries, encryptedDB, cfg, sessions, instructionService, pendingChangeService, commentService, viewService, history),
+		InstructionService:    instructionService,
+		PendingChangeService:  pendingChangeService,
+		CommentService:        commentService,
+		ViewService:           viewService,
+		ImageService:          NewImageService(db, queries, cfg, storage.NewS3(cfg.AWS)),
+		ModerationService:     NewModerationService(queries, sessions),
+		PresentationService:   NewPresentationService(queries, sessions),
+		AnalyticsService:      NewAnalyticsService(queries, cfg),
+		SandboxService:        NewSandboxService(cfg, registry),
+		DemoService:           NewDemoService(db, queries, encryptedDB, cfg, registry),
+		ServiceAccountService: NewServiceAccountService(db, queries),
+		DebugService:          NewDebugService(queries, cfg, history),
 	}
 
 }
+
+// Close releases what the services hold for the life of the server, such as
+// the demo's model client.
+func (s *Service) Close() error {
+	return s.DemoService.Close()
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+	"draw/pkg/config"
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is Postgres' unique_violation error code.
+const uniqueViolation = "23505"
+
+// ErrViewNotFound is returned when a saved view doesn't exist on the board.
+var ErrViewNotFound = fmt.Errorf("view %w", ErrNotFound)
+
+// ViewService manages named camera positions on a board and broadcasts
+// navigate events that move clients to them.
+type ViewService interface {
+	GetViews(ctx context.Context, req dto.GetViewsRequest) (*dto.GetViewsResponse, error)
+	CreateView(ctx context.Context, req dto.SaveViewRequest) (*dto.View, error)
+	UpdateView(ctx context.Context, req dto.SaveViewRequest) (*dto.View, error)
+	DeleteView(ctx context.Context, req dto.ViewRequest) error
+	Navigate(ctx context.Context, req dto.ViewRequest) (*dto.NavigateEvent, error)
+	NavigateByName(ctx context.Context, boardID string, userID string, phrase string) (bool, error)
+}
+
+type viewService struct {
+	queries  *repo.Queries
+	db       *pgxpool.Pool
+	config   *config.AppConfig
+	sessions *livekit.SessionManager
+}
+
+func NewViewService(
+	db *pgxpool.Pool,
+	queries *repo.Queries,
+	config *config.AppConfig,
+	sessions *livekit.SessionManager,
+) ViewService {
+	return &viewService{
+		db:       db,
+		queries:  queries,
+		config:   config,
+		sessions: sessions,
+	}
+}
+
+func (s *viewService) GetViews(ctx context.Context, req dto.GetViewsRequest) (*dto.GetViewsResponse, error) {
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	views, err := s.queries.GetViewsByBoardID(ctx, board.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get views: %w", err)
+	}
+
+	resp := make([]dto.View, 0, len(views))
+	for _, view := range views {
+		resp = append(resp, toViewResponse(view))
+	}
+	return &dto.GetViewsResponse{
+		Views: resp,
+	}, nil
+}
+
+func (s *viewService) CreateView(ctx context.Context, req dto.SaveViewRequest) (*dto.View, error) {
+	if err := validateView(&req); err != nil {
+		return nil, err
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+
+	view, err := s.queries.CreateView(ctx, repo.CreateViewParams{
+		BoardID:   board.ID,
+		Name:      req.Name,
+		X:         req.X,
+		Y:         req.Y,
+		Zoom:      req.Zoom,
+		Width:     req.Width,
+		Height:    req.Height,
+		FrameID:   req.FrameID,
+		CreatedBy: req.UserID,
+	})
+	if err != nil {
+		return nil, viewWriteError("create", req.Name, err)
+	}
+
+	resp := toViewResponse(view)
+	return &resp, nil
+}
+
+func (s *viewService) UpdateView(ctx context.Context, req dto.SaveViewRequest) (*dto.View, error) {
+	viewID, err := uuid.Parse(req.ViewID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid view id", ErrInvalidInput)
+	}
+	if err := validateView(&req); err != nil {
+		return nil, err
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotArchived(board); err != nil {
+		return nil, err
+	}
+
+	view, err := s.queries.UpdateView(ctx, repo.UpdateViewParams{
+		ID:      viewID,
+		BoardID: board.ID,
+		Name:    req.Name,
+		X:       req.X,
+		Y:       req.Y,
+		Zoom:    req.Zoom,
+		Width:   req.Width,
+		Height:  req.Height,
+		FrameID: req.FrameID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrViewNotFound
+	}
+	if err != nil {
+		return nil, viewWriteError("update", req.Name, err)
+	}
+
+	resp := toViewResponse(view)
+	return &resp, nil
+}
+
+func (s *viewService) DeleteView(ctx context.Context, req dto.ViewRequest) error {
+	viewID, err := uuid.Parse(req.ViewID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid view id", ErrInvalidInput)
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotArchived(board); err != nil {
+		return err
+	}
+
+	rows, err := s.queries.DeleteView(ctx, repo.DeleteViewParams{
+		ID:      viewID,
+		BoardID: board.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete view: %w", err)
+	}
+	if rows == 0 {
+		return ErrViewNotFound
+	}
+	return nil
+}
+
+// Navigate broadcasts the view's current viewport to everyone on the board.
+func (s *viewService) Navigate(ctx context.Context, req dto.ViewRequest) (*dto.NavigateEvent, error) {
+	viewID, err := uuid.Parse(req.ViewID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid view id", ErrInvalidInput)
+	}
+
+	board, err := s.getBoard(ctx, req.BoardID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	view, err := s.queries.GetViewByID(ctx, repo.GetViewByIDParams{
+		ID:      viewID,
+		BoardID: board.ID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrViewNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get view: %w", err)
+	}
+
+	return s.navigate(board, view, req.MutationID)
+}
+
+// NavigateByName navigates to the saved view a spoken phrase refers to. It
+// reports false when no view matches, so the instruction can be handled some
+// other way.
+func (s *viewService) NavigateByName(ctx context.Context, boardID string, userID string, phrase string) (bool, error) {
+	board, err := s.getBoard(ctx, boardID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	views, err := s.queries.GetViewsByBoardID(ctx, board.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get views: %w", err)
+	}
+	names := make([]string, len(views))
+	for i, view := range views {
+		names[i] = view.Name
+	}
+
+	match := whiteboard.MatchViewName(phrase, names)
+	if match < 0 {
+		return false, nil
+	}
+	if _, err := s.navigate(board, views[match], ""); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+func (s *viewService) navigate(board repo.Board, view repo.BoardView, mutationID string) (*dto.NavigateEvent, error) {
+	viewport, err := resolveViewport(board, view)
+	if err != nil {
+		return nil, err
+	}
+
+	event := dto.NavigateEvent{
+		ViewID:   view.ID,
+		Name:     view.Name,
+		Viewport: viewport,
+	}
+	s.sessions.Publish(board.ID.String(), livekit.StreamTextData{
+		Type:       "navigate",
+		Data:       event,
+		MutationID: mutationID,
+	})
+	return &event, nil
+}
+
+// resolveViewport returns where a view points now. Views that follow a frame
+// use the frame's current bounding box.
+func resolveViewport(board repo.Board, view repo.BoardView) (whiteboard.Viewport, error) {
+	if view.FrameID != nil {
+		var elements []llm.Element
+		if board.Elements != nil {
+			if err := json.Unmarshal(board.Elements, &elements); err != nil {
+				return whiteboard.Viewport{}, fmt.Errorf("failed to parse board elements: %w", err)
+			}
+		}
+		viewport, err := whiteboard.FrameViewport(*view.FrameID, elements)
+		if err != nil {
+			return whiteboard.Viewport{}, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return viewport, nil
+	}
+
+	if view.X == nil || view.Y == nil {
+		return whiteboard.Viewport{}, fmt.Errorf("view %q has no position", view.Name)
+	}
+	return whiteboard.Viewport{
+		X:      *view.X,
+		Y:      *view.Y,
+		Zoom:   view.Zoom,
+		Width:  view.Width,
+		Height: view.Height,
+	}, nil
+}
+
+// validateView checks that the view stores exactly one kind of position.
+func validateView(req *dto.SaveViewRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("%w: view name is empty", ErrInvalidInput)
+	}
+
+	if req.FrameID != nil {
+		if *req.FrameID == "" {
+			return fmt.Errorf("%w: frameId is empty", ErrInvalidInput)
+		}
+		if req.X != nil || req.Y != nil || req.Zoom != nil || req.Width != nil || req.Height != nil {
+			return fmt.Errorf("%w: a view follows either a frame or fixed coordinates, not both", ErrInvalidInput)
+		}
+		return nil
+	}
+
+	if req.X == nil || req.Y == nil {
+		return fmt.Errorf("%w: a view needs x and y, or a frameId", ErrInvalidInput)
+	}
+	if req.Zoom != nil && *req.Zoom <= 0 {
+		return fmt.Errorf("%w: zoom must be positive", ErrInvalidInput)
+	}
+	if (req.Width == nil) != (req.Height == nil) {
+		return fmt.Errorf("%w: width and height must be given together", ErrInvalidInput)
+	}
+	if req.Width != nil && (*req.Width <= 0 || *req.Height <= 0) {
+		return fmt.Errorf("%w: width and height must be positive", ErrInvalidInput)
+	}
+	return nil
+}
+
+func viewWriteError(op string, name string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: a view named %q already exists on this board", ErrConflict, name)
+	}
+	return fmt.Errorf("failed to %s view: %w", op, err)
+}
+
+// getBoard loads the board, which also checks that the user owns it.
+func (s *viewService) getBoard(ctx context.Context, boardID string, userID string) (repo.Board, error) {
+	id, err := uuid.Parse(boardID)
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("%w: invalid board id", ErrInvalidInput)
+	}
+	board, err := s.queries.GetBoardByID(ctx, repo.GetBoardByIDParams{
+		ID:      id,
+		OwnerID: userID,
+	})
+	if err != nil {
+		return repo.Board{}, fmt.Errorf("failed to get board: %w", err)
+	}
+	return board, nil
+}
+
+func toViewResponse(view repo.BoardView) dto.View {
+	return dto.View{
+		ID:        view.ID,
+		BoardID:   view.BoardID,
+		Name:      view.Name,
+		X:         view.X,
+		Y:         view.Y,
+		Zoom:      view.Zoom,
+		Width:     view.Width,
+		Height:    view.Height,
+		FrameID:   view.FrameID,
+		CreatedBy: view.CreatedBy,
+		CreatedAt: dto.NewTimestamp(view.CreatedAt),
+	}
+}
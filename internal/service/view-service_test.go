@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"draw/internal/db/repo"
+	"draw/internal/dto"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestResolveViewport(t *testing.T) {
+	frame := "arch"
+	board := func(elements string) repo.Board {
+		return repo.Board{ID: uuid.New(), OwnerID: "u1", Elements: []byte(elements)}
+	}
+	tests := []struct {
+		name  string
+		board repo.Board
+		view  repo.BoardView
+		want  string
+		err   error
+	}{
+		{
+			name:  "fixed point",
+			board: board(`[]`),
+			view:  repo.BoardView{Name: "start", X: float(10), Y: float(20), Zoom: float(2)},
+			want:  "10,20 zoom 2",
+		},
+		{
+			name:  "fixed box",
+			board: board(`[]`),
+			view:  repo.BoardView{Name: "box", X: float(10), Y: float(20), Width: float(300), Height: float(200)},
+			want:  "10,20 300x200",
+		},
+		{
+			// Saved coordinates are ignored; the frame is where it is now.
+			name:  "frame moved since it was saved",
+			board: board(`[{"id":"arch","type":"frame","x":500,"y":-100,"width":640,"height":480}]`),
+			view:  repo.BoardView{Name: "architecture", FrameID: &frame, X: float(0), Y: float(0)},
+			want:  "500,-100 640x480",
+		},
+		{
+			name:  "frame removed",
+			board: board(`[{"id":"other","type":"frame","x":0,"y":0,"width":10,"height":10}]`),
+			view:  repo.BoardView{Name: "architecture", FrameID: &frame},
+			err:   ErrConflict,
+		},
+		{
+			name:  "frame on a board without elements",
+			board: repo.Board{ID: uuid.New(), OwnerID: "u1"},
+			view:  repo.BoardView{Name: "architecture", FrameID: &frame},
+			err:   ErrConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveViewport(tt.board, tt.view)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %+v, %v, want %v", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveViewport: %v", err)
+			}
+			desc := fmt.Sprintf("%g,%g", got.X, got.Y)
+			if got.Zoom != nil {
+				desc += fmt.Sprintf(" zoom %g", *got.Zoom)
+			}
+			if got.Width != nil && got.Height != nil {
+				desc += fmt.Sprintf(" %gx%g", *got.Width, *got.Height)
+			}
+			if desc != tt.want {
+				t.Errorf("got %s, want %s", desc, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateView(t *testing.T) {
+	frame, empty := "arch", ""
+	tests := []struct {
+		name string
+		req  dto.SaveViewRequest
+		ok   bool
+	}{
+		{name: "point", req: dto.SaveViewRequest{Name: "start", X: float(0), Y: float(0)}, ok: true},
+		{name: "point with zoom", req: dto.SaveViewRequest{Name: "start", X: float(0), Y: float(0), Zoom: float(1.5)}, ok: true},
+		{name: "box", req: dto.SaveViewRequest{Name: "start", X: float(0), Y: float(0), Width: float(10), Height: float(10)}, ok: true},
+		{name: "frame", req: dto.SaveViewRequest{Name: "architecture", FrameID: &frame}, ok: true},
+		{name: "blank name", req: dto.SaveViewRequest{Name: "  ", X: float(0), Y: float(0)}},
+		{name: "no position", req: dto.SaveViewRequest{Name: "start"}},
+		{name: "only x", req: dto.SaveViewRequest{Name: "start", X: float(0)}},
+		{name: "frame and coordinates", req: dto.SaveViewRequest{Name: "architecture", FrameID: &frame, X: float(0), Y: float(0)}},
+		{name: "empty frame id", req: dto.SaveViewRequest{Name: "architecture", FrameID: &empty}},
+		{name: "zero zoom", req: dto.SaveViewRequest{Name: "start", X: float(0), Y: float(0), Zoom: float(0)}},
+		{name: "width without height", req: dto.SaveViewRequest{Name: "start", X: float(0), Y: float(0), Width: float(10)}},
+		{name: "negative size", req: dto.SaveViewRequest{Name: "start", X: float(0), Y: float(0), Width: float(-10), Height: float(10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateView(&tt.req)
+			if tt.ok && err != nil {
+				t.Errorf("got %v, want the view accepted", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("got %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+
+	req := dto.SaveViewRequest{Name: "  Architecture ", FrameID: &frame}
+	if err := validateView(&req); err != nil || req.Name != "Architecture" {
+		t.Errorf("got %q, %v, want the name trimmed", req.Name, err)
+	}
+}
+
+func TestViewWriteError(t *testing.T) {
+	other := errors.New("connection reset")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "duplicate name", err: &pgconn.PgError{Code: uniqueViolation}, want: ErrConflict},
+		{name: "other error", err: other, want: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := viewWriteError("create", "Architecture", tt.err)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if tt.want == ErrConflict && !strings.Contains(err.Error(), `"Architecture" already exists`) {
+				t.Errorf("got %q, want it to name the duplicate", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsHandler struct {
+	analyticsService service.AnalyticsService
+}
+
+func NewAnalyticsHandler(analyticsService service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsService: analyticsService,
+	}
+}
+
+func (h *AnalyticsHandler) GetIntentAnalytics(c *gin.Context) {
+	var req dto.IntentAnalyticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	resp, err := h.analyticsService.GetIntentAnalytics(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to get intent analytics", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Intent analytics fetched",
+		Data:    resp,
+	})
+}
+
+func (h *AnalyticsHandler) GetSlowInstructions(c *gin.Context) {
+	var req dto.SlowInstructionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	resp, err := h.analyticsService.GetSlowInstructions(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to get slow instructions", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Slow instructions fetched",
+		Data:    resp,
+	})
+}
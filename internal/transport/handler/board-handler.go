@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"bytes"
 	"draw/internal/dto"
 	"draw/internal/service"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -20,7 +24,8 @@ func NewBoardHandler(boardService service.BoardService) *BoardHandler {
 
 func (h *BoardHandler) CreateBoard(c *gin.Context) {
 	var req dto.CreateBoardRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// The body is optional.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
 			Message: "Invalid request",
 			Error:   err.Error(),
@@ -30,10 +35,7 @@ func (h *BoardHandler) CreateBoard(c *gin.Context) {
 	req.UserID = c.MustGet("userId").(string)
 	board, err := h.boardService.CreateBoard(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
-			Message: "Failed to create board",
-			Error:   err.Error(),
-		})
+		respondError(c, "Failed to create board", err)
 		return
 	}
 	c.JSON(http.StatusOK, dto.SuccessResponse{
@@ -46,8 +48,11 @@ func (h *BoardHandler) GetBoard(c *gin.Context) {
 	boardId := c.Param("id")
 	userId := c.MustGet("userId").(string)
 	board, err := h.boardService.GetBoard(c.Request.Context(), dto.GetBoardRequest{
-		BoardID: boardId,
-		UserID:  userId,
+		BoardID:              boardId,
+		UserID:               userId,
+		ServiceAccountID:     c.GetString("serviceAccountId"),
+		ServiceAccount:       c.GetString("serviceAccountName"),
+		ServiceAccountScopes: c.GetStringSlice("serviceAccountScopes"),
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
@@ -63,10 +68,16 @@ func (h *BoardHandler) GetBoard(c *gin.Context) {
 }
 
 func (h *BoardHandler) GetBoardsByUserID(c *gin.Context) {
-	userId := c.MustGet("userId").(string)
-	boards, err := h.boardService.GetBoardsByUserID(c.Request.Context(), dto.GetBoardsByUserIDRequest{
-		UserID: userId,
-	})
+	var req dto.GetBoardsByUserIDRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.UserID = c.MustGet("userId").(string)
+	boards, err := h.boardService.GetBoardsByUserID(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 			Message: "Failed to get boards",
@@ -91,12 +102,10 @@ func (h *BoardHandler) UpdateBoard(c *gin.Context) {
 	}
 	req.BoardID = c.Param("id")
 	req.UserID = c.MustGet("userId").(string)
+	req.MutationID = mutationID(c)
 	resp, err := h.boardService.UpdateBoard(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
-			Message: "Failed to update board",
-			Error:   err.Error(),
-		})
+		respondError(c, "Failed to update board", err)
 		return
 	}
 	c.JSON(http.StatusOK, dto.SuccessResponse{
@@ -114,13 +123,205 @@ func (h *BoardHandler) DeleteBoard(c *gin.Context) {
 		UserID:  userId,
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
-			Message: "Failed to delete board",
+		respondError(c, "Failed to delete board", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Board deleted",
+	})
+}
+
+// ArchiveBoard makes the board read-only and hides it from the board list.
+func (h *BoardHandler) ArchiveBoard(c *gin.Context) {
+	board, err := h.boardService.ArchiveBoard(c.Request.Context(), dto.ArchiveBoardRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to archive board", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Board archived",
+		Data:    board,
+	})
+}
+
+func (h *BoardHandler) UnarchiveBoard(c *gin.Context) {
+	board, err := h.boardService.UnarchiveBoard(c.Request.Context(), dto.ArchiveBoardRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to unarchive board", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Board unarchived",
+		Data:    board,
+	})
+}
+
+func (h *BoardHandler) ApplyPartial(c *gin.Context) {
+	err := h.boardService.ApplyPartial(c.Request.Context(), dto.ApplyPartialRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+		Token:   c.Param("token"),
+	})
+	if err != nil {
+		respondError(c, "Failed to apply partial action", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Partial action applied",
+	})
+}
+
+func (h *BoardHandler) UndoLast(c *gin.Context) {
+	err := h.boardService.UndoLast(c.Request.Context(), dto.UndoRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to undo", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Last instruction undone",
+	})
+}
+
+func (h *BoardHandler) ConfirmAction(c *gin.Context) {
+	err := h.boardService.ConfirmAction(c.Request.Context(), dto.ConfirmActionRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+		Token:   c.Param("token"),
+	})
+	if err != nil {
+		respondError(c, "Failed to confirm action", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Action confirmed",
+	})
+}
+
+// StreamSpeech accepts raw 16 kHz mono PCM16 audio, typically sent with
+// chunked transfer encoding, and transcribes it as it arrives.
+func (h *BoardHandler) StreamSpeech(c *gin.Context) {
+	resp, err := h.boardService.StreamSpeech(c.Request.Context(), dto.StreamSpeechRequest{
+		BoardID:          c.Param("id"),
+		UserID:           c.MustGet("userId").(string),
+		ServiceAccountID: c.GetString("serviceAccountId"),
+		Audio:            c.Request.Body,
+	})
+	if err != nil {
+		respondError(c, "Failed to transcribe speech", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Speech transcribed",
+		Data:    resp,
+	})
+}
+
+// maxBundleSize caps the size of an imported board bundle.
+const maxBundleSize = 50 << 20
+
+// ExportBoard returns the board as a bundle, or in the format ?format names:
+// "mermaid" for a Mermaid flowchart in plain text, "excalidraw" for an
+// .excalidraw file and "svg" for an SVG picture.
+func (h *BoardHandler) ExportBoard(c *gin.Context) {
+	var buf bytes.Buffer
+	resp, err := h.boardService.ExportBoard(c.Request.Context(), dto.ExportBoardRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+		Format:  c.Query("format"),
+	}, &buf)
+	if err != nil {
+		respondError(c, "Failed to export board", err)
+		return
+	}
+	if resp.Filename != "" {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": resp.Filename}))
+	}
+	c.Data(http.StatusOK, resp.ContentType, buf.Bytes())
+}
+
+// ImportBoard takes a bundle produced by ExportBoard as the request body.
+func (h *BoardHandler) ImportBoard(c *gin.Context) {
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBundleSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
 			Error:   err.Error(),
 		})
 		return
 	}
+	resp, err := h.boardService.ImportBoard(c.Request.Context(), dto.ImportBoardRequest{
+		UserID: c.MustGet("userId").(string),
+		Bundle: data,
+	})
+	if err != nil {
+		respondError(c, "Failed to import board", err)
+		return
+	}
 	c.JSON(http.StatusOK, dto.SuccessResponse{
-		Message: "Board deleted",
+		Message: "Board imported",
+		Data:    resp,
 	})
-}
\ No newline at end of file
+}
+
+// ImportMermaid adds a Mermaid flowchart to the board and returns the
+// elements it became.
+func (h *BoardHandler) ImportMermaid(c *gin.Context) {
+	var req dto.ImportMermaidRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.BoardID = c.Param("id")
+	req.UserID = c.MustGet("userId").(string)
+
+	resp, err := h.boardService.ImportMermaid(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to import flowchart", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Flowchart imported",
+		Data:    resp,
+	})
+}
+
+// InstantiateTemplate adds the template :name names to the board and returns
+// the elements it became. The body, which may be empty, can give an origin
+// and params.
+func (h *BoardHandler) InstantiateTemplate(c *gin.Context) {
+	var req dto.InstantiateTemplateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Message: "Invalid request",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	req.BoardID = c.Param("id")
+	req.UserID = c.MustGet("userId").(string)
+	req.Name = c.Param("name")
+
+	resp, err := h.boardService.InstantiateTemplate(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to insert template", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Template inserted",
+		Data:    resp,
+	})
+}
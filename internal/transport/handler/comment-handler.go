@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommentHandler struct {
+	commentService service.CommentService
+}
+
+func NewCommentHandler(commentService service.CommentService) *CommentHandler {
+	return &CommentHandler{
+		commentService: commentService,
+	}
+}
+
+func (h *CommentHandler) GetComments(c *gin.Context) {
+	resp, err := h.commentService.GetComments(c.Request.Context(), dto.GetCommentsRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to get comments", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Comments fetched",
+		Data:    resp,
+	})
+}
+
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	var req dto.CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.BoardID = c.Param("id")
+	req.UserID = c.MustGet("userId").(string)
+	req.MutationID = mutationID(c)
+
+	resp, err := h.commentService.CreateComment(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to create comment", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Comment created",
+		Data:    resp,
+	})
+}
+
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	var req dto.UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.BoardID = c.Param("id")
+	req.CommentID = c.Param("commentId")
+	req.UserID = c.MustGet("userId").(string)
+	req.MutationID = mutationID(c)
+
+	resp, err := h.commentService.UpdateComment(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to update comment", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Comment updated",
+		Data:    resp,
+	})
+}
+
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	err := h.commentService.DeleteComment(c.Request.Context(), dto.DeleteCommentRequest{
+		BoardID:    c.Param("id"),
+		CommentID:  c.Param("commentId"),
+		UserID:     c.MustGet("userId").(string),
+		MutationID: mutationID(c),
+	})
+	if err != nil {
+		respondError(c, "Failed to delete comment", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Comment deleted",
+	})
+}
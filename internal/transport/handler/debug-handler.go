@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DebugHandler struct {
+	debugService service.DebugService
+}
+
+func NewDebugHandler(debugService service.DebugService) *DebugHandler {
+	return &DebugHandler{
+		debugService: debugService,
+	}
+}
+
+// GetLLMHistory returns the board's last few model interactions.
+func (h *DebugHandler) GetLLMHistory(c *gin.Context) {
+	resp, err := h.debugService.GetLLMHistory(c.Request.Context(), dto.GetLLMHistoryRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to get LLM history", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "LLM history fetched",
+		Data:    resp,
+	})
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"io"
+
+	"draw/internal/dto"
+	"draw/internal/service"
+	"draw/pkg/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DemoHandler struct {
+	demoService service.DemoService
+}
+
+func NewDemoHandler(demoService service.DemoService) *DemoHandler {
+	return &DemoHandler{
+		demoService: demoService,
+	}
+}
+
+func (h *DemoHandler) CreateBoard(c *gin.Context) {
+	var req dto.CreateDemoBoardRequest
+	// The body is optional.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.SessionID = c.MustGet("demoSessionId").(string)
+	board, err := h.demoService.CreateBoard(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to create demo board", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Demo board created",
+		Data:    board,
+	})
+}
+
+func (h *DemoHandler) GetBoard(c *gin.Context) {
+	board, err := h.demoService.GetBoard(c.Request.Context(), dto.GetDemoBoardRequest{
+		SessionID: c.MustGet("demoSessionId").(string),
+		BoardID:   c.Param("id"),
+	})
+	if err != nil {
+		respondError(c, "Failed to get demo board", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Demo board fetched",
+		Data:    board,
+	})
+}
+
+func (h *DemoHandler) UpdateBoard(c *gin.Context) {
+	var req dto.UpdateDemoBoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.SessionID = c.MustGet("demoSessionId").(string)
+	req.BoardID = c.Param("id")
+	board, err := h.demoService.UpdateBoard(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to update demo board", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Demo board updated",
+		Data:    board,
+	})
+}
+
+func (h *DemoHandler) RunInstruction(c *gin.Context) {
+	var req dto.DemoInstructionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.SessionID = c.MustGet("demoSessionId").(string)
+	req.BoardID = c.Param("id")
+	resp, err := h.demoService.RunInstruction(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to run demo instruction", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Demo instruction run",
+		Data:    resp,
+	})
+}
+
+// ClaimBoard is called by a signed-in user, so the demo session comes from
+// the cookie rather than the demo middleware.
+func (h *DemoHandler) ClaimBoard(c *gin.Context) {
+	session, _ := c.Cookie(auth.DemoSessionCookie)
+	board, err := h.demoService.ClaimBoard(c.Request.Context(), dto.ClaimDemoBoardRequest{
+		UserID:  c.MustGet("userId").(string),
+		BoardID: c.Param("id"),
+		Session: session,
+	})
+	if err != nil {
+		respondError(c, "Failed to claim demo board", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Demo board claimed",
+		Data:    board,
+	})
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"draw/pkg/encryption"
+	"draw/pkg/llm"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError writes the error envelope with the status for err. Throttled
+// and unavailable errors also get a Retry-After header and retry guidance.
+func respondError(c *gin.Context, message string, err error) {
+	resp := dto.ErrorResponse{
+		Message: message,
+		Error:   err.Error(),
+	}
+	if errors.Is(err, encryption.ErrUnavailable) {
+		// Clients match on the code at the start of the message.
+		resp.Error = encryption.ErrUnavailable.Error()
+	}
+	if errors.Is(err, service.ErrBoardArchived) {
+		resp.Error = service.ErrBoardArchived.Error()
+	}
+
+	var retryErr *service.RetryError
+	if errors.As(err, &retryErr) {
+		resp.Retry = retryGuidance(retryErr)
+		if retryErr.Retryable {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryErr.After.Seconds()))))
+		}
+	}
+
+	c.JSON(errorStatus(err), resp)
+}
+
+// retryGuidance adds up to 50% jitter to the recommended wait.
+func retryGuidance(err *service.RetryError) *dto.RetryGuidance {
+	guidance := &dto.RetryGuidance{
+		Retryable: err.Retryable,
+		Resource:  err.Resource,
+	}
+	if err.Retryable {
+		after := err.After.Milliseconds()
+		guidance.AfterMs = after
+		guidance.JitteredAfterMs = after + rand.Int64N(after/2+1)
+	}
+	return guidance
+}
+
+// errorStatus maps service errors to HTTP status codes. Errors from the LLM
+// layer that the service passed on unclassified get llm's own mapping.
+func errorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidInput) || errors.Is(err, encryption.ErrReservedPrefix) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, service.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, service.ErrConflict) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, service.ErrGone) {
+		return http.StatusGone
+	}
+	if errors.Is(err, service.ErrThrottled) {
+		return http.StatusTooManyRequests
+	}
+	if errors.Is(err, service.ErrUnavailable) || errors.Is(err, encryption.ErrUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return llm.HTTPStatusForLLMError(err)
+}
+
+// maxMutationIDLength bounds the client-chosen ID echoed into broadcasts.
+const maxMutationIDLength = 128
+
+// mutationID returns the request's X-Mutation-ID header. Clients set it on
+// writes they have already applied locally; the broadcast the write causes
+// carries it back, so they can tell their own change from someone else's.
+// IDs that are too long are dropped rather than truncated, since a truncated
+// ID would never match.
+func mutationID(c *gin.Context) string {
+	id := c.GetHeader("X-Mutation-ID")
+	if len(id) > maxMutationIDLength {
+		return ""
+	}
+	return id
+}
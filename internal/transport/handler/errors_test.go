@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"draw/internal/dto"
+	"draw/internal/service"
+	"draw/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondErrorBoardArchived(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "archived board", err: service.ErrBoardArchived, status: http.StatusConflict, code: "board_archived"},
+		{name: "wrapped archived board", err: fmt.Errorf("failed to add comment: %w", service.ErrBoardArchived), status: http.StatusConflict, code: "board_archived"},
+		{name: "legal hold", err: service.ErrLegalHold, status: http.StatusConflict},
+		{name: "other conflict", err: fmt.Errorf("%w: the board changed", service.ErrConflict), status: http.StatusConflict},
+		{name: "not found", err: fmt.Errorf("board %w", service.ErrNotFound), status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respondError(c, "Failed to update board", tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			var resp dto.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			archived := strings.HasPrefix(resp.Error, "board_archived: ")
+			if archived != (tt.code == "board_archived") {
+				t.Errorf("error = %q, want the board_archived code only for archived boards", resp.Error)
+			}
+			if archived && resp.Error != service.ErrBoardArchived.Error() {
+				t.Errorf("error = %q, want %q without the wrapping", resp.Error, service.ErrBoardArchived.Error())
+			}
+		})
+	}
+}
+
+// TestRespondErrorRetryContract pins the retry object clients back off by,
+// across every throttling path.
+func TestRespondErrorRetryContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		retryAfter string
+		resource   string
+		afterMs    int64
+		retryable  bool
+	}{
+		{
+			name:       "llm queue full",
+			err:        &service.RetryError{Kind: service.ErrThrottled, Resource: "llm", After: 2 * time.Second, Retryable: true, Err: llm.ErrQueueFull},
+			status:     http.StatusTooManyRequests,
+			retryAfter: "2",
+			resource:   "llm",
+			afterMs:    2000,
+			retryable:  true,
+		},
+		{
+			name:       "llm rate limit rounds the header up",
+			err:        fmt.Errorf("failed to run instruction: %w", &service.RetryError{Kind: service.ErrThrottled, Resource: "llm", After: 1500 * time.Millisecond, Retryable: true, Err: errors.New("rate limited")}),
+			status:     http.StatusTooManyRequests,
+			retryAfter: "2",
+			resource:   "llm",
+			afterMs:    1500,
+			retryable:  true,
+		},
+		{
+			name:       "sandbox quota",
+			err:        &service.RetryError{Kind: service.ErrThrottled, Resource: "sandbox", After: time.Minute, Retryable: true, Err: errors.New("sandbox quota reached")},
+			status:     http.StatusTooManyRequests,
+			retryAfter: "60",
+			resource:   "sandbox",
+			afterMs:    60000,
+			retryable:  true,
+		},
+		{
+			name:       "llm unavailable",
+			err:        &service.RetryError{Kind: service.ErrUnavailable, Resource: "llm", After: 5 * time.Second, Retryable: true, Err: llm.ErrProviderUnavailable},
+			status:     http.StatusServiceUnavailable,
+			retryAfter: "5",
+			resource:   "llm",
+			afterMs:    5000,
+			retryable:  true,
+		},
+		{
+			name:     "demo instructions used up",
+			err:      &service.RetryError{Kind: service.ErrThrottled, Resource: "demo", Err: errors.New("used its instructions")},
+			status:   http.StatusTooManyRequests,
+			resource: "demo",
+		},
+		{
+			name:     "speech unsupported",
+			err:      &service.RetryError{Kind: service.ErrUnavailable, Resource: "speech", Err: errors.New("unimplemented")},
+			status:   http.StatusServiceUnavailable,
+			resource: "speech",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respondError(c, "Failed to run instruction", tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			var retry map[string]any
+			if err := json.Unmarshal(body["retry"], &retry); err != nil {
+				t.Fatalf("retry = %s, want an object: %v", body["retry"], err)
+			}
+			keys := make([]string, 0, len(retry))
+			for key := range retry {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if got, want := strings.Join(keys, ","), "afterMs,jitteredAfterMs,resource,retryable"; got != want {
+				t.Fatalf("retry fields = %s, want %s", got, want)
+			}
+			if retry["resource"] != tt.resource || retry["retryable"] != tt.retryable || retry["afterMs"] != float64(tt.afterMs) {
+				t.Errorf("retry = %v, want resource %s, retryable %v, afterMs %d", retry, tt.resource, tt.retryable, tt.afterMs)
+			}
+			jittered := int64(retry["jitteredAfterMs"].(float64))
+			if jittered < tt.afterMs || jittered > tt.afterMs+tt.afterMs/2 {
+				t.Errorf("jitteredAfterMs = %d, want between %d and %d", jittered, tt.afterMs, tt.afterMs+tt.afterMs/2)
+			}
+		})
+	}
+}
+
+func TestRespondErrorWithoutRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, err := range []error{service.ErrNotFound, fmt.Errorf("%w: bad", service.ErrInvalidInput), errors.New("boom")} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondError(c, "Failed", err)
+		if strings.Contains(w.Body.String(), `"retry"`) || w.Header().Get("Retry-After") != "" {
+			t.Errorf("%v: got %s with Retry-After %q, want no retry guidance", err, w.Body.String(), w.Header().Get("Retry-After"))
+		}
+	}
+}
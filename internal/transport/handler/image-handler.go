@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImageHandler struct {
+	imageService service.ImageService
+}
+
+func NewImageHandler(imageService service.ImageService) *ImageHandler {
+	return &ImageHandler{
+		imageService: imageService,
+	}
+}
+
+// CreateImage registers an image on the board and returns the fileId image
+// elements refer to it by, with a presigned URL to upload it to.
+func (h *ImageHandler) CreateImage(c *gin.Context) {
+	var req dto.CreateImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.BoardID = c.Param("id")
+	req.UserID = c.MustGet("userId").(string)
+
+	resp, err := h.imageService.CreateImage(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to create image", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Image created",
+		Data:    resp,
+	})
+}
+
+// GetImage returns a presigned URL to load the image from.
+func (h *ImageHandler) GetImage(c *gin.Context) {
+	resp, err := h.imageService.GetImage(c.Request.Context(), dto.GetImageRequest{
+		BoardID: c.Param("id"),
+		FileID:  c.Param("fileId"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to get image", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Image fetched",
+		Data:    resp,
+	})
+}
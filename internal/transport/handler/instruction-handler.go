@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InstructionHandler struct {
+	instructionService service.InstructionService
+}
+
+func NewInstructionHandler(instructionService service.InstructionService) *InstructionHandler {
+	return &InstructionHandler{
+		instructionService: instructionService,
+	}
+}
+
+func (h *InstructionHandler) GetBoardInstructions(c *gin.Context) {
+	boardId := c.Param("id")
+	userId := c.MustGet("userId").(string)
+	resp, err := h.instructionService.GetBoardInstructions(c.Request.Context(), dto.GetBoardInstructionsRequest{
+		BoardID: boardId,
+		UserID:  userId,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Message: "Failed to get instructions",
+			Error:   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Instructions fetched",
+		Data:    resp,
+	})
+}
+
+func (h *InstructionHandler) GetBoardHistory(c *gin.Context) {
+	resp, err := h.instructionService.GetBoardHistory(c.Request.Context(), dto.GetBoardHistoryRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to get history", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "History fetched",
+		Data:    resp,
+	})
+}
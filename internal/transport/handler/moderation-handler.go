@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationHandler struct {
+	moderationService service.ModerationService
+}
+
+func NewModerationHandler(moderationService service.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: moderationService,
+	}
+}
+
+func (h *ModerationHandler) MuteParticipant(c *gin.Context) {
+	if err := h.moderationService.MuteParticipant(c.Request.Context(), moderationRequest(c)); err != nil {
+		respondError(c, "Failed to mute participant", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Participant muted",
+	})
+}
+
+func (h *ModerationHandler) RemoveParticipant(c *gin.Context) {
+	if err := h.moderationService.RemoveParticipant(c.Request.Context(), moderationRequest(c)); err != nil {
+		respondError(c, "Failed to remove participant", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Participant removed",
+	})
+}
+
+func (h *ModerationHandler) SetParticipantRole(c *gin.Context) {
+	req := moderationRequest(c)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := h.moderationService.SetParticipantRole(c.Request.Context(), req); err != nil {
+		respondError(c, "Failed to change participant role", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Participant role changed",
+	})
+}
+
+func moderationRequest(c *gin.Context) dto.ModerateParticipantRequest {
+	return dto.ModerateParticipantRequest{
+		BoardID:  c.Param("id"),
+		UserID:   c.MustGet("userId").(string),
+		Identity: c.Param("identity"),
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PendingChangeHandler struct {
+	pendingChangeService service.PendingChangeService
+}
+
+func NewPendingChangeHandler(pendingChangeService service.PendingChangeService) *PendingChangeHandler {
+	return &PendingChangeHandler{
+		pendingChangeService: pendingChangeService,
+	}
+}
+
+func (h *PendingChangeHandler) GetPendingChanges(c *gin.Context) {
+	boardId := c.Param("id")
+	userId := c.MustGet("userId").(string)
+	resp, err := h.pendingChangeService.GetPendingChanges(c.Request.Context(), dto.GetPendingChangesRequest{
+		BoardID: boardId,
+		UserID:  userId,
+	})
+	if err != nil {
+		respondError(c, "Failed to get pending changes", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Pending changes fetched",
+		Data:    resp,
+	})
+}
+
+func (h *PendingChangeHandler) ApprovePendingChange(c *gin.Context) {
+	req, ok := bindDecision(c)
+	if !ok {
+		return
+	}
+	resp, err := h.pendingChangeService.ApprovePendingChange(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to approve pending change", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Pending change approved",
+		Data:    resp,
+	})
+}
+
+func (h *PendingChangeHandler) RejectPendingChange(c *gin.Context) {
+	req, ok := bindDecision(c)
+	if !ok {
+		return
+	}
+	resp, err := h.pendingChangeService.RejectPendingChange(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to reject pending change", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Pending change rejected",
+		Data:    resp,
+	})
+}
+
+// bindDecision reads the optional decision body (a reason) and path params.
+func bindDecision(c *gin.Context) (dto.DecidePendingChangeRequest, bool) {
+	var req dto.DecidePendingChangeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Message: "Invalid request",
+				Error:   err.Error(),
+			})
+			return req, false
+		}
+	}
+	req.BoardID = c.Param("id")
+	req.ChangeID = c.Param("changeId")
+	req.UserID = c.MustGet("userId").(string)
+	return req, true
+}
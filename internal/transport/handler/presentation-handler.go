@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PresentationHandler struct {
+	presentationService service.PresentationService
+}
+
+func NewPresentationHandler(presentationService service.PresentationService) *PresentationHandler {
+	return &PresentationHandler{
+		presentationService: presentationService,
+	}
+}
+
+func (h *PresentationHandler) CreateToken(c *gin.Context) {
+	req := dto.CreatePresentationTokenRequest{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Message: "Invalid request",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	req.BoardID = c.Param("id")
+	req.UserID = c.MustGet("userId").(string)
+
+	resp, err := h.presentationService.CreateToken(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to create presentation token", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Presentation token created",
+		Data:    resp,
+	})
+}
+
+func (h *PresentationHandler) GetTokens(c *gin.Context) {
+	resp, err := h.presentationService.GetTokens(c.Request.Context(), dto.GetPresentationTokensRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to get presentation tokens", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Presentation tokens fetched",
+		Data:    resp,
+	})
+}
+
+func (h *PresentationHandler) RevokeToken(c *gin.Context) {
+	err := h.presentationService.RevokeToken(c.Request.Context(), dto.RevokePresentationTokenRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+		TokenID: c.Param("tokenId"),
+	})
+	if err != nil {
+		respondError(c, "Failed to revoke presentation token", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Presentation token revoked",
+	})
+}
+
+// GetPresentation serves the board a presentation token grants. The board
+// comes from the token, so the route has no board ID to tamper with.
+func (h *PresentationHandler) GetPresentation(c *gin.Context) {
+	resp, err := h.presentationService.GetPresentation(c.Request.Context(), dto.GetPresentationRequest{
+		BoardID: c.MustGet("presentationBoardId").(string),
+		TokenID: c.MustGet("presentationTokenId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to get presentation", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Presentation fetched",
+		Data:    resp,
+	})
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SandboxHandler struct {
+	sandboxService service.SandboxService
+}
+
+func NewSandboxHandler(sandboxService service.SandboxService) *SandboxHandler {
+	return &SandboxHandler{
+		sandboxService: sandboxService,
+	}
+}
+
+func (h *SandboxHandler) RunInstruction(c *gin.Context) {
+	var req dto.SandboxInstructionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.UserID = c.MustGet("userId").(string)
+	resp, err := h.sandboxService.RunInstruction(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to run sandbox instruction", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Sandbox instruction evaluated",
+		Data:    resp,
+	})
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountService service.ServiceAccountService
+}
+
+func NewServiceAccountHandler(serviceAccountService service.ServiceAccountService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountService: serviceAccountService,
+	}
+}
+
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	req := dto.CreateServiceAccountRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.UserID = c.MustGet("userId").(string)
+
+	resp, err := h.serviceAccountService.CreateServiceAccount(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to create service account", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Service account created",
+		Data:    resp,
+	})
+}
+
+func (h *ServiceAccountHandler) GetServiceAccounts(c *gin.Context) {
+	resp, err := h.serviceAccountService.GetServiceAccounts(c.Request.Context(), dto.GetServiceAccountsRequest{
+		UserID: c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to get service accounts", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Service accounts fetched",
+		Data:    resp,
+	})
+}
+
+func (h *ServiceAccountHandler) RotateKey(c *gin.Context) {
+	req := dto.RotateServiceAccountKeyRequest{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Message: "Invalid request",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	req.UserID = c.MustGet("userId").(string)
+	req.ServiceAccountID = c.Param("accountId")
+
+	resp, err := h.serviceAccountService.RotateKey(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to rotate service account key", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Service account key rotated",
+		Data:    resp,
+	})
+}
+
+func (h *ServiceAccountHandler) DisableServiceAccount(c *gin.Context) {
+	err := h.serviceAccountService.DisableServiceAccount(c.Request.Context(), dto.DisableServiceAccountRequest{
+		UserID:           c.MustGet("userId").(string),
+		ServiceAccountID: c.Param("accountId"),
+	})
+	if err != nil {
+		respondError(c, "Failed to disable service account", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Service account disabled",
+	})
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"draw/internal/dto"
+	"draw/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ViewHandler struct {
+	viewService service.ViewService
+}
+
+func NewViewHandler(viewService service.ViewService) *ViewHandler {
+	return &ViewHandler{
+		viewService: viewService,
+	}
+}
+
+func (h *ViewHandler) GetViews(c *gin.Context) {
+	resp, err := h.viewService.GetViews(c.Request.Context(), dto.GetViewsRequest{
+		BoardID: c.Param("id"),
+		UserID:  c.MustGet("userId").(string),
+	})
+	if err != nil {
+		respondError(c, "Failed to get views", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Views fetched",
+		Data:    resp,
+	})
+}
+
+func (h *ViewHandler) CreateView(c *gin.Context) {
+	req, ok := bindView(c)
+	if !ok {
+		return
+	}
+	resp, err := h.viewService.CreateView(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to create view", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "View created",
+		Data:    resp,
+	})
+}
+
+func (h *ViewHandler) UpdateView(c *gin.Context) {
+	req, ok := bindView(c)
+	if !ok {
+		return
+	}
+	resp, err := h.viewService.UpdateView(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to update view", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "View updated",
+		Data:    resp,
+	})
+}
+
+func (h *ViewHandler) DeleteView(c *gin.Context) {
+	err := h.viewService.DeleteView(c.Request.Context(), viewRequest(c))
+	if err != nil {
+		respondError(c, "Failed to delete view", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "View deleted",
+	})
+}
+
+// Navigate moves everyone on the board to the view.
+func (h *ViewHandler) Navigate(c *gin.Context) {
+	req := viewRequest(c)
+	req.MutationID = mutationID(c)
+	resp, err := h.viewService.Navigate(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, "Failed to navigate to view", err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Navigated to view",
+		Data:    resp,
+	})
+}
+
+func bindView(c *gin.Context) (dto.SaveViewRequest, bool) {
+	var req dto.SaveViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message: "Invalid request",
+			Error:   err.Error(),
+		})
+		return req, false
+	}
+	req.BoardID = c.Param("id")
+	req.ViewID = c.Param("viewId")
+	req.UserID = c.MustGet("userId").(string)
+	return req, true
+}
+
+func viewRequest(c *gin.Context) dto.ViewRequest {
+	return dto.ViewRequest{
+		BoardID: c.Param("id"),
+		ViewID:  c.Param("viewId"),
+		UserID:  c.MustGet("userId").(string),
+	}
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"draw/internal/dto"
+	"draw/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// navigations records the mutation ID of every navigation it is asked for.
+type navigations struct {
+	service.ViewService
+	mutationIDs []string
+}
+
+func (n *navigations) Navigate(ctx context.Context, req dto.ViewRequest) (*dto.NavigateEvent, error) {
+	n.mutationIDs = append(n.mutationIDs, req.MutationID)
+	return &dto.NavigateEvent{}, nil
+}
+
+// TestNavigateMutationID has two clients navigate the same board. Each one's
+// mutation ID, and only its own, is passed on to the broadcast.
+func TestNavigateMutationID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	views := &navigations{}
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("userId", c.GetHeader("X-Test-User")) })
+	r.POST("/boards/:id/views/:viewId/navigate", NewViewHandler(views).Navigate)
+
+	tests := []struct {
+		name       string
+		user       string
+		mutationID string
+		want       string
+	}{
+		{name: "first client", user: "alice", mutationID: "alice-1", want: "alice-1"},
+		{name: "second client", user: "bob", mutationID: "bob-1", want: "bob-1"},
+		{name: "client without IDs", user: "carol", want: ""},
+		// A truncated ID would never match the client's, so none is sent.
+		{name: "ID too long", user: "alice", mutationID: strings.Repeat("x", maxMutationIDLength+1), want: ""},
+		{name: "longest ID", user: "alice", mutationID: strings.Repeat("y", maxMutationIDLength), want: strings.Repeat("y", maxMutationIDLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/boards/b1/views/v1/navigate", nil)
+			req.Header.Set("X-Test-User", tt.user)
+			if tt.mutationID != "" {
+				req.Header.Set("X-Mutation-ID", tt.mutationID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			if got := views.mutationIDs[len(views.mutationIDs)-1]; got != tt.want {
+				t.Errorf("broadcast mutation ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
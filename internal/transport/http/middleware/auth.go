@@ -1,7 +1,12 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"draw/pkg/auth"
 
@@ -9,8 +14,68 @@ import (
 	"github.com/lestrrat-go/jwx/v3/jwk"
 )
 
-func AuthMiddleware(authKeys jwk.Set) gin.HandlerFunc {
+// Principal types set under the "principal" key. Handlers on routes open to
+// more than one type can check it.
+const (
+	PrincipalUser           = "user"
+	PrincipalPresentation   = "presentation"
+	PrincipalServiceAccount = "service_account"
+)
+
+// presentationScheme is the Authorization scheme for presentation tokens.
+const presentationScheme = "Presentation "
+
+// serviceAccountScheme is the Authorization scheme for service account keys.
+const serviceAccountScheme = "ServiceKey "
+
+// ServiceAccountAuthenticator resolves a service account key to the user the
+// account acts for, the account itself and the scopes the key grants.
+type ServiceAccountAuthenticator func(ctx context.Context, key string) (ownerID string, account ServiceAccount, err error)
+
+// ServiceAccount identifies the service account behind a request. It is set
+// under the "serviceAccount" key, and its ID, name and scopes under
+// "serviceAccountId", "serviceAccountName" and "serviceAccountScopes".
+type ServiceAccount struct {
+	ID     string
+	Name   string
+	Scopes []string
+}
+
+// AuthMiddleware admits users with a valid JWT, and service accounts with a
+// valid key as "Authorization: ServiceKey <key>". A service account is
+// treated as its owner: "userId" is the owner's ID. Each service account may
+// make at most serviceLimit requests per minute.
+func AuthMiddleware(authKeys jwk.Set, serviceAccounts ServiceAccountAuthenticator, serviceLimit int) gin.HandlerFunc {
+	limiter := newTokenLimiter(serviceLimit, time.Minute)
 	return func(c *gin.Context) {
+		if isPresentationRequest(c.Request) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Presentation tokens can't access this endpoint"})
+			c.Abort()
+			return
+		}
+		if key, ok := strings.CutPrefix(c.Request.Header.Get("Authorization"), serviceAccountScheme); ok {
+			ownerID, account, err := serviceAccounts(c.Request.Context(), strings.TrimSpace(key))
+			if err != nil {
+				fmt.Println("Service account auth error:", err)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+				c.Abort()
+				return
+			}
+			if retryAfter, ok := limiter.allow(account.ID); !ok {
+				c.Header("Retry-After", fmt.Sprint(int(retryAfter.Seconds())+1))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+				c.Abort()
+				return
+			}
+			c.Set("principal", PrincipalServiceAccount)
+			c.Set("userId", ownerID)
+			c.Set("serviceAccount", account)
+			c.Set("serviceAccountId", account.ID)
+			c.Set("serviceAccountName", account.Name)
+			c.Set("serviceAccountScopes", account.Scopes)
+			c.Next()
+			return
+		}
 		userId, err := auth.UserFromToken(c.Request, authKeys)
 		if err != nil {
 			fmt.Println("Auth error:", err)
@@ -18,7 +83,34 @@ func AuthMiddleware(authKeys jwk.Set) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		c.Set("principal", PrincipalUser)
 		c.Set("userId", userId)
 		c.Next()
 	}
 }
+
+// RequireScope rejects service accounts whose key lacks scope. An empty scope
+// rejects every service account. Users are not affected.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("principal") != PrincipalServiceAccount {
+			c.Next()
+			return
+		}
+		account := c.MustGet("serviceAccount").(ServiceAccount)
+		if scope == "" || !slices.Contains(account.Scopes, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Service account key lacks the scope for this endpoint"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func isPresentationRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), presentationScheme)
+}
+
+func presentationToken(r *http.Request) string {
+	return strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), presentationScheme))
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"draw/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalDemo is an anonymous visitor identified by a demo session cookie.
+const PrincipalDemo = "demo"
+
+// DemoMiddleware admits anonymous visitors to the demo routes. A visitor
+// without a valid session cookie is given a new session. Each client IP may
+// make at most limit requests per minute.
+func DemoMiddleware(secret []byte, cookieMaxAge time.Duration, secure bool, limit int) gin.HandlerFunc {
+	limiter := newTokenLimiter(limit, time.Minute)
+	return func(c *gin.Context) {
+		if isPresentationRequest(c.Request) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Presentation tokens can't access this endpoint"})
+			c.Abort()
+			return
+		}
+		if retryAfter, ok := limiter.allow(c.ClientIP()); !ok {
+			c.Header("Retry-After", fmt.Sprint(int(retryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+
+		var sessionID string
+		if value, err := c.Cookie(auth.DemoSessionCookie); err == nil {
+			sessionID, _ = auth.VerifyDemoSession(secret, value)
+		}
+		if sessionID == "" {
+			var err error
+			if sessionID, err = auth.NewDemoSessionID(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start demo session"})
+				c.Abort()
+				return
+			}
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(auth.DemoSessionCookie, auth.SignDemoSession(secret, sessionID), int(cookieMaxAge.Seconds()), "/", "", secure, true)
+		}
+
+		c.Set("principal", PrincipalDemo)
+		c.Set("demoSessionId", sessionID)
+		c.Next()
+	}
+}
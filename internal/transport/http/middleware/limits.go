@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit lets each caller make at most limit requests per minute to the
+// routes it guards, on top of whatever limit their auth mode applies. It must
+// run after the auth middleware, which identifies the caller. A limit of 0 or
+// less lets every request through.
+func RateLimit(limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	limiter := newTokenLimiter(limit, time.Minute)
+	return func(c *gin.Context) {
+		if retryAfter, ok := limiter.allow(callerKey(c)); !ok {
+			c.Header("Retry-After", fmt.Sprint(int(retryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// callerKey tells callers apart for RateLimit. Service accounts are counted
+// apart from the user they act for, and anonymous demo visitors by IP, as
+// they can start a new demo session at will.
+func callerKey(c *gin.Context) string {
+	switch c.GetString("principal") {
+	case PrincipalServiceAccount:
+		return "service_account:" + c.GetString("serviceAccountId")
+	case PrincipalPresentation:
+		return "presentation:" + c.GetString("presentationTokenId")
+	case PrincipalUser:
+		return "user:" + c.GetString("userId")
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// MaxBody rejects request bodies larger than limit bytes with a 413. Bodies
+// declaring a larger Content-Length are rejected before the handler runs;
+// reads past limit fail for the rest. A limit of 0 or less leaves the body
+// to the handler.
+func MaxBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body may be at most %d bytes", limit)})
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresentationAuthenticator resolves a presentation token to the board and
+// token IDs it grants.
+type PresentationAuthenticator func(ctx context.Context, token string) (boardID string, tokenID string, err error)
+
+// PresentationMiddleware admits requests carrying a valid presentation token
+// as "Authorization: Presentation <token>". Each token may make at most
+// limit requests per minute.
+func PresentationMiddleware(authenticate PresentationAuthenticator, limit int) gin.HandlerFunc {
+	limiter := newTokenLimiter(limit, time.Minute)
+	return func(c *gin.Context) {
+		if !isPresentationRequest(c.Request) {
+			// User and service account credentials are refused outright, as
+			// AuthMiddleware refuses presentation tokens.
+			if c.Request.Header.Get("Authorization") != "" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Only presentation tokens can access this endpoint"})
+			} else {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			}
+			c.Abort()
+			return
+		}
+		boardID, tokenID, err := authenticate(c.Request.Context(), presentationToken(c.Request))
+		if err != nil {
+			fmt.Println("Presentation auth error:", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		if retryAfter, ok := limiter.allow(tokenID); !ok {
+			c.Header("Retry-After", fmt.Sprint(int(retryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+		c.Set("principal", PrincipalPresentation)
+		c.Set("presentationBoardId", boardID)
+		c.Set("presentationTokenId", tokenID)
+		c.Next()
+	}
+}
+
+// tokenLimiter is a fixed-window request counter per key.
+type tokenLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func newTokenLimiter(limit int, window time.Duration) *tokenLimiter {
+	return &tokenLimiter{
+		limit:  limit,
+		window: window,
+		counts: make(map[string]int),
+	}
+}
+
+// allow counts a request for key, or reports how long until the next window
+// when key is over the limit.
+func (l *tokenLimiter) allow(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.start) >= l.window {
+		// Starting a new window also drops tokens that went quiet.
+		l.start = now
+		clear(l.counts)
+	}
+	if l.counts[key] >= l.limit {
+		return l.window - now.Sub(l.start), false
+	}
+	l.counts[key]++
+	return 0, true
+}
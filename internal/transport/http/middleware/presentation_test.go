@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+)
+
+// signedJWT returns a token for user u1 and the key set that verifies it.
+func signedJWT(t *testing.T) (string, jwk.Set) {
+	t.Helper()
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.Import(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Set(jwk.KeyIDKey, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Set(jwk.AlgorithmKey, jwa.ES256()); err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.NewBuilder().Subject("u1").Expiration(time.Now().Add(time.Hour)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.ES256(), key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := jwk.NewSet()
+	if err := set.AddKey(key); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := jwk.PublicSetOf(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed), keys
+}
+
+func TestPrincipalRouteMatrix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token, keys := signedJWT(t)
+
+	serviceAccounts := func(ctx context.Context, key string) (string, ServiceAccount, error) {
+		if key != "good" {
+			return "", ServiceAccount{}, errors.New("unknown key")
+		}
+		return "u1", ServiceAccount{ID: "sa1", Name: "ci"}, nil
+	}
+	presentations := func(ctx context.Context, token string) (string, string, error) {
+		if token != "good" {
+			return "", "", errors.New("unknown token")
+		}
+		return "board-1", "token-1", nil
+	}
+
+	r := gin.New()
+	principal := func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("principal"))
+	}
+	r.GET("/jwt", AuthMiddleware(keys, serviceAccounts, 100), principal)
+	r.GET("/presentation", PresentationMiddleware(presentations, 100), principal)
+	r.GET("/demo", DemoMiddleware([]byte("secret"), time.Hour, false, 100), principal)
+
+	credentials := []struct {
+		name          string
+		authorization string
+	}{
+		{name: "presentation token", authorization: "Presentation good"},
+		{name: "unknown presentation token", authorization: "Presentation bad"},
+		{name: "jwt", authorization: "Bearer " + token},
+		{name: "service account", authorization: "ServiceKey good"},
+		{name: "none"},
+	}
+	// want maps each route to the status for each credential, in order,
+	// and the principal admitted requests get.
+	tests := []struct {
+		path       string
+		want       []int
+		principals []string
+	}{
+		{
+			path:       "/jwt",
+			want:       []int{http.StatusForbidden, http.StatusForbidden, http.StatusOK, http.StatusOK, http.StatusUnauthorized},
+			principals: []string{"", "", PrincipalUser, PrincipalServiceAccount, ""},
+		},
+		{
+			path:       "/presentation",
+			want:       []int{http.StatusOK, http.StatusUnauthorized, http.StatusForbidden, http.StatusForbidden, http.StatusUnauthorized},
+			principals: []string{PrincipalPresentation, "", "", "", ""},
+		},
+		{
+			path:       "/demo",
+			want:       []int{http.StatusForbidden, http.StatusForbidden, http.StatusOK, http.StatusOK, http.StatusOK},
+			principals: []string{"", "", PrincipalDemo, PrincipalDemo, PrincipalDemo},
+		},
+	}
+	for _, tt := range tests {
+		for i, credential := range credentials {
+			t.Run(tt.path+"/"+credential.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+				if credential.authorization != "" {
+					req.Header.Set("Authorization", credential.authorization)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != tt.want[i] {
+					t.Fatalf("got %d, want %d: %s", w.Code, tt.want[i], w.Body.String())
+				}
+				if w.Code == http.StatusOK && w.Body.String() != tt.principals[i] {
+					t.Errorf("principal = %q, want %q", w.Body.String(), tt.principals[i])
+				}
+			})
+		}
+	}
+}
+
+func TestPresentationRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/present", PresentationMiddleware(func(ctx context.Context, token string) (string, string, error) {
+		return "board-1", token, nil
+	}, 2), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		token string
+		want  int
+	}{
+		{token: "a", want: http.StatusOK},
+		{token: "a", want: http.StatusOK},
+		{token: "a", want: http.StatusTooManyRequests},
+		{token: "b", want: http.StatusOK},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/present", nil)
+		req.Header.Set("Authorization", "Presentation "+tt.token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("request %d with token %s: got %d, want %d", i, tt.token, w.Code, tt.want)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("request %d: 429 without Retry-After", i)
+		}
+	}
+}
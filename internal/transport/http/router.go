@@ -1,47 +1,239 @@
 package http
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"draw/internal/app"
+	"draw/internal/dto"
+	"draw/internal/service"
 	"draw/internal/transport/handler"
 	"draw/internal/transport/http/middleware"
+	"draw/pkg/llm"
+	"draw/pkg/llm/prompts"
+	"draw/pkg/slo"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/lestrrat-go/jwx/v3/jwk"
 )
 
-func RegisterRoutes(r *gin.Engine, authKeys jwk.Set, app *app.App) {
+func RegisterRoutes(r *gin.Engine, authKeys jwk.Set, app *app.App) error {
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "http://127.0.0.1:5173", "http://localhost:9000", "http://127.0.0.1:9000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Mutation-ID"},
 		AllowCredentials: true,
 	}))
 
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
-		})
-	})
+	routes := routeTable(app)
 
-	// Middlewares
-	protected := r.Group("")
-	protected.Use(middleware.AuthMiddleware(authKeys))
+	jwtAuth := middleware.AuthMiddleware(
+		authKeys,
+		func(ctx context.Context, key string) (string, middleware.ServiceAccount, error) {
+			principal, err := app.Service.ServiceAccountService.Authenticate(ctx, key)
+			if err != nil {
+				return "", middleware.ServiceAccount{}, err
+			}
+			return principal.OwnerID, middleware.ServiceAccount{
+				ID:     principal.ServiceAccountID.String(),
+				Name:   principal.Name,
+				Scopes: principal.Scopes,
+			}, nil
+		},
+		app.Config.Board.ServiceAccountRequestsPerMin,
+	)
 
-	// Inngest Endpoint
-	// r.Any("/api/inngest", app.Inngest.Handler())
+	presentationAuth := middleware.PresentationMiddleware(
+		func(ctx context.Context, token string) (string, string, error) {
+			principal, err := app.Service.PresentationService.Authenticate(ctx, token)
+			if err != nil {
+				return "", "", err
+			}
+			return principal.BoardID.String(), principal.TokenID.String(), nil
+		},
+		app.Config.Board.PresentationRequestsPerMin,
+	)
 
-	userHandler := handler.NewUserHandler(app.Service.UserService)
-	protected.GET("/users/:id", userHandler.GetUserByID)
+	demoAuth := middleware.DemoMiddleware(
+		[]byte(app.Config.Demo.SessionSecret),
+		time.Duration(app.Config.Demo.MaxTTLMin)*time.Minute,
+		app.Config.Env == "production",
+		app.Config.Demo.RequestsPerMin,
+	)
+
+	rates := map[RateClass]int{
+		RateLLM: app.Config.Server.LLMRequestsPerMin,
+	}
+	return registerRouteTable(r, jwtAuth, presentationAuth, demoAuth, rates, routes)
+}
 
+// routeTable declares every endpoint the server serves.
+func routeTable(app *app.App) []Route {
+	userHandler := handler.NewUserHandler(app.Service.UserService)
 	boardHandler := handler.NewBoardHandler(app.Service.BoardService)
-	protected.GET("/boards", boardHandler.GetBoardsByUserID)
-	protected.GET("/boards/:id", boardHandler.GetBoard)
-	protected.POST("/boards", boardHandler.CreateBoard)
-	protected.PUT("/boards/:id", boardHandler.UpdateBoard)
-	protected.DELETE("/boards/:id", boardHandler.DeleteBoard)
+	instructionHandler := handler.NewInstructionHandler(app.Service.InstructionService)
+	pendingChangeHandler := handler.NewPendingChangeHandler(app.Service.PendingChangeService)
+	commentHandler := handler.NewCommentHandler(app.Service.CommentService)
+	viewHandler := handler.NewViewHandler(app.Service.ViewService)
+	imageHandler := handler.NewImageHandler(app.Service.ImageService)
+	moderationHandler := handler.NewModerationHandler(app.Service.ModerationService)
+	presentationHandler := handler.NewPresentationHandler(app.Service.PresentationService)
+	analyticsHandler := handler.NewAnalyticsHandler(app.Service.AnalyticsService)
+	sandboxHandler := handler.NewSandboxHandler(app.Service.SandboxService)
+	demoHandler := handler.NewDemoHandler(app.Service.DemoService)
+	serviceAccountHandler := handler.NewServiceAccountHandler(app.Service.ServiceAccountService)
+	debugHandler := handler.NewDebugHandler(app.Service.DebugService)
+
+	// Inngest Endpoint
+	// {Method: http.MethodPost, Path: "/api/inngest", Auth: AuthPublic, Handler: gin.WrapH(app.Inngest.Handler())},
+
+	routes := []Route{
+		{Method: http.MethodGet, Path: "/health", Auth: AuthPublic, Handler: health(app.Models)},
+
+		{Method: http.MethodGet, Path: "/users/:id", Auth: AuthJWT, Handler: userHandler.GetUserByID},
+
+		{Method: http.MethodGet, Path: "/boards", Auth: AuthJWT, Scope: service.ScopeBoardsRead, Handler: boardHandler.GetBoardsByUserID},
+		{Method: http.MethodGet, Path: "/boards/:id", Auth: AuthJWT, Scope: service.ScopeBoardsRead, Handler: boardHandler.GetBoard},
+		{Method: http.MethodPost, Path: "/boards", Auth: AuthJWT, Scope: service.ScopeBoardsWrite, Handler: boardHandler.CreateBoard},
+		{Method: http.MethodPost, Path: "/boards/import", Auth: AuthJWT, Body: BodyStream, Handler: boardHandler.ImportBoard},
+		{Method: http.MethodPost, Path: "/boards/:id/import/mermaid", Auth: AuthJWT, Scope: service.ScopeBoardsWrite, Handler: boardHandler.ImportMermaid},
+		{Method: http.MethodPost, Path: "/boards/:id/templates/:name", Auth: AuthJWT, Scope: service.ScopeBoardsWrite, Handler: boardHandler.InstantiateTemplate},
+		{Method: http.MethodGet, Path: "/boards/:id/export", Auth: AuthJWT, Scope: service.ScopeBoardsRead, Handler: boardHandler.ExportBoard},
+		{Method: http.MethodPut, Path: "/boards/:id", Auth: AuthJWT, Scope: service.ScopeBoardsWrite, Body: BodyBoard, Handler: boardHandler.UpdateBoard},
+		{Method: http.MethodPatch, Path: "/boards/:id", Auth: AuthJWT, Scope: service.ScopeBoardsWrite, Body: BodyBoard, Handler: boardHandler.UpdateBoard},
+		{Method: http.MethodDelete, Path: "/boards/:id", Auth: AuthJWT, Handler: boardHandler.DeleteBoard},
+		{Method: http.MethodPost, Path: "/boards/:id/archive", Auth: AuthJWT, Handler: boardHandler.ArchiveBoard},
+		{Method: http.MethodPost, Path: "/boards/:id/unarchive", Auth: AuthJWT, Handler: boardHandler.UnarchiveBoard},
+		{Method: http.MethodPost, Path: "/boards/:id/partials/:token/apply", Auth: AuthJWT, Handler: boardHandler.ApplyPartial},
+		{Method: http.MethodPost, Path: "/boards/:id/confirmations/:token/apply", Auth: AuthJWT, Handler: boardHandler.ConfirmAction},
+		{Method: http.MethodPost, Path: "/boards/:id/undo", Auth: AuthJWT, Scope: service.ScopeBoardsWrite, Handler: boardHandler.UndoLast},
+		{Method: http.MethodPost, Path: "/boards/:id/speech", Auth: AuthJWT, Scope: service.ScopeInstructions, Rate: RateLLM, Body: BodyStream, Handler: boardHandler.StreamSpeech},
+
+		{Method: http.MethodGet, Path: "/boards/:id/instructions", Auth: AuthJWT, Scope: service.ScopeBoardsRead, Handler: instructionHandler.GetBoardInstructions},
+		{Method: http.MethodGet, Path: "/boards/:id/history", Auth: AuthJWT, Scope: service.ScopeBoardsRead, Handler: instructionHandler.GetBoardHistory},
+
+		{Method: http.MethodGet, Path: "/boards/:id/pending", Auth: AuthJWT, Handler: pendingChangeHandler.GetPendingChanges},
+		{Method: http.MethodPost, Path: "/boards/:id/pending/:changeId/approve", Auth: AuthJWT, Handler: pendingChangeHandler.ApprovePendingChange},
+		{Method: http.MethodPost, Path: "/boards/:id/pending/:changeId/reject", Auth: AuthJWT, Handler: pendingChangeHandler.RejectPendingChange},
+
+		{Method: http.MethodGet, Path: "/boards/:id/comments", Auth: AuthJWT, Scope: service.ScopeBoardsRead, Handler: commentHandler.GetComments},
+		{Method: http.MethodPost, Path: "/boards/:id/comments", Auth: AuthJWT, Scope: service.ScopeComments, Handler: commentHandler.CreateComment},
+		{Method: http.MethodPatch, Path: "/boards/:id/comments/:commentId", Auth: AuthJWT, Handler: commentHandler.UpdateComment},
+		{Method: http.MethodDelete, Path: "/boards/:id/comments/:commentId", Auth: AuthJWT, Handler: commentHandler.DeleteComment},
+
+		{Method: http.MethodGet, Path: "/boards/:id/views", Auth: AuthJWT, Handler: viewHandler.GetViews},
+		{Method: http.MethodPost, Path: "/boards/:id/views", Auth: AuthJWT, Handler: viewHandler.CreateView},
+		{Method: http.MethodPut, Path: "/boards/:id/views/:viewId", Auth: AuthJWT, Handler: viewHandler.UpdateView},
+		{Method: http.MethodDelete, Path: "/boards/:id/views/:viewId", Auth: AuthJWT, Handler: viewHandler.DeleteView},
+		{Method: http.MethodPost, Path: "/boards/:id/views/:viewId/navigate", Auth: AuthJWT, Handler: viewHandler.Navigate},
+
+		{Method: http.MethodPost, Path: "/boards/:id/images", Auth: AuthJWT, Scope: service.ScopeBoardsWrite, Handler: imageHandler.CreateImage},
+		{Method: http.MethodGet, Path: "/boards/:id/images/:fileId", Auth: AuthJWT, Scope: service.ScopeBoardsRead, Handler: imageHandler.GetImage},
+
+		{Method: http.MethodPost, Path: "/boards/:id/participants/:identity/mute", Auth: AuthJWT, Handler: moderationHandler.MuteParticipant},
+		{Method: http.MethodPost, Path: "/boards/:id/participants/:identity/kick", Auth: AuthJWT, Handler: moderationHandler.RemoveParticipant},
+		{Method: http.MethodPost, Path: "/boards/:id/participants/:identity/role", Auth: AuthJWT, Handler: moderationHandler.SetParticipantRole},
+
+		{Method: http.MethodGet, Path: "/boards/:id/debug/llm", Auth: AuthJWT, Scope: service.ScopeDebug, Handler: debugHandler.GetLLMHistory},
+
+		{Method: http.MethodGet, Path: "/boards/:id/presentation-tokens", Auth: AuthJWT, Handler: presentationHandler.GetTokens},
+		{Method: http.MethodPost, Path: "/boards/:id/presentation-tokens", Auth: AuthJWT, Handler: presentationHandler.CreateToken},
+		{Method: http.MethodDelete, Path: "/boards/:id/presentation-tokens/:tokenId", Auth: AuthJWT, Handler: presentationHandler.RevokeToken},
+
+		{Method: http.MethodGet, Path: "/present", Auth: AuthPresentation, Handler: presentationHandler.GetPresentation},
+
+		{Method: http.MethodGet, Path: "/service-accounts", Auth: AuthJWT, Handler: serviceAccountHandler.GetServiceAccounts},
+		{Method: http.MethodPost, Path: "/service-accounts", Auth: AuthJWT, Handler: serviceAccountHandler.CreateServiceAccount},
+		{Method: http.MethodPost, Path: "/service-accounts/:accountId/rotate", Auth: AuthJWT, Handler: serviceAccountHandler.RotateKey},
+		{Method: http.MethodPost, Path: "/service-accounts/:accountId/disable", Auth: AuthJWT, Handler: serviceAccountHandler.DisableServiceAccount},
+	}
+
+	if app.Config.Demo.Enabled {
+		routes = append(routes,
+			Route{Method: http.MethodPost, Path: "/api/demo/boards", Auth: AuthDemo, Handler: demoHandler.CreateBoard},
+			Route{Method: http.MethodGet, Path: "/api/demo/boards/:id", Auth: AuthDemo, Handler: demoHandler.GetBoard},
+			Route{Method: http.MethodPut, Path: "/api/demo/boards/:id", Auth: AuthDemo, Body: BodyBoard, Handler: demoHandler.UpdateBoard},
+			Route{Method: http.MethodPost, Path: "/api/demo/boards/:id/instructions", Auth: AuthDemo, Rate: RateLLM, Handler: demoHandler.RunInstruction},
+			Route{Method: http.MethodPost, Path: "/api/demo/boards/:id/claim", Auth: AuthJWT, Handler: demoHandler.ClaimBoard},
+		)
+	}
+
+	// Prometheus scrapes without credentials, so the metrics are only served
+	// when LLM_METRICS is set.
+	if app.LLMMetrics != nil {
+		routes = append(routes, Route{Method: http.MethodGet, Path: "/metrics", Auth: AuthPublic, Handler: metrics(app.LLMMetrics)})
+	}
+
+	// There are no admin roles yet, so the route listing, SLO report, intent
+	// analytics, slow instruction report, prompt reload and instruction
+	// sandbox are only served outside production.
+	if app.Config.Env != "production" {
+		routes = append(routes,
+			Route{Method: http.MethodGet, Path: "/api/routes", Auth: AuthJWT, Handler: listRoutes(routes)},
+			Route{Method: http.MethodGet, Path: "/api/admin/slo", Auth: AuthJWT, Handler: sloReport(app.SLO)},
+			Route{Method: http.MethodGet, Path: "/api/admin/analytics/intents", Auth: AuthJWT, Handler: analyticsHandler.GetIntentAnalytics},
+			Route{Method: http.MethodGet, Path: "/api/admin/instructions/slow", Auth: AuthJWT, Handler: analyticsHandler.GetSlowInstructions},
+			Route{Method: http.MethodPost, Path: "/api/admin/prompts/reload", Auth: AuthJWT, Handler: reloadPrompts(app.Prompts)},
+			Route{Method: http.MethodPost, Path: "/api/sandbox/instructions", Auth: AuthJWT, Rate: RateLLM, Body: BodyBoard, Handler: sandboxHandler.RunInstruction},
+		)
+	}
+
+	return routes
+}
+
+func sloReport(tracker *slo.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, dto.SuccessResponse{
+			Message: "SLO report fetched",
+			Data:    tracker.Report(),
+		})
+	}
+}
+
+// reloadPrompts rereads PROMPTS_DIR, so edited prompt versions take effect
+// without a restart.
+func reloadPrompts(registry *prompts.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := registry.Reload(); err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Message: "Failed to reload prompts",
+				Error:   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, dto.SuccessResponse{
+			Message: "Prompts reloaded",
+			Data:    gin.H{"versions": registry.Versions()},
+		})
+	}
+}
+
+// metrics serves the LLM provider metrics in the Prometheus text format.
+func metrics(m *llm.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		// A failed write means the scraper has gone away.
+		_ = m.WritePrometheus(c.Writer)
+	}
+}
+
+// health reports liveness along with the LLM model status, so a retired
+// model shows up without digging through logs.
+func health(models *llm.Models) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := "ok"
+		modelStatus := models.Status()
+		if !modelStatus.Healthy {
+			status = "degraded"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status": status,
+			"llm":    modelStatus,
+		})
+	}
 }
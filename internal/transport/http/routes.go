@@ -0,0 +1,160 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"draw/internal/dto"
+	"draw/internal/transport/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthMode is how a route authenticates its caller.
+type AuthMode string
+
+const (
+	AuthPublic AuthMode = "public"
+	AuthJWT    AuthMode = "jwt"
+	// AuthPresentation admits only presentation tokens, which grant read
+	// access to a single board. They are rejected by every other mode.
+	AuthPresentation AuthMode = "presentation"
+	// AuthDemo admits anonymous visitors with a signed demo session cookie,
+	// issuing one if they have none.
+	AuthDemo AuthMode = "demo"
+)
+
+// RateClass is how often one caller may use a route, on top of the limit
+// of its auth mode.
+type RateClass string
+
+const (
+	// RateStandard routes are only limited by their auth mode. It is the
+	// class of routes that don't declare one.
+	RateStandard RateClass = "standard"
+	// RateLLM routes run the LLM, which is slow and costs money per call.
+	RateLLM RateClass = "llm"
+)
+
+// BodyClass is how large a request body a route accepts.
+type BodyClass string
+
+const (
+	// BodySmall routes take small JSON requests. It is the class of routes
+	// that don't declare one.
+	BodySmall BodyClass = "small"
+	// BodyBoard routes take a board's elements.
+	BodyBoard BodyClass = "board"
+	// BodyStream routes bound their body themselves, such as streamed audio
+	// and board bundles.
+	BodyStream BodyClass = "stream"
+)
+
+// bodyLimits is the largest body of each class, in bytes; 0 leaves the body
+// to the handler.
+var bodyLimits = map[BodyClass]int64{
+	BodySmall:  1 << 20,
+	BodyBoard:  16 << 20,
+	BodyStream: 0,
+}
+
+// Route declares one endpoint. Every route must state its auth mode; routes
+// that don't are rejected when the router is built.
+type Route struct {
+	Method  string
+	Path    string
+	Auth    AuthMode
+	Handler gin.HandlerFunc
+	// Scope is the service account scope an AuthJWT route requires. Routes
+	// without one are for signed-in users only.
+	Scope string
+	// Rate defaults to RateStandard and Body to BodySmall.
+	Rate RateClass
+	Body BodyClass
+}
+
+func (r Route) rate() RateClass {
+	if r.Rate == "" {
+		return RateStandard
+	}
+	return r.Rate
+}
+
+func (r Route) body() BodyClass {
+	if r.Body == "" {
+		return BodySmall
+	}
+	return r.Body
+}
+
+// RouteInfo is the listing form of a Route.
+type RouteInfo struct {
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Auth   AuthMode  `json:"auth"`
+	Scope  string    `json:"scope,omitempty"`
+	Rate   RateClass `json:"rate"`
+	Body   BodyClass `json:"body"`
+}
+
+// registerRouteTable builds a Gin group per auth mode and registers every
+// route on the group for its mode, behind the limits of its rate and body
+// classes. rates is the requests per minute one caller may make to the
+// routes of each class other than RateStandard.
+func registerRouteTable(r *gin.Engine, jwt gin.HandlerFunc, presentation gin.HandlerFunc, demo gin.HandlerFunc, rates map[RateClass]int, routes []Route) error {
+	groups := map[AuthMode]*gin.RouterGroup{
+		AuthPublic:       r.Group(""),
+		AuthJWT:          r.Group("", jwt),
+		AuthPresentation: r.Group("", presentation),
+		AuthDemo:         r.Group("", demo),
+	}
+	// Each class has one limiter, shared by its routes.
+	limiters := map[RateClass]gin.HandlerFunc{
+		RateStandard: middleware.RateLimit(0),
+	}
+	for class, limit := range rates {
+		limiters[class] = middleware.RateLimit(limit)
+	}
+
+	for _, route := range routes {
+		group, ok := groups[route.Auth]
+		if !ok {
+			return fmt.Errorf("route %s %s has unknown auth mode %q", route.Method, route.Path, route.Auth)
+		}
+		limiter, ok := limiters[route.rate()]
+		if !ok {
+			return fmt.Errorf("route %s %s has unknown rate class %q", route.Method, route.Path, route.Rate)
+		}
+		bodyLimit, ok := bodyLimits[route.body()]
+		if !ok {
+			return fmt.Errorf("route %s %s has unknown body class %q", route.Method, route.Path, route.Body)
+		}
+		handlers := []gin.HandlerFunc{limiter, middleware.MaxBody(bodyLimit), route.Handler}
+		if route.Auth == AuthJWT {
+			handlers = append([]gin.HandlerFunc{middleware.RequireScope(route.Scope)}, handlers...)
+		}
+		group.Handle(route.Method, route.Path, handlers...)
+	}
+	return nil
+}
+
+// listRoutes serves the route table for debugging.
+func listRoutes(routes []Route) gin.HandlerFunc {
+	infos := make([]RouteInfo, 0, len(routes))
+	for _, route := range routes {
+		infos = append(infos, RouteInfo{
+			Method: route.Method,
+			Path:   route.Path,
+			Auth:   route.Auth,
+			Scope:  route.Scope,
+			Rate:   route.rate(),
+			Body:   route.body(),
+		})
+	}
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, dto.SuccessResponse{
+			Message: "Routes fetched",
+			Data:    infos,
+		})
+	}
+}
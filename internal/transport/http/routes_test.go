@@ -0,0 +1,204 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"draw/internal/app"
+	"draw/internal/service"
+	"draw/internal/transport/http/middleware"
+	"draw/pkg/config"
+	"draw/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+)
+
+// publicRoutes are the only routes callers may use without credentials.
+var publicRoutes = map[string]bool{
+	"GET /health":  true,
+	"GET /metrics": true,
+}
+
+// fullRouteTable returns the route table with every optional route
+// enabled, so all of them are checked.
+func fullRouteTable() []Route {
+	return routeTable(&app.App{
+		Config:     &config.AppConfig{Env: "development", Demo: config.DemoConfig{Enabled: true}},
+		Service:    &service.Service{},
+		LLMMetrics: llm.NewMetrics(),
+	})
+}
+
+func TestRouteTableDeclaresAuth(t *testing.T) {
+	routes := fullRouteTable()
+
+	seen := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		name := route.Method + " " + route.Path
+		if seen[name] {
+			t.Errorf("%s is declared twice", name)
+		}
+		seen[name] = true
+
+		switch route.Auth {
+		case AuthJWT, AuthPresentation, AuthDemo:
+		case AuthPublic:
+			if !publicRoutes[name] {
+				t.Errorf("%s is public; declare the auth mode it needs", name)
+			}
+		default:
+			t.Errorf("%s has auth mode %q, want one of jwt, presentation, demo or public", name, route.Auth)
+		}
+		if route.Scope != "" && route.Auth != AuthJWT {
+			t.Errorf("%s requires scope %s, which only jwt routes check", name, route.Scope)
+		}
+		if route.rate() != RateStandard && route.rate() != RateLLM {
+			t.Errorf("%s has rate class %q", name, route.Rate)
+		}
+		if _, ok := bodyLimits[route.body()]; !ok {
+			t.Errorf("%s has body class %q", name, route.Body)
+		}
+		if route.Handler == nil {
+			t.Errorf("%s has no handler", name)
+		}
+	}
+}
+
+func TestRegisterRouteTableRejectsUndeclared(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ok := func(c *gin.Context) {}
+	tests := []struct {
+		name  string
+		route Route
+	}{
+		{name: "no auth mode", route: Route{Method: http.MethodGet, Path: "/a", Handler: ok}},
+		{name: "unknown auth mode", route: Route{Method: http.MethodGet, Path: "/a", Auth: "share-link", Handler: ok}},
+		{name: "unknown rate class", route: Route{Method: http.MethodGet, Path: "/a", Auth: AuthJWT, Rate: "bulk", Handler: ok}},
+		{name: "unknown body class", route: Route{Method: http.MethodGet, Path: "/a", Auth: AuthJWT, Body: "huge", Handler: ok}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registerRouteTable(gin.New(), ok, ok, ok, nil, []Route{tt.route})
+			if err == nil {
+				t.Errorf("got nil, want the route rejected")
+			}
+		})
+	}
+}
+
+func TestRegisterRouteTableLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// jwt admits whoever the X-User header names.
+	jwt := func(c *gin.Context) {
+		c.Set("principal", "user")
+		c.Set("userId", c.GetHeader("X-User"))
+	}
+	reply := func(c *gin.Context) {
+		buf := make([]byte, 32<<10)
+		for {
+			_, err := c.Request.Body.Read(buf)
+			if err != nil {
+				break
+			}
+		}
+		c.Status(http.StatusOK)
+	}
+	r := gin.New()
+	err := registerRouteTable(r, jwt, jwt, jwt, map[RateClass]int{RateLLM: 2}, []Route{
+		{Method: http.MethodPost, Path: "/llm", Auth: AuthJWT, Rate: RateLLM, Handler: reply},
+		{Method: http.MethodPost, Path: "/small", Auth: AuthJWT, Handler: reply},
+		{Method: http.MethodPost, Path: "/board", Auth: AuthJWT, Body: BodyBoard, Handler: reply},
+		{Method: http.MethodPost, Path: "/stream", Auth: AuthJWT, Body: BodyStream, Handler: reply},
+	})
+	if err != nil {
+		t.Fatalf("registerRouteTable: %v", err)
+	}
+
+	large := strings.Repeat("x", 2<<20)
+	tests := []struct {
+		name string
+		path string
+		user string
+		body string
+		want int
+	}{
+		{name: "llm route", path: "/llm", user: "u1", want: http.StatusOK},
+		{name: "llm route again", path: "/llm", user: "u1", want: http.StatusOK},
+		{name: "llm route over the limit", path: "/llm", user: "u1", want: http.StatusTooManyRequests},
+		{name: "llm route for another user", path: "/llm", user: "u2", want: http.StatusOK},
+		{name: "standard route isn't limited", path: "/small", user: "u1", want: http.StatusOK},
+		{name: "small body", path: "/small", user: "u1", body: `{"name":"board"}`, want: http.StatusOK},
+		{name: "large body on a small route", path: "/small", user: "u1", body: large, want: http.StatusRequestEntityTooLarge},
+		{name: "large body on a board route", path: "/board", user: "u1", body: large, want: http.StatusOK},
+		{name: "large body on a stream route", path: "/stream", user: "u1", body: large, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("X-User", tt.user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+// TestPrincipalsStayOnTheirRoutes sends a presentation token to every route
+// that isn't for presentations, and user and service account credentials to
+// every presentation route. The auth middleware must refuse all of them
+// with a 403 before any handler runs.
+func TestPrincipalsStayOnTheirRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwt := middleware.AuthMiddleware(jwk.NewSet(), func(ctx context.Context, key string) (string, middleware.ServiceAccount, error) {
+		return "", middleware.ServiceAccount{}, errors.New("jwt routes aren't reached with this key")
+	}, 1000)
+	presentation := middleware.PresentationMiddleware(func(ctx context.Context, token string) (string, string, error) {
+		return "", "", errors.New("presentation routes aren't reached with this token")
+	}, 1000)
+	demo := middleware.DemoMiddleware([]byte("secret"), time.Hour, false, 1000)
+
+	routes := fullRouteTable()
+	r := gin.New()
+	if err := registerRouteTable(r, jwt, presentation, demo, map[RateClass]int{RateLLM: 0}, routes); err != nil {
+		t.Fatalf("registerRouteTable: %v", err)
+	}
+
+	for _, route := range routes {
+		if route.Auth == AuthPublic {
+			continue
+		}
+		credentials := []string{"Presentation token"}
+		if route.Auth == AuthPresentation {
+			credentials = []string{"Bearer token", "ServiceKey key"}
+		}
+		for _, credential := range credentials {
+			name := route.Method + " " + route.Path + " with " + strings.Fields(credential)[0]
+			t.Run(name, func(t *testing.T) {
+				req := httptest.NewRequest(route.Method, fillPath(route.Path), nil)
+				req.Header.Set("Authorization", credential)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != http.StatusForbidden {
+					t.Errorf("got %d, want 403", w.Code)
+				}
+			})
+		}
+	}
+}
+
+// fillPath gives each parameter in a route path a value.
+func fillPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/pkg/config"
+	"draw/pkg/logger"
+)
+
+// intentTTL is how long instruction intents are kept. Instructions are
+// resumed within minutes of a restart, if at all.
+const intentTTL = time.Hour
+
+// PurgeWorker periodically applies the retention policy to stored data.
+// Instruction text older than the retention window is replaced with a
+// redaction marker; the rest of the row (response, error, inverse action,
+// timestamps) is kept, so undo and the board history still work.
+// It also settles pending changes whose approval window has passed and
+// deletes expired demo boards and old instruction intents. Boards under legal
+// hold are skipped until the hold is released.
+type PurgeWorker struct {
+	queries   *repo.Queries
+	config    *config.RetentionConfig
+	log       *logger.Logger
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewPurgeWorker(queries *repo.Queries, cfg *config.RetentionConfig, log *logger.Logger) *PurgeWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PurgeWorker{
+		queries: queries,
+		config:  cfg,
+		log:     log,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start runs the purge loop in the background until Close is called.
+func (w *PurgeWorker) Start() {
+	if w.config.PurgeIntervalSec <= 0 {
+		return
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(time.Duration(w.config.PurgeIntervalSec) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			w.RunOnce(w.ctx)
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce performs a single purge pass.
+func (w *PurgeWorker) RunOnce(ctx context.Context) {
+	w.expirePendingChanges(ctx)
+	w.deleteIntents(ctx)
+	w.deleteExpiredDemoBoards(ctx)
+
+	if w.config.InstructionDays <= 0 {
+		return
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -w.config.InstructionDays)
+	redacted, err := w.queries.RedactInstructionsBefore(ctx, cutoff)
+	if err != nil {
+		w.log.Error(ctx, "Failed to redact instructions", "error", err)
+		return
+	}
+	if redacted > 0 {
+		w.log.Info(ctx, "Redacted expired instructions", "count", redacted, "cutoff", cutoff)
+	}
+	if held, err := w.queries.CountHeldInstructionsBefore(ctx, cutoff); err != nil {
+		w.log.Error(ctx, "Failed to count held instructions", "error", err)
+	} else if held > 0 {
+		w.log.Info(ctx, "Skipped expired instructions on boards under legal hold", "count", held, "cutoff", cutoff)
+	}
+
+	// Timing records are kept as long as the instruction text.
+	deleted, err := w.queries.DeleteInstructionTimingsBefore(ctx, cutoff)
+	if err != nil {
+		w.log.Error(ctx, "Failed to delete instruction timings", "error", err)
+		return
+	}
+	if deleted > 0 {
+		w.log.Info(ctx, "Deleted expired instruction timings", "count", deleted, "cutoff", cutoff)
+	}
+	if held, err := w.queries.CountHeldInstructionTimingsBefore(ctx, cutoff); err != nil {
+		w.log.Error(ctx, "Failed to count held instruction timings", "error", err)
+	} else if held > 0 {
+		w.log.Info(ctx, "Skipped expired instruction timings on boards under legal hold", "count", held, "cutoff", cutoff)
+	}
+}
+
+// expirePendingChanges marks pending changes past their deadline as expired.
+// Expired changes are already hidden from reads; this just settles their status.
+func (w *PurgeWorker) expirePendingChanges(ctx context.Context) {
+	expired, err := w.queries.ExpirePendingChanges(ctx)
+	if err != nil {
+		w.log.Error(ctx, "Failed to expire pending changes", "error", err)
+		return
+	}
+	if expired > 0 {
+		w.log.Info(ctx, "Expired pending changes", "count", expired)
+	}
+}
+
+// deleteIntents removes old instruction intents; they only matter while an
+// instruction is in flight or about to be resumed.
+func (w *PurgeWorker) deleteIntents(ctx context.Context) {
+	deleted, err := w.queries.DeleteIntentsBefore(ctx, time.Now().UTC().Add(-intentTTL))
+	if err != nil {
+		w.log.Error(ctx, "Failed to delete instruction intents", "error", err)
+		return
+	}
+	if deleted > 0 {
+		w.log.Info(ctx, "Deleted old instruction intents", "count", deleted)
+	}
+}
+
+// deleteExpiredDemoBoards removes demo boards past their expiry. They are
+// already hidden from reads.
+func (w *PurgeWorker) deleteExpiredDemoBoards(ctx context.Context) {
+	deleted, err := w.queries.DeleteExpiredDemoBoards(ctx)
+	if err != nil {
+		w.log.Error(ctx, "Failed to delete expired demo boards", "error", err)
+		return
+	}
+	if deleted > 0 {
+		w.log.Info(ctx, "Deleted expired demo boards", "count", deleted)
+	}
+}
+
+func (w *PurgeWorker) Close() error {
+	w.closeOnce.Do(func() {
+		w.cancel()
+		w.wg.Wait()
+	})
+	return nil
+}
@@ -0,0 +1,179 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"draw/internal/db/repo"
+	"draw/pkg/config"
+	"draw/pkg/logger"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB records the queries a purge pass runs. Writes report affected
+// rows and counts report held rows, as the test sets them.
+type fakeDB struct {
+	sql      map[string]string
+	affected map[string]string
+	held     int64
+}
+
+func newFakeDB(held int64) *fakeDB {
+	return &fakeDB{
+		sql: make(map[string]string),
+		affected: map[string]string{
+			"RedactInstructionsBefore":       "UPDATE 3",
+			"DeleteInstructionTimingsBefore": "DELETE 2",
+		},
+		held: held,
+	}
+}
+
+func (f *fakeDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	name := queryName(sql)
+	f.sql[name] = sql
+	if tag, ok := f.affected[name]; ok {
+		return pgconn.NewCommandTag(tag), nil
+	}
+	return pgconn.NewCommandTag("UPDATE 0"), nil
+}
+
+func (f *fakeDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	panic("unexpected query " + queryName(sql))
+}
+
+func (f *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	f.sql[queryName(sql)] = sql
+	return countRow(f.held)
+}
+
+type countRow int64
+
+func (r countRow) Scan(dest ...interface{}) error {
+	*dest[0].(*int64) = int64(r)
+	return nil
+}
+
+func queryName(sql string) string {
+	rest, _ := strings.CutPrefix(sql, "-- name: ")
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func newTestWorker(db *fakeDB, instructionDays int) (*PurgeWorker, *bytes.Buffer) {
+	var out bytes.Buffer
+	log := logger.NewLogger(logger.Config{
+		MinLevel: logger.LevelInfo,
+		Service:  "test",
+		Handlers: []io.WriteCloser{nopCloser{&out}},
+	})
+	return NewPurgeWorker(repo.New(db), &config.RetentionConfig{InstructionDays: instructionDays}, log), &out
+}
+
+func TestPurgeSkipsHeldBoards(t *testing.T) {
+	db := newFakeDB(4)
+	w, out := newTestWorker(db, 30)
+	w.RunOnce(context.Background())
+
+	tests := []struct {
+		job     string
+		query   string
+		counted string
+		skipped string
+	}{
+		{
+			job:     "instruction redaction",
+			query:   "RedactInstructionsBefore",
+			counted: "CountHeldInstructionsBefore",
+			skipped: "Skipped expired instructions on boards under legal hold",
+		},
+		{
+			job:     "timing purge",
+			query:   "DeleteInstructionTimingsBefore",
+			counted: "CountHeldInstructionTimingsBefore",
+			skipped: "Skipped expired instruction timings on boards under legal hold",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.job, func(t *testing.T) {
+			sql, ok := db.sql[tt.query]
+			if !ok {
+				t.Fatalf("%s didn't run", tt.query)
+			}
+			if !strings.Contains(sql, "NOT EXISTS") || !strings.Contains(sql, "legal_hold_at IS NOT NULL") {
+				t.Errorf("%s doesn't exclude held boards:\n%s", tt.query, sql)
+			}
+			if _, ok := db.sql[tt.counted]; !ok {
+				t.Errorf("%s didn't run", tt.counted)
+			}
+			if !strings.Contains(out.String(), tt.skipped) {
+				t.Errorf("log doesn't report %q:\n%s", tt.skipped, out.String())
+			}
+		})
+	}
+}
+
+func TestPurgeWithoutRetention(t *testing.T) {
+	db := newFakeDB(4)
+	w, out := newTestWorker(db, 0)
+	w.RunOnce(context.Background())
+
+	for _, query := range []string{"RedactInstructionsBefore", "DeleteInstructionTimingsBefore", "CountHeldInstructionsBefore"} {
+		if _, ok := db.sql[query]; ok {
+			t.Errorf("%s ran with no retention window", query)
+		}
+	}
+	for _, query := range []string{"ExpirePendingChanges", "DeleteIntentsBefore", "DeleteExpiredDemoBoards"} {
+		if _, ok := db.sql[query]; !ok {
+			t.Errorf("%s didn't run", query)
+		}
+	}
+	if strings.Contains(out.String(), "legal hold") {
+		t.Errorf("log reports held boards with no retention window:\n%s", out.String())
+	}
+}
+
+func TestPurgeNothingHeld(t *testing.T) {
+	db := newFakeDB(0)
+	w, out := newTestWorker(db, 30)
+	w.RunOnce(context.Background())
+
+	if strings.Contains(out.String(), "legal hold") {
+		t.Errorf("log reports held boards when none are held:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Redacted expired instructions") {
+		t.Errorf("log doesn't report the redaction:\n%s", out.String())
+	}
+}
+
+func TestRedactionKeepsStructuralData(t *testing.T) {
+	db := newFakeDB(0)
+	w, _ := newTestWorker(db, 30)
+	w.RunOnce(context.Background())
+
+	sql := db.sql["RedactInstructionsBefore"]
+	set, _, ok := strings.Cut(sql, "WHERE")
+	if !ok {
+		t.Fatalf("redaction has no WHERE clause:\n%s", sql)
+	}
+	if !strings.Contains(set, "instruction = ''") || !strings.Contains(set, "redacted_at") {
+		t.Errorf("redaction doesn't blank the text and mark the row:\n%s", set)
+	}
+	// Undo, the board history and the activity feed read these.
+	for _, column := range []string{"inverse_action", "undone_at", "raw_response", "error", "intent", "outcome", "created_at"} {
+		if strings.Contains(set, column) {
+			t.Errorf("redaction changes %s:\n%s", column, set)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/pkg/config"
+	"draw/pkg/logger"
+	"draw/pkg/whiteboard"
+)
+
+// maxFailedPerDay caps how many failed instructions are clustered per day.
+const maxFailedPerDay = 5000
+
+// RollupWorker periodically rolls the instruction records up into daily
+// per-intent counts and failing instruction patterns for the analytics
+// endpoint. The trailing days are recomputed on every run, so rows recorded
+// late are still counted; older days keep their rollups after the raw
+// instructions are redacted.
+type RollupWorker struct {
+	queries   *repo.Queries
+	config    *config.AnalyticsConfig
+	log       *logger.Logger
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewRollupWorker(queries *repo.Queries, cfg *config.AnalyticsConfig, log *logger.Logger) *RollupWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RollupWorker{
+		queries: queries,
+		config:  cfg,
+		log:     log,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start runs the rollup loop in the background until Close is called.
+func (w *RollupWorker) Start() {
+	if w.config.RollupIntervalSec <= 0 {
+		return
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(time.Duration(w.config.RollupIntervalSec) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			w.RunOnce(w.ctx)
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce recomputes the rollups for the trailing days.
+func (w *RollupWorker) RunOnce(ctx context.Context) {
+	days := w.config.RollupDays
+	if days < 1 {
+		days = 1
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for i := days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		if err := w.rollupDay(ctx, day); err != nil {
+			w.log.Error(ctx, "Failed to roll up instructions", "day", day.Format(time.DateOnly), "error", err)
+			return
+		}
+	}
+}
+
+func (w *RollupWorker) rollupDay(ctx context.Context, day time.Time) error {
+	until := day.AddDate(0, 0, 1)
+	if err := w.queries.RollupInstructionIntents(ctx, repo.RollupInstructionIntentsParams{
+		Since: day,
+		Until: until,
+	}); err != nil {
+		return err
+	}
+
+	failed, err := w.queries.GetFailedInstructionsBetween(ctx, repo.GetFailedInstructionsBetweenParams{
+		Since:   day,
+		Until:   until,
+		MaxRows: maxFailedPerDay,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Cluster per intent, so a pattern is counted against the intent its
+	// instructions were classified as.
+	byIntent := make(map[string][]string)
+	for _, row := range failed {
+		byIntent[row.Intent] = append(byIntent[row.Intent], row.Instruction)
+	}
+
+	if err := w.queries.DeleteFailurePatterns(ctx, day); err != nil {
+		return err
+	}
+	for intent, instructions := range byIntent {
+		for _, pattern := range whiteboard.ClusterInstructions(instructions) {
+			if err := w.queries.UpsertFailurePattern(ctx, repo.UpsertFailurePatternParams{
+				Day:     day,
+				Intent:  intent,
+				Pattern: truncatePattern(pattern.Pattern),
+				Count:   int64(len(pattern.Members)),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// truncatePattern keeps a pattern within its column.
+func truncatePattern(pattern string) string {
+	const maxLen = 255
+	if len(pattern) <= maxLen {
+		return pattern
+	}
+	return pattern[:maxLen]
+}
+
+func (w *RollupWorker) Close() error {
+	w.closeOnce.Do(func() {
+		w.cancel()
+		w.wg.Wait()
+	})
+	return nil
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"sync"
+
+	"draw/internal/db/repo"
+	"draw/pkg/logger"
+)
+
+// timingQueueSize is how many timing records may wait to be written.
+const timingQueueSize = 1000
+
+// TimingWriter writes instruction timing records in the background, so the
+// voice path never waits on the database for them. Records that arrive while
+// the queue is full are dropped.
+type TimingWriter struct {
+	queries   *repo.Queries
+	log       *logger.Logger
+	queue     chan repo.CreateInstructionTimingParams
+	mu        sync.RWMutex
+	closed    bool
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewTimingWriter(queries *repo.Queries, log *logger.Logger) *TimingWriter {
+	return &TimingWriter{
+		queries: queries,
+		log:     log,
+		queue:   make(chan repo.CreateInstructionTimingParams, timingQueueSize),
+	}
+}
+
+// Start writes queued records in the background until Close is called.
+func (w *TimingWriter) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ctx := context.Background()
+		for params := range w.queue {
+			if err := w.queries.CreateInstructionTiming(ctx, params); err != nil {
+				w.log.Error(ctx, "Failed to write instruction timing", "error", err, "requestId", params.RequestID)
+			}
+		}
+	}()
+}
+
+// Enqueue queues a record for writing. It reports false if the record was
+// dropped.
+func (w *TimingWriter) Enqueue(params repo.CreateInstructionTimingParams) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+	select {
+	case w.queue <- params:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting records and waits for the queued ones to be written.
+func (w *TimingWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		close(w.queue)
+		w.mu.Unlock()
+		w.wg.Wait()
+	})
+	return nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// DemoSessionCookie is the cookie holding a signed anonymous demo session.
+const DemoSessionCookie = "demo_session"
+
+var errInvalidDemoSession = errors.New("invalid demo session")
+
+// NewDemoSessionID returns a random anonymous session ID.
+func NewDemoSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SignDemoSession returns the cookie value for a session ID: the ID and its
+// HMAC, so clients can't pick another session's ID.
+func SignDemoSession(secret []byte, sessionID string) string {
+	return sessionID + "." + demoSignature(secret, sessionID)
+}
+
+// VerifyDemoSession returns the session ID from a cookie value made by
+// SignDemoSession.
+func VerifyDemoSession(secret []byte, value string) (string, error) {
+	sessionID, signature, ok := strings.Cut(value, ".")
+	if !ok || sessionID == "" || len(secret) == 0 {
+		return "", errInvalidDemoSession
+	}
+	if !hmac.Equal([]byte(signature), []byte(demoSignature(secret, sessionID))) {
+		return "", errInvalidDemoSession
+	}
+	return sessionID, nil
+}
+
+func demoSignature(secret []byte, sessionID string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,174 @@
+// Package bundle reads and writes board bundles: a zip of JSON parts that
+// carries a whole board between deployments.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"draw/pkg/llm"
+)
+
+// FormatVersion is the bundle format written by this build. Bundles with a
+// newer version are rejected; older ones are read part by part.
+const FormatVersion = 1
+
+// Part names.
+const (
+	PartBoard    = "board.json"
+	PartSettings = "settings.json"
+	PartComments = "comments.json"
+	manifestName = "manifest.json"
+)
+
+// Manifest describes the bundle and lists its parts.
+type Manifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	ExportedAt    time.Time `json:"exportedAt"`
+	Parts         []string  `json:"parts"`
+}
+
+type Board struct {
+	Name     string        `json:"name"`
+	Elements []llm.Element `json:"elements"`
+}
+
+type Settings struct {
+	Icon        *string `json:"icon,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	Protected   bool    `json:"protected"`
+	Timezone    *string `json:"timezone,omitempty"`
+	Locale      *string `json:"locale,omitempty"`
+	ArrowRepair string  `json:"arrowRepair,omitempty"`
+}
+
+type Comment struct {
+	ElementID *string   `json:"elementId,omitempty"`
+	AuthorID  string    `json:"authorId"`
+	Text      string    `json:"text"`
+	Resolved  bool      `json:"resolved"`
+	Orphaned  bool      `json:"orphaned"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Bundle is the decoded content of a bundle file. Settings and Comments are
+// nil when the bundle doesn't include them.
+type Bundle struct {
+	Manifest Manifest
+	Board    Board
+	Settings *Settings
+	Comments []Comment
+
+	// Skipped lists parts that were present but not imported, with why.
+	Skipped []string
+}
+
+// Write encodes b as a zip to w.
+func Write(w io.Writer, b *Bundle) error {
+	parts := map[string]any{PartBoard: b.Board}
+	if b.Settings != nil {
+		parts[PartSettings] = b.Settings
+	}
+	if b.Comments != nil {
+		parts[PartComments] = b.Comments
+	}
+
+	manifest := Manifest{
+		FormatVersion: FormatVersion,
+		ExportedAt:    time.Now().UTC(),
+	}
+	for _, name := range []string{PartBoard, PartSettings, PartComments} {
+		if _, ok := parts[name]; ok {
+			manifest.Parts = append(manifest.Parts, name)
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	if err := writePart(zw, manifestName, manifest); err != nil {
+		return err
+	}
+	for _, name := range manifest.Parts {
+		if err := writePart(zw, name, parts[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writePart(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Read decodes a bundle. The board part is required; other parts this build
+// doesn't know are listed in Skipped rather than failing the import.
+func Read(data []byte) (*Bundle, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("bundle is not a zip file: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	b := &Bundle{}
+	if err := readPart(files, manifestName, &b.Manifest); err != nil {
+		return nil, err
+	}
+	if b.Manifest.FormatVersion < 1 || b.Manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d", b.Manifest.FormatVersion)
+	}
+
+	hasBoard := false
+	for _, name := range b.Manifest.Parts {
+		var err error
+		switch name {
+		case PartBoard:
+			hasBoard = true
+			err = readPart(files, name, &b.Board)
+		case PartSettings:
+			b.Settings = &Settings{}
+			err = readPart(files, name, b.Settings)
+		case PartComments:
+			err = readPart(files, name, &b.Comments)
+		default:
+			b.Skipped = append(b.Skipped, name+": not supported by this server")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !hasBoard {
+		return nil, fmt.Errorf("bundle has no %s", PartBoard)
+	}
+	return b, nil
+}
+
+func readPart(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("bundle is missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return nil
+}
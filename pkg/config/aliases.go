@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envAlias maps an alternative variable name onto the one LoadConfig reads.
+// Deprecated aliases are old names we still honour but warn about; the rest
+// are standard names (e.g. the AWS SDK's) that are accepted silently.
+type envAlias struct {
+	Name       string
+	Alias      string
+	Deprecated bool
+}
+
+var envAliases = []envAlias{
+	{Name: "LLM_HOST", Alias: "OLLAMA_HOST", Deprecated: true},
+	{Name: "AWS_ACCESS_KEY", Alias: "AWS_ACCESS_KEY_ID"},
+	{Name: "AWS_SECRET_KEY", Alias: "AWS_SECRET_ACCESS_KEY"},
+	{Name: "AWS_REGION", Alias: "AWS_DEFAULT_REGION"},
+}
+
+// getEnv returns the value of key, falling back to any of its aliases.
+func getEnv(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	for _, alias := range envAliases {
+		if alias.Name != key {
+			continue
+		}
+		if value := os.Getenv(alias.Alias); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// checkEnvAliases reports deprecated names in use and fails when a name and
+// its alias are both set to different values.
+func checkEnvAliases() ([]string, error) {
+	var warnings, conflicts []string
+	for _, alias := range envAliases {
+		aliasValue := os.Getenv(alias.Alias)
+		if aliasValue == "" {
+			continue
+		}
+		value := os.Getenv(alias.Name)
+		switch {
+		case value == "":
+			if alias.Deprecated {
+				warnings = append(warnings, fmt.Sprintf("%s is deprecated, use %s instead", alias.Alias, alias.Name))
+			}
+		case value != aliasValue:
+			conflicts = append(conflicts, fmt.Sprintf("%s and %s are both set but differ", alias.Name, alias.Alias))
+		}
+	}
+	if len(conflicts) > 0 {
+		return warnings, fmt.Errorf("conflicting environment variables: %s", strings.Join(conflicts, "; "))
+	}
+	return warnings, nil
+}
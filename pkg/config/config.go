@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type DBConfig struct {
@@ -17,19 +19,89 @@ type DBConfig struct {
 type ServerConfig struct {
 	Port                int
 	GracefulShutdownSec int
+	LLMRequestsPerMin   int // Requests each caller may make per minute to routes that run the LLM; 0 disables the limit
 }
 
 type AppConfig struct {
-	DB       DBConfig
-	Server   ServerConfig
-	Auth     AuthConfig
-	LiveKit  LiveKitConfig
-	AWS      AWSConfig
-	Gemini   GeminiConfig
-	LLM      LLMConfig
-	Speech   SpeechConfig
-	LogLevel string
-	Env      string
+	DB         DBConfig
+	Server     ServerConfig
+	Auth       AuthConfig
+	LiveKit    LiveKitConfig
+	AWS        AWSConfig
+	Gemini     GeminiConfig
+	LLM        LLMConfig
+	Speech     SpeechConfig
+	Retention  RetentionConfig
+	Board      BoardConfig
+	SLO        SLOConfig
+	Recording  RecordingConfig
+	LLMHistory LLMHistoryConfig
+	Encryption EncryptionConfig
+	Analytics  AnalyticsConfig
+	Sandbox    SandboxConfig
+	Demo       DemoConfig
+	LogLevel   string
+	Env        string
+
+	// Deprecations lists deprecated settings found while loading, to be
+	// logged once a logger is available.
+	Deprecations []string
+}
+
+type SLOConfig struct {
+	LatencyTargetMs int     // Voice instructions should be applied within this long
+	Objective       float64 // Fraction of instructions that must meet the target
+}
+
+// RecordingConfig controls the session recorder, which captures every voice
+// instruction for replay. It is refused in production.
+type RecordingConfig struct {
+	Enabled bool   // Whether voice sessions are recorded
+	Dir     string // Directory recordings are written to
+}
+
+// LLMHistoryConfig bounds the in-memory history of each board's recent model
+// responses, kept for support to debug odd results.
+type LLMHistoryConfig struct {
+	Enabled  bool // Whether the history is kept
+	Size     int  // Instructions kept per board
+	TTLSec   int  // How long an instruction is kept
+	MaxBytes int  // Memory all boards' histories may use together
+}
+
+// EncryptionConfig holds the master keys board content is encrypted with.
+// Keys are base64-encoded 32-byte values; no key disables encryption.
+type EncryptionConfig struct {
+	Key          string   // Master key new data keys are wrapped with
+	PreviousKeys []string // Retired master keys, kept until rotation has re-wrapped every data key
+}
+
+// AnalyticsConfig controls the job that rolls instruction records up into
+// daily per-intent counts.
+type AnalyticsConfig struct {
+	RollupIntervalSec int // How often the rollup job runs
+	RollupDays        int // How many trailing days (UTC) each run recomputes
+}
+
+// SandboxConfig limits the instruction sandbox, which calls the LLM outside
+// the monthly budget.
+type SandboxConfig struct {
+	RequestsPerMin int // Sandbox requests each user may make per minute
+	RequestsPerDay int // Sandbox requests each user may make per day (UTC)
+	MaxBoardBytes  int // Largest board state accepted
+}
+
+// DemoConfig controls the anonymous "try it" mode. Demo boards are kept
+// apart from real ones, expire, and use the local fallback model.
+type DemoConfig struct {
+	Enabled             bool   // Whether the demo routes are served
+	SessionSecret       string // Key demo session cookies are signed with; required when enabled
+	TTLMin              int    // Minutes a demo board lives, extended on each change
+	MaxTTLMin           int    // Minutes after creation a demo board expires however active it is
+	MaxElements         int    // Elements a demo board may hold
+	MaxInstructions     int    // Voice-style instructions a demo board may run
+	MaxBoardsPerSession int    // Live demo boards one anonymous session may have
+	RequestsPerMin      int    // Demo requests each client IP may make per minute
 }
 
 type AuthConfig struct {
@@ -37,9 +109,11 @@ type AuthConfig struct {
 }
 
 type LiveKitConfig struct {
-	Host      string
-	APIKey    string
-	APISecret string
+	Host               string
+	APIKey             string
+	APISecret          string
+	RoomIdleTimeoutSec int // How long a room may sit without participants before it is deleted
+	ReapIntervalSec    int // How often idle rooms are checked
 }
 
 type AWSConfig struct {
@@ -56,32 +130,202 @@ type GeminiConfig struct {
 }
 
 type LLMConfig struct {
-	Provider string // "ollama", "gemini", or "nvidia"
-	Host     string // Provider host or base URL
-	Model    string // Model name (e.g., "llama3.2", "qwen2.5")
-	APIKey   string // API key for providers that require it (e.g., Nvidia)
+	Provider string    // "ollama", "nvidia", "openai", "gemini", "bedrock" or "generic-openai"
+	Failover []string  // Providers tried in order while the primary one fails; only "ollama", on FallbackHost and FallbackModel
+	Host     string    // Provider host or base URL
+	Model    string    // Model name (e.g., "llama3.2", "qwen2.5")
+	APIKey   string    // API key for providers that require it (e.g., Nvidia)
+	AWS      AWSConfig // Credentials and region for the bedrock provider; the same AWS_* settings S3 uses
+
+	InteractiveQueueSize int     // Capacity of the queue for requests users are waiting on
+	BackgroundQueueSize  int     // Capacity of the queue for background work
+	Concurrency          int     // Requests sent to the provider at once
+	QueueSize            int     // Requests that may wait for the provider before new ones are refused as busy
+	Temperature          float64 // Sampling temperature unless a request sets its own
+	MaxTokens            int     // Longest answer the provider may generate
+	RequestTimeoutSec    int     // How long one provider call may take
+	StructuredOutput     bool    // Constrain ollama and nvidia output to the action JSON schema; needs a recent Ollama
+	PromptVersion        string  // System prompt version to send; see PromptsDir
+	PromptsDir           string  // Directory of <version>.txt system prompts that add to or replace the built-in ones
+	CacheSize            int     // Responses kept for repeated instructions on an identical board; 0 disables the cache
+	CacheTTLSec          int     // How long a cached response is reused; 0 keeps it until evicted
+	RateLimitPerMinute   int     // Voice instructions one user may send the provider per minute; 0 disables the limit
+	RateLimitBurst       int     // Instructions a user may send at once before the per-minute rate applies
+	StartupCheck         bool    // Ping the provider at startup and refuse to start when it can't be used
+	ProviderAttempts     int     // Tries per provider call when the API is throttled, failing or unreachable (nvidia only)
+	MaxAttempts          int     // How many times an instruction is tried before it fails
+	RepairAttempts       int     // How many times the model is asked to fix output that isn't valid JSON; 0 disables it
+	PromptTokenBudget    int     // Estimated tokens the user prompt may use before optional sections are dropped; 0 disables the cap
+	BoardStateMaxBytes   int     // Size the board state JSON is compacted to fit in the prompt; 0 disables the cap
+	ConversationTurns    int     // Earlier instructions on a board sent along with a new one; 0 disables history
+	ConversationIdleSec  int     // How long a board's instruction history is kept after its last instruction
+	ResumeWindowSec      int     // Instructions cut off by a restart are re-run if their board is opened within this many seconds; 0 disables it
+	FastPath             bool    // Whether simple move/resize/recolor/delete commands are answered without the LLM
+	StrictValidation     bool    // Whether actions binding arrows to unknown elements are rejected rather than having the bindings dropped
+	NormalizeColors      bool    // Whether colors outside the prompt's palette are snapped to the nearest palette color
+	AutoLayout           bool    // Whether flowcharts added by the LLM are laid out by the server instead of at the coordinates it chose
+	LogRequests          bool    // Log every provider call with its size, duration, token usage and error
+	Metrics              bool    // Serve provider call latency and token usage at /metrics in the Prometheus format
+	BatchInstructions    bool    // Whether utterances spoken while an instruction is handled are sent to the LLM together once it is done
+
+	ServiceAccountRateLimitPerMinute int // Voice instructions one service account may send the provider per minute; 0 disables the limit
+	ServiceAccountRateLimitBurst     int // Instructions a service account may send at once before the per-minute rate applies
+
+	PromptPricePerMTok     float64 // USD per million prompt tokens on the primary provider
+	CompletionPricePerMTok float64 // USD per million completion tokens on the primary provider
+
+	MonthlyBudgetUSD    float64 // Spend per calendar month (UTC) before the budget is exhausted; 0 disables it
+	BudgetWarnRatio     float64 // Fraction of the budget at which a warning is logged
+	BudgetExhaustedMode string  // "downgrade" to the fallback provider or "reject" requests
+	BudgetCacheSec      int     // How long persisted spend is cached between queries
+	FallbackHost        string  // Ollama host used while downgraded
+	FallbackModel       string  // Ollama model used while downgraded
+
+	APIKeyHeader string            // Header the generic-openai provider sends APIKey in, as-is; empty sends "Authorization: Bearer <key>"
+	ExtraHeaders map[string]string // Headers the generic-openai provider sends with every request
+
+	ModelAliases []string // "old=new" replacements used when the provider reports a model as retired
 }
 
 type SpeechConfig struct {
-	Host string // gRPC host:port for Python speech service
+	Host            string // gRPC host:port for Python speech service
+	MaxUtteranceSec int    // Longest audio accepted in one streamed upload
+}
+
+type RetentionConfig struct {
+	InstructionDays     int  // Days instruction text is kept verbatim before redaction (0 keeps it forever)
+	StoreRawLLMOutput   bool // Whether raw model output is persisted with each instruction
+	AllowAudioRetention bool // Whether session audio may be recorded
+	PurgeIntervalSec    int  // How often the purge worker runs
+	// Whether the analytics endpoint may return raw instruction samples for
+	// failing patterns. Redacted instructions are never returned.
+	AllowAnalyticsSamples bool
+}
+
+type BoardConfig struct {
+	AllowCustomColors   bool // Whether board colors may be any hex value instead of the palette
+	PendingChangeTTLSec int  // How long a change on a protected board waits for approval
+
+	ConfirmDeleteCount int     // Elements one voice instruction may delete before the user must confirm it; 0 disables the check
+	ConfirmDeleteRatio float64 // Share of the board one voice instruction may delete before the user must confirm it; 0 disables the check
+	ConfirmRewrite     bool    // Whether voice updates that change every element on the board need confirming
+
+	PresentationRequestsPerMin   int // Requests each presentation token may make per minute
+	ServiceAccountRequestsPerMin int // Requests each service account may make per minute
+
+	ImageMaxBytes  int // Largest image that may be uploaded to a board
+	ImageURLTTLSec int // How long presigned image upload and download URLs stay valid
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := getEnv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	if value := getEnv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	if value := getEnv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList splits a comma-separated value, dropping empty entries.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvMap splits a comma-separated list of "name=value" pairs, dropping
+// entries without a name.
+func getEnvMap(key string) map[string]string {
+	values := make(map[string]string)
+	for _, entry := range getEnvList(key) {
+		name, value, _ := strings.Cut(entry, "=")
+		if name = strings.TrimSpace(name); name != "" {
+			values[name] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	if value := getEnv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 func LoadConfig() (*AppConfig, error) {
+	deprecations, err := checkEnvAliases()
+	if err != nil {
+		return nil, err
+	}
+
 	portStr := os.Getenv("DB_PORT")
 	portInt, err := strconv.Atoi(portStr)
 	if err != nil {
 		portInt = 5432
 	}
-	provider := getEnvOrDefault("LLM_PROVIDER", "ollama")
+	// LLM_PROVIDER may list failover providers after the primary one, e.g.
+	// "nvidia,ollama".
+	providers := getEnvList("LLM_PROVIDER")
+	if len(providers) == 0 {
+		providers = []string{"ollama"}
+	}
+	provider := providers[0]
+	for _, failover := range providers[1:] {
+		// The others would need hosts and credentials of their own.
+		if failover != "ollama" {
+			return nil, fmt.Errorf("unsupported failover LLM provider %q in LLM_PROVIDER: only ollama can follow the primary provider", failover)
+		}
+	}
 	defaultLLMHost := "http://localhost:11434"
 	defaultLLMModel := "llama3.2"
+	// Ollama has always run cooler, with a longer answer and a shorter timeout
+	// than the hosted providers.
+	defaultTemperature, defaultMaxTokens, defaultTimeoutSec := 0.1, 2000, 10
+	if provider != "ollama" {
+		defaultTemperature, defaultMaxTokens, defaultTimeoutSec = 0.2, 1024, 20
+	}
+	llmAPIKey := os.Getenv("LLM_API_KEY")
+	switch provider {
+	case "openai":
+		defaultLLMHost = "https://api.openai.com/v1"
+	case "gemini":
+		defaultLLMHost = "https://generativelanguage.googleapis.com/v1beta"
+		defaultLLMModel = getEnvOrDefault("GEMINI_CHAT_MODEL", "gemini-2.0-flash")
+		if llmAPIKey == "" {
+			llmAPIKey = os.Getenv("GEMINI_API_KEY")
+		}
+	case "bedrock":
+		// Empty uses the region's bedrock-runtime endpoint.
+		defaultLLMHost = ""
+		defaultLLMModel = "anthropic.claude-3-haiku-20240307-v1:0"
+	case "generic-openai":
+		// No default: LLM_HOST names the server, e.g.
+		// https://api.groq.com/openai/v1.
+		defaultLLMHost = ""
+	}
 
 	config := &AppConfig{
 		DB: DBConfig{
@@ -95,19 +339,22 @@ func LoadConfig() (*AppConfig, error) {
 		Server: ServerConfig{
 			Port:                9000,
 			GracefulShutdownSec: 5,
+			LLMRequestsPerMin:   getEnvIntOrDefault("SERVER_LLM_REQUESTS_PER_MIN", 30),
 		},
 		Auth: AuthConfig{
 			JwksURL: os.Getenv("JWKS_URL"),
 		},
 		LiveKit: LiveKitConfig{
-			Host:      os.Getenv("LK_HOST"),
-			APIKey:    os.Getenv("LK_API_KEY"),
-			APISecret: os.Getenv("LK_API_SECRET"),
+			Host:               os.Getenv("LK_HOST"),
+			APIKey:             os.Getenv("LK_API_KEY"),
+			APISecret:          os.Getenv("LK_API_SECRET"),
+			RoomIdleTimeoutSec: getEnvIntOrDefault("LK_ROOM_IDLE_TIMEOUT_SEC", 300),
+			ReapIntervalSec:    getEnvIntOrDefault("LK_REAP_INTERVAL_SEC", 60),
 		},
 		AWS: AWSConfig{
-			AccessKey: os.Getenv("AWS_ACCESS_KEY"),
-			SecretKey: os.Getenv("AWS_SECRET_KEY"),
-			Region:    os.Getenv("AWS_REGION"),
+			AccessKey: getEnv("AWS_ACCESS_KEY"),
+			SecretKey: getEnv("AWS_SECRET_KEY"),
+			Region:    getEnv("AWS_REGION"),
 			Bucket:    os.Getenv("AWS_S3_BUCKET"),
 		},
 		Gemini: GeminiConfig{
@@ -116,16 +363,128 @@ func LoadConfig() (*AppConfig, error) {
 			APIKey:        os.Getenv("GEMINI_API_KEY"),
 		},
 		Speech: SpeechConfig{
-			Host: getEnvOrDefault("SPEECH_SERVICE_HOST", "localhost:50051"),
+			Host:            getEnvOrDefault("SPEECH_SERVICE_HOST", "localhost:50051"),
+			MaxUtteranceSec: getEnvIntOrDefault("SPEECH_MAX_UTTERANCE_SEC", 30),
 		},
 		LLM: LLMConfig{
 			Provider: provider,
+			Failover: providers[1:],
 			Host:     getEnvOrDefault("LLM_HOST", defaultLLMHost),
 			Model:    getEnvOrDefault("LLM_MODEL", defaultLLMModel),
-			APIKey:   os.Getenv("LLM_API_KEY"),
+			APIKey:   llmAPIKey,
+
+			InteractiveQueueSize: getEnvIntOrDefault("LLM_INTERACTIVE_QUEUE_SIZE", 10),
+			BackgroundQueueSize:  getEnvIntOrDefault("LLM_BACKGROUND_QUEUE_SIZE", 50),
+			Concurrency:          getEnvIntOrDefault("LLM_CONCURRENCY", 4),
+			QueueSize:            getEnvIntOrDefault("LLM_QUEUE_SIZE", 10),
+			Temperature:          getEnvFloatOrDefault("LLM_TEMPERATURE", defaultTemperature),
+			MaxTokens:            getEnvIntOrDefault("LLM_MAX_TOKENS", defaultMaxTokens),
+			RequestTimeoutSec:    getEnvIntOrDefault("LLM_TIMEOUT_SEC", defaultTimeoutSec),
+			StructuredOutput:     getEnvBoolOrDefault("LLM_STRUCTURED_OUTPUT", false),
+			PromptVersion:        getEnvOrDefault("LLM_PROMPT_VERSION", "v1"),
+			PromptsDir:           getEnv("PROMPTS_DIR"),
+			CacheSize:            getEnvIntOrDefault("LLM_CACHE_SIZE", 0),
+			CacheTTLSec:          getEnvIntOrDefault("LLM_CACHE_TTL_SEC", 600),
+			RateLimitPerMinute:   getEnvIntOrDefault("LLM_RATE_LIMIT_PER_MIN", 0),
+			RateLimitBurst:       getEnvIntOrDefault("LLM_RATE_LIMIT_BURST", 5),
+			StartupCheck:         getEnvBoolOrDefault("LLM_STARTUP_CHECK", true),
+			ProviderAttempts:     getEnvIntOrDefault("LLM_PROVIDER_ATTEMPTS", 3),
+			MaxAttempts:          getEnvIntOrDefault("LLM_MAX_ATTEMPTS", 2),
+			RepairAttempts:       getEnvIntOrDefault("LLM_REPAIR_ATTEMPTS", 1),
+			PromptTokenBudget:    getEnvIntOrDefault("LLM_PROMPT_TOKEN_BUDGET", 0),
+			BoardStateMaxBytes:   getEnvIntOrDefault("LLM_BOARD_STATE_MAX_BYTES", 0),
+			ConversationTurns:    getEnvIntOrDefault("LLM_CONVERSATION_TURNS", 3),
+			ConversationIdleSec:  getEnvIntOrDefault("LLM_CONVERSATION_IDLE_SEC", 1800),
+			ResumeWindowSec:      getEnvIntOrDefault("LLM_RESUME_WINDOW_SEC", 120),
+			FastPath:             getEnvBoolOrDefault("LLM_FAST_PATH", true),
+			StrictValidation:     getEnvBoolOrDefault("LLM_STRICT_VALIDATION", true),
+			NormalizeColors:      getEnvBoolOrDefault("LLM_NORMALIZE_COLORS", false),
+			AutoLayout:           getEnvBoolOrDefault("LLM_AUTO_LAYOUT", false),
+			LogRequests:          getEnvBoolOrDefault("LLM_LOG_REQUESTS", false),
+			Metrics:              getEnvBoolOrDefault("LLM_METRICS", false),
+			BatchInstructions:    getEnvBoolOrDefault("LLM_BATCH_INSTRUCTIONS", false),
+
+			ServiceAccountRateLimitPerMinute: getEnvIntOrDefault("LLM_SERVICE_ACCOUNT_RATE_LIMIT_PER_MIN", 0),
+			ServiceAccountRateLimitBurst:     getEnvIntOrDefault("LLM_SERVICE_ACCOUNT_RATE_LIMIT_BURST", 5),
+
+			PromptPricePerMTok:     getEnvFloatOrDefault("LLM_PROMPT_PRICE_PER_MTOK", 0),
+			CompletionPricePerMTok: getEnvFloatOrDefault("LLM_COMPLETION_PRICE_PER_MTOK", 0),
+
+			MonthlyBudgetUSD:    getEnvFloatOrDefault("LLM_MONTHLY_BUDGET_USD", 0),
+			BudgetWarnRatio:     getEnvFloatOrDefault("LLM_BUDGET_WARN_RATIO", 0.8),
+			BudgetExhaustedMode: getEnvOrDefault("LLM_BUDGET_EXHAUSTED_MODE", "downgrade"),
+			BudgetCacheSec:      getEnvIntOrDefault("LLM_BUDGET_CACHE_SEC", 60),
+			FallbackHost:        getEnvOrDefault("LLM_FALLBACK_HOST", defaultLLMHost),
+			FallbackModel:       getEnvOrDefault("LLM_FALLBACK_MODEL", defaultLLMModel),
+
+			APIKeyHeader: os.Getenv("LLM_API_KEY_HEADER"),
+			ExtraHeaders: getEnvMap("LLM_EXTRA_HEADERS"),
+
+			ModelAliases: getEnvList("LLM_MODEL_ALIASES"),
+		},
+		Retention: RetentionConfig{
+			InstructionDays:     getEnvIntOrDefault("RETENTION_INSTRUCTION_DAYS", 0),
+			StoreRawLLMOutput:   getEnvBoolOrDefault("RETENTION_STORE_RAW_LLM_OUTPUT", true),
+			AllowAudioRetention: getEnvBoolOrDefault("RETENTION_ALLOW_AUDIO", false),
+			PurgeIntervalSec:    getEnvIntOrDefault("RETENTION_PURGE_INTERVAL_SEC", 3600),
+
+			AllowAnalyticsSamples: getEnvBoolOrDefault("RETENTION_ALLOW_ANALYTICS_SAMPLES", false),
+		},
+		Board: BoardConfig{
+			AllowCustomColors:   getEnvBoolOrDefault("BOARD_ALLOW_CUSTOM_COLORS", false),
+			PendingChangeTTLSec: getEnvIntOrDefault("BOARD_PENDING_CHANGE_TTL_SEC", 3600),
+
+			ConfirmDeleteCount: getEnvIntOrDefault("BOARD_CONFIRM_DELETE_COUNT", 10),
+			ConfirmDeleteRatio: getEnvFloatOrDefault("BOARD_CONFIRM_DELETE_RATIO", 0.5),
+			ConfirmRewrite:     getEnvBoolOrDefault("BOARD_CONFIRM_REWRITE", true),
+
+			PresentationRequestsPerMin:   getEnvIntOrDefault("BOARD_PRESENTATION_REQUESTS_PER_MIN", 30),
+			ServiceAccountRequestsPerMin: getEnvIntOrDefault("BOARD_SERVICE_ACCOUNT_REQUESTS_PER_MIN", 120),
+
+			ImageMaxBytes:  getEnvIntOrDefault("BOARD_IMAGE_MAX_BYTES", 10<<20),
+			ImageURLTTLSec: getEnvIntOrDefault("BOARD_IMAGE_URL_TTL_SEC", 900),
+		},
+		SLO: SLOConfig{
+			LatencyTargetMs: getEnvIntOrDefault("SLO_LATENCY_TARGET_MS", 4000),
+			Objective:       getEnvFloatOrDefault("SLO_OBJECTIVE", 0.95),
+		},
+		Recording: RecordingConfig{
+			Enabled: getEnvBoolOrDefault("SESSION_RECORDING_ENABLED", false),
+			Dir:     getEnvOrDefault("SESSION_RECORDING_DIR", "recordings"),
+		},
+		LLMHistory: LLMHistoryConfig{
+			Enabled:  getEnvBoolOrDefault("LLM_HISTORY_ENABLED", true),
+			Size:     getEnvIntOrDefault("LLM_HISTORY_SIZE", 10),
+			TTLSec:   getEnvIntOrDefault("LLM_HISTORY_TTL_SEC", 3600),
+			MaxBytes: getEnvIntOrDefault("LLM_HISTORY_MAX_BYTES", 8<<20),
+		},
+		Encryption: EncryptionConfig{
+			Key:          os.Getenv("ENCRYPTION_KEY"),
+			PreviousKeys: getEnvList("ENCRYPTION_PREVIOUS_KEYS"),
+		},
+		Analytics: AnalyticsConfig{
+			RollupIntervalSec: getEnvIntOrDefault("ANALYTICS_ROLLUP_INTERVAL_SEC", 900),
+			RollupDays:        getEnvIntOrDefault("ANALYTICS_ROLLUP_DAYS", 2),
+		},
+		Demo: DemoConfig{
+			Enabled:             getEnvBoolOrDefault("DEMO_ENABLED", false),
+			SessionSecret:       os.Getenv("DEMO_SESSION_SECRET"),
+			TTLMin:              getEnvIntOrDefault("DEMO_TTL_MIN", 30),
+			MaxTTLMin:           getEnvIntOrDefault("DEMO_MAX_TTL_MIN", 120),
+			MaxElements:         getEnvIntOrDefault("DEMO_MAX_ELEMENTS", 200),
+			MaxInstructions:     getEnvIntOrDefault("DEMO_MAX_INSTRUCTIONS", 20),
+			MaxBoardsPerSession: getEnvIntOrDefault("DEMO_MAX_BOARDS_PER_SESSION", 3),
+			RequestsPerMin:      getEnvIntOrDefault("DEMO_REQUESTS_PER_MIN", 60),
+		},
+		Sandbox: SandboxConfig{
+			RequestsPerMin: getEnvIntOrDefault("SANDBOX_REQUESTS_PER_MIN", 5),
+			RequestsPerDay: getEnvIntOrDefault("SANDBOX_REQUESTS_PER_DAY", 200),
+			MaxBoardBytes:  getEnvIntOrDefault("SANDBOX_MAX_BOARD_BYTES", 1<<20),
 		},
-		LogLevel: "info",
-		Env:      os.Getenv("APP_ENV"),
+		LogLevel:     "info",
+		Env:          os.Getenv("APP_ENV"),
+		Deprecations: deprecations,
 	}
+	config.LLM.AWS = config.AWS
 	return config, nil
 }
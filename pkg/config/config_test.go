@@ -0,0 +1,107 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFailoverProviders(t *testing.T) {
+	tests := []struct {
+		providers string
+		failover  []string
+		wantErr   bool
+	}{
+		{providers: "nvidia", failover: []string{}},
+		{providers: "nvidia, ollama", failover: []string{"ollama"}},
+		{providers: "nvidia,ollama,ollama", failover: []string{"ollama", "ollama"}},
+		{providers: "ollama,nvidia", wantErr: true},
+		{providers: "nvidia,gemini", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.providers, func(t *testing.T) {
+			t.Setenv("LLM_PROVIDER", tt.providers)
+			cfg, err := LoadConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadConfig succeeded, want an error for the failover providers")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if !reflect.DeepEqual(cfg.LLM.Failover, tt.failover) {
+				t.Errorf("failover = %q, want %q", cfg.LLM.Failover, tt.failover)
+			}
+		})
+	}
+}
+
+func TestEnvAliases(t *testing.T) {
+	field := map[string]func(cfg *AppConfig) string{
+		"LLM_HOST":       func(cfg *AppConfig) string { return cfg.LLM.Host },
+		"AWS_ACCESS_KEY": func(cfg *AppConfig) string { return cfg.AWS.AccessKey },
+		"AWS_SECRET_KEY": func(cfg *AppConfig) string { return cfg.AWS.SecretKey },
+		"AWS_REGION":     func(cfg *AppConfig) string { return cfg.AWS.Region },
+	}
+	if len(field) != len(envAliases) {
+		t.Fatalf("test covers %d aliases, want all %d", len(field), len(envAliases))
+	}
+
+	for _, alias := range envAliases {
+		get, ok := field[alias.Name]
+		if !ok {
+			t.Fatalf("no test for %s", alias.Name)
+		}
+		tests := []struct {
+			name      string
+			current   string
+			legacy    string
+			want      string
+			deprecate bool
+			conflict  bool
+		}{
+			{name: "current name only", current: "current-value", want: "current-value"},
+			{name: "alias only", legacy: "alias-value", want: "alias-value", deprecate: alias.Deprecated},
+			{name: "both agree", current: "same-value", legacy: "same-value", want: "same-value"},
+			{name: "both disagree", current: "current-value", legacy: "alias-value", conflict: true},
+		}
+		for _, tt := range tests {
+			t.Run(alias.Alias+"/"+tt.name, func(t *testing.T) {
+				for _, other := range envAliases {
+					t.Setenv(other.Name, "")
+					t.Setenv(other.Alias, "")
+				}
+				t.Setenv(alias.Name, tt.current)
+				t.Setenv(alias.Alias, tt.legacy)
+
+				cfg, err := LoadConfig()
+				if tt.conflict {
+					if err == nil {
+						t.Fatalf("LoadConfig succeeded, want a conflict")
+					}
+					if !strings.Contains(err.Error(), alias.Name) || !strings.Contains(err.Error(), alias.Alias) {
+						t.Errorf("error %q doesn't name both %s and %s", err, alias.Name, alias.Alias)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("LoadConfig: %v", err)
+				}
+				if got := get(cfg); got != tt.want {
+					t.Errorf("%s = %q, want %q", alias.Name, got, tt.want)
+				}
+				warned := false
+				for _, deprecation := range cfg.Deprecations {
+					if strings.Contains(deprecation, alias.Alias) && strings.Contains(deprecation, alias.Name) {
+						warned = true
+					}
+				}
+				if warned != tt.deprecate {
+					t.Errorf("deprecations = %q, want a warning naming both: %v", cfg.Deprecations, tt.deprecate)
+				}
+			})
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	cfg "draw/pkg/config"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -47,6 +49,14 @@ func (p *postgresDB) Connect() error {
 	config.MaxConnIdleTime = 30 * time.Minute
 	config.HealthCheckPeriod = 1 * time.Minute
 
+	// Keep every timestamp in UTC, both in the session and when scanned, so
+	// ordering and serialization don't depend on the server's time zone.
+	config.ConnConfig.RuntimeParams["timezone"] = "UTC"
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		scanInUTC(conn.TypeMap())
+		return nil
+	}
+
 	db, err := pgxpool.NewWithConfig(p.ctx, config)
 	if err != nil {
 		return err
@@ -59,6 +69,16 @@ func (p *postgresDB) Connect() error {
 	return nil
 }
 
+// scanInUTC makes timestamptz values scan into UTC times rather than the
+// server's local zone.
+func scanInUTC(typeMap *pgtype.Map) {
+	typeMap.RegisterType(&pgtype.Type{
+		Name:  "timestamptz",
+		OID:   pgtype.TimestamptzOID,
+		Codec: &pgtype.TimestamptzCodec{ScanLocation: time.UTC},
+	})
+}
+
 func (p *postgresDB) Close() error {
 	if p.db == nil {
 		log.Println("PostgreSQL database connection is already closed")
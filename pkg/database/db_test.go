@@ -0,0 +1,48 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestScanInUTC(t *testing.T) {
+	// Scans must not depend on the server's zone.
+	local := time.Local
+	time.Local = time.FixedZone("UTC+9", 9*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	typeMap := pgtype.NewMap()
+	scanInUTC(typeMap)
+	want := time.Date(2026, time.October, 14, 10, 30, 0, 0, time.UTC)
+	binary, err := typeMap.Encode(pgtype.TimestamptzOID, pgtype.BinaryFormatCode, want, nil)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		format int16
+		value  []byte
+	}{
+		{name: "text with offset", format: pgtype.TextFormatCode, value: []byte("2026-10-14 12:30:00+02")},
+		{name: "text in UTC", format: pgtype.TextFormatCode, value: []byte("2026-10-14 10:30:00+00")},
+		{name: "binary", format: pgtype.BinaryFormatCode, value: binary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got time.Time
+			if err := typeMap.Scan(pgtype.TimestamptzOID, tt.format, tt.value, &got); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("scanned into %v, want UTC", got.Location())
+			}
+			if !got.Equal(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "board_instruction" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	board_id UUID NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	instruction TEXT NOT NULL,
+	raw_response TEXT,
+	error TEXT,
+	redacted_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT board_instruction_board_id_fkey FOREIGN KEY (board_id) REFERENCES "board"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS board_instruction_board_id_created_at_idx ON "board_instruction" (board_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE "board_instruction";
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE board ADD COLUMN icon TEXT;
+ALTER TABLE board ADD COLUMN color TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE board DROP COLUMN color;
+ALTER TABLE board DROP COLUMN icon;
+-- +goose StatementEnd
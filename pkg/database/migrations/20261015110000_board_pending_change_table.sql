@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE board ADD COLUMN protected BOOLEAN DEFAULT false NOT NULL;
+CREATE TABLE IF NOT EXISTS "board_pending_change" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	board_id UUID NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	instruction TEXT NOT NULL,
+	response TEXT NOT NULL,
+	status VARCHAR(16) DEFAULT 'pending' NOT NULL,
+	reason TEXT,
+	decided_by VARCHAR(255),
+	decided_at TIMESTAMPTZ,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT board_pending_change_board_id_fkey FOREIGN KEY (board_id) REFERENCES "board"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS board_pending_change_board_id_status_idx ON "board_pending_change" (board_id, status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE "board_pending_change";
+ALTER TABLE board DROP COLUMN protected;
+-- +goose StatementEnd
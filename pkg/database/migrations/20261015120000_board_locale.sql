@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE board ADD COLUMN timezone TEXT;
+ALTER TABLE board ADD COLUMN locale TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE board DROP COLUMN locale;
+ALTER TABLE board DROP COLUMN timezone;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "board_comment" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	board_id UUID NOT NULL,
+	element_id TEXT,
+	author_id VARCHAR(255) NOT NULL,
+	text TEXT NOT NULL,
+	resolved BOOLEAN DEFAULT false NOT NULL,
+	orphaned BOOLEAN DEFAULT false NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT board_comment_board_id_fkey FOREIGN KEY (board_id) REFERENCES "board"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS board_comment_board_id_idx ON "board_comment" (board_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE "board_comment";
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "board_view" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	board_id UUID NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	x DOUBLE PRECISION,
+	y DOUBLE PRECISION,
+	zoom DOUBLE PRECISION,
+	width DOUBLE PRECISION,
+	height DOUBLE PRECISION,
+	frame_id TEXT,
+	created_by VARCHAR(255) NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT board_view_board_id_fkey FOREIGN KEY (board_id) REFERENCES "board"(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS board_view_board_id_name_idx ON "board_view" (board_id, lower(name));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE "board_view";
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE "board_instruction"
+	ADD COLUMN IF NOT EXISTS provider VARCHAR(32),
+	ADD COLUMN IF NOT EXISTS prompt_tokens INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS completion_tokens INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS board_instruction_created_at_idx ON "board_instruction" (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS board_instruction_created_at_idx;
+ALTER TABLE "board_instruction"
+	DROP COLUMN IF EXISTS provider,
+	DROP COLUMN IF EXISTS prompt_tokens,
+	DROP COLUMN IF EXISTS completion_tokens,
+	DROP COLUMN IF EXISTS cost_usd;
+-- +goose StatementEnd
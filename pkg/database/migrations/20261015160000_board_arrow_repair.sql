@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE board ADD COLUMN arrow_repair VARCHAR(16) NOT NULL DEFAULT 'unbind';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE board DROP COLUMN arrow_repair;
+-- +goose StatementEnd
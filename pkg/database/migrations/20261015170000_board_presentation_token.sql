@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "board_presentation_token" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	board_id UUID NOT NULL,
+	token_hash TEXT NOT NULL,
+	label VARCHAR(255) NOT NULL DEFAULT '',
+	created_by VARCHAR(255) NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT board_presentation_token_board_id_fkey FOREIGN KEY (board_id) REFERENCES "board"(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS board_presentation_token_token_hash_idx ON "board_presentation_token" (token_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE "board_presentation_token";
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "board_data_key" (
+	board_id UUID PRIMARY KEY NOT NULL,
+	wrapped_key BYTEA NOT NULL,
+	master_key_id VARCHAR(32) NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	rotated_at TIMESTAMPTZ,
+	CONSTRAINT board_data_key_board_id_fkey FOREIGN KEY (board_id) REFERENCES "board"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS board_data_key_master_key_id_idx ON "board_data_key" (master_key_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE "board_data_key";
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE "board_instruction"
+	ADD COLUMN IF NOT EXISTS intent VARCHAR(32) NOT NULL DEFAULT 'other',
+	ADD COLUMN IF NOT EXISTS outcome VARCHAR(32) NOT NULL DEFAULT 'success',
+	ADD COLUMN IF NOT EXISTS latency_ms INTEGER NOT NULL DEFAULT 0;
+UPDATE "board_instruction" SET outcome = 'error' WHERE error IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS "instruction_intent_daily" (
+	day DATE NOT NULL,
+	intent VARCHAR(32) NOT NULL,
+	outcome VARCHAR(32) NOT NULL,
+	provider VARCHAR(32) NOT NULL,
+	count BIGINT NOT NULL DEFAULT 0,
+	latency_ms_sum BIGINT NOT NULL DEFAULT 0,
+	latency_ms_max INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (day, intent, outcome, provider)
+);
+
+CREATE TABLE IF NOT EXISTS "instruction_failure_pattern_daily" (
+	day DATE NOT NULL,
+	intent VARCHAR(32) NOT NULL,
+	pattern VARCHAR(255) NOT NULL,
+	count BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (day, intent, pattern)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS "instruction_failure_pattern_daily";
+DROP TABLE IF EXISTS "instruction_intent_daily";
+ALTER TABLE "board_instruction"
+	DROP COLUMN IF EXISTS intent,
+	DROP COLUMN IF EXISTS outcome,
+	DROP COLUMN IF EXISTS latency_ms;
+-- +goose StatementEnd
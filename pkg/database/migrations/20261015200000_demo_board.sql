@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "demo_board" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	session_id VARCHAR(64) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	elements JSONB NOT NULL DEFAULT '[]'::jsonb,
+	instruction_count INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	max_expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS demo_board_session_id_idx ON "demo_board" (session_id);
+CREATE INDEX IF NOT EXISTS demo_board_expires_at_idx ON "demo_board" (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS "demo_board";
+-- +goose StatementEnd
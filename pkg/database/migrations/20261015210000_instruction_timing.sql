@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "instruction_timing" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	request_id VARCHAR(64) NOT NULL,
+	board_id UUID NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	provider VARCHAR(32) NOT NULL DEFAULT '',
+	model VARCHAR(255) NOT NULL DEFAULT '',
+	failed BOOLEAN NOT NULL DEFAULT FALSE,
+	queue_ms INTEGER NOT NULL DEFAULT 0,
+	first_byte_ms INTEGER NOT NULL DEFAULT 0,
+	llm_ms INTEGER NOT NULL DEFAULT 0,
+	validate_ms INTEGER NOT NULL DEFAULT 0,
+	broadcast_ms INTEGER NOT NULL DEFAULT 0,
+	total_ms INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT instruction_timing_board_id_fkey FOREIGN KEY (board_id) REFERENCES "board"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS instruction_timing_created_at_total_ms_idx ON "instruction_timing" (created_at, total_ms DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS "instruction_timing";
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "instruction_intent" (
+	request_id VARCHAR(64) PRIMARY KEY NOT NULL,
+	board_id UUID NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	instruction TEXT NOT NULL,
+	state VARCHAR(16) NOT NULL DEFAULT 'pending',
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT instruction_intent_board_id_fkey FOREIGN KEY (board_id) REFERENCES "board"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS instruction_intent_board_id_state_idx ON "instruction_intent" (board_id, state);
+CREATE INDEX IF NOT EXISTS instruction_intent_state_created_at_idx ON "instruction_intent" (state, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS "instruction_intent";
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS "service_account" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	owner_id VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	disabled_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT service_account_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS service_account_owner_id_idx ON "service_account" (owner_id);
+
+CREATE TABLE IF NOT EXISTS "service_account_key" (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4() NOT NULL,
+	service_account_id UUID NOT NULL,
+	key_hash TEXT NOT NULL,
+	scopes TEXT[] NOT NULL,
+	revoked_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT service_account_key_service_account_id_fkey FOREIGN KEY (service_account_id) REFERENCES "service_account"(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS service_account_key_key_hash_idx ON "service_account_key" (key_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE "service_account_key";
+DROP TABLE "service_account";
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE "board" ADD COLUMN IF NOT EXISTS legal_hold_reason TEXT;
+ALTER TABLE "board" ADD COLUMN IF NOT EXISTS legal_hold_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- Deletes on held boards fail with SQLSTATE LH001 whatever issues them,
+-- including cascades from deleting the owner.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION prevent_legal_hold_board_delete() RETURNS trigger AS $$
+BEGIN
+	IF OLD.legal_hold_at IS NOT NULL THEN
+		RAISE EXCEPTION 'board % is under legal hold', OLD.id USING ERRCODE = 'LH001';
+	END IF;
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION prevent_legal_hold_content_delete() RETURNS trigger AS $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM "board" WHERE id = OLD.board_id AND legal_hold_at IS NOT NULL) THEN
+		RAISE EXCEPTION 'board % is under legal hold', OLD.board_id USING ERRCODE = 'LH001';
+	END IF;
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER board_legal_hold_delete BEFORE DELETE ON "board"
+	FOR EACH ROW EXECUTE FUNCTION prevent_legal_hold_board_delete();
+CREATE TRIGGER board_comment_legal_hold_delete BEFORE DELETE ON "board_comment"
+	FOR EACH ROW EXECUTE FUNCTION prevent_legal_hold_content_delete();
+CREATE TRIGGER board_instruction_legal_hold_delete BEFORE DELETE ON "board_instruction"
+	FOR EACH ROW EXECUTE FUNCTION prevent_legal_hold_content_delete();
+CREATE TRIGGER instruction_timing_legal_hold_delete BEFORE DELETE ON "instruction_timing"
+	FOR EACH ROW EXECUTE FUNCTION prevent_legal_hold_content_delete();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TRIGGER IF EXISTS instruction_timing_legal_hold_delete ON "instruction_timing";
+DROP TRIGGER IF EXISTS board_instruction_legal_hold_delete ON "board_instruction";
+DROP TRIGGER IF EXISTS board_comment_legal_hold_delete ON "board_comment";
+DROP TRIGGER IF EXISTS board_legal_hold_delete ON "board";
+DROP FUNCTION IF EXISTS prevent_legal_hold_content_delete();
+DROP FUNCTION IF EXISTS prevent_legal_hold_board_delete();
+ALTER TABLE "board" DROP COLUMN IF EXISTS legal_hold_at;
+ALTER TABLE "board" DROP COLUMN IF EXISTS legal_hold_reason;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE "board_instruction"
+	ADD COLUMN IF NOT EXISTS inverse_action TEXT,
+	ADD COLUMN IF NOT EXISTS undone_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE "board_instruction"
+	DROP COLUMN IF EXISTS inverse_action,
+	DROP COLUMN IF EXISTS undone_at;
+-- +goose StatementEnd
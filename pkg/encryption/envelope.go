@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// envelopePrefix marks encrypted values. The full form is
+// "enc:v1:<board id>:<base64 nonce||ciphertext>". The board ID is bound to
+// the ciphertext and must match the board of the row the value is read
+// from, so a value moved to another board's row doesn't decrypt.
+const envelopePrefix = "enc:v1:"
+
+// ErrReservedPrefix is returned for content that starts with the envelope
+// prefix. Such content would be read back as an envelope, so it is refused
+// rather than stored.
+var ErrReservedPrefix = errors.New("content can't start with \"" + envelopePrefix + "\", which marks encrypted values")
+
+// ErrWrongBoard is returned when an envelope was sealed for a board other
+// than the one it is read for.
+var ErrWrongBoard = errors.New("encrypted value belongs to another board")
+
+// IsSealed reports whether s is an encrypted envelope.
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, envelopePrefix)
+}
+
+// Seal encrypts plaintext with a board's data key.
+func Seal(dataKey []byte, boardID string, plaintext []byte) (string, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, plaintext, []byte(boardID))
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + boardID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts an envelope read from a row of the given board, with that
+// board's data key. Envelopes sealed for another board are ErrWrongBoard.
+func Open(dataKey []byte, boardID string, envelope string) ([]byte, error) {
+	sealedFor, sealed, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+	if sealedFor != boardID {
+		return nil, fmt.Errorf("%w: sealed for %s, read for %s", ErrWrongBoard, sealedFor, boardID)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, sealed, []byte(boardID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt content for board %s: %w", boardID, err)
+	}
+	return plaintext, nil
+}
+
+func parseEnvelope(envelope string) (string, []byte, error) {
+	rest, ok := strings.CutPrefix(envelope, envelopePrefix)
+	if !ok {
+		return "", nil, fmt.Errorf("not an encrypted value")
+	}
+	boardID, encoded, ok := strings.Cut(rest, ":")
+	if !ok || boardID == "" {
+		return "", nil, fmt.Errorf("malformed encrypted value")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	return boardID, sealed, nil
+}
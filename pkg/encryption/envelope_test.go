@@ -0,0 +1,75 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newDataKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSealOpen(t *testing.T) {
+	key := newDataKey(t)
+	envelope, err := Seal(key, "board-1", []byte("top secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(envelope) || strings.Contains(envelope, "top secret") {
+		t.Fatalf("envelope %q isn't sealed", envelope)
+	}
+	plaintext, err := Open(key, "board-1", envelope)
+	if err != nil || string(plaintext) != "top secret" {
+		t.Fatalf("Open: got %q, %v", plaintext, err)
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	envelope, err := Seal(newDataKey(t), "board-1", []byte("top secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(newDataKey(t), "board-1", envelope); err == nil {
+		t.Error("opened with another board's data key")
+	}
+}
+
+func TestOpenWrongBoard(t *testing.T) {
+	key := newDataKey(t)
+	envelope, err := Seal(key, "board-1", []byte("top secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Copied into another board's row, the envelope is refused even by a
+	// key that would decrypt it.
+	if _, err := Open(key, "board-2", envelope); !errors.Is(err, ErrWrongBoard) {
+		t.Errorf("got %v, want ErrWrongBoard", err)
+	}
+	// Rewriting the board ID inside it breaks the ciphertext instead.
+	forged := strings.Replace(envelope, "board-1", "board-2", 1)
+	if _, err := Open(key, "board-2", forged); err == nil {
+		t.Error("opened an envelope whose board ID was rewritten")
+	}
+}
+
+func TestOpenMalformed(t *testing.T) {
+	key := newDataKey(t)
+	for _, envelope := range []string{
+		"plain text",
+		"enc:v1:",
+		"enc:v1:board-1",
+		"enc:v1:board-1:not base64!",
+		"enc:v1:board-1:AAAA",
+	} {
+		if _, err := Open(key, "board-1", envelope); err == nil {
+			t.Errorf("Open(%q) succeeded", envelope)
+		}
+	}
+}
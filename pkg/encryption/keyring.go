@@ -0,0 +1,149 @@
+// Package encryption seals board content with AES-GCM. Each board has its
+// own data key, stored wrapped by a master key that only the application
+// holds, so the database alone can't be used to read content.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnavailable is returned when content is encrypted but the master key
+// needed to read it isn't configured. Content is never served as
+// ciphertext instead.
+var ErrUnavailable = errors.New("encryption_unavailable: the key needed to read this content is not configured")
+
+// keySize is the size of master and data keys: AES-256.
+const keySize = 32
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring holds the master keys. The current key wraps new data keys;
+// previous keys can only unwrap, and are kept until rotation has re-wrapped
+// every data key with the current one.
+type Keyring struct {
+	current masterKey
+	keys    map[string]masterKey
+}
+
+// ParseKeyring builds a keyring from base64-encoded 32-byte keys. It returns
+// nil when current is empty, which disables encryption.
+func ParseKeyring(current string, previous []string) (*Keyring, error) {
+	if current == "" {
+		if len(previous) > 0 {
+			return nil, fmt.Errorf("previous encryption keys are set without a current key")
+		}
+		return nil, nil
+	}
+
+	currentKey, err := parseMasterKey(current)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	k := &Keyring{
+		current: currentKey,
+		keys:    map[string]masterKey{currentKey.id: currentKey},
+	}
+	for i, encoded := range previous {
+		key, err := parseMasterKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous encryption key %d: %w", i+1, err)
+		}
+		k.keys[key.id] = key
+	}
+	return k, nil
+}
+
+func parseMasterKey(encoded string) (masterKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return masterKey{}, fmt.Errorf("not base64: %w", err)
+	}
+	if len(raw) != keySize {
+		return masterKey{}, fmt.Errorf("must be %d bytes, got %d", keySize, len(raw))
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return masterKey{}, err
+	}
+	// The ID identifies the key without revealing it.
+	sum := sha256.Sum256(raw)
+	return masterKey{id: hex.EncodeToString(sum[:8]), aead: aead}, nil
+}
+
+// CurrentID identifies the master key new data keys are wrapped with.
+func (k *Keyring) CurrentID() string {
+	return k.current.id
+}
+
+// NewDataKey generates a data key and wraps it with the current master key.
+func (k *Keyring) NewDataKey() (dataKey []byte, wrapped []byte, masterKeyID string, err error) {
+	dataKey = make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, masterKeyID, err = k.Wrap(dataKey)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return dataKey, wrapped, masterKeyID, nil
+}
+
+// Wrap encrypts a data key with the current master key.
+func (k *Keyring) Wrap(dataKey []byte) ([]byte, string, error) {
+	wrapped, err := seal(k.current.aead, dataKey, []byte(k.current.id))
+	if err != nil {
+		return nil, "", err
+	}
+	return wrapped, k.current.id, nil
+}
+
+// Unwrap decrypts a data key wrapped by the master key with the given ID.
+// Unknown master keys and keys that fail to decrypt, such as when a
+// different key was configured under the same name, are ErrUnavailable.
+func (k *Keyring) Unwrap(wrapped []byte, masterKeyID string) ([]byte, error) {
+	key, ok := k.keys[masterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown master key %s", ErrUnavailable, masterKeyID)
+	}
+	dataKey, err := open(key.aead, wrapped, []byte(masterKeyID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to unwrap data key", ErrUnavailable)
+	}
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce||ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
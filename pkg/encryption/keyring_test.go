@@ -0,0 +1,113 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func newMasterKey(t *testing.T) string {
+	t.Helper()
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func mustParseKeyring(t *testing.T, current string, previous ...string) *Keyring {
+	t.Helper()
+	keyring, err := ParseKeyring(current, previous)
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+	return keyring
+}
+
+func TestParseKeyring(t *testing.T) {
+	keyring, err := ParseKeyring("", nil)
+	if err != nil || keyring != nil {
+		t.Fatalf("empty key: got %v, %v; want a nil keyring", keyring, err)
+	}
+	if _, err := ParseKeyring("", []string{newMasterKey(t)}); err == nil {
+		t.Error("previous keys without a current key were accepted")
+	}
+	if _, err := ParseKeyring("not base64!", nil); err == nil {
+		t.Error("a key that isn't base64 was accepted")
+	}
+	if _, err := ParseKeyring(base64.StdEncoding.EncodeToString([]byte("short")), nil); err == nil {
+		t.Error("a short key was accepted")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, newKey := newMasterKey(t), newMasterKey(t)
+	before := mustParseKeyring(t, oldKey)
+	dataKey, wrapped, masterKeyID, err := before.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := Seal(dataKey, "board-1", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key is kept as a previous key until rotation has finished.
+	during := mustParseKeyring(t, newKey, oldKey)
+	unwrapped, err := during.Unwrap(wrapped, masterKeyID)
+	if err != nil {
+		t.Fatalf("unwrapping with the previous key: %v", err)
+	}
+	rewrapped, newID, err := during.Wrap(unwrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newID == masterKeyID || newID != during.CurrentID() {
+		t.Fatalf("rewrapped with %s, want the current key %s", newID, during.CurrentID())
+	}
+
+	// Once rotated, the old key can go and content still opens: only the
+	// data key was re-wrapped.
+	after := mustParseKeyring(t, newKey)
+	rotated, err := after.Unwrap(rewrapped, newID)
+	if err != nil {
+		t.Fatalf("unwrapping after rotation: %v", err)
+	}
+	if !bytes.Equal(rotated, dataKey) {
+		t.Fatal("rotation changed the data key")
+	}
+	plaintext, err := Open(rotated, "board-1", envelope)
+	if err != nil || string(plaintext) != "hello" {
+		t.Fatalf("Open after rotation: got %q, %v", plaintext, err)
+	}
+
+	// Keys wrapped by the removed key can no longer be read.
+	if _, err := after.Unwrap(wrapped, masterKeyID); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unwrapping with a removed key: got %v, want ErrUnavailable", err)
+	}
+}
+
+func TestUnwrapWrongKey(t *testing.T) {
+	keyring := mustParseKeyring(t, newMasterKey(t))
+	_, wrapped, masterKeyID, err := keyring.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := mustParseKeyring(t, newMasterKey(t))
+	if _, err := other.Unwrap(wrapped, masterKeyID); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unknown master key: got %v, want ErrUnavailable", err)
+	}
+	// A different key configured under the same ID, as when the wrong key
+	// is deployed, fails to decrypt rather than returning garbage.
+	if _, err := other.Unwrap(wrapped, other.CurrentID()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("wrong master key: got %v, want ErrUnavailable", err)
+	}
+	// Tampered keys fail too.
+	wrapped[len(wrapped)-1] ^= 1
+	if _, err := keyring.Unwrap(wrapped, masterKeyID); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("tampered data key: got %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,145 @@
+package livekit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+)
+
+// Stages an instruction attempt can fail at.
+const (
+	StageGenerate = "generate"
+	StageParse    = "parse"
+	StageResolve  = "resolve"
+	StageValidate = "validate"
+)
+
+// maxRawOutputLen caps how much model output is echoed back to clients.
+const maxRawOutputLen = 4000
+
+// Attempt describes one failed try at turning an instruction into an action.
+type Attempt struct {
+	Number int    `json:"attempt"`
+	Stage  string `json:"stage"`
+	Error  string `json:"error"`
+	// Issues are the validation problems, for attempts that failed
+	// validation.
+	Issues []llm.ValidationIssue `json:"issues,omitempty"`
+}
+
+// InstructionFailure is returned once every attempt for an instruction has
+// failed. It carries what the client needs to explain the failure and, when
+// some elements in the last output were usable, offer to apply just those.
+type InstructionFailure struct {
+	Attempts   []Attempt `json:"attempts"`
+	FailedRule string    `json:"failedRule"`
+	RawOutput  string    `json:"rawOutput,omitempty"`
+
+	// PartialElements are the complete elements recovered from the last
+	// output. PartialToken is filled in by the session when it stores them
+	// for a later apply.
+	PartialElements []llm.Element `json:"partialElements,omitempty"`
+	PartialToken    string        `json:"partialToken,omitempty"`
+
+	// Provider, Usage and CostUSD add up what the failed attempts cost, so
+	// they still count towards the LLM budget.
+	Provider string    `json:"-"`
+	Model    string    `json:"-"`
+	Usage    llm.Usage `json:"-"`
+	CostUSD  float64   `json:"-"`
+	// Latency is how long the attempts took altogether.
+	Latency time.Duration `json:"-"`
+
+	boardHash string
+	// cause is the last attempt's error, so errors.Is sees through the
+	// failure to what the LLM layer reported.
+	cause error
+}
+
+func (f *InstructionFailure) Error() string {
+	if len(f.Attempts) == 0 {
+		return "instruction failed"
+	}
+	last := f.Attempts[len(f.Attempts)-1]
+	return fmt.Sprintf("instruction failed after %d attempt(s) at %s: %s", len(f.Attempts), last.Stage, last.Error)
+}
+
+func (f *InstructionFailure) Unwrap() error {
+	return f.cause
+}
+
+func (f *InstructionFailure) record(stage string, err error, rawOutput string) {
+	f.Attempts = append(f.Attempts, Attempt{
+		Number: len(f.Attempts) + 1,
+		Stage:  stage,
+		Error:  err.Error(),
+		Issues: validationErrors(err),
+	})
+	f.FailedRule = stage
+	f.cause = err
+	if stage == StageParse && !errors.Is(err, llm.ErrInvalidLLMOutput) {
+		f.cause = fmt.Errorf("%w: %w", llm.ErrInvalidLLMOutput, err)
+	}
+	f.RawOutput = SanitizeOutput(rawOutput)
+	f.PartialElements = nil
+	if rawOutput != "" && stage != StageResolve && stage != StageValidate {
+		parser := llm.NewElementStreamParser(func(element llm.Element) {
+			f.PartialElements = append(f.PartialElements, element)
+		})
+		parser.WriteString(rawOutput)
+	}
+}
+
+func (f *InstructionFailure) charge(response *llm.LLMResponse) {
+	f.Provider = response.Provider
+	f.Model = response.Model
+	f.Usage.PromptTokens += response.Usage.PromptTokens
+	f.Usage.CompletionTokens += response.Usage.CompletionTokens
+	f.CostUSD += response.CostUSD
+}
+
+// validationErrors returns the error-level issues of a validation failure,
+// or nil for other errors.
+func validationErrors(err error) []llm.ValidationIssue {
+	var validationErr *llm.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Report.Errors()
+	}
+	return nil
+}
+
+// SanitizeOutput strips control characters and truncates model output before
+// it is sent to clients.
+func SanitizeOutput(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > maxRawOutputLen {
+		s = s[:maxRawOutputLen]
+		s = strings.ToValidUTF8(s, "")
+	}
+	return s
+}
+
+// hashBoardState fingerprints the board state an attempt was made against,
+// so a partial can't be applied once the board has changed. The state is
+// canonicalized first so re-serializing an unchanged board doesn't count as a
+// change.
+func hashBoardState(boardState string) string {
+	data := []byte(boardState)
+	if canonical, err := whiteboard.Canonicalize(data); err == nil {
+		data = canonical
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,94 @@
+package livekit
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"draw/pkg/llm"
+
+	"github.com/google/uuid"
+)
+
+// confirmationTTL is how long an action held for confirmation can be
+// confirmed.
+const confirmationTTL = 5 * time.Minute
+
+var (
+	// ErrConfirmationNotFound is returned for unknown, used, or expired
+	// tokens.
+	ErrConfirmationNotFound = errors.New("action awaiting confirmation not found")
+	// ErrConfirmationStale is returned when the board changed after the
+	// action was held.
+	ErrConfirmationStale = errors.New("board has changed since the action was held for confirmation")
+)
+
+type storedConfirmation struct {
+	requestID     string
+	transcription string
+	responses     []*llm.LLMResponse
+	boardHash     string
+	expiresAt     time.Time
+}
+
+// holdForConfirmation keeps the responses of an instruction the guard
+// flagged and tells clients what confirming them would do. Every response is
+// given the same token, which applies them all.
+func (s *LiveKitSession) holdForConfirmation(requestID string, transcription string, responses []*llm.LLMResponse, boardHash string) {
+	now := time.Now()
+	token := uuid.New().String()
+	for _, response := range responses {
+		response.ConfirmationToken = token
+	}
+
+	s.confirmationsMu.Lock()
+	for key, held := range s.confirmations {
+		if now.After(held.expiresAt) {
+			delete(s.confirmations, key)
+		}
+	}
+	s.confirmations[token] = storedConfirmation{
+		requestID:     requestID,
+		transcription: transcription,
+		responses:     responses,
+		boardHash:     boardHash,
+		expiresAt:     now.Add(confirmationTTL),
+	}
+	s.confirmationsMu.Unlock()
+
+	s.publish(StreamTextData{
+		Type:      "confirmation_required",
+		RequestID: requestID,
+		Data:      responses,
+	})
+}
+
+// ConfirmAction applies the responses held under token, provided the board
+// still matches the state they were resolved against. They go through
+// approval like any other response. A token can only be used once.
+func (s *LiveKitSession) ConfirmAction(token string, boardState json.RawMessage) error {
+	s.confirmationsMu.Lock()
+	held, ok := s.confirmations[token]
+	if ok && time.Now().After(held.expiresAt) {
+		delete(s.confirmations, token)
+		ok = false
+	}
+	if !ok {
+		s.confirmationsMu.Unlock()
+		return ErrConfirmationNotFound
+	}
+	if hashBoardState(string(boardState)) != held.boardHash {
+		delete(s.confirmations, token)
+		s.confirmationsMu.Unlock()
+		return ErrConfirmationStale
+	}
+	delete(s.confirmations, token)
+	s.confirmationsMu.Unlock()
+
+	for _, response := range held.responses {
+		response.RequiresConfirmation = false
+		response.ConfirmationToken = ""
+		s.applyResponse(held.requestID, held.transcription, response)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package livekit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/pkg/llm"
+)
+
+const heldBoard = `[{"id":"a","type":"rectangle","x":0,"y":0},{"id":"b","type":"ellipse","x":100,"y":0}]`
+
+// holdClear holds a clear of heldBoard for confirmation and returns its
+// token, checking that clients were asked to confirm it.
+func holdClear(t *testing.T, s *LiveKitSession) string {
+	t.Helper()
+	response := &llm.LLMResponse{Response: `{"action":"clear"}`, RequiresConfirmation: true}
+	s.holdForConfirmation("req-1", "clear the board", []*llm.LLMResponse{response}, hashBoardState(heldBoard))
+
+	events := s.outbound.drain()
+	if len(events) != 1 || events[0].Type != "confirmation_required" {
+		t.Fatalf("published %+v, want one confirmation_required event", events)
+	}
+	if response.ConfirmationToken == "" {
+		t.Fatalf("held response has no confirmation token")
+	}
+	return response.ConfirmationToken
+}
+
+func TestConfirmAction(t *testing.T) {
+	var reset []*llm.WhiteboardAction
+	s := newTestSession(&repo.User{ID: "u1", Name: "alice"}, "board", SessionCallbacks{
+		OnBoardReset: func(boardID string, action *llm.WhiteboardAction) error {
+			reset = append(reset, action)
+			return nil
+		},
+	})
+	defer s.cancel()
+	token := holdClear(t, s)
+
+	// Reserializing the board the same way isn't a change.
+	if err := s.ConfirmAction(token, json.RawMessage(" "+heldBoard+"\n")); err != nil {
+		t.Fatalf("ConfirmAction: %v", err)
+	}
+	events := s.outbound.drain()
+	if len(events) != 1 || events[0].Type != "canvas_update" || events[0].RequestID != "req-1" {
+		t.Fatalf("published %+v, want the held canvas_update for req-1", events)
+	}
+	applied := events[0].Data.(*llm.LLMResponse)
+	if applied.RequiresConfirmation || applied.ConfirmationToken != "" {
+		t.Errorf("applied response = %+v, want it no longer awaiting confirmation", applied)
+	}
+	if len(reset) != 1 || reset[0].Action != llm.ActionClear {
+		t.Errorf("stored resets = %+v, want the clear", reset)
+	}
+
+	if err := s.ConfirmAction(token, json.RawMessage(heldBoard)); !errors.Is(err, ErrConfirmationNotFound) {
+		t.Errorf("confirming twice = %v, want ErrConfirmationNotFound", err)
+	}
+	if events := s.outbound.drain(); len(events) != 0 {
+		t.Errorf("second confirmation published %+v, want nothing", events)
+	}
+}
+
+func TestConfirmActionRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(t *testing.T, s *LiveKitSession) string
+		board string
+		want  error
+	}{
+		{
+			name:  "unknown token",
+			token: func(t *testing.T, s *LiveKitSession) string { holdClear(t, s); return "not-a-token" },
+			board: heldBoard,
+			want:  ErrConfirmationNotFound,
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T, s *LiveKitSession) string {
+				token := holdClear(t, s)
+				s.confirmationsMu.Lock()
+				held := s.confirmations[token]
+				held.expiresAt = time.Now().Add(-time.Second)
+				s.confirmations[token] = held
+				s.confirmationsMu.Unlock()
+				return token
+			},
+			board: heldBoard,
+			want:  ErrConfirmationNotFound,
+		},
+		{
+			name:  "board changed",
+			token: holdClear,
+			board: `[{"id":"a","type":"rectangle","x":0,"y":0}]`,
+			want:  ErrConfirmationStale,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSession(&repo.User{ID: "u1", Name: "alice"}, "board", SessionCallbacks{})
+			defer s.cancel()
+			token := tt.token(t, s)
+
+			if err := s.ConfirmAction(token, json.RawMessage(tt.board)); !errors.Is(err, tt.want) {
+				t.Fatalf("ConfirmAction = %v, want %v", err, tt.want)
+			}
+			if events := s.outbound.drain(); len(events) != 0 {
+				t.Errorf("rejected confirmation published %+v, want nothing", events)
+			}
+			// Rejected tokens can't be retried once the board matches again.
+			if err := s.ConfirmAction(token, json.RawMessage(heldBoard)); !errors.Is(err, ErrConfirmationNotFound) {
+				t.Errorf("retrying = %v, want ErrConfirmationNotFound", err)
+			}
+		})
+	}
+}
+
+func TestHoldPrunesExpiredConfirmations(t *testing.T) {
+	s := newTestSession(&repo.User{ID: "u1", Name: "alice"}, "board", SessionCallbacks{})
+	defer s.cancel()
+	old := holdClear(t, s)
+	s.confirmationsMu.Lock()
+	held := s.confirmations[old]
+	held.expiresAt = time.Now().Add(-time.Second)
+	s.confirmations[old] = held
+	s.confirmationsMu.Unlock()
+
+	current := holdClear(t, s)
+	s.confirmationsMu.Lock()
+	defer s.confirmationsMu.Unlock()
+	if _, ok := s.confirmations[old]; ok {
+		t.Errorf("expired confirmation was kept")
+	}
+	if _, ok := s.confirmations[current]; !ok {
+		t.Errorf("new confirmation was not stored")
+	}
+}
+
+func TestManagerConfirmActionWithoutSession(t *testing.T) {
+	m, _ := newTestManager(newFakeRoomService())
+	defer m.Close()
+	if err := m.ConfirmAction("board", "token", json.RawMessage(heldBoard)); !errors.Is(err, ErrConfirmationNotFound) {
+		t.Errorf("ConfirmAction without a session = %v, want ErrConfirmationNotFound", err)
+	}
+}
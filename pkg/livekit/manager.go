@@ -0,0 +1,502 @@
+package livekit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"draw/internal/db/repo"
+	"draw/pkg/config"
+	"draw/pkg/llm"
+	"draw/pkg/llm/prompts"
+	"draw/pkg/slo"
+
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	lksdk "github.com/livekit/server-sdk-go/v2"
+	"github.com/twitchtv/twirp"
+)
+
+// SessionManager keeps a single LiveKitSession per board and reaps sessions
+// whose rooms have had no participants for longer than the idle timeout.
+// Sessions are created lazily on join, so a reaped board is re-initialised
+// transparently the next time someone opens it.
+type SessionManager struct {
+	cfg          *config.AppConfig
+	budget       *llm.Budget
+	models       *llm.Models
+	limiter      *llm.RateLimiter
+	prompts      *prompts.Registry
+	hooks        []llm.GenerateHook
+	history      *llm.Conversations
+	slo          *slo.Tracker
+	recorder     InstructionRecorder
+	roomClient   roomService
+	idleTimeout  time.Duration
+	reapInterval time.Duration
+	mu           sync.Mutex
+	boards       map[string]*boardSession
+	reapedRooms  atomic.Int64
+	ctx          context.Context
+	cancel       context.CancelFunc
+	closeOnce    sync.Once
+	wg           sync.WaitGroup
+
+	// startSession creates and starts a board's session.
+	startSession func(userDetails *repo.User, boardID string, callbacks SessionCallbacks) (*LiveKitSession, error)
+}
+
+// boardSession guards creation and destruction of a board's session so the
+// reaper can never tear a session down while a client is joining it. The
+// lock is never held across calls to LiveKit's room API.
+type boardSession struct {
+	mu        sync.Mutex
+	session   *LiveKitSession
+	idleSince time.Time
+	// joins counts joins, so the reaper can tell whether anyone joined while
+	// it checked the room without the lock.
+	joins   uint64
+	removed bool
+	// deleted is closed once a removed entry's room is gone. Joiners wait
+	// for it, so a new session never joins a room that is being deleted.
+	deleted chan struct{}
+}
+
+// NewSessionManager creates the manager. budget, limiter, registry, tracker
+// and recorder are shared by every session; nil disables budget checks,
+// per-user rate limits, prompt versions, latency tracking and session
+// recording respectively.
+// Instruction history is kept here rather than in a session, so a board's
+// history outlives a session reaped while everyone was away.
+func NewSessionManager(cfg *config.AppConfig, budget *llm.Budget, models *llm.Models, limiter *llm.RateLimiter, registry *prompts.Registry, hooks []llm.GenerateHook, tracker *slo.Tracker, recorder InstructionRecorder) *SessionManager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &SessionManager{
+		cfg:      cfg,
+		budget:   budget,
+		models:   models,
+		limiter:  limiter,
+		prompts:  registry,
+		hooks:    hooks,
+		slo:      tracker,
+		recorder: recorder,
+		history:  llm.NewConversations(cfg.LLM.ConversationTurns, time.Duration(cfg.LLM.ConversationIdleSec)*time.Second),
+		roomClient: lksdk.NewRoomServiceClient(
+			cfg.LiveKit.Host,
+			cfg.LiveKit.APIKey,
+			cfg.LiveKit.APISecret,
+		),
+		idleTimeout:  time.Duration(cfg.LiveKit.RoomIdleTimeoutSec) * time.Second,
+		reapInterval: time.Duration(cfg.LiveKit.ReapIntervalSec) * time.Second,
+		boards:       make(map[string]*boardSession),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+
+	m.startSession = m.newSession
+
+	if m.reapInterval > 0 {
+		m.wg.Add(1)
+		go m.reaper()
+	}
+
+	return m
+}
+
+// SpectatorToken lets a presentation display join the board's room hidden,
+// to receive board events without publishing anything.
+func (m *SessionManager) SpectatorToken(boardID string, identity string, validFor time.Duration) (string, error) {
+	grant := &auth.VideoGrant{
+		RoomJoin: true,
+		Room:     boardID,
+		Hidden:   true,
+	}
+	grant.SetCanPublish(false)
+	grant.SetCanPublishData(false)
+	grant.SetCanSubscribe(true)
+
+	return auth.NewAccessToken(m.cfg.LiveKit.APIKey, m.cfg.LiveKit.APISecret).
+		SetVideoGrant(grant).
+		SetIdentity(identity).
+		SetValidFor(validFor).
+		ToJWT()
+}
+
+// GetOrCreate returns the running session for the board, starting a new one
+// if the board has no session or its previous session has stopped. The
+// user joins the session either way, so what they say is attributed to
+// them rather than to whoever started it.
+func (m *SessionManager) GetOrCreate(
+	userDetails *repo.User,
+	boardID string,
+	callbacks SessionCallbacks,
+) (*LiveKitSession, error) {
+	for {
+		entry, err := m.entry(boardID)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.mu.Lock()
+		if entry.removed {
+			// The entry was dropped while we were waiting for it. Start over
+			// once its room is gone.
+			entry.mu.Unlock()
+			<-entry.deleted
+			continue
+		}
+		entry.joins++
+
+		if entry.session != nil && !isStopped(entry.session) {
+			entry.idleSince = time.Time{}
+			session := entry.session
+			entry.mu.Unlock()
+			session.join(userDetails)
+			return session, nil
+		}
+
+		session, err := m.startSession(userDetails, boardID, callbacks)
+		if err != nil {
+			entry.mu.Unlock()
+			return nil, err
+		}
+
+		entry.session = session
+		entry.idleSince = time.Time{}
+		entry.mu.Unlock()
+
+		logger.Infow("Started board session", "boardID", boardID)
+		return session, nil
+	}
+}
+
+// newSession creates and starts a session for the board, with userDetails as
+// its first participant.
+func (m *SessionManager) newSession(userDetails *repo.User, boardID string, callbacks SessionCallbacks) (*LiveKitSession, error) {
+	session, err := NewLiveKitSession(userDetails, boardID, m.cfg, m.budget, m.models, m.limiter, m.prompts, m.hooks, m.history, m.slo, m.recorder, callbacks)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.Start(); err != nil {
+		session.Stop()
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+	return session, nil
+}
+
+// Publish sends data on the board's text stream if the board has a running
+// session. It reports whether the data was queued.
+func (m *SessionManager) Publish(boardID string, data StreamTextData) bool {
+	m.mu.Lock()
+	entry, ok := m.boards[boardID]
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	entry.mu.Lock()
+	session := entry.session
+	entry.mu.Unlock()
+	if session == nil || isStopped(session) {
+		return false
+	}
+
+	session.publish(data)
+	return true
+}
+
+// ApplyPartial applies a stored partial action on the board's running session.
+func (m *SessionManager) ApplyPartial(boardID string, token string, boardState json.RawMessage) error {
+	m.mu.Lock()
+	entry, ok := m.boards[boardID]
+	m.mu.Unlock()
+	if !ok {
+		return ErrPartialNotFound
+	}
+
+	entry.mu.Lock()
+	session := entry.session
+	entry.mu.Unlock()
+	if session == nil || isStopped(session) {
+		return ErrPartialNotFound
+	}
+	return session.ApplyPartial(token, boardState)
+}
+
+// ConfirmAction applies an action held for confirmation on the board's
+// running session.
+func (m *SessionManager) ConfirmAction(boardID string, token string, boardState json.RawMessage) error {
+	m.mu.Lock()
+	entry, ok := m.boards[boardID]
+	m.mu.Unlock()
+	if !ok {
+		return ErrConfirmationNotFound
+	}
+
+	entry.mu.Lock()
+	session := entry.session
+	entry.mu.Unlock()
+	if session == nil || isStopped(session) {
+		return ErrConfirmationNotFound
+	}
+	return session.ConfirmAction(token, boardState)
+}
+
+// StreamAudio streams uploaded audio into the board's running session. Its
+// instructions count against quota, or the session speaker's quota when it
+// is zero.
+func (m *SessionManager) StreamAudio(ctx context.Context, boardID string, r io.Reader, maxDuration time.Duration, quota Quota) (*AudioUpload, error) {
+	m.mu.Lock()
+	entry, ok := m.boards[boardID]
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNoVoiceSession
+	}
+
+	entry.mu.Lock()
+	session := entry.session
+	entry.mu.Unlock()
+	if session == nil || isStopped(session) {
+		return nil, ErrNoVoiceSession
+	}
+	return session.StreamAudio(ctx, r, maxDuration, quota)
+}
+
+// ReapedRooms reports how many idle rooms have been removed since startup.
+func (m *SessionManager) ReapedRooms() int64 {
+	return m.reapedRooms.Load()
+}
+
+// Close stops the reaper and every active session.
+func (m *SessionManager) Close() error {
+	m.closeOnce.Do(func() {
+		m.cancel()
+		m.wg.Wait()
+
+		m.mu.Lock()
+		boards := m.boards
+		m.boards = make(map[string]*boardSession)
+		m.mu.Unlock()
+
+		for boardID, entry := range boards {
+			entry.mu.Lock()
+			if entry.removed {
+				// Released concurrently; its teardown is under way.
+				entry.mu.Unlock()
+				continue
+			}
+			session := m.detach(boardID, entry)
+			entry.mu.Unlock()
+
+			if session != nil {
+				session.Stop()
+			}
+			close(entry.deleted)
+		}
+	})
+	return nil
+}
+
+func (m *SessionManager) entry(boardID string) (*boardSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ctx.Err() != nil {
+		return nil, fmt.Errorf("session manager is closed")
+	}
+
+	entry, ok := m.boards[boardID]
+	if !ok {
+		entry = &boardSession{deleted: make(chan struct{})}
+		m.boards[boardID] = entry
+	}
+	return entry, nil
+}
+
+func (m *SessionManager) reaper() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.reapIdle()
+		}
+	}
+}
+
+func (m *SessionManager) reapIdle() {
+	m.mu.Lock()
+	boardIDs := make([]string, 0, len(m.boards))
+	for boardID := range m.boards {
+		boardIDs = append(boardIDs, boardID)
+	}
+	m.mu.Unlock()
+
+	for _, boardID := range boardIDs {
+		m.reapBoard(boardID)
+	}
+}
+
+func (m *SessionManager) reapBoard(boardID string) {
+	m.mu.Lock()
+	entry, ok := m.boards[boardID]
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	entry.mu.Lock()
+	if entry.removed {
+		entry.mu.Unlock()
+		return
+	}
+	joins := entry.joins
+	entry.mu.Unlock()
+
+	participants, err := m.countParticipants(boardID)
+	if err != nil {
+		logger.Warnw("Failed to list room participants", err, "boardID", boardID)
+		return
+	}
+
+	entry.mu.Lock()
+	if entry.removed || entry.joins != joins {
+		// Someone joined while the room was being checked.
+		entry.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	if participants > 0 {
+		entry.idleSince = time.Time{}
+		entry.mu.Unlock()
+		return
+	}
+	if entry.idleSince.IsZero() {
+		entry.idleSince = now
+	}
+	idleFor := now.Sub(entry.idleSince)
+	if idleFor < m.idleTimeout {
+		entry.mu.Unlock()
+		return
+	}
+	session := m.detach(boardID, entry)
+	entry.mu.Unlock()
+
+	if err := m.teardown(boardID, entry, session); err != nil {
+		logger.Warnw("Failed to delete idle room", err, "boardID", boardID)
+	}
+
+	m.reapedRooms.Inc()
+	logger.Infow("Reaped idle board session",
+		"boardID", boardID,
+		"idleFor", idleFor.String(),
+		"reapedRooms", m.reapedRooms.Load(),
+	)
+}
+
+// Release stops the board's session and deletes its room, disconnecting
+// everyone in it whether or not it is idle.
+func (m *SessionManager) Release(boardID string) error {
+	entry, err := m.entry(boardID)
+	if err != nil {
+		return err
+	}
+
+	entry.mu.Lock()
+	if entry.removed {
+		entry.mu.Unlock()
+		return nil
+	}
+	session := m.detach(boardID, entry)
+	entry.mu.Unlock()
+
+	if err := m.teardown(boardID, entry, session); err != nil {
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+	logger.Infow("Released board session", "boardID", boardID)
+	return nil
+}
+
+// detach marks the entry removed and drops it from the registry, returning
+// its session for teardown. The caller holds entry.mu.
+func (m *SessionManager) detach(boardID string, entry *boardSession) *LiveKitSession {
+	session := entry.session
+	entry.session = nil
+	entry.removed = true
+
+	m.mu.Lock()
+	if m.boards[boardID] == entry {
+		delete(m.boards, boardID)
+	}
+	m.mu.Unlock()
+	return session
+}
+
+// teardown stops a detached entry's session and deletes its room, then lets
+// the joiners waiting on the entry start a new one. The caller must not hold
+// entry.mu. The room is dropped even if it can't be deleted; LiveKit closes
+// empty rooms itself.
+func (m *SessionManager) teardown(boardID string, entry *boardSession, session *LiveKitSession) error {
+	defer close(entry.deleted)
+
+	if session != nil {
+		session.Stop()
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+	_, err := m.roomClient.DeleteRoom(ctx, &livekit.DeleteRoomRequest{Room: boardID})
+	if isRoomNotFound(err) {
+		err = nil
+	}
+	return err
+}
+
+// countParticipants returns the number of participants in the board's room,
+// excluding the server bot. A room that no longer exists counts as empty.
+func (m *SessionManager) countParticipants(boardID string) (int, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := m.roomClient.ListParticipants(ctx, &livekit.ListParticipantsRequest{Room: boardID})
+	if err != nil {
+		if isRoomNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	count := 0
+	for _, p := range resp.Participants {
+		if p.Identity != botIdentity {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func isRoomNotFound(err error) bool {
+	var twerr twirp.Error
+	return errors.As(err, &twerr) && twerr.Code() == twirp.NotFound
+}
+
+func isStopped(session *LiveKitSession) bool {
+	select {
+	case <-session.Done():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,224 @@
+package livekit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"draw/internal/db/repo"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// fakeRoomService is a LiveKit room API with a participant count per room.
+// listing blocks ListParticipants while set, so a test can join mid-reap.
+type fakeRoomService struct {
+	roomService
+
+	mu           sync.Mutex
+	participants map[string]int
+	deleted      []string
+	listing      chan struct{}
+	listed       chan struct{}
+}
+
+func newFakeRoomService() *fakeRoomService {
+	return &fakeRoomService{participants: make(map[string]int)}
+}
+
+func (f *fakeRoomService) ListParticipants(ctx context.Context, req *livekit.ListParticipantsRequest) (*livekit.ListParticipantsResponse, error) {
+	f.mu.Lock()
+	listing, listed := f.listing, f.listed
+	count := f.participants[req.Room]
+	f.mu.Unlock()
+	if listing != nil {
+		listed <- struct{}{}
+		<-listing
+	}
+
+	resp := &livekit.ListParticipantsResponse{}
+	for i := 0; i < count; i++ {
+		resp.Participants = append(resp.Participants, &livekit.ParticipantInfo{Identity: "user"})
+	}
+	return resp, nil
+}
+
+func (f *fakeRoomService) DeleteRoom(ctx context.Context, req *livekit.DeleteRoomRequest) (*livekit.DeleteRoomResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, req.Room)
+	return &livekit.DeleteRoomResponse{}, nil
+}
+
+func (f *fakeRoomService) deletedRooms() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.deleted...)
+}
+
+// newTestManager returns a manager whose sessions connect to nothing, and
+// which reaps only when the test calls reapIdle.
+func newTestManager(rooms *fakeRoomService) (*SessionManager, *int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &SessionManager{
+		roomClient:  rooms,
+		idleTimeout: time.Nanosecond,
+		boards:      make(map[string]*boardSession),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	started := 0
+	m.startSession = func(userDetails *repo.User, boardID string, callbacks SessionCallbacks) (*LiveKitSession, error) {
+		started++
+		return newTestSession(userDetails, boardID, callbacks), nil
+	}
+	return m, &started
+}
+
+// newTestSession returns a session that isn't connected to a room, so what
+// it publishes stays queued for the test to read.
+func newTestSession(userDetails *repo.User, boardID string, callbacks SessionCallbacks) *LiveKitSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &LiveKitSession{
+		boardID:       boardID,
+		ctx:           ctx,
+		cancel:        cancel,
+		callbacks:     callbacks,
+		outbound:      newOutboundQueue(outboundQueueSize),
+		partials:      make(map[string]storedPartial),
+		participants:  make(map[string]participant),
+		confirmations: make(map[string]storedConfirmation),
+	}
+	session.join(userDetails)
+	return session
+}
+
+// reap runs the reaper until the board has been idle past the timeout.
+func reap(m *SessionManager) {
+	m.reapIdle()
+	time.Sleep(time.Millisecond)
+	m.reapIdle()
+}
+
+func TestJoinIdleRejoin(t *testing.T) {
+	rooms := newFakeRoomService()
+	m, started := newTestManager(rooms)
+	defer m.Close()
+
+	alice := &repo.User{ID: "u1", Name: "alice"}
+	first, err := m.GetOrCreate(alice, "board", SessionCallbacks{})
+	if err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+
+	reap(m)
+	if !isStopped(first) {
+		t.Fatalf("idle session was not stopped")
+	}
+	if got := rooms.deletedRooms(); len(got) != 1 || got[0] != "board" {
+		t.Fatalf("deleted rooms = %v, want [board]", got)
+	}
+
+	second, err := m.GetOrCreate(alice, "board", SessionCallbacks{})
+	if err != nil {
+		t.Fatalf("GetOrCreate after reap: %v", err)
+	}
+	if second == first || isStopped(second) {
+		t.Fatalf("rejoin reused the reaped session")
+	}
+	if *started != 2 {
+		t.Fatalf("started %d sessions, want 2", *started)
+	}
+}
+
+func TestOccupiedRoomIsKept(t *testing.T) {
+	rooms := newFakeRoomService()
+	rooms.participants["board"] = 1
+	m, _ := newTestManager(rooms)
+	defer m.Close()
+
+	session, err := m.GetOrCreate(&repo.User{ID: "u1", Name: "alice"}, "board", SessionCallbacks{})
+	if err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+
+	reap(m)
+	if isStopped(session) {
+		t.Fatalf("session with participants was reaped")
+	}
+	if got := rooms.deletedRooms(); len(got) != 0 {
+		t.Fatalf("deleted rooms = %v, want none", got)
+	}
+}
+
+func TestJoinDuringReapIsKept(t *testing.T) {
+	rooms := newFakeRoomService()
+	m, started := newTestManager(rooms)
+	defer m.Close()
+
+	alice := &repo.User{ID: "u1", Name: "alice"}
+	session, err := m.GetOrCreate(alice, "board", SessionCallbacks{})
+	if err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+	m.reapIdle()
+	time.Sleep(time.Millisecond)
+
+	// Hold the reaper in ListParticipants and join meanwhile; the join must
+	// not wait for LiveKit, and the session it joins must survive.
+	rooms.mu.Lock()
+	rooms.listing, rooms.listed = make(chan struct{}), make(chan struct{})
+	rooms.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		m.reapIdle()
+		close(done)
+	}()
+	<-rooms.listed
+
+	joined, err := m.GetOrCreate(&repo.User{ID: "u2", Name: "bob"}, "board", SessionCallbacks{})
+	if err != nil {
+		t.Fatalf("GetOrCreate during reap: %v", err)
+	}
+	close(rooms.listing)
+	<-done
+
+	if joined != session || isStopped(session) {
+		t.Fatalf("session joined during the reap was torn down")
+	}
+	if *started != 1 {
+		t.Fatalf("started %d sessions, want 1", *started)
+	}
+	if got := rooms.deletedRooms(); len(got) != 0 {
+		t.Fatalf("deleted rooms = %v, want none", got)
+	}
+}
+
+func TestParticipantsKeepTheirDetails(t *testing.T) {
+	rooms := newFakeRoomService()
+	m, _ := newTestManager(rooms)
+	defer m.Close()
+
+	alice := &repo.User{ID: "u1", Name: "alice"}
+	bob := &repo.User{ID: "u2", Name: "bob"}
+	session, err := m.GetOrCreate(alice, "board", SessionCallbacks{})
+	if err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+	if _, err := m.GetOrCreate(bob, "board", SessionCallbacks{}); err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+
+	if got := session.user().ID; got != "u1" {
+		t.Fatalf("speaker before subscribing = %q, want u1", got)
+	}
+	session.listenTo("bob")
+	if got := session.user().ID; got != "u2" {
+		t.Fatalf("speaker after subscribing to bob = %q, want u2", got)
+	}
+	session.listenTo("mallory")
+	if got := session.user().ID; got != "u2" {
+		t.Fatalf("speaker after subscribing to a stranger = %q, want u2", got)
+	}
+}
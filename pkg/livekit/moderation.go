@@ -0,0 +1,148 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// roomService is the subset of the LiveKit room API the manager uses.
+type roomService interface {
+	ListRooms(ctx context.Context, req *livekit.ListRoomsRequest) (*livekit.ListRoomsResponse, error)
+	DeleteRoom(ctx context.Context, req *livekit.DeleteRoomRequest) (*livekit.DeleteRoomResponse, error)
+	ListParticipants(ctx context.Context, req *livekit.ListParticipantsRequest) (*livekit.ListParticipantsResponse, error)
+	GetParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (*livekit.ParticipantInfo, error)
+	RemoveParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (*livekit.RemoveParticipantResponse, error)
+	MutePublishedTrack(ctx context.Context, req *livekit.MuteRoomTrackRequest) (*livekit.MuteRoomTrackResponse, error)
+	UpdateParticipant(ctx context.Context, req *livekit.UpdateParticipantRequest) (*livekit.ParticipantInfo, error)
+}
+
+// Participant roles an owner can assign.
+const (
+	RoleSpeaker  = "speaker"
+	RoleListener = "listener"
+)
+
+var (
+	// ErrParticipantNotFound is returned when the participant has left.
+	ErrParticipantNotFound = errors.New("participant is not in the room")
+	// ErrRoomNotFound is returned when the board's room no longer exists.
+	ErrRoomNotFound = errors.New("room no longer exists")
+	// ErrCannotModerateBot is returned for moderation aimed at the server bot.
+	ErrCannotModerateBot = errors.New("the server participant cannot be moderated")
+	// ErrUnknownRole is returned for roles other than speaker or listener.
+	ErrUnknownRole = errors.New("role must be speaker or listener")
+)
+
+// ParticipantEvent is broadcast when a participant is moderated.
+type ParticipantEvent struct {
+	Action   string `json:"action"`
+	Identity string `json:"identity"`
+	Role     string `json:"role,omitempty"`
+}
+
+// MuteParticipant mutes every audio track the participant publishes.
+func (m *SessionManager) MuteParticipant(ctx context.Context, boardID string, identity string) error {
+	if identity == botIdentity {
+		return ErrCannotModerateBot
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	participant, err := m.roomClient.GetParticipant(ctx, &livekit.RoomParticipantIdentity{
+		Room:     boardID,
+		Identity: identity,
+	})
+	if err != nil {
+		return m.moderationError(ctx, boardID, err)
+	}
+
+	for _, track := range participant.Tracks {
+		if track.Type != livekit.TrackType_AUDIO || track.Muted {
+			continue
+		}
+		if _, err := m.roomClient.MutePublishedTrack(ctx, &livekit.MuteRoomTrackRequest{
+			Room:     boardID,
+			Identity: identity,
+			TrackSid: track.Sid,
+			Muted:    true,
+		}); err != nil {
+			return m.moderationError(ctx, boardID, err)
+		}
+	}
+
+	m.Publish(boardID, StreamTextData{
+		Type: "participant",
+		Data: ParticipantEvent{Action: "muted", Identity: identity},
+	})
+	return nil
+}
+
+// RemoveParticipant disconnects the participant from the board's room.
+func (m *SessionManager) RemoveParticipant(ctx context.Context, boardID string, identity string) error {
+	if identity == botIdentity {
+		return ErrCannotModerateBot
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := m.roomClient.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+		Room:     boardID,
+		Identity: identity,
+	}); err != nil {
+		return m.moderationError(ctx, boardID, err)
+	}
+
+	m.Publish(boardID, StreamTextData{
+		Type: "participant",
+		Data: ParticipantEvent{Action: "removed", Identity: identity},
+	})
+	return nil
+}
+
+// SetParticipantRole lets a participant speak or restricts them to
+// listening. Listeners can still subscribe and send data.
+func (m *SessionManager) SetParticipantRole(ctx context.Context, boardID string, identity string, role string) error {
+	if identity == botIdentity {
+		return ErrCannotModerateBot
+	}
+	if role != RoleSpeaker && role != RoleListener {
+		return ErrUnknownRole
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := m.roomClient.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+		Room:     boardID,
+		Identity: identity,
+		Permission: &livekit.ParticipantPermission{
+			CanSubscribe:   true,
+			CanPublish:     role == RoleSpeaker,
+			CanPublishData: true,
+		},
+	}); err != nil {
+		return m.moderationError(ctx, boardID, err)
+	}
+
+	m.Publish(boardID, StreamTextData{
+		Type: "participant",
+		Data: ParticipantEvent{Action: "role", Identity: identity, Role: role},
+	})
+	return nil
+}
+
+// moderationError tells a missing participant apart from a missing room,
+// since LiveKit reports both as not found.
+func (m *SessionManager) moderationError(ctx context.Context, boardID string, err error) error {
+	if !isRoomNotFound(err) {
+		return fmt.Errorf("livekit request failed: %w", err)
+	}
+	rooms, listErr := m.roomClient.ListRooms(ctx, &livekit.ListRoomsRequest{Names: []string{boardID}})
+	if listErr == nil && len(rooms.Rooms) == 0 {
+		return ErrRoomNotFound
+	}
+	return ErrParticipantNotFound
+}
@@ -0,0 +1,205 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"draw/internal/db/repo"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/twitchtv/twirp"
+)
+
+// moderatedRooms is a LiveKit room API holding one room, "board", with the
+// given participants. It answers like LiveKit: not found for a missing
+// participant or room alike. failWith fails every moderation call.
+type moderatedRooms struct {
+	*fakeRoomService
+
+	mu           sync.Mutex
+	roomExists   bool
+	participants map[string]*livekit.ParticipantInfo
+	failWith     error
+	calls        []string
+}
+
+func newModeratedRooms(participants ...*livekit.ParticipantInfo) *moderatedRooms {
+	f := &moderatedRooms{
+		fakeRoomService: newFakeRoomService(),
+		roomExists:      true,
+		participants:    make(map[string]*livekit.ParticipantInfo),
+	}
+	for _, p := range participants {
+		f.participants[p.Identity] = p
+	}
+	return f
+}
+
+func (f *moderatedRooms) find(call string, room string, identity string) (*livekit.ParticipantInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+	if f.failWith != nil {
+		return nil, f.failWith
+	}
+	if !f.roomExists || room != "board" {
+		return nil, twirp.NotFoundError("room not found")
+	}
+	p, ok := f.participants[identity]
+	if !ok {
+		return nil, twirp.NotFoundError("participant not found")
+	}
+	return p, nil
+}
+
+func (f *moderatedRooms) ListRooms(ctx context.Context, req *livekit.ListRoomsRequest) (*livekit.ListRoomsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &livekit.ListRoomsResponse{}
+	for _, name := range req.Names {
+		if name == "board" && f.roomExists {
+			resp.Rooms = append(resp.Rooms, &livekit.Room{Name: name})
+		}
+	}
+	return resp, nil
+}
+
+func (f *moderatedRooms) GetParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (*livekit.ParticipantInfo, error) {
+	return f.find("get", req.Room, req.Identity)
+}
+
+func (f *moderatedRooms) RemoveParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (*livekit.RemoveParticipantResponse, error) {
+	if _, err := f.find("remove", req.Room, req.Identity); err != nil {
+		return nil, err
+	}
+	return &livekit.RemoveParticipantResponse{}, nil
+}
+
+func (f *moderatedRooms) MutePublishedTrack(ctx context.Context, req *livekit.MuteRoomTrackRequest) (*livekit.MuteRoomTrackResponse, error) {
+	if _, err := f.find("mute "+req.TrackSid, req.Room, req.Identity); err != nil {
+		return nil, err
+	}
+	return &livekit.MuteRoomTrackResponse{}, nil
+}
+
+func (f *moderatedRooms) UpdateParticipant(ctx context.Context, req *livekit.UpdateParticipantRequest) (*livekit.ParticipantInfo, error) {
+	p, err := f.find(fmt.Sprintf("update publish=%v", req.Permission.CanPublish), req.Room, req.Identity)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Permission.CanSubscribe || !req.Permission.CanPublishData {
+		return nil, fmt.Errorf("permission %+v takes away more than publishing", req.Permission)
+	}
+	return p, nil
+}
+
+func TestModeration(t *testing.T) {
+	bob := func() *livekit.ParticipantInfo {
+		return &livekit.ParticipantInfo{
+			Identity: "bob",
+			Tracks: []*livekit.TrackInfo{
+				{Sid: "TR_mic", Type: livekit.TrackType_AUDIO},
+				{Sid: "TR_cam", Type: livekit.TrackType_VIDEO},
+				{Sid: "TR_muted", Type: livekit.TrackType_AUDIO, Muted: true},
+			},
+		}
+	}
+	moderate := map[string]func(m *SessionManager, identity string) error{
+		"mute": func(m *SessionManager, identity string) error {
+			return m.MuteParticipant(context.Background(), "board", identity)
+		},
+		"remove": func(m *SessionManager, identity string) error {
+			return m.RemoveParticipant(context.Background(), "board", identity)
+		},
+		"listener": func(m *SessionManager, identity string) error {
+			return m.SetParticipantRole(context.Background(), "board", identity, RoleListener)
+		},
+		"speaker": func(m *SessionManager, identity string) error {
+			return m.SetParticipantRole(context.Background(), "board", identity, RoleSpeaker)
+		},
+		"owner": func(m *SessionManager, identity string) error {
+			return m.SetParticipantRole(context.Background(), "board", identity, "owner")
+		},
+	}
+
+	tests := []struct {
+		name     string
+		action   string
+		identity string
+		// setup changes the room before the action.
+		setup func(rooms *moderatedRooms)
+		want  error
+		calls string
+		event string
+	}{
+		{name: "mute", action: "mute", identity: "bob", calls: "get,mute TR_mic", event: "muted bob"},
+		{name: "remove", action: "remove", identity: "bob", calls: "remove", event: "removed bob"},
+		{name: "make listener", action: "listener", identity: "bob", calls: "update publish=false", event: "role bob listener"},
+		{name: "make speaker", action: "speaker", identity: "bob", calls: "update publish=true", event: "role bob speaker"},
+		{name: "unknown role", action: "owner", identity: "bob", want: ErrUnknownRole},
+		{name: "mute the bot", action: "mute", identity: botIdentity, want: ErrCannotModerateBot},
+		{name: "remove the bot", action: "remove", identity: botIdentity, want: ErrCannotModerateBot},
+		{name: "demote the bot", action: "listener", identity: botIdentity, want: ErrCannotModerateBot},
+		{name: "participant gone", action: "mute", identity: "carol", want: ErrParticipantNotFound, calls: "get"},
+		{name: "participant gone before removal", action: "remove", identity: "carol", want: ErrParticipantNotFound, calls: "remove"},
+		{
+			name: "room gone", action: "remove", identity: "bob",
+			setup: func(rooms *moderatedRooms) { rooms.roomExists = false },
+			want:  ErrRoomNotFound, calls: "remove",
+		},
+		{
+			name: "livekit failure", action: "listener", identity: "bob",
+			setup: func(rooms *moderatedRooms) { rooms.failWith = twirp.InternalError("boom") },
+			calls: "update publish=false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rooms := newModeratedRooms(bob())
+			if tt.setup != nil {
+				tt.setup(rooms)
+			}
+			m, _ := newTestManager(rooms.fakeRoomService)
+			m.roomClient = rooms
+			defer m.Close()
+			session, err := m.GetOrCreate(&repo.User{ID: "u1", Name: "alice"}, "board", SessionCallbacks{})
+			if err != nil {
+				t.Fatalf("GetOrCreate: %v", err)
+			}
+			session.outbound.drain()
+
+			err = moderate[tt.action](m, tt.identity)
+			switch {
+			case tt.want != nil:
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("got %v, want %v", err, tt.want)
+				}
+			case tt.event == "":
+				if err == nil || errors.Is(err, ErrParticipantNotFound) || errors.Is(err, ErrRoomNotFound) {
+					t.Fatalf("got %v, want the LiveKit failure passed on", err)
+				}
+			case err != nil:
+				t.Fatalf("got %v, want success", err)
+			}
+
+			if got := strings.Join(rooms.calls, ","); got != tt.calls {
+				t.Errorf("LiveKit calls = %q, want %q", got, tt.calls)
+			}
+			var events []string
+			for _, event := range session.outbound.drain() {
+				if event.Type != "participant" {
+					continue
+				}
+				p := event.Data.(ParticipantEvent)
+				events = append(events, strings.TrimSpace(p.Action+" "+p.Identity+" "+p.Role))
+			}
+			if got := strings.Join(events, ","); got != tt.event {
+				t.Errorf("participant events = %q, want %q", got, tt.event)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package livekit
+
+import (
+	"sync"
+)
+
+// outboundQueueSize bounds how many board events can wait to be sent.
+const outboundQueueSize = 100
+
+// deliveryPolicy decides what happens to an event when clients fall behind.
+type deliveryPolicy int
+
+const (
+	// policyMustDeliver events are sent in order. If the queue is full of
+	// them, the backlog is discarded and a resync event is sent instead.
+	policyMustDeliver deliveryPolicy = iota
+	// policyLatestWins events replace an older queued event of the same
+	// type from the same sender.
+	policyLatestWins
+	// policyDroppable events are discarded whenever space is needed.
+	policyDroppable
+)
+
+// eventPolicies lists event types that may be coalesced or dropped. Anything
+// not listed, including canvas updates, is must-deliver.
+var eventPolicies = map[string]deliveryPolicy{
+	"presence": policyLatestWins,
+	"caption":  policyLatestWins,
+	"preview":  policyDroppable,
+}
+
+func policyFor(eventType string) deliveryPolicy {
+	if policy, ok := eventPolicies[eventType]; ok {
+		return policy
+	}
+	return policyMustDeliver
+}
+
+// outboundQueue buffers board events between producers and the text stream
+// writer, applying each event type's delivery policy instead of blocking
+// producers when the writer is slow.
+type outboundQueue struct {
+	mu       sync.Mutex
+	events   []StreamTextData
+	capacity int
+	notify   chan struct{}
+	dropped  map[string]uint64
+}
+
+func newOutboundQueue(capacity int) *outboundQueue {
+	return &outboundQueue{
+		capacity: capacity,
+		notify:   make(chan struct{}, 1),
+		dropped:  make(map[string]uint64),
+	}
+}
+
+func (q *outboundQueue) push(data StreamTextData) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch policyFor(data.Type) {
+	case policyLatestWins:
+		for i, queued := range q.events {
+			if queued.Type == data.Type && queued.Sender == data.Sender {
+				q.events[i] = data
+				q.dropped[data.Type]++
+				return
+			}
+		}
+		if len(q.events) >= q.capacity && !q.evictDroppable() {
+			q.dropped[data.Type]++
+			return
+		}
+	case policyDroppable:
+		if len(q.events) >= q.capacity {
+			q.dropped[data.Type]++
+			return
+		}
+	default:
+		if len(q.events) >= q.capacity && !q.evictDroppable() {
+			q.overflow()
+		}
+	}
+
+	q.events = append(q.events, data)
+	q.signal()
+}
+
+// evictDroppable removes the oldest event that isn't must-deliver, reporting
+// whether one was found.
+func (q *outboundQueue) evictDroppable() bool {
+	for i, queued := range q.events {
+		if policyFor(queued.Type) != policyMustDeliver {
+			q.dropped[queued.Type]++
+			q.events = append(q.events[:i], q.events[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// overflow discards the whole backlog and replaces it with a resync event
+// telling clients to reload the board.
+func (q *outboundQueue) overflow() {
+	for _, queued := range q.events {
+		q.dropped[queued.Type]++
+	}
+	q.events = append(q.events[:0], StreamTextData{Type: "resync"})
+}
+
+func (q *outboundQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain takes every queued event, oldest first.
+func (q *outboundQueue) drain() []StreamTextData {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	events := q.events
+	q.events = nil
+	return events
+}
+
+// droppedCounts returns how many events of each type were coalesced or
+// dropped.
+func (q *outboundQueue) droppedCounts() map[string]uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counts := make(map[string]uint64, len(q.dropped))
+	for eventType, count := range q.dropped {
+		counts[eventType] = count
+	}
+	return counts
+}
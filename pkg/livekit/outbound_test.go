@@ -0,0 +1,201 @@
+package livekit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func eventTypes(events []StreamTextData) []string {
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type + ":" + event.Sender + ":" + fmt.Sprint(event.Data)
+	}
+	return types
+}
+
+func TestOutboundQueuePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		push    []StreamTextData
+		want    []string
+		dropped map[string]uint64
+	}{
+		{
+			name: "presence coalesced per sender",
+			push: []StreamTextData{
+				{Type: "presence", Sender: "a", Data: 1},
+				{Type: "presence", Sender: "b", Data: 1},
+				{Type: "presence", Sender: "a", Data: 2},
+			},
+			want:    []string{"presence:a:2", "presence:b:1"},
+			dropped: map[string]uint64{"presence": 1},
+		},
+		{
+			name: "captions coalesced apart from presence",
+			push: []StreamTextData{
+				{Type: "caption", Sender: "a", Data: 1},
+				{Type: "presence", Sender: "a", Data: 1},
+				{Type: "caption", Sender: "a", Data: 2},
+			},
+			want:    []string{"caption:a:2", "presence:a:1"},
+			dropped: map[string]uint64{"caption": 1},
+		},
+		{
+			name: "mutations never coalesced",
+			push: []StreamTextData{
+				{Type: "canvas_update", Sender: "a", Data: 1},
+				{Type: "canvas_update", Sender: "a", Data: 2},
+			},
+			want:    []string{"canvas_update:a:1", "canvas_update:a:2"},
+			dropped: map[string]uint64{},
+		},
+		{
+			name: "preview dropped when full",
+			push: []StreamTextData{
+				{Type: "canvas_update", Data: 1},
+				{Type: "canvas_update", Data: 2},
+				{Type: "preview", Data: 3},
+			},
+			want:    []string{"canvas_update::1", "canvas_update::2"},
+			dropped: map[string]uint64{"preview": 1},
+		},
+		{
+			name: "mutation evicts a preview",
+			push: []StreamTextData{
+				{Type: "preview", Data: 1},
+				{Type: "canvas_update", Data: 2},
+				{Type: "canvas_update", Data: 3},
+			},
+			want:    []string{"canvas_update::2", "canvas_update::3"},
+			dropped: map[string]uint64{"preview": 1},
+		},
+		{
+			name: "presence evicts a preview",
+			push: []StreamTextData{
+				{Type: "preview", Data: 1},
+				{Type: "canvas_update", Data: 2},
+				{Type: "presence", Sender: "a", Data: 3},
+			},
+			want:    []string{"canvas_update::2", "presence:a:3"},
+			dropped: map[string]uint64{"preview": 1},
+		},
+		{
+			name: "presence dropped behind mutations",
+			push: []StreamTextData{
+				{Type: "canvas_update", Data: 1},
+				{Type: "canvas_update", Data: 2},
+				{Type: "presence", Sender: "a", Data: 3},
+			},
+			want:    []string{"canvas_update::1", "canvas_update::2"},
+			dropped: map[string]uint64{"presence": 1},
+		},
+		{
+			name: "mutation overflow resyncs",
+			push: []StreamTextData{
+				{Type: "canvas_update", Data: 1},
+				{Type: "canvas_update", Data: 2},
+				{Type: "canvas_update", Data: 3},
+			},
+			want:    []string{"resync::<nil>", "canvas_update::3"},
+			dropped: map[string]uint64{"canvas_update": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newOutboundQueue(2)
+			for _, event := range tt.push {
+				q.push(event)
+			}
+			got := eventTypes(q.drain())
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("queued %v, want %v", got, tt.want)
+			}
+			if dropped := q.droppedCounts(); fmt.Sprint(dropped) != fmt.Sprint(tt.dropped) {
+				t.Errorf("dropped %v, want %v", dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestOutboundQueueSlowReader(t *testing.T) {
+	const mutations = 50
+	const presenceUpdates = 500
+	q := newOutboundQueue(outboundQueueSize)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < mutations; i++ {
+			q.push(StreamTextData{Type: "canvas_update", Data: i})
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < presenceUpdates; i++ {
+			for _, sender := range []string{"a", "b"} {
+				q.push(StreamTextData{Type: "presence", Sender: sender, Data: i})
+			}
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var received []StreamTextData
+	for finished := false; !finished; {
+		select {
+		case <-q.notify:
+		case <-done:
+			finished = true
+		}
+		// A slow reader: everything pushed meanwhile piles up.
+		time.Sleep(2 * time.Millisecond)
+		received = append(received, q.drain()...)
+	}
+	received = append(received, q.drain()...)
+
+	next := 0
+	presence := 0
+	last := map[string]int{}
+	for _, event := range received {
+		switch event.Type {
+		case "canvas_update":
+			if event.Data != next {
+				t.Fatalf("mutation %v arrived when %d was next", event.Data, next)
+			}
+			next++
+		case "presence":
+			presence++
+			if event.Data.(int) < last[event.Sender] {
+				t.Errorf("presence from %s went back to %v after %d", event.Sender, event.Data, last[event.Sender])
+			}
+			last[event.Sender] = event.Data.(int)
+		default:
+			t.Errorf("unexpected %s event", event.Type)
+		}
+	}
+	if next != mutations {
+		t.Errorf("received %d mutations, want all %d", next, mutations)
+	}
+	if presence >= 2*presenceUpdates {
+		t.Errorf("received all %d presence updates, want them collapsed", presence)
+	}
+	for _, sender := range []string{"a", "b"} {
+		if last[sender] != presenceUpdates-1 {
+			t.Errorf("last presence from %s = %d, want the latest, %d", sender, last[sender], presenceUpdates-1)
+		}
+	}
+	dropped := q.droppedCounts()
+	if dropped["canvas_update"] != 0 {
+		t.Errorf("dropped %d mutations, want none", dropped["canvas_update"])
+	}
+	if int(dropped["presence"])+presence != 2*presenceUpdates {
+		t.Errorf("dropped %d and received %d presence updates, want %d in all", dropped["presence"], presence, 2*presenceUpdates)
+	}
+}
@@ -0,0 +1,101 @@
+package livekit
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+
+	"github.com/google/uuid"
+)
+
+// partialTTL is how long the valid part of a failed instruction can be applied.
+const partialTTL = 5 * time.Minute
+
+var (
+	// ErrPartialNotFound is returned for unknown, used, or expired tokens.
+	ErrPartialNotFound = errors.New("partial action not found")
+	// ErrPartialStale is returned when the board changed after the partial
+	// was produced.
+	ErrPartialStale = errors.New("board has changed since the partial action was produced")
+)
+
+type storedPartial struct {
+	elements  []llm.Element
+	boardHash string
+	expiresAt time.Time
+}
+
+// storePartial keeps the usable elements of a failed instruction and sets the
+// failure's token so the client can apply them later.
+func (s *LiveKitSession) storePartial(failure *InstructionFailure) {
+	if len(failure.PartialElements) == 0 {
+		return
+	}
+
+	now := time.Now()
+	token := uuid.New().String()
+
+	s.partialsMu.Lock()
+	defer s.partialsMu.Unlock()
+
+	for key, partial := range s.partials {
+		if now.After(partial.expiresAt) {
+			delete(s.partials, key)
+		}
+	}
+	s.partials[token] = storedPartial{
+		elements:  failure.PartialElements,
+		boardHash: failure.boardHash,
+		expiresAt: now.Add(partialTTL),
+	}
+	failure.PartialToken = token
+}
+
+// ApplyPartial broadcasts the stored elements for token as an add action,
+// provided the board still matches the state they were produced against.
+// A token can only be used once.
+func (s *LiveKitSession) ApplyPartial(token string, boardState json.RawMessage) error {
+	s.partialsMu.Lock()
+	partial, ok := s.partials[token]
+	if ok && time.Now().After(partial.expiresAt) {
+		delete(s.partials, token)
+		ok = false
+	}
+	if !ok {
+		s.partialsMu.Unlock()
+		return ErrPartialNotFound
+	}
+	if hashBoardState(string(boardState)) != partial.boardHash {
+		delete(s.partials, token)
+		s.partialsMu.Unlock()
+		return ErrPartialStale
+	}
+	delete(s.partials, token)
+	s.partialsMu.Unlock()
+
+	action := &llm.WhiteboardAction{
+		Action:   llm.ActionAdd,
+		Elements: partial.elements,
+	}
+	// The elements come straight from model output, so their IDs may clash
+	// with the board like any other add. The board matched the stored hash,
+	// so it parses; if not, only the collision check against it is lost.
+	var board []llm.Element
+	_ = json.Unmarshal(boardState, &board)
+	whiteboard.AssignElementIDs(action, board, token)
+	data, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	s.publish(StreamTextData{
+		Type: "canvas_update",
+		Data: &llm.LLMResponse{
+			Response:  string(data),
+			Timestamp: time.Now().UTC(),
+		},
+	})
+	return nil
+}
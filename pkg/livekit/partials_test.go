@@ -0,0 +1,89 @@
+package livekit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"draw/internal/db/repo"
+	"draw/pkg/llm"
+)
+
+// storeTestPartial stores one recovered ellipse produced against board and
+// returns its token.
+func storeTestPartial(t *testing.T, s *LiveKitSession, board string) string {
+	t.Helper()
+	failure := &InstructionFailure{
+		PartialElements: []llm.Element{{ID: "c", Type: "ellipse", X: 200, Y: 0}},
+		boardHash:       hashBoardState(board),
+	}
+	s.storePartial(failure)
+	if failure.PartialToken == "" {
+		t.Fatalf("partial was not stored")
+	}
+	return failure.PartialToken
+}
+
+// TestApplyPartialBoardState checks the partial against the board the server
+// has stored, whatever the client last saw.
+func TestApplyPartialBoardState(t *testing.T) {
+	tests := []struct {
+		name       string
+		producedOn string
+		// stored is the board's persisted elements when the partial is
+		// applied; the service passes "[]" for boards without any.
+		stored string
+		want   error
+	}{
+		{name: "unchanged board", producedOn: heldBoard, stored: heldBoard},
+		{name: "reserialized board", producedOn: heldBoard, stored: " " + heldBoard + "\n"},
+		{name: "board without content", producedOn: "[]", stored: "[]"},
+		{name: "board changed since", producedOn: heldBoard, stored: `[{"id":"a","type":"rectangle","x":0,"y":0}]`, want: ErrPartialStale},
+		{name: "board filled since", producedOn: "[]", stored: heldBoard, want: ErrPartialStale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSession(&repo.User{ID: "u1", Name: "alice"}, "board", SessionCallbacks{})
+			defer s.cancel()
+			token := storeTestPartial(t, s, tt.producedOn)
+
+			err := s.ApplyPartial(token, json.RawMessage(tt.stored))
+			events := s.outbound.drain()
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("ApplyPartial = %v, want %v", err, tt.want)
+				}
+				if len(events) != 0 {
+					t.Errorf("rejected partial published %+v, want nothing", events)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("ApplyPartial: %v", err)
+				}
+				if len(events) != 1 || events[0].Type != "canvas_update" {
+					t.Fatalf("published %+v, want one canvas_update", events)
+				}
+				action, err := llm.ParseWhiteboardAction(events[0].Data.(*llm.LLMResponse).Response)
+				if err != nil {
+					t.Fatalf("published action doesn't parse: %v", err)
+				}
+				if action.Action != llm.ActionAdd || len(action.Elements) != 1 || action.Elements[0].Type != "ellipse" {
+					t.Errorf("published %+v, want the recovered ellipse added", action)
+				}
+			}
+
+			// Either way the token is spent.
+			if err := s.ApplyPartial(token, json.RawMessage(tt.producedOn)); !errors.Is(err, ErrPartialNotFound) {
+				t.Errorf("reusing the token = %v, want ErrPartialNotFound", err)
+			}
+		})
+	}
+}
+
+func TestManagerApplyPartialWithoutSession(t *testing.T) {
+	m, _ := newTestManager(newFakeRoomService())
+	defer m.Close()
+	if err := m.ApplyPartial("board", "token", json.RawMessage(heldBoard)); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("ApplyPartial without a session = %v, want ErrPartialNotFound", err)
+	}
+}
@@ -0,0 +1,707 @@
+package livekit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+)
+
+// Pipeline turns a transcribed instruction into a whiteboard action. Each
+// stage is a method of its own so the pipeline can run outside a voice
+// session, such as when a recorded session is replayed.
+type Pipeline struct {
+	LLMClient llm.LLMClient
+	// MaxAttempts is how many times an instruction is tried before giving
+	// up. Values below 1 mean a single attempt.
+	MaxAttempts int
+	// PromptTokenBudget caps the estimated prompt size; 0 means no cap.
+	PromptTokenBudget int
+	// BoardStateMaxBytes caps the board state JSON in the prompt; 0 means
+	// no cap.
+	BoardStateMaxBytes int
+	// FastPath answers simple geometry commands without the model; see
+	// whiteboard.FastPathAction.
+	FastPath bool
+	// LenientValidation drops arrow bindings to elements that don't exist
+	// instead of rejecting the action; see llm.DropBadBindings.
+	LenientValidation bool
+	// NormalizeColors snaps colors outside the prompt's palette to the
+	// nearest palette color; see whiteboard.NormalizeColors.
+	NormalizeColors bool
+	// AutoLayout lays out the flowcharts add actions draw instead of keeping
+	// the model's coordinates; see whiteboard.LayoutAdded.
+	AutoLayout bool
+	// Guard flags resolved actions that delete or rewrite too much of the
+	// board with RequiresConfirmation; the zero value flags none.
+	Guard whiteboard.DestructiveLimits
+	// OnPreview receives provisional elements when the client streams;
+	// OnPreviewClear is called once streaming finishes. Both are optional.
+	OnPreview      PreviewCallback
+	OnPreviewClear PreviewClearCallback
+}
+
+// FastPathProvider is reported as the provider of fast-path responses, so
+// they show up on their own in analytics and timing reports.
+const FastPathProvider = "fast_path"
+
+// Instruction is everything the pipeline's output depends on besides the
+// model itself.
+type Instruction struct {
+	RequestID     string
+	Transcription string
+	BoardState    string
+	Board         []llm.Element
+	Options       llm.GenerateOptions
+	ArrowRepair   string
+	// Instructions are the instructions joined into Transcription, in the
+	// order they were given, when a batch holds several; see PrepareBatch.
+	Instructions []string
+}
+
+// PipelineAttempt is the model output of one attempt and, if the attempt
+// failed, the stage and error it failed with.
+type PipelineAttempt struct {
+	Output string                `json:"output,omitempty"`
+	Stage  string                `json:"stage,omitempty"`
+	Error  string                `json:"error,omitempty"`
+	Issues []llm.ValidationIssue `json:"issues,omitempty"`
+}
+
+// PipelineResult is the outcome of running an instruction. Err is an
+// *InstructionFailure when every attempt failed.
+type PipelineResult struct {
+	Response *llm.LLMResponse
+	Action   *llm.WhiteboardAction
+	Attempts []PipelineAttempt
+	// Followups are the actions after Action, in order, when a batch of
+	// instructions was answered with several. Each is broadcast on its own;
+	// the usage of the request is charged to Response.
+	Followups []*llm.LLMResponse
+	Err       error
+
+	timing instructionTiming
+}
+
+// Prepare parses the board state and resolves the context added to the
+// prompt. Relative dates are resolved against now in the board's time zone
+// and locale; an unknown arrow repair policy falls back to unbinding.
+func (p *Pipeline) Prepare(requestID string, transcription string, boardState string, now time.Time, timezone string, locale string, arrowRepair string) *Instruction {
+	var board []llm.Element
+	if err := json.Unmarshal([]byte(boardState), &board); err != nil {
+		fmt.Println("Failed to parse board state", err)
+		board = nil
+	}
+	if !whiteboard.ValidArrowRepair(arrowRepair) {
+		arrowRepair = whiteboard.ArrowRepairUnbind
+	}
+
+	return &Instruction{
+		RequestID:     requestID,
+		Transcription: transcription,
+		BoardState:    boardState,
+		Board:         board,
+		Options: llm.GenerateOptions{
+			Referents:          append(whiteboard.ResolveReferents(transcription, board), whiteboard.ResolveSpatialReferents(transcription, board)...),
+			Substitutions:      whiteboard.ResolveDates(transcription, now, timezone, locale),
+			PromptTokenBudget:  p.PromptTokenBudget,
+			BoardStateMaxBytes: p.BoardStateMaxBytes,
+		},
+		ArrowRepair: arrowRepair,
+	}
+}
+
+// PrepareBatch prepares instructions given one after another, such as the
+// sentences a user speaks before pausing, to be sent to the model in one
+// request. Transcription holds them joined, so a batch reads like a single
+// instruction everywhere but in the prompt.
+func (p *Pipeline) PrepareBatch(requestID string, instructions []string, boardState string, now time.Time, timezone string, locale string, arrowRepair string) *Instruction {
+	inst := p.Prepare(requestID, strings.Join(instructions, " "), boardState, now, timezone, locale, arrowRepair)
+	if len(instructions) > 1 {
+		inst.Instructions = instructions
+	}
+	return inst
+}
+
+// Run tries the instruction until it yields a usable action or the attempt
+// budget is spent.
+func (p *Pipeline) Run(ctx context.Context, inst *Instruction) *PipelineResult {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	started := time.Now()
+	if p.FastPath && len(inst.Instructions) == 0 {
+		if result := p.fastPath(inst, started); result != nil {
+			return result
+		}
+	}
+	result := &PipelineResult{}
+	failure := &InstructionFailure{boardHash: hashBoardState(inst.BoardState)}
+	// attemptInst carries the previous attempt's validation errors into the
+	// next prompt; inst itself is left as prepared.
+	attemptInst := *inst
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		generateStart := time.Now()
+		response, err := p.Generate(ctx, &attemptInst)
+		elapsed := time.Since(generateStart)
+		if response != nil {
+			result.timing.queue += response.QueueWait
+			elapsed -= response.QueueWait
+			if result.timing.firstByte == 0 {
+				result.timing.firstByte = response.FirstByte
+			}
+		}
+		result.timing.llm += elapsed
+		var invalid *llm.InvalidOutputError
+		if errors.As(err, &invalid) {
+			// The client already asked the model to fix its JSON; what it
+			// returned in the end failed like any other unparseable output.
+			failure.record(StageParse, invalid.Err, invalid.Raw)
+			if invalid.Response != nil {
+				failure.charge(invalid.Response)
+			}
+			result.Attempts = append(result.Attempts, PipelineAttempt{Output: invalid.Raw, Stage: StageParse, Error: invalid.Err.Error()})
+			continue
+		}
+		if err != nil {
+			failure.record(StageGenerate, err, "")
+			result.Attempts = append(result.Attempts, PipelineAttempt{Stage: StageGenerate, Error: err.Error()})
+			// Retrying at once can't help when the budget is spent, the
+			// provider is backed up, the user is over their rate limit or the
+			// credentials are wrong.
+			if errors.Is(err, llm.ErrBudgetExhausted) || errors.Is(err, llm.ErrQueueFull) || errors.Is(err, llm.ErrAuth) || errors.Is(err, llm.ErrRateLimited) {
+				break
+			}
+			continue
+		}
+
+		rawOutput := response.Response
+		applyStart := time.Now()
+		var followups []*llm.LLMResponse
+		var action *llm.WhiteboardAction
+		var stage string
+		if len(inst.Instructions) > 0 {
+			action, followups, stage, err = p.ResolveBatch(inst, response)
+		} else {
+			action, stage, err = p.Resolve(inst, response)
+		}
+		result.timing.apply += time.Since(applyStart)
+		if err != nil {
+			failure.record(stage, err, response.Response)
+			failure.charge(response)
+			issues := validationErrors(err)
+			result.Attempts = append(result.Attempts, PipelineAttempt{Output: rawOutput, Stage: stage, Error: err.Error(), Issues: issues})
+			attemptInst.Options.Repair = issues
+			continue
+		}
+		result.Attempts = append(result.Attempts, PipelineAttempt{Output: rawOutput})
+
+		// Earlier failed attempts were paid for too.
+		response.Usage.PromptTokens += failure.Usage.PromptTokens
+		response.Usage.CompletionTokens += failure.Usage.CompletionTokens
+		response.CostUSD += failure.CostUSD
+		response.Latency = time.Since(started)
+		result.Response = response
+		result.Action = action
+		result.Followups = followups
+		return result
+	}
+
+	failure.Latency = time.Since(started)
+	result.Err = failure
+	return result
+}
+
+// fastPath answers the instruction without the model when it is a simple
+// geometry command on one unambiguous element, or returns nil. The result
+// has no attempts, since the model was never asked.
+func (p *Pipeline) fastPath(inst *Instruction, started time.Time) *PipelineResult {
+	action, ok := whiteboard.FastPathAction(inst.Transcription, inst.Board)
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(action)
+	if err != nil {
+		return nil
+	}
+	response := &llm.LLMResponse{
+		Response:     string(data),
+		Timestamp:    time.Now().UTC(),
+		FastPath:     true,
+		Provider:     FastPathProvider,
+		ParsedAction: action,
+	}
+	if err := repairDelete(response, action, inst.Board, inst.ArrowRepair); err != nil {
+		return nil
+	}
+	if reason := p.Guard.Check(action, inst.Board); reason != "" {
+		response.RequiresConfirmation = true
+		response.ConfirmationReason = reason
+	}
+	if inverse, err := whiteboard.Invert(action, inst.Board); err == nil {
+		response.Inverse = inverse
+	}
+	response.Latency = time.Since(started)
+	return &PipelineResult{
+		Response: response,
+		Action:   action,
+		timing:   instructionTiming{apply: response.Latency},
+	}
+}
+
+// Generate calls the LLM, streaming provisional elements to OnPreview when
+// the client supports streaming.
+func (p *Pipeline) Generate(ctx context.Context, inst *Instruction) (*llm.LLMResponse, error) {
+	streamer, ok := p.LLMClient.(llm.StreamingLLMClient)
+	if !ok || p.OnPreview == nil {
+		if len(inst.Instructions) > 0 {
+			return llm.GenerateResponseBatch(ctx, p.LLMClient, inst.Instructions, inst.BoardState, inst.Options)
+		}
+		return p.LLMClient.GenerateResponseWithOptions(ctx, inst.Transcription, inst.BoardState, inst.Options)
+	}
+
+	parser := llm.NewElementStreamParser(func(element llm.Element) {
+		p.OnPreview(inst.RequestID, element)
+	})
+	started := time.Now()
+	var firstByte time.Duration
+	prompt := inst.Transcription
+	if len(inst.Instructions) > 0 {
+		prompt = llm.BatchPrompt(inst.Instructions)
+	}
+	response, err := streamer.GenerateResponseStream(ctx, prompt, inst.BoardState, inst.Options, func(chunk string) {
+		if firstByte == 0 {
+			firstByte = time.Since(started)
+		}
+		parser.WriteString(chunk)
+	})
+	if p.OnPreviewClear != nil {
+		p.OnPreviewClear(inst.RequestID)
+	}
+	if response != nil && firstByte > 0 {
+		response.FirstByte = firstByte - response.QueueWait
+	}
+	return response, err
+}
+
+// Resolve parses the model output, unless the client already did, and
+// resolves it against the board:
+// transforms become plain updates, updates and deletes aimed at the wrong
+// element of one described by position are retargeted, stickies become
+// rectangles, added elements get server-assigned IDs, the action is validated, and deletes
+// take dangling arrows with them. Actions over the Guard limits are flagged
+// for confirmation.
+// The response and its ParsedAction are rewritten to the resolved action. On
+// failure the stage it failed at is returned with the error.
+func (p *Pipeline) Resolve(inst *Instruction, response *llm.LLMResponse) (*llm.WhiteboardAction, string, error) {
+	action, _, stage, err := p.resolve(inst, response)
+	return action, stage, err
+}
+
+// ResolveBatch resolves the answer to a batch of instructions, which may hold
+// one action or several in the order they apply. Each action is resolved as
+// Resolve does against the board the actions before it leave behind, and may
+// refer to the elements they added by the IDs the model gave them. The
+// response is rewritten to the first action; those after it are returned as
+// follow-up responses.
+func (p *Pipeline) ResolveBatch(inst *Instruction, response *llm.LLMResponse) (*llm.WhiteboardAction, []*llm.LLMResponse, string, error) {
+	actions, err := llm.ParseWhiteboardActions(response.Response)
+	if err != nil {
+		return nil, nil, StageParse, err
+	}
+
+	var first *llm.WhiteboardAction
+	var followups []*llm.LLMResponse
+	board := inst.Board
+	ids := make(map[string]string)
+	for i, action := range actions {
+		whiteboard.RemapActionReferences(action, ids)
+		data, err := json.Marshal(action)
+		if err != nil {
+			return nil, nil, StageResolve, fmt.Errorf("failed to marshal action %d: %w", i+1, err)
+		}
+
+		step := response
+		stepInst := *inst
+		stepInst.Board = board
+		if i > 0 {
+			step = &llm.LLMResponse{
+				Timestamp:       response.Timestamp,
+				Provider:        response.Provider,
+				Model:           response.Model,
+				CompactionLevel: response.CompactionLevel,
+			}
+			// Keeps the IDs assigned to each action's elements apart.
+			stepInst.RequestID = fmt.Sprintf("%s/%d", inst.RequestID, i)
+		}
+		step.Response = string(data)
+		step.ParsedAction = action
+
+		resolved, assigned, stage, err := p.resolve(&stepInst, step)
+		if err != nil {
+			if len(actions) > 1 {
+				err = fmt.Errorf("action %d: %w", i+1, err)
+			}
+			return nil, nil, stage, err
+		}
+		for old, assignedID := range assigned {
+			ids[old] = assignedID
+		}
+		if board, err = whiteboard.ApplyAction(board, resolved); err != nil {
+			return nil, nil, StageResolve, fmt.Errorf("action %d: %w", i+1, err)
+		}
+		if i == 0 {
+			first = resolved
+		} else {
+			followups = append(followups, step)
+		}
+	}
+	return first, followups, "", nil
+}
+
+// resolve is Resolve, also returning the IDs assigned to added elements.
+func (p *Pipeline) resolve(inst *Instruction, response *llm.LLMResponse) (*llm.WhiteboardAction, map[string]string, string, error) {
+	var err error
+	action := response.ParsedAction
+	if action == nil {
+		if action, err = llm.ParseWhiteboardAction(response.Response); err != nil {
+			return nil, nil, StageParse, err
+		}
+	}
+
+	if action, err = resolveTransform(response, action, inst.Board); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	// Templates are laid out by hand, so the layout leaves them alone.
+	template := action.Action == llm.ActionTemplate
+	if action, err = expandTemplate(response, action, inst.Board); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if len(inst.Instructions) <= 1 {
+		if action, err = correctSpatialTarget(response, action, inst.Board, inst.Transcription); err != nil {
+			return nil, nil, StageResolve, err
+		}
+	}
+	if action, err = expandStickies(response, action, inst.Board); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if action, err = fitLabels(response, action, inst.Board); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if action, err = normalizeLinePoints(response, action); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if action, err = layoutAdded(response, action, inst.Board, p.AutoLayout && !template); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if action, err = fitFrames(response, action, inst.Board); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if action, err = routeArrows(response, action, inst.Board); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if action, err = normalizeColors(response, action, p.NormalizeColors); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	ids, err := assignElementIDs(response, action, inst.Board, inst.RequestID)
+	if err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if err := validateAction(response, action, inst.Board, p.LenientValidation); err != nil {
+		return nil, nil, StageValidate, err
+	}
+	if err := restoreCompacted(response, action, inst.Board, inst.Options.Referents); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if err := repairDelete(response, action, inst.Board, inst.ArrowRepair); err != nil {
+		return nil, nil, StageResolve, err
+	}
+	if reason := p.Guard.Check(action, inst.Board); reason != "" {
+		response.RequiresConfirmation = true
+		response.ConfirmationReason = reason
+	}
+	if inverse, err := whiteboard.Invert(action, inst.Board); err == nil {
+		response.Inverse = inverse
+	}
+	response.ParsedAction = action
+	return action, ids, "", nil
+}
+
+// resolveTransform rewrites transform actions (e.g. copy_style) into plain
+// updates against the board state, since clients only understand
+// add/update/delete. Other actions are returned unchanged.
+func resolveTransform(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	if action.Action != llm.ActionTransform {
+		return action, nil
+	}
+
+	resolved, err := whiteboard.ResolveTransform(action, board)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve transform: %w", err)
+	}
+	data, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resolved action: %w", err)
+	}
+	response.Response = string(data)
+	return resolved, nil
+}
+
+// expandTemplate rewrites template actions as an add of the template's
+// elements. Other actions are returned unchanged.
+func expandTemplate(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	if action.Action != llm.ActionTemplate {
+		return action, nil
+	}
+
+	expanded, err := whiteboard.ExpandTemplate(action, board)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand template: %w", err)
+	}
+	data, err := json.Marshal(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal expanded action: %w", err)
+	}
+	response.Response = string(data)
+	return expanded, nil
+}
+
+// correctSpatialTarget points an update or delete the model aimed at the
+// wrong element back at the one the instruction describes by where it is,
+// such as "the box on the left". Batches, whose instructions may each
+// describe another element, are left to the model.
+func correctSpatialTarget(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element, transcription string) (*llm.WhiteboardAction, error) {
+	corrected := whiteboard.CorrectSpatialTarget(action, board, transcription)
+	if corrected == action {
+		return action, nil
+	}
+	data, err := json.Marshal(corrected)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal corrected action: %w", err)
+	}
+	response.Response = string(data)
+	return corrected, nil
+}
+
+// expandStickies rewrites sticky notes as the labelled rectangles clients
+// draw. Actions without stickies are returned unchanged.
+func expandStickies(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	expanded, err := whiteboard.ExpandStickies(action, board)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand sticky notes: %w", err)
+	}
+	if expanded == action {
+		return action, nil
+	}
+	data, err := json.Marshal(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal expanded action: %w", err)
+	}
+	response.Response = string(data)
+	return expanded, nil
+}
+
+// fitLabels wraps labels and grows the shapes that hold them to fit.
+func fitLabels(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	fitted := whiteboard.FitLabels(action, board)
+	if fitted == action {
+		return action, nil
+	}
+	data, err := json.Marshal(fitted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fitted action: %w", err)
+	}
+	response.Response = string(data)
+	return fitted, nil
+}
+
+// normalizeLinePoints makes the points of added lines and arrows relative to
+// their position, as clients expect.
+func normalizeLinePoints(response *llm.LLMResponse, action *llm.WhiteboardAction) (*llm.WhiteboardAction, error) {
+	normalized := whiteboard.NormalizeLinePoints(action)
+	if normalized == action {
+		return action, nil
+	}
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal normalized action: %w", err)
+	}
+	response.Response = string(data)
+	return normalized, nil
+}
+
+// layoutAdded lays out the flowchart an add action draws when enabled.
+func layoutAdded(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element, enabled bool) (*llm.WhiteboardAction, error) {
+	if !enabled {
+		return action, nil
+	}
+	laid := whiteboard.LayoutAdded(action, board)
+	if laid == action {
+		return action, nil
+	}
+	data, err := json.Marshal(laid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal laid out action: %w", err)
+	}
+	response.Response = string(data)
+	return laid, nil
+}
+
+// fitFrames sizes added frames the model gave no size to around their
+// children.
+func fitFrames(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	fitted := whiteboard.FitFrames(action, board)
+	if fitted == action {
+		return action, nil
+	}
+	data, err := json.Marshal(fitted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fitted action: %w", err)
+	}
+	response.Response = string(data)
+	return fitted, nil
+}
+
+// routeArrows computes the points of elbow and curved arrows, including
+// those bound to shapes an update moves.
+func routeArrows(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	routed := whiteboard.RouteArrows(action, board)
+	if routed == action {
+		return action, nil
+	}
+	data, err := json.Marshal(routed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal routed action: %w", err)
+	}
+	response.Response = string(data)
+	return routed, nil
+}
+
+// normalizeColors snaps the colors an action sets to the palette when
+// enabled.
+func normalizeColors(response *llm.LLMResponse, action *llm.WhiteboardAction, enabled bool) (*llm.WhiteboardAction, error) {
+	if !enabled {
+		return action, nil
+	}
+	normalized := whiteboard.NormalizeColors(action)
+	if normalized == action {
+		return action, nil
+	}
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal normalized action: %w", err)
+	}
+	response.Response = string(data)
+	return normalized, nil
+}
+
+// assignElementIDs replaces the IDs the model chose for added elements, so an
+// add or replace can't collide with an element already on the board, and
+// returns the old-to-new mapping.
+func assignElementIDs(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element, requestID string) (map[string]string, error) {
+	if action.Action != llm.ActionAdd && action.Action != llm.ActionReplace {
+		return nil, nil
+	}
+	ids := whiteboard.AssignElementIDs(action, board, requestID)
+	data, err := json.Marshal(action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal action with assigned IDs: %w", err)
+	}
+	response.Response = string(data)
+	return ids, nil
+}
+
+// validateAction rejects actions with validation errors with an
+// *llm.ValidationError, and passes warnings on in the response. When lenient,
+// bad arrow bindings are dropped first and only reported as warnings.
+func validateAction(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element, lenient bool) error {
+	if action.Action == llm.ActionError {
+		return nil
+	}
+	report := llm.ValidateAction(action, board)
+	if lenient {
+		corrected := llm.DropBadBindings(action, report)
+		if len(corrected.Errors()) < len(report.Errors()) {
+			data, err := json.Marshal(action)
+			if err != nil {
+				return fmt.Errorf("failed to marshal corrected action: %w", err)
+			}
+			response.Response = string(data)
+		}
+		report = corrected
+	}
+	if len(report.Errors()) > 0 {
+		return &llm.ValidationError{Report: report}
+	}
+	if warnings := report.Warnings(); len(warnings) > 0 {
+		response.ValidationWarnings = warnings
+		for _, warning := range warnings {
+			response.Warning = strings.TrimSpace(response.Warning + " " + warning.Message + ".")
+		}
+	}
+	return nil
+}
+
+// restoreCompacted puts back the fields an update dropped or coarsened
+// because the model only saw a compacted board, so updating one property
+// doesn't reset the others.
+func restoreCompacted(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element, referents []llm.Referent) error {
+	if action.Action != llm.ActionUpdate || response.CompactionLevel == llm.CompactionFull {
+		return nil
+	}
+
+	byID := make(map[string]llm.Element, len(board))
+	for _, element := range board {
+		byID[element.ID] = element
+	}
+	referenced := make(map[string]bool, len(referents))
+	for _, r := range referents {
+		referenced[r.ElementID] = true
+	}
+	for i, update := range action.Elements {
+		original, ok := byID[update.ID]
+		if !ok {
+			continue
+		}
+		restored, err := llm.RestoreCompacted(original, update, response.CompactionLevel, referenced[update.ID])
+		if err != nil {
+			return fmt.Errorf("failed to restore compacted element %s: %w", update.ID, err)
+		}
+		action.Elements[i] = restored
+	}
+	data, err := json.Marshal(action)
+	if err != nil {
+		return fmt.Errorf("failed to marshal restored action: %w", err)
+	}
+	response.Response = string(data)
+	return nil
+}
+
+// repairDelete extends delete actions to the arrows and labels left dangling
+// by the deletion, per the board's arrow repair policy, and notes the repair
+// in the response warning.
+func repairDelete(response *llm.LLMResponse, action *llm.WhiteboardAction, board []llm.Element, policy string) error {
+	if action.Action != llm.ActionDelete {
+		return nil
+	}
+
+	repair := whiteboard.RepairDelete(action, board, policy)
+	if repair.Empty() {
+		return nil
+	}
+	data, err := json.Marshal(action)
+	if err != nil {
+		return fmt.Errorf("failed to marshal repaired action: %w", err)
+	}
+	response.Response = string(data)
+	response.Warning = strings.TrimSpace(response.Warning + " " + repair.Warning())
+	return nil
+}
@@ -0,0 +1,80 @@
+package livekit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+)
+
+// slowClient answers like addClient after delay, standing in for a model's
+// latency.
+type slowClient struct {
+	addClient
+	delay time.Duration
+}
+
+func (c *slowClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts llm.GenerateOptions) (*llm.LLMResponse, error) {
+	time.Sleep(c.delay)
+	return c.addClient.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+}
+
+func TestPipelineFastPath(t *testing.T) {
+	const board = `[
+		{"id":"api","type":"rectangle","x":100,"y":100,"width":160,"height":80,"label":{"text":"API"}},
+		{"id":"login-1","type":"rectangle","x":0,"y":400,"width":100,"height":60,"label":{"text":"Login"}},
+		{"id":"login-2","type":"rectangle","x":200,"y":400,"width":100,"height":60,"label":{"text":"Login"}}
+	]`
+	const modelLatency = 50 * time.Millisecond
+
+	tests := []struct {
+		name        string
+		fastPath    bool
+		instruction string
+		want        string
+	}{
+		{name: "move", fastPath: true, instruction: "move the API box up", want: llm.ActionUpdate},
+		{name: "delete", fastPath: true, instruction: "delete the API box", want: llm.ActionDelete},
+		{name: "outside the grammar", fastPath: true, instruction: "add a box called Billing"},
+		{name: "ambiguous target", fastPath: true, instruction: "delete the login box"},
+		{name: "disabled", fastPath: false, instruction: "move the API box up"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &slowClient{delay: modelLatency}
+			pipeline := &Pipeline{LLMClient: client, MaxAttempts: 1, FastPath: tt.fastPath}
+			inst := pipeline.Prepare("req-1", tt.instruction, board, time.Now(), "UTC", "en-US", whiteboard.ArrowRepairUnbind)
+
+			started := time.Now()
+			result := pipeline.Run(context.Background(), inst)
+			elapsed := time.Since(started)
+			if result.Err != nil {
+				t.Fatalf("Run: %v", result.Err)
+			}
+
+			fast := tt.want != ""
+			if result.Response.FastPath != fast || (result.Response.Provider == FastPathProvider) != fast {
+				t.Errorf("fastPath = %v from %q, want %v", result.Response.FastPath, result.Response.Provider, fast)
+			}
+			if wantCalls := map[bool]int{true: 0, false: 1}[fast]; client.calls != wantCalls {
+				t.Errorf("the model was called %d times, want %d", client.calls, wantCalls)
+			}
+			if !fast {
+				return
+			}
+			if result.Action.Action != tt.want || len(result.Attempts) != 0 {
+				t.Errorf("got a %s action after %d attempts, want a %s without any", result.Action.Action, len(result.Attempts), tt.want)
+			}
+			if result.Response.Inverse == nil {
+				t.Errorf("fast-path %s can't be undone", tt.want)
+			}
+			// Skipping the model is the point: the answer comes well before
+			// the model's would.
+			if elapsed >= modelLatency {
+				t.Errorf("fast path took %s, no faster than the model's %s", elapsed, modelLatency)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package livekit
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"draw/pkg/llm"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestEventWireFormat pins the data messages clients receive from the room,
+// one per line in testdata/events.golden. Clients on older app versions
+// parse these, so a change to a recorded line has to stay readable by them.
+func TestEventWireFormat(t *testing.T) {
+	events := []StreamTextData{
+		{
+			Type:      "canvas_update",
+			RequestID: "req-1",
+			Data: &llm.LLMResponse{
+				Response:        `{"action":"add","elements":[{"id":"start","type":"rectangle","x":0,"y":0,"width":100,"height":60}]}`,
+				Timestamp:       time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+				DroppedSections: []string{"referents"},
+				CompactionLevel: 1,
+				Provider:        "ollama",
+			},
+		},
+		{
+			Type:      "canvas_update",
+			RequestID: "req-2",
+			Data: &llm.LLMResponse{
+				Response:  `{"action":"clear"}`,
+				Timestamp: time.Date(2026, 10, 15, 9, 1, 0, 0, time.UTC),
+				FastPath:  true,
+			},
+			MutationID: "mutation-1",
+		},
+		{
+			Type:      "instruction_failed",
+			RequestID: "req-3",
+			Data: &InstructionFailure{
+				Attempts:   []Attempt{{Number: 1, Stage: StageParse, Error: "no JSON object"}},
+				FailedRule: StageParse,
+				RawOutput:  "sure!",
+			},
+		},
+		{Type: "preview", RequestID: "req-4", Data: llm.Element{ID: "circle", Type: "ellipse", X: 100, Width: 60, Height: 60}},
+		{Type: "preview_clear", RequestID: "req-4"},
+		{Type: "participant", Data: ParticipantEvent{Action: "role", Identity: "bob", Role: RoleListener}},
+		{Type: "presence", Data: map[string]float64{"x": 10, "y": 20}, Sender: "alice"},
+		{Type: "resync"},
+	}
+
+	var got bytes.Buffer
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("failed to encode %s: %v", event.Type, err)
+		}
+		got.Write(line)
+		got.WriteByte('\n')
+	}
+
+	path := filepath.Join("testdata", "events.golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("failed to create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s, run with -update to create it: %v", path, err)
+	}
+	gotLines, wantLines := bytes.Split(got.Bytes(), []byte("\n")), bytes.Split(want, []byte("\n"))
+	if len(gotLines) != len(wantLines) {
+		t.Fatalf("encoded %d events, recorded %d", len(gotLines)-1, len(wantLines)-1)
+	}
+	for i := range gotLines {
+		if !bytes.Equal(gotLines[i], wantLines[i]) {
+			t.Errorf("event %d changed on the wire:\n got %s\nwant %s", i+1, gotLines[i], wantLines[i])
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package livekit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"draw/internal/db/repo"
+	"draw/pkg/config"
+	"draw/pkg/llm"
+	"draw/pkg/speech"
+
+	"github.com/livekit/protocol/auth"
+)
+
+// addClient answers every instruction by adding a rectangle.
+type addClient struct {
+	calls int
+}
+
+func (c *addClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*llm.LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, llm.GenerateOptions{})
+}
+
+func (c *addClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts llm.GenerateOptions) (*llm.LLMResponse, error) {
+	c.calls++
+	return &llm.LLMResponse{
+		Response: `{"action":"add","elements":[{"id":"rect-1","type":"rectangle","x":0,"y":0,"width":100,"height":60}]}`,
+	}, nil
+}
+
+func (c *addClient) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (c *addClient) Close() error {
+	return nil
+}
+
+// drainResults returns the queued events other than previews, which every
+// instruction clears whether or not it streamed any.
+func drainResults(s *LiveKitSession) []StreamTextData {
+	var events []StreamTextData
+	for _, event := range s.outbound.drain() {
+		if event.Type != "preview" && event.Type != "preview_clear" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestServiceAccountInstruction(t *testing.T) {
+	owner := &repo.User{ID: "u1", Name: "alice"}
+	var attributed []string
+	s := newTestSession(owner, "board", SessionCallbacks{
+		GetBoardState: func(boardID string, userID string) (json.RawMessage, error) {
+			return json.RawMessage(`[]`), nil
+		},
+		OnLLMResponse: func(boardID string, userID string, instruction string, response *llm.LLMResponse, err error) {
+			attributed = append(attributed, userID)
+		},
+	})
+	defer s.cancel()
+
+	provider := &addClient{}
+	s.llmClient = llm.NewRateLimitedLLMClient(provider, llm.NewRateLimiter(map[llm.RateLimitClass]llm.RateLimit{
+		llm.RateLimitUser:           {PerMinute: 1},
+		llm.RateLimitServiceAccount: {PerMinute: 1},
+	}))
+	s.llmConfig = &config.LLMConfig{MaxAttempts: 1}
+	speechClient, err := speech.NewClient("localhost:0")
+	if err != nil {
+		t.Fatalf("speech.NewClient: %v", err)
+	}
+	defer speechClient.Close()
+	s.speechClient = speechClient
+
+	// The account joins under its own identity, so alice stays in the room.
+	identity := s.JoinServiceAccount(owner, "acct-1")
+	if identity != "sa-acct-1" {
+		t.Fatalf("identity = %q, want sa-acct-1", identity)
+	}
+	if len(s.participants) != 2 {
+		t.Fatalf("got %d participants, want alice and the account", len(s.participants))
+	}
+	s.listenTo(identity)
+
+	handler, err := s.newVoiceHandler()
+	if err != nil {
+		t.Fatalf("newVoiceHandler: %v", err)
+	}
+	defer handler.Close()
+	say := func(text string) {
+		handler.handleLLMResponse(utterance{text: text, quota: handler.speakerQuota()})
+	}
+
+	say("add a rectangle")
+	events := drainResults(s)
+	if len(events) != 1 || events[0].Type != "canvas_update" {
+		t.Fatalf("got events %+v, want one canvas_update", events)
+	}
+	if provider.calls != 1 {
+		t.Fatalf("provider got %d calls, want 1", provider.calls)
+	}
+
+	// The account's quota is spent; alice's isn't touched by it.
+	say("add another rectangle")
+	events = drainResults(s)
+	if len(events) != 1 || events[0].Type != "instruction_failed" {
+		t.Fatalf("got events %+v, want the account's second instruction to fail", events)
+	}
+	failure, ok := events[0].Data.(*InstructionFailure)
+	if !ok || !errors.Is(failure, llm.ErrRateLimited) {
+		t.Fatalf("got %+v, want the account rate limited", events[0].Data)
+	}
+
+	s.listenTo(owner.Name)
+	say("add a rectangle")
+	events = drainResults(s)
+	if len(events) != 1 || events[0].Type != "canvas_update" {
+		t.Fatalf("got events %+v, want alice's instruction applied", events)
+	}
+
+	// Whoever speaks, the instructions are attributed to the board owner.
+	for i, userID := range attributed {
+		if userID != owner.ID {
+			t.Errorf("instruction %d attributed to %q, want %q", i, userID, owner.ID)
+		}
+	}
+	if len(attributed) != 3 {
+		t.Errorf("got %d responses, want 3", len(attributed))
+	}
+}
+
+func TestGenerateUserTokenGrant(t *testing.T) {
+	s := newTestSession(&repo.User{ID: "u1", Name: "alice"}, "board", SessionCallbacks{})
+	defer s.cancel()
+	s.lkConfig = &config.LiveKitConfig{APIKey: "key", APISecret: "a-secret-long-enough-to-sign-tokens"}
+
+	tests := []struct {
+		name     string
+		identity string
+		role     string
+		admin    bool
+		publish  bool
+	}{
+		{name: "owner", identity: "alice", role: RoleSpeaker, admin: true, publish: true},
+		{name: "service account with instructions scope", identity: ServiceAccountIdentity("acct-1"), role: RoleSpeaker, publish: true},
+		{name: "read-only service account", identity: ServiceAccountIdentity("acct-2"), role: RoleListener},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := s.GenerateUserToken(tt.identity, tt.name, tt.role, tt.admin)
+			if err != nil {
+				t.Fatalf("GenerateUserToken: %v", err)
+			}
+			verifier, err := auth.ParseAPIToken(token)
+			if err != nil {
+				t.Fatalf("ParseAPIToken: %v", err)
+			}
+			claims, err := verifier.Verify(s.lkConfig.APISecret)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if claims.Identity != tt.identity {
+				t.Errorf("identity = %q, want %q", claims.Identity, tt.identity)
+			}
+			if got := claims.Video.GetCanPublish(); got != tt.publish {
+				t.Errorf("canPublish = %v, want %v", got, tt.publish)
+			}
+			if !claims.Video.GetCanSubscribe() {
+				t.Errorf("canSubscribe = false, want true")
+			}
+			if claims.Video.RoomAdmin != tt.admin {
+				t.Errorf("roomAdmin = %v, want %v", claims.Video.RoomAdmin, tt.admin)
+			}
+		})
+	}
+}
@@ -3,13 +3,17 @@ package livekit
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"draw/pkg/config"
 	"draw/pkg/llm"
+	"draw/pkg/llm/prompts"
+	"draw/pkg/slo"
 	"draw/pkg/speech"
+	"draw/pkg/whiteboard"
 
 	"draw/internal/db/repo"
 
@@ -25,17 +29,58 @@ import (
 
 type SessionCallbacks struct {
 	OnMeetingEnd  func(meetingID string, recordingURL string, transcriptURL string, err error)
-	OnLLMResponse func(boardID string, response *llm.LLMResponse, err error)
+	OnLLMResponse func(boardID string, userID string, instruction string, response *llm.LLMResponse, err error)
 	GetBoardState func(boardID string, userID string) (json.RawMessage, error)
+	// HoldForApproval is consulted before an LLM response is broadcast. If it
+	// returns a non-nil pending change, the response is not applied and the
+	// pending change is broadcast instead. An error also blocks the response.
+	HoldForApproval func(boardID string, userID string, instruction string, response *llm.LLMResponse) (pending any, err error)
+	// GetBoardLocale returns the board's time zone and locale, used to
+	// resolve dates spoken in instructions.
+	GetBoardLocale func(boardID string) (timezone string, locale string, err error)
+	// GetArrowRepair returns the board's policy for arrows bound to deleted
+	// elements.
+	GetArrowRepair func(boardID string) (string, error)
+	// OnBoardMetadata validates and stores an icon/color change made by voice.
+	OnBoardMetadata func(boardID string, userID string, intent whiteboard.BoardMetadataIntent) error
+	// OnComment stores a comment made by voice and broadcasts it.
+	OnComment func(boardID string, userID string, intent whiteboard.CommentIntent) error
+	// OnNavigate moves everyone to the saved view named by phrase. It
+	// reports false when no view matches.
+	OnNavigate func(boardID string, userID string, phrase string) (bool, error)
+	// OnInstructionTiming receives the stage timing of each instruction that
+	// reached the LLM. It is called on the instruction's goroutine and must
+	// not block.
+	OnInstructionTiming func(boardID string, userID string, timing InstructionTiming)
+	// OnInstructionState records an instruction's progress; see
+	// InstructionStateCallback.
+	OnInstructionState func(boardID string, userID string, requestID string, instruction string, state string)
+	// OnBoardReset stores a clear, replace or reorder action before it is
+	// broadcast, so the stored board never holds a mix of the old and new
+	// elements and keeps the new stacking order. An error blocks the
+	// response.
+	OnBoardReset func(boardID string, action *llm.WhiteboardAction) error
+	// OnUndo undoes the board's last instruction by voice, storing and
+	// broadcasting the result.
+	OnUndo func(boardID string, userID string) error
 }
 
+// botIdentity is the participant identity the server joins rooms with.
+const botIdentity = "bot"
+
 type StreamTextData struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Type      string      `json:"type"`
+	RequestID string      `json:"requestId,omitempty"`
+	Data      interface{} `json:"data"`
+	// Sender identifies who produced the event; latest-wins events are
+	// coalesced per sender.
+	Sender string `json:"sender,omitempty"`
+	// MutationID echoes the X-Mutation-ID of the request that caused the
+	// event, so the client that made the change can skip its own echo.
+	MutationID string `json:"mutationId,omitempty"`
 }
 
 type LiveKitSession struct {
-	userDetails     *repo.User
 	boardID         string
 	room            *lksdk.Room
 	handler         LivekitHandler
@@ -46,19 +91,50 @@ type LiveKitSession struct {
 	speechConfig    *config.SpeechConfig
 	llmConfig       *config.LLMConfig
 	awsConfig       *config.AWSConfig
+	retentionConfig *config.RetentionConfig
 	ctx             context.Context
 	cancel          context.CancelFunc
 	callbacks       SessionCallbacks
 	stopOnce        sync.Once
-	textStreamQueue chan StreamTextData
+	outbound        *outboundQueue
 	recordingURL    string
 	transcriptURL   string
+	slo             *slo.Tracker
+	recorder        InstructionRecorder
+	history         *llm.Conversations
+	partialsMu      sync.Mutex
+	partials        map[string]storedPartial
+	guard           whiteboard.DestructiveLimits
+	confirmationsMu sync.Mutex
+	confirmations   map[string]storedConfirmation
+	// participants are the users and service accounts who joined the
+	// session, by their LiveKit identity. speaker is the one whose audio the
+	// session listens to, and whom instructions are attributed to.
+	participantsMu sync.Mutex
+	participants   map[string]participant
+	speaker        participant
+}
+
+// participant is someone who joined the session: a user, or a service
+// account acting for one. Instructions from either are attributed to the
+// user; serviceAccountID only decides whose quota they count against.
+type participant struct {
+	user             *repo.User
+	serviceAccountID string
 }
 
 func NewLiveKitSession(
 	userDetails *repo.User,
 	boardID string,
 	cfg *config.AppConfig,
+	budget *llm.Budget,
+	models *llm.Models,
+	limiter *llm.RateLimiter,
+	registry *prompts.Registry,
+	hooks []llm.GenerateHook,
+	history *llm.Conversations,
+	tracker *slo.Tracker,
+	recorder InstructionRecorder,
 	callbacks SessionCallbacks,
 ) (*LiveKitSession, error) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -69,15 +145,14 @@ func NewLiveKitSession(
 		return nil, fmt.Errorf("failed to create speech client: %w", err)
 	}
 
-	llmClient, err := llm.NewLLMClient(&cfg.LLM)
+	llmClient, err := llm.NewLLMClient(&cfg.LLM, budget, models, limiter, registry, hooks...)
 	if err != nil {
 		speechClient.Close()
 		cancel()
 		return nil, fmt.Errorf("failed to create LLM client: %w", err)
 	}
 
-	return &LiveKitSession{
-		userDetails:     userDetails,
+	session := &LiveKitSession{
 		boardID:         boardID,
 		lkConfig:        &cfg.LiveKit,
 		speechConfig:    &cfg.Speech,
@@ -85,12 +160,91 @@ func NewLiveKitSession(
 		speechClient:    speechClient,
 		llmClient:       llmClient,
 		awsConfig:       &cfg.AWS,
+		retentionConfig: &cfg.Retention,
 		ctx:             ctx,
 		cancel:          cancel,
 		callbacks:       callbacks,
+		slo:             tracker,
+		recorder:        recorder,
+		history:         history,
 		stopOnce:        sync.Once{},
-		textStreamQueue: make(chan StreamTextData, 100),
-	}, nil
+		outbound:        newOutboundQueue(outboundQueueSize),
+		partials:        make(map[string]storedPartial),
+		guard: whiteboard.DestructiveLimits{
+			MaxDeletes:     cfg.Board.ConfirmDeleteCount,
+			MaxDeleteRatio: cfg.Board.ConfirmDeleteRatio,
+			ConfirmRewrite: cfg.Board.ConfirmRewrite,
+		},
+		confirmations: make(map[string]storedConfirmation),
+		participants:  make(map[string]participant),
+	}
+	session.join(userDetails)
+	return session, nil
+}
+
+// ServiceAccountIdentity is the LiveKit identity of a service account. Users
+// join under their name, so the prefix keeps an account named like a user
+// from taking the user's place in the room.
+func ServiceAccountIdentity(accountID string) string {
+	return "sa-" + accountID
+}
+
+// join records user as a participant. The first to join is the speaker
+// until a participant's audio is subscribed.
+func (s *LiveKitSession) join(user *repo.User) {
+	s.add(user.Name, participant{user: user})
+}
+
+// JoinServiceAccount records a service account acting for owner as a
+// participant, and returns the identity it joins the room under. What the
+// account says is attributed to owner, but counts against the account's own
+// quota.
+func (s *LiveKitSession) JoinServiceAccount(owner *repo.User, accountID string) string {
+	identity := ServiceAccountIdentity(accountID)
+	s.add(identity, participant{user: owner, serviceAccountID: accountID})
+	return identity
+}
+
+func (s *LiveKitSession) add(identity string, p participant) {
+	s.participantsMu.Lock()
+	defer s.participantsMu.Unlock()
+	s.participants[identity] = p
+	if s.speaker.user == nil {
+		s.speaker = p
+	}
+}
+
+// user returns the user instructions are attributed to.
+func (s *LiveKitSession) user() *repo.User {
+	s.participantsMu.Lock()
+	defer s.participantsMu.Unlock()
+	return s.speaker.user
+}
+
+// quota returns the quota the speaker's instructions count against.
+func (s *LiveKitSession) quota() Quota {
+	s.participantsMu.Lock()
+	defer s.participantsMu.Unlock()
+	if s.speaker.serviceAccountID != "" {
+		return ServiceAccountQuota(s.speaker.serviceAccountID)
+	}
+	return Quota{Class: llm.RateLimitUser, Key: s.speaker.user.ID}
+}
+
+// ServiceAccountQuota is the quota of a service account, kept apart from
+// that of the user it acts for.
+func ServiceAccountQuota(accountID string) Quota {
+	return Quota{Class: llm.RateLimitServiceAccount, Key: accountID}
+}
+
+// listenTo makes the participant with the given identity the speaker, if they
+// joined through the session.
+func (s *LiveKitSession) listenTo(identity string) {
+	s.participantsMu.Lock()
+	defer s.participantsMu.Unlock()
+	if p, ok := s.participants[identity]; ok {
+		s.speaker = p
+	}
 }
 
 func (s *LiveKitSession) Start() error {
@@ -104,14 +258,14 @@ func (s *LiveKitSession) Stop() error {
 	var stopErr error
 	s.stopOnce.Do(func() {
 		s.cancel()
+		if dropped := s.DroppedEvents(); len(dropped) > 0 {
+			logger.Infow("Board events dropped for slow clients", "boardID", s.boardID, "dropped", dropped)
+		}
 		if s.egressInfo != nil {
 			if err := s.stopRecording(s.egressInfo.EgressId); err != nil {
 				stopErr = fmt.Errorf("failed to stop recording: %w", err)
 			}
 		}
-		if s.textStreamQueue != nil {
-			close(s.textStreamQueue)
-		}
 		if s.room != nil {
 			s.room.Disconnect()
 		}
@@ -128,14 +282,28 @@ func (s *LiveKitSession) Stop() error {
 	return stopErr
 }
 
-func (s *LiveKitSession) GenerateUserToken() (string, error) {
+// Done is closed once the session has been stopped.
+func (s *LiveKitSession) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// GenerateUserToken returns a token to join the board's room under identity,
+// shown as name, with moderation rights if admin is set. A RoleListener can
+// subscribe and send data but not publish audio, so it can't speak
+// instructions to the board.
+func (s *LiveKitSession) GenerateUserToken(identity string, name string, role string, admin bool) (string, error) {
 	at := auth.NewAccessToken(s.lkConfig.APIKey, s.lkConfig.APISecret)
 	grant := &auth.VideoGrant{
-		RoomJoin: true,
-		Room:     s.boardID,
+		RoomJoin:  true,
+		Room:      s.boardID,
+		RoomAdmin: admin,
 	}
+	grant.SetCanPublish(role != RoleListener)
+	grant.SetCanPublishData(true)
+	grant.SetCanSubscribe(true)
 	at.SetVideoGrant(grant).
-		SetIdentity(s.userDetails.Name).
+		SetIdentity(identity).
+		SetName(name).
 		SetValidFor(time.Hour)
 	token, err := at.ToJWT()
 	if err != nil {
@@ -144,6 +312,18 @@ func (s *LiveKitSession) GenerateUserToken() (string, error) {
 	return token, nil
 }
 
+// ResumeInstruction re-runs an instruction interrupted by a server restart,
+// broadcasting its outcome under the original request ID. It reports false if
+// the session has no voice pipeline to run it on.
+func (s *LiveKitSession) ResumeInstruction(requestID string, instruction string) bool {
+	handler, ok := s.handler.(*VoiceHandler)
+	if !ok || handler.pipeline == nil {
+		return false
+	}
+	handler.ResumeInstruction(requestID, instruction)
+	return true
+}
+
 func (s *LiveKitSession) HandleMute() error {
 	if s.handler == nil {
 		return nil
@@ -161,44 +341,7 @@ func (s *LiveKitSession) HandleUnmute() error {
 func (s *LiveKitSession) connectBot() error {
 	audioWriterChan := make(chan media.PCM16Sample, 500)
 
-	sessionID := fmt.Sprintf("%s:%s", s.boardID, s.userDetails.ID)
-
-	handler, err := NewVoiceHandler(VoiceHandlerConfig{
-		SessionID:    sessionID,
-		BoardID:      s.boardID,
-		UserID:       s.userDetails.ID,
-		SpeechClient: s.speechClient,
-		LLMClient:    s.llmClient,
-		OnLLMResponse: func(response *llm.LLMResponse, err error) {
-			if err != nil {
-				logger.Errorw("LLM error", err)
-				if s.callbacks.OnLLMResponse != nil {
-					s.callbacks.OnLLMResponse(s.boardID, nil, err)
-				}
-				return
-			}
-
-			jsonData, err := json.MarshalIndent(response, "", "  ")
-			if err != nil {
-				logger.Errorw("Failed to marshal LLM response", err)
-				return
-			}
-
-			fmt.Println("LLM response", string(jsonData))
-
-			s.textStreamQueue <- StreamTextData{
-				Type: "canvas_update",
-				Data: response,
-			}
-		},
-		GetBoardState: func() (string, error) {
-			boardState, err := s.callbacks.GetBoardState(s.boardID, s.userDetails.ID)
-			if err != nil {
-				return "", err
-			}
-			return string(boardState), nil
-		},
-	})
+	handler, err := s.newVoiceHandler()
 	if err != nil {
 		close(audioWriterChan)
 		return fmt.Errorf("failed to create voice handler: %w", err)
@@ -223,12 +366,141 @@ func (s *LiveKitSession) connectBot() error {
 	return nil
 }
 
+// newVoiceHandler creates the handler that turns what the speaker says into
+// board changes for the session.
+func (s *LiveKitSession) newVoiceHandler() (*VoiceHandler, error) {
+	sessionID := fmt.Sprintf("%s:%s", s.boardID, s.user().ID)
+
+	return NewVoiceHandler(VoiceHandlerConfig{
+		SessionID:          sessionID,
+		BoardID:            s.boardID,
+		Quota:              s.quota,
+		SpeechClient:       s.speechClient,
+		LLMClient:          s.llmClient,
+		MaxAttempts:        s.llmConfig.MaxAttempts,
+		PromptTokenBudget:  s.llmConfig.PromptTokenBudget,
+		BoardStateMaxBytes: s.llmConfig.BoardStateMaxBytes,
+		FastPath:           s.llmConfig.FastPath,
+		LenientValidation:  !s.llmConfig.StrictValidation,
+		NormalizeColors:    s.llmConfig.NormalizeColors,
+		AutoLayout:         s.llmConfig.AutoLayout,
+		BatchInstructions:  s.llmConfig.BatchInstructions,
+		Guard:              s.guard,
+		SLO:                s.slo,
+		Recorder:           s.recorder,
+		Conversations:      s.history,
+		OnLLMResponse: func(requestID string, transcription string, response *llm.LLMResponse, err error) {
+			if s.callbacks.OnLLMResponse != nil {
+				s.callbacks.OnLLMResponse(s.boardID, s.user().ID, transcription, response, err)
+			}
+			if err != nil {
+				logger.Errorw("LLM error", err)
+				var failure *InstructionFailure
+				if errors.As(err, &failure) {
+					s.storePartial(failure)
+					s.publish(StreamTextData{
+						Type:      "instruction_failed",
+						RequestID: requestID,
+						Data:      failure,
+					})
+				}
+				return
+			}
+
+			s.applyResponse(requestID, transcription, response)
+		},
+		OnConfirmationRequired: s.holdForConfirmation,
+		OnTiming: func(timing InstructionTiming) {
+			if s.callbacks.OnInstructionTiming != nil {
+				s.callbacks.OnInstructionTiming(s.boardID, s.user().ID, timing)
+			}
+		},
+		OnInstructionState: func(requestID string, transcription string, state string) {
+			if s.callbacks.OnInstructionState != nil {
+				s.callbacks.OnInstructionState(s.boardID, s.user().ID, requestID, transcription, state)
+			}
+		},
+		OnPreview: func(requestID string, element llm.Element) {
+			// Previews are best effort and never persisted; drop them rather
+			// than hold up the LLM stream if the queue is backed up.
+			s.publish(StreamTextData{
+				Type:      "preview",
+				RequestID: requestID,
+				Data:      element,
+			})
+		},
+		OnPreviewClear: func(requestID string) {
+			s.publish(StreamTextData{
+				Type:      "preview_clear",
+				RequestID: requestID,
+			})
+		},
+		OnBoardMetadata: func(requestID string, intent whiteboard.BoardMetadataIntent) {
+			if s.callbacks.OnBoardMetadata == nil {
+				return
+			}
+			if err := s.callbacks.OnBoardMetadata(s.boardID, s.user().ID, intent); err != nil {
+				logger.Errorw("Failed to update board metadata", err, "boardID", s.boardID)
+				return
+			}
+			s.publish(StreamTextData{
+				Type:      "board_metadata",
+				RequestID: requestID,
+				Data:      intent,
+			})
+		},
+		OnComment: func(requestID string, intent whiteboard.CommentIntent) {
+			if s.callbacks.OnComment == nil {
+				return
+			}
+			if err := s.callbacks.OnComment(s.boardID, s.user().ID, intent); err != nil {
+				logger.Errorw("Failed to add comment", err, "boardID", s.boardID)
+			}
+		},
+		OnNavigate: func(requestID string, phrase string) bool {
+			if s.callbacks.OnNavigate == nil {
+				return false
+			}
+			handled, err := s.callbacks.OnNavigate(s.boardID, s.user().ID, phrase)
+			if err != nil {
+				logger.Errorw("Failed to navigate to view", err, "boardID", s.boardID)
+			}
+			return handled
+		},
+		OnUndo: func(requestID string) error {
+			if s.callbacks.OnUndo == nil {
+				return nil
+			}
+			return s.callbacks.OnUndo(s.boardID, s.user().ID)
+		},
+		GetBoardState: func() (string, error) {
+			boardState, err := s.callbacks.GetBoardState(s.boardID, s.user().ID)
+			if err != nil {
+				return "", err
+			}
+			return string(boardState), nil
+		},
+		GetBoardLocale: func() (string, string, error) {
+			if s.callbacks.GetBoardLocale == nil {
+				return "", "", nil
+			}
+			return s.callbacks.GetBoardLocale(s.boardID)
+		},
+		GetArrowRepair: func() (string, error) {
+			if s.callbacks.GetArrowRepair == nil {
+				return "", nil
+			}
+			return s.callbacks.GetArrowRepair(s.boardID)
+		},
+	})
+}
+
 func (s *LiveKitSession) connectToRoom() error {
 	room, err := lksdk.ConnectToRoom(s.lkConfig.Host, lksdk.ConnectInfo{
 		APIKey:              s.lkConfig.APIKey,
 		APISecret:           s.lkConfig.APISecret,
 		RoomName:            s.boardID,
-		ParticipantIdentity: "bot",
+		ParticipantIdentity: botIdentity,
 	}, s.callbacksForRoom())
 	if err != nil {
 		return err
@@ -249,6 +521,7 @@ func (s *LiveKitSession) callbacksForRoom() *lksdk.RoomCallback {
 				if pcmRemoteTrack != nil {
 					return
 				}
+				s.listenTo(rp.Identity())
 				pcmRemoteTrack, _ = s.handleSubscribe(track)
 			},
 			OnTrackMuted: func(pub lksdk.TrackPublication, p lksdk.Participant) {
@@ -273,7 +546,9 @@ func (s *LiveKitSession) callbacksForRoom() *lksdk.RoomCallback {
 			},
 		},
 		OnParticipantDisconnected: func(participant *lksdk.RemoteParticipant) {
-			s.Stop()
+			// The session outlives individual participants; empty rooms are
+			// cleaned up by the SessionManager once they have been idle long enough.
+			logger.Infow("Participant disconnected", "participant", participant.Identity(), "boardID", s.boardID)
 		},
 		OnDisconnected: func() {
 			if pcmRemoteTrack != nil {
@@ -322,20 +597,93 @@ func (s *LiveKitSession) handlePublish(audioWriterChan chan media.PCM16Sample) {
 	}
 }
 
+// applyResponse broadcasts an instruction's response, unless the board holds
+// it for approval, in which case the pending change is broadcast instead.
+func (s *LiveKitSession) applyResponse(requestID string, transcription string, response *llm.LLMResponse) {
+	jsonData, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		logger.Errorw("Failed to marshal LLM response", err)
+		return
+	}
+
+	fmt.Println("LLM response", string(jsonData))
+
+	if s.callbacks.HoldForApproval != nil {
+		pending, err := s.callbacks.HoldForApproval(s.boardID, s.user().ID, transcription, response)
+		if err != nil {
+			logger.Errorw("Failed to hold change for approval", err, "boardID", s.boardID)
+			return
+		}
+		if pending != nil {
+			s.publish(StreamTextData{
+				Type:      "pending_change",
+				RequestID: requestID,
+				Data:      pending,
+			})
+			return
+		}
+	}
+
+	if s.callbacks.OnBoardReset != nil {
+		if err := s.resetBoard(response); err != nil {
+			logger.Errorw("Failed to store board reset", err, "boardID", s.boardID)
+			return
+		}
+	}
+
+	s.publish(StreamTextData{
+		Type:      "canvas_update",
+		RequestID: requestID,
+		Data:      response,
+	})
+}
+
+// resetBoard stores the clear, replace and reorder actions in response.
+func (s *LiveKitSession) resetBoard(response *llm.LLMResponse) error {
+	actions, err := llm.ParseWhiteboardActions(response.Response)
+	if err != nil {
+		// Nothing to store; the client reports the unparseable response.
+		return nil
+	}
+	for _, action := range actions {
+		if action.Action != llm.ActionClear && action.Action != llm.ActionReplace && action.Action != llm.ActionReorder {
+			continue
+		}
+		if err := s.callbacks.OnBoardReset(s.boardID, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish queues data for the board's clients. It never blocks; the event
+// type's delivery policy decides what happens when clients fall behind.
+func (s *LiveKitSession) publish(data StreamTextData) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.outbound.push(data)
+}
+
+// DroppedEvents returns, per event type, how many events were coalesced or
+// dropped because clients fell behind.
+func (s *LiveKitSession) DroppedEvents() map[string]uint64 {
+	return s.outbound.droppedCounts()
+}
+
 func (s *LiveKitSession) handleTextStreamQueue() {
 	for {
 		select {
-		case data, ok := <-s.textStreamQueue:
-			if !ok {
-				return
-			}
-			marshalData, err := json.Marshal(data)
-			if err != nil {
-				continue
+		case <-s.outbound.notify:
+			for _, data := range s.outbound.drain() {
+				marshalData, err := json.Marshal(data)
+				if err != nil {
+					continue
+				}
+				s.room.LocalParticipant.SendText(string(marshalData), lksdk.StreamTextOptions{
+					Topic: "board",
+				})
 			}
-			s.room.LocalParticipant.SendText(string(marshalData), lksdk.StreamTextOptions{
-				Topic: "board",
-			})
 		case <-s.ctx.Done():
 			return
 		}
@@ -362,12 +710,17 @@ func (s *LiveKitSession) handleSubscribe(track *webrtc.TrackRemote) (*lkmedia.PC
 }
 
 func (s *LiveKitSession) startRecording() (*livekit.EgressInfo, error) {
+	if !s.retentionConfig.AllowAudioRetention {
+		return nil, fmt.Errorf("audio retention is disabled")
+	}
+
 	req := &livekit.RoomCompositeEgressRequest{
 		RoomName:  "",
 		Layout:    "grid",
 		AudioOnly: false,
 	}
-	outputPath := fmt.Sprintf("%s/%s/recording.mp4", s.userDetails.ID, s.userDetails.Name)
+	user := s.user()
+	outputPath := fmt.Sprintf("%s/%s/recording.mp4", user.ID, user.Name)
 	req.FileOutputs = []*livekit.EncodedFileOutput{
 		{
 			Filepath: outputPath,
@@ -0,0 +1,33 @@
+package livekit
+
+import "time"
+
+// InstructionTiming breaks down where one instruction's time went. It is
+// built from the same stage timers the SLO tracker is fed with. FirstByte is
+// only measured when the LLM response is streamed.
+type InstructionTiming struct {
+	RequestID string
+	Provider  string
+	Model     string
+	Failed    bool
+
+	Queue     time.Duration
+	FirstByte time.Duration
+	LLM       time.Duration
+	Validate  time.Duration
+	Broadcast time.Duration
+	Total     time.Duration
+}
+
+// TimingCallback receives the timing of every instruction that reached the
+// LLM, after its outcome has been broadcast.
+type TimingCallback func(timing InstructionTiming)
+
+// instructionTiming adds up where an instruction's time went across
+// attempts.
+type instructionTiming struct {
+	queue     time.Duration
+	firstByte time.Duration
+	llm       time.Duration
+	apply     time.Duration
+}
@@ -0,0 +1,131 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	// uploadBytesPerSecond is 16 kHz mono PCM16, the format the speech
+	// service expects.
+	uploadBytesPerSecond = 16000 * 2
+	// uploadChunkSize is 100ms of audio.
+	uploadChunkSize = uploadBytesPerSecond / 10
+)
+
+var (
+	// ErrUtteranceTooLong is returned when streamed audio exceeds the
+	// maximum utterance duration.
+	ErrUtteranceTooLong = errors.New("utterance exceeds the maximum duration")
+	// ErrNoVoiceSession is returned when the board has no running session
+	// that can take audio.
+	ErrNoVoiceSession = errors.New("board has no running voice session")
+)
+
+// AudioUpload summarizes a streamed upload.
+type AudioUpload struct {
+	DurationMs     int64    `json:"durationMs"`
+	Transcriptions []string `json:"transcriptions"`
+}
+
+// StreamAudio feeds raw 16 kHz mono PCM16 audio from r to the speech service
+// as it arrives, so transcription runs while the client is still sending.
+// Each transcription triggers an instruction exactly as spoken audio does,
+// counting against quota. Sends block while the speech service is behind,
+// which in turn stops reading from r. The stream is aborted when ctx is
+// cancelled or the audio passes maxDuration.
+func (h *VoiceHandler) StreamAudio(ctx context.Context, r io.Reader, maxDuration time.Duration, quota Quota) (*AudioUpload, error) {
+	var (
+		mu             sync.Mutex
+		transcriptions []string
+	)
+	callback := func(transcription string, err error) {
+		if err == nil && transcription != "" {
+			mu.Lock()
+			transcriptions = append(transcriptions, transcription)
+			mu.Unlock()
+		}
+		h.transcribed(transcription, err, quota)
+	}
+
+	// Uploads get their own speech session so they don't interfere with
+	// audio arriving over the room.
+	sessionID := h.sessionID + "-upload-" + uuid.New().String()
+	session, err := h.speechClient.NewTranscribeSession(ctx, sessionID, callback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcription session: %w", err)
+	}
+	defer func() {
+		if err := h.speechClient.CleanupSession(context.Background(), sessionID); err != nil {
+			logger.Warnw("Failed to cleanup speech session", err, "sessionID", sessionID)
+		}
+	}()
+
+	maxBytes := int64(maxDuration.Seconds() * uploadBytesPerSecond)
+	var total int64
+	buf := make([]byte, uploadChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		// Keep whole samples only.
+		n -= n % 2
+		if n > 0 {
+			total += int64(n)
+			if maxBytes > 0 && total > maxBytes {
+				_ = session.Close()
+				return nil, ErrUtteranceTooLong
+			}
+			if err := session.SendAudio(buf[:n]); err != nil {
+				_ = session.Close()
+				if ctx.Err() != nil {
+					// The stream broke because the client went away.
+					return nil, ctx.Err()
+				}
+				return nil, fmt.Errorf("failed to send audio chunk: %w", err)
+			}
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			_ = session.Close()
+			return nil, fmt.Errorf("failed to read audio: %w", readErr)
+		}
+		if ctx.Err() != nil {
+			_ = session.Close()
+			return nil, ctx.Err()
+		}
+	}
+
+	if err := session.Finalize(); err != nil {
+		return nil, fmt.Errorf("failed to finalize transcription session: %w", err)
+	}
+
+	duration := time.Duration(total) * time.Second / uploadBytesPerSecond
+	mu.Lock()
+	defer mu.Unlock()
+	return &AudioUpload{
+		DurationMs:     duration.Milliseconds(),
+		Transcriptions: transcriptions,
+	}, nil
+}
+
+// StreamAudio streams uploaded audio into the session's voice handler. Its
+// instructions count against quota, or the speaker's quota when it is zero.
+func (s *LiveKitSession) StreamAudio(ctx context.Context, r io.Reader, maxDuration time.Duration, quota Quota) (*AudioUpload, error) {
+	handler, ok := s.handler.(*VoiceHandler)
+	if !ok || handler == nil {
+		return nil, ErrNoVoiceSession
+	}
+	if quota == (Quota{}) {
+		quota = s.quota()
+	}
+	return handler.StreamAudio(ctx, r, maxDuration, quota)
+}
@@ -0,0 +1,236 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"draw/pkg/speech"
+	pb "draw/pkg/speech/pb"
+
+	"google.golang.org/grpc"
+)
+
+// fakeSpeech is a speech backend that answers every transcribeEvery bytes of
+// audio with the audio time it has heard so far, and once more at the end of
+// the stream.
+type fakeSpeech struct {
+	pb.UnimplementedSpeechServiceServer
+	transcribeEvery int
+
+	mu       sync.Mutex
+	received int
+	aborted  bool
+	cleaned  []string
+}
+
+func (f *fakeSpeech) StreamTranscribe(stream grpc.BidiStreamingServer[pb.TranscribeRequest, pb.TranscribeResponse]) error {
+	heard := 0
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			f.mu.Lock()
+			f.aborted = true
+			f.mu.Unlock()
+			return err
+		}
+		before := heard
+		heard += len(req.AudioChunk)
+		f.mu.Lock()
+		f.received = heard
+		f.mu.Unlock()
+
+		if heard/f.transcribeEvery > before/f.transcribeEvery || req.EndOfStream {
+			ms := heard * 1000 / uploadBytesPerSecond
+			if err := stream.Send(&pb.TranscribeResponse{Transcription: fmt.Sprintf("heard %dms", ms), Success: true}); err != nil {
+				return err
+			}
+		}
+		if req.EndOfStream {
+			return nil
+		}
+	}
+}
+
+func (f *fakeSpeech) CleanupSession(ctx context.Context, req *pb.CleanupRequest) (*pb.CleanupResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cleaned = append(f.cleaned, req.SessionId)
+	return &pb.CleanupResponse{Success: true}, nil
+}
+
+func (f *fakeSpeech) state() (received int, aborted bool, cleaned int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.received, f.aborted, len(f.cleaned)
+}
+
+// newUploadHandler returns a voice handler backed by a fake speech service
+// and a channel of the transcriptions it reports.
+func newUploadHandler(t *testing.T) (*VoiceHandler, *fakeSpeech, <-chan string) {
+	t.Helper()
+	backend := &fakeSpeech{transcribeEvery: uploadBytesPerSecond / 2}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterSpeechServiceServer(server, backend)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	client, err := speech.NewClient(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	transcribed := make(chan string, 100)
+	handler := &VoiceHandler{
+		sessionID:    "session-1",
+		speechClient: client,
+		onTranscribe: func(sessionID string, transcription string, err error) {
+			if err == nil {
+				transcribed <- transcription
+			}
+		},
+	}
+	return handler, backend, transcribed
+}
+
+// speak writes d of silence to w in 100ms chunks, pausing between them as a
+// client streaming live audio would.
+func speak(w io.Writer, d time.Duration, pause time.Duration) error {
+	chunk := make([]byte, uploadChunkSize)
+	for sent := time.Duration(0); sent < d; sent += 100 * time.Millisecond {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		time.Sleep(pause)
+	}
+	return nil
+}
+
+func TestStreamAudioTranscribesWhileSending(t *testing.T) {
+	handler, backend, transcribed := newUploadHandler(t)
+	r, w := io.Pipe()
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		speak(w, time.Second, 20*time.Millisecond)
+		w.Close()
+	}()
+
+	type result struct {
+		upload *AudioUpload
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		upload, err := handler.StreamAudio(context.Background(), r, 30*time.Second, Quota{})
+		done <- result{upload, err}
+	}()
+
+	// The first transcription lands while the client is still sending.
+	select {
+	case first := <-transcribed:
+		select {
+		case <-sent:
+			t.Errorf("first transcription %q arrived after the upload finished", first)
+		default:
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no transcription while streaming")
+	}
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("StreamAudio: %v", res.err)
+	}
+	if res.upload.DurationMs != 1000 {
+		t.Errorf("duration = %dms, want 1000ms", res.upload.DurationMs)
+	}
+	want := []string{"heard 500ms", "heard 1000ms", "heard 1000ms"}
+	if fmt.Sprint(res.upload.Transcriptions) != fmt.Sprint(want) {
+		t.Errorf("transcriptions = %q, want %q", res.upload.Transcriptions, want)
+	}
+	if received, aborted, cleaned := backend.state(); received != uploadBytesPerSecond || aborted || cleaned != 1 {
+		t.Errorf("backend received %d bytes (aborted %v, cleaned up %d), want %d, finished and cleaned up once", received, aborted, cleaned, uploadBytesPerSecond)
+	}
+}
+
+func TestStreamAudioAborts(t *testing.T) {
+	errDisconnected := errors.New("client disconnected")
+	tests := []struct {
+		name string
+		// stream writes audio to w, cancelling the request when it wants to.
+		stream      func(w *io.PipeWriter, cancel context.CancelFunc)
+		maxDuration time.Duration
+		// want lists the errors the upload may fail with.
+		want []error
+	}{
+		{
+			name: "utterance too long",
+			stream: func(w *io.PipeWriter, cancel context.CancelFunc) {
+				speak(w, 2*time.Second, 0)
+			},
+			maxDuration: 500 * time.Millisecond,
+			want:        []error{ErrUtteranceTooLong},
+		},
+		{
+			name: "client disconnects",
+			stream: func(w *io.PipeWriter, cancel context.CancelFunc) {
+				speak(w, 300*time.Millisecond, 0)
+				// A dropped connection cancels the request and fails the
+				// body read.
+				cancel()
+				w.CloseWithError(errDisconnected)
+			},
+			maxDuration: 30 * time.Second,
+			want:        []error{errDisconnected, context.Canceled},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, backend, _ := newUploadHandler(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r, w := io.Pipe()
+			go tt.stream(w, cancel)
+
+			_, err := handler.StreamAudio(ctx, r, tt.maxDuration, Quota{})
+			r.CloseWithError(io.ErrClosedPipe)
+			matched := false
+			for _, want := range tt.want {
+				matched = matched || errors.Is(err, want)
+			}
+			if !matched {
+				t.Fatalf("got %v, want one of %v", err, tt.want)
+			}
+
+			maxBytes := int(tt.maxDuration.Seconds() * uploadBytesPerSecond)
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				received, _, cleaned := backend.state()
+				if received > maxBytes {
+					t.Fatalf("backend received %d bytes, want at most %d", received, maxBytes)
+				}
+				if cleaned == 1 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("speech session not cleaned up")
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
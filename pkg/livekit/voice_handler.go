@@ -2,26 +2,115 @@ package livekit
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"draw/pkg/llm"
+	"draw/pkg/slo"
 	"draw/pkg/speech"
+	"draw/pkg/whiteboard"
 
+	"github.com/google/uuid"
 	"github.com/livekit/media-sdk"
 	"github.com/livekit/protocol/logger"
 )
 
-type LLMResponseCallback func(response *llm.LLMResponse, err error)
+type LLMResponseCallback func(requestID string, transcription string, response *llm.LLMResponse, err error)
+
+// PreviewCallback receives provisional elements while the LLM is still streaming.
+type PreviewCallback func(requestID string, element llm.Element)
+
+// PreviewClearCallback is called once streaming for a request has finished,
+// whether or not it produced a usable action.
+type PreviewClearCallback func(requestID string)
+
+// ConfirmationCallback receives the responses of an instruction held because
+// one of them deletes or rewrites too much of the board, in order, with the
+// hash of the board they were resolved against. They are applied only once
+// the user confirms them.
+type ConfirmationCallback func(requestID string, transcription string, responses []*llm.LLMResponse, boardHash string)
+
+// BoardMetadataCallback handles instructions that only change the board's
+// icon or color; these never reach the LLM.
+type BoardMetadataCallback func(requestID string, intent whiteboard.BoardMetadataIntent)
+
+// CommentCallback handles instructions that leave a comment, with the target
+// element already resolved; these never reach the LLM.
+type CommentCallback func(requestID string, intent whiteboard.CommentIntent)
+
+// UndoCallback undoes the board's last instruction, for "undo that"; these
+// never reach the LLM. An error is reported like a failed instruction.
+type UndoCallback func(requestID string) error
+
+// Durable states of an instruction that reached the LLM.
+const (
+	InstructionPending = "pending"
+	InstructionApplied = "applied"
+	InstructionFailed  = "failed"
+)
+
+// InstructionStateCallback is told when an instruction is about to be sent
+// to the LLM (pending) and once its outcome has been broadcast (applied or
+// failed), so an instruction cut off by a restart can be found and resumed.
+type InstructionStateCallback func(requestID string, transcription string, state string)
+
+// NavigateCallback moves clients to the saved view named by phrase. It
+// reports whether a view matched; unmatched instructions go to the LLM.
+type NavigateCallback func(requestID string, phrase string) bool
 
 type GetBoardStateFunc func() (string, error)
 
+// InstructionRecorder captures what each instruction went through so the
+// session can be replayed later.
+type InstructionRecorder interface {
+	RecordInstruction(sessionID string, boardID string, trace InstructionTrace)
+}
+
+// InstructionRecorders hands each instruction to every recorder in turn.
+type InstructionRecorders []InstructionRecorder
+
+func (r InstructionRecorders) RecordInstruction(sessionID string, boardID string, trace InstructionTrace) {
+	for _, recorder := range r {
+		recorder.RecordInstruction(sessionID, boardID, trace)
+	}
+}
+
+// InstructionTrace is everything needed to replay one instruction: the
+// pipeline's inputs and what it produced.
+type InstructionTrace struct {
+	RequestID string
+	// AudioRef locates the utterance in the session's audio, when audio is
+	// retained.
+	AudioRef      string
+	At            time.Time
+	Transcription string
+	BoardState    string
+	Timezone      string
+	Locale        string
+	ArrowRepair   string
+	// Instructions are the utterances sent together as one batch, when there
+	// were several; Transcription holds them joined.
+	Instructions []string
+	Result       *PipelineResult
+}
+
+// GetBoardLocaleFunc returns the board's IANA time zone and locale; either
+// may be empty when unset.
+type GetBoardLocaleFunc func() (timezone string, locale string, err error)
+
+// GetArrowRepairFunc returns the board's policy for arrows bound to deleted
+// elements.
+type GetArrowRepairFunc func() (string, error)
+
 type VoiceHandler struct {
 	sessionID             string
 	boardID               string
-	userID                string
+	quota                 func() Quota
 	speechClient          *speech.Client
-	llmClient             llm.LLMClient
+	pipeline              *Pipeline
 	session               *speech.TranscribeSession
 	ctx                   context.Context
 	cancel                context.CancelFunc
@@ -29,19 +118,86 @@ type VoiceHandler struct {
 	isMuted               bool
 	onTranscribe          TranscriptionCallback
 	onLLMResponse         LLMResponseCallback
+	onConfirmation        ConfirmationCallback
+	onBoardMetadata       BoardMetadataCallback
+	onComment             CommentCallback
+	onNavigate            NavigateCallback
+	onUndo                UndoCallback
+	onTiming              TimingCallback
+	onInstructionState    InstructionStateCallback
 	getBoardState         GetBoardStateFunc
+	getBoardLocale        GetBoardLocaleFunc
+	getArrowRepair        GetArrowRepairFunc
+	slo                   *slo.Tracker
+	recorder              InstructionRecorder
 	transcriptionCallback speech.TranscriptionCallback
+	conversations         *llm.Conversations
+	batchInstructions     bool
+
+	// buffered holds the utterances spoken while a batch was being handled;
+	// flushing is set while a goroutine is sending them.
+	bufferMu sync.Mutex
+	buffered []utterance
+	flushing bool
 }
 
 type VoiceHandlerConfig struct {
-	SessionID     string
-	BoardID       string
-	UserID        string
+	SessionID string
+	BoardID   string
+	// Quota returns the quota of whoever is speaking, which their
+	// instructions count against.
+	Quota         func() Quota
 	SpeechClient  *speech.Client
 	LLMClient     llm.LLMClient
 	OnTranscribe  TranscriptionCallback
 	OnLLMResponse LLMResponseCallback
-	GetBoardState GetBoardStateFunc
+	// OnConfirmationRequired receives the responses Guard flags instead of
+	// OnLLMResponse. When nil, flagged responses are passed to OnLLMResponse
+	// like any other.
+	OnConfirmationRequired ConfirmationCallback
+	OnPreview              PreviewCallback
+	OnPreviewClear         PreviewClearCallback
+	OnBoardMetadata        BoardMetadataCallback
+	OnComment              CommentCallback
+	OnNavigate             NavigateCallback
+	OnUndo                 UndoCallback
+	OnTiming               TimingCallback
+	OnInstructionState     InstructionStateCallback
+	GetBoardState          GetBoardStateFunc
+	GetBoardLocale         GetBoardLocaleFunc
+	GetArrowRepair         GetArrowRepairFunc
+	// SLO records instruction latency; nil disables it.
+	SLO *slo.Tracker
+	// Recorder captures each instruction for replay; nil disables it.
+	Recorder InstructionRecorder
+	// Conversations holds the board's earlier instructions, so follow-ups
+	// can refer back to them; nil disables history.
+	Conversations *llm.Conversations
+	// MaxAttempts is how many times an instruction is tried before giving
+	// up. Values below 1 mean a single attempt.
+	MaxAttempts int
+	// PromptTokenBudget caps the estimated prompt size; 0 means no cap.
+	PromptTokenBudget int
+	// BoardStateMaxBytes caps the board state JSON in the prompt; 0 means
+	// no cap.
+	BoardStateMaxBytes int
+	// FastPath answers simple geometry commands without the model.
+	FastPath bool
+	// LenientValidation drops bad arrow bindings instead of rejecting the
+	// action.
+	LenientValidation bool
+	// NormalizeColors snaps colors outside the palette to the nearest one.
+	NormalizeColors bool
+	// AutoLayout lays out added flowcharts instead of keeping the model's
+	// coordinates.
+	AutoLayout bool
+	// Guard flags actions that delete or rewrite too much of the board for
+	// confirmation; the zero value flags none.
+	Guard whiteboard.DestructiveLimits
+	// BatchInstructions holds back utterances spoken while an instruction is
+	// being handled and sends them to the LLM in one request once it is done,
+	// instead of one request each against a board that is about to change.
+	BatchInstructions bool
 }
 
 func NewVoiceHandler(cfg VoiceHandlerConfig) (*VoiceHandler, error) {
@@ -55,39 +211,89 @@ func NewVoiceHandler(cfg VoiceHandlerConfig) (*VoiceHandler, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	handler := &VoiceHandler{
-		sessionID:     cfg.SessionID,
-		boardID:       cfg.BoardID,
-		userID:        cfg.UserID,
-		speechClient:  cfg.SpeechClient,
-		llmClient:     cfg.LLMClient,
-		ctx:           ctx,
-		cancel:        cancel,
-		isMuted:       true,
-		onTranscribe:  cfg.OnTranscribe,
-		onLLMResponse: cfg.OnLLMResponse,
-		getBoardState: cfg.GetBoardState,
-	}
-
-	transcriptionCallback := func(transcription string, err error) {
-		if err != nil {
-			if handler.onTranscribe != nil {
-				handler.onTranscribe(handler.sessionID, "", err)
-			}
-			return
-		}
-		if handler.llmClient != nil {
-			go handler.handleLLMResponse(transcription)
-		}
-		if handler.onTranscribe != nil {
-			handler.onTranscribe(handler.sessionID, transcription, nil)
+		sessionID:          cfg.SessionID,
+		boardID:            cfg.BoardID,
+		quota:              cfg.Quota,
+		speechClient:       cfg.SpeechClient,
+		ctx:                ctx,
+		cancel:             cancel,
+		isMuted:            true,
+		onTranscribe:       cfg.OnTranscribe,
+		onLLMResponse:      cfg.OnLLMResponse,
+		onConfirmation:     cfg.OnConfirmationRequired,
+		onBoardMetadata:    cfg.OnBoardMetadata,
+		onComment:          cfg.OnComment,
+		onNavigate:         cfg.OnNavigate,
+		onUndo:             cfg.OnUndo,
+		onTiming:           cfg.OnTiming,
+		onInstructionState: cfg.OnInstructionState,
+		getBoardState:      cfg.GetBoardState,
+		getBoardLocale:     cfg.GetBoardLocale,
+		getArrowRepair:     cfg.GetArrowRepair,
+		slo:                cfg.SLO,
+		recorder:           cfg.Recorder,
+		conversations:      cfg.Conversations,
+		batchInstructions:  cfg.BatchInstructions,
+	}
+	if cfg.LLMClient != nil {
+		handler.pipeline = &Pipeline{
+			LLMClient:          cfg.LLMClient,
+			MaxAttempts:        cfg.MaxAttempts,
+			PromptTokenBudget:  cfg.PromptTokenBudget,
+			BoardStateMaxBytes: cfg.BoardStateMaxBytes,
+			FastPath:           cfg.FastPath,
+			LenientValidation:  cfg.LenientValidation,
+			NormalizeColors:    cfg.NormalizeColors,
+			AutoLayout:         cfg.AutoLayout,
+			Guard:              cfg.Guard,
+			OnPreview:          cfg.OnPreview,
+			OnPreviewClear:     cfg.OnPreviewClear,
 		}
 	}
 
-	handler.transcriptionCallback = transcriptionCallback
+	handler.transcriptionCallback = func(transcription string, err error) {
+		handler.transcribed(transcription, err, handler.speakerQuota())
+	}
 
 	return handler, nil
 }
 
+// transcribed runs a transcription as an instruction counting against quota.
+func (h *VoiceHandler) transcribed(transcription string, err error, quota Quota) {
+	if err != nil {
+		if h.onTranscribe != nil {
+			h.onTranscribe(h.sessionID, "", err)
+		}
+		return
+	}
+	if h.pipeline != nil {
+		go h.handleLLMResponse(utterance{text: transcription, quota: quota})
+	}
+	if h.onTranscribe != nil {
+		h.onTranscribe(h.sessionID, transcription, nil)
+	}
+}
+
+// Quota is the rate limit class and key an instruction counts against.
+type Quota struct {
+	Class llm.RateLimitClass
+	Key   string
+}
+
+// utterance is a transcription and the quota of whoever said it.
+type utterance struct {
+	text  string
+	quota Quota
+}
+
+// speakerQuota returns the quota of whoever is speaking.
+func (h *VoiceHandler) speakerQuota() Quota {
+	if h.quota == nil {
+		return Quota{}
+	}
+	return h.quota()
+}
+
 func (h *VoiceHandler) SendAudioChunk(sample media.PCM16Sample) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -170,7 +376,124 @@ func (h *VoiceHandler) Close() error {
 	return nil
 }
 
-func (h *VoiceHandler) handleLLMResponse(transcription string) {
+func (h *VoiceHandler) handleLLMResponse(u utterance) {
+	if !h.batchInstructions {
+		h.runInstruction(uuid.New().String(), u)
+		return
+	}
+
+	h.bufferMu.Lock()
+	h.buffered = append(h.buffered, u)
+	if h.flushing {
+		h.bufferMu.Unlock()
+		return
+	}
+	h.flushing = true
+	h.bufferMu.Unlock()
+	h.flush()
+}
+
+// flush handles the buffered utterances until none are left; those spoken
+// while a batch is being handled make up the next one.
+func (h *VoiceHandler) flush() {
+	for {
+		h.bufferMu.Lock()
+		batch := h.buffered
+		h.buffered = nil
+		if len(batch) == 0 {
+			h.flushing = false
+			h.bufferMu.Unlock()
+			return
+		}
+		h.bufferMu.Unlock()
+		h.runBatch(batch)
+	}
+}
+
+// runBatch handles the utterances the server answers itself, such as
+// navigation and comments, one by one, and sends the rest to the LLM in one
+// request, which counts against the quota of whoever spoke first.
+func (h *VoiceHandler) runBatch(utterances []utterance) {
+	if len(utterances) == 1 {
+		h.runInstruction(uuid.New().String(), utterances[0])
+		return
+	}
+	var instructions []string
+	var quota Quota
+	for _, u := range utterances {
+		if h.answeredByServer(u.text) {
+			h.runInstruction(uuid.New().String(), u)
+			continue
+		}
+		if len(instructions) == 0 {
+			quota = u.quota
+		}
+		instructions = append(instructions, u.text)
+	}
+	if len(instructions) > 0 {
+		h.runInstructions(uuid.New().String(), instructions, quota)
+	}
+}
+
+// answeredByServer reports whether transcription is an intent the server
+// handles without the LLM.
+func (h *VoiceHandler) answeredByServer(transcription string) bool {
+	if _, ok := whiteboard.ParseBoardMetadataIntent(transcription); ok && h.onBoardMetadata != nil {
+		return true
+	}
+	if _, ok := whiteboard.ParseNavigateIntent(transcription); ok && h.onNavigate != nil {
+		return true
+	}
+	if _, ok := whiteboard.ParseCommentIntent(transcription); ok && h.onComment != nil {
+		return true
+	}
+	if whiteboard.ParseUndoIntent(transcription) && h.onUndo != nil {
+		return true
+	}
+	return false
+}
+
+// ResumeInstruction re-runs an instruction interrupted by a server restart
+// under its original request ID, so reconnected clients can correlate the
+// outcome. It runs on its own goroutine.
+func (h *VoiceHandler) ResumeInstruction(requestID string, transcription string) {
+	if h.pipeline == nil {
+		return
+	}
+	go h.runInstruction(requestID, utterance{text: transcription, quota: h.speakerQuota()})
+}
+
+func (h *VoiceHandler) runInstruction(requestID string, u utterance) {
+	h.runInstructions(requestID, []string{u.text}, u.quota)
+}
+
+// runInstructions handles one instruction, or several sent to the LLM as a
+// batch; intents the server answers itself are only recognised on their own.
+// The LLM request counts against quota.
+func (h *VoiceHandler) runInstructions(requestID string, instructions []string, quota Quota) {
+	started := time.Now()
+	transcription := strings.Join(instructions, " ")
+	single := len(instructions) == 1
+	if intent, ok := whiteboard.ParseBoardMetadataIntent(transcription); ok && single && h.onBoardMetadata != nil {
+		h.onBoardMetadata(requestID, *intent)
+		return
+	}
+	if phrase, ok := whiteboard.ParseNavigateIntent(transcription); ok && single && h.onNavigate != nil {
+		if h.onNavigate(requestID, phrase) {
+			return
+		}
+	}
+	if whiteboard.ParseUndoIntent(transcription) && single && h.onUndo != nil {
+		if err := h.onUndo(requestID); err != nil {
+			failure := &InstructionFailure{}
+			failure.record(StageResolve, err, "")
+			if h.onLLMResponse != nil {
+				h.onLLMResponse(requestID, transcription, nil, failure)
+			}
+		}
+		return
+	}
+
 	var boardStateJSON string = "[]"
 	if h.getBoardState != nil && h.boardID != "" {
 		boardState, err := h.getBoardState()
@@ -183,17 +506,154 @@ func (h *VoiceHandler) handleLLMResponse(transcription string) {
 
 	fmt.Println("Transcription", transcription)
 
-	response, err := h.llmClient.GenerateResponse(context.Background(), transcription, boardStateJSON)
-	if err != nil {
-		if h.onLLMResponse != nil {
-			h.onLLMResponse(nil, err)
-		}
+	timezone, locale := h.boardLocale()
+	arrowRepair := h.arrowRepair()
+	inst := h.pipeline.PrepareBatch(requestID, instructions, boardStateJSON, started.UTC(), timezone, locale, arrowRepair)
+
+	if intent, ok := whiteboard.ParseCommentIntent(transcription); ok && single && h.onComment != nil {
+		h.handleComment(requestID, transcription, *intent, inst.Board)
 		return
 	}
 
-	if h.onLLMResponse != nil {
-		h.onLLMResponse(response, nil)
+	if h.onInstructionState != nil {
+		h.onInstructionState(requestID, transcription, InstructionPending)
+	}
+	inst.Options.History = h.conversations.Turns(h.boardID)
+	result := h.pipeline.Run(llm.WithRateLimitKey(context.Background(), quota.Class, quota.Key), inst)
+	if result.Err == nil && result.Response != nil {
+		h.conversations.Remember(h.boardID, llm.Turn{Instruction: transcription, Response: result.Response.Response})
+	}
+	if h.recorder != nil {
+		h.recorder.RecordInstruction(h.sessionID, h.boardID, InstructionTrace{
+			RequestID:     requestID,
+			AudioRef:      fmt.Sprintf("%s@%s", h.sessionID, started.UTC().Format(time.RFC3339Nano)),
+			At:            started.UTC(),
+			Transcription: transcription,
+			BoardState:    boardStateJSON,
+			Timezone:      timezone,
+			Locale:        locale,
+			ArrowRepair:   inst.ArrowRepair,
+			Instructions:  inst.Instructions,
+			Result:        result,
+		})
+	}
+
+	broadcastStart := time.Now()
+	responses := append([]*llm.LLMResponse{result.Response}, result.Followups...)
+	if result.Err == nil && h.onConfirmation != nil && requiresConfirmation(responses) {
+		h.onConfirmation(requestID, transcription, responses, hashBoardState(boardStateJSON))
+	} else if h.onLLMResponse != nil {
+		h.onLLMResponse(requestID, transcription, result.Response, result.Err)
+		for _, followup := range result.Followups {
+			h.onLLMResponse(requestID, transcription, followup, nil)
+		}
+	}
+	if h.onInstructionState != nil {
+		state := InstructionApplied
+		if result.Err != nil {
+			state = InstructionFailed
+		}
+		h.onInstructionState(requestID, transcription, state)
+	}
+	broadcast := time.Since(broadcastStart)
+	total := time.Since(started)
+	h.slo.Record(slo.Latency{
+		Stages: map[string]time.Duration{
+			slo.StageQueue:     result.timing.queue,
+			slo.StageLLM:       result.timing.llm,
+			slo.StageApply:     result.timing.apply,
+			slo.StageBroadcast: broadcast,
+		},
+		Total:  total,
+		Failed: result.Err != nil,
+	})
+	if h.onTiming != nil {
+		timing := InstructionTiming{
+			RequestID: requestID,
+			Failed:    result.Err != nil,
+			Queue:     result.timing.queue,
+			FirstByte: result.timing.firstByte,
+			LLM:       result.timing.llm,
+			Validate:  result.timing.apply,
+			Broadcast: broadcast,
+			Total:     total,
+		}
+		var failure *InstructionFailure
+		if result.Response != nil {
+			timing.Provider = result.Response.Provider
+			timing.Model = result.Response.Model
+		} else if errors.As(result.Err, &failure) {
+			timing.Provider = failure.Provider
+			timing.Model = failure.Model
+		}
+		h.onTiming(timing)
+	}
+}
+
+// requiresConfirmation reports whether any of an instruction's responses
+// was flagged by the guard.
+func requiresConfirmation(responses []*llm.LLMResponse) bool {
+	for _, response := range responses {
+		if response != nil && response.RequiresConfirmation {
+			return true
+		}
+	}
+	return false
+}
+
+// handleComment resolves the comment's target element and hands it on. An
+// unresolvable target is reported like any other failed instruction.
+func (h *VoiceHandler) handleComment(requestID string, transcription string, intent whiteboard.CommentIntent, board []llm.Element) {
+	if intent.Target != "" {
+		referents := whiteboard.ResolveReferents(intent.Target, board)
+		if len(referents) == 0 {
+			// Not named by its label; it may be described by where it is,
+			// as in "the box on the left".
+			if id, err := whiteboard.ResolveReference(intent.Target, board); err == nil {
+				referents = append(referents, llm.Referent{Phrase: intent.Target, ElementID: id})
+			}
+		}
+		if len(referents) != 1 {
+			err := fmt.Errorf("no element matches %q", intent.Target)
+			if len(referents) > 1 {
+				err = fmt.Errorf("%q matches %d elements", intent.Target, len(referents))
+			}
+			failure := &InstructionFailure{}
+			failure.record(StageResolve, err, "")
+			if h.onLLMResponse != nil {
+				h.onLLMResponse(requestID, transcription, nil, failure)
+			}
+			return
+		}
+		intent.ElementID = &referents[0].ElementID
+	}
+	h.onComment(requestID, intent)
+}
+
+// boardLocale returns the board's time zone and locale, used to resolve
+// relative dates. Empty values fall back to UTC and the default locale.
+func (h *VoiceHandler) boardLocale() (string, string) {
+	if h.getBoardLocale == nil {
+		return "", ""
+	}
+	timezone, locale, err := h.getBoardLocale()
+	if err != nil {
+		fmt.Println("Failed to get board locale", err, "boardID", h.boardID)
+	}
+	return timezone, locale
+}
+
+// arrowRepair returns the board's arrow repair policy, or "" to use the
+// default.
+func (h *VoiceHandler) arrowRepair() string {
+	if h.getArrowRepair == nil {
+		return ""
+	}
+	policy, err := h.getArrowRepair()
+	if err != nil {
+		fmt.Println("Failed to get arrow repair policy", err, "boardID", h.boardID)
 	}
+	return policy
 }
 
 func pcm16ToBytes(sample media.PCM16Sample) []byte {
@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ActionAdd       = "add"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionTransform = "transform"
+	ActionError     = "error"
+	// ActionClear removes every element from the board.
+	ActionClear = "clear"
+	// ActionReplace removes every element from the board and adds Elements
+	// in their place.
+	ActionReplace = "replace"
+	// ActionReorder moves TargetIDs to Position in the stacking order.
+	ActionReorder = "reorder"
+	// ActionTemplate adds the diagram skeleton Name, from the template
+	// library, at Origin.
+	ActionTemplate = "template"
+)
+
+// Transform operations.
+const (
+	// OperationCopyStyle copies the visual style of SourceID onto TargetIDs.
+	OperationCopyStyle = "copy_style"
+	// OperationRecolorStickies gives TargetIDs, or every sticky note when
+	// there are none, the palette color Color.
+	OperationRecolorStickies = "recolor_stickies"
+	// OperationClusterStickies arranges TargetIDs, or every sticky note when
+	// there are none, into one column per color.
+	OperationClusterStickies = "cluster_stickies"
+)
+
+// Reorder positions.
+const (
+	// PositionFront puts elements above all others.
+	PositionFront = "front"
+	// PositionBack puts elements below all others.
+	PositionBack = "back"
+	// PositionForward moves elements up past the element above them.
+	PositionForward = "forward"
+	// PositionBackward moves elements down past the element below them.
+	PositionBackward = "backward"
+)
+
+// WhiteboardAction is the structured form of a model response.
+type WhiteboardAction struct {
+	Action    string    `json:"action"`
+	Elements  []Element `json:"elements,omitempty"`
+	DeleteIDs []string  `json:"delete_ids,omitempty"`
+	Message   string    `json:"message,omitempty"`
+
+	// Transform fields. Transforms are resolved against the board state on
+	// the server and never reach clients as-is.
+	Operation string `json:"operation,omitempty"`
+	SourceID  string `json:"source_id,omitempty"`
+	// TargetIDs are also the elements a reorder moves.
+	TargetIDs []string `json:"target_ids,omitempty"`
+	Color     string   `json:"color,omitempty"`
+
+	// Position is where a reorder moves its targets.
+	Position string `json:"position,omitempty"`
+
+	// Template fields. Templates are expanded into an add on the server and
+	// never reach clients as-is.
+	Name string `json:"name,omitempty"`
+	// Origin is where the template's top-left corner goes; without one it
+	// goes below the board.
+	Origin *Point `json:"origin,omitempty"`
+	// Params fill in the template's text, such as its title.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Point is a position on the board.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ParseWhiteboardAction extracts the action object from raw model output,
+// tolerating code fences and prose around the JSON as well as responses that
+// were JSON-encoded twice.
+func ParseWhiteboardAction(raw string) (*WhiteboardAction, error) {
+	action, err := decodeFirstObject(unquote(raw))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkActionType(action); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// ParseWhiteboardActions parses the answer to a batch of instructions, which
+// is either one action or a JSON array of actions in the order they apply.
+// Output that isn't an array is parsed like ParseWhiteboardAction.
+func ParseWhiteboardActions(raw string) ([]*WhiteboardAction, error) {
+	raw = unquote(raw)
+	start := strings.IndexAny(raw, "[{")
+	if start < 0 || raw[start] != '[' || !strings.HasPrefix(strings.TrimSpace(raw[start+1:]), "{") {
+		action, err := ParseWhiteboardAction(raw)
+		if err != nil {
+			return nil, err
+		}
+		return []*WhiteboardAction{action}, nil
+	}
+
+	var actions []*WhiteboardAction
+	if err := json.NewDecoder(strings.NewReader(raw[start:])).Decode(&actions); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("response is truncated: %w", err)
+		}
+		return nil, fmt.Errorf("invalid action array JSON: %w", err)
+	}
+	for i, action := range actions {
+		if action == nil {
+			return nil, fmt.Errorf("action %d is null", i+1)
+		}
+		if err := checkActionType(action); err != nil {
+			return nil, fmt.Errorf("action %d: %w", i+1, err)
+		}
+	}
+	return actions, nil
+}
+
+// unquote trims raw and undoes a second layer of JSON encoding, which some
+// models add.
+func unquote(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, `"`) {
+		var inner string
+		if err := json.Unmarshal([]byte(raw), &inner); err == nil {
+			raw = inner
+		}
+	}
+	return raw
+}
+
+func checkActionType(action *WhiteboardAction) error {
+	switch action.Action {
+	case ActionAdd, ActionUpdate, ActionDelete, ActionTransform, ActionError, ActionClear, ActionReplace, ActionReorder, ActionTemplate:
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q", action.Action)
+	}
+}
+
+// maxObjectAttempts bounds how many braces decodeFirstObject tries to decode
+// from. Each attempt reads to the end of the response, so output full of
+// braces would otherwise take quadratic time.
+const maxObjectAttempts = 32
+
+// decodeFirstObject decodes the first JSON object in s that parses as an
+// action, skipping braces that belong to surrounding prose or to objects
+// nested inside a broken action. Anything after the object is ignored.
+func decodeFirstObject(s string) (*WhiteboardAction, error) {
+	var firstErr error
+	for offset, attempts := 0, 0; offset < len(s) && attempts < maxObjectAttempts; attempts++ {
+		start := strings.IndexByte(s[offset:], '{')
+		if start < 0 {
+			break
+		}
+		start += offset
+
+		var action WhiteboardAction
+		err := json.NewDecoder(strings.NewReader(s[start:])).Decode(&action)
+		if err == nil && action.Action != "" {
+			return &action, nil
+		}
+		if err == nil {
+			// A nested object (e.g. an element from a truncated response)
+			// rather than the action itself.
+			err = fmt.Errorf("object has no action")
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		offset = start + 1
+	}
+
+	if firstErr == nil {
+		return nil, fmt.Errorf("no JSON object in response")
+	}
+	if errors.Is(firstErr, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("response is truncated: %w", firstErr)
+	}
+	return nil, fmt.Errorf("invalid action JSON: %w", firstErr)
+}
@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzParseWhiteboardAction feeds arbitrary model output to the parsers.
+// Besides the corpus in testdata/fuzz, every file in testdata/regressions is
+// a seed: add the raw response of each production incident the parser was
+// involved in there, one file per response.
+func FuzzParseWhiteboardAction(f *testing.F) {
+	regressions, err := filepath.Glob(filepath.Join("testdata", "regressions", "*.txt"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range regressions {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(data))
+	}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		action, err := ParseWhiteboardAction(raw)
+		if err == nil {
+			checkParsedAction(t, action)
+		} else if action != nil {
+			t.Fatalf("returned an action along with error %v", err)
+		}
+
+		actions, err := ParseWhiteboardActions(raw)
+		if err != nil {
+			return
+		}
+		if len(actions) == 0 {
+			t.Fatal("returned no actions and no error")
+		}
+		for _, action := range actions {
+			checkParsedAction(t, action)
+		}
+	})
+}
+
+// checkParsedAction asserts what holds for every action the parser returns,
+// whatever it was given.
+func checkParsedAction(t *testing.T, action *WhiteboardAction) {
+	t.Helper()
+	if err := checkActionType(action); err != nil {
+		t.Fatalf("parsed an action of unknown type: %v", err)
+	}
+	for i, element := range action.Elements {
+		numbers := []float64{element.X, element.Y, element.Width, element.Height, element.StrokeWidth, element.FontSize}
+		for _, point := range element.Points {
+			numbers = append(numbers, point...)
+		}
+		for _, n := range numbers {
+			if math.IsNaN(n) || math.IsInf(n, 0) {
+				t.Fatalf("element %d has the non-finite number %v", i, n)
+			}
+		}
+	}
+
+	data, err := json.Marshal(action)
+	if err != nil {
+		t.Fatalf("parsed action doesn't marshal: %v", err)
+	}
+	if !json.Valid(data) {
+		t.Fatalf("parsed action marshals to invalid JSON %s", data)
+	}
+	reparsed, err := ParseWhiteboardAction(string(data))
+	if err != nil {
+		t.Fatalf("marshalled action %s doesn't parse: %v", data, err)
+	}
+	if reparsed.Action != action.Action || len(reparsed.Elements) != len(action.Elements) {
+		t.Fatalf("marshalled action %s parses as a %s of %d elements", data, reparsed.Action, len(reparsed.Elements))
+	}
+
+	// The lenient path leaves no binding that can't be resolved.
+	report := DropBadBindings(action, ValidateAction(action, nil))
+	for _, issue := range report.Errors() {
+		if issue.Code == IssueBindingUnresolvable {
+			t.Fatalf("binding left unresolvable: %s", issue.Message)
+		}
+	}
+}
+
+func TestDecodeFirstObjectBoundsAttempts(t *testing.T) {
+	// Past the cap, an action behind enough stray braces is no longer found.
+	raw := ""
+	for i := 0; i < maxObjectAttempts; i++ {
+		raw += "{x} "
+	}
+	if _, err := ParseWhiteboardAction(raw + `{"action":"delete","delete_ids":["a"]}`); err == nil {
+		t.Error("decoded an action past the attempt cap")
+	}
+	if _, err := ParseWhiteboardAction("{x} " + `{"action":"delete","delete_ids":["a"]}`); err != nil {
+		t.Errorf("action after one stray brace: %v", err)
+	}
+}
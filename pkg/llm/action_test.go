@@ -0,0 +1,207 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseClearAndReplace(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		action   string
+		elements int
+	}{
+		{name: "clear", raw: `{"action":"clear"}`, action: ActionClear},
+		{name: "clear with a message", raw: `{"action":"clear","message":"Starting over"}`, action: ActionClear},
+		{name: "clear in a code fence", raw: "```json\n{\"action\":\"clear\"}\n```", action: ActionClear},
+		{name: "clear encoded twice", raw: `"{\"action\":\"clear\"}"`, action: ActionClear},
+		{
+			name:     "replace",
+			raw:      `{"action":"replace","elements":[{"id":"a","type":"rectangle","x":0,"y":0},{"id":"b","type":"ellipse","x":200,"y":0}]}`,
+			action:   ActionReplace,
+			elements: 2,
+		},
+		{
+			name:     "replace after prose",
+			raw:      `Here is the new board: {"action":"replace","elements":[{"id":"a","type":"text","x":0,"y":0,"text":"Hi"}]}`,
+			action:   ActionReplace,
+			elements: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, err := ParseWhiteboardAction(tt.raw)
+			if err != nil {
+				t.Fatalf("ParseWhiteboardAction: %v", err)
+			}
+			if action.Action != tt.action {
+				t.Errorf("action = %q, want %q", action.Action, tt.action)
+			}
+			if len(action.Elements) != tt.elements {
+				t.Errorf("got %d elements, want %d", len(action.Elements), tt.elements)
+			}
+		})
+	}
+
+	actions, err := ParseWhiteboardActions(`[{"action":"clear"},{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0}]}]`)
+	if err != nil {
+		t.Fatalf("ParseWhiteboardActions: %v", err)
+	}
+	if len(actions) != 2 || actions[0].Action != ActionClear || actions[1].Action != ActionAdd {
+		t.Errorf("batch = %+v, want a clear then an add", actions)
+	}
+
+	// A clear carries nothing, so it encodes as nothing but its action.
+	data, err := json.Marshal(&WhiteboardAction{Action: ActionClear})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != `{"action":"clear"}` {
+		t.Errorf("clear = %s, want {\"action\":\"clear\"}", data)
+	}
+}
+
+func TestValidateClearAndReplace(t *testing.T) {
+	board := `[
+		{"id":"box","type":"rectangle","x":0,"y":0,"width":100,"height":60},
+		{"id":"pic","type":"image","x":200,"y":0,"width":100,"height":100,"fileId":"file-1"}
+	]`
+	tests := []struct {
+		name   string
+		action string
+		board  string
+		// errors are the codes of the errors expected, in order.
+		errors []string
+	}{
+		{name: "clear", action: `{"action":"clear"}`, board: board},
+		{name: "clear on an empty board", action: `{"action":"clear"}`, board: `[]`},
+		{name: "clear with elements", action: `{"action":"clear","elements":[{"id":"a","type":"rectangle","x":0,"y":0}]}`, board: board, errors: []string{IssueUnexpectedElements}},
+		{name: "clear with delete_ids", action: `{"action":"clear","delete_ids":["box"]}`, board: board, errors: []string{IssueUnexpectedElements}},
+		{
+			name:   "replace with IDs not on the board",
+			action: `{"action":"replace","elements":[{"id":"new-1","type":"rectangle","x":0,"y":0},{"id":"new-2","type":"arrow","x":0,"y":0,"start":{"id":"new-1"}}]}`,
+			board:  board,
+		},
+		{
+			name:   "replace reusing an ID from the board",
+			action: `{"action":"replace","elements":[{"id":"box","type":"ellipse","x":0,"y":0}]}`,
+			board:  board,
+		},
+		{
+			name:   "replace on an empty board",
+			action: `{"action":"replace","elements":[{"id":"a","type":"rectangle","x":0,"y":0}]}`,
+			board:  `[]`,
+		},
+		{
+			name:   "replace showing an image from the board",
+			action: `{"action":"replace","elements":[{"id":"a","type":"image","x":0,"y":0,"fileId":"file-1"}]}`,
+			board:  board,
+		},
+		{
+			name:   "replace bound to an element it removes",
+			action: `{"action":"replace","elements":[{"id":"a","type":"arrow","x":0,"y":0,"start":{"id":"box"}}]}`,
+			board:  board,
+			errors: []string{IssueBindingUnresolvable},
+		},
+		{
+			name:   "replace in a frame it removes",
+			action: `{"action":"replace","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"frameId":"box"}]}`,
+			board:  board,
+			errors: []string{IssueFrameUnresolvable},
+		},
+		{name: "replace without elements", action: `{"action":"replace"}`, board: board, errors: []string{IssueMissingElements}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var action WhiteboardAction
+			if err := json.Unmarshal([]byte(tt.action), &action); err != nil {
+				t.Fatalf("failed to parse action: %v", err)
+			}
+			var elements []Element
+			if err := json.Unmarshal([]byte(tt.board), &elements); err != nil {
+				t.Fatalf("failed to parse board: %v", err)
+			}
+
+			var codes []string
+			for _, issue := range ValidateAction(&action, elements).Errors() {
+				codes = append(codes, issue.Code)
+			}
+			if len(codes) != len(tt.errors) {
+				t.Fatalf("errors = %v, want %v", codes, tt.errors)
+			}
+			for i := range codes {
+				if codes[i] != tt.errors[i] {
+					t.Errorf("errors = %v, want %v", codes, tt.errors)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseWhiteboardAction(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		// want summarizes the action as action/element ids/delete ids/message.
+		want string
+		err  string
+	}{
+		{name: "bare", raw: `{"action":"delete","delete_ids":["a","b"]}`, want: "delete//a,b/"},
+		{name: "fenced", raw: "```json\n{\"action\":\"add\",\"elements\":[{\"id\":\"a\",\"type\":\"rectangle\",\"x\":0,\"y\":0}]}\n```", want: "add/a//"},
+		{name: "fenced without a language", raw: "```\n{\"action\":\"clear\"}\n```", want: "clear///"},
+		{name: "leading prose", raw: `Sure! Here you go: {"action":"clear","message":"Cleared"}`, want: "clear///Cleared"},
+		{name: "trailing commentary", raw: `{"action":"delete","delete_ids":["a"]} I removed the box {as asked}.`, want: "delete//a/"},
+		{name: "prose with braces first", raw: `I'll use {curly} braces: {"action":"clear"}`, want: "clear///"},
+		{name: "malformed", raw: `{"action":"add","elements":[{"id":"a",}]}`, err: "invalid action JSON: invalid character '}' looking for beginning of object key string"},
+		{name: "truncated", raw: `{"action":"add","elements":[{"id":"a","type":"rect`, err: "response is truncated: unexpected EOF"},
+		{name: "no JSON", raw: "I can't draw that.", err: "no JSON object in response"},
+		{name: "unknown action", raw: `{"action":"explode"}`, err: `unknown action "explode"`},
+		{name: "no action", raw: `{"elements":[]}`, err: "invalid action JSON: object has no action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, err := ParseWhiteboardAction(tt.raw)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWhiteboardAction: %v", err)
+			}
+			var ids []string
+			for _, element := range action.Elements {
+				ids = append(ids, element.ID)
+			}
+			got := action.Action + "/" + strings.Join(ids, ",") + "/" + strings.Join(action.DeleteIDs, ",") + "/" + action.Message
+			if got != tt.want {
+				t.Errorf("action = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseElementSchema(t *testing.T) {
+	action, err := ParseWhiteboardAction(`{"action":"add","elements":[
+		{"id":"box","type":"rectangle","x":10,"y":20,"width":120,"height":60,"label":{"text":"Login","fontSize":16}},
+		{"id":"flow","type":"arrow","x":130,"y":50,"points":[[0,0],[100,0]],"start":{"id":"box"},"end":{"id":"db"},"seed":7}
+	]}`)
+	if err != nil {
+		t.Fatalf("ParseWhiteboardAction: %v", err)
+	}
+	box, arrow := action.Elements[0], action.Elements[1]
+	if box.Type != "rectangle" || box.X != 10 || box.Width != 120 || box.Label == nil || box.Label.Text != "Login" || box.Label.FontSize != 16 {
+		t.Errorf("box = %+v", box)
+	}
+	if arrow.Start == nil || arrow.Start.ID != "box" || arrow.End == nil || arrow.End.ID != "db" || len(arrow.Points) != 2 {
+		t.Errorf("arrow = %+v", arrow)
+	}
+	// Fields outside the schema are kept, so elements round-trip.
+	if string(arrow.Extra["seed"]) != "7" {
+		t.Errorf("seed = %s, want it kept in Extra", arrow.Extra["seed"])
+	}
+}
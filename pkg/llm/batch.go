@@ -0,0 +1,34 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// BatchPrompt numbers instructions given one after another, so the model
+// carries them out in order; the system prompt describes the format. A
+// single instruction is returned as-is.
+func BatchPrompt(instructions []string) string {
+	if len(instructions) == 1 {
+		return instructions[0]
+	}
+	var b strings.Builder
+	b.WriteString("Instructions, in the order they were given:")
+	for i, instruction := range instructions {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, instruction)
+	}
+	return b.String()
+}
+
+// GenerateResponseBatch sends instructions given one after another, such as
+// the sentences a user speaks before pausing, in one request against the same
+// board state. The model answers with one action covering them all or an
+// array of actions in order, which ParseWhiteboardActions reads; ParsedAction
+// only holds the first.
+func GenerateResponseBatch(ctx context.Context, client LLMClient, instructions []string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	if len(instructions) == 0 {
+		return nil, fmt.Errorf("no instructions to send")
+	}
+	return client.GenerateResponseWithOptions(ctx, BatchPrompt(instructions), boardState, opts)
+}
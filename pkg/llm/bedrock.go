@@ -0,0 +1,393 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// bedrockThrottlingErrors are the Bedrock error types that mean "try again
+// later" rather than "this request is wrong".
+var bedrockThrottlingErrors = []string{
+	"ThrottlingException",
+	"ServiceQuotaExceededException",
+	"ServiceUnavailableException",
+	"ModelNotReadyException",
+}
+
+// BedrockLLMClient calls the Bedrock Converse API to generate whiteboard
+// updates, signing requests with the configured AWS credentials.
+type BedrockLLMClient struct {
+	httpClient  *http.Client
+	signer      *v4.Signer
+	credentials aws.Credentials
+	region      string
+	endpoint    string
+	model       string
+	models      *Models
+	settings    RequestSettings
+	requestChan chan llmRequest
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+// NewBedrockLLMClient creates the client. endpoint overrides the regional
+// bedrock-runtime endpoint, e.g. for a VPC endpoint, and may be empty.
+// models may be nil, in which case retired models are not substituted.
+func NewBedrockLLMClient(endpoint, region, model, accessKey, secretKey string, models *Models, settings RequestSettings, pool WorkerPool) (*BedrockLLMClient, error) {
+	if strings.TrimSpace(region) == "" {
+		return nil, fmt.Errorf("bedrock region is required")
+	}
+	if strings.TrimSpace(accessKey) == "" || strings.TrimSpace(secretKey) == "" {
+		return nil, fmt.Errorf("bedrock access key and secret key are required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("bedrock model is required")
+	}
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	client := &BedrockLLMClient{
+		httpClient: newHTTPClient(settings.Timeout+5*time.Second, pool),
+		signer:     v4.NewSigner(),
+		credentials: aws.Credentials{
+			AccessKeyID:     accessKey,
+			SecretAccessKey: secretKey,
+		},
+		region:      region,
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		model:       model,
+		models:      models,
+		settings:    settings,
+		requestChan: make(chan llmRequest, pool.queueSize()),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	pool.start(&client.wg, client.worker)
+
+	return client, nil
+}
+
+func (c *BedrockLLMClient) worker() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			rejectPending(c.requestChan)
+			return
+		case req := <-c.requestChan:
+			if err := req.ctx.Err(); err != nil {
+				// The caller gave up while the request was queued.
+				req.errCh <- err
+				continue
+			}
+			result, err := c.generateResponseSync(req)
+			if err != nil {
+				req.errCh <- timeoutError(req, err)
+			} else {
+				req.resultCh <- result
+			}
+		}
+	}
+}
+
+func (c *BedrockLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *BedrockLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return nil, fmt.Errorf("empty text provided")
+	}
+
+	boardStateJSON := boardState
+	if boardState == "" {
+		boardStateJSON = "[]"
+	} else {
+		var js json.RawMessage
+		if err := json.Unmarshal([]byte(boardState), &js); err != nil {
+			boardStateJSON = "[]"
+		}
+	}
+
+	if opts.ContextWindow == 0 {
+		opts.ContextWindow = ContextWindow(LLMProviderBedrock, c.model)
+	}
+	userPrompt := opts.BuildPrompt(prompt, boardStateJSON)
+	systemPrompt, promptVersion := c.settings.systemPrompt(opts.Mode)
+
+	if c.ctx.Err() != nil {
+		return nil, ErrClientClosed
+	}
+	resultCh := make(chan *LLMResponse, 1)
+	errCh := make(chan error, 1)
+
+	select {
+	case c.requestChan <- llmRequest{
+		ctx:          ctx,
+		prompt:       userPrompt.Text,
+		systemPrompt: systemPrompt,
+		options:      opts,
+		resultCh:     resultCh,
+		errCh:        errCh,
+	}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	default:
+		return nil, ErrQueueFull
+	}
+
+	select {
+	case result := <-resultCh:
+		result.DroppedSections = userPrompt.Dropped
+		result.CompactionLevel = userPrompt.CompactionLevel
+		result.PromptVersion = promptVersion
+		return result, nil
+	case err := <-errCh:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	}
+}
+
+// generateResponseSync calls the API with the model currently in use. When
+// the API reports that model as not found, the request is retried once with
+// its configured replacement.
+func (c *BedrockLLMClient) generateResponseSync(llmReq llmRequest) (*LLMResponse, error) {
+	model, err := c.models.Resolve(c.model)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.generate(llmReq, model)
+	var notFound *modelNotFoundError
+	if !errors.As(err, &notFound) {
+		return resp, err
+	}
+	replacement, ok := c.models.Retired(model, notFound.detail)
+	if !ok {
+		fmt.Printf("WARNING: bedrock model %q is not available and no replacement is configured in LLM_MODEL_ALIASES: %s\n", model, notFound.detail)
+		return nil, fmt.Errorf("%w: %s", ErrModelUnavailable, err)
+	}
+	fmt.Printf("WARNING: bedrock model %q is deprecated; using %q instead. Update LLM_MODEL.\n", model, replacement)
+	return c.generate(llmReq, replacement)
+}
+
+func (c *BedrockLLMClient) generate(llmReq llmRequest, model string) (*LLMResponse, error) {
+	temperature := llmReq.options.temperature(c.settings.Temperature)
+	topP := 0.9
+	var messages []bedrockMessage
+	for _, turn := range llmReq.options.History {
+		messages = append(messages,
+			bedrockMessage{Role: "user", Content: []bedrockContentBlock{{Text: turn.Instruction}}},
+			bedrockMessage{Role: "assistant", Content: []bedrockContentBlock{{Text: turn.Response}}},
+		)
+	}
+	messages = append(messages, bedrockMessage{
+		Role:    "user",
+		Content: []bedrockContentBlock{{Text: llmReq.prompt}},
+	})
+	payload := bedrockConverseRequest{
+		Messages: messages,
+		InferenceConfig: bedrockInferenceConfig{
+			MaxTokens:   c.settings.MaxTokens,
+			Temperature: &temperature,
+			TopP:        &topP,
+		},
+	}
+	if llmReq.systemPrompt != "" {
+		payload.System = []bedrockContentBlock{{Text: llmReq.systemPrompt}}
+	}
+
+	reqCtx, cancel := requestContext(llmReq, c.ctx, c.settings.Timeout)
+	defer cancel()
+
+	converseResp, err := c.converse(reqCtx, model, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	for _, block := range converseResp.Output.Message.Content {
+		text.WriteString(block.Text)
+	}
+	if text.Len() == 0 {
+		if converseResp.StopReason != "" {
+			return nil, fmt.Errorf("%w: bedrock stop reason %s", ErrEmptyResponse, converseResp.StopReason)
+		}
+		return nil, fmt.Errorf("%w: bedrock", ErrEmptyResponse)
+	}
+
+	return &LLMResponse{
+		Response:  strings.TrimSpace(text.String()),
+		Timestamp: time.Now().UTC(),
+		Seed:      llmReq.options.Seed,
+		Provider:  string(LLMProviderBedrock),
+		Model:     model,
+		Usage: Usage{
+			PromptTokens:     converseResp.Usage.InputTokens,
+			CompletionTokens: converseResp.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// converse sends one Converse request for model.
+func (c *BedrockLLMClient) converse(ctx context.Context, model string, payload bedrockConverseRequest) (*bedrockConverseResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bedrock request: %w", err)
+	}
+
+	// Model IDs may be ARNs, whose slashes and colons must stay inside one
+	// path segment, escaped the way the AWS SDKs escape them.
+	endpoint := fmt.Sprintf("%s/model/%s/converse", c.endpoint, strings.ReplaceAll(url.PathEscape(model), ":", "%3A"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bedrock request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	payloadHash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(ctx, c.credentials, req, hex.EncodeToString(payloadHash[:]), "bedrock", c.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign bedrock request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock api request error: %w", err)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		detail := fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+		errorType := resp.Header.Get("X-Amzn-ErrorType")
+		if isBedrockThrottled(resp.StatusCode, errorType) {
+			return nil, fmt.Errorf("%w: bedrock api error: %s", ErrProviderThrottled, detail)
+		}
+		if strings.HasPrefix(errorType, "ResourceNotFoundException") || isModelNotFound(resp.StatusCode, string(errBody)) {
+			return nil, &modelNotFoundError{model: model, detail: detail}
+		}
+		if class := statusError(resp.StatusCode); class != nil {
+			return nil, fmt.Errorf("%w: bedrock api error: %s", class, detail)
+		}
+		return nil, fmt.Errorf("bedrock api error: %s", detail)
+	}
+
+	var converseResp bedrockConverseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&converseResp); err != nil {
+		return nil, fmt.Errorf("failed to decode bedrock response: %w", err)
+	}
+	return &converseResp, nil
+}
+
+// Ping asks for a one-token answer: the runtime API has no cheaper call that
+// checks both the credentials and access to the model.
+func (c *BedrockLLMClient) Ping(ctx context.Context) error {
+	model, err := c.models.Resolve(c.model)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.settings.Timeout)
+	defer cancel()
+
+	_, err = c.converse(ctx, model, bedrockConverseRequest{
+		Messages: []bedrockMessage{{
+			Role:    "user",
+			Content: []bedrockContentBlock{{Text: "ping"}},
+		}},
+		InferenceConfig: bedrockInferenceConfig{MaxTokens: 1},
+	})
+	var notFound *modelNotFoundError
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("%w: %s", ErrModelUnavailable, err)
+	}
+	return err
+}
+
+// isBedrockThrottled reports whether an error response asks the caller to
+// back off. errorType is the X-Amzn-ErrorType header, which may carry a
+// ":<docs url>" suffix.
+func isBedrockThrottled(status int, errorType string) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	for _, name := range bedrockThrottlingErrors {
+		if strings.HasPrefix(errorType, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueLength reports how many requests are waiting for a worker.
+func (c *BedrockLLMClient) QueueLength() int {
+	return len(c.requestChan)
+}
+
+func (c *BedrockLLMClient) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.wg.Wait()
+		c.httpClient.CloseIdleConnections()
+	})
+	return nil
+}
+
+type bedrockContentBlock struct {
+	Text string `json:"text"`
+}
+
+type bedrockMessage struct {
+	Role    string                `json:"role"`
+	Content []bedrockContentBlock `json:"content"`
+}
+
+type bedrockInferenceConfig struct {
+	MaxTokens   int      `json:"maxTokens"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+}
+
+type bedrockConverseRequest struct {
+	System          []bedrockContentBlock  `json:"system,omitempty"`
+	Messages        []bedrockMessage       `json:"messages"`
+	InferenceConfig bedrockInferenceConfig `json:"inferenceConfig"`
+}
+
+type bedrockConverseResponse struct {
+	Output struct {
+		Message bedrockMessage `json:"message"`
+	} `json:"output"`
+	StopReason string `json:"stopReason"`
+	Usage      struct {
+		InputTokens  int `json:"inputTokens"`
+		OutputTokens int `json:"outputTokens"`
+	} `json:"usage"`
+}
@@ -0,0 +1,308 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"draw/pkg/config"
+)
+
+// ErrBudgetExhausted is returned instead of calling the provider once the
+// monthly budget is spent and the budget is configured to reject requests.
+var ErrBudgetExhausted = errors.New("budget_exhausted: the monthly LLM budget has been spent")
+
+// What happens once the budget is spent.
+const (
+	BudgetModeDowngrade = "downgrade"
+	BudgetModeReject    = "reject"
+)
+
+// degradedWarning is attached to responses served by the fallback provider.
+const degradedWarning = "The monthly LLM budget is exhausted; this response came from the fallback model and may be lower quality."
+
+// modelUnavailableWarning is attached to responses the fallback provider
+// served because the primary model has been retired.
+const modelUnavailableWarning = "The configured LLM model is unavailable; this response came from the fallback model and may be lower quality."
+
+type BudgetState int
+
+const (
+	BudgetOK BudgetState = iota
+	BudgetWarning
+	BudgetExhausted
+)
+
+// SpendFunc returns how much has been spent on the primary provider since the
+// given time, as persisted with each instruction.
+type SpendFunc func(ctx context.Context, since time.Time) (float64, error)
+
+// Budget tracks spend against a monthly limit. Spend is read from persisted
+// usage so it survives restarts and is shared across replicas, and is cached
+// for a short while so checking the budget doesn't query the database on
+// every request. Costs recorded locally are added to the cached value until
+// the next refresh.
+type Budget struct {
+	limit      float64
+	warnRatio  float64
+	mode       string
+	ttl        time.Duration
+	spend      SpendFunc
+	promptRate float64
+	outputRate float64
+
+	mu          sync.Mutex
+	period      time.Time
+	spent       float64
+	refreshedAt time.Time
+	warned      bool
+	exhausted   bool
+	// refreshing is set while one caller reads the spend; addedSince is
+	// what Add counted meanwhile.
+	refreshing bool
+	addedSince float64
+}
+
+func NewBudget(cfg *config.LLMConfig, spend SpendFunc) (*Budget, error) {
+	if cfg.BudgetExhaustedMode != BudgetModeDowngrade && cfg.BudgetExhaustedMode != BudgetModeReject {
+		return nil, fmt.Errorf("unknown LLM budget mode %q: must be %s or %s", cfg.BudgetExhaustedMode, BudgetModeDowngrade, BudgetModeReject)
+	}
+	if cfg.MonthlyBudgetUSD < 0 {
+		return nil, fmt.Errorf("LLM monthly budget must not be negative")
+	}
+	return &Budget{
+		limit:      cfg.MonthlyBudgetUSD,
+		warnRatio:  cfg.BudgetWarnRatio,
+		mode:       cfg.BudgetExhaustedMode,
+		ttl:        time.Duration(cfg.BudgetCacheSec) * time.Second,
+		spend:      spend,
+		promptRate: cfg.PromptPricePerMTok,
+		outputRate: cfg.CompletionPricePerMTok,
+	}, nil
+}
+
+// Mode reports what happens once the budget is spent.
+func (b *Budget) Mode() string {
+	return b.mode
+}
+
+// Cost prices a primary provider response.
+func (b *Budget) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*b.promptRate + float64(usage.CompletionTokens)*b.outputRate) / 1e6
+}
+
+// Add counts a cost towards the cached spend until the next refresh.
+func (b *Budget) Add(cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent += cost
+	if b.refreshing {
+		b.addedSince += cost
+	}
+}
+
+// State reports where spend stands against the limit for the current month.
+// The warning and the switch to exhausted are logged once per month. One
+// caller at a time refreshes the spend; the rest use the cached value rather
+// than wait for the query.
+func (b *Budget) State(ctx context.Context) BudgetState {
+	if b.limit <= 0 {
+		return BudgetOK
+	}
+
+	now := time.Now().UTC()
+	period := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	b.mu.Lock()
+	if !period.Equal(b.period) {
+		b.period = period
+		b.spent = 0
+		b.refreshedAt = time.Time{}
+		b.refreshing = false
+		b.warned = false
+		b.exhausted = false
+	}
+	refresh := !b.refreshing && now.Sub(b.refreshedAt) >= b.ttl
+	if refresh {
+		// Retry failed refreshes after the TTL too, rather than on every request.
+		b.refreshing = true
+		b.refreshedAt = now
+		b.addedSince = 0
+	}
+	b.mu.Unlock()
+
+	if refresh {
+		b.refresh(ctx, period)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.spent >= b.limit:
+		if !b.exhausted {
+			b.exhausted = true
+			fmt.Printf("LLM budget exhausted: $%.2f of $%.2f spent this month, mode %s\n", b.spent, b.limit, b.mode)
+		}
+		return BudgetExhausted
+	case b.spent >= b.limit*b.warnRatio:
+		if !b.warned {
+			b.warned = true
+			fmt.Printf("LLM budget warning: $%.2f of $%.2f spent this month\n", b.spent, b.limit)
+		}
+		return BudgetWarning
+	default:
+		return BudgetOK
+	}
+}
+
+// refresh reads the spend for period. Costs added while the query runs are
+// kept on top of what it returns, as they are after any refresh. A result
+// for a month that has since ended is dropped.
+func (b *Budget) refresh(ctx context.Context, period time.Time) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	spent, err := b.spend(queryCtx, period)
+	cancel()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !period.Equal(b.period) {
+		return
+	}
+	b.refreshing = false
+	if err != nil {
+		fmt.Printf("Failed to refresh LLM spend, using cached value: %v\n", err)
+		return
+	}
+	b.spent = spent + b.addedSince
+}
+
+// BudgetLLMClient sends requests to the primary provider while the budget
+// allows, then either to the fallback provider or nowhere. Responses from the
+// primary provider are priced and counted against the budget. The fallback
+// also takes over while the primary model is unavailable.
+type BudgetLLMClient struct {
+	primary  LLMClient
+	fallback LLMClient
+	budget   *Budget
+}
+
+// NewBudgetLLMClient wraps primary. fallback may be nil, in which case
+// requests are rejected once the budget is exhausted.
+func NewBudgetLLMClient(primary LLMClient, fallback LLMClient, budget *Budget) *BudgetLLMClient {
+	return &BudgetLLMClient{
+		primary:  primary,
+		fallback: fallback,
+		budget:   budget,
+	}
+}
+
+func (c *BudgetLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *BudgetLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	client, degraded, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+	if !degraded && c.fallback != nil && errors.Is(err, ErrModelUnavailable) {
+		resp, err = c.fallback.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+		return c.accountUnavailable(resp), err
+	}
+	c.accountInvalid(err, degraded)
+	return c.account(resp, degraded), err
+}
+
+// GenerateResponseStream streams when the chosen provider supports it.
+func (c *BudgetLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	client, degraded, err := c.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := generateStream(ctx, client, prompt, boardState, opts, onChunk)
+	if !degraded && c.fallback != nil && errors.Is(err, ErrModelUnavailable) {
+		resp, err = generateStream(ctx, c.fallback, prompt, boardState, opts, onChunk)
+		return c.accountUnavailable(resp), err
+	}
+	c.accountInvalid(err, degraded)
+	return c.account(resp, degraded), err
+}
+
+// generateStream streams from client when it supports streaming.
+func generateStream(ctx context.Context, client LLMClient, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	if streamer, ok := client.(StreamingLLMClient); ok {
+		return streamer.GenerateResponseStream(ctx, prompt, boardState, opts, onChunk)
+	}
+	return client.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+}
+
+// Ping checks the fallback client too, so a broken fallback is found before
+// the budget runs out.
+func (c *BudgetLLMClient) Ping(ctx context.Context) error {
+	if err := c.primary.Ping(ctx); err != nil {
+		return err
+	}
+	if c.fallback != nil {
+		if err := c.fallback.Ping(ctx); err != nil {
+			return fmt.Errorf("budget fallback: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *BudgetLLMClient) Close() error {
+	err := c.primary.Close()
+	if c.fallback != nil {
+		if fallbackErr := c.fallback.Close(); err == nil {
+			err = fallbackErr
+		}
+	}
+	return err
+}
+
+func (c *BudgetLLMClient) route(ctx context.Context) (LLMClient, bool, error) {
+	if c.budget.State(ctx) != BudgetExhausted {
+		return c.primary, false, nil
+	}
+	if c.fallback == nil {
+		return nil, false, ErrBudgetExhausted
+	}
+	return c.fallback, true, nil
+}
+
+// accountInvalid charges for output that never parsed: the tokens were spent
+// all the same.
+func (c *BudgetLLMClient) accountInvalid(err error, degraded bool) {
+	var invalid *InvalidOutputError
+	if errors.As(err, &invalid) && invalid.Response != nil {
+		c.account(invalid.Response, degraded)
+	}
+}
+
+func (c *BudgetLLMClient) account(resp *LLMResponse, degraded bool) *LLMResponse {
+	if resp == nil {
+		return nil
+	}
+	if degraded {
+		// The fallback runs locally and isn't charged.
+		resp.Warning = degradedWarning
+		return resp
+	}
+	resp.CostUSD = c.budget.Cost(resp.Usage)
+	c.budget.Add(resp.CostUSD)
+	return resp
+}
+
+// accountUnavailable marks a response the fallback served in place of an
+// unavailable primary model. Like any fallback response, it isn't charged.
+func (c *BudgetLLMClient) accountUnavailable(resp *LLMResponse) *LLMResponse {
+	if resp == nil {
+		return nil
+	}
+	resp.Warning = modelUnavailableWarning
+	return resp
+}
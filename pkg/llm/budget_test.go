@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"draw/pkg/config"
+)
+
+func newTestBudget(t *testing.T, spend SpendFunc) *Budget {
+	t.Helper()
+	budget, err := NewBudget(&config.LLMConfig{
+		MonthlyBudgetUSD:    100,
+		BudgetWarnRatio:     0.8,
+		BudgetExhaustedMode: BudgetModeDowngrade,
+		BudgetCacheSec:      3600,
+	}, spend)
+	if err != nil {
+		t.Fatalf("NewBudget: %v", err)
+	}
+	return budget
+}
+
+func TestBudgetState(t *testing.T) {
+	tests := []struct {
+		name  string
+		spent float64
+		added float64
+		want  BudgetState
+	}{
+		{name: "under the warning", spent: 10, want: BudgetOK},
+		{name: "past the warning", spent: 85, want: BudgetWarning},
+		{name: "spent", spent: 100, want: BudgetExhausted},
+		{name: "spent by local costs", spent: 70, added: 40, want: BudgetExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := newTestBudget(t, func(ctx context.Context, since time.Time) (float64, error) {
+				return tt.spent, nil
+			})
+			budget.State(context.Background())
+			budget.Add(tt.added)
+			if got := budget.State(context.Background()); got != tt.want {
+				t.Errorf("got state %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetStateKeepsCachedSpendOnError(t *testing.T) {
+	var calls atomic.Int32
+	budget := newTestBudget(t, func(ctx context.Context, since time.Time) (float64, error) {
+		if calls.Add(1) == 1 {
+			return 90, nil
+		}
+		return 0, errors.New("database unavailable")
+	})
+	if got := budget.State(context.Background()); got != BudgetWarning {
+		t.Fatalf("got state %d, want warning", got)
+	}
+	// Force the next call to refresh.
+	budget.mu.Lock()
+	budget.refreshedAt = time.Time{}
+	budget.mu.Unlock()
+	if got := budget.State(context.Background()); got != BudgetWarning {
+		t.Errorf("got state %d after a failed refresh, want the cached warning", got)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("spend queried %d times, want 2", calls.Load())
+	}
+}
+
+func TestBudgetStateDoesNotWaitForRefresh(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls atomic.Int32
+	budget := newTestBudget(t, func(ctx context.Context, since time.Time) (float64, error) {
+		calls.Add(1)
+		close(started)
+		<-release
+		return 50, nil
+	})
+
+	refreshed := make(chan BudgetState)
+	go func() {
+		refreshed <- budget.State(context.Background())
+	}()
+	<-started
+
+	// While the query runs, other callers use the cached spend, and costs
+	// can still be added.
+	done := make(chan BudgetState)
+	go func() {
+		budget.Add(40)
+		done <- budget.State(context.Background())
+	}()
+	select {
+	case got := <-done:
+		if got != BudgetOK {
+			t.Errorf("got state %d during the refresh, want OK from the cached spend", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("State waited for another caller's refresh")
+	}
+
+	close(release)
+	// The refreshed spend keeps the cost added meanwhile: 50 + 40.
+	if got := <-refreshed; got != BudgetWarning {
+		t.Errorf("got state %d after the refresh, want a warning with the added cost counted", got)
+	}
+	budget.mu.Lock()
+	spent := budget.spent
+	budget.mu.Unlock()
+	if spent != 90 {
+		t.Errorf("spent = %v, want 90", spent)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("spend queried %d times, want 1", calls.Load())
+	}
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachingLLMClient answers repeated requests from memory. A request repeats
+// an earlier one when its instruction matches after normalizing case and
+// whitespace, and its board state and generation options match exactly.
+// Only successful responses are cached. The least recently used entry is
+// evicted once the cache is full, and entries expire after the TTL.
+type CachingLLMClient struct {
+	inner LLMClient
+	size  int
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+	hits    uint64
+	misses  uint64
+}
+
+type cacheEntry struct {
+	key      string
+	response LLMResponse
+	expires  time.Time
+}
+
+// CacheStats counts cache lookups since the client was created.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// NewCachingLLMClient wraps inner with a cache of up to size responses,
+// each kept for ttl. A ttl of 0 keeps entries until they are evicted.
+func NewCachingLLMClient(inner LLMClient, size int, ttl time.Duration) *CachingLLMClient {
+	if size < 1 {
+		size = 1
+	}
+	return &CachingLLMClient{
+		inner:   inner,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *CachingLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *CachingLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	return c.generate(prompt, boardState, opts, func() (*LLMResponse, error) {
+		return c.inner.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+	})
+}
+
+// GenerateResponseStream streams on a miss. A hit is returned at once
+// without any chunks.
+func (c *CachingLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	return c.generate(prompt, boardState, opts, func() (*LLMResponse, error) {
+		return generateStream(ctx, c.inner, prompt, boardState, opts, onChunk)
+	})
+}
+
+func (c *CachingLLMClient) generate(prompt string, boardState string, opts GenerateOptions, call func() (*LLMResponse, error)) (*LLMResponse, error) {
+	key, ok := cacheKey(prompt, boardState, opts)
+	if !ok {
+		return call()
+	}
+	if resp, ok := c.get(key); ok {
+		return resp, nil
+	}
+	resp, err := call()
+	if err == nil {
+		c.put(key, resp)
+	}
+	return resp, err
+}
+
+// cacheKey hashes what a response depends on. Retries that carry repair
+// instructions are never cached: they exist because the first answer was
+// wrong.
+func cacheKey(prompt string, boardState string, opts GenerateOptions) (string, bool) {
+	if len(opts.Repair) > 0 || opts.Malformed != nil {
+		return "", false
+	}
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	h.Write([]byte(strings.Join(strings.Fields(strings.ToLower(prompt)), " ")))
+	h.Write([]byte{0})
+	h.Write([]byte(boardState))
+	h.Write([]byte{0})
+	h.Write(options)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func (c *CachingLLMClient) get(key string) (*LLMResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if ok && c.ttl > 0 && time.Now().After(element.Value.(*cacheEntry).expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+
+	// Hits cost nothing, and the caller gets a copy of its own to change.
+	resp := element.Value.(*cacheEntry).response
+	resp.Cached = true
+	resp.CostUSD = 0
+	return &resp, true
+}
+
+func (c *CachingLLMClient) put(key string, resp *LLMResponse) {
+	// What callers add to a response after the fact isn't part of the
+	// answer; ParsedAction is parsed again for each hit.
+	stored := *resp
+	stored.ParsedAction = nil
+	stored.ValidationWarnings = nil
+	stored.FirstByte = 0
+	stored.Latency = 0
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, response: stored, expires: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Stats reports the hit and miss counts and how many entries are cached.
+func (c *CachingLLMClient) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len()}
+}
+
+func (c *CachingLLMClient) Ping(ctx context.Context) error {
+	return c.inner.Ping(ctx)
+}
+
+func (c *CachingLLMClient) Close() error {
+	return c.inner.Close()
+}
@@ -2,43 +2,355 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"draw/pkg/config"
+	"draw/pkg/llm/prompts"
 )
 
 type LLMResponse struct {
-	Response  string    `json:"response"`
-	Timestamp time.Time `json:"timestamp"`
+	Response          string    `json:"response"`
+	Timestamp         time.Time `json:"timestamp"`
+	Seed              *int64    `json:"seed,omitempty"`
+	SystemFingerprint string    `json:"systemFingerprint,omitempty"`
+	// Warning tells clients the response came from a degraded mode, such as
+	// the fallback provider used once the LLM budget is exhausted.
+	Warning string `json:"warning,omitempty"`
+	// DroppedSections names the prompt sections left out to stay within the
+	// prompt token budget, for debugging answers that lacked context.
+	DroppedSections []string `json:"droppedSections,omitempty"`
+	// CompactionLevel is how far the board state was compacted to fit the
+	// model's context; see CompactBoard.
+	CompactionLevel int `json:"compactionLevel,omitempty"`
+	// FastPath marks responses built without the model, for commands
+	// simple enough to resolve directly against the board.
+	FastPath bool `json:"fastPath,omitempty"`
+	// Cached marks responses repeated from the response cache rather than
+	// generated for this request; see CachingLLMClient.
+	Cached bool `json:"cached,omitempty"`
+	// ValidationWarnings are the warning-level issues found in the action,
+	// which was applied regardless.
+	ValidationWarnings []ValidationIssue `json:"validationWarnings,omitempty"`
+	// RequiresConfirmation marks an action that deletes or rewrites too much
+	// of the board to apply unasked; ConfirmationReason says why, and
+	// ConfirmationToken is what the client sends back to apply it anyway.
+	RequiresConfirmation bool   `json:"requiresConfirmation,omitempty"`
+	ConfirmationReason   string `json:"confirmationReason,omitempty"`
+	ConfirmationToken    string `json:"confirmationToken,omitempty"`
+	// ParsedAction is Response parsed as a whiteboard action, or nil when it
+	// doesn't parse. Clients built by NewLLMClient set it; the voice
+	// pipeline replaces it with the action as resolved against the board.
+	ParsedAction *WhiteboardAction `json:"-"`
+	// Inverse undoes ParsedAction on the board it was resolved against, or
+	// is nil when it can't be undone; see whiteboard.Invert.
+	Inverse *WhiteboardAction `json:"-"`
+
+	Provider string  `json:"-"`
+	Model    string  `json:"-"`
+	Usage    Usage   `json:"-"`
+	CostUSD  float64 `json:"-"`
+	// PromptVersion names the system prompt the response was generated
+	// with; see prompts.Registry.
+	PromptVersion string `json:"-"`
+	// QueueWait is how long the request waited for the provider.
+	QueueWait time.Duration `json:"-"`
+	// FirstByte is how long a streamed response took to produce its first
+	// chunk, queue wait excluded. It is set by the voice pipeline.
+	FirstByte time.Duration `json:"-"`
+	// Latency is how long the instruction took end to end, every attempt
+	// included. It is set by the voice pipeline.
+	Latency time.Duration `json:"-"`
+}
+
+// Usage is the token count a provider reported for one response.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// GenerateOptions tweaks a single generation request. Zero values keep the
+// client's defaults.
+type GenerateOptions struct {
+	// Seed makes sampling reproducible on providers that support it.
+	Seed *int64
+	// Temperature overrides the client's default temperature. When a seed is
+	// set and no temperature is given, 0 is used.
+	Temperature *float64
+	// Referents are board elements the instruction most likely refers to,
+	// resolved before prompting so the model doesn't have to guess.
+	Referents []Referent
+	// Substitutions give concrete values for relative phrases in the
+	// instruction, e.g. "today" -> "15.10.2026".
+	Substitutions []Substitution
+	// PromptTokenBudget caps the estimated size of the user prompt; optional
+	// sections are dropped by priority to fit. 0 means no cap.
+	PromptTokenBudget int
+	// BoardStateMaxBytes caps the size of the board state JSON; the board is
+	// compacted as far as needed to fit. 0 means no cap.
+	BoardStateMaxBytes int
+	// ContextWindow is the model's context size in tokens. When set, the
+	// board state is compacted as far as needed for the prompt to fit it.
+	ContextWindow int
+	// Repair lists what was wrong with the previous attempt's action, so a
+	// retry can fix it; see RepairInstructions.
+	Repair []ValidationIssue
+	// Malformed is the previous answer when it wasn't valid JSON, so the
+	// model can correct it; see RepairLLMClient.
+	Malformed *MalformedOutput
+	// History holds earlier turns of the conversation, oldest first, so
+	// follow-ups such as "make it bigger" can refer back. Providers send it
+	// as chat messages ahead of the prompt; see Conversations.
+	History []Turn
+	// Mode picks the system prompt; empty means prompts.ModeDiagram.
+	Mode prompts.Mode
+}
+
+// Turn is one earlier instruction and the model's answer to it.
+type Turn struct {
+	Instruction string
+	Response    string
+}
+
+// Referent maps a phrase from the instruction to a board element ID.
+type Referent struct {
+	Phrase    string `json:"phrase"`
+	ElementID string `json:"id"`
+}
+
+// Substitution maps a phrase from the instruction to the text it stands for.
+type Substitution struct {
+	Phrase string `json:"phrase"`
+	Value  string `json:"value"`
+}
+
+// Priorities of the optional prompt sections. Substitutions outrank
+// referents: a wrong date is worse than a guessed element. A retry's repair
+// list outranks both, since without it the retry repeats the mistake.
+const (
+	priorityReferents     = 10
+	prioritySubstitutions = 20
+	priorityRepair        = 30
+)
+
+// BuildPrompt assembles the user prompt, adding the options that give the
+// model extra context as sections. The board state is compacted one level at
+// a time until every section fits and the board state is within
+// BoardStateMaxBytes; only at the last level are optional sections dropped.
+func (o GenerateOptions) BuildPrompt(text string, boardState string) prompts.Prompt {
+	budget := o.PromptTokenBudget
+	if o.ContextWindow > 0 {
+		if window := contextBudget(o.ContextWindow, o.Mode); budget <= 0 || window < budget {
+			budget = window
+		}
+	}
+
+	prompt := o.buildPrompt(text, boardState, budget)
+	if o.fits(prompt, boardState, budget) {
+		return prompt
+	}
+	var board []Element
+	if err := json.Unmarshal([]byte(boardState), &board); err != nil {
+		return prompt
+	}
+	referenced := make(map[string]bool, len(o.Referents))
+	for _, r := range o.Referents {
+		referenced[r.ElementID] = true
+	}
+	for level := CompactionFull + 1; level <= CompactionSkeleton; level++ {
+		compacted, err := json.Marshal(CompactBoard(board, level, referenced))
+		if err != nil {
+			return prompt
+		}
+		prompt = o.buildPrompt(text, string(compacted), budget)
+		prompt.CompactionLevel = level
+		if o.fits(prompt, string(compacted), budget) {
+			break
+		}
+	}
+	return prompt
+}
+
+// fits reports whether prompt, built with boardState, needs no further
+// compaction.
+func (o GenerateOptions) fits(prompt prompts.Prompt, boardState string, budget int) bool {
+	if o.BoardStateMaxBytes > 0 && len(boardState) > o.BoardStateMaxBytes {
+		return false
+	}
+	return budget <= 0 || (prompt.Tokens <= budget && len(prompt.Dropped) == 0)
+}
+
+func (o GenerateOptions) buildPrompt(text string, boardState string, budget int) prompts.Prompt {
+	referents := make([]string, 0, len(o.Referents))
+	for _, r := range o.Referents {
+		referents = append(referents, fmt.Sprintf("%q -> %s", r.Phrase, r.ElementID))
+	}
+	substitutions := make([]string, 0, len(o.Substitutions))
+	for _, sub := range o.Substitutions {
+		substitutions = append(substitutions, fmt.Sprintf("%q = %s", sub.Phrase, sub.Value))
+	}
+	return prompts.NewWhiteboardPrompt(text, boardState).
+		Add(prompts.PromptSection{Name: "referents", Title: "LIKELY REFERENTS", Content: strings.Join(referents, "\n"), Priority: priorityReferents}).
+		Add(prompts.PromptSection{Name: "substitutions", Title: "SUBSTITUTIONS (use these exact values)", Content: strings.Join(substitutions, "\n"), Priority: prioritySubstitutions}).
+		Add(prompts.PromptSection{Name: "repair", Title: "YOUR PREVIOUS ANSWER WAS REJECTED (fix these problems)", Content: RepairInstructions(o.Repair), Priority: priorityRepair}).
+		Add(prompts.PromptSection{Name: "malformed", Title: "YOUR PREVIOUS ANSWER WAS NOT A VALID ACTION (return only the corrected JSON)", Content: o.Malformed.instructions(), Priority: priorityRepair}).
+		Build(budget)
+}
+
+// temperature resolves the temperature to send given the client default.
+func (o GenerateOptions) temperature(defaultValue float64) float64 {
+	if o.Temperature != nil {
+		return *o.Temperature
+	}
+	if o.Seed != nil {
+		return 0
+	}
+	return defaultValue
 }
 
 type LLMClient interface {
 	GenerateResponse(ctx context.Context, text string, boardState string) (*LLMResponse, error)
+	GenerateResponseWithOptions(ctx context.Context, text string, boardState string, opts GenerateOptions) (*LLMResponse, error)
+	// Ping checks that the provider is reachable, accepts the configured
+	// credentials and serves the configured model, as cheaply as the
+	// provider allows.
+	Ping(ctx context.Context) error
 	Close() error
 }
 
+// StreamingLLMClient is implemented by clients that can report partial model
+// output while a response is still being generated.
+type StreamingLLMClient interface {
+	LLMClient
+	GenerateResponseStream(ctx context.Context, text string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error)
+}
+
 type LLMProvider string
 
 const (
-	LLMProviderOllama LLMProvider = "ollama"
-	LLMProviderNvidia LLMProvider = "nvidia"
-	LLMProviderOpenAI LLMProvider = "openai"
+	LLMProviderOllama  LLMProvider = "ollama"
+	LLMProviderNvidia  LLMProvider = "nvidia"
+	LLMProviderOpenAI  LLMProvider = "openai"
+	LLMProviderGemini  LLMProvider = "gemini"
+	LLMProviderBedrock LLMProvider = "bedrock"
+	// LLMProviderGenericOpenAI is any hosted server that speaks the OpenAI
+	// chat completions protocol; see NewGenericOpenAILLMClient.
+	LLMProviderGenericOpenAI LLMProvider = "generic-openai"
 )
 
-func NewLLMClient(cfg *config.LLMConfig) (LLMClient, error) {
+// ErrClientClosed is returned for requests made to, or still queued in, a
+// client that has been closed.
+var ErrClientClosed = errors.New("llm client is closed")
+
+// ErrProviderThrottled is returned when the provider refuses a request
+// because of rate limits or capacity. The request may be retried later.
+var ErrProviderThrottled = errors.New("provider_throttled: the LLM provider is rate limiting requests")
+
+// ErrProviderUnavailable is returned when the provider keeps failing or
+// can't be reached. Another provider may still be able to serve the request.
+var ErrProviderUnavailable = errors.New("provider_unavailable: the LLM provider is failing or unreachable")
+
+// NewLLMClient creates the configured provider client. When budget is not nil,
+// requests are checked against it before reaching the provider. Failover
+// providers take over, in order, while the primary one is failing. models
+// tracks retired models across clients, limiter caps requests per user and
+// registry supplies the system prompt; each may be nil. hooks are told about
+// every call to a provider, failover and fallback providers included.
+func NewLLMClient(cfg *config.LLMConfig, budget *Budget, models *Models, limiter *RateLimiter, registry *prompts.Registry, hooks ...GenerateHook) (LLMClient, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("llm config is required")
 	}
 
+	settings, pool := NewRequestSettings(cfg), NewWorkerPool(cfg)
+	settings.Prompts = registry
+	provider, err := newProviderClient(cfg, models, settings, pool)
+	if err != nil {
+		return nil, err
+	}
+	provider = withHooks(provider, LLMProvider(cfg.Provider), cfg.Model, hooks)
+	var client LLMClient = NewRepairLLMClient(provider, cfg.RepairAttempts)
+	if budget != nil {
+		var fallback LLMClient
+		if budget.Mode() == BudgetModeDowngrade {
+			ollama, err := NewOllamaLLMClient(cfg.FallbackHost, cfg.FallbackModel, settings, pool)
+			if err != nil {
+				client.Close()
+				return nil, fmt.Errorf("failed to create fallback LLM client: %w", err)
+			}
+			fallback = NewRepairLLMClient(withHooks(ollama, LLMProviderOllama, cfg.FallbackModel, hooks), cfg.RepairAttempts)
+		}
+		client = NewBudgetLLMClient(client, fallback, budget)
+	}
+	if len(cfg.Failover) > 0 {
+		failover, err := newFailoverClients(cfg, settings, pool, hooks)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		client = NewFallbackLLMClient(client, failover...)
+	}
+	if cfg.CacheSize > 0 {
+		client = NewCachingLLMClient(client, cfg.CacheSize, time.Duration(cfg.CacheTTLSec)*time.Second)
+	}
+	client = NewPriorityLLMClient(client, cfg.InteractiveQueueSize, cfg.BackgroundQueueSize, cfg.Concurrency)
+	if limiter != nil {
+		// Outermost, so a refused request never takes a place in a queue.
+		client = NewRateLimitedLLMClient(client, limiter)
+	}
+	return client, nil
+}
+
+// newFailoverClients creates the clients listed in cfg.Failover. Only ollama
+// is supported there, on the fallback host and model: the other providers
+// would need credentials of their own.
+func newFailoverClients(cfg *config.LLMConfig, settings RequestSettings, pool WorkerPool, hooks []GenerateHook) ([]LLMClient, error) {
+	clients := make([]LLMClient, 0, len(cfg.Failover))
+	for _, name := range cfg.Failover {
+		if LLMProvider(name) != LLMProviderOllama {
+			for _, client := range clients {
+				client.Close()
+			}
+			return nil, fmt.Errorf("unsupported failover LLM provider %q: only ollama can follow the primary provider", name)
+		}
+		ollama, err := NewOllamaLLMClient(cfg.FallbackHost, cfg.FallbackModel, settings, pool)
+		if err != nil {
+			for _, client := range clients {
+				client.Close()
+			}
+			return nil, fmt.Errorf("failed to create failover LLM client: %w", err)
+		}
+		clients = append(clients, NewRepairLLMClient(withHooks(ollama, LLMProviderOllama, cfg.FallbackModel, hooks), cfg.RepairAttempts))
+	}
+	return clients, nil
+}
+
+// withHooks wraps a provider client in a HookedLLMClient, unless there are
+// no hooks to call.
+func withHooks(client LLMClient, provider LLMProvider, model string, hooks []GenerateHook) LLMClient {
+	if len(hooks) == 0 {
+		return client
+	}
+	return NewHookedLLMClient(client, provider, model, hooks...)
+}
+
+func newProviderClient(cfg *config.LLMConfig, models *Models, settings RequestSettings, pool WorkerPool) (LLMClient, error) {
 	switch LLMProvider(cfg.Provider) {
 	case LLMProviderOllama:
 		fmt.Println("Creating Ollama LLM client")
-		return NewOllamaLLMClient(cfg.Host, cfg.Model)
+		return NewOllamaLLMClient(cfg.Host, cfg.Model, settings, pool)
 	case LLMProviderNvidia:
-		return NewNvidiaLLMClient(cfg.Host, cfg.Model, cfg.APIKey)
+		return NewNvidiaLLMClient(cfg.Host, cfg.Model, cfg.APIKey, models, cfg.ProviderAttempts, settings, pool)
 	case LLMProviderOpenAI:
-		return NewOpenAILLMClient(cfg.Host, cfg.Model, cfg.APIKey)
+		return NewOpenAILLMClient(cfg.Host, cfg.Model, cfg.APIKey, settings, pool)
+	case LLMProviderGemini:
+		return NewGeminiLLMClient(cfg.Host, cfg.Model, cfg.APIKey, models, settings, pool)
+	case LLMProviderBedrock:
+		return NewBedrockLLMClient(cfg.Host, cfg.AWS.Region, cfg.Model, cfg.AWS.AccessKey, cfg.AWS.SecretKey, models, settings, pool)
+	case LLMProviderGenericOpenAI:
+		return NewGenericOpenAILLMClient(cfg.Host, cfg.Model, cfg.APIKey, cfg.APIKeyHeader, cfg.ExtraHeaders, settings, pool)
 	default:
 		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.Provider)
 	}
@@ -0,0 +1,661 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"draw/pkg/config"
+	"draw/pkg/llm/prompts"
+)
+
+// TestCloseWhileGenerating closes clients while callers are queueing
+// requests on them. Run with -race: every call must return, none may panic,
+// and calls after Close must be refused with ErrClientClosed.
+func TestCloseWhileGenerating(t *testing.T) {
+	pool := WorkerPool{Concurrency: 2, QueueSize: 4}
+	clients := map[string]func(t *testing.T) LLMClient{
+		"ollama": func(t *testing.T) LLMClient {
+			server := newFakeProvider(t, slowly(ollamaChatAnswer(`{"action":"clear"}`)))
+			client, err := NewOllamaLLMClient(server.URL, "llama3.2", testSettings, pool)
+			if err != nil {
+				t.Fatalf("NewOllamaLLMClient: %v", err)
+			}
+			return client
+		},
+		"nvidia": func(t *testing.T) LLMClient {
+			server := newFakeProvider(t, slowly(nvidiaChatAnswer(`{"action":"clear"}`)))
+			client, err := NewNvidiaLLMClient(server.URL, "meta/llama-3.1-8b-instruct", "key", nil, 1, testSettings, pool)
+			if err != nil {
+				t.Fatalf("NewNvidiaLLMClient: %v", err)
+			}
+			return client
+		},
+		"openai": func(t *testing.T) LLMClient {
+			server := newFakeProvider(t, slowly(openAIChatAnswer(`{"action":"clear"}`)))
+			client, err := NewOpenAILLMClient(server.URL, "served-model", "", testSettings, pool)
+			if err != nil {
+				t.Fatalf("NewOpenAILLMClient: %v", err)
+			}
+			return client
+		},
+	}
+
+	for name, newClient := range clients {
+		t.Run(name, func(t *testing.T) {
+			client := newClient(t)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 64)
+			for i := 0; i < cap(errs); i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+					errs <- err
+				}()
+			}
+			time.Sleep(15 * time.Millisecond)
+			var closers sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				closers.Add(1)
+				go func() {
+					defer closers.Done()
+					client.Close()
+				}()
+			}
+
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				closers.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("calls were still waiting 5s after Close")
+			}
+			close(errs)
+
+			closed := 0
+			for err := range errs {
+				switch {
+				case err == nil, errors.Is(err, ErrQueueFull), errors.Is(err, context.Canceled):
+				case errors.Is(err, ErrClientClosed):
+					closed++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if closed == 0 {
+				t.Errorf("no call was refused by the closed client")
+			}
+
+			if _, err := client.GenerateResponse(context.Background(), "clear the board", "[]"); !errors.Is(err, ErrClientClosed) {
+				t.Errorf("after Close: error = %v, want ErrClientClosed", err)
+			}
+		})
+	}
+}
+
+// slowly delays answer long enough for requests to queue up behind it.
+func slowly(answer http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			answer(w, r)
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// withFingerprint adds a system_fingerprint to an OpenAI-style answer.
+func withFingerprint(answer http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		answer(rec, r)
+		var body map[string]any
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		body["system_fingerprint"] = "fp_1"
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+func TestSeedPlumbing(t *testing.T) {
+	settings := testSettings
+	settings.Temperature = 0.5
+	seed := int64(42)
+	warm := 0.7
+
+	providers := []struct {
+		name string
+		// newClient returns a client for a fake provider and the request
+		// object holding the sampling fields.
+		newClient   func(t *testing.T) (LLMClient, func(t *testing.T) map[string]any)
+		fingerprint string
+	}{
+		{
+			name: "ollama",
+			newClient: func(t *testing.T) (LLMClient, func(t *testing.T) map[string]any) {
+				server := newFakeProvider(t, ollamaChatAnswer(`{"action":"clear"}`))
+				client, err := NewOllamaLLMClient(server.URL, "llama3.2", settings, WorkerPool{})
+				if err != nil {
+					t.Fatalf("NewOllamaLLMClient: %v", err)
+				}
+				return client, func(t *testing.T) map[string]any {
+					options, _ := server.lastBody(t)["options"].(map[string]any)
+					return options
+				}
+			},
+		},
+		{
+			name: "nvidia",
+			newClient: func(t *testing.T) (LLMClient, func(t *testing.T) map[string]any) {
+				server := newFakeProvider(t, withFingerprint(nvidiaChatAnswer(`{"action":"clear"}`)))
+				client, err := NewNvidiaLLMClient(server.URL, "meta/llama-3.1-8b-instruct", "key", nil, 1, settings, WorkerPool{})
+				if err != nil {
+					t.Fatalf("NewNvidiaLLMClient: %v", err)
+				}
+				return client, server.lastBody
+			},
+			fingerprint: "fp_1",
+		},
+		{
+			name: "openai",
+			newClient: func(t *testing.T) (LLMClient, func(t *testing.T) map[string]any) {
+				server := newFakeProvider(t, withFingerprint(openAIChatAnswer(`{"action":"clear"}`)))
+				client, err := NewOpenAILLMClient(server.URL+"/v1", "served-model", "secret", settings, WorkerPool{})
+				if err != nil {
+					t.Fatalf("NewOpenAILLMClient: %v", err)
+				}
+				return client, server.lastBody
+			},
+			fingerprint: "fp_1",
+		},
+	}
+	tests := []struct {
+		name        string
+		opts        GenerateOptions
+		temperature float64
+	}{
+		{name: "no seed", temperature: 0.5},
+		{name: "seed", opts: GenerateOptions{Seed: &seed}, temperature: 0},
+		{name: "seed and temperature", opts: GenerateOptions{Seed: &seed, Temperature: &warm}, temperature: 0.7},
+		{name: "temperature", opts: GenerateOptions{Temperature: &warm}, temperature: 0.7},
+	}
+	for _, provider := range providers {
+		for _, tt := range tests {
+			t.Run(provider.name+"/"+tt.name, func(t *testing.T) {
+				client, sent := provider.newClient(t)
+				defer client.Close()
+				resp, err := client.GenerateResponseWithOptions(context.Background(), "clear the board", "[]", tt.opts)
+				if err != nil {
+					t.Fatalf("GenerateResponseWithOptions: %v", err)
+				}
+
+				fields := sent(t)
+				if fields == nil {
+					t.Fatalf("request has no sampling fields")
+				}
+				gotSeed, ok := fields["seed"]
+				if tt.opts.Seed == nil {
+					if ok {
+						t.Errorf("seed = %v, want none sent", gotSeed)
+					}
+				} else if gotSeed != float64(seed) {
+					t.Errorf("seed = %v, want %d", gotSeed, seed)
+				}
+				if fields["temperature"] != tt.temperature {
+					t.Errorf("temperature = %v, want %v", fields["temperature"], tt.temperature)
+				}
+
+				if (resp.Seed == nil) != (tt.opts.Seed == nil) || (resp.Seed != nil && *resp.Seed != seed) {
+					t.Errorf("response seed = %v, want %v", resp.Seed, tt.opts.Seed)
+				}
+				if resp.SystemFingerprint != provider.fingerprint {
+					t.Errorf("system fingerprint = %q, want %q", resp.SystemFingerprint, provider.fingerprint)
+				}
+			})
+		}
+	}
+}
+
+// TestLocalOnlyDeployment checks what a deployment that must keep board
+// content local relies on: with LLM_PROVIDER=ollama, the budget fallback and
+// the failover providers are local Ollama too, so no path leads to an
+// external API.
+func TestLocalOnlyDeployment(t *testing.T) {
+	failing := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}
+	tests := []struct {
+		name      string
+		primary   http.HandlerFunc
+		spent     float64
+		failover  []string
+		fromLocal int
+		fromSpare int
+	}{
+		{name: "within budget", primary: ollamaChatAnswer(`{"action":"clear"}`), fromLocal: 1},
+		{name: "budget exhausted", primary: ollamaChatAnswer(`{"action":"clear"}`), spent: 100, fromSpare: 1},
+		{name: "primary failing", primary: failing, failover: []string{"ollama"}, fromLocal: 1, fromSpare: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := newFakeProvider(t, tt.primary)
+			spare := newFakeProvider(t, ollamaChatAnswer(`{"action":"clear"}`))
+			cfg := &config.LLMConfig{
+				Provider:             "ollama",
+				Failover:             tt.failover,
+				Host:                 local.URL,
+				Model:                "llama3.2",
+				FallbackHost:         spare.URL,
+				FallbackModel:        "llama3.2",
+				InteractiveQueueSize: 4,
+				BackgroundQueueSize:  4,
+				Concurrency:          1,
+				MaxTokens:            100,
+				RequestTimeoutSec:    5,
+			}
+			budget := newTestBudget(t, func(ctx context.Context, since time.Time) (float64, error) {
+				return tt.spent, nil
+			})
+
+			var mu sync.Mutex
+			var providers []string
+			client, err := NewLLMClient(cfg, budget, nil, nil, nil, func(ctx context.Context, info RequestInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				providers = append(providers, info.Provider)
+			})
+			if err != nil {
+				t.Fatalf("NewLLMClient: %v", err)
+			}
+			defer client.Close()
+
+			if _, err := client.GenerateResponse(context.Background(), "clear the board", "[]"); err != nil {
+				t.Fatalf("GenerateResponse: %v", err)
+			}
+			if local.count() != tt.fromLocal || spare.count() != tt.fromSpare {
+				t.Errorf("primary got %d requests and fallback %d, want %d and %d", local.count(), spare.count(), tt.fromLocal, tt.fromSpare)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(providers) != tt.fromLocal+tt.fromSpare {
+				t.Errorf("hooks saw %d calls, want %d", len(providers), tt.fromLocal+tt.fromSpare)
+			}
+			for _, provider := range providers {
+				if provider != string(LLMProviderOllama) {
+					t.Errorf("called %s, want only ollama", provider)
+				}
+			}
+		})
+	}
+}
+
+// TestDroppedSectionsReported checks that the sections a token budget leaves
+// out of the prompt are reported on the response, for every provider.
+func TestDroppedSectionsReported(t *testing.T) {
+	clients := map[string]func(t *testing.T) (LLMClient, *fakeProvider){
+		"ollama": func(t *testing.T) (LLMClient, *fakeProvider) {
+			server := newFakeProvider(t, ollamaChatAnswer(`{"action":"clear"}`))
+			client, err := NewOllamaLLMClient(server.URL, "llama3.2", testSettings, WorkerPool{})
+			if err != nil {
+				t.Fatalf("NewOllamaLLMClient: %v", err)
+			}
+			return client, server
+		},
+		"nvidia": func(t *testing.T) (LLMClient, *fakeProvider) {
+			server := newFakeProvider(t, nvidiaChatAnswer(`{"action":"clear"}`))
+			client, err := NewNvidiaLLMClient(server.URL, "meta/llama-3.1-8b-instruct", "key", nil, 1, testSettings, WorkerPool{})
+			if err != nil {
+				t.Fatalf("NewNvidiaLLMClient: %v", err)
+			}
+			return client, server
+		},
+		"openai": func(t *testing.T) (LLMClient, *fakeProvider) {
+			server := newFakeProvider(t, openAIChatAnswer(`{"action":"clear"}`))
+			client, err := NewOpenAILLMClient(server.URL+"/v1", "served-model", "", testSettings, WorkerPool{})
+			if err != nil {
+				t.Fatalf("NewOpenAILLMClient: %v", err)
+			}
+			return client, server
+		},
+	}
+	referents := []Referent{{Phrase: "the login box", ElementID: "login"}}
+	substitutions := []Substitution{{Phrase: "tomorrow", Value: "16.10.2026"}}
+
+	for name, newClient := range clients {
+		t.Run(name, func(t *testing.T) {
+			client, server := newClient(t)
+			defer client.Close()
+
+			tests := []struct {
+				name    string
+				budget  int
+				dropped string
+			}{
+				{name: "no budget", budget: 0},
+				// Room for the substitution, which outranks the referent.
+				{name: "tight budget", budget: 50, dropped: "referents"},
+			}
+			for _, tt := range tests {
+				opts := GenerateOptions{Referents: referents, Substitutions: substitutions, PromptTokenBudget: tt.budget}
+				resp, err := client.GenerateResponseWithOptions(context.Background(), "label the login box with tomorrow", "[]", opts)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				if got := strings.Join(resp.DroppedSections, ","); got != tt.dropped {
+					t.Errorf("%s: dropped %q, want %q", tt.name, got, tt.dropped)
+				}
+				body, _ := json.Marshal(server.lastBody(t))
+				if sent := strings.Contains(string(body), "LIKELY REFERENTS"); sent != (tt.dropped == "") {
+					t.Errorf("%s: referents sent: %v, want %v", tt.name, sent, tt.dropped == "")
+				}
+			}
+		})
+	}
+}
+
+// TestParsedActionAttached checks that clients from NewLLMClient attach the
+// parsed action to responses, and fail when the output doesn't parse.
+func TestParsedActionAttached(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		action string
+	}{
+		{name: "fenced", output: "```json\n{\"action\":\"delete\",\"delete_ids\":[\"a\"]}\n```", action: ActionDelete},
+		{name: "trailing commentary", output: `{"action":"clear"} Done, the board is empty.`, action: ActionClear},
+		{name: "malformed", output: `{"action":"add","elements":[`},
+		{name: "unknown action", output: `{"action":"explode"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeProvider(t, ollamaChatAnswer(tt.output))
+			cfg := &config.LLMConfig{
+				Provider:             "ollama",
+				Host:                 server.URL,
+				Model:                "llama3.2",
+				InteractiveQueueSize: 4,
+				BackgroundQueueSize:  4,
+				Concurrency:          1,
+				MaxTokens:            100,
+				RequestTimeoutSec:    5,
+			}
+			client, err := NewLLMClient(cfg, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("NewLLMClient: %v", err)
+			}
+			defer client.Close()
+
+			resp, err := client.GenerateResponse(context.Background(), "do it", "[]")
+			if tt.action == "" {
+				if !errors.Is(err, ErrInvalidLLMOutput) {
+					t.Fatalf("err = %v, want ErrInvalidLLMOutput", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateResponse: %v", err)
+			}
+			if resp.Response != tt.output {
+				t.Errorf("Response = %q, want the raw output %q", resp.Response, tt.output)
+			}
+			if resp.ParsedAction == nil || resp.ParsedAction.Action != tt.action {
+				t.Errorf("ParsedAction = %+v, want a %s", resp.ParsedAction, tt.action)
+			}
+		})
+	}
+}
+
+// inFlight wraps answer to count the requests being answered at once, each
+// held for latency.
+type inFlight struct {
+	mu      sync.Mutex
+	current int
+	peak    int
+}
+
+func (f *inFlight) answer(latency time.Duration, answer http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.current++
+		f.peak = max(f.peak, f.current)
+		f.mu.Unlock()
+		defer func() {
+			f.mu.Lock()
+			f.current--
+			f.mu.Unlock()
+		}()
+		time.Sleep(latency)
+		answer(w, r)
+	}
+}
+
+// TestWorkerPoolConcurrency checks that each provider client answers as
+// many requests at once as it has workers, so concurrent requests take
+// about one round trip rather than one each.
+func TestWorkerPoolConcurrency(t *testing.T) {
+	const latency = 100 * time.Millisecond
+	const requests = 4
+	clients := map[string]func(t *testing.T, url string, pool WorkerPool) LLMClient{
+		"ollama": func(t *testing.T, url string, pool WorkerPool) LLMClient {
+			client, err := NewOllamaLLMClient(url, "llama3.2", testSettings, pool)
+			if err != nil {
+				t.Fatalf("NewOllamaLLMClient: %v", err)
+			}
+			return client
+		},
+		"nvidia": func(t *testing.T, url string, pool WorkerPool) LLMClient {
+			client, err := NewNvidiaLLMClient(url, "meta/llama-3.1-8b-instruct", "key", nil, 1, testSettings, pool)
+			if err != nil {
+				t.Fatalf("NewNvidiaLLMClient: %v", err)
+			}
+			return client
+		},
+		"openai": func(t *testing.T, url string, pool WorkerPool) LLMClient {
+			client, err := NewOpenAILLMClient(url, "served-model", "", testSettings, pool)
+			if err != nil {
+				t.Fatalf("NewOpenAILLMClient: %v", err)
+			}
+			return client
+		},
+	}
+	answers := map[string]http.HandlerFunc{
+		"ollama": ollamaChatAnswer(`{"action":"clear"}`),
+		"nvidia": nvidiaChatAnswer(`{"action":"clear"}`),
+		"openai": openAIChatAnswer(`{"action":"clear"}`),
+	}
+	tests := []struct {
+		name string
+		pool WorkerPool
+		peak int
+		// rounds is how many round trips the requests take.
+		rounds int
+	}{
+		{name: "one worker", pool: WorkerPool{Concurrency: 1, QueueSize: requests}, peak: 1, rounds: requests},
+		{name: "unset", pool: WorkerPool{QueueSize: requests}, peak: 1, rounds: requests},
+		{name: "two workers", pool: WorkerPool{Concurrency: 2, QueueSize: requests}, peak: 2, rounds: 2},
+		{name: "a worker per request", pool: WorkerPool{Concurrency: requests, QueueSize: requests}, peak: requests, rounds: 1},
+	}
+
+	for name, newClient := range clients {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				var counter inFlight
+				server := newFakeProvider(t, counter.answer(latency, answers[name]))
+				client := newClient(t, server.URL, tt.pool)
+
+				start := time.Now()
+				var wg sync.WaitGroup
+				for i := 0; i < requests; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := client.GenerateResponse(context.Background(), "clear the board", "[]"); err != nil {
+							t.Errorf("GenerateResponse: %v", err)
+						}
+					}()
+				}
+				wg.Wait()
+				elapsed := time.Since(start)
+				if err := client.Close(); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+
+				if counter.peak != tt.peak {
+					t.Errorf("%d requests ran at once, want %d", counter.peak, tt.peak)
+				}
+				want := time.Duration(tt.rounds) * latency
+				if elapsed < want || elapsed >= want+latency {
+					t.Errorf("requests took %v, want about %v", elapsed, want)
+				}
+			})
+		}
+	}
+}
+
+// status answers every request with code and a JSON error body.
+func status(code int, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"error":%q}`, message)
+	}
+}
+
+// TestPing checks that each provider's Ping reports a working setup and
+// classifies what is wrong with a broken one, as generation errors are.
+func TestPing(t *testing.T) {
+	served := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"other-model","object":"model"},{"id":"served-model","object":"model"}]}`)
+	}
+	notServed := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"other-model","object":"model"}]}`)
+	}
+	shown := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"modelfile":"FROM llama3.2"}`)
+	}
+
+	tests := []struct {
+		provider string
+		name     string
+		respond  http.HandlerFunc
+		path     string
+		err      error
+	}{
+		{provider: "ollama", name: "model pulled", respond: shown, path: "/api/show"},
+		{provider: "ollama", name: "model not pulled", respond: status(http.StatusNotFound, "model not found"), path: "/api/show", err: ErrModelUnavailable},
+		{provider: "ollama", name: "server failing", respond: status(http.StatusInternalServerError, "boom"), path: "/api/show", err: ErrProviderUnavailable},
+		{provider: "nvidia", name: "completion", respond: nvidiaChatAnswer("{"), path: "/v1/chat/completions"},
+		{provider: "nvidia", name: "bad key", respond: status(http.StatusUnauthorized, "invalid api key"), path: "/v1/chat/completions", err: ErrAuth},
+		{provider: "nvidia", name: "unknown model", respond: status(http.StatusNotFound, "model not found"), path: "/v1/chat/completions", err: ErrModelUnavailable},
+		{provider: "nvidia", name: "throttled", respond: status(http.StatusTooManyRequests, "slow down"), path: "/v1/chat/completions", err: ErrProviderThrottled},
+		{provider: "openai", name: "model served", respond: served, path: "/v1/models"},
+		{provider: "openai", name: "model not served", respond: notServed, path: "/v1/models", err: ErrModelUnavailable},
+		{provider: "openai", name: "bad key", respond: status(http.StatusForbidden, "forbidden"), path: "/v1/models", err: ErrAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.name, func(t *testing.T) {
+			server := newFakeProvider(t, tt.respond)
+			var client LLMClient
+			var err error
+			switch tt.provider {
+			case "ollama":
+				client, err = NewOllamaLLMClient(server.URL, "llama3.2", testSettings, WorkerPool{})
+			case "nvidia":
+				client, err = NewNvidiaLLMClient(server.URL+"/v1/chat/completions", "meta/llama-3.1-8b-instruct", "key", nil, 3, testSettings, WorkerPool{})
+			case "openai":
+				client, err = NewOpenAILLMClient(server.URL+"/v1", "served-model", "key", testSettings, WorkerPool{})
+			}
+			if err != nil {
+				t.Fatalf("creating the %s client: %v", tt.provider, err)
+			}
+			defer client.Close()
+
+			err = client.Ping(context.Background())
+			if tt.err == nil && err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			// Ping checks once; a startup check shouldn't sit through retries.
+			if server.count() != 1 || server.paths[0] != tt.path {
+				t.Errorf("Ping made requests to %v, want one to %s", server.paths, tt.path)
+			}
+		})
+	}
+}
+
+// TestPromptVersionRecorded checks that the configured prompt version is
+// sent and recorded on the response, and that a version a reload dropped
+// falls back to the built-in prompt.
+func TestPromptVersionRecorded(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "terse.txt")
+	if err := os.WriteFile(path, []byte("Answer with JSON only."), 0o644); err != nil {
+		t.Fatalf("failed to write the prompt: %v", err)
+	}
+	registry, err := prompts.NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	server := newFakeProvider(t, ollamaChatAnswer(`{"action":"clear"}`))
+	settings := testSettings
+	settings.Prompts, settings.PromptVersion = registry, "terse"
+	client, err := NewOllamaLLMClient(server.URL, "llama3.2", settings, WorkerPool{})
+	if err != nil {
+		t.Fatalf("NewOllamaLLMClient: %v", err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		name    string
+		remove  bool
+		version string
+		system  string
+	}{
+		{name: "configured version", version: "terse", system: "Answer with JSON only."},
+		{name: "version dropped by a reload", remove: true, version: prompts.DefaultVersion, system: prompts.SystemPrompt(prompts.ModeDiagram)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.remove {
+				os.Remove(path)
+				if err := registry.Reload(); err != nil {
+					t.Fatalf("Reload: %v", err)
+				}
+			}
+			resp, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+			if err != nil {
+				t.Fatalf("GenerateResponse: %v", err)
+			}
+			if resp.PromptVersion != tt.version {
+				t.Errorf("PromptVersion = %q, want %q", resp.PromptVersion, tt.version)
+			}
+			messages, _ := server.lastBody(t)["messages"].([]any)
+			if len(messages) == 0 {
+				t.Fatal("request has no messages")
+			}
+			if system, _ := messages[0].(map[string]any)["content"].(string); system != tt.system {
+				t.Errorf("system prompt = %.60q, want %.60q", system, tt.system)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package llm
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+
+	"draw/pkg/llm/prompts"
+)
+
+// Compaction levels for the board state sent to the model. Each level keeps
+// less of every element, so a large board still fits a small context.
+const (
+	// CompactionFull sends elements as stored.
+	CompactionFull = 0
+	// CompactionNoNoise drops Excalidraw bookkeeping (seed, versionNonce,
+	// points, ...) and rendering details that instructions never refer to,
+	// and rounds positions and sizes to whole pixels.
+	CompactionNoNoise = 1
+	// CompactionFocused also drops colors and stroke details from elements
+	// the instruction doesn't refer to.
+	CompactionFocused = 2
+	// CompactionSkeleton keeps only IDs, types, text, labels, background
+	// colors, bindings, frames, image files and positions and sizes rounded
+	// to coarseGrid.
+	CompactionSkeleton = 3
+)
+
+// coarseGrid is the grid skeleton positions and sizes are rounded to.
+const coarseGrid = 10
+
+// CompactBoard returns the board as the model sees it at level. referenced
+// holds the IDs of elements the instruction refers to, which keep their
+// styling at CompactionFocused.
+func CompactBoard(board []Element, level int, referenced map[string]bool) []Element {
+	compacted := make([]Element, 0, len(board))
+	for _, element := range board {
+		compacted = append(compacted, compactElement(element, level, referenced[element.ID]))
+	}
+	return compacted
+}
+
+func compactElement(element Element, level int, referenced bool) Element {
+	if level <= CompactionFull {
+		return element
+	}
+	if level >= CompactionSkeleton {
+		skeleton := Element{
+			ID:     element.ID,
+			Type:   element.Type,
+			X:      roundCoarse(element.X),
+			Y:      roundCoarse(element.Y),
+			Width:  roundCoarse(element.Width),
+			Height: roundCoarse(element.Height),
+			Text:   element.Text,
+			// Instructions pick elements out by color: "the red box".
+			BackgroundColor: element.BackgroundColor,
+			Start:           element.Start,
+			End:             element.End,
+			Name:            element.Name,
+			FrameID:         element.FrameID,
+			FileID:          element.FileID,
+		}
+		if element.Label != nil {
+			skeleton.Label = &ElementLabel{Text: element.Label.Text}
+		}
+		return skeleton
+	}
+
+	element.X = math.Round(element.X)
+	element.Y = math.Round(element.Y)
+	element.Width = math.Round(element.Width)
+	element.Height = math.Round(element.Height)
+	element.Extra = nil
+	element.Index = ""
+	element.Points = nil
+	element.Roughness = nil
+	element.Opacity = nil
+	element.FillStyle = ""
+	element.FontFamily = 0
+	if level >= CompactionFocused && !referenced {
+		element.BackgroundColor = ""
+		element.StrokeColor = ""
+		element.StrokeWidth = 0
+		element.StrokeStyle = ""
+		if element.Label != nil {
+			label := *element.Label
+			label.StrokeColor = ""
+			element.Label = &label
+		}
+	}
+	return element
+}
+
+func roundCoarse(v float64) float64 {
+	return math.Round(v/coarseGrid) * coarseGrid
+}
+
+// RestoreCompacted rebuilds an updated element the model produced from a
+// compacted view of original. Fields the model left as it saw them, or
+// never saw, keep their stored value; only fields it changed are taken from
+// update. This keeps an update from wiping colors the model wasn't shown or
+// snapping an element to the coarse grid.
+func RestoreCompacted(original Element, update Element, level int, referenced bool) (Element, error) {
+	originalFields, err := elementMap(original)
+	if err != nil {
+		return update, err
+	}
+	seenFields, err := elementMap(compactElement(original, level, referenced))
+	if err != nil {
+		return update, err
+	}
+	updateFields, err := elementMap(update)
+	if err != nil {
+		return update, err
+	}
+
+	data, err := json.Marshal(mergeChanged(originalFields, seenFields, updateFields))
+	if err != nil {
+		return update, err
+	}
+	var restored Element
+	if err := json.Unmarshal(data, &restored); err != nil {
+		return update, err
+	}
+	return restored, nil
+}
+
+// mergeChanged overlays onto original the values in update that differ from
+// what was seen, descending into nested objects.
+func mergeChanged(original map[string]any, seen map[string]any, update map[string]any) map[string]any {
+	merged := make(map[string]any, len(original)+len(update))
+	for key, value := range original {
+		merged[key] = value
+	}
+	for key, value := range update {
+		if reflect.DeepEqual(seen[key], value) {
+			continue
+		}
+		originalObject, ok1 := original[key].(map[string]any)
+		updateObject, ok2 := value.(map[string]any)
+		if ok1 && ok2 {
+			seenObject, _ := seen[key].(map[string]any)
+			merged[key] = mergeChanged(originalObject, seenObject, updateObject)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+func elementMap(element Element) (map[string]any, error) {
+	data, err := json.Marshal(element)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// completionReserveTokens is the part of the context window kept free for
+// the model's answer.
+const completionReserveTokens = 1024
+
+// ollamaContextWindow is the context our requests ask Ollama for with
+// num_ctx. Its own default of 4096 leaves no room once the system prompt and
+// completionReserveTokens are taken.
+const ollamaContextWindow = 8192
+
+// defaultContextWindow is assumed for hosted models not in contextWindows.
+const defaultContextWindow = 32768
+
+// contextWindows maps model name prefixes to their context size in tokens.
+// The longest matching prefix wins.
+var contextWindows = map[string]int{
+	"gpt-4o":                   128000,
+	"gpt-4.1":                  1000000,
+	"gpt-3.5-turbo":            16385,
+	"meta/llama-3.1":           128000,
+	"meta/llama-3.2":           128000,
+	"meta/llama-3.3":           128000,
+	"meta/llama3":              8192,
+	"mistralai/mistral-7b":     32768,
+	"mistralai/mixtral-8x7b":   32768,
+	"google/gemma-2":           8192,
+	"gemini-1.5-pro":           2000000,
+	"gemini-1.5-flash":         1000000,
+	"gemini-2.0-flash":         1000000,
+	"gemini-2.5":               1000000,
+	"anthropic.claude":         200000,
+	"amazon.nova-lite":         300000,
+	"amazon.nova-pro":          300000,
+	"amazon.nova-micro":        128000,
+	"meta.llama3-1":            128000,
+	"microsoft/phi-3-mini-4k":  4096,
+	"microsoft/phi-3-mini-128": 128000,
+}
+
+// ContextWindow returns the context size in tokens a request to model on
+// provider can use.
+func ContextWindow(provider LLMProvider, model string) int {
+	if provider == LLMProviderOllama {
+		return ollamaContextWindow
+	}
+	window, matched := defaultContextWindow, 0
+	for prefix, size := range contextWindows {
+		if len(prefix) > matched && strings.HasPrefix(model, prefix) {
+			window, matched = size, len(prefix)
+		}
+	}
+	return window
+}
+
+// contextBudget is how many tokens of a window the user prompt may use once
+// the system prompt of mode and the answer are accounted for.
+func contextBudget(window int, mode prompts.Mode) int {
+	budget := window - prompts.EstimateTokens(prompts.SystemPrompt(mode)) - completionReserveTokens
+	if budget < 1 {
+		return 1
+	}
+	return budget
+}
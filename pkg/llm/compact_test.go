@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// noisyBoard returns n labelled rectangles with arrows between them, carrying
+// the bookkeeping Excalidraw stores with every element.
+func noisyBoard(n int) string {
+	var elements []string
+	for i := 1; i <= n; i++ {
+		elements = append(elements, fmt.Sprintf(
+			`{"id":"box-%d","type":"rectangle","x":%d.37,"y":%d.62,"width":160.4,"height":80.5,"backgroundColor":"#a5d8ff","strokeColor":"#1971c2","strokeWidth":2,"fillStyle":"hachure","roughness":1,"opacity":100,"label":{"text":"Step %d","strokeColor":"#1e1e1e"},"seed":%d,"version":%d,"versionNonce":%d,"updated":1700000000000,"index":"a%d","groupIds":[],"boundElements":null}`,
+			i, i*200, i*7, i, 1000+i, i, 2000+i, i))
+		if i > 1 {
+			elements = append(elements, fmt.Sprintf(
+				`{"id":"arrow-%d","type":"arrow","x":%d,"y":40,"width":40,"height":0,"points":[[0,0],[20,0.5],[40,0]],"start":{"id":"box-%d"},"end":{"id":"box-%d"},"seed":%d,"version":1,"versionNonce":1,"updated":1700000000000}`,
+				i, i*200-40, i-1, i, 3000+i))
+		}
+	}
+	return "[" + strings.Join(elements, ",") + "]"
+}
+
+// boardSection returns the board state a prompt was built with.
+func boardSection(t *testing.T, text string) string {
+	t.Helper()
+	const heading = "## CURRENT BOARD STATE\n"
+	start := strings.Index(text, heading)
+	if start < 0 {
+		t.Fatalf("prompt has no board state:\n%s", text)
+	}
+	rest := text[start+len(heading):]
+	end := strings.Index(rest, "\n\n## ")
+	if end < 0 {
+		t.Fatalf("board state has no end:\n%s", text)
+	}
+	return rest[:end]
+}
+
+func TestCompactBoard(t *testing.T) {
+	var board []Element
+	if err := json.Unmarshal([]byte(noisyBoard(2)), &board); err != nil {
+		t.Fatalf("failed to parse board: %v", err)
+	}
+	box, arrow := board[1], board[2]
+
+	noNoise := CompactBoard(board, CompactionNoNoise, nil)
+	if got := noNoise[1]; got.X != 400 || got.Y != 15 || got.Width != 160 || got.Height != 81 {
+		t.Errorf("rounded box = (%v, %v) %v x %v, want (400, 15) 160 x 81", got.X, got.Y, got.Width, got.Height)
+	}
+	if len(noNoise[1].Extra) != 0 || noNoise[1].Index != "" || noNoise[1].Roughness != nil || noNoise[1].FillStyle != "" {
+		t.Errorf("box kept bookkeeping: %+v", noNoise[1])
+	}
+	if noNoise[1].BackgroundColor != box.BackgroundColor || noNoise[1].Label.StrokeColor != box.Label.StrokeColor {
+		t.Errorf("box lost its colors: %+v", noNoise[1])
+	}
+	if noNoise[2].Points != nil || noNoise[2].Start == nil || noNoise[2].Start.ID != arrow.Start.ID {
+		t.Errorf("arrow = %+v, want its points dropped and its bindings kept", noNoise[2])
+	}
+
+	focused := CompactBoard(board, CompactionFocused, map[string]bool{"box-1": true})
+	if focused[0].StrokeColor == "" || focused[1].StrokeColor != "" {
+		t.Errorf("focused strokes = %q, %q, want only the referenced box's", focused[0].StrokeColor, focused[1].StrokeColor)
+	}
+
+	skeleton := CompactBoard(board, CompactionSkeleton, nil)
+	data, err := json.Marshal(skeleton[1])
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"id":"box-2","type":"rectangle","x":400,"y":10,"width":160,"height":80,"backgroundColor":"#a5d8ff","label":{"text":"Step 2"}}`
+	if string(data) != want {
+		t.Errorf("skeleton box = %s, want %s", data, want)
+	}
+
+	if board[1].X != 400.37 || board[1].Extra["seed"] == nil || board[2].Points == nil {
+		t.Errorf("CompactBoard modified the board it was given")
+	}
+}
+
+func TestBoardStateMaxBytes(t *testing.T) {
+	boardState := noisyBoard(100)
+	var board []Element
+	if err := json.Unmarshal([]byte(boardState), &board); err != nil {
+		t.Fatalf("failed to parse board: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		level    int
+	}{
+		{name: "no budget", maxBytes: 0, level: CompactionFull},
+		{name: "fits already", maxBytes: len(boardState), level: CompactionFull},
+		{name: "noise dropped", maxBytes: len(boardState) * 3 / 5, level: CompactionNoNoise},
+		{name: "styling dropped", maxBytes: len(boardState) * 2 / 5, level: CompactionFocused},
+		// A board too large at every level is sent as a skeleton.
+		{name: "past every level", maxBytes: 100, level: CompactionSkeleton},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := GenerateOptions{BoardStateMaxBytes: tt.maxBytes}.BuildPrompt("make box-7 red", boardState)
+			if prompt.CompactionLevel != tt.level {
+				t.Errorf("compaction level = %d, want %d", prompt.CompactionLevel, tt.level)
+			}
+
+			sent := boardSection(t, prompt.Text)
+			if tt.level < CompactionSkeleton && tt.maxBytes > 0 && len(sent) > tt.maxBytes {
+				t.Errorf("board state is %d bytes, want at most %d", len(sent), tt.maxBytes)
+			}
+			// The model must still be able to refer to every element by ID.
+			var compacted []Element
+			if err := json.Unmarshal([]byte(sent), &compacted); err != nil {
+				t.Fatalf("board state is not valid JSON: %v", err)
+			}
+			if len(compacted) != len(board) {
+				t.Fatalf("board state has %d elements, want %d", len(compacted), len(board))
+			}
+			for i := range board {
+				if compacted[i].ID != board[i].ID || compacted[i].Type != board[i].Type {
+					t.Errorf("element %d = %s %s, want %s %s", i, compacted[i].Type, compacted[i].ID, board[i].Type, board[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreCompacted(t *testing.T) {
+	var board []Element
+	if err := json.Unmarshal([]byte(noisyBoard(1)), &board); err != nil {
+		t.Fatalf("failed to parse board: %v", err)
+	}
+	original := board[0]
+
+	// The model saw the skeleton and recolored the box, repeating the
+	// rounded position it was shown.
+	seen := CompactBoard(board, CompactionSkeleton, nil)[0]
+	update := seen
+	update.BackgroundColor = "#ffc9c9"
+	restored, err := RestoreCompacted(original, update, CompactionSkeleton, false)
+	if err != nil {
+		t.Fatalf("RestoreCompacted: %v", err)
+	}
+	if restored.BackgroundColor != "#ffc9c9" {
+		t.Errorf("background = %q, want the model's change", restored.BackgroundColor)
+	}
+	if restored.X != original.X || restored.Y != original.Y || restored.StrokeColor != original.StrokeColor {
+		t.Errorf("restored = %+v, want the stored position and stroke kept", restored)
+	}
+	if string(restored.Extra["seed"]) != string(original.Extra["seed"]) {
+		t.Errorf("seed = %s, want the stored %s", restored.Extra["seed"], original.Extra["seed"])
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		provider LLMProvider
+		model    string
+		want     int
+	}{
+		{provider: LLMProviderOllama, model: "llama3.2", want: ollamaContextWindow},
+		{provider: LLMProviderOpenAI, model: "gpt-4o-mini", want: 128000},
+		{provider: LLMProviderNvidia, model: "meta/llama3-70b-instruct", want: 8192},
+		{provider: LLMProviderNvidia, model: "microsoft/phi-3-mini-4k-instruct", want: 4096},
+		// The longest prefix wins over a shorter one that also matches.
+		{provider: LLMProviderNvidia, model: "microsoft/phi-3-mini-128k-instruct", want: 128000},
+		{provider: LLMProviderNvidia, model: "someone/unlisted", want: defaultContextWindow},
+	}
+	for _, tt := range tests {
+		if got := ContextWindow(tt.provider, tt.model); got != tt.want {
+			t.Errorf("ContextWindow(%s, %q) = %d, want %d", tt.provider, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestContextWindowCompaction(t *testing.T) {
+	tests := []struct {
+		name   string
+		window int
+		boxes  int
+		level  int
+	}{
+		{name: "small board, local model", window: ollamaContextWindow, boxes: 3, level: CompactionFull},
+		{name: "large board, local model", window: ollamaContextWindow, boxes: 30, level: CompactionNoNoise},
+		{name: "larger board, local model", window: ollamaContextWindow, boxes: 60, level: CompactionFocused},
+		// Past the last level the skeleton is sent as it is.
+		{name: "huge board, local model", window: ollamaContextWindow, boxes: 100, level: CompactionSkeleton},
+		{name: "huge board, hosted model", window: 128000, boxes: 100, level: CompactionFull},
+		// A window the system prompt already fills still gets a board.
+		{name: "window too small", window: 4096, boxes: 3, level: CompactionSkeleton},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := GenerateOptions{ContextWindow: tt.window}
+			prompt := opts.BuildPrompt("make box-2 red", noisyBoard(tt.boxes))
+			if prompt.CompactionLevel != tt.level {
+				t.Errorf("compaction level = %d, want %d", prompt.CompactionLevel, tt.level)
+			}
+			if budget := contextBudget(tt.window, opts.Mode); tt.level < CompactionSkeleton && prompt.Tokens > budget {
+				t.Errorf("prompt is %d tokens, over the %d the window leaves", prompt.Tokens, budget)
+			}
+			var compacted []Element
+			if err := json.Unmarshal([]byte(boardSection(t, prompt.Text)), &compacted); err != nil {
+				t.Errorf("board state is not valid JSON: %v", err)
+			}
+		})
+	}
+}
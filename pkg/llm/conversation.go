@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"sync"
+	"time"
+)
+
+// Conversations keeps the last turns of each conversation, such as the
+// instructions given on one board, so follow-ups like "make it bigger" can
+// refer back to them. A conversation left idle for longer than its TTL is
+// forgotten.
+type Conversations struct {
+	depth int
+	ttl   time.Duration
+
+	mu            sync.Mutex
+	conversations map[string]*conversation
+}
+
+type conversation struct {
+	turns    []Turn
+	lastUsed time.Time
+}
+
+// NewConversations keeps up to depth turns per conversation, each
+// conversation for ttl after its last turn; a ttl of 0 keeps them until the
+// process exits. It returns nil when depth is 0 or less, which disables
+// history.
+func NewConversations(depth int, ttl time.Duration) *Conversations {
+	if depth <= 0 {
+		return nil
+	}
+	return &Conversations{
+		depth:         depth,
+		ttl:           ttl,
+		conversations: make(map[string]*conversation),
+	}
+}
+
+// Turns returns the conversation's turns, oldest first. A nil Conversations
+// has none.
+func (c *Conversations) Turns(key string) []Turn {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conv, ok := c.conversations[key]
+	if !ok {
+		return nil
+	}
+	if c.expired(conv, time.Now()) {
+		delete(c.conversations, key)
+		return nil
+	}
+	return append([]Turn(nil), conv.turns...)
+}
+
+// Remember adds turn to the conversation, dropping its oldest turn once it
+// holds depth of them.
+func (c *Conversations) Remember(key string, turn Turn) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	conv, ok := c.conversations[key]
+	if !ok || c.expired(conv, now) {
+		// Only a new conversation can grow the map, so that is when idle
+		// ones are swept.
+		c.forgetIdle(now)
+		conv = &conversation{}
+		c.conversations[key] = conv
+	}
+	conv.turns = append(conv.turns, turn)
+	if len(conv.turns) > c.depth {
+		conv.turns = conv.turns[len(conv.turns)-c.depth:]
+	}
+	conv.lastUsed = now
+}
+
+func (c *Conversations) expired(conv *conversation, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(conv.lastUsed) > c.ttl
+}
+
+func (c *Conversations) forgetIdle(now time.Time) {
+	for key, conv := range c.conversations {
+		if c.expired(conv, now) {
+			delete(c.conversations, key)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package llm
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Element is a single whiteboard element as described by the whiteboard prompt.
+// Fields the prompt schema doesn't know about (Excalidraw bookkeeping such as
+// seed or versionNonce) are preserved in Extra so elements round-trip intact.
+type Element struct {
+	ID              string          `json:"id,omitempty"`
+	Type            string          `json:"type"`
+	X               float64         `json:"x"`
+	Y               float64         `json:"y"`
+	Width           float64         `json:"width,omitempty"`
+	Height          float64         `json:"height,omitempty"`
+	BackgroundColor string          `json:"backgroundColor,omitempty"`
+	StrokeColor     string          `json:"strokeColor,omitempty"`
+	StrokeWidth     float64         `json:"strokeWidth,omitempty"`
+	StrokeStyle     string          `json:"strokeStyle,omitempty"`
+	FillStyle       string          `json:"fillStyle,omitempty"`
+	Roughness       *float64        `json:"roughness,omitempty"`
+	Opacity         *float64        `json:"opacity,omitempty"`
+	Text            string          `json:"text,omitempty"`
+	FontSize        float64         `json:"fontSize,omitempty"`
+	FontFamily      int             `json:"fontFamily,omitempty"`
+	Label           *ElementLabel   `json:"label,omitempty"`
+	Start           *ElementBinding `json:"start,omitempty"`
+	End             *ElementBinding `json:"end,omitempty"`
+
+	// Points are the vertices of a line or arrow, relative to X and Y, so
+	// the first is [0, 0].
+	Points [][]float64 `json:"points,omitempty"`
+	// Routing is how an arrow runs between its ends: RoutingStraight,
+	// RoutingElbow or RoutingCurved. The server computes the points of elbow
+	// and curved arrows, and records the routing in customData, which
+	// clients keep.
+	Routing string `json:"routing,omitempty"`
+
+	// Name is a frame's title. FrameID puts an element in a frame.
+	Name    string `json:"name,omitempty"`
+	FrameID string `json:"frameId,omitempty"`
+	// FileID names the uploaded picture an image element shows.
+	FileID string `json:"fileId,omitempty"`
+	// Children lists the elements a frame the model adds should hold, for
+	// ones already on the board. ApplyAction moves them into the frame; it
+	// is never stored.
+	Children []string `json:"children,omitempty"`
+
+	// Index is the element's fractional index, which orders elements from
+	// back to front the way Excalidraw does.
+	Index string `json:"index,omitempty"`
+
+	// Color is the palette color of a "sticky" element. Stickies only come
+	// from the model; the server expands them into labelled rectangles.
+	Color string `json:"color,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Arrow routings.
+const (
+	// RoutingStraight is a single segment between the ends.
+	RoutingStraight = "straight"
+	// RoutingElbow runs in horizontal and vertical segments around the
+	// shapes it joins.
+	RoutingElbow = "elbow"
+	// RoutingCurved bows out between the ends.
+	RoutingCurved = "curved"
+)
+
+type ElementLabel struct {
+	Text        string  `json:"text"`
+	FontSize    float64 `json:"fontSize,omitempty"`
+	StrokeColor string  `json:"strokeColor,omitempty"`
+}
+
+type ElementBinding struct {
+	ID string `json:"id"`
+}
+
+// elementFields is the alias used to (un)marshal the typed fields without
+// recursing into Element's own MarshalJSON/UnmarshalJSON.
+type elementFields Element
+
+var (
+	knownElementKeysOnce sync.Once
+	knownElementKeys     map[string]struct{}
+)
+
+func elementKeys() map[string]struct{} {
+	knownElementKeysOnce.Do(func() {
+		knownElementKeys = make(map[string]struct{})
+		t := reflect.TypeOf(Element{})
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				knownElementKeys[name] = struct{}{}
+			}
+		}
+	})
+	return knownElementKeys
+}
+
+func (e *Element) UnmarshalJSON(data []byte) error {
+	var fields elementFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	known := elementKeys()
+	for key, value := range raw {
+		if _, ok := known[key]; ok {
+			continue
+		}
+		if fields.Extra == nil {
+			fields.Extra = make(map[string]json.RawMessage)
+		}
+		fields.Extra[key] = value
+	}
+
+	*e = Element(fields)
+	return nil
+}
+
+func (e Element) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(elementFields(e))
+	if err != nil || len(e.Extra) == 0 {
+		return data, err
+	}
+
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range e.Extra {
+		if _, ok := merged[key]; !ok {
+			merged[key] = value
+		}
+	}
+	return json.Marshal(merged)
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ErrAuth is returned when the provider rejects the configured credentials.
+// Retrying won't help; the configuration has to be fixed.
+var ErrAuth = errors.New("llm_auth: the LLM provider rejected the configured credentials")
+
+// ErrTimeout is returned when the provider didn't answer within the request
+// timeout, while the caller was still waiting.
+var ErrTimeout = errors.New("llm_timeout: the LLM provider did not answer in time")
+
+// ErrEmptyResponse is returned when the provider answered without any output.
+var ErrEmptyResponse = errors.New("empty_response: the LLM provider returned no output")
+
+// statusError returns the error class of a failed provider HTTP call, or nil
+// when the status doesn't say more than that the request failed.
+func statusError(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusTooManyRequests:
+		return ErrProviderThrottled
+	case status >= 500:
+		return ErrProviderUnavailable
+	default:
+		return nil
+	}
+}
+
+// timeoutError marks err as ErrTimeout when the provider call ran out of time
+// on its own, rather than because the caller gave up.
+func timeoutError(llmReq llmRequest, err error) error {
+	if err == nil || llmReq.ctx.Err() != nil || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// HTTPStatusForLLMError maps an error from this package to the HTTP status a
+// handler should answer with. Errors it doesn't know are a 500.
+func HTTPStatusForLLMError(err error) int {
+	switch {
+	case errors.Is(err, ErrProviderThrottled), errors.Is(err, ErrQueueFull), errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrInvalidLLMOutput), errors.Is(err, ErrEmptyResponse):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrProviderUnavailable), errors.Is(err, ErrModelUnavailable),
+		errors.Is(err, ErrBudgetExhausted), errors.Is(err, ErrClientClosed):
+		return http.StatusServiceUnavailable
+	default:
+		// ErrAuth included: it needs an operator, not a retry.
+		return http.StatusInternalServerError
+	}
+}
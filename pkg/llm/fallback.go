@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// failoverWarning is attached to responses served by any client but the
+// first.
+const failoverWarning = "The primary LLM provider is failing; this response came from a fallback provider and may be lower quality."
+
+// FallbackLLMClient tries its clients in order, moving on to the next one
+// when a provider is throttled, failing, unreachable or too slow. Other
+// errors, such as invalid output or an exhausted budget, are returned as
+// they are: the next provider wouldn't fare any better.
+type FallbackLLMClient struct {
+	clients []LLMClient
+}
+
+// NewFallbackLLMClient tries primary first and then each of fallbacks.
+func NewFallbackLLMClient(primary LLMClient, fallbacks ...LLMClient) *FallbackLLMClient {
+	return &FallbackLLMClient{clients: append([]LLMClient{primary}, fallbacks...)}
+}
+
+func (c *FallbackLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *FallbackLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	return c.generate(ctx, func(client LLMClient) (*LLMResponse, bool, error) {
+		resp, err := client.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+		return resp, false, err
+	})
+}
+
+// GenerateResponseStream streams from each client in turn. Once a client has
+// streamed part of an answer, its failure is final: previews of a second
+// answer would be drawn over those of the first.
+func (c *FallbackLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	return c.generate(ctx, func(client LLMClient) (*LLMResponse, bool, error) {
+		streamed := false
+		resp, err := generateStream(ctx, client, prompt, boardState, opts, func(chunk string) {
+			streamed = true
+			onChunk(chunk)
+		})
+		return resp, streamed, err
+	})
+}
+
+// generate calls each client until one succeeds or fails for a reason
+// failing over can't fix. call reports whether output already reached the
+// caller.
+func (c *FallbackLLMClient) generate(ctx context.Context, call func(client LLMClient) (*LLMResponse, bool, error)) (*LLMResponse, error) {
+	var err error
+	for i, client := range c.clients {
+		var resp *LLMResponse
+		var streamed bool
+		resp, streamed, err = call(client)
+		if err == nil {
+			if i > 0 && resp.Warning == "" {
+				resp.Warning = failoverWarning
+			}
+			return resp, nil
+		}
+		if streamed || !shouldFailOver(ctx, err) {
+			return nil, err
+		}
+		if i+1 < len(c.clients) {
+			fmt.Printf("WARNING: LLM provider %d of %d failed, trying the next one: %v\n", i+1, len(c.clients), err)
+		}
+	}
+	return nil, err
+}
+
+// shouldFailOver reports whether err means the provider, rather than the
+// request, is at fault. Nothing is retried once the caller has given up.
+func shouldFailOver(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrProviderThrottled) || errors.Is(err, ErrProviderUnavailable) ||
+		errors.Is(err, ErrQueueFull) || errors.Is(err, ErrModelUnavailable) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Ping checks every client rather than the first that answers: a broken
+// fallback should be found before the primary provider fails.
+func (c *FallbackLLMClient) Ping(ctx context.Context) error {
+	for i, client := range c.clients {
+		if err := client.Ping(ctx); err != nil {
+			if i == 0 {
+				return err
+			}
+			return fmt.Errorf("failover provider %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *FallbackLLMClient) Close() error {
+	var errs []error
+	for _, client := range c.clients {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
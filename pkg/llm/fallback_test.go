@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+// stubClient fails every call with err, or answers it as provider name,
+// streaming chunk first when it is set.
+type stubClient struct {
+	name   string
+	err    error
+	chunk  string
+	calls  int
+	closed bool
+}
+
+func (c *stubClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *stubClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	return c.GenerateResponseStream(ctx, prompt, boardState, opts, nil)
+}
+
+func (c *stubClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	c.calls++
+	if c.chunk != "" && onChunk != nil {
+		onChunk(c.chunk)
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &LLMResponse{Response: `{"action":"clear"}`, Provider: c.name}, nil
+}
+
+func (c *stubClient) Ping(ctx context.Context) error {
+	return c.err
+}
+
+func (c *stubClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestFallbackErrorClasses(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		failOver bool
+	}{
+		{name: "throttled", err: fmt.Errorf("%w: nvidia api error: status 429", ErrProviderThrottled), failOver: true},
+		{name: "unavailable", err: fmt.Errorf("%w: after 3 attempts", ErrProviderUnavailable), failOver: true},
+		{name: "queue full", err: ErrQueueFull, failOver: true},
+		{name: "model retired", err: fmt.Errorf("%w: gone", ErrModelUnavailable), failOver: true},
+		{name: "timed out", err: fmt.Errorf("%w: %w", ErrTimeout, context.DeadlineExceeded), failOver: true},
+		{name: "unreachable", err: fmt.Errorf("ollama chat error: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), failOver: true},
+		{name: "bad credentials", err: fmt.Errorf("%w: status 401", ErrAuth)},
+		{name: "invalid output", err: fmt.Errorf("%w: not JSON", ErrInvalidLLMOutput)},
+		{name: "empty response", err: fmt.Errorf("%w: nvidia", ErrEmptyResponse)},
+		{name: "budget exhausted", err: ErrBudgetExhausted},
+		{name: "rate limited", err: ErrRateLimited},
+		{name: "bad request", err: errors.New("nvidia api error: status 400")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubClient{name: "nvidia", err: tt.err}
+			secondary := &stubClient{name: "ollama"}
+			client := NewFallbackLLMClient(primary, secondary)
+
+			resp, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+			if !tt.failOver {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("error = %v, want the primary's error", err)
+				}
+				if secondary.calls != 0 {
+					t.Errorf("failed over on %v", tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateResponse: %v", err)
+			}
+			if secondary.calls != 1 || resp.Provider != "ollama" {
+				t.Errorf("served by %q after %d fallback calls, want ollama after 1", resp.Provider, secondary.calls)
+			}
+			if resp.Warning != failoverWarning {
+				t.Errorf("warning = %q, want the failover warning", resp.Warning)
+			}
+		})
+	}
+}
+
+func TestFallbackInOrder(t *testing.T) {
+	first := &stubClient{name: "nvidia", err: ErrProviderUnavailable}
+	second := &stubClient{name: "ollama", err: ErrQueueFull}
+	third := &stubClient{name: "ollama-2"}
+	client := NewFallbackLLMClient(first, second, third)
+
+	resp, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if resp.Provider != "ollama-2" || first.calls != 1 || second.calls != 1 {
+		t.Errorf("served by %q after %d and %d calls, want the third client after one each", resp.Provider, first.calls, second.calls)
+	}
+
+	third.err = ErrProviderThrottled
+	if _, err := client.GenerateResponse(context.Background(), "clear the board", "[]"); !errors.Is(err, ErrProviderThrottled) {
+		t.Errorf("error = %v, want the last client's error once all have failed", err)
+	}
+
+	primary := &stubClient{name: "nvidia"}
+	resp, err = NewFallbackLLMClient(primary, third).GenerateResponse(context.Background(), "clear the board", "[]")
+	if err != nil || resp.Warning != "" {
+		t.Errorf("primary answer = %+v, %v, want no warning", resp, err)
+	}
+}
+
+func TestFallbackNotAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	primary := &stubClient{name: "nvidia", err: fmt.Errorf("%w: %w", ErrProviderUnavailable, context.Canceled)}
+	secondary := &stubClient{name: "ollama"}
+
+	if _, err := NewFallbackLLMClient(primary, secondary).GenerateResponse(ctx, "clear the board", "[]"); err == nil {
+		t.Fatalf("GenerateResponse succeeded, want the primary's error")
+	}
+	if secondary.calls != 0 {
+		t.Errorf("failed over after the caller gave up")
+	}
+}
+
+func TestFallbackNotAfterStreaming(t *testing.T) {
+	primary := &stubClient{name: "nvidia", err: ErrProviderUnavailable, chunk: `{"action":`}
+	secondary := &stubClient{name: "ollama"}
+	client := NewFallbackLLMClient(primary, secondary)
+
+	var chunks []string
+	_, err := client.GenerateResponseStream(context.Background(), "clear the board", "[]", GenerateOptions{}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if !errors.Is(err, ErrProviderUnavailable) {
+		t.Errorf("error = %v, want the streaming client's error", err)
+	}
+	if secondary.calls != 0 || len(chunks) != 1 {
+		t.Errorf("failed over after %d chunks reached the caller", len(chunks))
+	}
+}
+
+func TestFallbackClosesAll(t *testing.T) {
+	clients := []*stubClient{{name: "nvidia"}, {name: "ollama"}, {name: "ollama-2"}}
+	NewFallbackLLMClient(clients[0], clients[1], clients[2]).Close()
+	for _, client := range clients {
+		if !client.closed {
+			t.Errorf("%s was not closed", client.name)
+		}
+	}
+}
+
+func TestFallbackPingsAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		errs    []error
+		wantErr string
+	}{
+		{name: "all reachable", errs: []error{nil, nil, nil}},
+		{name: "primary down", errs: []error{ErrAuth, nil, nil}, wantErr: ErrAuth.Error()},
+		{name: "failover down", errs: []error{nil, nil, ErrModelUnavailable}, wantErr: "failover provider 2: " + ErrModelUnavailable.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := make([]LLMClient, len(tt.errs))
+			for i, err := range tt.errs {
+				clients[i] = &stubClient{name: fmt.Sprint(i), err: err}
+			}
+			err := NewFallbackLLMClient(clients[0], clients[1:]...).Ping(context.Background())
+			if (tt.wantErr == "" && err != nil) || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Ping = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
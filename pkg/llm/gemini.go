@@ -0,0 +1,370 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// geminiBaseURL is used when no host is configured.
+const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiLLMClient calls Gemini's generateContent API to generate whiteboard
+// updates.
+type GeminiLLMClient struct {
+	httpClient  *http.Client
+	baseURL     string
+	model       string
+	apiKey      string
+	models      *Models
+	settings    RequestSettings
+	requestChan chan llmRequest
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+// NewGeminiLLMClient creates the client. baseURL is the API root, such as
+// geminiBaseURL; requests go to {baseURL}/models/{model}:generateContent.
+// models may be nil, in which case retired models are not substituted.
+func NewGeminiLLMClient(baseURL, model, apiKey string, models *Models, settings RequestSettings, pool WorkerPool) (*GeminiLLMClient, error) {
+	if strings.TrimSpace(apiKey) == "" {
+		return nil, fmt.Errorf("gemini api key is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("gemini model is required")
+	}
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = geminiBaseURL
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	client := &GeminiLLMClient{
+		httpClient:  newHTTPClient(settings.Timeout+5*time.Second, pool),
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		model:       model,
+		apiKey:      apiKey,
+		models:      models,
+		settings:    settings,
+		requestChan: make(chan llmRequest, pool.queueSize()),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	pool.start(&client.wg, client.worker)
+
+	return client, nil
+}
+
+func (c *GeminiLLMClient) worker() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			rejectPending(c.requestChan)
+			return
+		case req := <-c.requestChan:
+			if err := req.ctx.Err(); err != nil {
+				// The caller gave up while the request was queued.
+				req.errCh <- err
+				continue
+			}
+			result, err := c.generateResponseSync(req)
+			if err != nil {
+				req.errCh <- timeoutError(req, err)
+			} else {
+				req.resultCh <- result
+			}
+		}
+	}
+}
+
+func (c *GeminiLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *GeminiLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return nil, fmt.Errorf("empty text provided")
+	}
+
+	boardStateJSON := boardState
+	if boardState == "" {
+		boardStateJSON = "[]"
+	} else {
+		var js json.RawMessage
+		if err := json.Unmarshal([]byte(boardState), &js); err != nil {
+			boardStateJSON = "[]"
+		}
+	}
+
+	if opts.ContextWindow == 0 {
+		opts.ContextWindow = ContextWindow(LLMProviderGemini, c.model)
+	}
+	userPrompt := opts.BuildPrompt(prompt, boardStateJSON)
+	systemPrompt, promptVersion := c.settings.systemPrompt(opts.Mode)
+
+	if c.ctx.Err() != nil {
+		return nil, ErrClientClosed
+	}
+	resultCh := make(chan *LLMResponse, 1)
+	errCh := make(chan error, 1)
+
+	select {
+	case c.requestChan <- llmRequest{
+		ctx:          ctx,
+		prompt:       userPrompt.Text,
+		systemPrompt: systemPrompt,
+		options:      opts,
+		resultCh:     resultCh,
+		errCh:        errCh,
+	}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	default:
+		return nil, ErrQueueFull
+	}
+
+	select {
+	case result := <-resultCh:
+		result.DroppedSections = userPrompt.Dropped
+		result.CompactionLevel = userPrompt.CompactionLevel
+		result.PromptVersion = promptVersion
+		return result, nil
+	case err := <-errCh:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	}
+}
+
+// generateResponseSync calls the API with the model currently in use. When
+// the API reports that model as not found, the request is retried once with
+// its configured replacement.
+func (c *GeminiLLMClient) generateResponseSync(llmReq llmRequest) (*LLMResponse, error) {
+	model, err := c.models.Resolve(c.model)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.generate(llmReq, model)
+	var notFound *modelNotFoundError
+	if !errors.As(err, &notFound) {
+		return resp, err
+	}
+	replacement, ok := c.models.Retired(model, notFound.detail)
+	if !ok {
+		fmt.Printf("WARNING: gemini model %q is not available and no replacement is configured in LLM_MODEL_ALIASES: %s\n", model, notFound.detail)
+		return nil, fmt.Errorf("%w: %s", ErrModelUnavailable, err)
+	}
+	fmt.Printf("WARNING: gemini model %q is deprecated; using %q instead. Update LLM_MODEL.\n", model, replacement)
+	return c.generate(llmReq, replacement)
+}
+
+func (c *GeminiLLMClient) generate(llmReq llmRequest, model string) (*LLMResponse, error) {
+	temperature := llmReq.options.temperature(c.settings.Temperature)
+	topP := 0.9
+	var contents []geminiContent
+	for _, turn := range llmReq.options.History {
+		contents = append(contents,
+			geminiContent{Role: "user", Parts: []geminiPart{{Text: turn.Instruction}}},
+			geminiContent{Role: "model", Parts: []geminiPart{{Text: turn.Response}}},
+		)
+	}
+	contents = append(contents, geminiContent{
+		Role:  "user",
+		Parts: []geminiPart{{Text: llmReq.prompt}},
+	})
+	payload := geminiRequest{
+		Contents: contents,
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     &temperature,
+			TopP:            &topP,
+			MaxOutputTokens: c.settings.MaxTokens,
+			Seed:            llmReq.options.Seed,
+		},
+	}
+	if llmReq.systemPrompt != "" {
+		payload.SystemInstruction = &geminiContent{
+			Parts: []geminiPart{{Text: llmReq.systemPrompt}},
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal gemini request: %w", err)
+	}
+
+	reqCtx, cancel := requestContext(llmReq, c.ctx, c.settings.Timeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", c.baseURL, url.PathEscape(model))
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini request: %w", err)
+	}
+
+	// Sent as a header rather than the key query parameter, so it doesn't
+	// end up in URLs that get logged.
+	req.Header.Set("x-goog-api-key", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gemini api request error: %w", err)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if isModelNotFound(resp.StatusCode, string(errBody)) {
+			return nil, &modelNotFoundError{model: model, detail: fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(errBody)))}
+		}
+		detail := fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+		if class := statusError(resp.StatusCode); class != nil {
+			return nil, fmt.Errorf("%w: gemini api error: %s", class, detail)
+		}
+		return nil, fmt.Errorf("gemini api error: %s", detail)
+	}
+
+	var genResp geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&genResp); err != nil {
+		return nil, fmt.Errorf("failed to decode gemini response: %w", err)
+	}
+
+	if genResp.PromptFeedback.BlockReason != "" {
+		return nil, fmt.Errorf("gemini api blocked the prompt: %s", genResp.PromptFeedback.BlockReason)
+	}
+	if len(genResp.Candidates) == 0 {
+		return nil, fmt.Errorf("%w: gemini", ErrEmptyResponse)
+	}
+	candidate := genResp.Candidates[0]
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	if text.Len() == 0 {
+		if candidate.FinishReason != "" {
+			return nil, fmt.Errorf("%w: gemini finish reason %s", ErrEmptyResponse, candidate.FinishReason)
+		}
+		return nil, fmt.Errorf("%w: gemini", ErrEmptyResponse)
+	}
+
+	return &LLMResponse{
+		Response:          strings.TrimSpace(text.String()),
+		Timestamp:         time.Now().UTC(),
+		Seed:              llmReq.options.Seed,
+		SystemFingerprint: genResp.ModelVersion,
+		Provider:          string(LLMProviderGemini),
+		Model:             model,
+		Usage: Usage{
+			PromptTokens:     genResp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: genResp.UsageMetadata.CandidatesTokenCount,
+		},
+	}, nil
+}
+
+// Ping fetches the model's details, which needs a valid key.
+func (c *GeminiLLMClient) Ping(ctx context.Context) error {
+	model, err := c.models.Resolve(c.model)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.settings.Timeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s/models/%s", c.baseURL, url.PathEscape(model))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create gemini request: %w", err)
+	}
+	req.Header.Set("x-goog-api-key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("gemini api request error: %w", err)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		detail := fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: gemini model %q: %s", ErrModelUnavailable, model, detail)
+		}
+		if class := statusError(resp.StatusCode); class != nil {
+			return fmt.Errorf("%w: gemini api error: %s", class, detail)
+		}
+		return fmt.Errorf("gemini api error: %s", detail)
+	}
+	return nil
+}
+
+// QueueLength reports how many requests are waiting for a worker.
+func (c *GeminiLLMClient) QueueLength() int {
+	return len(c.requestChan)
+}
+
+func (c *GeminiLLMClient) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.wg.Wait()
+		c.httpClient.CloseIdleConnections()
+	})
+	return nil
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens"`
+	Seed            *int64   `json:"seed,omitempty"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// RequestInfo describes one call to a provider, for GenerateHooks.
+type RequestInfo struct {
+	Provider string
+	Model    string
+	// Streaming is set for calls made through GenerateResponseStream.
+	Streaming bool
+	// Repair is set for calls that ask the model to fix its previous,
+	// unparseable answer; see RepairLLMClient.
+	Repair bool
+	// PromptBytes and BoardStateBytes are the sizes of the instruction and
+	// the board state as given, before the prompt is built around them.
+	PromptBytes     int
+	BoardStateBytes int
+	BoardElements   int
+	Duration        time.Duration
+	Usage           Usage
+	// ParseFailed is set when the provider answered with something that
+	// isn't a whiteboard action.
+	ParseFailed bool
+	Err         error
+}
+
+// GenerateHook is told about every provider call once it returns. Hooks run
+// on the caller's goroutine, so they must be quick.
+type GenerateHook func(ctx context.Context, info RequestInfo)
+
+// HookedLLMClient reports every call to its provider client to hooks, for
+// logging and metrics. It wraps a provider directly, so each repair prompt
+// and each failover counts as a call of its own.
+type HookedLLMClient struct {
+	inner    LLMClient
+	provider LLMProvider
+	model    string
+	hooks    []GenerateHook
+}
+
+// NewHookedLLMClient wraps inner, a client for model on provider.
+func NewHookedLLMClient(inner LLMClient, provider LLMProvider, model string, hooks ...GenerateHook) *HookedLLMClient {
+	return &HookedLLMClient{inner: inner, provider: provider, model: model, hooks: hooks}
+}
+
+func (c *HookedLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *HookedLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	started := time.Now()
+	resp, err := c.inner.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+	c.report(ctx, prompt, boardState, opts, false, started, resp, err)
+	return resp, err
+}
+
+func (c *HookedLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	started := time.Now()
+	resp, err := generateStream(ctx, c.inner, prompt, boardState, opts, onChunk)
+	_, streaming := c.inner.(StreamingLLMClient)
+	c.report(ctx, prompt, boardState, opts, streaming && onChunk != nil, started, resp, err)
+	return resp, err
+}
+
+func (c *HookedLLMClient) report(ctx context.Context, prompt string, boardState string, opts GenerateOptions, streaming bool, started time.Time, resp *LLMResponse, err error) {
+	info := RequestInfo{
+		Provider:        string(c.provider),
+		Model:           c.model,
+		Streaming:       streaming,
+		Repair:          opts.Malformed != nil,
+		PromptBytes:     len(prompt),
+		BoardStateBytes: len(boardState),
+		Duration:        time.Since(started),
+		Err:             err,
+	}
+	var elements []json.RawMessage
+	if json.Unmarshal([]byte(boardState), &elements) == nil {
+		info.BoardElements = len(elements)
+	}
+	if resp != nil {
+		if resp.Model != "" {
+			info.Model = resp.Model
+		}
+		info.Usage = resp.Usage
+		if _, parseErr := ParseWhiteboardAction(resp.Response); parseErr != nil {
+			info.ParseFailed = true
+		}
+	}
+	for _, hook := range c.hooks {
+		hook(ctx, info)
+	}
+}
+
+func (c *HookedLLMClient) Ping(ctx context.Context) error {
+	return c.inner.Ping(ctx)
+}
+
+func (c *HookedLLMClient) Close() error {
+	return c.inner.Close()
+}
+
+// NewLogHook logs every provider call to logger: at info level, or at warn
+// level when the call failed or its answer didn't parse.
+func NewLogHook(logger *slog.Logger) GenerateHook {
+	return func(ctx context.Context, info RequestInfo) {
+		level := slog.LevelInfo
+		attrs := []slog.Attr{
+			slog.String("provider", info.Provider),
+			slog.String("model", info.Model),
+			slog.Bool("streaming", info.Streaming),
+			slog.Bool("repair", info.Repair),
+			slog.Int("prompt_bytes", info.PromptBytes),
+			slog.Int("board_state_bytes", info.BoardStateBytes),
+			slog.Int("board_elements", info.BoardElements),
+			slog.Duration("duration", info.Duration),
+			slog.Int("prompt_tokens", info.Usage.PromptTokens),
+			slog.Int("completion_tokens", info.Usage.CompletionTokens),
+		}
+		if info.ParseFailed {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.Bool("parse_failed", true))
+		}
+		if info.Err != nil {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.String("error", info.Err.Error()))
+		}
+		logger.LogAttrs(ctx, level, "llm request", attrs...)
+	}
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Outcomes of a provider call, as labelled in Metrics.
+const (
+	OutcomeOK            = "ok"
+	OutcomeInvalidOutput = "invalid_output"
+	OutcomeError         = "error"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request duration
+// histogram.
+var durationBuckets = [...]float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 7.5, 10, 20, 30}
+
+// Metrics counts provider calls per provider, model and outcome, and
+// renders them in the Prometheus text format. Hook feeds it.
+type Metrics struct {
+	mu     sync.Mutex
+	series map[metricsKey]*metricsSeries
+}
+
+type metricsKey struct {
+	provider string
+	model    string
+	outcome  string
+}
+
+type metricsSeries struct {
+	buckets          [len(durationBuckets)]uint64
+	count            uint64
+	sum              float64
+	promptTokens     uint64
+	completionTokens uint64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{series: make(map[metricsKey]*metricsSeries)}
+}
+
+// Hook returns the GenerateHook that records calls in m.
+func (m *Metrics) Hook() GenerateHook {
+	return func(_ context.Context, info RequestInfo) {
+		m.observe(info)
+	}
+}
+
+func (m *Metrics) observe(info RequestInfo) {
+	key := metricsKey{provider: info.Provider, model: info.Model, outcome: OutcomeOK}
+	switch {
+	case info.Err != nil && !errors.Is(info.Err, ErrInvalidLLMOutput):
+		key.outcome = OutcomeError
+	case info.Err != nil || info.ParseFailed:
+		key.outcome = OutcomeInvalidOutput
+	}
+	seconds := info.Duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	series, ok := m.series[key]
+	if !ok {
+		series = &metricsSeries{}
+		m.series[key] = series
+	}
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			series.buckets[i]++
+		}
+	}
+	series.count++
+	series.sum += seconds
+	series.promptTokens += uint64(info.Usage.PromptTokens)
+	series.completionTokens += uint64(info.Usage.CompletionTokens)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition
+// format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	keys := make([]metricsKey, 0, len(m.series))
+	snapshot := make(map[metricsKey]metricsSeries, len(m.series))
+	for key, series := range m.series {
+		keys = append(keys, key)
+		snapshot[key] = *series
+	}
+	m.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.provider != b.provider {
+			return a.provider < b.provider
+		}
+		if a.model != b.model {
+			return a.model < b.model
+		}
+		return a.outcome < b.outcome
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP llm_request_duration_seconds Time taken by LLM provider calls.\n")
+	b.WriteString("# TYPE llm_request_duration_seconds histogram\n")
+	for _, key := range keys {
+		series := snapshot[key]
+		labels := key.labels()
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(&b, "llm_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bound, series.buckets[i])
+		}
+		fmt.Fprintf(&b, "llm_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, series.count)
+		fmt.Fprintf(&b, "llm_request_duration_seconds_sum{%s} %g\n", labels, series.sum)
+		fmt.Fprintf(&b, "llm_request_duration_seconds_count{%s} %d\n", labels, series.count)
+	}
+	b.WriteString("# HELP llm_tokens_total Tokens used by LLM provider calls.\n")
+	b.WriteString("# TYPE llm_tokens_total counter\n")
+	for _, key := range keys {
+		series := snapshot[key]
+		labels := key.labels()
+		fmt.Fprintf(&b, "llm_tokens_total{%s,kind=\"prompt\"} %d\n", labels, series.promptTokens)
+		fmt.Fprintf(&b, "llm_tokens_total{%s,kind=\"completion\"} %d\n", labels, series.completionTokens)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (k metricsKey) labels() string {
+	return fmt.Sprintf(`provider="%s",model="%s",outcome="%s"`, labelEscaper.Replace(k.provider), labelEscaper.Replace(k.model), k.outcome)
+}
+
+// labelEscaper escapes label values as the text format expects.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrModelUnavailable is returned without calling the provider while the
+// configured model is known to be retired and has no replacement.
+var ErrModelUnavailable = errors.New("model_unavailable: the provider no longer serves the configured model")
+
+// modelRecheckInterval is how long a retired model is failed fast before the
+// provider is asked again, in case the model comes back.
+const modelRecheckInterval = 5 * time.Minute
+
+// Models tracks model slugs a provider has retired. When a provider reports
+// a model as not found, its configured replacement is used from then on;
+// without one the model is marked unavailable so requests fail fast instead
+// of each waiting for the provider. It is shared by every client created
+// from the same config.
+type Models struct {
+	aliases map[string]string
+
+	mu            sync.Mutex
+	substitutions map[string]ModelSubstitution
+	outages       map[string]ModelOutage
+}
+
+// ModelSubstitution is a retired model and the replacement used in its place.
+type ModelSubstitution struct {
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	Since time.Time `json:"since"`
+}
+
+// ModelOutage is a retired model with no replacement.
+type ModelOutage struct {
+	Model  string    `json:"model"`
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+}
+
+// ModelStatus reports the substitutions and outages in effect.
+type ModelStatus struct {
+	Healthy       bool                `json:"healthy"`
+	Substitutions []ModelSubstitution `json:"substitutions"`
+	Unavailable   []ModelOutage       `json:"unavailable"`
+}
+
+// ParseModelAliases parses "old=new" entries mapping retired model slugs to
+// their replacements.
+func ParseModelAliases(entries []string) (map[string]string, error) {
+	aliases := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		from, to, ok := strings.Cut(entry, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid model alias %q: expected old=new", entry)
+		}
+		if from == to {
+			return nil, fmt.Errorf("invalid model alias %q: model maps to itself", entry)
+		}
+		aliases[from] = to
+	}
+	return aliases, nil
+}
+
+func NewModels(aliases map[string]string) *Models {
+	return &Models{
+		aliases:       aliases,
+		substitutions: make(map[string]ModelSubstitution),
+		outages:       make(map[string]ModelOutage),
+	}
+}
+
+// Resolve returns the model to request in place of model, following any
+// substitutions made so far. It fails with ErrModelUnavailable while the
+// model it resolves to is marked retired. A nil Models resolves every model
+// to itself.
+func (m *Models) Resolve(model string) (string, error) {
+	if m == nil {
+		return model, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for seen := 0; seen < len(m.substitutions); seen++ {
+		sub, ok := m.substitutions[model]
+		if !ok {
+			break
+		}
+		model = sub.To
+	}
+	if outage, ok := m.outages[model]; ok {
+		if time.Since(outage.Since) < modelRecheckInterval {
+			return "", fmt.Errorf("%w: %s (%s)", ErrModelUnavailable, model, outage.Reason)
+		}
+		delete(m.outages, model)
+	}
+	return model, nil
+}
+
+// Retired records that the provider doesn't serve model. It returns the
+// configured replacement, or false after marking the model unavailable.
+func (m *Models) Retired(model string, reason string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if replacement, ok := m.aliases[model]; ok {
+		m.substitutions[model] = ModelSubstitution{From: model, To: replacement, Since: time.Now().UTC()}
+		return replacement, true
+	}
+	m.outages[model] = ModelOutage{Model: model, Reason: reason, Since: time.Now().UTC()}
+	return "", false
+}
+
+// Status reports the substitutions and outages in effect.
+func (m *Models) Status() ModelStatus {
+	status := ModelStatus{
+		Healthy:       true,
+		Substitutions: []ModelSubstitution{},
+		Unavailable:   []ModelOutage{},
+	}
+	if m == nil {
+		return status
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sub := range m.substitutions {
+		status.Substitutions = append(status.Substitutions, sub)
+	}
+	for _, outage := range m.outages {
+		if time.Since(outage.Since) < modelRecheckInterval {
+			status.Unavailable = append(status.Unavailable, outage)
+		}
+	}
+	sort.Slice(status.Substitutions, func(i, j int) bool { return status.Substitutions[i].From < status.Substitutions[j].From })
+	sort.Slice(status.Unavailable, func(i, j int) bool { return status.Unavailable[i].Model < status.Unavailable[j].Model })
+	status.Healthy = len(status.Unavailable) == 0
+	return status
+}
+
+// modelNotFoundError is a provider's response to a request for a model it
+// doesn't serve.
+type modelNotFoundError struct {
+	model  string
+	detail string
+}
+
+func (e *modelNotFoundError) Error() string {
+	return fmt.Sprintf("model %q not found: %s", e.model, e.detail)
+}
+
+// isModelNotFound recognises a provider's "model not found" response: a 404
+// from the completions endpoint, or a 400 or 422 whose body says the model is
+// unknown or retired.
+func isModelNotFound(status int, body string) bool {
+	if status == 404 {
+		return true
+	}
+	if status != 400 && status != 422 {
+		return false
+	}
+	body = strings.ToLower(body)
+	if !strings.Contains(body, "model") {
+		return false
+	}
+	for _, phrase := range []string{"not found", "does not exist", "unknown model", "deprecated", "retired", "decommissioned"} {
+		if strings.Contains(body, phrase) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseModelAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "none", want: map[string]string{}},
+		{name: "trimmed", entries: []string{" meta/llama3-70b = meta/llama-3.1-70b "}, want: map[string]string{"meta/llama3-70b": "meta/llama-3.1-70b"}},
+		{name: "no replacement", entries: []string{"meta/llama3-70b="}, wantErr: true},
+		{name: "no separator", entries: []string{"meta/llama3-70b"}, wantErr: true},
+		{name: "maps to itself", entries: []string{"a=a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseModelAliases(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for from, to := range tt.want {
+				if got[from] != to {
+					t.Errorf("%s maps to %q, want %q", from, got[from], to)
+				}
+			}
+		})
+	}
+}
+
+func TestIsModelNotFound(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{status: 404, body: "", want: true},
+		{status: 400, body: `{"detail":"Model meta/llama3-70b not found"}`, want: true},
+		{status: 422, body: `{"error":"model has been deprecated"}`, want: true},
+		{status: 400, body: `{"error":"max_tokens must be positive"}`, want: false},
+		// Not found, but not the model.
+		{status: 400, body: `{"error":"function not found"}`, want: false},
+		{status: 500, body: `{"error":"model not found"}`, want: false},
+	}
+	for _, tt := range tests {
+		if got := isModelNotFound(tt.status, tt.body); got != tt.want {
+			t.Errorf("isModelNotFound(%d, %s) = %v, want %v", tt.status, tt.body, got, tt.want)
+		}
+	}
+}
+
+// retiringProvider answers with a 404 for the retired model and with an
+// action for any other, recording the model each request asked for.
+func retiringProvider(t *testing.T, retired string) (*fakeProvider, *[]string) {
+	t.Helper()
+	var asked []string
+	var server *fakeProvider
+	server = newFakeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		model, _ := server.lastBody(t)["model"].(string)
+		asked = append(asked, model)
+		if model == retired {
+			http.Error(w, `{"detail":"model not found"}`, http.StatusNotFound)
+			return
+		}
+		nvidiaChatAnswer(`{"action":"clear"}`)(w, r)
+	})
+	return server, &asked
+}
+
+func TestNvidiaRetiredModel(t *testing.T) {
+	const retired = "meta/llama3-70b-instruct"
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		// asked is the models requested over two calls.
+		asked       string
+		wantErr     error
+		healthy     bool
+		substituted string
+	}{
+		{
+			name:        "mapped",
+			aliases:     map[string]string{retired: "meta/llama-3.1-70b-instruct"},
+			asked:       retired + ",meta/llama-3.1-70b-instruct,meta/llama-3.1-70b-instruct",
+			healthy:     true,
+			substituted: "meta/llama-3.1-70b-instruct",
+		},
+		{
+			// The second call fails fast without reaching the provider.
+			name:    "unmapped",
+			asked:   retired,
+			wantErr: ErrModelUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, asked := retiringProvider(t, retired)
+			models := NewModels(tt.aliases)
+			client, err := NewNvidiaLLMClient(server.URL, retired, "key", models, 1, testSettings, WorkerPool{})
+			if err != nil {
+				t.Fatalf("NewNvidiaLLMClient: %v", err)
+			}
+			defer client.Close()
+
+			for call := 1; call <= 2; call++ {
+				_, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+				if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+					t.Errorf("call %d: got %v, want %v", call, err, tt.wantErr)
+				}
+			}
+			if got := strings.Join(*asked, ","); got != tt.asked {
+				t.Errorf("models asked for: %s, want %s", got, tt.asked)
+			}
+
+			status := models.Status()
+			if status.Healthy != tt.healthy {
+				t.Errorf("healthy = %v, want %v", status.Healthy, tt.healthy)
+			}
+			if tt.substituted != "" && (len(status.Substitutions) != 1 || status.Substitutions[0].To != tt.substituted) {
+				t.Errorf("substitutions = %+v, want %s replaced by %s", status.Substitutions, retired, tt.substituted)
+			}
+			if !tt.healthy && (len(status.Unavailable) != 1 || status.Unavailable[0].Model != retired) {
+				t.Errorf("unavailable = %+v, want %s", status.Unavailable, retired)
+			}
+		})
+	}
+}
+
+func TestBudgetFallsBackForUnavailableModel(t *testing.T) {
+	primary := &stubClient{name: "nvidia", err: ErrModelUnavailable}
+	fallback := &stubClient{name: "ollama"}
+	client := NewBudgetLLMClient(primary, fallback, newTestBudget(t, func(ctx context.Context, since time.Time) (float64, error) { return 0, nil }))
+
+	resp, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if resp.Provider != "ollama" || resp.Warning != modelUnavailableWarning {
+		t.Errorf("got a response from %s warning %q, want one from ollama warning %q", resp.Provider, resp.Warning, modelUnavailableWarning)
+	}
+}
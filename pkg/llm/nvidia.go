@@ -1,17 +1,26 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+)
 
-	"draw/pkg/llm/prompts"
+// Backoff between attempts at the Nvidia API: it doubles from
+// nvidiaRetryBaseDelay up to nvidiaRetryMaxDelay, plus up to 50% jitter.
+const (
+	nvidiaRetryBaseDelay = 500 * time.Millisecond
+	nvidiaRetryMaxDelay  = 8 * time.Second
 )
 
 // NvidiaLLMClient calls Nvidia's Chat Completions API to generate whiteboard updates.
@@ -20,13 +29,21 @@ type NvidiaLLMClient struct {
 	baseURL     string
 	model       string
 	apiKey      string
+	models      *Models
+	settings    RequestSettings
+	maxAttempts int
 	requestChan chan llmRequest
 	ctx         context.Context
 	cancel      context.CancelFunc
 	closeOnce   sync.Once
+	wg          sync.WaitGroup
 }
 
-func NewNvidiaLLMClient(baseURL, model, apiKey string) (*NvidiaLLMClient, error) {
+// NewNvidiaLLMClient creates the client. models may be nil, in which case
+// retired models are not substituted. maxAttempts is how many times a request
+// is tried when the API is throttled, failing or unreachable; values below 1
+// mean a single try.
+func NewNvidiaLLMClient(baseURL, model, apiKey string, models *Models, maxAttempts int, settings RequestSettings, pool WorkerPool) (*NvidiaLLMClient, error) {
 	if strings.TrimSpace(apiKey) == "" {
 		return nil, fmt.Errorf("nvidia api key is required")
 	}
@@ -36,33 +53,47 @@ func NewNvidiaLLMClient(baseURL, model, apiKey string) (*NvidiaLLMClient, error)
 	if strings.TrimSpace(baseURL) == "" {
 		baseURL = "https://integrate.api.nvidia.com/v1/chat/completions"
 	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	client := &NvidiaLLMClient{
-		httpClient:  &http.Client{Timeout: 25 * time.Second},
+		httpClient:  newHTTPClient(settings.Timeout+5*time.Second, pool),
 		baseURL:     baseURL,
 		model:       model,
 		apiKey:      apiKey,
-		requestChan: make(chan llmRequest, 10),
+		models:      models,
+		settings:    settings,
+		maxAttempts: maxAttempts,
+		requestChan: make(chan llmRequest, pool.queueSize()),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 
-	go client.worker()
+	pool.start(&client.wg, client.worker)
 
 	return client, nil
 }
 
 func (c *NvidiaLLMClient) worker() {
+	defer c.wg.Done()
+
 	for {
 		select {
 		case <-c.ctx.Done():
+			rejectPending(c.requestChan)
 			return
 		case req := <-c.requestChan:
-			result, err := c.generateResponseSync(req.prompt, req.systemPrompt)
-			if err != nil {
+			if err := req.ctx.Err(); err != nil {
+				// The caller gave up while the request was queued.
 				req.errCh <- err
+				continue
+			}
+			result, err := c.generateResponseSync(req)
+			if err != nil {
+				req.errCh <- timeoutError(req, err)
 			} else {
 				req.resultCh <- result
 			}
@@ -71,6 +102,17 @@ func (c *NvidiaLLMClient) worker() {
 }
 
 func (c *NvidiaLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseStream(ctx, prompt, boardState, GenerateOptions{}, nil)
+}
+
+func (c *NvidiaLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	return c.GenerateResponseStream(ctx, prompt, boardState, opts, nil)
+}
+
+// GenerateResponseStream behaves like GenerateResponseWithOptions but also
+// reports each piece of model output to onChunk as the API streams it back
+// over server-sent events.
+func (c *NvidiaLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
 	fmt.Println("Nvidia Generating response for prompt", prompt, "and board state", boardState)
 	if strings.TrimSpace(prompt) == "" {
 		return nil, fmt.Errorf("empty text provided")
@@ -86,54 +128,110 @@ func (c *NvidiaLLMClient) GenerateResponse(ctx context.Context, prompt string, b
 		}
 	}
 
-	userPrompt := prompts.BuildWhiteboardPrompt(prompt, boardStateJSON)
-	systemPrompt := prompts.WhiteboardSystemPrompt
+	if opts.ContextWindow == 0 {
+		opts.ContextWindow = ContextWindow(LLMProviderNvidia, c.model)
+	}
+	userPrompt := opts.BuildPrompt(prompt, boardStateJSON)
+	systemPrompt, promptVersion := c.settings.systemPrompt(opts.Mode)
 
+	if c.ctx.Err() != nil {
+		return nil, ErrClientClosed
+	}
 	resultCh := make(chan *LLMResponse, 1)
 	errCh := make(chan error, 1)
 
 	select {
 	case c.requestChan <- llmRequest{
-		prompt:       userPrompt,
+		ctx:          ctx,
+		prompt:       userPrompt.Text,
 		systemPrompt: systemPrompt,
+		options:      opts,
+		onChunk:      onChunk,
 		resultCh:     resultCh,
 		errCh:        errCh,
 	}:
 	case <-ctx.Done():
 		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	default:
+		return nil, ErrQueueFull
 	}
 
 	select {
 	case result := <-resultCh:
+		result.DroppedSections = userPrompt.Dropped
+		result.CompactionLevel = userPrompt.CompactionLevel
+		result.PromptVersion = promptVersion
 		return result, nil
 	case err := <-errCh:
 		return nil, err
 	case <-ctx.Done():
 		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
 	}
 }
 
-func (c *NvidiaLLMClient) generateResponseSync(prompt string, systemPrompt string) (*LLMResponse, error) {
-	messages := []nvidiaChatMessage{
-		{
-			Role:    "user",
-			Content: prompt,
-		},
+// generateResponseSync calls the API with the model currently in use. When
+// the provider reports that model as not found, the request is retried once
+// with its configured replacement.
+func (c *NvidiaLLMClient) generateResponseSync(llmReq llmRequest) (*LLMResponse, error) {
+	model, err := c.models.Resolve(c.model)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.complete(llmReq, model)
+	var notFound *modelNotFoundError
+	if !errors.As(err, &notFound) {
+		return resp, err
+	}
+	replacement, ok := c.models.Retired(model, notFound.detail)
+	if !ok {
+		fmt.Printf("WARNING: nvidia model %q is not available and no replacement is configured in LLM_MODEL_ALIASES: %s\n", model, notFound.detail)
+		return nil, fmt.Errorf("%w: %s", ErrModelUnavailable, err)
 	}
-	if systemPrompt != "" {
-		messages = append([]nvidiaChatMessage{{
+	fmt.Printf("WARNING: nvidia model %q is deprecated; using %q instead. Update LLM_MODEL.\n", model, replacement)
+	return c.complete(llmReq, replacement)
+}
+
+func (c *NvidiaLLMClient) complete(llmReq llmRequest, model string) (*LLMResponse, error) {
+	var messages []nvidiaChatMessage
+	if llmReq.systemPrompt != "" {
+		messages = append(messages, nvidiaChatMessage{
 			Role:    "system",
-			Content: systemPrompt,
-		}}, messages...)
+			Content: llmReq.systemPrompt,
+		})
+	}
+	for _, turn := range llmReq.options.History {
+		messages = append(messages,
+			nvidiaChatMessage{Role: "user", Content: turn.Instruction},
+			nvidiaChatMessage{Role: "assistant", Content: turn.Response},
+		)
 	}
+	messages = append(messages, nvidiaChatMessage{
+		Role:    "user",
+		Content: llmReq.prompt,
+	})
 
 	payload := nvidiaChatRequest{
-		Model:       c.model,
+		Model:       model,
 		Messages:    messages,
-		MaxTokens:   1024,
-		Temperature: 0.2,
+		MaxTokens:   c.settings.MaxTokens,
+		Temperature: llmReq.options.temperature(c.settings.Temperature),
 		TopP:        0.9,
-		Stream:      false,
+		Seed:        llmReq.options.Seed,
+		Stream:      llmReq.onChunk != nil,
+	}
+	if payload.Stream {
+		payload.StreamOptions = &nvidiaStreamOptions{IncludeUsage: true}
+	}
+	if c.settings.StructuredOutput {
+		payload.ResponseFormat = &nvidiaResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &nvidiaJSONSchema{Name: "whiteboard_action", Schema: whiteboardActionSchema},
+		}
 	}
 
 	body, err := json.Marshal(payload)
@@ -141,50 +239,260 @@ func (c *NvidiaLLMClient) generateResponseSync(prompt string, systemPrompt strin
 		return nil, fmt.Errorf("failed to marshal nvidia request: %w", err)
 	}
 
-	reqCtx, cancel := context.WithTimeout(c.ctx, 20*time.Second)
+	// The deadline covers every attempt, backoff included.
+	reqCtx, cancel := requestContext(llmReq, c.ctx, c.settings.Timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, c.baseURL, bytes.NewBuffer(body))
+	var chatResp *nvidiaChatResponse
+	for attempt := 1; ; attempt++ {
+		chatResp, err = c.post(reqCtx, body, model, llmReq.onChunk)
+		if err == nil {
+			break
+		}
+		var transient *nvidiaTransientError
+		if !errors.As(err, &transient) {
+			return nil, err
+		}
+		if attempt >= c.maxAttempts {
+			return nil, transient.final(attempt)
+		}
+		delay := nvidiaRetryDelay(attempt, transient.retryAfter)
+		if deadline, ok := reqCtx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, transient.final(attempt)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-reqCtx.Done():
+			timer.Stop()
+			return nil, transient.final(attempt)
+		}
+	}
+
+	if len(chatResp.Choices) == 0 || chatResp.Choices[0].Message.Content == "" {
+		return nil, fmt.Errorf("%w: nvidia", ErrEmptyResponse)
+	}
+
+	responseText := strings.TrimSpace(chatResp.Choices[0].Message.Content)
+
+	return &LLMResponse{
+		Response:          responseText,
+		Timestamp:         time.Now().UTC(),
+		Seed:              llmReq.options.Seed,
+		SystemFingerprint: chatResp.SystemFingerprint,
+		Provider:          string(LLMProviderNvidia),
+		Model:             model,
+		Usage: Usage{
+			PromptTokens:     chatResp.Usage.PromptTokens,
+			CompletionTokens: chatResp.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// post sends one chat completion request, streaming the answer to onChunk
+// when it is set. Throttling, server errors and network failures before the
+// answer starts come back as *nvidiaTransientError, so complete can try
+// again; anything else is final.
+func (c *NvidiaLLMClient) post(ctx context.Context, body []byte, model string, onChunk func(chunk string)) (*nvidiaChatResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create nvidia request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	if onChunk != nil {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("nvidia api request error: %w", err)
+		err = fmt.Errorf("nvidia api request error: %w", err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &nvidiaTransientError{err: err}
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return nil, fmt.Errorf("nvidia api error: status %d: %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+		detail := fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+		if isModelNotFound(resp.StatusCode, string(errBody)) {
+			return nil, &modelNotFoundError{model: model, detail: detail}
+		}
+		err := fmt.Errorf("nvidia api error: %s", detail)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return nil, &nvidiaTransientError{
+				err:        err,
+				throttled:  resp.StatusCode == http.StatusTooManyRequests,
+				retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			}
+		}
+		if class := statusError(resp.StatusCode); class != nil {
+			return nil, fmt.Errorf("%w: %w", class, err)
+		}
+		return nil, err
 	}
 
+	if onChunk != nil {
+		return readNvidiaStream(ctx, resp.Body, onChunk)
+	}
 	var chatResp nvidiaChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
 		return nil, fmt.Errorf("failed to decode nvidia response: %w", err)
 	}
+	return &chatResp, nil
+}
 
-	if len(chatResp.Choices) == 0 || chatResp.Choices[0].Message.Content == "" {
-		return nil, fmt.Errorf("nvidia api returned empty response")
+// readNvidiaStream reads a streamed completion, passing each piece of
+// content to onChunk, and assembles it into a single response. A stream
+// cut off by ctx returns ctx.Err().
+func readNvidiaStream(ctx context.Context, body io.Reader, onChunk func(chunk string)) (*nvidiaChatResponse, error) {
+	var assembled nvidiaChatResponse
+	var text strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk nvidiaChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode nvidia stream chunk: %w", err)
+		}
+		if chunk.SystemFingerprint != "" {
+			assembled.SystemFingerprint = chunk.SystemFingerprint
+		}
+		if chunk.Usage.PromptTokens != 0 || chunk.Usage.CompletionTokens != 0 {
+			assembled.Usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				text.WriteString(choice.Delta.Content)
+				onChunk(choice.Delta.Content)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("nvidia api stream error: %w", err)
 	}
 
-	responseText := strings.TrimSpace(chatResp.Choices[0].Message.Content)
+	assembled.Choices = []nvidiaChoice{{Message: nvidiaChoiceContent{Content: text.String()}}}
+	return &assembled, nil
+}
 
-	return &LLMResponse{
-		Response:  responseText,
-		Timestamp: time.Now(),
-	}, nil
+// nvidiaTransientError is a failed attempt worth repeating. retryAfter is
+// the wait the API asked for, if any.
+type nvidiaTransientError struct {
+	err        error
+	throttled  bool
+	retryAfter time.Duration
+}
+
+func (e *nvidiaTransientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nvidiaTransientError) Unwrap() error {
+	return e.err
+}
+
+// final is the error returned once no attempts are left: ErrProviderThrottled
+// for throttling, so callers are told to back off, and ErrProviderUnavailable
+// otherwise.
+func (e *nvidiaTransientError) final(attempts int) error {
+	if e.throttled {
+		return fmt.Errorf("%w: after %d attempts: %w", ErrProviderThrottled, attempts, e.err)
+	}
+	return fmt.Errorf("%w: after %d attempts: %w", ErrProviderUnavailable, attempts, e.err)
+}
+
+// nvidiaRetryDelay is the wait before attempt+1. The API's Retry-After wins
+// when it sent one.
+func nvidiaRetryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	delay := nvidiaRetryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > nvidiaRetryMaxDelay {
+		delay = nvidiaRetryMaxDelay
+	}
+	return delay + rand.N(delay/2+1)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date. It returns 0 when the header is missing or unreadable.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// Ping asks for a one-token completion: the API has no cheaper call that
+// checks both the key and the model. It is tried once, without backoff.
+func (c *NvidiaLLMClient) Ping(ctx context.Context) error {
+	model, err := c.models.Resolve(c.model)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(nvidiaChatRequest{
+		Model:     model,
+		Messages:  []nvidiaChatMessage{{Role: "user", Content: "ping"}},
+		MaxTokens: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal nvidia request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.settings.Timeout)
+	defer cancel()
+
+	_, err = c.post(ctx, body, model, nil)
+	var transient *nvidiaTransientError
+	var notFound *modelNotFoundError
+	switch {
+	case errors.As(err, &transient):
+		return transient.final(1)
+	case errors.As(err, &notFound):
+		return fmt.Errorf("%w: %s", ErrModelUnavailable, err)
+	}
+	return err
+}
+
+// QueueLength reports how many requests are waiting for a worker.
+func (c *NvidiaLLMClient) QueueLength() int {
+	return len(c.requestChan)
 }
 
 func (c *NvidiaLLMClient) Close() error {
 	c.closeOnce.Do(func() {
 		c.cancel()
-		close(c.requestChan)
+		c.wg.Wait()
+		c.httpClient.CloseIdleConnections()
 	})
 	return nil
 }
@@ -200,13 +508,43 @@ type nvidiaChatRequest struct {
 	MaxTokens   int                 `json:"max_tokens"`
 	Temperature float64             `json:"temperature"`
 	TopP        float64             `json:"top_p"`
+	Seed        *int64              `json:"seed,omitempty"`
 	Stream      bool                `json:"stream"`
+
+	StreamOptions  *nvidiaStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *nvidiaResponseFormat `json:"response_format,omitempty"`
+}
+
+type nvidiaStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type nvidiaResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *nvidiaJSONSchema `json:"json_schema,omitempty"`
 }
 
+type nvidiaJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// nvidiaChatResponse is both a whole response and, when streaming, one
+// chunk of it; chunks carry their content in Delta instead of Message.
 type nvidiaChatResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+	Choices           []nvidiaChoice `json:"choices"`
+	SystemFingerprint string         `json:"system_fingerprint"`
+	Usage             struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+type nvidiaChoice struct {
+	Message nvidiaChoiceContent `json:"message"`
+	Delta   nvidiaChoiceContent `json:"delta"`
+}
+
+type nvidiaChoiceContent struct {
+	Content string `json:"content"`
 }
@@ -0,0 +1,378 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// nvidiaChatAnswer answers a chat completion request with content.
+func nvidiaChatAnswer(content string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]string{"role": "assistant", "content": content}}},
+			"usage":   map[string]int{"prompt_tokens": 12, "completion_tokens": 5},
+		})
+	}
+}
+
+func newTestNvidiaClient(t *testing.T, url string, maxAttempts int, settings RequestSettings) *NvidiaLLMClient {
+	t.Helper()
+	client, err := NewNvidiaLLMClient(url, "meta/llama-3.1-8b-instruct", "key", nil, maxAttempts, settings, WorkerPool{})
+	if err != nil {
+		t.Fatalf("NewNvidiaLLMClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestNvidiaStructuredOutput(t *testing.T) {
+	for _, structured := range []bool{true, false} {
+		server := newFakeProvider(t, nvidiaChatAnswer(`{"action":"clear"}`))
+		settings := testSettings
+		settings.StructuredOutput = structured
+		client := newTestNvidiaClient(t, server.URL, 1, settings)
+		if _, err := client.GenerateResponse(context.Background(), "clear the board", "[]"); err != nil {
+			t.Fatalf("GenerateResponse: %v", err)
+		}
+
+		format, ok := server.lastBody(t)["response_format"].(map[string]any)
+		if !structured {
+			if ok {
+				t.Errorf("response_format = %v without structured output, want none", format)
+			}
+			continue
+		}
+		if !ok {
+			t.Fatalf("no response_format in the request")
+		}
+		if format["type"] != "json_schema" {
+			t.Errorf("response_format.type = %v, want json_schema", format["type"])
+		}
+		schema, _ := format["json_schema"].(map[string]any)
+		assertSchema(t, schema["schema"])
+	}
+}
+
+func TestNvidiaCancel(t *testing.T) {
+	server, received, aborted := blockingProvider(t)
+	client := newTestNvidiaClient(t, server.URL, 3, testSettings)
+
+	assertCancelled(t, received, aborted, func(ctx context.Context) error {
+		_, err := client.GenerateResponse(ctx, "clear the board", "[]")
+		return err
+	})
+	// A cancelled call is the caller's doing, not a transient failure.
+	if got := server.count(); got != 1 {
+		t.Errorf("server got %d requests, want no retries after cancelling", got)
+	}
+}
+
+// failingFor answers with status until it has failed times, and then with
+// answer.
+func failingFor(times int, status int, header http.Header, answer http.HandlerFunc) http.HandlerFunc {
+	var mu sync.Mutex
+	failed := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fail := failed < times
+		failed++
+		mu.Unlock()
+		if !fail {
+			answer(w, r)
+			return
+		}
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error":"nope"}`))
+	}
+}
+
+func TestNvidiaRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		failures int
+		timeout  time.Duration
+		header   http.Header
+		want     error
+		requests int
+	}{
+		{name: "recovers from 503", status: http.StatusServiceUnavailable, failures: 2, requests: 3},
+		{name: "throttled throughout", status: http.StatusTooManyRequests, failures: 100, want: ErrProviderThrottled, requests: 3},
+		{name: "failing throughout", status: http.StatusInternalServerError, failures: 100, want: ErrProviderUnavailable, requests: 3},
+		{name: "bad request", status: http.StatusBadRequest, failures: 100, requests: 1},
+		{name: "unauthorized", status: http.StatusUnauthorized, failures: 100, want: ErrAuth, requests: 1},
+		{name: "unprocessable", status: http.StatusUnprocessableEntity, failures: 100, requests: 1},
+		{
+			name: "backoff past the deadline", status: http.StatusServiceUnavailable, failures: 100,
+			timeout: 300 * time.Millisecond, want: ErrProviderUnavailable, requests: 1,
+		},
+		{
+			name: "retry-after past the deadline", status: http.StatusTooManyRequests, failures: 100,
+			timeout: 2 * time.Second, header: http.Header{"Retry-After": {"5"}}, want: ErrProviderThrottled, requests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := newFakeProvider(t, failingFor(tt.failures, tt.status, tt.header, nvidiaChatAnswer(`{"action":"clear"}`)))
+			settings := testSettings
+			if tt.timeout > 0 {
+				settings.Timeout = tt.timeout
+			}
+			client := newTestNvidiaClient(t, server.URL, 3, settings)
+
+			resp, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+			if got := server.count(); got != tt.requests {
+				t.Errorf("server got %d requests, want %d", got, tt.requests)
+			}
+			if tt.failures < tt.requests {
+				if err != nil {
+					t.Fatalf("GenerateResponse: %v", err)
+				}
+				if resp.Response != `{"action":"clear"}` {
+					t.Errorf("response = %q, want the answer after the retries", resp.Response)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("GenerateResponse succeeded, want an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+			if tt.want == ErrProviderThrottled || tt.want == ErrProviderUnavailable {
+				if want := fmt.Sprintf("after %d attempts", tt.requests); !strings.Contains(err.Error(), want) {
+					t.Errorf("error = %v, want it to say %q", err, want)
+				}
+			}
+			if !strings.Contains(err.Error(), fmt.Sprintf("status %d", tt.status)) {
+				t.Errorf("error = %v, want it to wrap the API's status", err)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":        0,
+		"3":       3 * time.Second,
+		"-1":      0,
+		"soon":    0,
+		"Wed, 21": 0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", value, got, want)
+		}
+	}
+	at := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(at); got <= 55*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, want about a minute", at, got)
+	}
+}
+
+// nvidiaStreamAnswer answers a streamed chat completion request with one
+// SSE event per chunk of content, then the usage and [DONE].
+func nvidiaStreamAnswer(chunks ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher, _ := w.(http.Flusher)
+		for _, chunk := range chunks {
+			data, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"delta": map[string]string{"content": chunk}}}})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":5}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+func TestReadNvidiaStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		chunks []string
+		usage  int
+		err    string
+	}{
+		{
+			name:   "deltas",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"{\\\"action\\\"\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\":\\\"clear\\\"}\"}}]}\n\ndata: [DONE]\n\n",
+			chunks: []string{`{"action"`, `:"clear"}`},
+		},
+		{
+			name:   "comments, blank lines and empty deltas",
+			stream: ": keep-alive\n\ndata:{\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\nevent: message\ndata: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\ndata: [DONE]\n",
+			chunks: []string{"a"},
+		},
+		{
+			name:   "usage in the last chunk",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\ndata: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":5}}\n\ndata: [DONE]\n\n",
+			chunks: []string{"a"},
+			usage:  5,
+		},
+		{
+			name:   "nothing read after DONE",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\ndata: [DONE]\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"b\"}}]}\n\n",
+			chunks: []string{"a"},
+		},
+		{
+			name:   "stream without DONE",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\n",
+			chunks: []string{"a"},
+		},
+		{
+			name:   "malformed chunk",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\ndata: {\"choices\":\n\n",
+			chunks: []string{"a"},
+			err:    "failed to decode nvidia stream chunk: unexpected end of JSON input",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chunks []string
+			resp, err := readNvidiaStream(context.Background(), strings.NewReader(tt.stream), func(chunk string) {
+				chunks = append(chunks, chunk)
+			})
+			if strings.Join(chunks, "|") != strings.Join(tt.chunks, "|") {
+				t.Errorf("chunks = %q, want %q", chunks, tt.chunks)
+			}
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readNvidiaStream: %v", err)
+			}
+			if got := resp.Choices[0].Message.Content; got != strings.Join(tt.chunks, "") {
+				t.Errorf("content = %q, want the chunks joined", got)
+			}
+			if resp.Usage.CompletionTokens != tt.usage {
+				t.Errorf("completion tokens = %d, want %d", resp.Usage.CompletionTokens, tt.usage)
+			}
+		})
+	}
+}
+
+func TestNvidiaStreamRequest(t *testing.T) {
+	server := newFakeProvider(t, nvidiaStreamAnswer(`{"action":`, `"clear"}`))
+	client := newTestNvidiaClient(t, server.URL, 1, testSettings)
+
+	var chunks []string
+	resp, err := client.GenerateResponseStream(context.Background(), "clear the board", "[]", GenerateOptions{}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatalf("GenerateResponseStream: %v", err)
+	}
+	if resp.Response != `{"action":"clear"}` || len(chunks) != 2 {
+		t.Errorf("response %q from chunks %q, want the chunks assembled", resp.Response, chunks)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 5 {
+		t.Errorf("usage = %+v, want the usage of the last chunk", resp.Usage)
+	}
+
+	body := server.lastBody(t)
+	options, _ := body["stream_options"].(map[string]any)
+	if body["stream"] != true || options["include_usage"] != true {
+		t.Errorf("stream = %v with options %v, want a stream that includes usage", body["stream"], body["stream_options"])
+	}
+	server.mu.Lock()
+	accept := server.headers[len(server.headers)-1].Get("Accept")
+	server.mu.Unlock()
+	if accept != "text/event-stream" {
+		t.Errorf("Accept = %q, want text/event-stream", accept)
+	}
+}
+
+// TestNvidiaConnectionReuse streams rounds of concurrent requests to a TLS
+// stub and counts the connections they open. With newHTTPClient's pool,
+// each worker keeps its connection; without keep-alive every request pays
+// for a TCP and TLS handshake. Run with -v for the time to first chunk of
+// both: about 0.6ms against 7ms on a development machine.
+func TestNvidiaConnectionReuse(t *testing.T) {
+	const workers, rounds = 4, 10
+	tests := []struct {
+		name      string
+		keepAlive bool
+		maxConns  int
+	}{
+		{name: "pooled", keepAlive: true, maxConns: workers},
+		{name: "a connection per request", maxConns: workers * rounds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(nvidiaStreamAnswer(`{"action":`, `"clear"}`))
+			var mu sync.Mutex
+			conns := 0
+			server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					mu.Lock()
+					conns++
+					mu.Unlock()
+				}
+			}
+			server.StartTLS()
+			defer server.Close()
+
+			client, err := NewNvidiaLLMClient(server.URL, "meta/llama-3.1-8b-instruct", "key", nil, 1, testSettings, WorkerPool{Concurrency: workers, QueueSize: workers})
+			if err != nil {
+				t.Fatalf("NewNvidiaLLMClient: %v", err)
+			}
+			defer client.Close()
+			transport := client.httpClient.Transport.(*http.Transport)
+			transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+			transport.DisableKeepAlives = !tt.keepAlive
+
+			var firstChunks []time.Duration
+			for round := 0; round < rounds; round++ {
+				var wg sync.WaitGroup
+				for i := 0; i < workers; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						start := time.Now()
+						var once sync.Once
+						_, err := client.GenerateResponseStream(context.Background(), "clear the board", "[]", GenerateOptions{}, func(string) {
+							once.Do(func() {
+								mu.Lock()
+								firstChunks = append(firstChunks, time.Since(start))
+								mu.Unlock()
+							})
+						})
+						if err != nil {
+							t.Errorf("GenerateResponseStream: %v", err)
+						}
+					}()
+				}
+				wg.Wait()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if conns > tt.maxConns {
+				t.Errorf("%d requests opened %d connections, want at most %d", workers*rounds, conns, tt.maxConns)
+			}
+			slices.Sort(firstChunks)
+			t.Logf("%d connections; time to first chunk p50 %v, p90 %v", conns, firstChunks[len(firstChunks)/2], firstChunks[len(firstChunks)*9/10])
+		})
+	}
+}
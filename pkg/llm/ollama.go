@@ -3,36 +3,155 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
+	"draw/pkg/config"
 	"draw/pkg/llm/prompts"
 
 	"github.com/ollama/ollama/api"
 )
 
 type llmRequest struct {
-	prompt     string
+	// ctx is the caller's context; cancelling it aborts the provider call.
+	ctx          context.Context
+	prompt       string
 	systemPrompt string
-	resultCh chan *LLMResponse
-	errCh    chan error
+	options      GenerateOptions
+	onChunk      func(chunk string)
+	resultCh     chan *LLMResponse
+	errCh        chan error
 }
 
-type OllamaLLMClient struct {
-	client       *api.Client
-	model        string
-	requestChan chan llmRequest
-	ctx          context.Context
-	cancel       context.CancelFunc
-	closeOnce    sync.Once
+// requestContext bounds one provider call by the caller's context, the
+// client's lifetime and timeout, whichever ends first.
+func requestContext(llmReq llmRequest, client context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(llmReq.ctx, timeout)
+	stop := context.AfterFunc(client, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
 }
 
+// WorkerPool sizes a provider client: how many requests it sends at once and
+// how many may wait for a free worker before it reports ErrQueueFull.
+type WorkerPool struct {
+	Concurrency int
+	QueueSize   int
+}
 
+// NewWorkerPool takes the pool size from the LLM config.
+func NewWorkerPool(cfg *config.LLMConfig) WorkerPool {
+	return WorkerPool{Concurrency: cfg.Concurrency, QueueSize: cfg.QueueSize}
+}
 
-func NewOllamaLLMClient(ollamaHost string, model string) (*OllamaLLMClient, error) {
-	client, err := api.ClientFromEnvironment()
+func (p WorkerPool) queueSize() int {
+	if p.QueueSize < 1 {
+		return 1
+	}
+	return p.QueueSize
+}
+
+func (p WorkerPool) concurrency() int {
+	if p.Concurrency < 1 {
+		return 1
+	}
+	return p.Concurrency
+}
+
+// start runs the workers, at least one, tracked by wg so Close can wait for
+// them.
+func (p WorkerPool) start(wg *sync.WaitGroup, worker func()) {
+	for i := 0; i < p.concurrency(); i++ {
+		wg.Add(1)
+		go worker()
+	}
+}
+
+// RequestSettings are sent with every provider call. GenerateOptions can
+// still set the temperature for a single request.
+type RequestSettings struct {
+	Temperature float64
+	MaxTokens   int
+	Timeout     time.Duration
+	// StructuredOutput asks providers that support it to constrain output
+	// to the whiteboard action schema.
+	StructuredOutput bool
+	// Prompts supplies the system prompt of PromptVersion; when nil, the
+	// built-in prompt is sent.
+	Prompts       *prompts.Registry
+	PromptVersion string
+}
+
+// NewRequestSettings takes the settings from the LLM config. A non-positive
+// max tokens or timeout falls back to 1024 tokens or 20 seconds.
+func NewRequestSettings(cfg *config.LLMConfig) RequestSettings {
+	settings := RequestSettings{
+		Temperature: cfg.Temperature,
+		MaxTokens:   cfg.MaxTokens,
+		Timeout:     time.Duration(cfg.RequestTimeoutSec) * time.Second,
+
+		StructuredOutput: cfg.StructuredOutput,
+		PromptVersion:    cfg.PromptVersion,
+	}
+	if settings.MaxTokens <= 0 {
+		settings.MaxTokens = 1024
+	}
+	if settings.Timeout <= 0 {
+		settings.Timeout = 20 * time.Second
+	}
+	return settings
+}
+
+// systemPrompt returns the system prompt to send for mode and its version. A
+// version dropped from the registry by a reload falls back to the built-in
+// prompt.
+func (s RequestSettings) systemPrompt(mode prompts.Mode) (string, string) {
+	if s.Prompts == nil {
+		return prompts.SystemPrompt(mode), prompts.DefaultVersion
+	}
+	text, err := s.Prompts.System(s.PromptVersion, mode)
+	if err != nil {
+		fmt.Printf("WARNING: %v; using prompt version %s\n", err, prompts.DefaultVersion)
+		return prompts.SystemPrompt(mode), prompts.DefaultVersion
+	}
+	return text, s.PromptVersion
+}
+
+// rejectPending fails the requests still queued when a client closes, so
+// their callers don't wait for answers that will never come.
+func rejectPending(requests chan llmRequest) {
+	for {
+		select {
+		case req := <-requests:
+			req.errCh <- ErrClientClosed
+		default:
+			return
+		}
+	}
+}
+
+type OllamaLLMClient struct {
+	client      *api.Client
+	model       string
+	settings    RequestSettings
+	requestChan chan llmRequest
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+// NewOllamaLLMClient talks to the Ollama server at ollamaHost, given as a URL
+// or host:port. An empty host falls back to OLLAMA_HOST and then localhost.
+func NewOllamaLLMClient(ollamaHost string, model string, settings RequestSettings, pool WorkerPool) (*OllamaLLMClient, error) {
+	client, err := newOllamaAPIClient(ollamaHost)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Ollama client: %w", err)
 	}
@@ -40,27 +159,54 @@ func NewOllamaLLMClient(ollamaHost string, model string) (*OllamaLLMClient, erro
 	ctx, cancel := context.WithCancel(context.Background())
 
 	llmClient := &OllamaLLMClient{
-		client:       client,
-		model:        model,
-		requestChan: make(chan llmRequest, 10),
-		ctx:          ctx,
-		cancel:       cancel,
+		client:      client,
+		model:       model,
+		settings:    settings,
+		requestChan: make(chan llmRequest, pool.queueSize()),
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 
-	go llmClient.worker()
+	pool.start(&llmClient.wg, llmClient.worker)
 
 	return llmClient, nil
 }
 
+func newOllamaAPIClient(host string) (*api.Client, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return api.ClientFromEnvironment()
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	base, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ollama host %q: %w", host, err)
+	}
+	if base.Host == "" {
+		return nil, fmt.Errorf("invalid ollama host %q: missing host name", host)
+	}
+	return api.NewClient(base, http.DefaultClient), nil
+}
+
 func (c *OllamaLLMClient) worker() {
+	defer c.wg.Done()
+
 	for {
 		select {
 		case <-c.ctx.Done():
+			rejectPending(c.requestChan)
 			return
 		case req := <-c.requestChan:
-			result, err := c.generateResponseSync(req.prompt, req.systemPrompt)
-			if err != nil {
+			if err := req.ctx.Err(); err != nil {
+				// The caller gave up while the request was queued.
 				req.errCh <- err
+				continue
+			}
+			result, err := c.generateResponseSync(req)
+			if err != nil {
+				req.errCh <- timeoutError(req, err)
 			} else {
 				req.resultCh <- result
 			}
@@ -69,6 +215,16 @@ func (c *OllamaLLMClient) worker() {
 }
 
 func (c *OllamaLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseStream(ctx, prompt, boardState, GenerateOptions{}, nil)
+}
+
+func (c *OllamaLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	return c.GenerateResponseStream(ctx, prompt, boardState, opts, nil)
+}
+
+// GenerateResponseStream behaves like GenerateResponseWithOptions but also
+// reports each piece of model output to onChunk as Ollama streams it back.
+func (c *OllamaLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
 	if strings.TrimSpace(prompt) == "" {
 		return nil, fmt.Errorf("empty text provided")
 	}
@@ -87,76 +243,156 @@ func (c *OllamaLLMClient) GenerateResponse(ctx context.Context, prompt string, b
 	}
 
 	// Build the user prompt with board state
-	userPrompt := prompts.BuildWhiteboardPrompt(prompt, boardStateJSON)
-	systemPrompt := prompts.WhiteboardSystemPrompt
+	if opts.ContextWindow == 0 {
+		opts.ContextWindow = ContextWindow(LLMProviderOllama, c.model)
+	}
+	userPrompt := opts.BuildPrompt(prompt, boardStateJSON)
+	systemPrompt, promptVersion := c.settings.systemPrompt(opts.Mode)
 
+	if c.ctx.Err() != nil {
+		return nil, ErrClientClosed
+	}
 	resultCh := make(chan *LLMResponse, 1)
 	errCh := make(chan error, 1)
 
 	select {
 	case c.requestChan <- llmRequest{
-		prompt:       userPrompt,
+		ctx:          ctx,
+		prompt:       userPrompt.Text,
 		systemPrompt: systemPrompt,
-		resultCh:    resultCh,
-		errCh:       errCh,
+		options:      opts,
+		onChunk:      onChunk,
+		resultCh:     resultCh,
+		errCh:        errCh,
 	}:
 	case <-ctx.Done():
 		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	default:
+		return nil, ErrQueueFull
 	}
 
 	select {
 	case result := <-resultCh:
+		result.DroppedSections = userPrompt.Dropped
+		result.CompactionLevel = userPrompt.CompactionLevel
+		result.PromptVersion = promptVersion
 		return result, nil
-	case err := <-errCh:	
+	case err := <-errCh:
 		return nil, err
 	case <-ctx.Done():
 		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
 	}
 }
 
-func (c *OllamaLLMClient) generateResponseSync(prompt string, systemPrompt string) (*LLMResponse, error) {
-	req := &api.GenerateRequest{
-		Model:  c.model,
-		Prompt: prompt,
-		Stream: new(bool),
+func (c *OllamaLLMClient) generateResponseSync(llmReq llmRequest) (*LLMResponse, error) {
+	onChunk := llmReq.onChunk
+	stream := onChunk != nil
+	var messages []api.Message
+	if llmReq.systemPrompt != "" {
+		messages = append(messages, api.Message{Role: "system", Content: llmReq.systemPrompt})
+	}
+	for _, turn := range llmReq.options.History {
+		messages = append(messages,
+			api.Message{Role: "user", Content: turn.Instruction},
+			api.Message{Role: "assistant", Content: turn.Response},
+		)
+	}
+	messages = append(messages, api.Message{Role: "user", Content: llmReq.prompt})
+
+	req := &api.ChatRequest{
+		Model:    c.model,
+		Messages: messages,
+		Stream:   &stream,
 		Options: map[string]any{
-			"temperature": 0.1,
-			"num_predict": 2000,
+			"temperature": llmReq.options.temperature(c.settings.Temperature),
+			"num_predict": c.settings.MaxTokens,
+			"num_ctx":     ollamaContextWindow,
 		},
 	}
-
-	// Set system prompt if provided
-	if systemPrompt != "" {
-		req.System = systemPrompt
+	if llmReq.options.Seed != nil {
+		req.Options["seed"] = *llmReq.options.Seed
+	}
+	if c.settings.StructuredOutput {
+		req.Format = whiteboardActionSchema
 	}
 
-	// daata, _ := json.MarshalIndent(req, "", "  ")
-	// fmt.Println("Request", string(daata))
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(llmReq, c.ctx, c.settings.Timeout)
 	defer cancel()
 
 	var fullResponse strings.Builder
-	err := c.client.Generate(ctx, req, func(resp api.GenerateResponse) error {
-		fullResponse.WriteString(resp.Response)
+	var usage Usage
+	err := c.client.Chat(ctx, req, func(resp api.ChatResponse) error {
+		fullResponse.WriteString(resp.Message.Content)
+		if resp.Done {
+			usage = Usage{
+				PromptTokens:     resp.PromptEvalCount,
+				CompletionTokens: resp.EvalCount,
+			}
+		}
+		if onChunk != nil && resp.Message.Content != "" {
+			onChunk(resp.Message.Content)
+		}
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("ollama generate error: %w", err)
+		var statusErr api.StatusError
+		if errors.As(err, &statusErr) {
+			if class := statusError(statusErr.StatusCode); class != nil {
+				return nil, fmt.Errorf("%w: ollama chat error: %w", class, err)
+			}
+		}
+		return nil, fmt.Errorf("ollama chat error: %w", err)
 	}
 
 	responseText := strings.TrimSpace(fullResponse.String())
+	if responseText == "" {
+		return nil, fmt.Errorf("%w: ollama", ErrEmptyResponse)
+	}
 
 	return &LLMResponse{
 		Response:  responseText,
-		Timestamp: time.Now(),
+		Timestamp: time.Now().UTC(),
+		Seed:      llmReq.options.Seed,
+		Provider:  string(LLMProviderOllama),
+		Model:     c.model,
+		Usage:     usage,
 	}, nil
 }
 
+// Ping asks the server for the model's details, which fails unless the model
+// has been pulled there.
+func (c *OllamaLLMClient) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.settings.Timeout)
+	defer cancel()
+
+	if _, err := c.client.Show(ctx, &api.ShowRequest{Model: c.model}); err != nil {
+		var statusErr api.StatusError
+		if errors.As(err, &statusErr) {
+			if statusErr.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("%w: ollama model %q is not pulled on the server: %w", ErrModelUnavailable, c.model, err)
+			}
+			if class := statusError(statusErr.StatusCode); class != nil {
+				return fmt.Errorf("%w: ollama show error: %w", class, err)
+			}
+		}
+		return fmt.Errorf("ollama show error: %w", err)
+	}
+	return nil
+}
+
+// QueueLength reports how many requests are waiting for a worker.
+func (c *OllamaLLMClient) QueueLength() int {
+	return len(c.requestChan)
+}
+
 func (c *OllamaLLMClient) Close() error {
 	c.closeOnce.Do(func() {
 		c.cancel()
-		close(c.requestChan)
+		c.wg.Wait()
 	})
 	return nil
 }
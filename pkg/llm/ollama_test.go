@@ -0,0 +1,270 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSettings keep test calls short.
+var testSettings = RequestSettings{MaxTokens: 100, Timeout: 5 * time.Second}
+
+// fakeProvider is an HTTP server that records the requests it is sent and
+// answers them with respond.
+type fakeProvider struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	paths    []string
+	headers  []http.Header
+	bodies   [][]byte
+	requests int
+}
+
+func newFakeProvider(t *testing.T, respond http.HandlerFunc) *fakeProvider {
+	t.Helper()
+	f := &fakeProvider{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.requests++
+		f.paths = append(f.paths, r.URL.Path)
+		f.headers = append(f.headers, r.Header.Clone())
+		f.bodies = append(f.bodies, body)
+		f.mu.Unlock()
+		respond(w, r)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeProvider) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// lastBody returns the body of the last request, decoded.
+func (f *fakeProvider) lastBody(t *testing.T) map[string]any {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.bodies) == 0 {
+		t.Fatalf("no request reached the fake provider")
+	}
+	var body map[string]any
+	if err := json.Unmarshal(f.bodies[len(f.bodies)-1], &body); err != nil {
+		t.Fatalf("request body is not JSON: %v", err)
+	}
+	return body
+}
+
+// ollamaChatAnswer answers an Ollama chat request with content.
+func ollamaChatAnswer(content string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		json.NewEncoder(w).Encode(map[string]any{
+			"model":             "llama3.2",
+			"message":           map[string]string{"role": "assistant", "content": content},
+			"done":              true,
+			"prompt_eval_count": 12,
+			"eval_count":        5,
+		})
+	}
+}
+
+func TestOllamaHost(t *testing.T) {
+	server := newFakeProvider(t, ollamaChatAnswer(`{"action":"clear"}`))
+	hostPort := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name string
+		host string
+		env  string
+	}{
+		{name: "url", host: server.URL},
+		{name: "host and port", host: hostPort},
+		{name: "padded", host: "  " + server.URL + "  "},
+		{name: "empty falls back to OLLAMA_HOST", host: "", env: server.URL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Anything that ignores the configured host ends up here.
+			t.Setenv("OLLAMA_HOST", "http://127.0.0.1:1")
+			if tt.env != "" {
+				t.Setenv("OLLAMA_HOST", tt.env)
+			}
+
+			client, err := NewOllamaLLMClient(tt.host, "llama3.2", testSettings, WorkerPool{})
+			if err != nil {
+				t.Fatalf("NewOllamaLLMClient(%q): %v", tt.host, err)
+			}
+			defer client.Close()
+
+			before := server.count()
+			resp, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+			if err != nil {
+				t.Fatalf("GenerateResponse: %v", err)
+			}
+			if server.count() != before+1 {
+				t.Fatalf("fake server got %d requests, want 1", server.count()-before)
+			}
+			if resp.Response != `{"action":"clear"}` {
+				t.Errorf("response = %q, want the fake server's answer", resp.Response)
+			}
+			if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 5 {
+				t.Errorf("usage = %+v, want 12 prompt and 5 completion tokens", resp.Usage)
+			}
+		})
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, path := range server.paths {
+		if path != "/api/chat" {
+			t.Errorf("request path = %q, want /api/chat", path)
+		}
+	}
+}
+
+func TestOllamaInvalidHost(t *testing.T) {
+	for _, host := range []string{"http://", "http://[::1", "://"} {
+		if _, err := NewOllamaLLMClient(host, "llama3.2", testSettings, WorkerPool{}); err == nil {
+			t.Errorf("NewOllamaLLMClient(%q) succeeded, want an error", host)
+		}
+	}
+}
+
+func TestOllamaStructuredOutput(t *testing.T) {
+	for _, structured := range []bool{true, false} {
+		server := newFakeProvider(t, ollamaChatAnswer(`{"action":"clear"}`))
+		settings := testSettings
+		settings.StructuredOutput = structured
+		client, err := NewOllamaLLMClient(server.URL, "llama3.2", settings, WorkerPool{})
+		if err != nil {
+			t.Fatalf("NewOllamaLLMClient: %v", err)
+		}
+		if _, err := client.GenerateResponse(context.Background(), "clear the board", "[]"); err != nil {
+			t.Fatalf("GenerateResponse: %v", err)
+		}
+		client.Close()
+
+		format, ok := server.lastBody(t)["format"]
+		if !structured {
+			if ok {
+				t.Errorf("format = %v without structured output, want none", format)
+			}
+			continue
+		}
+		assertSchema(t, format)
+	}
+}
+
+// TestOllamaContextWindow checks that requests ask for the context window
+// prompts are budgeted against, which Ollama's default is too small for.
+func TestOllamaContextWindow(t *testing.T) {
+	server := newFakeProvider(t, ollamaChatAnswer(`{"action":"clear"}`))
+	client, err := NewOllamaLLMClient(server.URL, "llama3.2", testSettings, WorkerPool{})
+	if err != nil {
+		t.Fatalf("NewOllamaLLMClient: %v", err)
+	}
+	defer client.Close()
+
+	resp, err := client.GenerateResponse(context.Background(), "make box-2 red", noisyBoard(3))
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if resp.CompactionLevel != CompactionFull {
+		t.Errorf("compaction level = %d for a small board, want %d", resp.CompactionLevel, CompactionFull)
+	}
+	options, _ := server.lastBody(t)["options"].(map[string]any)
+	if got := options["num_ctx"]; got != float64(ContextWindow(LLMProviderOllama, "llama3.2")) {
+		t.Errorf("num_ctx = %v, want %d", got, ContextWindow(LLMProviderOllama, "llama3.2"))
+	}
+}
+
+// assertSchema fails unless sent is whiteboardActionSchema.
+func assertSchema(t *testing.T, sent any) {
+	t.Helper()
+	var want any
+	if err := json.Unmarshal(whiteboardActionSchema, &want); err != nil {
+		t.Fatalf("whiteboardActionSchema is not JSON: %v", err)
+	}
+	got, _ := json.Marshal(sent)
+	wantJSON, _ := json.Marshal(want)
+	if string(got) != string(wantJSON) {
+		t.Errorf("schema sent = %s, want %s", got, wantJSON)
+	}
+}
+
+// blockingProvider holds every request until the client aborts it, and
+// reports the abort on aborted.
+func blockingProvider(t *testing.T) (*fakeProvider, <-chan struct{}, <-chan struct{}) {
+	t.Helper()
+	received := make(chan struct{}, 1)
+	aborted := make(chan struct{}, 1)
+	server := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+		case <-time.After(10 * time.Second):
+		}
+	})
+	return server, received, aborted
+}
+
+// assertCancelled cancels a call to generate once the provider has it, and
+// checks that the call returns and the HTTP request is aborted promptly.
+func assertCancelled(t *testing.T, received <-chan struct{}, aborted <-chan struct{}, generate func(ctx context.Context) error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- generate(ctx) }()
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the request never reached the provider")
+	}
+	cancelled := time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the call was still running a second after its context was cancelled")
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatalf("the HTTP request was not aborted")
+	}
+	if elapsed := time.Since(cancelled); elapsed > time.Second {
+		t.Errorf("took %s to abort, want well under the request timeout", elapsed)
+	}
+}
+
+func TestOllamaCancel(t *testing.T) {
+	server, received, aborted := blockingProvider(t)
+	client, err := NewOllamaLLMClient(server.URL, "llama3.2", testSettings, WorkerPool{})
+	if err != nil {
+		t.Fatalf("NewOllamaLLMClient: %v", err)
+	}
+	defer client.Close()
+
+	assertCancelled(t, received, aborted, func(ctx context.Context) error {
+		_, err := client.GenerateResponse(ctx, "clear the board", "[]")
+		return err
+	})
+}
@@ -3,64 +3,119 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
-	"draw/pkg/llm/prompts"
-
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 )
 
 type OpenAILLMClient struct {
 	client      openai.Client
+	provider    LLMProvider
 	model       string
+	settings    RequestSettings
 	requestChan chan llmRequest
 	ctx         context.Context
 	cancel      context.CancelFunc
 	closeOnce   sync.Once
+	wg          sync.WaitGroup
 }
 
-func NewOpenAILLMClient(baseURL, model, apiKey string) (*OpenAILLMClient, error) {
-	if strings.TrimSpace(apiKey) == "" {
-		return nil, fmt.Errorf("openai api key is required")
-	}
+// openAIBaseURL is used when no host is configured.
+const openAIBaseURL = "https://api.openai.com/v1"
+
+// NewOpenAILLMClient talks to OpenAI or any server that speaks its chat
+// completions protocol, such as vLLM, posting to {baseURL}/chat/completions.
+// The API key is only required for OpenAI itself; self-hosted servers often
+// run without one.
+func NewOpenAILLMClient(baseURL, model, apiKey string, settings RequestSettings, pool WorkerPool) (*OpenAILLMClient, error) {
 	if strings.TrimSpace(model) == "" {
 		return nil, fmt.Errorf("openai model is required")
 	}
 	if strings.TrimSpace(baseURL) == "" {
-		baseURL = "https://api.openai.com/v1"
+		baseURL = openAIBaseURL
+	}
+	if strings.TrimSpace(apiKey) == "" && strings.TrimRight(baseURL, "/") == openAIBaseURL {
+		return nil, fmt.Errorf("openai api key is required")
 	}
 
+	return newOpenAIClient(LLMProviderOpenAI, model, settings, pool,
+		// Set even when empty, so an OPENAI_API_KEY in the environment
+		// isn't sent to a self-hosted server.
+		option.WithAPIKey(apiKey),
+		option.WithBaseURL(baseURL),
+	), nil
+}
+
+// NewGenericOpenAILLMClient talks to a hosted server that speaks the OpenAI
+// chat completions protocol, such as Groq, Together or Fireworks, at
+// {baseURL}/chat/completions. The API key is sent as a bearer token, or
+// as-is in apiKeyHeader when that is set; extraHeaders are sent with every
+// request.
+func NewGenericOpenAILLMClient(baseURL, model, apiKey, apiKeyHeader string, extraHeaders map[string]string, settings RequestSettings, pool WorkerPool) (*OpenAILLMClient, error) {
+	if strings.TrimSpace(baseURL) == "" {
+		return nil, fmt.Errorf("generic-openai base url is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("generic-openai model is required")
+	}
+
+	opts := []option.RequestOption{
+		option.WithAPIKey(apiKey),
+		option.WithBaseURL(baseURL),
+	}
+	customHeader := apiKeyHeader != "" && !strings.EqualFold(apiKeyHeader, "Authorization")
+	if apiKey == "" || customHeader {
+		opts = append(opts, option.WithHeaderDel("Authorization"))
+	}
+	if apiKey != "" && customHeader {
+		opts = append(opts, option.WithHeader(apiKeyHeader, apiKey))
+	}
+	for name, value := range extraHeaders {
+		opts = append(opts, option.WithHeader(name, value))
+	}
+	return newOpenAIClient(LLMProviderGenericOpenAI, model, settings, pool, opts...), nil
+}
+
+func newOpenAIClient(provider LLMProvider, model string, settings RequestSettings, pool WorkerPool, opts ...option.RequestOption) *OpenAILLMClient {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	c := &OpenAILLMClient{
-		client: openai.NewClient(
-			option.WithAPIKey(apiKey),
-			option.WithBaseURL(baseURL),
-		),
+		client:      openai.NewClient(opts...),
+		provider:    provider,
 		model:       model,
-		requestChan: make(chan llmRequest, 10),
+		settings:    settings,
+		requestChan: make(chan llmRequest, pool.queueSize()),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 
-	go c.worker()
+	pool.start(&c.wg, c.worker)
 
-	return c, nil
+	return c
 }
 
 func (c *OpenAILLMClient) worker() {
+	defer c.wg.Done()
+
 	for {
 		select {
 		case <-c.ctx.Done():
+			rejectPending(c.requestChan)
 			return
 		case req := <-c.requestChan:
-			result, err := c.generateResponseSync(req.prompt, req.systemPrompt)
-			if err != nil {
+			if err := req.ctx.Err(); err != nil {
+				// The caller gave up while the request was queued.
 				req.errCh <- err
+				continue
+			}
+			result, err := c.generateResponseSync(req)
+			if err != nil {
+				req.errCh <- timeoutError(req, err)
 			} else {
 				req.resultCh <- result
 			}
@@ -69,6 +124,10 @@ func (c *OpenAILLMClient) worker() {
 }
 
 func (c *OpenAILLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *OpenAILLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
 	fmt.Println("Generating response for prompt", prompt, "and board state", boardState)
 	if strings.TrimSpace(prompt) == "" {
 		return nil, fmt.Errorf("empty text provided")
@@ -84,68 +143,137 @@ func (c *OpenAILLMClient) GenerateResponse(ctx context.Context, prompt string, b
 		}
 	}
 
-	userPrompt := prompts.BuildWhiteboardPrompt(prompt, boardStateJSON)
-	systemPrompt := prompts.WhiteboardSystemPrompt
+	if opts.ContextWindow == 0 {
+		opts.ContextWindow = ContextWindow(c.provider, c.model)
+	}
+	userPrompt := opts.BuildPrompt(prompt, boardStateJSON)
+	systemPrompt, promptVersion := c.settings.systemPrompt(opts.Mode)
 
+	if c.ctx.Err() != nil {
+		return nil, ErrClientClosed
+	}
 	resultCh := make(chan *LLMResponse, 1)
 	errCh := make(chan error, 1)
 
 	select {
 	case c.requestChan <- llmRequest{
-		prompt:       userPrompt,
+		ctx:          ctx,
+		prompt:       userPrompt.Text,
 		systemPrompt: systemPrompt,
+		options:      opts,
 		resultCh:     resultCh,
 		errCh:        errCh,
 	}:
 	case <-ctx.Done():
 		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	default:
+		return nil, ErrQueueFull
 	}
 
 	select {
 	case result := <-resultCh:
+		result.DroppedSections = userPrompt.Dropped
+		result.CompactionLevel = userPrompt.CompactionLevel
+		result.PromptVersion = promptVersion
 		return result, nil
 	case err := <-errCh:
 		return nil, err
 	case <-ctx.Done():
 		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
 	}
 }
 
-func (c *OpenAILLMClient) generateResponseSync(prompt string, systemPrompt string) (*LLMResponse, error) {
-	reqCtx, cancel := context.WithTimeout(c.ctx, 20*time.Second)
+func (c *OpenAILLMClient) generateResponseSync(llmReq llmRequest) (*LLMResponse, error) {
+	reqCtx, cancel := requestContext(llmReq, c.ctx, c.settings.Timeout)
 	defer cancel()
 
 	messages := []openai.ChatCompletionMessageParamUnion{}
-	if systemPrompt != "" {
-		messages = append(messages, openai.SystemMessage(systemPrompt))
+	if llmReq.systemPrompt != "" {
+		messages = append(messages, openai.SystemMessage(llmReq.systemPrompt))
+	}
+	for _, turn := range llmReq.options.History {
+		messages = append(messages, openai.UserMessage(turn.Instruction), openai.AssistantMessage(turn.Response))
 	}
-	messages = append(messages, openai.UserMessage(prompt))
+	messages = append(messages, openai.UserMessage(llmReq.prompt))
 
-	resp, err := c.client.Chat.Completions.New(reqCtx, openai.ChatCompletionNewParams{
+	params := openai.ChatCompletionNewParams{
 		Model:       openai.ChatModel(c.model),
 		Messages:    messages,
-		MaxTokens:   openai.Int(1024),
-		Temperature: openai.Float(0.2),
+		MaxTokens:   openai.Int(int64(c.settings.MaxTokens)),
+		Temperature: openai.Float(llmReq.options.temperature(c.settings.Temperature)),
 		TopP:        openai.Float(0.9),
-	})
+	}
+	if llmReq.options.Seed != nil {
+		params.Seed = openai.Int(*llmReq.options.Seed)
+	}
+
+	resp, err := c.client.Chat.Completions.New(reqCtx, params)
 	if err != nil {
-		return nil, fmt.Errorf("openai api request error: %w", err)
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) {
+			if class := statusError(apiErr.StatusCode); class != nil {
+				return nil, fmt.Errorf("%w: %s api request error: %w", class, c.provider, err)
+			}
+		}
+		return nil, fmt.Errorf("%s api request error: %w", c.provider, err)
 	}
 
 	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
-		return nil, fmt.Errorf("openai api returned empty response")
+		return nil, fmt.Errorf("%w: %s", ErrEmptyResponse, c.provider)
 	}
 
 	return &LLMResponse{
-		Response:  strings.TrimSpace(resp.Choices[0].Message.Content),
-		Timestamp: time.Now(),
+		Response:          strings.TrimSpace(resp.Choices[0].Message.Content),
+		Timestamp:         time.Now().UTC(),
+		Seed:              llmReq.options.Seed,
+		SystemFingerprint: resp.SystemFingerprint,
+		Provider:          string(c.provider),
+		Model:             c.model,
+		Usage: Usage{
+			PromptTokens:     int(resp.Usage.PromptTokens),
+			CompletionTokens: int(resp.Usage.CompletionTokens),
+		},
 	}, nil
 }
 
+// Ping lists the server's models and looks for the configured one. Listing
+// works on OpenAI and on self-hosted servers alike, which don't all serve
+// single models.
+func (c *OpenAILLMClient) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.settings.Timeout)
+	defer cancel()
+
+	page, err := c.client.Models.List(ctx)
+	if err != nil {
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) {
+			if class := statusError(apiErr.StatusCode); class != nil {
+				return fmt.Errorf("%w: %s api request error: %w", class, c.provider, err)
+			}
+		}
+		return fmt.Errorf("%s api request error: %w", c.provider, err)
+	}
+	for _, model := range page.Data {
+		if model.ID == c.model {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s model %q is not served at the configured host", ErrModelUnavailable, c.provider, c.model)
+}
+
+// QueueLength reports how many requests are waiting for a worker.
+func (c *OpenAILLMClient) QueueLength() int {
+	return len(c.requestChan)
+}
+
 func (c *OpenAILLMClient) Close() error {
 	c.closeOnce.Do(func() {
 		c.cancel()
-		close(c.requestChan)
+		c.wg.Wait()
 	})
 	return nil
 }
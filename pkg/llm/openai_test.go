@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// openAIChatAnswer answers a chat completion request with content.
+func openAIChatAnswer(content string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"model":   "served-model",
+			"choices": []any{map[string]any{"index": 0, "finish_reason": "stop", "message": map[string]string{"role": "assistant", "content": content}}},
+			"usage":   map[string]int{"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17},
+		})
+	}
+}
+
+func TestOpenAIPayload(t *testing.T) {
+	server := newFakeProvider(t, openAIChatAnswer(`{"action":"clear"}`))
+	client, err := NewOpenAILLMClient(server.URL+"/v1", "served-model", "secret", testSettings, WorkerPool{})
+	if err != nil {
+		t.Fatalf("NewOpenAILLMClient: %v", err)
+	}
+	defer client.Close()
+
+	resp, err := client.GenerateResponse(context.Background(), "clear the board", `[{"id":"a","type":"rectangle","x":0,"y":0}]`)
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if resp.Response != `{"action":"clear"}` || resp.Provider != string(LLMProviderOpenAI) {
+		t.Errorf("response = %q from %q, want the fake server's answer from openai", resp.Response, resp.Provider)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 5 {
+		t.Errorf("usage = %+v, want 12 prompt and 5 completion tokens", resp.Usage)
+	}
+
+	server.mu.Lock()
+	path, auth := server.paths[0], server.headers[0].Get("Authorization")
+	server.mu.Unlock()
+	if path != "/v1/chat/completions" {
+		t.Errorf("path = %q, want /v1/chat/completions", path)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the API key as a bearer token", auth)
+	}
+
+	body := server.lastBody(t)
+	if body["model"] != "served-model" {
+		t.Errorf("model = %v, want served-model", body["model"])
+	}
+	messages, _ := body["messages"].([]any)
+	if len(messages) != 2 {
+		t.Fatalf("sent %d messages, want a system and a user message", len(messages))
+	}
+	for i, role := range []string{"system", "user"} {
+		message, _ := messages[i].(map[string]any)
+		if message["role"] != role {
+			t.Errorf("message %d role = %v, want %s", i, message["role"], role)
+		}
+	}
+	user, _ := messages[1].(map[string]any)
+	content, _ := user["content"].(string)
+	if !strings.Contains(content, "clear the board") || !strings.Contains(content, `"rectangle"`) {
+		t.Errorf("user message = %q, want the instruction and the board state", content)
+	}
+}
+
+func TestOpenAIErrorStatus(t *testing.T) {
+	tests := []struct {
+		status   int
+		want     error
+		requests int
+	}{
+		{status: http.StatusBadRequest, requests: 1},
+		{status: http.StatusUnauthorized, want: ErrAuth, requests: 1},
+		{status: http.StatusTooManyRequests, want: ErrProviderThrottled, requests: 3},
+		{status: http.StatusServiceUnavailable, want: ErrProviderUnavailable, requests: 3},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := newFakeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				// Keeps the SDK's own retries quick.
+				w.Header().Set("Retry-After-Ms", "1")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"error":{"message":"nope","type":"invalid_request_error"}}`))
+			})
+			client, err := NewOpenAILLMClient(server.URL, "served-model", "", testSettings, WorkerPool{})
+			if err != nil {
+				t.Fatalf("NewOpenAILLMClient: %v", err)
+			}
+			defer client.Close()
+
+			_, err = client.GenerateResponse(context.Background(), "clear the board", "[]")
+			if err == nil {
+				t.Fatalf("GenerateResponse succeeded on a %d", tt.status)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+			for _, class := range []error{ErrAuth, ErrProviderThrottled, ErrProviderUnavailable} {
+				if class != tt.want && errors.Is(err, class) {
+					t.Errorf("error = %v, want it not to be %v", err, class)
+				}
+			}
+			if !strings.Contains(err.Error(), "openai api request error") {
+				t.Errorf("error = %v, want it to name the provider", err)
+			}
+			if got := server.count(); got != tt.requests {
+				t.Errorf("server got %d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"encoding/json"
+)
+
+// ElementStreamParser incrementally scans a whiteboard action as it streams in
+// and reports every object in the top-level "elements" array as soon as its
+// closing brace arrives. Anything before the root object (prose, code fences)
+// is ignored. Elements reported this way are provisional: the action as a
+// whole may still fail to parse or validate.
+type ElementStreamParser struct {
+	onElement func(Element)
+
+	buf           []byte
+	stack         []byte
+	inString      bool
+	escaped       bool
+	stringStart   int
+	expectKey     bool
+	lastKey       string
+	elementsDepth int
+	elementStart  int
+	done          bool
+}
+
+func NewElementStreamParser(onElement func(Element)) *ElementStreamParser {
+	return &ElementStreamParser{
+		onElement:    onElement,
+		elementStart: -1,
+	}
+}
+
+// Write feeds the next chunk of model output into the parser. It never fails;
+// malformed input simply produces no elements.
+func (p *ElementStreamParser) Write(chunk []byte) (int, error) {
+	for _, b := range chunk {
+		p.feed(b)
+	}
+	return len(chunk), nil
+}
+
+// WriteString is a convenience wrapper around Write.
+func (p *ElementStreamParser) WriteString(chunk string) {
+	p.Write([]byte(chunk))
+}
+
+func (p *ElementStreamParser) feed(b byte) {
+	if p.done {
+		return
+	}
+
+	if len(p.stack) == 0 && b != '{' {
+		// Still outside the root object.
+		return
+	}
+
+	p.buf = append(p.buf, b)
+	pos := len(p.buf) - 1
+
+	if p.inString {
+		switch {
+		case p.escaped:
+			p.escaped = false
+		case b == '\\':
+			p.escaped = true
+		case b == '"':
+			p.inString = false
+			p.endString(pos)
+		}
+		return
+	}
+
+	switch b {
+	case '"':
+		p.inString = true
+		p.stringStart = pos
+	case '{':
+		if p.elementsDepth > 0 && len(p.stack) == p.elementsDepth && p.elementStart < 0 {
+			p.elementStart = pos
+		}
+		p.stack = append(p.stack, '{')
+		p.expectKey = true
+	case '[':
+		if len(p.stack) == 1 && p.lastKey == "elements" {
+			p.elementsDepth = len(p.stack) + 1
+		}
+		p.stack = append(p.stack, '[')
+		p.expectKey = false
+	case '}', ']':
+		if len(p.stack) == 0 {
+			return
+		}
+		p.stack = p.stack[:len(p.stack)-1]
+		if b == ']' && len(p.stack)+1 == p.elementsDepth {
+			p.elementsDepth = 0
+		}
+		if b == '}' && p.elementStart >= 0 && len(p.stack) == p.elementsDepth {
+			p.emit(p.buf[p.elementStart : pos+1])
+			p.elementStart = -1
+		}
+		if len(p.stack) == 0 {
+			p.done = true
+		}
+		p.expectKey = false
+	case ',':
+		p.expectKey = len(p.stack) > 0 && p.stack[len(p.stack)-1] == '{'
+	}
+}
+
+func (p *ElementStreamParser) endString(pos int) {
+	if !p.expectKey {
+		return
+	}
+	p.expectKey = false
+	if len(p.stack) != 1 {
+		return
+	}
+	var key string
+	if err := json.Unmarshal(p.buf[p.stringStart:pos+1], &key); err == nil {
+		p.lastKey = key
+	}
+}
+
+func (p *ElementStreamParser) emit(raw []byte) {
+	if p.onElement == nil {
+		return
+	}
+	var element Element
+	if err := json.Unmarshal(raw, &element); err != nil {
+		return
+	}
+	p.onElement(element)
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestElementStreamParser(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ids   []string
+		texts []string
+	}{
+		{
+			name: "whitespace and a code fence",
+			input: "Here you go:\n```json\n{ \"action\" : \"add\" ,\n  \"elements\" : [\n" +
+				"    { \"id\" : \"a\" , \"type\" : \"rectangle\" , \"x\" : 0 , \"y\" : 0 } ,\n" +
+				"\t{\"id\":\"b\",\"type\":\"ellipse\",\"x\":10,\"y\":10}\n  ]\n}\n```",
+			ids:   []string{"a", "b"},
+			texts: []string{"", ""},
+		},
+		{
+			name:  "escaped strings",
+			input: `{"action":"add","elements":[{"id":"a","type":"text","x":0,"y":0,"text":"say \"}]\" and {\\"},{"id":"b","type":"text","x":0,"y":0,"text":"C:\\path\\"}]}`,
+			ids:   []string{"a", "b"},
+			texts: []string{`say "}]" and {\`, `C:\path\`},
+		},
+		{
+			name:  "nested label objects",
+			input: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"label":{"text":"Start {here}","fontSize":16},"start":{"id":"b"}},{"id":"b","type":"rectangle","x":0,"y":0,"label":{"text":"End"}}]}`,
+			ids:   []string{"a", "b"},
+			texts: []string{"Start {here}", "End"},
+		},
+		{
+			name:  "elements key inside an element",
+			input: `{"action":"add","elements":[{"id":"a","type":"frame","x":0,"y":0,"customData":{"elements":[{"id":"inner","type":"text"}]}}]}`,
+			ids:   []string{"a"},
+			texts: []string{""},
+		},
+		{
+			name:  "elements as a value",
+			input: `{"action":"elements","delete_ids":[{"id":"a","type":"text"}]}`,
+		},
+		{
+			name:  "malformed element skipped",
+			input: `{"action":"add","elements":[{"id":1,"type":"rectangle"},{"id":"b","type":"ellipse","x":0,"y":0}]}`,
+			ids:   []string{"b"},
+			texts: []string{""},
+		},
+		{
+			name:  "input after the root object ignored",
+			input: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0}]} {"action":"add","elements":[{"id":"b","type":"rectangle"}]}`,
+			ids:   []string{"a"},
+			texts: []string{""},
+		},
+		{
+			name:  "truncated stream",
+			input: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0},{"id":"b","type":"rect`,
+			ids:   []string{"a"},
+			texts: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Element
+			fed := 0
+			parser := NewElementStreamParser(func(element Element) {
+				// Each element is reported as soon as its closing brace arrives.
+				if tt.input[fed-1] != '}' {
+					t.Errorf("element %s reported after %q, want right after its closing brace", element.ID, tt.input[:fed])
+				}
+				got = append(got, element)
+			})
+			for fed < len(tt.input) {
+				fed++
+				parser.Write([]byte{tt.input[fed-1]})
+			}
+
+			if len(got) != len(tt.ids) {
+				t.Fatalf("got %d elements, want %d: %+v", len(got), len(tt.ids), got)
+			}
+			for i, element := range got {
+				if element.ID != tt.ids[i] {
+					t.Errorf("element %d id = %q, want %q", i, element.ID, tt.ids[i])
+				}
+				text := element.Text
+				if element.Label != nil {
+					text = element.Label.Text
+				}
+				if text != tt.texts[i] {
+					t.Errorf("element %d text = %q, want %q", i, text, tt.texts[i])
+				}
+			}
+
+			// Feeding it all at once reports the same elements.
+			var whole []string
+			NewElementStreamParser(func(element Element) {
+				whole = append(whole, element.ID)
+			}).WriteString(tt.input)
+			if strings.Join(whole, ",") != strings.Join(tt.ids, ",") {
+				t.Errorf("whole input reported %v, want %v", whole, tt.ids)
+			}
+		})
+	}
+}
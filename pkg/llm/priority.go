@@ -0,0 +1,234 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Priority decides which lane a request waits in.
+type Priority int
+
+const (
+	// PriorityInteractive is for requests a user is actively waiting on,
+	// such as voice commands. It is the default.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is for work nobody is watching (summaries, notes).
+	// Background requests only run when no interactive request is waiting.
+	PriorityBackground
+)
+
+// ErrQueueFull is returned when a request's lane, or a provider client's
+// queue, is at capacity. The request is refused at once rather than applied
+// long after it was made.
+var ErrQueueFull = errors.New("llm request queue is full")
+
+type priorityKey struct{}
+
+// WithPriority marks ctx so PriorityLLMClient queues requests made with it in
+// the given lane.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityInteractive
+}
+
+type queuedRequest struct {
+	ctx        context.Context
+	enqueuedAt time.Time
+	run        func(ctx context.Context) (*LLMResponse, error)
+	resultCh   chan *LLMResponse
+	errCh      chan error
+}
+
+// PriorityLLMClient wraps an LLMClient with separate interactive and
+// background lanes. A single dispatcher feeds the inner client up to
+// concurrency requests at a time, always draining the interactive lane
+// first, so background work can't starve voice commands.
+type PriorityLLMClient struct {
+	inner       LLMClient
+	interactive chan queuedRequest
+	background  chan queuedRequest
+	slots       chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+func NewPriorityLLMClient(inner LLMClient, interactiveCapacity int, backgroundCapacity int, concurrency int) *PriorityLLMClient {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &PriorityLLMClient{
+		inner:       inner,
+		interactive: make(chan queuedRequest, interactiveCapacity),
+		background:  make(chan queuedRequest, backgroundCapacity),
+		slots:       make(chan struct{}, concurrency),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	c.wg.Add(1)
+	go c.dispatch()
+
+	return c
+}
+
+func (c *PriorityLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *PriorityLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	return c.submit(ctx, func(ctx context.Context) (*LLMResponse, error) {
+		return c.inner.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+	})
+}
+
+// GenerateResponseStream streams through the inner client when it supports
+// streaming; otherwise onChunk is never called.
+func (c *PriorityLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	return c.submit(ctx, func(ctx context.Context) (*LLMResponse, error) {
+		if streamer, ok := c.inner.(StreamingLLMClient); ok {
+			return streamer.GenerateResponseStream(ctx, prompt, boardState, opts, onChunk)
+		}
+		return c.inner.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+	})
+}
+
+// QueueDepth reports how many requests are waiting in each lane.
+func (c *PriorityLLMClient) QueueDepth() (interactive int, background int) {
+	return len(c.interactive), len(c.background)
+}
+
+// Ping goes straight to the wrapped client, without waiting for a slot.
+func (c *PriorityLLMClient) Ping(ctx context.Context) error {
+	return c.inner.Ping(ctx)
+}
+
+func (c *PriorityLLMClient) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.wg.Wait()
+	})
+	return c.inner.Close()
+}
+
+func (c *PriorityLLMClient) submit(ctx context.Context, run func(ctx context.Context) (*LLMResponse, error)) (*LLMResponse, error) {
+	lane := c.interactive
+	if priorityFrom(ctx) == PriorityBackground {
+		lane = c.background
+	}
+
+	req := queuedRequest{
+		ctx:        ctx,
+		enqueuedAt: time.Now(),
+		run:        run,
+		resultCh:   make(chan *LLMResponse, 1),
+		errCh:      make(chan error, 1),
+	}
+
+	if c.ctx.Err() != nil {
+		return nil, ErrClientClosed
+	}
+	select {
+	case lane <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	default:
+		return nil, ErrQueueFull
+	}
+
+	select {
+	case result := <-req.resultCh:
+		return result, nil
+	case err := <-req.errCh:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	}
+}
+
+func (c *PriorityLLMClient) dispatch() {
+	defer c.wg.Done()
+
+	for {
+		// Wait for a free slot before picking a request, so the choice
+		// between the lanes is made as late as possible.
+		select {
+		case c.slots <- struct{}{}:
+		case <-c.ctx.Done():
+			c.rejectPending()
+			return
+		}
+
+		// Interactive requests always go first; only fall through to the
+		// shared select when that lane is empty.
+		var req queuedRequest
+		select {
+		case req = <-c.interactive:
+		default:
+			select {
+			case <-c.ctx.Done():
+				c.rejectPending()
+				return
+			case req = <-c.interactive:
+			case req = <-c.background:
+			}
+		}
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			defer func() { <-c.slots }()
+			c.run(req)
+		}()
+	}
+}
+
+// rejectPending fails the requests still queued in either lane once the
+// client is closed.
+func (c *PriorityLLMClient) rejectPending() {
+	for {
+		select {
+		case req := <-c.interactive:
+			req.errCh <- ErrClientClosed
+		case req := <-c.background:
+			req.errCh <- ErrClientClosed
+		default:
+			return
+		}
+	}
+}
+
+func (c *PriorityLLMClient) run(req queuedRequest) {
+	if err := req.ctx.Err(); err != nil {
+		// The caller gave up while the request was queued.
+		req.errCh <- err
+		return
+	}
+
+	wait := time.Since(req.enqueuedAt)
+	result, err := req.run(req.ctx)
+	if err != nil {
+		req.errCh <- err
+	} else {
+		result.QueueWait = wait
+		if result.ParsedAction == nil {
+			result.ParsedAction, _ = ParseWhiteboardAction(result.Response)
+		}
+		req.resultCh <- result
+	}
+}
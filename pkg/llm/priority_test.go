@@ -0,0 +1,274 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedClient answers each request after delay, once release is closed,
+// and records the prompts in the order it started them.
+type gatedClient struct {
+	delay   time.Duration
+	release chan struct{}
+
+	mu      sync.Mutex
+	started []string
+}
+
+func newGatedClient(delay time.Duration) *gatedClient {
+	return &gatedClient{delay: delay, release: make(chan struct{})}
+}
+
+func (c *gatedClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *gatedClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	c.mu.Lock()
+	c.started = append(c.started, prompt)
+	c.mu.Unlock()
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	time.Sleep(c.delay)
+	return &LLMResponse{Response: `{"action":"clear"}`}, nil
+}
+
+func (c *gatedClient) Ping(ctx context.Context) error { return nil }
+func (c *gatedClient) Close() error                   { return nil }
+
+func (c *gatedClient) order() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.started...)
+}
+
+// waitForDepth waits until the client's lanes hold the given numbers of
+// requests.
+func waitForDepth(t *testing.T, client *PriorityLLMClient, interactive int, background int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		gotInteractive, gotBackground := client.QueueDepth()
+		if gotInteractive == interactive && gotBackground == background {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue depth = %d interactive, %d background, want %d and %d", gotInteractive, gotBackground, interactive, background)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPriorityLanesOrder(t *testing.T) {
+	inner := newGatedClient(0)
+	client := NewPriorityLLMClient(inner, 10, 10, 1)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	submit := func(prompt string, priority Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GenerateResponse(WithPriority(context.Background(), priority), prompt, "[]"); err != nil {
+				t.Errorf("%s: %v", prompt, err)
+			}
+		}()
+	}
+
+	// The first request holds the only slot while the rest queue behind it.
+	submit("running", PriorityBackground)
+	for len(inner.order()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for _, prompt := range []string{"b1", "b2", "b3"} {
+		submit(prompt, PriorityBackground)
+	}
+	waitForDepth(t, client, 0, 3)
+	submit("i1", PriorityInteractive)
+	waitForDepth(t, client, 1, 3)
+	submit("i2", PriorityInteractive)
+	waitForDepth(t, client, 2, 3)
+
+	close(inner.release)
+	wg.Wait()
+
+	order := inner.order()
+	if len(order) != 6 || order[1] != "i1" && order[1] != "i2" || order[2] != "i1" && order[2] != "i2" {
+		t.Errorf("started %v, want both interactive requests right after the running one", order)
+	}
+}
+
+func TestPriorityInteractiveLatencyBounded(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	inner := newGatedClient(delay)
+	close(inner.release)
+	client := NewPriorityLLMClient(inner, 10, 100, 2)
+	defer client.Close()
+
+	// Saturate the background lane with ~1s of work.
+	ctx, cancel := context.WithCancel(WithPriority(context.Background(), PriorityBackground))
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.GenerateResponse(ctx, "background", "[]")
+		}()
+	}
+	defer wg.Wait()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, background := client.QueueDepth(); background < 50; _, background = client.QueueDepth() {
+		if time.Now().After(deadline) {
+			t.Fatalf("background lane holds %d requests, want it saturated", background)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if _, err := client.GenerateResponse(context.Background(), "interactive", "[]"); err != nil {
+			t.Fatalf("interactive request: %v", err)
+		}
+		// At worst it waits for a running background request to finish.
+		if latency := time.Since(start); latency > 10*delay {
+			t.Errorf("interactive request %d took %v behind the background lane, want under %v", i, latency, 10*delay)
+		}
+	}
+	if _, background := client.QueueDepth(); background == 0 {
+		t.Errorf("background lane drained before the interactive requests ran")
+	}
+}
+
+// TestPriorityConcurrency checks that the dispatcher runs as many requests
+// at once as it is configured for, rather than one at a time in front of the
+// provider's workers.
+func TestPriorityConcurrency(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	tests := []struct {
+		concurrency int
+		rounds      int
+	}{
+		{concurrency: 1, rounds: 4},
+		{concurrency: 2, rounds: 2},
+		{concurrency: 4, rounds: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.concurrency), func(t *testing.T) {
+			inner := newGatedClient(delay)
+			close(inner.release)
+			client := NewPriorityLLMClient(inner, 4, 4, tt.concurrency)
+			defer client.Close()
+
+			start := time.Now()
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := client.GenerateResponse(context.Background(), "clear", "[]"); err != nil {
+						t.Errorf("GenerateResponse: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			want := time.Duration(tt.rounds) * delay
+			if elapsed := time.Since(start); elapsed < want || elapsed >= want+delay {
+				t.Errorf("4 requests took %v, want about %v", elapsed, want)
+			}
+		})
+	}
+}
+
+func TestPriorityLaneCapacity(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority Priority
+	}{
+		{name: "interactive", priority: PriorityInteractive},
+		{name: "background", priority: PriorityBackground},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := newGatedClient(0)
+			client := NewPriorityLLMClient(inner, 1, 1, 1)
+			defer client.Close()
+			defer close(inner.release)
+
+			ctx := WithPriority(context.Background(), tt.priority)
+			errs := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					_, err := client.GenerateResponse(ctx, "queued", "[]")
+					errs <- err
+				}()
+				// One runs, the next fills the lane.
+				for len(inner.order()) == 0 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			if tt.priority == PriorityInteractive {
+				waitForDepth(t, client, 1, 0)
+			} else {
+				waitForDepth(t, client, 0, 1)
+			}
+
+			if _, err := client.GenerateResponse(ctx, "refused", "[]"); !errors.Is(err, ErrQueueFull) {
+				t.Errorf("got %v from a full lane, want ErrQueueFull", err)
+			}
+
+			// The other lane still takes requests.
+			other := PriorityBackground
+			if tt.priority == PriorityBackground {
+				other = PriorityInteractive
+			}
+			go client.GenerateResponse(WithPriority(context.Background(), other), "other", "[]")
+			waitForDepth(t, client, 1, 1)
+		})
+	}
+}
+
+func TestPriorityCloseRejectsQueued(t *testing.T) {
+	inner := newGatedClient(0)
+	client := NewPriorityLLMClient(inner, 5, 5, 1)
+
+	errs := make(chan error, 3)
+	for _, priority := range []Priority{PriorityInteractive, PriorityBackground, PriorityBackground} {
+		go func() {
+			_, err := client.GenerateResponse(WithPriority(context.Background(), priority), "queued", "[]")
+			errs <- err
+		}()
+	}
+	for {
+		interactive, background := client.QueueDepth()
+		if len(inner.order()) == 1 && interactive+background == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		client.Close()
+		close(closed)
+	}()
+	for i := 0; i < 3; i++ {
+		if err := <-errs; !errors.Is(err, ErrClientClosed) && !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v after Close, want ErrClientClosed", err)
+		}
+	}
+	close(inner.release)
+	<-closed
+
+	if _, err := client.GenerateResponse(context.Background(), "late", "[]"); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("got %v after Close, want ErrClientClosed", err)
+	}
+}
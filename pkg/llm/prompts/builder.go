@@ -0,0 +1,133 @@
+package prompts
+
+import (
+	"sort"
+	"strings"
+)
+
+// PriorityRequired marks sections that are always included, whatever the
+// budget, such as the board state and the instruction itself.
+const PriorityRequired = -1
+
+// responseFooter ends every whiteboard prompt.
+const responseFooter = `## YOUR RESPONSE (JSON ONLY, NO OTHER TEXT):`
+
+// PromptSection is one named block of the user prompt.
+type PromptSection struct {
+	// Name identifies the section in the dropped list, e.g. "referents".
+	Name  string
+	Title string
+	// Content is the section body. Optional sections with no content are
+	// left out without counting as dropped.
+	Content string
+	// Priority decides which optional sections are kept when the budget is
+	// tight: higher first, ties in the order they were added.
+	Priority int
+	// Tokens is the section's estimated size; 0 estimates it from the text.
+	Tokens int
+}
+
+func (s PromptSection) render() string {
+	return "## " + s.Title + "\n" + s.Content + "\n\n"
+}
+
+func (s PromptSection) tokens() int {
+	if s.Tokens > 0 {
+		return s.Tokens
+	}
+	return EstimateTokens(s.render())
+}
+
+// Prompt is a built prompt and the sections left out of it.
+type Prompt struct {
+	Text     string
+	Tokens   int
+	Dropped  []string
+	Sections []SectionReport
+	// CompactionLevel is how far the board state was compacted to fit; it
+	// is set by the caller that did the compacting.
+	CompactionLevel int
+}
+
+// SectionReport describes how one section fared in a build.
+type SectionReport struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+	Tokens   int    `json:"tokens"`
+	Included bool   `json:"included"`
+}
+
+// PromptBuilder assembles the user prompt from ordered sections. Sections
+// appear in the order they were added; the budget only decides which
+// optional ones are kept.
+type PromptBuilder struct {
+	sections []PromptSection
+}
+
+func NewPromptBuilder() *PromptBuilder {
+	return &PromptBuilder{}
+}
+
+// Add appends a section.
+func (b *PromptBuilder) Add(section PromptSection) *PromptBuilder {
+	b.sections = append(b.sections, section)
+	return b
+}
+
+// Build renders the prompt within budget estimated tokens. Required sections
+// and the response footer are always included; optional sections are then
+// added by priority while they fit. A budget of 0 or less includes
+// everything.
+func (b *PromptBuilder) Build(budget int) Prompt {
+	prompt := Prompt{Tokens: EstimateTokens(responseFooter)}
+
+	included := make([]bool, len(b.sections))
+	var optional []int
+	for i, section := range b.sections {
+		if section.Priority == PriorityRequired {
+			included[i] = true
+			prompt.Tokens += section.tokens()
+			continue
+		}
+		if section.Content != "" {
+			optional = append(optional, i)
+		}
+	}
+	sort.SliceStable(optional, func(i, j int) bool {
+		return b.sections[optional[i]].Priority > b.sections[optional[j]].Priority
+	})
+	for _, i := range optional {
+		tokens := b.sections[i].tokens()
+		if budget > 0 && prompt.Tokens+tokens > budget {
+			prompt.Dropped = append(prompt.Dropped, b.sections[i].Name)
+			continue
+		}
+		included[i] = true
+		prompt.Tokens += tokens
+	}
+
+	var text strings.Builder
+	for i, section := range b.sections {
+		if section.Priority != PriorityRequired && section.Content == "" {
+			continue
+		}
+		prompt.Sections = append(prompt.Sections, SectionReport{
+			Name:     section.Name,
+			Priority: section.Priority,
+			Tokens:   section.tokens(),
+			Included: included[i],
+		})
+		if included[i] {
+			text.WriteString(section.render())
+		}
+	}
+	text.WriteString(responseFooter)
+	prompt.Text = text.String()
+	return prompt
+}
+
+// EstimateTokens approximates the token count of s at four characters per
+// token, which is close enough for budgeting across providers.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
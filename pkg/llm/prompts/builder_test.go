@@ -0,0 +1,133 @@
+package prompts
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with the golden file name in testdata, or rewrites it
+// with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("failed to create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s, run with -update to create it: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file, run with -update if the change is intended:\n%s", path, got)
+	}
+}
+
+// TestBuildWhiteboardPromptGolden pins the prompt built from the legacy
+// sections. The golden file was written by the string concatenation the
+// builder replaced, so it must not be regenerated to make a change pass.
+func TestBuildWhiteboardPromptGolden(t *testing.T) {
+	got := BuildWhiteboardPrompt(
+		"connect the login box to the API and label it with tomorrow's date",
+		`[{"id":"login","type":"rectangle","x":100,"y":100,"width":160,"height":80,"label":{"text":"Login"}},{"id":"api","type":"ellipse","x":400,"y":100,"width":120,"height":80,"label":{"text":"API"}}]`,
+		Section{Title: "LIKELY REFERENTS", Lines: []string{`"the login box" -> login`, `"the API" -> api`}},
+		Section{Title: "SUBSTITUTIONS (use these exact values)"},
+		Section{Title: "SUBSTITUTIONS (use these exact values)", Lines: []string{`"tomorrow" = 16.10.2026`}},
+	)
+	golden(t, "whiteboard_prompt.golden", []byte(got))
+}
+
+func TestPromptBuilderBudget(t *testing.T) {
+	// Each optional section renders as "## X\n" + 12 bytes + "\n\n": 20
+	// bytes, 5 tokens. The required ones and the footer come to 24 tokens.
+	body := strings.Repeat("x", 12)
+	newBuilder := func() *PromptBuilder {
+		return NewWhiteboardPrompt("do it", "[]").
+			Add(PromptSection{Name: "low", Title: "L", Content: body, Priority: 1}).
+			Add(PromptSection{Name: "high", Title: "H", Content: body, Priority: 3}).
+			Add(PromptSection{Name: "empty", Title: "E", Priority: 5}).
+			Add(PromptSection{Name: "mid", Title: "M", Content: body, Priority: 2}).
+			Add(PromptSection{Name: "tie", Title: "T", Content: body, Priority: 2})
+	}
+	base := newBuilder().Build(1).Tokens
+
+	tests := []struct {
+		name    string
+		budget  int
+		titles  string
+		dropped string
+	}{
+		{name: "no budget", budget: 0, titles: "L,H,M,T"},
+		{name: "room for all", budget: base + 20, titles: "L,H,M,T"},
+		{name: "room for three", budget: base + 15, titles: "H,M,T", dropped: "low"},
+		{name: "ties in order added", budget: base + 10, titles: "H,M", dropped: "tie,low"},
+		{name: "room for one", budget: base + 9, titles: "H", dropped: "mid,tie,low"},
+		{name: "required sections still kept", budget: 1, dropped: "high,mid,tie,low"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := newBuilder().Build(tt.budget)
+
+			var titles []string
+			for _, line := range strings.Split(prompt.Text, "\n") {
+				title, ok := strings.CutPrefix(line, "## ")
+				if ok && len(title) == 1 {
+					titles = append(titles, title)
+				}
+			}
+			if got := strings.Join(titles, ","); got != tt.titles {
+				t.Errorf("sections %q, want %q", got, tt.titles)
+			}
+			if got := strings.Join(prompt.Dropped, ","); got != tt.dropped {
+				t.Errorf("dropped %q, want %q", got, tt.dropped)
+			}
+			for _, required := range []string{"## CURRENT BOARD STATE\n[]\n", "## USER INSTRUCTION\ndo it\n"} {
+				if !strings.Contains(prompt.Text, required) {
+					t.Errorf("prompt lacks %q", required)
+				}
+			}
+			if !strings.HasSuffix(prompt.Text, responseFooter) {
+				t.Errorf("prompt doesn't end with the response footer")
+			}
+			if tt.budget > 1 && prompt.Tokens > tt.budget {
+				t.Errorf("prompt is %d tokens, over the budget of %d", prompt.Tokens, tt.budget)
+			}
+
+			// Every section with content is reported, the empty one isn't.
+			included := 0
+			for _, section := range prompt.Sections {
+				if section.Name == "empty" {
+					t.Errorf("empty section reported")
+				}
+				if section.Included {
+					included++
+				}
+			}
+			if len(prompt.Sections) != 6 || included != 2+len(titles) {
+				t.Errorf("reported %d sections, %d included; want 6, %d included", len(prompt.Sections), included, 2+len(titles))
+			}
+		})
+	}
+}
+
+func TestPromptSectionTokens(t *testing.T) {
+	section := PromptSection{Title: "T", Content: strings.Repeat("x", 12)}
+	if got := section.tokens(); got != 5 {
+		t.Errorf("estimated %d tokens, want 5", got)
+	}
+	section.Tokens = 40
+	if got := section.tokens(); got != 40 {
+		t.Errorf("got %d tokens, want the given 40", got)
+	}
+}
@@ -0,0 +1,139 @@
+package prompts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode selects what the model draws for an instruction. Every mode answers
+// with the same JSON; they differ in the layout rules and the examples.
+type Mode string
+
+const (
+	// ModeDiagram draws shapes connected by arrows. It is the default.
+	ModeDiagram Mode = "diagram"
+	// ModeNotes writes a column of headings and bullet points.
+	ModeNotes Mode = "notes"
+	// ModeMindmap branches topics out from a central idea.
+	ModeMindmap Mode = "mindmap"
+)
+
+// Modes lists every mode, default first.
+var Modes = []Mode{ModeDiagram, ModeNotes, ModeMindmap}
+
+// ParseMode reads a mode name, case-insensitively. An empty name is
+// ModeDiagram.
+func ParseMode(name string) (Mode, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return ModeDiagram, nil
+	}
+	for _, mode := range Modes {
+		if string(mode) == name {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown mode %q", name)
+}
+
+// SystemPrompt returns the built-in system prompt for mode, falling back to
+// WhiteboardSystemPrompt for an unknown one.
+func SystemPrompt(mode Mode) string {
+	switch mode {
+	case ModeNotes:
+		return NotesSystemPrompt
+	case ModeMindmap:
+		return MindmapSystemPrompt
+	default:
+		return WhiteboardSystemPrompt
+	}
+}
+
+// NotesSystemPrompt turns dictation into notes: a title and bullet points
+// written as text elements in a column.
+var NotesSystemPrompt = `You convert spoken notes into Excalidraw whiteboard text. Return ONLY valid JSON, no other text.
+
+` + outputContract + "\n\n" + elementTypes + "\n\n" + hallucinationRules + "\n\n" + transforms + "\n\n" + layering + "\n\n" + templateLibrary + "\n\n" +
+	notesLayout + "\n\n" + palette + "\n\n" + speechHandling + "\n\n" + multipleInstructions + "\n\n" + notesExamples + "\n\n" + finalReminders
+
+// MindmapSystemPrompt turns speech into a mind map: a central topic with
+// branches connected to it by arrows.
+var MindmapSystemPrompt = `You convert spoken ideas into an Excalidraw mind map. Return ONLY valid JSON, no other text.
+
+` + outputContract + "\n\n" + elementTypes + "\n\n" + hallucinationRules + "\n\n" + transforms + "\n\n" + layering + "\n\n" + templateLibrary + "\n\n" +
+	mindmapLayout + "\n\n" + palette + "\n\n" + speechHandling + "\n\n" + multipleInstructions + "\n\n" + mindmapExamples + "\n\n" + finalReminders
+
+const notesLayout = `## NOTES LAYOUT
+- Write notes as "text" elements, one per line; do not draw shapes unless asked
+- Title: fontSize 28, at x:100, y:100 on an empty board
+- Bullet points: fontSize 20, text starts with "• ", same x as the title, 40px below the line above
+- Sub-points: indent 40px to the right of their bullet, prefix "– "
+- Existing notes: continue below the lowest text element, same x, 40px further down
+- Keep each line short; split long sentences into several bullets
+- "new section" or "heading" = another title-sized line, 60px below the line above`
+
+const notesExamples = `## EXAMPLES
+
+### Example 1: Start Notes
+Instruction: "Meeting notes. Launch is moved to March, uh, and marketing needs the new screenshots"
+Board: []
+Response:
+{"action":"add","elements":[{"type":"text","id":"title-1","x":100,"y":100,"text":"Meeting notes","fontSize":28,"strokeColor":"#1e1e1e"},{"type":"text","id":"note-1","x":100,"y":150,"text":"• Launch moved to March","fontSize":20,"strokeColor":"#1e1e1e"},{"type":"text","id":"note-2","x":100,"y":190,"text":"• Marketing needs the new screenshots","fontSize":20,"strokeColor":"#1e1e1e"}]}
+
+### Example 2: Continue Notes
+Instruction: "Also add that QA signs off on Friday"
+Board: [{"type":"text","id":"title-1","x":100,"y":100,"text":"Meeting notes","fontSize":28},{"type":"text","id":"note-1","x":100,"y":150,"text":"• Launch moved to March","fontSize":20}]
+Response:
+{"action":"add","elements":[{"type":"text","id":"note-2","x":100,"y":190,"text":"• QA signs off on Friday","fontSize":20,"strokeColor":"#1e1e1e"}]}
+
+### Example 3: Sub-point
+Instruction: "Under the launch point, note that the press release is drafted"
+Board: [{"type":"text","id":"note-1","x":100,"y":150,"text":"• Launch moved to March","fontSize":20}]
+Response:
+{"action":"add","elements":[{"type":"text","id":"note-1a","x":140,"y":190,"text":"– Press release drafted","fontSize":20,"strokeColor":"#1e1e1e"}]}
+
+### Example 4: Highlight a Line
+Instruction: "Make the screenshots point red"
+Board: [{"type":"text","id":"note-2","x":100,"y":190,"text":"• Marketing needs the new screenshots","fontSize":20,"strokeColor":"#1e1e1e"}]
+Response:
+{"action":"update","elements":[{"type":"text","id":"note-2","x":100,"y":190,"text":"• Marketing needs the new screenshots","fontSize":20,"strokeColor":"#e03131"}]}
+
+### Example 5: Delete a Line
+Instruction: "Scratch the QA point"
+Board: [{"type":"text","id":"note-3","x":100,"y":230,"text":"• QA signs off on Friday","fontSize":20}]
+Response:
+{"action":"delete","delete_ids":["note-3"]}`
+
+const mindmapLayout = `## MIND MAP LAYOUT
+- Central topic: an "ellipse" with a label, 200x100, centered at x:500, y:400 on an empty board
+- Branches: "rectangle" with a label, 160x60, about 250px from the central topic, spread evenly around it (right, left, below, above, then the diagonals)
+- Sub-topics: "rectangle" 140x50, about 200px further out from their branch, in the same direction
+- Connect every branch to its parent with an "arrow" whose start is the parent and end is the branch
+- Give each branch its own light color; its sub-topics share it
+- Existing map: find the parent in the board state by its label and add next to it without overlapping other elements`
+
+const mindmapExamples = `## EXAMPLES
+
+### Example 1: Start a Map
+Instruction: "Mind map for the product launch with marketing and engineering"
+Board: []
+Response:
+{"action":"add","elements":[{"type":"ellipse","id":"topic","x":400,"y":350,"width":200,"height":100,"backgroundColor":"#ffec99","strokeColor":"#f08c00","label":{"text":"Product launch","fontSize":20}},{"type":"rectangle","id":"branch-marketing","x":850,"y":370,"width":160,"height":60,"backgroundColor":"#a5d8ff","strokeColor":"#1971c2","label":{"text":"Marketing","fontSize":18}},{"type":"rectangle","id":"branch-engineering","x":-10,"y":370,"width":160,"height":60,"backgroundColor":"#b2f2bb","strokeColor":"#2f9e44","label":{"text":"Engineering","fontSize":18}},{"type":"arrow","x":600,"y":400,"width":250,"height":0,"strokeColor":"#1e1e1e","strokeWidth":2,"start":{"id":"topic"},"end":{"id":"branch-marketing"}},{"type":"arrow","x":400,"y":400,"width":-250,"height":0,"strokeColor":"#1e1e1e","strokeWidth":2,"start":{"id":"topic"},"end":{"id":"branch-engineering"}}]}
+
+### Example 2: Add a Sub-topic
+Instruction: "Under marketing add social media"
+Board: [{"type":"ellipse","id":"topic","x":400,"y":350,"width":200,"height":100,"label":{"text":"Product launch"}},{"type":"rectangle","id":"branch-marketing","x":850,"y":370,"width":160,"height":60,"backgroundColor":"#a5d8ff","label":{"text":"Marketing"}}]
+Response:
+{"action":"add","elements":[{"type":"rectangle","id":"sub-social","x":1210,"y":375,"width":140,"height":50,"backgroundColor":"#a5d8ff","strokeColor":"#1971c2","label":{"text":"Social media","fontSize":16}},{"type":"arrow","x":1010,"y":400,"width":200,"height":0,"strokeColor":"#1e1e1e","strokeWidth":2,"start":{"id":"branch-marketing"},"end":{"id":"sub-social"}}]}
+
+### Example 3: Rename a Branch
+Instruction: "Rename engineering to build"
+Board: [{"type":"rectangle","id":"branch-engineering","x":-10,"y":370,"width":160,"height":60,"backgroundColor":"#b2f2bb","label":{"text":"Engineering"}}]
+Response:
+{"action":"update","elements":[{"type":"rectangle","id":"branch-engineering","x":-10,"y":370,"width":160,"height":60,"backgroundColor":"#b2f2bb","label":{"text":"Build"}}]}
+
+### Example 4: Remove a Branch
+Instruction: "Drop the social media idea"
+Board: [{"type":"rectangle","id":"sub-social","x":1210,"y":375,"width":140,"height":50,"label":{"text":"Social media"}},{"type":"arrow","id":"arrow-social","x":1010,"y":400,"width":200,"height":0,"start":{"id":"branch-marketing"},"end":{"id":"sub-social"}}]
+Response:
+{"action":"delete","delete_ids":["sub-social","arrow-social"]}`
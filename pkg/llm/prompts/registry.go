@@ -0,0 +1,129 @@
+package prompts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultVersion names WhiteboardSystemPrompt in a Registry.
+const DefaultVersion = "v1"
+
+// promptFileExt marks the files in a prompts directory that hold versions.
+const promptFileExt = ".txt"
+
+// Registry holds the named versions of the system prompts: the built-in
+// ones, plus those in an optional directory, which replace a built-in
+// version of the same name. In the directory <version>.txt holds a
+// version's diagram prompt and <version>.<mode>.txt its prompt for another
+// mode; a mode a version leaves out uses the built-in prompt. Reload picks
+// up edits to the directory without a restart.
+type Registry struct {
+	dir string
+
+	mu       sync.RWMutex
+	versions map[string]map[Mode]string
+}
+
+// NewRegistry loads the built-in versions and those in dir, which may be
+// empty.
+func NewRegistry(dir string) (*Registry, error) {
+	r := &Registry{dir: dir}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the directory again. On error the versions loaded before are
+// kept.
+func (r *Registry) Reload() error {
+	versions := map[string]map[Mode]string{
+		DefaultVersion: builtinPrompts(),
+	}
+	if r.dir != "" {
+		entries, err := os.ReadDir(r.dir)
+		if err != nil {
+			return fmt.Errorf("failed to read prompts directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != promptFileExt {
+				continue
+			}
+			version, mode := parsePromptFileName(entry.Name())
+			data, err := os.ReadFile(filepath.Join(r.dir, entry.Name()))
+			if err != nil {
+				return fmt.Errorf("failed to read prompt %s: %w", entry.Name(), err)
+			}
+			text := strings.TrimSpace(string(data))
+			if text == "" {
+				return fmt.Errorf("prompt %s is empty", entry.Name())
+			}
+			if _, ok := versions[version]; !ok {
+				versions[version] = builtinPrompts()
+			}
+			versions[version][mode] = text
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.versions = versions
+	return nil
+}
+
+// builtinPrompts is the prompt of every mode, for a new version to start
+// from.
+func builtinPrompts() map[Mode]string {
+	prompts := make(map[Mode]string, len(Modes))
+	for _, mode := range Modes {
+		prompts[mode] = SystemPrompt(mode)
+	}
+	return prompts
+}
+
+// parsePromptFileName splits <version>.<mode>.txt; any other name is a
+// diagram prompt named after the whole file, so versions may contain dots.
+func parsePromptFileName(name string) (string, Mode) {
+	base := strings.TrimSuffix(name, promptFileExt)
+	if i := strings.LastIndex(base, "."); i > 0 {
+		if mode, err := ParseMode(base[i+1:]); err == nil && base[i+1:] != "" {
+			return base[:i], mode
+		}
+	}
+	return base, ModeDiagram
+}
+
+// System returns the system prompt of version for mode; an empty mode is
+// ModeDiagram.
+func (r *Registry) System(version string, mode Mode) (string, error) {
+	if mode == "" {
+		mode = ModeDiagram
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	prompts, ok := r.versions[version]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt version %q", version)
+	}
+	text, ok := prompts[mode]
+	if !ok {
+		return "", fmt.Errorf("unknown mode %q", mode)
+	}
+	return text, nil
+}
+
+// Versions lists the loaded versions in order.
+func (r *Registry) Versions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := make([]string, 0, len(r.versions))
+	for version := range r.versions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
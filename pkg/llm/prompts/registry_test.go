@@ -0,0 +1,140 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePrompts writes files, name to text, into a new directory.
+func writePrompts(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		versions string
+		// want maps version/mode to the start of its prompt; "builtin"
+		// means the built-in prompt of the mode.
+		want map[string]string
+		err  string
+	}{
+		{
+			name:     "built-in only",
+			versions: "v1",
+			want:     map[string]string{"v1/diagram": "builtin", "v1/notes": "builtin", "v1/mindmap": "builtin"},
+		},
+		{
+			name:     "new version",
+			files:    map[string]string{"v2.txt": "  terse prompt\n", "v2.notes.txt": "terse notes", "README.md": "not a prompt"},
+			versions: "v1 v2",
+			want:     map[string]string{"v1/diagram": "builtin", "v2/diagram": "terse prompt", "v2/notes": "terse notes", "v2/mindmap": "builtin"},
+		},
+		{
+			name:     "built-in version replaced",
+			files:    map[string]string{"v1.txt": "replaced"},
+			versions: "v1",
+			want:     map[string]string{"v1/diagram": "replaced", "v1/notes": "builtin"},
+		},
+		{
+			// Only a known mode after the last dot names a mode.
+			name:     "dots in the version",
+			files:    map[string]string{"v2.1.txt": "point one", "v2.1.mindmap.txt": "point one maps"},
+			versions: "v1 v2.1",
+			want:     map[string]string{"v2.1/diagram": "point one", "v2.1/mindmap": "point one maps"},
+		},
+		{name: "empty prompt", files: map[string]string{"v2.txt": " \n"}, err: "prompt v2.txt is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := ""
+			if tt.files != nil {
+				dir = writePrompts(t, tt.files)
+			}
+			registry, err := NewRegistry(dir)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRegistry: %v", err)
+			}
+			if got := strings.Join(registry.Versions(), " "); got != tt.versions {
+				t.Errorf("versions = %s, want %s", got, tt.versions)
+			}
+			for key, want := range tt.want {
+				version, mode, _ := strings.Cut(key, "/")
+				got, err := registry.System(version, Mode(mode))
+				if err != nil {
+					t.Fatalf("System(%s): %v", key, err)
+				}
+				if want == "builtin" {
+					want = SystemPrompt(Mode(mode))
+				}
+				if got != want {
+					t.Errorf("System(%s) = %.40q, want %.40q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistrySystemErrors(t *testing.T) {
+	registry, err := NewRegistry("")
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	if got, err := registry.System(DefaultVersion, ""); err != nil || got != SystemPrompt(ModeDiagram) {
+		t.Errorf("an empty mode gave %.40q, %v, want the diagram prompt", got, err)
+	}
+	if _, err := registry.System("v9", ModeDiagram); err == nil || err.Error() != `unknown prompt version "v9"` {
+		t.Errorf("err = %v, want the unknown version", err)
+	}
+	if _, err := registry.System(DefaultVersion, "poems"); err == nil || err.Error() != `unknown mode "poems"` {
+		t.Errorf("err = %v, want the unknown mode", err)
+	}
+	if _, err := NewRegistry(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("NewRegistry accepted a directory that doesn't exist")
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	dir := writePrompts(t, map[string]string{"v2.txt": "first"})
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "v2.txt"), []byte("second"), 0o644)
+	os.WriteFile(filepath.Join(dir, "v3.txt"), []byte("third"), 0o644)
+	if err := registry.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got, _ := registry.System("v2", ModeDiagram); got != "second" {
+		t.Errorf("v2 = %q after reload, want the edited prompt", got)
+	}
+	if got := strings.Join(registry.Versions(), " "); got != "v1 v2 v3" {
+		t.Errorf("versions = %s, want the added version", got)
+	}
+
+	// A failed reload keeps what was loaded.
+	os.WriteFile(filepath.Join(dir, "v2.txt"), nil, 0o644)
+	if err := registry.Reload(); err == nil {
+		t.Fatal("Reload accepted an empty prompt")
+	}
+	if got, _ := registry.System("v2", ModeDiagram); got != "second" {
+		t.Errorf("v2 = %q after a failed reload, want it kept", got)
+	}
+}
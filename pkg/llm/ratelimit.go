@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is matched by errors.Is for requests refused by a
+// RateLimiter.
+var ErrRateLimited = errors.New("rate_limited: too many LLM requests from this user")
+
+// RateLimitError says how long until the key may make another request.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v; retry in %s", ErrRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// maxIdleBuckets is how many keys the limiter tracks before it forgets the
+// ones whose buckets have refilled.
+const maxIdleBuckets = 1024
+
+// RateLimitClass groups the keys a RateLimiter limits. Each class has its own
+// rate and its own buckets, so one class's requests never use up another's.
+type RateLimitClass string
+
+const (
+	// RateLimitUser is the class of signed-in users.
+	RateLimitUser RateLimitClass = "user"
+	// RateLimitServiceAccount is the class of service accounts, which are
+	// kept out of the quota of the user they act for.
+	RateLimitServiceAccount RateLimitClass = "service_account"
+)
+
+// RateLimit is how many requests each key of a class may make: Burst at
+// once, and PerMinute a minute after that.
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// RateLimiter is a token bucket per key, shared by every client it limits.
+type RateLimiter struct {
+	rates map[RateLimitClass]classRate
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*tokenBucket
+}
+
+type classRate struct {
+	rate  float64 // tokens per second
+	burst float64
+}
+
+type bucketKey struct {
+	class RateLimitClass
+	key   string
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates the limiter, or returns nil when no class has a
+// PerMinute above 0, which disables limiting. Classes left out, or with a
+// PerMinute of 0 or less, aren't limited. Burst defaults to 1.
+func NewRateLimiter(limits map[RateLimitClass]RateLimit) *RateLimiter {
+	rates := make(map[RateLimitClass]classRate)
+	for class, limit := range limits {
+		if limit.PerMinute <= 0 {
+			continue
+		}
+		rates[class] = classRate{
+			rate:  float64(limit.PerMinute) / 60,
+			burst: math.Max(1, float64(limit.Burst)),
+		}
+	}
+	if len(rates) == 0 {
+		return nil
+	}
+	return &RateLimiter{
+		rates:   rates,
+		buckets: make(map[bucketKey]*tokenBucket),
+	}
+}
+
+// Allow takes a token from the bucket of key in class, or returns a
+// *RateLimitError when the bucket is empty.
+func (l *RateLimiter) Allow(class RateLimitClass, key string) error {
+	limit, ok := l.rates[class]
+	if !ok {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[bucketKey{class, key}]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.forgetFull(now)
+		}
+		bucket = &tokenBucket{tokens: limit.burst, last: now}
+		l.buckets[bucketKey{class, key}] = bucket
+	}
+	bucket.tokens = math.Min(limit.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limit.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / limit.rate * float64(time.Second))
+		return &RateLimitError{RetryAfter: wait}
+	}
+	bucket.tokens--
+	return nil
+}
+
+// forgetFull drops buckets that have refilled; a new bucket starts full, so
+// nothing is lost.
+func (l *RateLimiter) forgetFull(now time.Time) {
+	for key, bucket := range l.buckets {
+		limit := l.rates[key.class]
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*limit.rate >= limit.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+type rateLimitKey struct{}
+
+// rateLimitCharge is the bucket a request is charged to.
+type rateLimitCharge struct {
+	class RateLimitClass
+	key   string
+}
+
+// WithRateLimitKey marks ctx so RateLimitedLLMClient charges requests made
+// with it to key in class, usually the ID of the user or service account
+// who asked.
+func WithRateLimitKey(ctx context.Context, class RateLimitClass, key string) context.Context {
+	return context.WithValue(ctx, rateLimitKey{}, rateLimitCharge{class: class, key: key})
+}
+
+func rateLimitKeyFrom(ctx context.Context) (rateLimitCharge, bool) {
+	charge, ok := ctx.Value(rateLimitKey{}).(rateLimitCharge)
+	return charge, ok && charge.key != ""
+}
+
+// RateLimitedLLMClient checks every request against a shared RateLimiter
+// before passing it on. Requests whose context carries no key, such as
+// background work, are not limited.
+type RateLimitedLLMClient struct {
+	inner   LLMClient
+	limiter *RateLimiter
+}
+
+// NewRateLimitedLLMClient wraps inner. A nil limiter lets every request
+// through.
+func NewRateLimitedLLMClient(inner LLMClient, limiter *RateLimiter) *RateLimitedLLMClient {
+	return &RateLimitedLLMClient{inner: inner, limiter: limiter}
+}
+
+func (c *RateLimitedLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *RateLimitedLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	if err := c.allow(ctx); err != nil {
+		return nil, err
+	}
+	return c.inner.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+}
+
+func (c *RateLimitedLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	if err := c.allow(ctx); err != nil {
+		return nil, err
+	}
+	return generateStream(ctx, c.inner, prompt, boardState, opts, onChunk)
+}
+
+func (c *RateLimitedLLMClient) allow(ctx context.Context) error {
+	charge, ok := rateLimitKeyFrom(ctx)
+	if c.limiter == nil || !ok {
+		return nil
+	}
+	return c.limiter.Allow(charge.class, charge.key)
+}
+
+func (c *RateLimitedLLMClient) Ping(ctx context.Context) error {
+	return c.inner.Ping(ctx)
+}
+
+func (c *RateLimitedLLMClient) Close() error {
+	return c.inner.Close()
+}
@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRateLimiterClasses(t *testing.T) {
+	limiter := NewRateLimiter(map[RateLimitClass]RateLimit{
+		RateLimitUser:           {PerMinute: 1, Burst: 2},
+		RateLimitServiceAccount: {PerMinute: 1},
+	})
+
+	tests := []struct {
+		name    string
+		class   RateLimitClass
+		key     string
+		limited bool
+	}{
+		{name: "user within burst", class: RateLimitUser, key: "u1"},
+		{name: "user burst spent", class: RateLimitUser, key: "u1"},
+		{name: "user over limit", class: RateLimitUser, key: "u1", limited: true},
+		// A service account keyed like a user has its own bucket.
+		{name: "service account keyed like the user", class: RateLimitServiceAccount, key: "u1"},
+		{name: "service account burst defaults to 1", class: RateLimitServiceAccount, key: "u1", limited: true},
+		{name: "another service account", class: RateLimitServiceAccount, key: "sa2"},
+		{name: "unlimited class", class: "demo", key: "u1"},
+		{name: "unlimited class again", class: "demo", key: "u1"},
+	}
+	for _, tt := range tests {
+		err := limiter.Allow(tt.class, tt.key)
+		if limited := errors.Is(err, ErrRateLimited); limited != tt.limited {
+			t.Errorf("%s: got %v, want limited %v", tt.name, err, tt.limited)
+		}
+	}
+}
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	limiter := NewRateLimiter(map[RateLimitClass]RateLimit{
+		RateLimitUser:           {Burst: 5},
+		RateLimitServiceAccount: {PerMinute: -1},
+	})
+	if limiter != nil {
+		t.Fatalf("got a limiter, want nil when no class has a rate")
+	}
+
+	inner := &stubClient{name: "inner"}
+	client := NewRateLimitedLLMClient(inner, limiter)
+	ctx := WithRateLimitKey(context.Background(), RateLimitUser, "u1")
+	for i := 0; i < 3; i++ {
+		if _, err := client.GenerateResponseWithOptions(ctx, "prompt", "[]", GenerateOptions{}); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+}
+
+func TestRateLimitedClientCharge(t *testing.T) {
+	limiter := NewRateLimiter(map[RateLimitClass]RateLimit{
+		RateLimitServiceAccount: {PerMinute: 1},
+	})
+	inner := &stubClient{name: "inner"}
+	client := NewRateLimitedLLMClient(inner, limiter)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		limited bool
+	}{
+		{name: "first request", ctx: WithRateLimitKey(context.Background(), RateLimitServiceAccount, "sa1")},
+		{name: "second request", ctx: WithRateLimitKey(context.Background(), RateLimitServiceAccount, "sa1"), limited: true},
+		{name: "user class isn't limited", ctx: WithRateLimitKey(context.Background(), RateLimitUser, "sa1")},
+		{name: "no key", ctx: context.Background()},
+		{name: "empty key", ctx: WithRateLimitKey(context.Background(), RateLimitServiceAccount, "")},
+	}
+	for _, tt := range tests {
+		_, err := client.GenerateResponseWithOptions(tt.ctx, "prompt", "[]", GenerateOptions{})
+		if limited := errors.Is(err, ErrRateLimited); limited != tt.limited {
+			t.Errorf("%s: got %v, want limited %v", tt.name, err, tt.limited)
+		}
+	}
+	if inner.calls != 4 {
+		t.Errorf("inner client got %d calls, want 4", inner.calls)
+	}
+}
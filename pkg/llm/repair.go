@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidLLMOutput is matched by errors.Is for output that still didn't
+// parse as a whiteboard action after every repair attempt.
+var ErrInvalidLLMOutput = errors.New("invalid_llm_output: the model did not return a valid whiteboard action")
+
+// maxRepairOutputBytes caps how much of the broken output is sent back to
+// the model; a truncated answer is usually cut off anyway.
+const maxRepairOutputBytes = 4096
+
+// InvalidOutputError carries the last unparseable response for debugging.
+// Response adds up the usage of every attempt, so it can still be charged.
+type InvalidOutputError struct {
+	Raw      string
+	Err      error
+	Response *LLMResponse
+}
+
+func (e *InvalidOutputError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrInvalidLLMOutput, e.Err)
+}
+
+func (e *InvalidOutputError) Unwrap() []error {
+	return []error{ErrInvalidLLMOutput, e.Err}
+}
+
+// MalformedOutput is an answer that didn't parse, shown to the model on a
+// repair attempt together with why.
+type MalformedOutput struct {
+	Output string
+	Error  string
+}
+
+// instructions renders the prompt section for a repair attempt; it is empty,
+// and so left out, when there is nothing to repair.
+func (m *MalformedOutput) instructions() string {
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("Error: %s\nAnswer:\n%s", m.Error, m.Output)
+}
+
+// RepairLLMClient re-prompts the model when its output doesn't parse as a
+// whiteboard action, passing back the broken output and the parse error and
+// asking for corrected JSON only. Responses that parse carry ParsedAction.
+type RepairLLMClient struct {
+	inner    LLMClient
+	attempts int
+}
+
+// NewRepairLLMClient wraps inner. attempts is how many repair prompts follow
+// an unparseable answer; 0 turns repair off, leaving only ParsedAction and
+// ErrInvalidLLMOutput.
+func NewRepairLLMClient(inner LLMClient, attempts int) *RepairLLMClient {
+	if attempts < 0 {
+		attempts = 0
+	}
+	return &RepairLLMClient{inner: inner, attempts: attempts}
+}
+
+func (c *RepairLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *RepairLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	resp, err := c.inner.GenerateResponseWithOptions(ctx, prompt, boardState, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.repair(ctx, prompt, boardState, opts, resp)
+}
+
+// GenerateResponseStream streams the first answer when the inner client
+// supports streaming. Repairs are not streamed: previews of a corrected
+// answer would redraw what the first one already showed.
+func (c *RepairLLMClient) GenerateResponseStream(ctx context.Context, prompt string, boardState string, opts GenerateOptions, onChunk func(chunk string)) (*LLMResponse, error) {
+	resp, err := generateStream(ctx, c.inner, prompt, boardState, opts, onChunk)
+	if err != nil {
+		return nil, err
+	}
+	return c.repair(ctx, prompt, boardState, opts, resp)
+}
+
+func (c *RepairLLMClient) repair(ctx context.Context, prompt string, boardState string, opts GenerateOptions, resp *LLMResponse) (*LLMResponse, error) {
+	action, parseErr := ParseWhiteboardAction(resp.Response)
+	usage := resp.Usage
+	for attempt := 0; parseErr != nil && attempt < c.attempts; attempt++ {
+		output := resp.Response
+		if len(output) > maxRepairOutputBytes {
+			output = strings.ToValidUTF8(output[:maxRepairOutputBytes], "")
+		}
+		repairOpts := opts
+		repairOpts.Malformed = &MalformedOutput{Output: output, Error: parseErr.Error()}
+
+		repaired, err := c.inner.GenerateResponseWithOptions(ctx, prompt, boardState, repairOpts)
+		if err != nil {
+			// The broken answer is more useful to the caller than why the
+			// repair couldn't be asked for.
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			break
+		}
+		usage.PromptTokens += repaired.Usage.PromptTokens
+		usage.CompletionTokens += repaired.Usage.CompletionTokens
+		resp = repaired
+		action, parseErr = ParseWhiteboardAction(resp.Response)
+	}
+	resp.Usage = usage
+
+	if parseErr != nil {
+		return nil, &InvalidOutputError{Raw: resp.Response, Err: parseErr, Response: resp}
+	}
+	resp.ParsedAction = action
+	return resp, nil
+}
+
+func (c *RepairLLMClient) Ping(ctx context.Context) error {
+	return c.inner.Ping(ctx)
+}
+
+func (c *RepairLLMClient) Close() error {
+	return c.inner.Close()
+}
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoRecordedOutput is returned by ReplayLLMClient when it is asked for
+// more responses than were recorded.
+var ErrNoRecordedOutput = errors.New("no recorded LLM output left to replay")
+
+// ReplayOutput is one recorded provider call: the raw model output, or the
+// error the call failed with.
+type ReplayOutput struct {
+	Response string
+	Err      string
+}
+
+// ReplayLLMClient answers requests with recorded outputs, in order, instead
+// of calling a provider. It makes the pipeline deterministic when replaying a
+// recorded session.
+type ReplayLLMClient struct {
+	provider string
+
+	mu      sync.Mutex
+	outputs []ReplayOutput
+}
+
+// NewReplayLLMClient creates a client that reports its responses as coming
+// from provider.
+func NewReplayLLMClient(provider string) *ReplayLLMClient {
+	return &ReplayLLMClient{provider: provider}
+}
+
+// Load queues outputs behind any not yet replayed.
+func (c *ReplayLLMClient) Load(outputs ...ReplayOutput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.outputs = append(c.outputs, outputs...)
+}
+
+// Remaining reports how many loaded outputs have not been replayed.
+func (c *ReplayLLMClient) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.outputs)
+}
+
+func (c *ReplayLLMClient) GenerateResponse(ctx context.Context, prompt string, boardState string) (*LLMResponse, error) {
+	return c.GenerateResponseWithOptions(ctx, prompt, boardState, GenerateOptions{})
+}
+
+func (c *ReplayLLMClient) GenerateResponseWithOptions(ctx context.Context, prompt string, boardState string, opts GenerateOptions) (*LLMResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.outputs) == 0 {
+		return nil, ErrNoRecordedOutput
+	}
+	output := c.outputs[0]
+	c.outputs = c.outputs[1:]
+
+	if output.Err != "" {
+		return nil, errors.New(output.Err)
+	}
+	return &LLMResponse{
+		Response:  output.Response,
+		Timestamp: time.Now().UTC(),
+		Provider:  c.provider,
+	}, nil
+}
+
+// Ping always succeeds: there is no provider to reach.
+func (c *ReplayLLMClient) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (c *ReplayLLMClient) Close() error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReplayLLMClient(t *testing.T) {
+	client := NewReplayLLMClient("nvidia")
+	client.Load(ReplayOutput{Response: `{"action":"clear"}`}, ReplayOutput{Err: "provider unavailable"})
+	client.Load(ReplayOutput{Response: "not JSON"})
+
+	tests := []struct {
+		want    string
+		wantErr string
+		left    int
+	}{
+		{want: `{"action":"clear"}`, left: 2},
+		{wantErr: "provider unavailable", left: 1},
+		{want: "not JSON", left: 0},
+	}
+	for i, tt := range tests {
+		resp, err := client.GenerateResponse(context.Background(), "clear the board", "[]")
+		switch {
+		case tt.wantErr != "":
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("call %d: got %v, want %q", i+1, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("call %d: %v", i+1, err)
+		case resp.Response != tt.want || resp.Provider != "nvidia":
+			t.Errorf("call %d: got %q from %s, want %q from nvidia", i+1, resp.Response, resp.Provider, tt.want)
+		}
+		if left := client.Remaining(); left != tt.left {
+			t.Errorf("call %d: %d outputs left, want %d", i+1, left, tt.left)
+		}
+	}
+
+	if _, err := client.GenerateResponse(context.Background(), "one more", "[]"); !errors.Is(err, ErrNoRecordedOutput) {
+		t.Errorf("got %v once outputs ran out, want ErrNoRecordedOutput", err)
+	}
+}
@@ -0,0 +1,41 @@
+package llm
+
+import "encoding/json"
+
+// whiteboardActionSchema is the JSON schema of a WhiteboardAction, sent to
+// providers that can constrain their output to it. Elements only pin down
+// the fields every element needs; the prompt describes the rest.
+var whiteboardActionSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"action": {"type": "string", "enum": ["add", "update", "delete", "transform", "error", "clear", "replace", "reorder", "template"]},
+		"elements": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"id": {"type": "string"},
+					"type": {"type": "string"},
+					"x": {"type": "number"},
+					"y": {"type": "number"}
+				},
+				"required": ["type", "x", "y"]
+			}
+		},
+		"delete_ids": {"type": "array", "items": {"type": "string"}},
+		"message": {"type": "string"},
+		"operation": {"type": "string", "enum": ["copy_style", "recolor_stickies", "cluster_stickies"]},
+		"source_id": {"type": "string"},
+		"target_ids": {"type": "array", "items": {"type": "string"}},
+		"color": {"type": "string"},
+		"position": {"type": "string", "enum": ["front", "back", "forward", "backward"]},
+		"name": {"type": "string"},
+		"origin": {
+			"type": "object",
+			"properties": {"x": {"type": "number"}, "y": {"type": "number"}},
+			"required": ["x", "y"]
+		},
+		"params": {"type": "object", "additionalProperties": {"type": "string"}}
+	},
+	"required": ["action"]
+}`)
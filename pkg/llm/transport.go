@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Limits for connecting to a hosted provider. They bound only the
+// connection; how long the answer may take is up to the request timeout.
+const (
+	providerDialTimeout  = 5 * time.Second
+	providerTLSTimeout   = 5 * time.Second
+	providerIdleTimeout  = 90 * time.Second
+	providerKeepAlive    = 30 * time.Second
+	maxDrainedBodyLength = 64 * 1024
+)
+
+// newHTTPClient returns a client for a hosted provider's API that keeps
+// connections open between requests, one for every worker in pool, so a
+// request doesn't pay for a TCP and TLS handshake each time.
+func newHTTPClient(timeout time.Duration, pool WorkerPool) *http.Client {
+	dialer := &net.Dialer{Timeout: providerDialTimeout, KeepAlive: providerKeepAlive}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   pool.concurrency(),
+			IdleConnTimeout:       providerIdleTimeout,
+			TLSHandshakeTimeout:   providerTLSTimeout,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// closeBody reads what is left of a response body before closing it; a body
+// closed unread takes its connection with it instead of returning it to the
+// pool. A decoder stops short of the trailing newline, and a stream reader at
+// its end marker, so there is usually a little left.
+func closeBody(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainedBodyLength))
+	body.Close()
+}
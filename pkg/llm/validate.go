@@ -0,0 +1,414 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Issue codes. They are part of the API: clients and the retry prompt match
+// on them, so they must not change.
+const (
+	IssueUnknownElementID    = "unknown_element_id"
+	IssueDuplicateElementID  = "duplicate_element_id"
+	IssueInvalidColor        = "invalid_color"
+	IssueBindingUnresolvable = "binding_unresolvable"
+	IssueLimitExceeded       = "limit_exceeded"
+	IssueTypeNotAllowed      = "type_not_allowed"
+	IssueMissingElements     = "missing_elements"
+	IssueInvalidNumber       = "invalid_number"
+	IssueUnexpectedElements  = "unexpected_elements"
+	IssueInvalidPoints       = "invalid_points"
+	IssueFrameUnresolvable   = "frame_unresolvable"
+	IssueUnknownFile         = "unknown_file"
+	IssueInvalidPosition     = "invalid_position"
+)
+
+// Issue severities. Errors keep an action from being applied; warnings are
+// passed on with the result.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// MaxActionElements caps how many elements one action may add or update.
+const MaxActionElements = 200
+
+// MaxCoordinate bounds positions and sizes. Anything further out is a model
+// mistake, and clients can't draw it usefully anyway.
+const MaxCoordinate = 1e6
+
+// frameType is the Excalidraw type of frames, which hold other elements.
+const frameType = "frame"
+
+// imageType is the Excalidraw type of images, which show an uploaded file.
+const imageType = "image"
+
+// allowedElementTypes are the types the system prompt lets the model create.
+var allowedElementTypes = map[string]bool{
+	"rectangle": true, "ellipse": true, "diamond": true, "text": true, "arrow": true, "line": true,
+	"frame": true, "image": true,
+}
+
+var hexColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// ValidationIssue is one problem found in an action.
+type ValidationIssue struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	// Index is the position of the element in the action's elements, or of
+	// the ID in its delete_ids or target_ids; nil for issues with the action
+	// as a whole.
+	Index     *int   `json:"index,omitempty"`
+	ElementID string `json:"elementId,omitempty"`
+	// Field is the element field at fault, e.g. "strokeColor" or "start.id".
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	// Repair suggests a fix, where there is an obvious one.
+	Repair string `json:"repair,omitempty"`
+}
+
+// ValidationReport lists everything wrong with an action.
+type ValidationReport struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// Errors returns the issues that block the action.
+func (r ValidationReport) Errors() []ValidationIssue {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the issues the action is applied despite.
+func (r ValidationReport) Warnings() []ValidationIssue {
+	return r.filter(SeverityWarning)
+}
+
+func (r ValidationReport) filter(severity string) []ValidationIssue {
+	var issues []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// ValidationError is returned for actions with error-level issues.
+type ValidationError struct {
+	Report ValidationReport
+}
+
+func (e *ValidationError) Error() string {
+	errs := e.Report.Errors()
+	messages := make([]string, 0, len(errs))
+	for _, issue := range errs {
+		messages = append(messages, issue.Code+": "+issue.Message)
+	}
+	return "invalid action: " + strings.Join(messages, "; ")
+}
+
+// ValidateAction checks a resolved add, update, delete, clear, replace or
+// reorder action against the board it will be applied to. The elements of a replace
+// action are checked like added ones, against an empty board, since they are
+// all that will be left.
+func ValidateAction(action *WhiteboardAction, board []Element) ValidationReport {
+	v := &validator{
+		board:  make(map[string]Element, len(board)),
+		added:  make(map[string]bool),
+		frames: make(map[string]bool),
+		files:  make(map[string]bool),
+	}
+	for _, element := range board {
+		if element.ID != "" {
+			v.board[element.ID] = element
+		}
+		// A replace may show the board's images again, so files are
+		// collected before it empties v.board.
+		if element.FileID != "" {
+			v.files[element.FileID] = true
+		}
+	}
+
+	switch action.Action {
+	case ActionAdd, ActionUpdate, ActionReplace:
+		kind := action.Action
+		if kind == ActionReplace {
+			kind = ActionAdd
+			v.board = map[string]Element{}
+		}
+		if len(action.Elements) == 0 {
+			v.add(ValidationIssue{Code: IssueMissingElements, Severity: SeverityError,
+				Message: fmt.Sprintf("%s action has no elements", action.Action)})
+		}
+		if len(action.Elements) > MaxActionElements {
+			v.add(ValidationIssue{Code: IssueLimitExceeded, Severity: SeverityError,
+				Message: fmt.Sprintf("action has %d elements; at most %d are allowed", len(action.Elements), MaxActionElements)})
+		}
+		for i, element := range action.Elements {
+			if kind == ActionAdd && element.ID != "" {
+				v.added[element.ID] = true
+				if element.Type == frameType {
+					v.frames[element.ID] = true
+				}
+			}
+			v.element(kind, i, element)
+		}
+		for i, element := range action.Elements {
+			v.binding(i, element, "start", element.Start)
+			v.binding(i, element, "end", element.End)
+			v.frame(i, element)
+		}
+	case ActionDelete:
+		if len(action.DeleteIDs) == 0 {
+			v.add(ValidationIssue{Code: IssueMissingElements, Severity: SeverityError,
+				Message: "delete action has no delete_ids"})
+		}
+		for i, id := range action.DeleteIDs {
+			if _, ok := v.board[id]; !ok {
+				v.add(ValidationIssue{Code: IssueUnknownElementID, Severity: SeverityWarning, Index: &i, ElementID: id, Field: "delete_ids",
+					Message: fmt.Sprintf("element %q is not on the board; there is nothing to delete", id)})
+			}
+		}
+	case ActionClear:
+		if len(action.Elements) > 0 || len(action.DeleteIDs) > 0 {
+			v.add(ValidationIssue{Code: IssueUnexpectedElements, Severity: SeverityError,
+				Message: "clear action takes no elements or delete_ids"})
+		}
+	case ActionReorder:
+		if len(action.TargetIDs) == 0 {
+			v.add(ValidationIssue{Code: IssueMissingElements, Severity: SeverityError,
+				Message: "reorder action has no target_ids"})
+		}
+		for i, id := range action.TargetIDs {
+			if _, ok := v.board[id]; !ok {
+				v.add(ValidationIssue{Code: IssueUnknownElementID, Severity: SeverityError, Index: &i, ElementID: id, Field: "target_ids",
+					Message: fmt.Sprintf("element %q is not on the board", id)})
+			}
+		}
+		switch action.Position {
+		case PositionFront, PositionBack, PositionForward, PositionBackward:
+		default:
+			v.add(ValidationIssue{Code: IssueInvalidPosition, Severity: SeverityError, Field: "position",
+				Message: fmt.Sprintf("position %q is not one of front, back, forward or backward", action.Position)})
+		}
+	}
+	return v.report
+}
+
+type validator struct {
+	board  map[string]Element
+	added  map[string]bool
+	frames map[string]bool
+	files  map[string]bool
+	report ValidationReport
+}
+
+func (v *validator) add(issue ValidationIssue) {
+	if issue.Repair == "" {
+		issue.Repair = repairHints[issue.Code]
+	}
+	v.report.Issues = append(v.report.Issues, issue)
+}
+
+func (v *validator) element(action string, i int, element Element) {
+	original, exists := v.board[element.ID]
+	switch {
+	case action == ActionUpdate && !exists:
+		v.add(ValidationIssue{Code: IssueUnknownElementID, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: "id",
+			Message: fmt.Sprintf("element %q is not on the board", element.ID)})
+	case action == ActionAdd && exists:
+		v.add(ValidationIssue{Code: IssueDuplicateElementID, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: "id",
+			Message: fmt.Sprintf("element %q is already on the board", element.ID)})
+	}
+
+	// Updates may keep a type the model can't create, such as a freehand
+	// drawing; they may not change an element to one.
+	if !allowedElementTypes[element.Type] && (action == ActionAdd || element.Type != original.Type) {
+		v.add(ValidationIssue{Code: IssueTypeNotAllowed, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: "type",
+			Message: fmt.Sprintf("type %q is not allowed", element.Type)})
+	}
+
+	colors := []struct{ field, value string }{
+		{"backgroundColor", element.BackgroundColor},
+		{"strokeColor", element.StrokeColor},
+	}
+	if element.Label != nil {
+		colors = append(colors, struct{ field, value string }{"label.strokeColor", element.Label.StrokeColor})
+	}
+	for _, color := range colors {
+		if color.value != "" && color.value != "transparent" && !hexColorPattern.MatchString(color.value) {
+			v.add(ValidationIssue{Code: IssueInvalidColor, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: color.field,
+				Message: fmt.Sprintf("%s %q is not a hex color", color.field, color.value)})
+		}
+	}
+
+	// Positions and arrow extents may be negative; stroke widths and font
+	// sizes may not.
+	numbers := []numberField{
+		{"x", element.X, false},
+		{"y", element.Y, false},
+		{"width", element.Width, false},
+		{"height", element.Height, false},
+		{"strokeWidth", element.StrokeWidth, true},
+		{"fontSize", element.FontSize, true},
+	}
+	if element.Label != nil {
+		numbers = append(numbers, numberField{"label.fontSize", element.Label.FontSize, true})
+	}
+	for _, number := range numbers {
+		if math.IsNaN(number.value) || math.Abs(number.value) > MaxCoordinate || (number.nonNegative && number.value < 0) {
+			v.add(ValidationIssue{Code: IssueInvalidNumber, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: number.field,
+				Message: fmt.Sprintf("%s %v is out of range", number.field, number.value)})
+		}
+	}
+	v.points(i, element)
+	v.file(action, i, element)
+}
+
+// file checks that an image shows a file already on the board: the model
+// can't upload pictures, only place the ones users did.
+func (v *validator) file(action string, i int, element Element) {
+	switch {
+	case element.FileID != "" && !v.files[element.FileID]:
+		v.add(ValidationIssue{Code: IssueUnknownFile, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: "fileId",
+			Message: fmt.Sprintf("fileId %q is not used by any image on the board", element.FileID)})
+	case element.Type == imageType && element.FileID == "" && action == ActionAdd:
+		v.add(ValidationIssue{Code: IssueUnknownFile, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: "fileId",
+			Message: "image has no fileId"})
+	}
+}
+
+// points checks that a line's or arrow's points are at least two [x, y]
+// pairs of numbers in range. Only the first bad point is reported.
+func (v *validator) points(i int, element Element) {
+	if element.Points == nil {
+		return
+	}
+	if len(element.Points) < 2 {
+		v.add(ValidationIssue{Code: IssueInvalidPoints, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: "points",
+			Message: fmt.Sprintf("points has %d point(s); a line needs at least 2", len(element.Points))})
+		return
+	}
+	for n, point := range element.Points {
+		field := fmt.Sprintf("points[%d]", n)
+		if len(point) != 2 {
+			v.add(ValidationIssue{Code: IssueInvalidPoints, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: field,
+				Message: fmt.Sprintf("%s has %d coordinates, not an [x, y] pair", field, len(point))})
+			return
+		}
+		for _, value := range point {
+			if math.IsNaN(value) || math.Abs(value) > MaxCoordinate {
+				v.add(ValidationIssue{Code: IssueInvalidPoints, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: field,
+					Message: fmt.Sprintf("%s %v is out of range", field, point)})
+				return
+			}
+		}
+	}
+}
+
+type numberField struct {
+	field       string
+	value       float64
+	nonNegative bool
+}
+
+func (v *validator) binding(i int, element Element, end string, binding *ElementBinding) {
+	if binding == nil || binding.ID == "" {
+		return
+	}
+	if _, ok := v.board[binding.ID]; ok || v.added[binding.ID] {
+		return
+	}
+	v.add(ValidationIssue{Code: IssueBindingUnresolvable, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: end + ".id",
+		Message: fmt.Sprintf("%s is bound to %q, which is neither on the board nor added by this action", end, binding.ID)})
+}
+
+// frame checks that an element's frame, and a frame's children, are on the
+// board or added by the action.
+func (v *validator) frame(i int, element Element) {
+	if element.FrameID != "" && !v.frames[element.FrameID] {
+		if board, ok := v.board[element.FrameID]; !ok || board.Type != frameType {
+			v.add(ValidationIssue{Code: IssueFrameUnresolvable, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: "frameId",
+				Message: fmt.Sprintf("frameId %q is neither a frame on the board nor one added by this action", element.FrameID)})
+		}
+	}
+	for _, child := range element.Children {
+		if _, ok := v.board[child]; !ok && !v.added[child] {
+			v.add(ValidationIssue{Code: IssueUnknownElementID, Severity: SeverityError, Index: &i, ElementID: element.ID, Field: "children",
+				Message: fmt.Sprintf("child %q is neither on the board nor added by this action", child)})
+		}
+	}
+}
+
+// repairHints tell the model how to fix each kind of issue on retry.
+var repairHints = map[string]string{
+	IssueUnknownElementID:    "use an id that appears in the board state",
+	IssueDuplicateElementID:  "give added elements ids that are not in the board state, or use an update action",
+	IssueInvalidColor:        `use a hex color such as "#1971c2", or "transparent"`,
+	IssueBindingUnresolvable: "bind to an id from the board state or one added in the same action, or leave the binding out",
+	IssueLimitExceeded:       fmt.Sprintf("use at most %d elements", MaxActionElements),
+	IssueTypeNotAllowed:      `use only "rectangle", "ellipse", "diamond", "text", "arrow", "line", "frame", "image" or "sticky"`,
+	IssueMissingElements:     "include the elements the action applies to",
+	IssueUnexpectedElements:  `leave "elements" and "delete_ids" out of a clear action, or use "replace" to draw a new board`,
+	IssueFrameUnresolvable:   "set frameId to the id of a frame from the board state or one added in the same action",
+	IssueUnknownFile:         "use a fileId from an image in the board state; images can't be created from scratch",
+	IssueInvalidPoints:       "give points as at least two [x, y] pairs of numbers relative to the element's x and y, starting with [0, 0]",
+	IssueInvalidNumber:       fmt.Sprintf("use finite numbers within ±%g; stroke widths and font sizes may not be negative", MaxCoordinate),
+	IssueInvalidPosition:     `set "position" to "front", "back", "forward" or "backward"`,
+}
+
+// DropBadBindings removes the arrow bindings the report found unresolvable
+// from action, leaving the arrows unbound, and returns the report with
+// those issues downgraded to warnings. It is the lenient alternative to
+// rejecting the action.
+func DropBadBindings(action *WhiteboardAction, report ValidationReport) ValidationReport {
+	issues := make([]ValidationIssue, len(report.Issues))
+	for n, issue := range report.Issues {
+		if issue.Code == IssueBindingUnresolvable && issue.Severity == SeverityError && issue.Index != nil {
+			element := &action.Elements[*issue.Index]
+			end := strings.TrimSuffix(issue.Field, ".id")
+			var id string
+			switch end {
+			case "start":
+				id, element.Start = element.Start.ID, nil
+			case "end":
+				id, element.End = element.End.ID, nil
+			}
+			issue.Severity = SeverityWarning
+			issue.Message = fmt.Sprintf("%s binding to %q was dropped: the element is neither on the board nor added by this action", end, id)
+			issue.Repair = ""
+		}
+		issues[n] = issue
+	}
+	return ValidationReport{Issues: issues}
+}
+
+// RepairInstructions renders issues as the retry prompt's list of problems
+// to fix, one line per issue, built from the codes rather than the
+// messages so the wording stays stable.
+func RepairInstructions(issues []ValidationIssue) string {
+	lines := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		location := "action"
+		if issue.Index != nil {
+			switch issue.Field {
+			case "delete_ids":
+				location = fmt.Sprintf("delete_ids[%d]", *issue.Index)
+			case "":
+				location = fmt.Sprintf("elements[%d]", *issue.Index)
+			default:
+				location = fmt.Sprintf("elements[%d].%s", *issue.Index, issue.Field)
+			}
+		}
+		line := fmt.Sprintf("- %s at %s", issue.Code, location)
+		if issue.ElementID != "" {
+			line += fmt.Sprintf(" (id %q)", issue.ElementID)
+		}
+		if hint := repairHints[issue.Code]; hint != "" {
+			line += ": " + hint
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
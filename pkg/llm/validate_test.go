@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const validateBoard = `[
+	{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":60},
+	{"id":"f","type":"frame","x":-20,"y":-20,"width":400,"height":300},
+	{"id":"pic","type":"image","x":200,"y":0,"width":80,"height":80,"fileId":"file-1"},
+	{"id":"doodle","type":"freedraw","x":0,"y":200,"points":[[0,0],[5,5]]}
+]`
+
+// describeIssues summarizes issues as code/severity/index/field.
+func describeIssues(issues []ValidationIssue) string {
+	var parts []string
+	for _, issue := range issues {
+		index := "-"
+		if issue.Index != nil {
+			index = fmt.Sprint(*issue.Index)
+		}
+		parts = append(parts, fmt.Sprintf("%s/%s/%s/%s", issue.Code, issue.Severity, index, issue.Field))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestValidateAction(t *testing.T) {
+	var board []Element
+	if err := json.Unmarshal([]byte(validateBoard), &board); err != nil {
+		t.Fatalf("failed to parse board: %v", err)
+	}
+	tests := []struct {
+		name   string
+		action string
+		want   string
+	}{
+		{
+			name:   "valid add",
+			action: `{"action":"add","elements":[{"id":"b","type":"ellipse","x":10,"y":10,"width":50,"height":50,"backgroundColor":"#a5d8ff","frameId":"f"},{"id":"ab","type":"arrow","x":0,"y":0,"points":[[0,0],[10,0]],"start":{"id":"a"},"end":{"id":"b"}}]}`,
+		},
+		{name: "add without elements", action: `{"action":"add","elements":[]}`, want: "missing_elements/error/-/"},
+		{name: "duplicate id", action: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0}]}`, want: "duplicate_element_id/error/0/id"},
+		{name: "type not allowed", action: `{"action":"add","elements":[{"id":"b","type":"star","x":0,"y":0}]}`, want: "type_not_allowed/error/0/type"},
+		{name: "update keeps a type it can't create", action: `{"action":"update","elements":[{"id":"doodle","type":"freedraw","x":10,"y":200}]}`},
+		{name: "update to a type it can't create", action: `{"action":"update","elements":[{"id":"a","type":"freedraw","x":0,"y":0}]}`, want: "type_not_allowed/error/0/type"},
+		{name: "unknown update", action: `{"action":"update","elements":[{"id":"zz","type":"rectangle","x":0,"y":0}]}`, want: "unknown_element_id/error/0/id"},
+		{
+			name:   "invalid colors",
+			action: `{"action":"add","elements":[{"id":"b","type":"rectangle","x":0,"y":0,"backgroundColor":"blue","strokeColor":"transparent","label":{"text":"B","strokeColor":"#12"}}]}`,
+			want:   "invalid_color/error/0/backgroundColor invalid_color/error/0/label.strokeColor",
+		},
+		{
+			name:   "numbers out of range",
+			action: `{"action":"add","elements":[{"id":"b","type":"rectangle","x":-50,"y":2000000,"strokeWidth":-1}]}`,
+			want:   "invalid_number/error/0/y invalid_number/error/0/strokeWidth",
+		},
+		{name: "one point", action: `{"action":"add","elements":[{"id":"l","type":"line","x":0,"y":0,"points":[[0,0]]}]}`, want: "invalid_points/error/0/points"},
+		{name: "bad point", action: `{"action":"add","elements":[{"id":"l","type":"line","x":0,"y":0,"points":[[0,0],[1,2,3]]}]}`, want: "invalid_points/error/0/points[1]"},
+		{
+			name:   "binding unresolvable",
+			action: `{"action":"add","elements":[{"id":"ab","type":"arrow","x":0,"y":0,"start":{"id":"a"},"end":{"id":"gone"}}]}`,
+			want:   "binding_unresolvable/error/0/end.id",
+		},
+		{name: "frame that isn't a frame", action: `{"action":"add","elements":[{"id":"b","type":"rectangle","x":0,"y":0,"frameId":"a"}]}`, want: "frame_unresolvable/error/0/frameId"},
+		{name: "frame child unknown", action: `{"action":"add","elements":[{"id":"g","type":"frame","x":0,"y":0,"children":["a","gone"]}]}`, want: "unknown_element_id/error/0/children"},
+		{name: "image of a file on the board", action: `{"action":"add","elements":[{"id":"pic-2","type":"image","x":0,"y":0,"fileId":"file-1"}]}`},
+		{name: "image of an unknown file", action: `{"action":"add","elements":[{"id":"pic-2","type":"image","x":0,"y":0,"fileId":"file-9"}]}`, want: "unknown_file/error/0/fileId"},
+		{name: "image without a file", action: `{"action":"add","elements":[{"id":"pic-2","type":"image","x":0,"y":0}]}`, want: "unknown_file/error/0/fileId"},
+		// A replace empties the board, so its elements may reuse ids, and
+		// may show the board's images again.
+		{name: "replace", action: `{"action":"replace","elements":[{"id":"a","type":"rectangle","x":0,"y":0},{"id":"pic","type":"image","x":0,"y":0,"fileId":"file-1"}]}`},
+		{name: "replace bound to an element it drops", action: `{"action":"replace","elements":[{"id":"l","type":"arrow","x":0,"y":0,"start":{"id":"f"}}]}`, want: "binding_unresolvable/error/0/start.id"},
+		{name: "delete unknown id", action: `{"action":"delete","delete_ids":["a","zz"]}`, want: "unknown_element_id/warning/1/delete_ids"},
+		{name: "delete nothing", action: `{"action":"delete","delete_ids":[]}`, want: "missing_elements/error/-/"},
+		{name: "clear with elements", action: `{"action":"clear","delete_ids":["a"]}`, want: "unexpected_elements/error/-/"},
+		{name: "reorder", action: `{"action":"reorder","target_ids":["a"],"position":"front"}`},
+		{
+			name:   "reorder unknown and nowhere",
+			action: `{"action":"reorder","target_ids":["zz"],"position":"top"}`,
+			want:   "unknown_element_id/error/0/target_ids invalid_position/error/-/position",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var action WhiteboardAction
+			if err := json.Unmarshal([]byte(tt.action), &action); err != nil {
+				t.Fatalf("failed to parse action: %v", err)
+			}
+			report := ValidateAction(&action, board)
+			if got := describeIssues(report.Issues); got != tt.want {
+				t.Errorf("issues = %q, want %q", got, tt.want)
+			}
+			for _, issue := range report.Issues {
+				if issue.Message == "" || issue.Repair == "" {
+					t.Errorf("%s has message %q and repair %q, want both", issue.Code, issue.Message, issue.Repair)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateActionLimit(t *testing.T) {
+	elements := make([]Element, MaxActionElements+1)
+	for i := range elements {
+		elements[i] = Element{ID: fmt.Sprintf("e%d", i), Type: "rectangle"}
+	}
+	report := ValidateAction(&WhiteboardAction{Action: ActionAdd, Elements: elements}, nil)
+	if got := describeIssues(report.Issues); got != "limit_exceeded/error/-/" {
+		t.Errorf("issues = %q, want only limit_exceeded", got)
+	}
+}
+
+// TestValidationReportJSON pins the report's JSON shape, which clients read
+// from failed attempts and validationWarnings.
+func TestValidationReportJSON(t *testing.T) {
+	var action WhiteboardAction
+	json.Unmarshal([]byte(`{"action":"add","elements":[{"id":"b","type":"rectangle","x":0,"y":0,"strokeColor":"red"}]}`), &action)
+	report := ValidateAction(&action, nil)
+	report.Issues = append(report.Issues, ValidateAction(&WhiteboardAction{Action: ActionDelete}, nil).Issues...)
+
+	got, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"issues":[` +
+		`{"code":"invalid_color","severity":"error","index":0,"elementId":"b","field":"strokeColor","message":"strokeColor \"red\" is not a hex color","repair":"use a hex color such as \"#1971c2\", or \"transparent\""},` +
+		`{"code":"missing_elements","severity":"error","message":"delete action has no delete_ids","repair":"include the elements the action applies to"}]}`
+	if string(got) != want {
+		t.Errorf("report = %s\nwant %s", got, want)
+	}
+
+	err = &ValidationError{Report: report}
+	if want := `invalid action: invalid_color: strokeColor "red" is not a hex color; missing_elements: delete action has no delete_ids`; err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestRepairInstructions(t *testing.T) {
+	zero, one := 0, 1
+	got := RepairInstructions([]ValidationIssue{
+		{Code: IssueBindingUnresolvable, Index: &zero, ElementID: "ab", Field: "end.id", Message: "prose the prompt ignores"},
+		{Code: IssueUnknownElementID, Index: &one, ElementID: "zz", Field: "delete_ids"},
+		{Code: IssueLimitExceeded},
+	})
+	want := strings.Join([]string{
+		`- binding_unresolvable at elements[0].end.id (id "ab"): ` + repairHints[IssueBindingUnresolvable],
+		`- unknown_element_id at delete_ids[1] (id "zz"): ` + repairHints[IssueUnknownElementID],
+		`- limit_exceeded at action: ` + repairHints[IssueLimitExceeded],
+	}, "\n")
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRepairHintsCoverEveryCode(t *testing.T) {
+	codes := []string{
+		IssueUnknownElementID, IssueDuplicateElementID, IssueInvalidColor, IssueBindingUnresolvable,
+		IssueLimitExceeded, IssueTypeNotAllowed, IssueMissingElements, IssueInvalidNumber,
+		IssueUnexpectedElements, IssueInvalidPoints, IssueFrameUnresolvable, IssueUnknownFile, IssueInvalidPosition,
+	}
+	for _, code := range codes {
+		if repairHints[code] == "" {
+			t.Errorf("%s has no repair hint", code)
+		}
+	}
+}
+
+func TestDropBadBindings(t *testing.T) {
+	var action WhiteboardAction
+	json.Unmarshal([]byte(`{"action":"add","elements":[{"id":"ab","type":"arrow","x":0,"y":0,"start":{"id":"gone"},"end":{"id":"b"}},{"id":"b","type":"rectangle","x":0,"y":0,"strokeColor":"red"}]}`), &action)
+
+	report := DropBadBindings(&action, ValidateAction(&action, nil))
+	if got := describeIssues(report.Issues); got != "invalid_color/error/1/strokeColor binding_unresolvable/warning/0/start.id" {
+		t.Errorf("issues = %q, want the binding downgraded and the color kept", got)
+	}
+	if arrow := action.Elements[0]; arrow.Start != nil || arrow.End == nil || arrow.End.ID != "b" {
+		t.Errorf("arrow = %+v, want only the bad start dropped", arrow)
+	}
+}
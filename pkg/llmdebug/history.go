@@ -0,0 +1,210 @@
+// Package llmdebug keeps the last few model interactions of each board in
+// memory, so support can see exactly what the model answered without
+// turning on debug logging for everyone.
+package llmdebug
+
+import (
+	"sync"
+	"time"
+
+	"draw/pkg/livekit"
+)
+
+// Entry is one instruction as the model saw and answered it. It holds
+// prompt metadata, not the prompt: the board state is left out.
+type Entry struct {
+	RequestID   string    `json:"requestId"`
+	At          time.Time `json:"at"`
+	Instruction string    `json:"instruction"`
+
+	Provider         string   `json:"provider,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	PromptVersion    string   `json:"promptVersion,omitempty"`
+	FastPath         bool     `json:"fastPath,omitempty"`
+	CompactionLevel  int      `json:"compactionLevel,omitempty"`
+	DroppedSections  []string `json:"droppedSections,omitempty"`
+	PromptTokens     int      `json:"promptTokens,omitempty"`
+	CompletionTokens int      `json:"completionTokens,omitempty"`
+	LatencyMs        int64    `json:"latencyMs"`
+
+	// Attempts hold each attempt's raw output, sanitized, unless raw output
+	// may not be stored; then only how each attempt failed is kept.
+	Attempts []Attempt `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+
+	size int
+}
+
+// Attempt is one model answer and, if it was rejected, why.
+type Attempt struct {
+	Output string `json:"output,omitempty"`
+	Stage  string `json:"stage,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// History is a bounded, concurrency-safe per-board history. It implements
+// livekit.InstructionRecorder. Boards keep at most size entries, entries
+// expire after ttl, and once all boards together exceed maxBytes the oldest
+// entries go first, whichever board they belong to.
+type History struct {
+	size      int
+	ttl       time.Duration
+	maxBytes  int
+	rawOutput bool
+
+	mu     sync.Mutex
+	boards map[string][]Entry
+	bytes  int
+}
+
+// NewHistory creates a history. With rawOutput false, model output is never
+// kept, only the metadata around it.
+func NewHistory(size int, ttl time.Duration, maxBytes int, rawOutput bool) *History {
+	if size < 1 {
+		size = 1
+	}
+	return &History{
+		size:      size,
+		ttl:       ttl,
+		maxBytes:  maxBytes,
+		rawOutput: rawOutput,
+		boards:    make(map[string][]Entry),
+	}
+}
+
+// RecordInstruction implements livekit.InstructionRecorder.
+func (h *History) RecordInstruction(sessionID string, boardID string, trace livekit.InstructionTrace) {
+	entry := h.entry(trace)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expire()
+	entries := append(h.boards[boardID], entry)
+	h.bytes += entry.size
+	for len(entries) > h.size {
+		h.bytes -= entries[0].size
+		entries = entries[1:]
+	}
+	h.boards[boardID] = entries
+	for h.maxBytes > 0 && h.bytes > h.maxBytes {
+		if !h.evictOldest() {
+			break
+		}
+	}
+}
+
+// Entries returns the board's history, newest first.
+func (h *History) Entries(boardID string) []Entry {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expire()
+	entries := h.boards[boardID]
+	newestFirst := make([]Entry, len(entries))
+	for i, entry := range entries {
+		newestFirst[len(entries)-1-i] = entry
+	}
+	return newestFirst
+}
+
+// Clear forgets the board's history, e.g. once the board is deleted.
+func (h *History) Clear(boardID string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, entry := range h.boards[boardID] {
+		h.bytes -= entry.size
+	}
+	delete(h.boards, boardID)
+}
+
+func (h *History) entry(trace livekit.InstructionTrace) Entry {
+	entry := Entry{
+		RequestID:   trace.RequestID,
+		At:          trace.At,
+		Instruction: trace.Transcription,
+		Attempts:    []Attempt{},
+	}
+	if result := trace.Result; result != nil {
+		for _, attempt := range result.Attempts {
+			recorded := Attempt{Stage: attempt.Stage, Error: attempt.Error}
+			if h.rawOutput {
+				recorded.Output = livekit.SanitizeOutput(attempt.Output)
+			}
+			entry.Attempts = append(entry.Attempts, recorded)
+		}
+		if response := result.Response; response != nil {
+			entry.Provider = response.Provider
+			entry.Model = response.Model
+			entry.PromptVersion = response.PromptVersion
+			entry.FastPath = response.FastPath
+			entry.CompactionLevel = response.CompactionLevel
+			entry.DroppedSections = response.DroppedSections
+			entry.PromptTokens = response.Usage.PromptTokens
+			entry.CompletionTokens = response.Usage.CompletionTokens
+			entry.LatencyMs = response.Latency.Milliseconds()
+		}
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
+		}
+	}
+
+	entry.size = len(entry.RequestID) + len(entry.Instruction) + len(entry.Error) + len(entry.Provider) + len(entry.Model) + len(entry.PromptVersion)
+	for _, attempt := range entry.Attempts {
+		entry.size += len(attempt.Output) + len(attempt.Stage) + len(attempt.Error)
+	}
+	return entry
+}
+
+// expire drops entries older than the TTL. The caller holds h.mu.
+func (h *History) expire() {
+	if h.ttl <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-h.ttl)
+	for boardID, entries := range h.boards {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.At.Before(cutoff) {
+				h.bytes -= entry.size
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		if len(kept) == 0 {
+			delete(h.boards, boardID)
+			continue
+		}
+		h.boards[boardID] = kept
+	}
+}
+
+// evictOldest drops the oldest entry of any board and reports whether there
+// was one. The caller holds h.mu.
+func (h *History) evictOldest() bool {
+	oldestBoard := ""
+	var oldest time.Time
+	for boardID, entries := range h.boards {
+		if oldestBoard == "" || entries[0].At.Before(oldest) {
+			oldestBoard, oldest = boardID, entries[0].At
+		}
+	}
+	if oldestBoard == "" {
+		return false
+	}
+	entries := h.boards[oldestBoard]
+	h.bytes -= entries[0].size
+	if len(entries) == 1 {
+		delete(h.boards, oldestBoard)
+	} else {
+		h.boards[oldestBoard] = entries[1:]
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package llmdebug
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+)
+
+type record struct {
+	board string
+	age   time.Duration
+	text  string
+}
+
+func trace(text string, age time.Duration, attempts ...livekit.PipelineAttempt) livekit.InstructionTrace {
+	return livekit.InstructionTrace{
+		RequestID:     "req-" + text,
+		At:            time.Now().Add(-age),
+		Transcription: text,
+		BoardState:    `[{"id":"secret"}]`,
+		Result:        &livekit.PipelineResult{Attempts: attempts},
+	}
+}
+
+// instructions lists the board's history, newest first.
+func instructions(h *History, board string) string {
+	var texts []string
+	for _, entry := range h.Entries(board) {
+		texts = append(texts, entry.Instruction)
+	}
+	return strings.Join(texts, " ")
+}
+
+func TestHistoryBounds(t *testing.T) {
+	// Each of these entries is 8 bytes: "req-" plus the 2-byte text, twice.
+	tests := []struct {
+		name     string
+		size     int
+		ttl      time.Duration
+		maxBytes int
+		records  []record
+		want     map[string]string
+	}{
+		{
+			name:    "newest first",
+			size:    10,
+			records: []record{{"b1", 0, "a1"}, {"b1", 0, "a2"}, {"b2", 0, "c1"}},
+			want:    map[string]string{"b1": "a2 a1", "b2": "c1", "b3": ""},
+		},
+		{
+			name:    "per-board size",
+			size:    2,
+			records: []record{{"b1", 0, "a1"}, {"b1", 0, "a2"}, {"b1", 0, "a3"}, {"b2", 0, "c1"}},
+			want:    map[string]string{"b1": "a3 a2", "b2": "c1"},
+		},
+		{
+			name:    "expired entries",
+			size:    10,
+			ttl:     time.Hour,
+			records: []record{{"b1", 2 * time.Hour, "a1"}, {"b1", time.Minute, "a2"}, {"b2", 2 * time.Hour, "c1"}},
+			want:    map[string]string{"b1": "a2", "b2": ""},
+		},
+		{
+			name:     "total size evicts the oldest of any board",
+			size:     10,
+			maxBytes: 24,
+			records:  []record{{"b1", 3 * time.Minute, "a1"}, {"b2", 2 * time.Minute, "c1"}, {"b1", time.Minute, "a2"}, {"b2", 0, "c2"}},
+			want:     map[string]string{"b1": "a2", "b2": "c2 c1"},
+		},
+		{
+			name:     "entry larger than the total",
+			size:     10,
+			maxBytes: 4,
+			records:  []record{{"b1", 0, "a1"}},
+			want:     map[string]string{"b1": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(tt.size, tt.ttl, tt.maxBytes, true)
+			for _, r := range tt.records {
+				h.RecordInstruction("s1", r.board, trace(r.text, r.age))
+			}
+			for board, want := range tt.want {
+				if got := instructions(h, board); got != want {
+					t.Errorf("%s = %q, want %q", board, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryRawOutput(t *testing.T) {
+	attempts := []livekit.PipelineAttempt{
+		{Output: "not json\x07", Stage: "parse", Error: "invalid character"},
+		{Output: `{"action":"clear"}`},
+	}
+	tests := []struct {
+		name      string
+		rawOutput bool
+		want      []Attempt
+	}{
+		{
+			name:      "stored sanitized",
+			rawOutput: true,
+			want:      []Attempt{{Output: "not json", Stage: "parse", Error: "invalid character"}, {Output: `{"action":"clear"}`}},
+		},
+		{
+			// A deployment that may not store raw output still sees how
+			// each attempt failed.
+			name: "forbidden by policy",
+			want: []Attempt{{Stage: "parse", Error: "invalid character"}, {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(10, time.Hour, 0, tt.rawOutput)
+			tr := trace("draw", 0, attempts...)
+			tr.Result.Response = &llm.LLMResponse{Provider: "ollama", Model: "llama3", Usage: llm.Usage{PromptTokens: 120}, Latency: 1500 * time.Millisecond}
+			tr.Result.Err = errors.New("every attempt failed")
+			h.RecordInstruction("s1", "b1", tr)
+
+			entries := h.Entries("b1")
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if fmt.Sprint(entry.Attempts) != fmt.Sprint(tt.want) {
+				t.Errorf("attempts = %+v, want %+v", entry.Attempts, tt.want)
+			}
+			if entry.Provider != "ollama" || entry.Model != "llama3" || entry.PromptTokens != 120 || entry.LatencyMs != 1500 || entry.Error != "every attempt failed" {
+				t.Errorf("entry = %+v, want the response metadata and error", entry)
+			}
+		})
+	}
+}
+
+func TestHistoryClear(t *testing.T) {
+	h := NewHistory(10, 0, 0, true)
+	h.RecordInstruction("s1", "b1", trace("a1", 0))
+	h.RecordInstruction("s1", "b2", trace("c1", 0))
+	h.Clear("b1")
+
+	if got := instructions(h, "b1"); got != "" {
+		t.Errorf("b1 = %q after Clear, want nothing", got)
+	}
+	if got := instructions(h, "b2"); got != "c1" {
+		t.Errorf("b2 = %q, want it untouched", got)
+	}
+	if h.bytes != 8 {
+		t.Errorf("bytes = %d, want only b2's 8", h.bytes)
+	}
+
+	var disabled *History
+	disabled.Clear("b1")
+	if entries := disabled.Entries("b1"); entries != nil {
+		t.Errorf("disabled history returned %v", entries)
+	}
+}
+
+func TestHistoryConcurrent(t *testing.T) {
+	h := NewHistory(5, time.Hour, 200, true)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		board := fmt.Sprintf("b%d", i%3)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.RecordInstruction("s1", board, trace(fmt.Sprintf("i%02d", j), 0))
+				h.Entries(board)
+				if j%25 == 0 {
+					h.Clear(board)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, board := range []string{"b0", "b1", "b2"} {
+		entries := h.Entries(board)
+		if len(entries) > 5 {
+			t.Errorf("%s has %d entries, want at most 5", board, len(entries))
+		}
+		for _, entry := range entries {
+			total += entry.size
+		}
+	}
+	if total != h.bytes || total > 200 {
+		t.Errorf("entries hold %d bytes, counted %d, want them equal and at most 200", total, h.bytes)
+	}
+}
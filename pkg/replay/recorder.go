@@ -0,0 +1,162 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+)
+
+// Recorder writes one recording per voice session into a directory,
+// rewriting it after every utterance. It keeps each session's recording in
+// memory, so it is meant for development and staging only.
+type Recorder struct {
+	dir string
+
+	mu       sync.Mutex
+	sessions map[string]*sessionRecording
+}
+
+type sessionRecording struct {
+	mu         sync.Mutex
+	path       string
+	recording  Recording
+	resultHash string
+}
+
+// NewRecorder records into dir, creating it if needed.
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	return &Recorder{
+		dir:      dir,
+		sessions: make(map[string]*sessionRecording),
+	}, nil
+}
+
+// RecordInstruction implements livekit.InstructionRecorder. Failures are
+// logged; recording never gets in the way of the session.
+func (r *Recorder) RecordInstruction(sessionID string, boardID string, trace livekit.InstructionTrace) {
+	session := r.session(sessionID, boardID, trace)
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	utterance, err := session.add(trace)
+	if err != nil {
+		fmt.Println("Failed to record instruction", err, "sessionID", sessionID)
+		return
+	}
+	session.recording.Utterances = append(session.recording.Utterances, utterance)
+	if err := WriteFile(session.path, &session.recording); err != nil {
+		fmt.Println("Failed to write session recording", err, "sessionID", sessionID)
+	}
+}
+
+func (r *Recorder) session(sessionID string, boardID string, trace livekit.InstructionTrace) *sessionRecording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		name := strings.NewReplacer(":", "_", "/", "_").Replace(sessionID)
+		session = &sessionRecording{
+			path: filepath.Join(r.dir, fmt.Sprintf("%s-%s.json", name, trace.At.Format("20060102T150405"))),
+			recording: Recording{
+				Version:   recordingVersion,
+				SessionID: sessionID,
+				BoardID:   boardID,
+				StartedAt: trace.At,
+			},
+		}
+		r.sessions[sessionID] = session
+	}
+	return session
+}
+
+// add turns a trace into an utterance. The board is stored with the
+// utterance only when it isn't what the previous utterance left behind.
+func (s *sessionRecording) add(trace livekit.InstructionTrace) (Utterance, error) {
+	var board []llm.Element
+	if err := json.Unmarshal([]byte(trace.BoardState), &board); err != nil {
+		return Utterance{}, fmt.Errorf("failed to parse board state: %w", err)
+	}
+	boardHash, err := hashBoard(board)
+	if err != nil {
+		return Utterance{}, err
+	}
+
+	result := trace.Result
+	utterance := Utterance{
+		RequestID:    trace.RequestID,
+		At:           trace.At,
+		AudioRef:     trace.AudioRef,
+		Transcript:   trace.Transcription,
+		Instructions: trace.Instructions,
+		BoardHash:    boardHash,
+		Timezone:     trace.Timezone,
+		Locale:       trace.Locale,
+		ArrowRepair:  trace.ArrowRepair,
+		Attempts:     result.Attempts,
+		Action:       result.Action,
+		Followups:    followupActions(result),
+	}
+	if len(s.recording.Utterances) == 0 {
+		s.recording.Board = board
+	} else if boardHash != s.resultHash {
+		utterance.Board = board
+	}
+	if result.Response != nil {
+		utterance.Provider = result.Response.Provider
+		utterance.Warning = result.Response.Warning
+	}
+	if result.Err != nil {
+		utterance.Error = result.Err.Error()
+	}
+
+	utterance.ResultHash, err = resultHash(board, append([]*llm.WhiteboardAction{result.Action}, utterance.Followups...))
+	if err != nil {
+		return Utterance{}, err
+	}
+	s.resultHash = utterance.ResultHash
+	return utterance, nil
+}
+
+// resultHash hashes the board after actions are applied to it in order.
+func resultHash(board []llm.Element, actions []*llm.WhiteboardAction) (string, error) {
+	for _, action := range actions {
+		if action == nil {
+			continue
+		}
+		var err error
+		if board, err = whiteboard.ApplyAction(board, action); err != nil {
+			return "", err
+		}
+	}
+	return hashBoard(board)
+}
+
+// followupActions returns the actions of the result's follow-up responses.
+func followupActions(result *livekit.PipelineResult) []*llm.WhiteboardAction {
+	var actions []*llm.WhiteboardAction
+	for _, followup := range result.Followups {
+		actions = append(actions, followup.ParsedAction)
+	}
+	return actions
+}
+
+// hashBoard hashes the board so that an empty board hashes the same however
+// it was encoded.
+func hashBoard(board []llm.Element) (string, error) {
+	if board == nil {
+		board = []llm.Element{}
+	}
+	return whiteboard.HashElements(board)
+}
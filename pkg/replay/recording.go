@@ -0,0 +1,99 @@
+// Package replay records voice sessions instruction by instruction and
+// replays the recordings against the current pipeline, so a reported bug can
+// be turned into a regression check.
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+)
+
+// recordingVersion is bumped when the format changes incompatibly.
+const recordingVersion = 1
+
+// Recording is a replayable bundle of one voice session.
+type Recording struct {
+	Version   int       `json:"version"`
+	SessionID string    `json:"sessionId"`
+	BoardID   string    `json:"boardId"`
+	StartedAt time.Time `json:"startedAt"`
+	// Board is the fixture the first utterance ran against.
+	Board      []llm.Element `json:"board"`
+	Utterances []Utterance   `json:"utterances"`
+}
+
+// Utterance is one instruction: its inputs and what the pipeline made of it.
+type Utterance struct {
+	RequestID  string    `json:"requestId"`
+	At         time.Time `json:"at"`
+	AudioRef   string    `json:"audioRef,omitempty"`
+	Transcript string    `json:"transcript"`
+	// Instructions are the utterances of a batch sent to the LLM together;
+	// Transcript holds them joined.
+	Instructions []string `json:"instructions,omitempty"`
+	// BoardHash is the canonical hash of the board the instruction ran
+	// against. Board holds the board itself only when it differs from the
+	// previous utterance's outcome, i.e. when it was edited in between.
+	BoardHash   string        `json:"boardHash"`
+	Board       []llm.Element `json:"board,omitempty"`
+	Timezone    string        `json:"timezone,omitempty"`
+	Locale      string        `json:"locale,omitempty"`
+	ArrowRepair string        `json:"arrowRepair"`
+
+	Provider string                    `json:"provider,omitempty"`
+	Attempts []livekit.PipelineAttempt `json:"attempts"`
+	// Action is the resolved action applied to the board, or nil when the
+	// instruction failed with Error.
+	Action *llm.WhiteboardAction `json:"action,omitempty"`
+	// Followups are the actions applied after Action when a batch was
+	// answered with several.
+	Followups  []*llm.WhiteboardAction `json:"followups,omitempty"`
+	Warning    string                  `json:"warning,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	ResultHash string                  `json:"resultHash"`
+}
+
+// ReadFile loads a recording.
+func ReadFile(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("invalid recording %s: %w", path, err)
+	}
+	if rec.Version != recordingVersion {
+		return nil, fmt.Errorf("recording %s has version %d, expected %d", path, rec.Version, recordingVersion)
+	}
+	return &rec, nil
+}
+
+// WriteFile saves a recording, replacing the file atomically so a reader
+// never sees it half written.
+func WriteFile(path string, rec *Recording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
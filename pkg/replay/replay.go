@@ -0,0 +1,221 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+)
+
+// Divergence is one way a replayed utterance differs from the recording.
+type Divergence struct {
+	Utterance  int    `json:"utterance"`
+	RequestID  string `json:"requestId"`
+	Transcript string `json:"transcript"`
+	Field      string `json:"field"`
+	Recorded   string `json:"recorded"`
+	Replayed   string `json:"replayed"`
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("utterance %d (%q): %s differs\n  recorded: %s\n  replayed: %s", d.Utterance, d.Transcript, d.Field, d.Recorded, d.Replayed)
+}
+
+// Report lists the divergences found replaying a recording. Divergences
+// after the first may be knock-on effects of it, since each utterance runs
+// against the board the replay left behind.
+type Report struct {
+	SessionID   string       `json:"sessionId"`
+	Utterances  int          `json:"utterances"`
+	Divergences []Divergence `json:"divergences"`
+}
+
+// OK reports whether the replay matched the recording.
+func (r *Report) OK() bool {
+	return len(r.Divergences) == 0
+}
+
+// Replay runs every utterance of rec through the current pipeline, answering
+// LLM calls with the recorded model output, and compares the outcome with
+// what was recorded. The error is only for recordings that can't be replayed
+// at all.
+func Replay(ctx context.Context, rec *Recording) (*Report, error) {
+	report := &Report{
+		SessionID:  rec.SessionID,
+		Utterances: len(rec.Utterances),
+	}
+
+	board := rec.Board
+	for i, utterance := range rec.Utterances {
+		if utterance.Board != nil {
+			board = utterance.Board
+		}
+		diverge := func(field string, recorded string, replayed string) {
+			report.Divergences = append(report.Divergences, Divergence{
+				Utterance:  i + 1,
+				RequestID:  utterance.RequestID,
+				Transcript: utterance.Transcript,
+				Field:      field,
+				Recorded:   recorded,
+				Replayed:   replayed,
+			})
+		}
+
+		boardHash, err := hashBoard(board)
+		if err != nil {
+			return nil, fmt.Errorf("utterance %d: %w", i+1, err)
+		}
+		if boardHash != utterance.BoardHash {
+			diverge("board before", utterance.BoardHash, boardHash)
+		}
+
+		result, err := replayUtterance(ctx, board, utterance)
+		if err != nil {
+			return nil, fmt.Errorf("utterance %d: %w", i+1, err)
+		}
+
+		recordedAction, err := encodeAction(utterance.Action)
+		if err != nil {
+			return nil, fmt.Errorf("utterance %d: %w", i+1, err)
+		}
+		replayedAction, err := encodeAction(result.Action)
+		if err != nil {
+			return nil, fmt.Errorf("utterance %d: %w", i+1, err)
+		}
+		if recordedAction != replayedAction {
+			diverge("action", recordedAction, replayedAction)
+		}
+		replayedFollowups := followupActions(result)
+		for j := 0; j < len(utterance.Followups) || j < len(replayedFollowups); j++ {
+			var recorded, replayed *llm.WhiteboardAction
+			if j < len(utterance.Followups) {
+				recorded = utterance.Followups[j]
+			}
+			if j < len(replayedFollowups) {
+				replayed = replayedFollowups[j]
+			}
+			recordedFollowup, err := encodeAction(recorded)
+			if err != nil {
+				return nil, fmt.Errorf("utterance %d: %w", i+1, err)
+			}
+			replayedFollowup, err := encodeAction(replayed)
+			if err != nil {
+				return nil, fmt.Errorf("utterance %d: %w", i+1, err)
+			}
+			if recordedFollowup != replayedFollowup {
+				diverge(fmt.Sprintf("followup %d", j+1), recordedFollowup, replayedFollowup)
+			}
+		}
+
+		replayedError := ""
+		if result.Err != nil {
+			replayedError = result.Err.Error()
+		}
+		if replayedError != utterance.Error {
+			diverge("error", utterance.Error, replayedError)
+		}
+		replayedWarning := ""
+		if result.Response != nil {
+			replayedWarning = result.Response.Warning
+		}
+		if replayedWarning != utterance.Warning {
+			diverge("warning", utterance.Warning, replayedWarning)
+		}
+
+		for _, action := range append([]*llm.WhiteboardAction{result.Action}, replayedFollowups...) {
+			if action == nil {
+				continue
+			}
+			if board, err = whiteboard.ApplyAction(board, action); err != nil {
+				diverge("apply", "", err.Error())
+				break
+			}
+		}
+		resultHash, err := hashBoard(board)
+		if err != nil {
+			return nil, fmt.Errorf("utterance %d: %w", i+1, err)
+		}
+		if resultHash != utterance.ResultHash {
+			diverge("board after", utterance.ResultHash, resultHash)
+		}
+	}
+	return report, nil
+}
+
+// replayUtterance runs the pipeline with the utterance's recorded inputs,
+// allowing it as many attempts as the recording made.
+func replayUtterance(ctx context.Context, board []llm.Element, utterance Utterance) (*livekit.PipelineResult, error) {
+	boardState, err := json.Marshal(board)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode board: %w", err)
+	}
+	if board == nil {
+		boardState = []byte("[]")
+	}
+
+	client := llm.NewReplayLLMClient(utterance.Provider)
+	for _, attempt := range utterance.Attempts {
+		output := llm.ReplayOutput{Response: attempt.Output}
+		if attempt.Stage == livekit.StageGenerate {
+			output = llm.ReplayOutput{Err: attempt.Error}
+		}
+		client.Load(output)
+	}
+
+	// Utterances answered by the fast path were recorded without attempts.
+	pipeline := &livekit.Pipeline{
+		LLMClient:   client,
+		MaxAttempts: len(utterance.Attempts),
+		FastPath:    len(utterance.Attempts) == 0,
+	}
+	inst := pipeline.Prepare(utterance.RequestID, utterance.Transcript, string(boardState), utterance.At, utterance.Timezone, utterance.Locale, utterance.ArrowRepair)
+	if len(utterance.Instructions) > 0 {
+		inst = pipeline.PrepareBatch(utterance.RequestID, utterance.Instructions, string(boardState), utterance.At, utterance.Timezone, utterance.Locale, utterance.ArrowRepair)
+	}
+	return pipeline.Run(ctx, inst), nil
+}
+
+func encodeAction(action *llm.WhiteboardAction) (string, error) {
+	if action == nil {
+		return "", nil
+	}
+	data, err := whiteboard.CanonicalJSON(action)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode action: %w", err)
+	}
+	return string(data), nil
+}
+
+// TB is the part of testing.TB the test helper uses.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// AssertReplays replays the recording at path and fails t with every
+// divergence, turning a recorded session into a regression test.
+func AssertReplays(t TB, path string) {
+	t.Helper()
+
+	rec, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read recording: %v", err)
+	}
+	report, err := Replay(context.Background(), rec)
+	if err != nil {
+		t.Fatalf("failed to replay %s: %v", path, err)
+	}
+	if !report.OK() {
+		var b strings.Builder
+		for _, d := range report.Divergences {
+			b.WriteString("\n")
+			b.WriteString(d.String())
+		}
+		t.Errorf("%s diverged from the recording in %d place(s):%s", path, len(report.Divergences), b.String())
+	}
+}
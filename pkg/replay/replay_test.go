@@ -0,0 +1,275 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"draw/pkg/livekit"
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+)
+
+// recordedSession drives the pipeline the way a voice session does and
+// records every instruction, keeping the board as a client would.
+type recordedSession struct {
+	t        *testing.T
+	recorder *Recorder
+	board    []llm.Element
+	at       time.Time
+	said     int
+}
+
+func newRecordedSession(t *testing.T) (*recordedSession, string) {
+	t.Helper()
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	return &recordedSession{
+		t:        t,
+		recorder: recorder,
+		at:       time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+	}, dir
+}
+
+// say runs an instruction that the model answers with outputs, one per
+// attempt; an output starting with "error: " fails the call instead. With
+// no outputs the instruction must take the fast path.
+func (s *recordedSession) say(transcript string, outputs ...string) {
+	s.t.Helper()
+	client := llm.NewReplayLLMClient("ollama")
+	for _, output := range outputs {
+		if message, ok := strings.CutPrefix(output, "error: "); ok {
+			client.Load(llm.ReplayOutput{Err: message})
+		} else {
+			client.Load(llm.ReplayOutput{Response: output})
+		}
+	}
+	pipeline := &livekit.Pipeline{LLMClient: client, MaxAttempts: len(outputs), FastPath: true}
+
+	boardState, err := json.Marshal(s.board)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if s.board == nil {
+		boardState = []byte("[]")
+	}
+	s.said++
+	s.at = s.at.Add(time.Minute)
+	requestID := fmt.Sprintf("req-%d", s.said)
+	inst := pipeline.Prepare(requestID, transcript, string(boardState), s.at, "UTC", "en-US", whiteboard.ArrowRepairUnbind)
+	result := pipeline.Run(context.Background(), inst)
+	if client.Remaining() != 0 {
+		s.t.Fatalf("%q left %d outputs unused", transcript, client.Remaining())
+	}
+
+	s.recorder.RecordInstruction("session-1", "board-1", livekit.InstructionTrace{
+		RequestID:     requestID,
+		At:            s.at,
+		Transcription: transcript,
+		BoardState:    string(boardState),
+		Timezone:      "UTC",
+		Locale:        "en-US",
+		ArrowRepair:   whiteboard.ArrowRepairUnbind,
+		Result:        result,
+	})
+	if result.Action != nil {
+		if s.board, err = whiteboard.ApplyAction(s.board, result.Action); err != nil {
+			s.t.Fatalf("ApplyAction: %v", err)
+		}
+	}
+}
+
+// readRecording loads the single recording in dir.
+func readRecording(t *testing.T, dir string) (*Recording, string) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("got recordings %v (%v), want one", paths, err)
+	}
+	rec, err := ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	return rec, paths[0]
+}
+
+// recordSession records four instructions: one answered at once, one after
+// a retry, one by the fast path after the user edited the board by hand, and
+// one that failed.
+func recordSession(t *testing.T) (*Recording, string) {
+	t.Helper()
+	s, dir := newRecordedSession(t)
+	s.say("add a start box",
+		`{"action":"add","elements":[{"id":"start","type":"rectangle","x":0,"y":0,"width":100,"height":60,"label":{"text":"Start"}}]}`)
+	s.say("add a circle",
+		`here you go: {"action":`,
+		`{"action":"add","elements":[{"id":"circle","type":"ellipse","x":200,"y":0,"width":60,"height":60}]}`)
+	// Edited in the client between utterances.
+	s.board[1].Y = 300
+	s.say("delete start")
+	s.say("make the circle blue", "error: provider unavailable")
+	return readRecording(t, dir)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	rec, _ := recordSession(t)
+
+	if rec.SessionID != "session-1" || rec.BoardID != "board-1" || len(rec.Utterances) != 4 {
+		t.Fatalf("recorded session %s of board %s with %d utterances, want session-1, board-1 and 4", rec.SessionID, rec.BoardID, len(rec.Utterances))
+	}
+	if len(rec.Board) != 0 {
+		t.Errorf("recorded a starting board of %d elements, want the empty one", len(rec.Board))
+	}
+	for i, utterance := range rec.Utterances {
+		// Only the board edited by hand needs storing again.
+		if edited := i == 2; (utterance.Board != nil) != edited {
+			t.Errorf("utterance %d stored its board: %v, want %v", i+1, utterance.Board != nil, edited)
+		}
+	}
+	attempts := []int{1, 2, 0, 1}
+	for i, utterance := range rec.Utterances {
+		if len(utterance.Attempts) != attempts[i] {
+			t.Errorf("utterance %d recorded %d attempts, want %d", i+1, len(utterance.Attempts), attempts[i])
+		}
+	}
+	if rec.Utterances[2].Provider != livekit.FastPathProvider {
+		t.Errorf("utterance 3 came from %q, want the fast path", rec.Utterances[2].Provider)
+	}
+	if rec.Utterances[3].Error == "" || rec.Utterances[3].Action != nil {
+		t.Errorf("utterance 4 recorded action %+v and error %q, want only the error", rec.Utterances[3].Action, rec.Utterances[3].Error)
+	}
+
+	report, err := Replay(context.Background(), rec)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if !report.OK() || report.Utterances != 4 {
+		t.Errorf("replayed %d utterances with divergences %v, want 4 and none", report.Utterances, report.Divergences)
+	}
+}
+
+func TestReplayReportsDivergence(t *testing.T) {
+	tests := []struct {
+		name string
+		// change edits the recording the way a change to the pipeline would
+		// make it differ.
+		change func(rec *Recording)
+		// want is the first divergence as utterance:field.
+		want string
+	}{
+		{
+			name: "model answered differently",
+			change: func(rec *Recording) {
+				rec.Utterances[0].Attempts[0].Output = `{"action":"add","elements":[{"id":"start","type":"rectangle","x":50,"y":0,"width":100,"height":60,"label":{"text":"Start"}}]}`
+			},
+			want: "1:action",
+		},
+		{
+			name: "retry fails too",
+			change: func(rec *Recording) {
+				rec.Utterances[1].Attempts[1].Output = `still not JSON`
+			},
+			want: "2:action",
+		},
+		{
+			name: "edited board",
+			change: func(rec *Recording) {
+				rec.Utterances[2].Board[1].Y = 301
+			},
+			want: "3:board before",
+		},
+		{
+			name: "failure fixed",
+			change: func(rec *Recording) {
+				rec.Utterances[3].Attempts[0] = livekit.PipelineAttempt{Output: `{"action":"clear"}`}
+			},
+			want: "4:action",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := recordSession(t)
+			tt.change(rec)
+			report, err := Replay(context.Background(), rec)
+			if err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			if report.OK() {
+				t.Fatalf("replay matched the changed recording")
+			}
+			first := report.Divergences[0]
+			if got := fmt.Sprintf("%d:%s", first.Utterance, first.Field); got != tt.want {
+				t.Errorf("first divergence is %s, want %s; all: %v", got, tt.want, report.Divergences)
+			}
+		})
+	}
+}
+
+// recordingT is a TB that keeps what it is told.
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// assertReplays runs AssertReplays on its own goroutine, as Fatalf ends it,
+// and returns the failures reported.
+func assertReplays(path string) []string {
+	var t recordingT
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		AssertReplays(&t, path)
+	}()
+	<-done
+	return t.errors
+}
+
+func TestAssertReplays(t *testing.T) {
+	rec, path := recordSession(t)
+
+	if errors := assertReplays(path); len(errors) != 0 {
+		t.Errorf("matching recording failed with %v", errors)
+	}
+
+	rec.Utterances[0].Attempts[0].Output = `{"action":"clear"}`
+	changed := filepath.Join(t.TempDir(), "changed.json")
+	if err := WriteFile(changed, rec); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if errors := assertReplays(changed); len(errors) != 1 || !strings.Contains(errors[0], `utterance 1 ("add a start box"): action differs`) {
+		t.Errorf("changed recording failed with %v, want the divergent action reported", errors)
+	}
+
+	if errors := assertReplays(filepath.Join(t.TempDir(), "missing.json")); len(errors) != 1 {
+		t.Errorf("missing recording failed with %v, want one error", errors)
+	}
+}
+
+func TestReadFileRejectsOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.json")
+	if err := os.WriteFile(path, []byte(`{"version":0,"utterances":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(path); err == nil {
+		t.Errorf("got no error for a recording of another version")
+	}
+}
@@ -0,0 +1,317 @@
+// Package slo tracks voice instruction latency against a service level
+// objective, in fixed-size per-minute histograms kept in memory.
+package slo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Stages of a voice instruction.
+const (
+	StageQueue     = "queue"
+	StageLLM       = "llm"
+	StageApply     = "apply"
+	StageBroadcast = "broadcast"
+	StageTotal     = "total"
+)
+
+var stages = []string{StageQueue, StageLLM, StageApply, StageBroadcast, StageTotal}
+
+// bucketBounds are the histogram upper bounds in milliseconds. The last
+// bucket catches everything slower.
+var bucketBounds = [...]float64{50, 100, 250, 500, 1000, 2000, 3000, 4000, 5000, 7500, 10000, 20000}
+
+// retention is how many one-minute slots are kept; enough for the longest
+// burn-rate window.
+const retention = 6 * 60
+
+// Window is a span the SLI and burn rate are computed over.
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// BurnAlert fires when both its long and short windows burn error budget
+// faster than Threshold. The short window makes it stop firing soon after
+// the problem is fixed.
+type BurnAlert struct {
+	Name      string
+	Long      Window
+	Short     Window
+	Threshold float64
+}
+
+var (
+	window5m  = Window{Name: "5m", Duration: 5 * time.Minute}
+	window30m = Window{Name: "30m", Duration: 30 * time.Minute}
+	window1h  = Window{Name: "1h", Duration: time.Hour}
+	window6h  = Window{Name: "6h", Duration: 6 * time.Hour}
+
+	windows = []Window{window5m, window30m, window1h, window6h}
+
+	// The thresholds spend 2% of a 30-day budget in an hour and 5% in six
+	// hours respectively.
+	burnAlerts = []BurnAlert{
+		{Name: "fast", Long: window1h, Short: window5m, Threshold: 14.4},
+		{Name: "slow", Long: window6h, Short: window30m, Threshold: 6},
+	}
+)
+
+// Latency is one instruction's timing. Failed instructions count against
+// the objective whatever their latency.
+type Latency struct {
+	Stages map[string]time.Duration
+	Total  time.Duration
+	Failed bool
+}
+
+type histogram struct {
+	counts [len(bucketBounds) + 1]uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(ms float64) {
+	i := 0
+	for i < len(bucketBounds) && ms > bucketBounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += ms
+}
+
+func (h *histogram) merge(other *histogram) {
+	for i := range h.counts {
+		h.counts[i] += other.counts[i]
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// quantile returns the upper bound of the bucket holding quantile q, or -1
+// when everything above the highest bound is needed.
+func (h *histogram) quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			if i == len(bucketBounds) {
+				return -1
+			}
+			return bucketBounds[i]
+		}
+	}
+	return -1
+}
+
+type slot struct {
+	minute int64
+	total  uint64
+	good   uint64
+	stages map[string]*histogram
+}
+
+// Tracker aggregates latencies. It is safe for concurrent use; recording
+// and reporting only touch the fixed ring of slots.
+type Tracker struct {
+	target    time.Duration
+	objective float64
+	now       func() time.Time
+
+	mu        sync.Mutex
+	slots     [retention]slot
+	lastAlert map[string]time.Time
+}
+
+// NewTracker tracks the objective "objective of instructions finish within
+// target", e.g. 0.95 within 4s.
+func NewTracker(target time.Duration, objective float64) *Tracker {
+	return &Tracker{
+		target:    target,
+		objective: objective,
+		now:       time.Now,
+		lastAlert: make(map[string]time.Time),
+	}
+}
+
+// Record adds one instruction and logs any burn alert that starts firing,
+// at most once an hour per alert.
+func (t *Tracker) Record(latency Latency) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	s := t.slot(now)
+	s.total++
+	if !latency.Failed && latency.Total <= t.target {
+		s.good++
+	}
+	for stage, d := range latency.Stages {
+		s.histogram(stage).observe(float64(d) / float64(time.Millisecond))
+	}
+	s.histogram(StageTotal).observe(float64(latency.Total) / float64(time.Millisecond))
+
+	for _, status := range t.alerts(now) {
+		if status.Firing && now.Sub(t.lastAlert[status.Name]) >= time.Hour {
+			t.lastAlert[status.Name] = now
+			fmt.Printf("SLO %s burn alert: burn rate %.1f over %s and %.1f over %s (threshold %.1f)\n",
+				status.Name, status.LongBurnRate, status.Long, status.ShortBurnRate, status.Short, status.Threshold)
+		}
+	}
+}
+
+func (t *Tracker) slot(now time.Time) *slot {
+	minute := now.Unix() / 60
+	s := &t.slots[minute%retention]
+	if s.minute != minute {
+		*s = slot{minute: minute}
+	}
+	return s
+}
+
+func (s *slot) histogram(stage string) *histogram {
+	if s.stages == nil {
+		s.stages = make(map[string]*histogram)
+	}
+	h, ok := s.stages[stage]
+	if !ok {
+		h = &histogram{}
+		s.stages[stage] = h
+	}
+	return h
+}
+
+// WindowReport is the SLI over one window.
+type WindowReport struct {
+	Window   string             `json:"window"`
+	Total    uint64             `json:"total"`
+	Good     uint64             `json:"good"`
+	SLI      float64            `json:"sli"`
+	BurnRate float64            `json:"burnRate"`
+	P95Ms    map[string]float64 `json:"p95Ms"`
+}
+
+// AlertStatus is the state of one burn alert.
+type AlertStatus struct {
+	Name          string  `json:"name"`
+	Long          string  `json:"long"`
+	Short         string  `json:"short"`
+	Threshold     float64 `json:"threshold"`
+	LongBurnRate  float64 `json:"longBurnRate"`
+	ShortBurnRate float64 `json:"shortBurnRate"`
+	Firing        bool    `json:"firing"`
+}
+
+// Report is a point-in-time view of the SLO.
+type Report struct {
+	TargetMs  int64          `json:"targetMs"`
+	Objective float64        `json:"objective"`
+	Windows   []WindowReport `json:"windows"`
+	Alerts    []AlertStatus  `json:"alerts"`
+}
+
+// Report computes the SLI and burn rate for every window. A p95 of -1 means
+// it is above the highest histogram bucket.
+func (t *Tracker) Report() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	report := Report{
+		TargetMs:  t.target.Milliseconds(),
+		Objective: t.objective,
+		Alerts:    t.alerts(now),
+	}
+	for _, w := range windows {
+		total, good, stageHists := t.sum(now, w.Duration, true)
+		wr := WindowReport{
+			Window:   w.Name,
+			Total:    total,
+			Good:     good,
+			SLI:      1,
+			BurnRate: t.burnRate(total, good),
+			P95Ms:    make(map[string]float64, len(stages)),
+		}
+		if total > 0 {
+			wr.SLI = float64(good) / float64(total)
+		}
+		for _, stage := range stages {
+			if h, ok := stageHists[stage]; ok {
+				wr.P95Ms[stage] = h.quantile(0.95)
+			}
+		}
+		report.Windows = append(report.Windows, wr)
+	}
+	return report
+}
+
+func (t *Tracker) alerts(now time.Time) []AlertStatus {
+	statuses := make([]AlertStatus, 0, len(burnAlerts))
+	for _, alert := range burnAlerts {
+		longTotal, longGood, _ := t.sum(now, alert.Long.Duration, false)
+		shortTotal, shortGood, _ := t.sum(now, alert.Short.Duration, false)
+		status := AlertStatus{
+			Name:          alert.Name,
+			Long:          alert.Long.Name,
+			Short:         alert.Short.Name,
+			Threshold:     alert.Threshold,
+			LongBurnRate:  t.burnRate(longTotal, longGood),
+			ShortBurnRate: t.burnRate(shortTotal, shortGood),
+		}
+		status.Firing = status.LongBurnRate > alert.Threshold && status.ShortBurnRate > alert.Threshold
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// burnRate is the error rate as a multiple of the rate the objective allows;
+// 1 spends the error budget exactly over the SLO period.
+func (t *Tracker) burnRate(total uint64, good uint64) float64 {
+	if total == 0 || t.objective >= 1 {
+		return 0
+	}
+	errorRate := float64(total-good) / float64(total)
+	return errorRate / (1 - t.objective)
+}
+
+// sum adds up the slots within d of now, merging their histograms when
+// withStages is set.
+func (t *Tracker) sum(now time.Time, d time.Duration, withStages bool) (total uint64, good uint64, stageHists map[string]*histogram) {
+	current := now.Unix() / 60
+	oldest := current - int64(d/time.Minute) + 1
+	stageHists = make(map[string]*histogram)
+	for i := range t.slots {
+		s := &t.slots[i]
+		if s.minute < oldest || s.minute > current {
+			continue
+		}
+		total += s.total
+		good += s.good
+		if !withStages {
+			continue
+		}
+		for stage, h := range s.stages {
+			merged, ok := stageHists[stage]
+			if !ok {
+				merged = &histogram{}
+				stageHists[stage] = merged
+			}
+			merged.merge(h)
+		}
+	}
+	return total, good, stageHists
+}
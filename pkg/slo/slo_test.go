@@ -0,0 +1,274 @@
+package slo
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestTracker returns a tracker for "95% within 4s" on a clock the test
+// moves with the returned function.
+func newTestTracker() (*Tracker, func(time.Time)) {
+	tracker := NewTracker(4*time.Second, 0.95)
+	var mu sync.Mutex
+	now := time.Date(2026, time.October, 14, 12, 0, 30, 0, time.UTC)
+	tracker.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	return tracker, func(t time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = t
+	}
+}
+
+// record adds n instructions taking total each.
+func record(tracker *Tracker, n int, total time.Duration, failed bool) {
+	for i := 0; i < n; i++ {
+		tracker.Record(Latency{Total: total, Failed: failed})
+	}
+}
+
+func windowReport(t *testing.T, report Report, name string) WindowReport {
+	t.Helper()
+	for _, w := range report.Windows {
+		if w.Window == name {
+			return w
+		}
+	}
+	t.Fatalf("report has no %s window", name)
+	return WindowReport{}
+}
+
+func TestBurnRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		fast   int
+		onTime int
+		slow   int
+		failed int
+		sli    float64
+		burn   float64
+	}{
+		{name: "no traffic", sli: 1, burn: 0},
+		{name: "all within target", fast: 100, sli: 1, burn: 0},
+		{name: "exactly on target", onTime: 10, sli: 1, burn: 0},
+		{name: "exactly on budget", fast: 95, slow: 5, sli: 0.95, burn: 1},
+		{name: "twice the budget", fast: 90, slow: 10, sli: 0.9, burn: 2},
+		{name: "fast failures count", fast: 90, failed: 10, sli: 0.9, burn: 2},
+		{name: "everything slow", slow: 50, sli: 0, burn: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, _ := newTestTracker()
+			record(tracker, tt.fast, time.Second, false)
+			record(tracker, tt.onTime, 4*time.Second, false)
+			record(tracker, tt.slow, 6*time.Second, false)
+			record(tracker, tt.failed, 500*time.Millisecond, true)
+
+			w := windowReport(t, tracker.Report(), "5m")
+			if total := uint64(tt.fast + tt.onTime + tt.slow + tt.failed); w.Total != total {
+				t.Errorf("total = %d, want %d", w.Total, total)
+			}
+			if math.Abs(w.SLI-tt.sli) > 1e-9 {
+				t.Errorf("sli = %v, want %v", w.SLI, tt.sli)
+			}
+			if math.Abs(w.BurnRate-tt.burn) > 1e-9 {
+				t.Errorf("burn rate = %v, want %v", w.BurnRate, tt.burn)
+			}
+		})
+	}
+}
+
+func TestBurnRateDistribution(t *testing.T) {
+	// 1,000 instructions spread evenly from 0 to 5s: the 200 above 4s burn
+	// the 5% budget four times over.
+	tracker, _ := newTestTracker()
+	for i := 0; i < 1000; i++ {
+		tracker.Record(Latency{Total: time.Duration(i+1) * 5 * time.Millisecond})
+	}
+	w := windowReport(t, tracker.Report(), "5m")
+	if w.Good != 800 {
+		t.Errorf("good = %d, want 800", w.Good)
+	}
+	if math.Abs(w.BurnRate-4) > 1e-9 {
+		t.Errorf("burn rate = %v, want 4", w.BurnRate)
+	}
+	if w.P95Ms[StageTotal] != 5000 {
+		t.Errorf("total p95 = %v, want the 5000ms bucket", w.P95Ms[StageTotal])
+	}
+}
+
+func TestWindows(t *testing.T) {
+	tracker, setNow := newTestTracker()
+	now := tracker.now()
+
+	// One slow instruction at each age; the windows include those younger
+	// than their span.
+	for _, age := range []time.Duration{0, 10 * time.Minute, 45 * time.Minute, 3 * time.Hour, 7 * time.Hour} {
+		setNow(now.Add(-age))
+		record(tracker, 1, 10*time.Second, false)
+	}
+	setNow(now)
+
+	report := tracker.Report()
+	for _, tt := range []struct {
+		window string
+		total  uint64
+	}{
+		{window: "5m", total: 1},
+		{window: "30m", total: 2},
+		{window: "1h", total: 3},
+		{window: "6h", total: 4},
+	} {
+		if w := windowReport(t, report, tt.window); w.Total != tt.total {
+			t.Errorf("%s total = %d, want %d", tt.window, w.Total, tt.total)
+		}
+	}
+
+	// Once the clock moves on, old instructions leave the windows.
+	setNow(now.Add(6 * time.Minute))
+	if w := windowReport(t, tracker.Report(), "5m"); w.Total != 0 {
+		t.Errorf("5m total = %d six minutes later, want 0", w.Total)
+	}
+}
+
+func TestWindowsReuseSlots(t *testing.T) {
+	tracker, setNow := newTestTracker()
+	now := tracker.now()
+	record(tracker, 5, 10*time.Second, false)
+
+	// Exactly one ring later the slot is reset rather than added to.
+	setNow(now.Add(retention * time.Minute))
+	record(tracker, 1, time.Second, false)
+	w := windowReport(t, tracker.Report(), "6h")
+	if w.Total != 1 || w.Good != 1 {
+		t.Errorf("6h = %d total, %d good, want only the new instruction", w.Total, w.Good)
+	}
+}
+
+func TestBurnAlerts(t *testing.T) {
+	firing := func(report Report) map[string]bool {
+		got := make(map[string]bool)
+		for _, alert := range report.Alerts {
+			got[alert.Name] = alert.Firing
+		}
+		return got
+	}
+
+	t.Run("outage", func(t *testing.T) {
+		// Everything failing for the last hour burns at 20 on every window.
+		tracker, setNow := newTestTracker()
+		now := tracker.now()
+		for age := 59; age >= 0; age-- {
+			setNow(now.Add(-time.Duration(age) * time.Minute))
+			record(tracker, 2, time.Second, true)
+		}
+		got := firing(tracker.Report())
+		if !got["fast"] || !got["slow"] {
+			t.Errorf("firing = %v, want both alerts", got)
+		}
+	})
+
+	t.Run("recovered", func(t *testing.T) {
+		// An outage that ended ten minutes ago still burns the long windows,
+		// but the short ones are clean, so nothing fires.
+		tracker, setNow := newTestTracker()
+		now := tracker.now()
+		for age := 59; age >= 10; age-- {
+			setNow(now.Add(-time.Duration(age) * time.Minute))
+			record(tracker, 2, time.Second, true)
+		}
+		for age := 9; age >= 0; age-- {
+			setNow(now.Add(-time.Duration(age) * time.Minute))
+			record(tracker, 2, time.Second, false)
+		}
+		report := tracker.Report()
+		got := firing(report)
+		if got["fast"] {
+			t.Errorf("fast alert firing after recovery: %+v", report.Alerts)
+		}
+		for _, alert := range report.Alerts {
+			if alert.Name == "fast" && alert.LongBurnRate <= alert.Threshold {
+				t.Errorf("fast long burn rate = %v, want it still above %v", alert.LongBurnRate, alert.Threshold)
+			}
+		}
+	})
+
+	t.Run("slow burn", func(t *testing.T) {
+		// 40% slow for six hours burns at 8: enough for the slow alert but
+		// not the fast one.
+		tracker, setNow := newTestTracker()
+		now := tracker.now()
+		for age := 359; age >= 0; age-- {
+			setNow(now.Add(-time.Duration(age) * time.Minute))
+			record(tracker, 3, time.Second, false)
+			record(tracker, 2, 5*time.Second, false)
+		}
+		got := firing(tracker.Report())
+		if got["fast"] || !got["slow"] {
+			t.Errorf("firing = %v, want only the slow alert", got)
+		}
+	})
+}
+
+func TestStageQuantiles(t *testing.T) {
+	tracker, _ := newTestTracker()
+	for i := 0; i < 100; i++ {
+		llm := 200 * time.Millisecond
+		if i >= 95 {
+			llm = 30 * time.Second
+		}
+		if i == 99 {
+			// The slowest 1% don't move the p95.
+			llm = time.Minute
+		}
+		tracker.Record(Latency{
+			Stages: map[string]time.Duration{StageQueue: 40 * time.Millisecond, StageLLM: llm},
+			Total:  llm,
+		})
+	}
+	w := windowReport(t, tracker.Report(), "5m")
+	for stage, want := range map[string]float64{StageQueue: 50, StageLLM: 250, StageTotal: 250} {
+		if got := w.P95Ms[stage]; got != want {
+			t.Errorf("%s p95 = %v, want %v", stage, got, want)
+		}
+	}
+	if _, ok := w.P95Ms[StageBroadcast]; ok {
+		t.Errorf("broadcast p95 reported without any broadcasts")
+	}
+
+	var h histogram
+	h.observe(25000)
+	if got := h.quantile(0.95); got != -1 {
+		t.Errorf("p95 above the last bucket = %v, want -1", got)
+	}
+}
+
+func TestTrackerConcurrent(t *testing.T) {
+	tracker, _ := newTestTracker()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			record(tracker, 100, time.Second, false)
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				tracker.Report()
+			}
+		}()
+	}
+	wg.Wait()
+	if w := windowReport(t, tracker.Report(), "5m"); w.Total != 800 {
+		t.Errorf("total = %d, want 800", w.Total)
+	}
+
+	var nilTracker *Tracker
+	nilTracker.Record(Latency{Total: time.Second})
+}
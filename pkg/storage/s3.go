@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"draw/pkg/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// ErrNotConfigured is returned when no S3 bucket is configured.
+var ErrNotConfigured = errors.New("storage: no S3 bucket configured")
+
+// unsignedPayload lets a presigned URL carry any body; for uploads the
+// signed Content-Length and Content-Type still pin it down.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3 presigns requests against the configured bucket, so clients move object
+// data directly to and from S3 instead of through the server.
+type S3 struct {
+	signer      *v4.Signer
+	credentials aws.Credentials
+	region      string
+	bucket      string
+}
+
+// NewS3 returns nil when cfg has no bucket; its methods then return
+// ErrNotConfigured.
+func NewS3(cfg config.AWSConfig) *S3 {
+	if cfg.Bucket == "" {
+		return nil
+	}
+	return &S3{
+		signer: v4.NewSigner(),
+		credentials: aws.Credentials{
+			AccessKeyID:     cfg.AccessKey,
+			SecretAccessKey: cfg.SecretKey,
+		},
+		region: cfg.Region,
+		bucket: cfg.Bucket,
+	}
+}
+
+// PresignPut returns a URL that uploads exactly size bytes of contentType
+// to key, and the headers the upload must send with it.
+func (s *S3) PresignPut(ctx context.Context, key string, contentType string, size int64, expires time.Duration) (string, http.Header, error) {
+	req, err := s.request(ctx, http.MethodPut, key, expires)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = size
+	signedURL, _, err := s.signer.PresignHTTP(ctx, s.credentials, req, unsignedPayload, "s3", s.region, time.Now(), s3SignerOptions)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to presign upload: %w", err)
+	}
+	// Browsers set Host and Content-Length themselves.
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+	return signedURL, headers, nil
+}
+
+// PresignGet returns a URL that downloads key.
+func (s *S3) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	req, err := s.request(ctx, http.MethodGet, key, expires)
+	if err != nil {
+		return "", err
+	}
+	signedURL, _, err := s.signer.PresignHTTP(ctx, s.credentials, req, unsignedPayload, "s3", s.region, time.Now(), s3SignerOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign download: %w", err)
+	}
+	return signedURL, nil
+}
+
+// s3SignerOptions signs the path as it is sent; S3, unlike other services,
+// doesn't escape it a second time.
+func s3SignerOptions(options *v4.SignerOptions) {
+	options.DisableURIPathEscaping = true
+}
+
+func (s *S3) request(ctx context.Context, method string, key string, expires time.Duration) (*http.Request, error) {
+	if s == nil {
+		return nil, ErrNotConfigured
+	}
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, escapeKey(key))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build S3 request: %w", err)
+	}
+	query := req.URL.Query()
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	req.URL.RawQuery = query.Encode()
+	return req, nil
+}
+
+// escapeKey escapes each segment of key, keeping the slashes between them.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
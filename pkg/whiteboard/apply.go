@@ -0,0 +1,135 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"draw/pkg/llm"
+)
+
+// ApplyAction applies an add, update, delete, clear, replace or reorder
+// action to the board the way clients do: adds are appended, updates are
+// merged field by field into the element with the same ID, deletes mark
+// elements isDeleted, and clear marks every element isDeleted, as does
+// replace before appending its elements. An appended element takes the place
+// of a deleted one with its ID, as restoring it does. Frames an action adds
+// take in the board elements they list as children. A reorder moves its
+// targets as Reorder does. Elements that are added or moved are given
+// fractional indices, and when every element has one the board is kept
+// sorted by them, so undoing a delete or reorder restores the stacking
+// order. The board is not modified; a new slice is returned.
+func ApplyAction(board []llm.Element, action *llm.WhiteboardAction) ([]llm.Element, error) {
+	result := make([]llm.Element, len(board), len(board)+len(action.Elements))
+	copy(result, board)
+
+	switch action.Action {
+	case llm.ActionAdd:
+		result = appendElements(result, action.Elements)
+	case llm.ActionUpdate:
+		index := make(map[string]int, len(result))
+		for i, element := range result {
+			index[element.ID] = i
+		}
+		for _, update := range action.Elements {
+			i, ok := index[update.ID]
+			if !ok {
+				continue
+			}
+			merged, err := mergeElement(result[i], update)
+			if err != nil {
+				return nil, fmt.Errorf("failed to update element %s: %w", update.ID, err)
+			}
+			result[i] = merged
+		}
+	case llm.ActionDelete:
+		deleting := make(map[string]bool, len(action.DeleteIDs))
+		for _, id := range action.DeleteIDs {
+			deleting[id] = true
+		}
+		for i := range result {
+			if deleting[result[i].ID] {
+				setExtra(&result[i], "isDeleted", json.RawMessage("true"))
+			}
+		}
+	case llm.ActionClear, llm.ActionReplace:
+		for i := range result {
+			setExtra(&result[i], "isDeleted", json.RawMessage("true"))
+		}
+		if action.Action == llm.ActionReplace {
+			result = appendElements(result, action.Elements)
+		}
+	case llm.ActionReorder:
+		reordered, err := reorderAll(result, action.TargetIDs, action.Position)
+		if err != nil {
+			return nil, err
+		}
+		result = reordered
+	case llm.ActionError:
+	default:
+		return nil, fmt.Errorf("cannot apply %q action", action.Action)
+	}
+	if action.Action == llm.ActionAdd || action.Action == llm.ActionReplace {
+		adoptFrameChildren(result)
+		// Elements restored with their indices go back to their place.
+		synced, err := SyncIndices(OrderByIndex(result))
+		if err != nil {
+			return nil, err
+		}
+		result = synced
+	}
+	return result, nil
+}
+
+// appendElements appends elements to board, each in place of a deleted
+// element with its ID if there is one.
+func appendElements(board []llm.Element, elements []llm.Element) []llm.Element {
+	deleted := make(map[string]int)
+	for i, element := range board {
+		if element.ID != "" && isDeleted(element) {
+			deleted[element.ID] = i
+		}
+	}
+	for _, element := range elements {
+		if i, ok := deleted[element.ID]; ok {
+			board[i] = element
+			delete(deleted, element.ID)
+			continue
+		}
+		board = append(board, element)
+	}
+	return board
+}
+
+// mergeElement overlays the fields set in update onto element.
+func mergeElement(element llm.Element, update llm.Element) (llm.Element, error) {
+	var fields map[string]json.RawMessage
+	data, err := json.Marshal(element)
+	if err != nil {
+		return llm.Element{}, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return llm.Element{}, err
+	}
+
+	var updated map[string]json.RawMessage
+	data, err = json.Marshal(update)
+	if err != nil {
+		return llm.Element{}, err
+	}
+	if err := json.Unmarshal(data, &updated); err != nil {
+		return llm.Element{}, err
+	}
+	for key, value := range updated {
+		fields[key] = value
+	}
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return llm.Element{}, err
+	}
+	var merged llm.Element
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return llm.Element{}, err
+	}
+	return merged, nil
+}
@@ -0,0 +1,184 @@
+package whiteboard
+
+import (
+	"errors"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+func TestApplyClear(t *testing.T) {
+	empty, err := ApplyAction(nil, &llm.WhiteboardAction{Action: llm.ActionClear})
+	if err != nil {
+		t.Fatalf("ApplyAction on an empty board: %v", err)
+	}
+	if len(empty) != 0 {
+		t.Errorf("clearing an empty board left %d elements, want none", len(empty))
+	}
+	if _, err := Invert(&llm.WhiteboardAction{Action: llm.ActionClear}, nil); !errors.Is(err, ErrNotInvertible) {
+		t.Errorf("Invert of a clear on an empty board = %v, want ErrNotInvertible", err)
+	}
+	if reason := (DestructiveLimits{MaxDeletes: 1, MaxDeleteRatio: 0.5}).Check(&llm.WhiteboardAction{Action: llm.ActionClear}, nil); reason != "" {
+		t.Errorf("clearing an empty board was held: %s", reason)
+	}
+
+	board := parseElements(t, `[
+		{"id":"a","type":"rectangle","x":0,"y":0},
+		{"id":"b","type":"ellipse","x":100,"y":0,"isDeleted":true}
+	]`)
+	cleared, err := ApplyAction(board, &llm.WhiteboardAction{Action: llm.ActionClear})
+	if err != nil {
+		t.Fatalf("ApplyAction: %v", err)
+	}
+	if len(cleared) != 2 {
+		t.Fatalf("clear left %d elements, want both, deleted", len(cleared))
+	}
+	for _, element := range cleared {
+		if !isDeleted(element) {
+			t.Errorf("element %s survived the clear", element.ID)
+		}
+	}
+	if isDeleted(board[0]) {
+		t.Errorf("ApplyAction modified the board it was given")
+	}
+
+	undo, err := Invert(&llm.WhiteboardAction{Action: llm.ActionClear}, board)
+	if err != nil {
+		t.Fatalf("Invert: %v", err)
+	}
+	if undo.Action != llm.ActionReplace || len(undo.Elements) != 1 || undo.Elements[0].ID != "a" {
+		t.Errorf("undo = %+v, want a replace restoring a", undo)
+	}
+}
+
+func TestApplyReplace(t *testing.T) {
+	board := parseElements(t, `[
+		{"id":"a","type":"rectangle","x":0,"y":0,"index":"a0"},
+		{"id":"b","type":"ellipse","x":100,"y":0,"index":"a1"}
+	]`)
+	tests := []struct {
+		name     string
+		elements string
+		// live are the IDs left on the board, in order.
+		live []string
+	}{
+		{
+			name:     "IDs not on the board",
+			elements: `[{"id":"x","type":"rectangle","x":0,"y":0},{"id":"y","type":"arrow","x":0,"y":0,"start":{"id":"x"}}]`,
+			live:     []string{"x", "y"},
+		},
+		{
+			name:     "reusing an ID from the board",
+			elements: `[{"id":"b","type":"diamond","x":0,"y":0},{"id":"z","type":"text","x":0,"y":100,"text":"new"}]`,
+			live:     []string{"b", "z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := &llm.WhiteboardAction{Action: llm.ActionReplace, Elements: parseElements(t, tt.elements)}
+			result, err := ApplyAction(board, action)
+			if err != nil {
+				t.Fatalf("ApplyAction: %v", err)
+			}
+			var live []string
+			ids := make(map[string]int)
+			for _, element := range result {
+				ids[element.ID]++
+				if !isDeleted(element) {
+					live = append(live, element.ID)
+				}
+			}
+			if !equalStrings(live, tt.live) {
+				t.Errorf("live elements = %v, want %v", live, tt.live)
+			}
+			for id, count := range ids {
+				if count > 1 {
+					t.Errorf("element %s is on the board %d times", id, count)
+				}
+			}
+
+			undo, err := Invert(action, board)
+			if err != nil {
+				t.Fatalf("Invert: %v", err)
+			}
+			restored, err := ApplyAction(result, undo)
+			if err != nil {
+				t.Fatalf("ApplyAction of the undo: %v", err)
+			}
+			live = live[:0]
+			for _, element := range restored {
+				if !isDeleted(element) {
+					live = append(live, element.ID)
+				}
+			}
+			if !equalStrings(live, []string{"a", "b"}) {
+				t.Errorf("live elements after undo = %v, want [a b]", live)
+			}
+		})
+	}
+
+	// Replacing an empty board is undone by deleting what it added.
+	action := &llm.WhiteboardAction{Action: llm.ActionReplace, Elements: parseElements(t, `[{"id":"x","type":"rectangle","x":0,"y":0}]`)}
+	undo, err := Invert(action, nil)
+	if err != nil {
+		t.Fatalf("Invert: %v", err)
+	}
+	if undo.Action != llm.ActionDelete || !equalStrings(undo.DeleteIDs, []string{"x"}) {
+		t.Errorf("undo = %+v, want a delete of x", undo)
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	board := parseElements(t, `[
+		{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":60,"strokeColor":"#1e1e1e","seed":7,"customData":{"owner":"u1"}},
+		{"id":"b","type":"ellipse","x":200,"y":0,"width":60,"height":60}
+	]`)
+	tests := []struct {
+		name   string
+		action string
+		// want is element a after the update, as JSON. Updates carry the
+		// type and position, as the prompt asks the model to send them.
+		want string
+	}{
+		{
+			name:   "set fields replace the stored ones",
+			action: `{"action":"update","elements":[{"id":"a","type":"rectangle","x":40,"y":0,"backgroundColor":"#ffc9c9"}]}`,
+			want:   `{"id":"a","type":"rectangle","x":40,"y":0,"width":100,"height":60,"strokeColor":"#1e1e1e","backgroundColor":"#ffc9c9","customData":{"owner":"u1"},"seed":7}`,
+		},
+		{
+			name:   "fields only clients know are kept",
+			action: `{"action":"update","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"strokeColor":"#e03131"}]}`,
+			want:   `{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":60,"strokeColor":"#e03131","customData":{"owner":"u1"},"seed":7}`,
+		},
+		{
+			name:   "unknown element ignored",
+			action: `{"action":"update","elements":[{"id":"gone","type":"rectangle","x":40,"y":0}]}`,
+			want:   `{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":60,"strokeColor":"#1e1e1e","customData":{"owner":"u1"},"seed":7}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := ApplyAction(board, parseAction(t, tt.action))
+			if err != nil {
+				t.Fatalf("ApplyAction: %v", err)
+			}
+			if len(updated) != 2 || updated[1].X != 200 {
+				t.Fatalf("update touched other elements: %+v", updated)
+			}
+			got, err := CanonicalJSON(updated[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := CanonicalJSON(parseElements(t, "["+tt.want+"]")[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("a = %s, want %s", got, want)
+			}
+		})
+	}
+	if board[0].X != 0 || board[0].BackgroundColor != "" {
+		t.Errorf("ApplyAction modified the board it was given")
+	}
+}
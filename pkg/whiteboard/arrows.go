@@ -0,0 +1,230 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"draw/pkg/llm"
+)
+
+// How arrows bound to deleted elements are repaired.
+const (
+	// ArrowRepairUnbind deletes arrows that lose every bound endpoint and
+	// leaves arrows that keep one, unbound at their current coordinates at the
+	// lost end.
+	ArrowRepairUnbind = "unbind"
+	// ArrowRepairCascade deletes every arrow bound to a deleted element.
+	ArrowRepairCascade = "cascade"
+)
+
+// ValidArrowRepair reports whether policy is a known repair policy.
+func ValidArrowRepair(policy string) bool {
+	return policy == ArrowRepairUnbind || policy == ArrowRepairCascade
+}
+
+// ArrowRepair lists what a repair changed. Deleted includes the label text of
+// deleted arrows.
+type ArrowRepair struct {
+	Deleted []string
+	Unbound []string
+}
+
+func (r ArrowRepair) Empty() bool {
+	return len(r.Deleted) == 0 && len(r.Unbound) == 0
+}
+
+// Warning describes the repair for the instruction result.
+func (r ArrowRepair) Warning() string {
+	var parts []string
+	if len(r.Deleted) > 0 {
+		parts = append(parts, fmt.Sprintf("deleted %d connected element(s)", len(r.Deleted)))
+	}
+	if len(r.Unbound) > 0 {
+		parts = append(parts, fmt.Sprintf("unbound %d arrow(s)", len(r.Unbound)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Arrows pointed at deleted elements: " + strings.Join(parts, " and ") + "."
+}
+
+// RepairArrows fixes arrows whose bindings point at elements that are no
+// longer on the board, per policy. Text bound to a deleted arrow is deleted
+// with it, and references to deleted arrows are dropped from the
+// boundElements of the elements that remain.
+func RepairArrows(elements []llm.Element, policy string) ([]llm.Element, ArrowRepair) {
+	present := make(map[string]bool, len(elements))
+	for _, element := range elements {
+		if element.ID != "" && !isDeleted(element) {
+			present[element.ID] = true
+		}
+	}
+
+	var repair ArrowRepair
+	deleted := make(map[string]bool)
+	for i := range elements {
+		element := &elements[i]
+		if isDeleted(*element) {
+			continue
+		}
+		start, end := arrowEnds(*element)
+		startLost := start != "" && !present[start]
+		endLost := end != "" && !present[end]
+		if !startLost && !endLost {
+			continue
+		}
+
+		keepsEnd := (start != "" && !startLost) || (end != "" && !endLost)
+		if policy == ArrowRepairCascade || !keepsEnd {
+			deleted[element.ID] = true
+			repair.Deleted = append(repair.Deleted, element.ID)
+			continue
+		}
+		if startLost {
+			unbindEnd(element, "startBinding")
+			element.Start = nil
+		}
+		if endLost {
+			unbindEnd(element, "endBinding")
+			element.End = nil
+		}
+		repair.Unbound = append(repair.Unbound, element.ID)
+	}
+	if len(deleted) == 0 {
+		return elements, repair
+	}
+
+	// Labels follow their arrow.
+	for _, element := range elements {
+		if containerID := extraString(element.Extra, "containerId"); deleted[containerID] && !deleted[element.ID] {
+			deleted[element.ID] = true
+			repair.Deleted = append(repair.Deleted, element.ID)
+		}
+	}
+
+	kept := make([]llm.Element, 0, len(elements)-len(deleted))
+	for _, element := range elements {
+		if deleted[element.ID] {
+			continue
+		}
+		dropBoundElements(&element, deleted)
+		kept = append(kept, element)
+	}
+	return kept, repair
+}
+
+// RepairDelete extends a delete action so it also removes the arrows the
+// policy deletes and the bound text of every deleted element. Arrows the
+// policy would only unbind are reported but left for the board save, since
+// a delete action can't carry updates.
+func RepairDelete(action *llm.WhiteboardAction, board []llm.Element, policy string) ArrowRepair {
+	if action.Action != llm.ActionDelete {
+		return ArrowRepair{}
+	}
+
+	deleting := make(map[string]bool, len(action.DeleteIDs))
+	for _, id := range action.DeleteIDs {
+		deleting[id] = true
+	}
+	remaining := make([]llm.Element, 0, len(board))
+	for _, element := range board {
+		if !deleting[element.ID] {
+			remaining = append(remaining, element)
+		}
+	}
+
+	_, repair := RepairArrows(remaining, policy)
+	for _, element := range remaining {
+		if deleting[extraString(element.Extra, "containerId")] {
+			repair.Deleted = append(repair.Deleted, element.ID)
+		}
+	}
+	for _, id := range repair.Deleted {
+		if !deleting[id] {
+			deleting[id] = true
+			action.DeleteIDs = append(action.DeleteIDs, id)
+		}
+	}
+	return repair
+}
+
+// arrowEnds returns the IDs an element is bound to, from either the prompt's
+// start/end or Excalidraw's startBinding/endBinding.
+func arrowEnds(element llm.Element) (start string, end string) {
+	if element.Start != nil {
+		start = element.Start.ID
+	} else {
+		start = bindingID(element.Extra, "startBinding")
+	}
+	if element.End != nil {
+		end = element.End.ID
+	} else {
+		end = bindingID(element.Extra, "endBinding")
+	}
+	return start, end
+}
+
+func bindingID(extra map[string]json.RawMessage, key string) string {
+	var binding struct {
+		ElementID string `json:"elementId"`
+	}
+	if raw, ok := extra[key]; ok && json.Unmarshal(raw, &binding) == nil {
+		return binding.ElementID
+	}
+	return ""
+}
+
+// unbindEnd clears a binding, leaving the arrow's points where they are.
+func unbindEnd(element *llm.Element, key string) {
+	if _, ok := element.Extra[key]; ok {
+		setExtra(element, key, json.RawMessage("null"))
+	}
+}
+
+func dropBoundElements(element *llm.Element, deleted map[string]bool) {
+	var bound []map[string]json.RawMessage
+	raw, ok := element.Extra["boundElements"]
+	if !ok || json.Unmarshal(raw, &bound) != nil || bound == nil {
+		return
+	}
+	kept := bound[:0]
+	for _, ref := range bound {
+		var id string
+		if json.Unmarshal(ref["id"], &id) == nil && deleted[id] {
+			continue
+		}
+		kept = append(kept, ref)
+	}
+	if len(kept) != len(bound) {
+		data, _ := json.Marshal(kept)
+		setExtra(element, "boundElements", data)
+	}
+}
+
+// setExtra copies Extra before writing to it, since element copies share the
+// map with the board they came from.
+func setExtra(element *llm.Element, key string, value json.RawMessage) {
+	extra := make(map[string]json.RawMessage, len(element.Extra)+1)
+	for k, v := range element.Extra {
+		extra[k] = v
+	}
+	extra[key] = value
+	element.Extra = extra
+}
+
+func isDeleted(element llm.Element) bool {
+	var deleted bool
+	if raw, ok := element.Extra["isDeleted"]; ok && json.Unmarshal(raw, &deleted) == nil {
+		return deleted
+	}
+	return false
+}
+
+func extraString(extra map[string]json.RawMessage, key string) string {
+	var s string
+	if raw, ok := extra[key]; ok && json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return ""
+}
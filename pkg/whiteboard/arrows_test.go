@@ -0,0 +1,207 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+// arrowBoard holds three shapes and two arrows: a-b, bound the prompt's way
+// and labelled, and a-c, bound the way Excalidraw saves it.
+const arrowBoard = `[
+	{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":60,
+		"boundElements":[{"id":"ab","type":"arrow"},{"id":"ac","type":"arrow"}]},
+	{"id":"b","type":"rectangle","x":300,"y":0,"width":100,"height":60,
+		"boundElements":[{"id":"ab","type":"arrow"},{"id":"b-label","type":"text"}]},
+	{"id":"b-label","type":"text","x":320,"y":20,"text":"B","containerId":"b"},
+	{"id":"c","type":"ellipse","x":0,"y":300,"width":100,"height":60,
+		"boundElements":[{"id":"ac","type":"arrow"}]},
+	{"id":"ab","type":"arrow","x":100,"y":30,"points":[[0,0],[200,0]],"start":{"id":"a"},"end":{"id":"b"},
+		"boundElements":[{"id":"ab-label","type":"text"}]},
+	{"id":"ab-label","type":"text","x":180,"y":20,"text":"next","containerId":"ab"},
+	{"id":"ac","type":"arrow","x":50,"y":60,"points":[[0,0],[0,240]],
+		"startBinding":{"elementId":"a","focus":0,"gap":4},"endBinding":{"elementId":"c","focus":0,"gap":4}}
+]`
+
+// without returns the board's elements except ids.
+func without(t *testing.T, ids ...string) []llm.Element {
+	t.Helper()
+	drop := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	var elements []llm.Element
+	for _, element := range parseElements(t, arrowBoard) {
+		if !drop[element.ID] {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+func sortedIDs(ids []string) string {
+	ids = append([]string(nil), ids...)
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+// describeArrows summarizes the arrows left on the board as id:start>end.
+func describeArrows(elements []llm.Element) string {
+	var arrows []string
+	for _, element := range elements {
+		if element.Type != "arrow" {
+			continue
+		}
+		start, end := arrowEnds(element)
+		arrows = append(arrows, fmt.Sprintf("%s:%s>%s", element.ID, start, end))
+	}
+	return strings.Join(arrows, " ")
+}
+
+func TestRepairArrows(t *testing.T) {
+	tests := []struct {
+		name    string
+		deleted []string
+		policy  string
+		want    string
+		removed string
+		unbound string
+	}{
+		{name: "nothing lost", policy: ArrowRepairUnbind, want: "ab:a>b ac:a>c"},
+		{name: "unbind one end", deleted: []string{"b"}, policy: ArrowRepairUnbind, want: "ab:a> ac:a>c", unbound: "ab"},
+		{name: "unbind Excalidraw bindings", deleted: []string{"a"}, policy: ArrowRepairUnbind, want: "ab:>b ac:>c", unbound: "ab,ac"},
+		{name: "unbind deletes an arrow left with no ends", deleted: []string{"a", "b"}, policy: ArrowRepairUnbind, want: "ac:>c", removed: "ab,ab-label", unbound: "ac"},
+		{name: "cascade", deleted: []string{"b"}, policy: ArrowRepairCascade, want: "ac:a>c", removed: "ab,ab-label"},
+		{name: "cascade every arrow of a shape", deleted: []string{"a"}, policy: ArrowRepairCascade, want: "", removed: "ab,ab-label,ac"},
+		{name: "unlabelled arrow", deleted: []string{"c"}, policy: ArrowRepairCascade, want: "ab:a>b", removed: "ac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repaired, repair := RepairArrows(without(t, tt.deleted...), tt.policy)
+			if got := describeArrows(repaired); got != tt.want {
+				t.Errorf("arrows = %q, want %q", got, tt.want)
+			}
+			if got := sortedIDs(repair.Deleted); got != tt.removed {
+				t.Errorf("deleted %q, want %q", got, tt.removed)
+			}
+			if got := sortedIDs(repair.Unbound); got != tt.unbound {
+				t.Errorf("unbound %q, want %q", got, tt.unbound)
+			}
+
+			// Nothing left refers to an element the repair deleted.
+			removed := make(map[string]bool)
+			for _, id := range repair.Deleted {
+				removed[id] = true
+			}
+			for _, element := range repaired {
+				if removed[element.ID] {
+					t.Errorf("deleted element %s is still on the board", element.ID)
+				}
+				if container := extraString(element.Extra, "containerId"); removed[container] {
+					t.Errorf("%s is still bound to deleted %s", element.ID, container)
+				}
+				for _, id := range boundIDs(element) {
+					if removed[id] {
+						t.Errorf("%s still lists deleted %s in boundElements", element.ID, id)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRepairArrowsSkipsDeletedElements(t *testing.T) {
+	elements := parseElements(t, arrowBoard)
+	for i := range elements {
+		if elements[i].ID == "b" {
+			setExtra(&elements[i], "isDeleted", []byte("true"))
+		}
+	}
+	repaired, repair := RepairArrows(elements, ArrowRepairCascade)
+	if got := sortedIDs(repair.Deleted); got != "ab,ab-label" {
+		t.Errorf("deleted %q, want the arrow to the soft-deleted shape and its label", got)
+	}
+	if got := describeArrows(repaired); got != "ac:a>c" {
+		t.Errorf("arrows = %q, want ac:a>c", got)
+	}
+}
+
+func TestRepairDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		policy  string
+		want    string
+		unbound string
+		warning string
+	}{
+		{
+			name:    "unbind leaves the arrow for the save",
+			action:  `{"action":"delete","delete_ids":["b"]}`,
+			policy:  ArrowRepairUnbind,
+			want:    "b,b-label",
+			unbound: "ab",
+			warning: "Arrows pointed at deleted elements: deleted 1 connected element(s) and unbound 1 arrow(s).",
+		},
+		{
+			name:    "cascade deletes the arrow and its label",
+			action:  `{"action":"delete","delete_ids":["b"]}`,
+			policy:  ArrowRepairCascade,
+			want:    "ab,ab-label,b,b-label",
+			warning: "Arrows pointed at deleted elements: deleted 3 connected element(s).",
+		},
+		{
+			name:    "deleting an arrow takes its label",
+			action:  `{"action":"delete","delete_ids":["ab"]}`,
+			policy:  ArrowRepairUnbind,
+			want:    "ab,ab-label",
+			warning: "Arrows pointed at deleted elements: deleted 1 connected element(s).",
+		},
+		{
+			name:    "arrow already being deleted",
+			action:  `{"action":"delete","delete_ids":["b","ab"]}`,
+			policy:  ArrowRepairCascade,
+			want:    "ab,ab-label,b,b-label",
+			warning: "Arrows pointed at deleted elements: deleted 2 connected element(s).",
+		},
+		{
+			name:   "not a delete",
+			action: `{"action":"clear"}`,
+			policy: ArrowRepairCascade,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := parseAction(t, tt.action)
+			repair := RepairDelete(action, parseElements(t, arrowBoard), tt.policy)
+			if got := sortedIDs(action.DeleteIDs); got != tt.want {
+				t.Errorf("deleting %q, want %q", got, tt.want)
+			}
+			if got := sortedIDs(repair.Unbound); got != tt.unbound {
+				t.Errorf("unbound %q, want %q", got, tt.unbound)
+			}
+			if got := repair.Warning(); got != tt.warning {
+				t.Errorf("warning = %q, want %q", got, tt.warning)
+			}
+			if repair.Empty() != (tt.warning == "") {
+				t.Errorf("Empty() = %v with warning %q", repair.Empty(), tt.warning)
+			}
+		})
+	}
+}
+
+func boundIDs(element llm.Element) []string {
+	var bound []struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(element.Extra["boundElements"], &bound)
+	ids := make([]string, len(bound))
+	for i, ref := range bound {
+		ids[i] = ref.ID
+	}
+	return ids
+}
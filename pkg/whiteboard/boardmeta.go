@@ -0,0 +1,190 @@
+package whiteboard
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BoardPalette is the set of colors a board card may use, keyed by name.
+var BoardPalette = map[string]string{
+	"red":    "#ffc9c9",
+	"orange": "#ffd8a8",
+	"yellow": "#ffec99",
+	"green":  "#b2f2bb",
+	"teal":   "#96f2d7",
+	"cyan":   "#99e9f2",
+	"blue":   "#a5d8ff",
+	"purple": "#d0bfff",
+	"pink":   "#fcc2d7",
+	"gray":   "#e9ecef",
+}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ValidateBoardColor checks a board color. Only palette colors are accepted
+// unless allowCustom is set, in which case any #rrggbb hex is.
+func ValidateBoardColor(color string, allowCustom bool) error {
+	color = strings.ToLower(color)
+	for _, hex := range BoardPalette {
+		if color == hex {
+			return nil
+		}
+	}
+	if allowCustom && hexColorPattern.MatchString(color) {
+		return nil
+	}
+	if allowCustom {
+		return fmt.Errorf("color %q is not a #rrggbb hex color", color)
+	}
+	return fmt.Errorf("color %q is not in the board palette", color)
+}
+
+// MaxBoardNameLength is the most characters a board name may have.
+const MaxBoardNameLength = 120
+
+// ValidateBoardName checks that name is 1 to MaxBoardNameLength characters
+// long. Callers trim it first.
+func ValidateBoardName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if n := utf8.RuneCountInString(name); n > MaxBoardNameLength {
+		return fmt.Errorf("name is %d characters long; the limit is %d", n, MaxBoardNameLength)
+	}
+	return nil
+}
+
+// ValidateBoardIcon checks that icon is exactly one emoji.
+func ValidateBoardIcon(icon string) error {
+	if !IsSingleEmoji(icon) {
+		return fmt.Errorf("icon %q must be a single emoji", icon)
+	}
+	return nil
+}
+
+const (
+	zeroWidthJoiner  = '\u200d'
+	variationSelect  = '\ufe0f'
+	combiningKeycap  = '\u20e3'
+	skinToneFirst    = 0x1f3fb
+	skinToneLast     = 0x1f3ff
+	tagFirst         = 0xe0020
+	tagLast          = 0xe007f
+	regionalIndFirst = 0x1f1e6
+	regionalIndLast  = 0x1f1ff
+)
+
+// IsSingleEmoji reports whether s is one emoji grapheme: a pictograph with
+// optional modifiers, a ZWJ sequence, a keycap, or a flag. Plain text and
+// several emoji in a row are rejected.
+func IsSingleEmoji(s string) bool {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return false
+	}
+
+	if isRegionalIndicator(runes[0]) {
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	}
+
+	if last := runes[len(runes)-1]; last == combiningKeycap {
+		keycap := runes[:len(runes)-1]
+		if len(keycap) == 2 && keycap[1] == variationSelect {
+			keycap = keycap[:1]
+		}
+		return len(keycap) == 1 && strings.ContainsRune("0123456789#*", keycap[0])
+	}
+
+	expectBase := true
+	for _, r := range runes {
+		switch {
+		case expectBase:
+			if r < 0x80 || !unicode.Is(unicode.So, r) {
+				return false
+			}
+			expectBase = false
+		case r == zeroWidthJoiner:
+			expectBase = true
+		case r == variationSelect,
+			r >= skinToneFirst && r <= skinToneLast,
+			r >= tagFirst && r <= tagLast:
+		default:
+			return false
+		}
+	}
+	return !expectBase
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalIndFirst && r <= regionalIndLast
+}
+
+// iconNames maps spoken icon names to emoji.
+var iconNames = map[string]string{
+	"rocket":      "🚀",
+	"star":        "⭐",
+	"fire":        "🔥",
+	"heart":       "❤️",
+	"light bulb":  "💡",
+	"lightbulb":   "💡",
+	"idea":        "💡",
+	"check":       "✅",
+	"checkmark":   "✅",
+	"bug":         "🐛",
+	"book":        "📚",
+	"pencil":      "✏️",
+	"chart":       "📈",
+	"calendar":    "📅",
+	"target":      "🎯",
+	"sparkles":    "✨",
+	"brain":       "🧠",
+	"house":       "🏠",
+	"globe":       "🌍",
+	"gear":        "⚙️",
+	"lock":        "🔒",
+	"money":       "💰",
+	"smiley":      "😀",
+	"smiley face": "😀",
+}
+
+// BoardMetadataIntent is a request to change a board's icon or color.
+type BoardMetadataIntent struct {
+	Icon  *string `json:"icon,omitempty"`
+	Color *string `json:"color,omitempty"`
+}
+
+var (
+	iconIntentPattern  = regexp.MustCompile(`(?i)^(?:please\s+)?(?:set|change|make)\s+(?:this|the)\s+board(?:'s)?\s+(?:icon|emoji)\s+(?:to\s+|into\s+)?(?:an?\s+)?(.+?)[.!]?$`)
+	colorIntentPattern = regexp.MustCompile(`(?i)^(?:please\s+)?(?:set|change|make)\s+(?:this|the)\s+board(?:'s)?\s+colou?r\s+(?:to\s+)?(.+?)[.!]?$`)
+)
+
+// ParseBoardMetadataIntent recognises "set this board's icon to a rocket" and
+// "make the board color blue" so they can be handled without the whiteboard
+// prompt. The returned values are not validated.
+func ParseBoardMetadataIntent(instruction string) (*BoardMetadataIntent, bool) {
+	instruction = strings.TrimSpace(instruction)
+
+	if m := iconIntentPattern.FindStringSubmatch(instruction); m != nil {
+		value := strings.TrimSpace(m[1])
+		if emoji, ok := iconNames[strings.ToLower(value)]; ok {
+			value = emoji
+		}
+		return &BoardMetadataIntent{Icon: &value}, true
+	}
+
+	if m := colorIntentPattern.FindStringSubmatch(instruction); m != nil {
+		value := strings.ToLower(strings.TrimSpace(m[1]))
+		if value == "grey" {
+			value = "gray"
+		}
+		if hex, ok := BoardPalette[value]; ok {
+			value = hex
+		}
+		return &BoardMetadataIntent{Color: &value}, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,182 @@
+package whiteboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"draw/pkg/llm"
+)
+
+// unorderedArrayKeys are element fields holding arrays whose order carries no
+// meaning, sorted by canonical encoding so producers that append in a
+// different order serialize the same. Arrays not listed here (points,
+// groupIds, the elements themselves) keep their order.
+var unorderedArrayKeys = map[string]bool{
+	"boundElements": true,
+}
+
+// CanonicalJSON encodes v so that equal content always produces the same
+// bytes: object keys are sorted, numbers use one format (100.0 and 1e2 are
+// both written 100), and unordered arrays are sorted. Use it wherever bytes
+// are compared or hashed.
+func CanonicalJSON(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize(data)
+}
+
+// Canonicalize rewrites JSON produced elsewhere (clients, the model) into its
+// canonical form.
+func Canonicalize(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// HashElements returns a hex SHA-256 of the elements' canonical encoding.
+func HashElements(elements []llm.Element) (string, error) {
+	data, err := CanonicalJSON(elements)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		return writeCanonicalNumber(buf, v)
+	case string:
+		return writeCanonicalString(buf, v)
+	case []any:
+		return writeCanonicalArray(buf, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalString(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+
+			element := v[key]
+			if items, ok := element.([]any); ok && unorderedArrayKeys[key] {
+				if err := writeSortedArray(buf, items); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := writeCanonical(buf, element); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unexpected JSON value %T", value)
+	}
+	return nil
+}
+
+// writeCanonicalString writes s as a JSON string without HTML escaping, so
+// "<" stays readable in fixtures.
+func writeCanonicalString(buf *bytes.Buffer, s string) error {
+	var strBuf bytes.Buffer
+	enc := json.NewEncoder(&strBuf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(strBuf.Bytes(), []byte("\n")))
+	return nil
+}
+
+func writeCanonicalArray(buf *bytes.Buffer, items []any) error {
+	buf.WriteByte('[')
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeCanonical(buf, item); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func writeSortedArray(buf *bytes.Buffer, items []any) error {
+	encoded := make([][]byte, len(items))
+	for i, item := range items {
+		var itemBuf bytes.Buffer
+		if err := writeCanonical(&itemBuf, item); err != nil {
+			return err
+		}
+		encoded[i] = itemBuf.Bytes()
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	buf.WriteByte('[')
+	for i, item := range encoded {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(item)
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// writeCanonicalNumber writes integral values without a fraction or exponent
+// and everything else in the shortest form that round-trips, switching to
+// exponent notation only for very large or small magnitudes.
+func writeCanonicalNumber(buf *bytes.Buffer, n json.Number) error {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q: %w", n, err)
+	}
+	if f == 0 {
+		// Also folds -0 into 0.
+		buf.WriteByte('0')
+		return nil
+	}
+
+	abs := math.Abs(f)
+	if abs < 1e21 && abs >= 1e-6 {
+		buf.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+	} else {
+		buf.WriteString(strconv.FormatFloat(f, 'e', -1, 64))
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "keys sorted", in: `{"y":1,"x":2,"a":{"d":1,"c":2}}`, want: `{"a":{"c":2,"d":1},"x":2,"y":1}`},
+		{name: "integral fraction", in: `{"width":100.0}`, want: `{"width":100}`},
+		{name: "integral exponent", in: `{"width":1e2}`, want: `{"width":100}`},
+		{name: "negative zero", in: `{"x":-0.0}`, want: `{"x":0}`},
+		{name: "fraction", in: `{"x":0.50}`, want: `{"x":0.5}`},
+		{name: "tiny", in: `{"x":0.0000001}`, want: `{"x":1e-07}`},
+		{name: "huge", in: `{"x":1e21}`, want: `{"x":1e+21}`},
+		{name: "whitespace", in: " {\n\t\"a\" : [ 1 , 2 ] }\n", want: `{"a":[1,2]}`},
+		{name: "bound elements sorted", in: `{"boundElements":[{"type":"text","id":"b"},{"id":"a","type":"arrow"}]}`, want: `{"boundElements":[{"id":"a","type":"arrow"},{"id":"b","type":"text"}]}`},
+		{name: "points keep their order", in: `{"points":[[10,0],[0,0]]}`, want: `{"points":[[10,0],[0,0]]}`},
+		{name: "elements keep their order", in: `[{"id":"b"},{"id":"a"}]`, want: `[{"id":"b"},{"id":"a"}]`},
+		{name: "html not escaped", in: `{"text":"a<b & é"}`, want: `{"text":"a<b & é"}`},
+		{name: "literals", in: `[null,true,false,""]`, want: `[null,true,false,""]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonicalize([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Canonicalize: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Canonicalize([]byte(`{"x":`)); err == nil {
+		t.Errorf("got no error for truncated JSON")
+	}
+}
+
+// TestHashElementsAcrossPaths builds the same element the ways the server
+// receives them: typed by the server, sent by a client that orders keys its
+// own way and writes 100.0, and parsed out of a model response.
+func TestHashElementsAcrossPaths(t *testing.T) {
+	built := llm.Element{
+		ID: "a", Type: "rectangle", X: 10, Y: 20, Width: 100, Height: 50,
+		StrokeColor: "#1e1e1e",
+		Extra: map[string]json.RawMessage{
+			"boundElements": json.RawMessage(`[{"id":"arrow","type":"arrow"},{"id":"label","type":"text"}]`),
+		},
+	}
+
+	var fromClient []llm.Element
+	client := `[{"strokeColor":"#1e1e1e","height":50.0,"boundElements":[{"type":"text","id":"label"},{"type":"arrow","id":"arrow"}],"width":1e2,"y":20,"x":10.0,"type":"rectangle","id":"a"}]`
+	if err := json.Unmarshal([]byte(client), &fromClient); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := llm.ParseWhiteboardAction(`{"action":"add","elements":[{"type":"rectangle","id":"a","x":10,"y":20,"width":100,"height":50,"strokeColor":"#1e1e1e","boundElements":[{"id":"label","type":"text"},{"id":"arrow","type":"arrow"}]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := HashElements([]llm.Element{built})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := map[string][]llm.Element{
+		"client": fromClient,
+		"model":  parsed.Elements,
+	}
+	for name, elements := range paths {
+		if got, err := HashElements(elements); err != nil || got != want {
+			t.Errorf("%s hash = %s (%v), want %s", name, got, err, want)
+		}
+	}
+
+	moved := built
+	moved.X = 11
+	if got, _ := HashElements([]llm.Element{moved}); got == want {
+		t.Errorf("moving the element kept its hash")
+	}
+	second := llm.Element{ID: "b", Type: "ellipse"}
+	forward, _ := HashElements([]llm.Element{built, second})
+	backward, _ := HashElements([]llm.Element{second, built})
+	if forward == backward {
+		t.Errorf("reordering elements kept the hash, want z-order to count")
+	}
+}
+
+// FuzzCanonicalize checks that the canonical form is a fixed point: encoding
+// it again, directly or after decoding it, gives the same bytes, and it
+// holds the same values as the input.
+func FuzzCanonicalize(f *testing.F) {
+	for _, seed := range []string{
+		`[{"id":"a","type":"rectangle","x":0,"y":0,"width":100.0,"height":5e1,"boundElements":[{"id":"z","type":"text"},{"id":"b","type":"arrow"}]}]`,
+		`{"points":[[0,0],[-0.0,1.5],[1e-9,1e300]],"text":"<ü 🚀>","customData":{"b":null,"a":[true,false]}}`,
+		`{"boundElements":[[2],[1],{"x":1},"s",3,null]}`,
+		`0.1`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		canonical, err := Canonicalize([]byte(raw))
+		if err != nil {
+			return
+		}
+		again, err := Canonicalize(canonical)
+		if err != nil {
+			t.Fatalf("canonical form %s doesn't canonicalize: %v", canonical, err)
+		}
+		if string(again) != string(canonical) {
+			t.Fatalf("canonicalizing %s again gave %s", canonical, again)
+		}
+
+		var value any
+		if err := json.Unmarshal(canonical, &value); err != nil {
+			t.Fatalf("canonical form %s doesn't decode: %v", canonical, err)
+		}
+		encoded, err := CanonicalJSON(value)
+		if err != nil || string(encoded) != string(canonical) {
+			t.Fatalf("round trip of %s gave %s (%v)", canonical, encoded, err)
+		}
+
+		var original any
+		if json.Unmarshal([]byte(raw), &original) == nil && !reflect.DeepEqual(sortUnordered(original, ""), value) {
+			t.Fatalf("canonical form %s holds different values than %s", canonical, raw)
+		}
+	})
+}
+
+// sortUnordered returns value with its unordered arrays in canonical order,
+// so it can be compared with a decoded canonical form. Negative zero
+// becomes zero, as in the canonical form.
+func sortUnordered(value any, key string) any {
+	switch v := value.(type) {
+	case float64:
+		if v == 0 {
+			return float64(0)
+		}
+	case []any:
+		for i := range v {
+			v[i] = sortUnordered(v[i], "")
+		}
+		if unorderedArrayKeys[key] {
+			data, _ := CanonicalJSON(map[string]any{key: v})
+			var sorted map[string]any
+			json.Unmarshal(data, &sorted)
+			return sorted[key]
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = sortUnordered(v[k], k)
+		}
+	}
+	return value
+}
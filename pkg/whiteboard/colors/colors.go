@@ -0,0 +1,232 @@
+// Package colors is the whiteboard palette: the colors the prompt offers the
+// model, the names speech may use for them, and the mapping of stray hexes
+// back onto them. It imports nothing else of ours, so the prompts can be
+// built from it.
+package colors
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Stroke and fill used when no color is asked for.
+const (
+	DefaultStroke = "#1e1e1e"
+	Transparent   = "transparent"
+)
+
+// Hue is a palette color as a light shade for fills and a dark one for
+// strokes, Excalidraw's second and last shade of it.
+type Hue struct {
+	Name  string
+	Light string
+	Dark  string
+	// Aliases are other names speech uses for the hue.
+	Aliases []string
+}
+
+// Palette lists the hues in the order the prompt shows them.
+var Palette = []Hue{
+	{Name: "red", Light: "#ffc9c9", Dark: "#e03131", Aliases: []string{"crimson", "scarlet", "maroon", "ruby"}},
+	{Name: "pink", Light: "#fcc2d7", Dark: "#c2255c", Aliases: []string{"rose", "magenta", "fuchsia"}},
+	{Name: "grape", Light: "#eebefa", Dark: "#9c36b5", Aliases: []string{"plum", "mauve"}},
+	{Name: "violet", Light: "#d0bfff", Dark: "#6741d9", Aliases: []string{"purple", "lavender", "lilac", "indigo"}},
+	{Name: "blue", Light: "#a5d8ff", Dark: "#1971c2", Aliases: []string{"navy", "azure", "cobalt", "sky"}},
+	{Name: "cyan", Light: "#99e9f2", Dark: "#0c8599", Aliases: []string{"aqua", "turquoise"}},
+	{Name: "teal", Light: "#96f2d7", Dark: "#099268", Aliases: []string{"mint", "seafoam"}},
+	{Name: "green", Light: "#b2f2bb", Dark: "#2f9e44", Aliases: []string{"lime", "emerald", "olive", "forest"}},
+	{Name: "yellow", Light: "#ffec99", Dark: "#f08c00", Aliases: []string{"gold", "golden", "amber", "mustard"}},
+	{Name: "orange", Light: "#ffd8a8", Dark: "#e8590c", Aliases: []string{"peach", "tangerine", "coral"}},
+	{Name: "bronze", Light: "#eaddd7", Dark: "#846358", Aliases: []string{"brown", "tan", "beige", "copper"}},
+	{Name: "gray", Light: "#e9ecef", Dark: "#343a40", Aliases: []string{"grey", "silver", "charcoal", "slate"}},
+}
+
+// neutrals are named colors outside the hues; they have no light and dark
+// pair, so both fill and stroke are the one color, except white, which
+// keeps the default stroke to stay visible.
+var neutrals = map[string]struct{ bg, stroke string }{
+	"black": {DefaultStroke, DefaultStroke},
+	"white": {"#ffffff", DefaultStroke},
+}
+
+// A dark modifier picks a hue's dark shade for the fill as well; dark
+// aliases such as "navy" say it on their own. Light modifiers only restate
+// the default.
+var (
+	darkModifiers  = []string{"dark", "deep"}
+	lightModifiers = []string{"light", "pale", "pastel", "bright", "soft"}
+	darkAliases    = map[string]bool{"navy": true, "maroon": true, "charcoal": true, "forest": true, "olive": true, "indigo": true}
+)
+
+// minFuzzyLength is the shortest name matched despite a typo.
+const minFuzzyLength = 5
+
+// fillerWords are dropped from a spoken color before it is looked up.
+var fillerWords = map[string]bool{"the": true, "a": true, "color": true, "colour": true, "shade": true, "of": true}
+
+// ResolveColor maps a spoken color name, such as "blue", "dark blue",
+// "navy" or "light purple", to a palette fill and stroke. Names a letter
+// off from a palette name, such as "purpel", still match. ok is false when
+// nothing matches.
+func ResolveColor(name string) (bg, stroke string, ok bool) {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	})
+	dark := false
+	var rest []string
+	for _, word := range words {
+		switch {
+		case fillerWords[word]:
+		case contains(darkModifiers, word):
+			dark = true
+		case contains(lightModifiers, word):
+		default:
+			rest = append(rest, word)
+		}
+	}
+	if len(rest) != 1 {
+		return "", "", false
+	}
+	word := rest[0]
+
+	if neutral, ok := neutrals[word]; ok {
+		return neutral.bg, neutral.stroke, true
+	}
+	hue, ok := lookupHue(word)
+	if !ok {
+		return "", "", false
+	}
+	if dark || darkAliases[word] {
+		return hue.Dark, hue.Dark, true
+	}
+	return hue.Light, hue.Dark, true
+}
+
+func lookupHue(word string) (Hue, bool) {
+	for _, hue := range Palette {
+		if hue.Name == word || contains(hue.Aliases, word) {
+			return hue, true
+		}
+	}
+	// Short words are a letter off too many others: "bold" from "gold".
+	if len(word) < minFuzzyLength {
+		return Hue{}, false
+	}
+	for _, hue := range Palette {
+		for _, candidate := range append([]string{hue.Name}, hue.Aliases...) {
+			if len(candidate) >= minFuzzyLength && withinOneEdit(word, candidate) {
+				return hue, true
+			}
+		}
+	}
+	return Hue{}, false
+}
+
+// IsPalette reports whether hex is a palette color, a neutral or the
+// default stroke. Case doesn't matter.
+func IsPalette(hex string) bool {
+	hex = strings.ToLower(hex)
+	for _, color := range allColors() {
+		if color == hex {
+			return true
+		}
+	}
+	return false
+}
+
+// Nearest returns the palette color closest to hex, a #rgb, #rrggbb or
+// #rrggbbaa color, ignoring any alpha; ok is false for anything else.
+func Nearest(hex string) (string, bool) {
+	r, g, b, ok := parseHex(hex)
+	if !ok {
+		return "", false
+	}
+	best, bestDistance := "", math.Inf(1)
+	for _, color := range allColors() {
+		cr, cg, cb, _ := parseHex(color)
+		// Weighted for how the eye sees each channel.
+		distance := 0.3*square(r-cr) + 0.59*square(g-cg) + 0.11*square(b-cb)
+		if distance < bestDistance {
+			best, bestDistance = color, distance
+		}
+	}
+	return best, true
+}
+
+// PromptSection renders the palette as the prompts' COLORS section.
+func PromptSection() string {
+	var sb strings.Builder
+	sb.WriteString("## COLORS\n")
+	sb.WriteString("Use only these colors: fill shapes with the light shade and outline them with the dark one.\n")
+	for _, hue := range Palette {
+		fmt.Fprintf(&sb, "- %s: %q (light), %q (dark)\n", strings.ToUpper(hue.Name[:1])+hue.Name[1:], hue.Light, hue.Dark)
+	}
+	fmt.Fprintf(&sb, "- Default: %q (stroke), %q (fill)", DefaultStroke, Transparent)
+	return sb.String()
+}
+
+func allColors() []string {
+	colors := make([]string, 0, 2*len(Palette)+len(neutrals))
+	for _, hue := range Palette {
+		colors = append(colors, hue.Light, hue.Dark)
+	}
+	return append(colors, DefaultStroke, neutrals["white"].bg)
+}
+
+func parseHex(hex string) (r, g, b float64, ok bool) {
+	hex = strings.TrimPrefix(hex, "#")
+	switch len(hex) {
+	case 3:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 8:
+		hex = hex[:6]
+	}
+	if len(hex) != 6 {
+		return 0, 0, 0, false
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return float64(value >> 16 & 0xff), float64(value >> 8 & 0xff), float64(value & 0xff), true
+}
+
+func square(v float64) float64 {
+	return v * v
+}
+
+func contains(list []string, word string) bool {
+	for _, item := range list {
+		if item == word {
+			return true
+		}
+	}
+	return false
+}
+
+// withinOneEdit reports whether a and b differ by at most one inserted,
+// deleted, substituted or swapped letter.
+func withinOneEdit(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if len(a) == len(b) {
+		if i+1 < len(a) && a[i] == b[i+1] && a[i+1] == b[i] && a[i+2:] == b[i+2:] {
+			return true
+		}
+		return a[i+1:] == b[i+1:]
+	}
+	return a[i:] == b[i+1:]
+}
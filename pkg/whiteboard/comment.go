@@ -0,0 +1,49 @@
+package whiteboard
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CommentIntent is a request to leave a comment. Target is the spoken name of
+// the element ("the billing box"), empty for a board-level comment; ElementID
+// is filled in once Target is resolved.
+type CommentIntent struct {
+	Target    string  `json:"-"`
+	Text      string  `json:"text"`
+	ElementID *string `json:"elementId,omitempty"`
+}
+
+var (
+	elementCommentPattern = regexp.MustCompile(`(?i)^(?:please\s+)?(?:add|leave|put)\s+an?\s+(?:comment|note)\s+(?:on|to)\s+(.+?)\s+(?:saying|that says|reading)\s+(.+?)[.!]?$`)
+	boardCommentPattern   = regexp.MustCompile(`(?i)^(?:please\s+)?(?:add|leave|put)\s+an?\s+(?:comment|note)\s+(?:saying|that says|reading)\s+(.+?)[.!]?$`)
+)
+
+// ParseCommentIntent recognises "add a comment on the billing box saying
+// needs review" and "leave a note saying ship it" so they can be handled
+// without the whiteboard prompt.
+func ParseCommentIntent(instruction string) (*CommentIntent, bool) {
+	instruction = strings.TrimSpace(instruction)
+
+	if m := elementCommentPattern.FindStringSubmatch(instruction); m != nil {
+		target := strings.TrimSpace(m[1])
+		if !isBoardTarget(target) {
+			return &CommentIntent{Target: target, Text: strings.TrimSpace(m[2])}, true
+		}
+		return &CommentIntent{Text: strings.TrimSpace(m[2])}, true
+	}
+
+	if m := boardCommentPattern.FindStringSubmatch(instruction); m != nil {
+		return &CommentIntent{Text: strings.TrimSpace(m[1])}, true
+	}
+
+	return nil, false
+}
+
+func isBoardTarget(target string) bool {
+	switch strings.ToLower(target) {
+	case "the board", "this board", "the whiteboard", "this whiteboard":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package whiteboard
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"draw/pkg/llm"
+)
+
+// DefaultLocale is used for boards without a locale.
+const DefaultLocale = "en-US"
+
+type localeFormat struct {
+	date      string
+	weekStart time.Weekday
+	// nextIsFollowingWeek means "next Friday" is the Friday of next week
+	// rather than the coming Friday.
+	nextIsFollowingWeek bool
+}
+
+var localeFormats = map[string]localeFormat{
+	"en-US": {date: "01/02/2006", weekStart: time.Sunday},
+	"en-CA": {date: "2006-01-02", weekStart: time.Sunday},
+	"en-GB": {date: "02/01/2006", weekStart: time.Monday, nextIsFollowingWeek: true},
+	"en-AU": {date: "02/01/2006", weekStart: time.Monday, nextIsFollowingWeek: true},
+	"en-IN": {date: "02/01/2006", weekStart: time.Monday},
+	"de-DE": {date: "02.01.2006", weekStart: time.Monday, nextIsFollowingWeek: true},
+	"fr-FR": {date: "02/01/2006", weekStart: time.Monday, nextIsFollowingWeek: true},
+	"es-ES": {date: "02/01/2006", weekStart: time.Monday, nextIsFollowingWeek: true},
+	"ja-JP": {date: "2006/01/02", weekStart: time.Sunday},
+}
+
+// languageDefaults picks a regional format when only a language is given.
+var languageDefaults = map[string]string{
+	"en": "en-US",
+	"de": "de-DE",
+	"fr": "fr-FR",
+	"es": "es-ES",
+	"ja": "ja-JP",
+}
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// ValidateLocale checks a BCP-47 language or language-region tag such as
+// "de" or "en-GB".
+func ValidateLocale(locale string) error {
+	if !localePattern.MatchString(locale) {
+		return fmt.Errorf("locale %q is not a language or language-region tag", locale)
+	}
+	return nil
+}
+
+// ValidateTimezone checks an IANA time zone name.
+func ValidateTimezone(timezone string) error {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("unknown time zone %q", timezone)
+	}
+	return nil
+}
+
+func formatFor(locale string) localeFormat {
+	if format, ok := localeFormats[locale]; ok {
+		return format
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	if fallback, ok := languageDefaults[language]; ok {
+		return localeFormats[fallback]
+	}
+	return localeFormat{date: "2006-01-02", weekStart: time.Monday}
+}
+
+// FormatDate formats t as a short date in the locale's convention.
+func FormatDate(t time.Time, locale string) string {
+	return t.Format(formatFor(locale).date)
+}
+
+// FormatDateRange formats an inclusive date range.
+func FormatDateRange(start, end time.Time, locale string) string {
+	return FormatDate(start, locale) + " – " + FormatDate(end, locale)
+}
+
+var (
+	relativeDayPattern  = regexp.MustCompile(`(?i)\b(today|tomorrow|yesterday)\b`)
+	relativeWeekPattern = regexp.MustCompile(`(?i)\b(this|next|last) week(?:'s)?\b`)
+	weekdayPattern      = regexp.MustCompile(`(?i)\b(this|next|last) (monday|tuesday|wednesday|thursday|friday|saturday|sunday)\b`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// ResolveDates finds relative date phrases in the instruction ("today",
+// "next Friday", "this week's") and resolves them against now in the
+// board's time zone, formatted for the board's locale. Ambiguous phrases
+// follow the locale's conventions.
+func ResolveDates(instruction string, now time.Time, timezone string, locale string) []llm.Substitution {
+	if loc, err := time.LoadLocation(timezone); err == nil && timezone != "" {
+		now = now.In(loc)
+	}
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	format := formatFor(locale)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var subs []llm.Substitution
+	seen := make(map[string]bool)
+	add := func(phrase, value string) {
+		key := strings.ToLower(phrase)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		subs = append(subs, llm.Substitution{Phrase: phrase, Value: value})
+	}
+
+	for _, m := range relativeDayPattern.FindAllString(instruction, -1) {
+		offset := map[string]int{"today": 0, "tomorrow": 1, "yesterday": -1}[strings.ToLower(m)]
+		add(m, FormatDate(today.AddDate(0, 0, offset), locale))
+	}
+
+	weekStart := startOfWeek(today, format.weekStart)
+	for _, m := range relativeWeekPattern.FindAllStringSubmatch(instruction, -1) {
+		start := weekStart
+		switch strings.ToLower(m[1]) {
+		case "next":
+			start = start.AddDate(0, 0, 7)
+		case "last":
+			start = start.AddDate(0, 0, -7)
+		}
+		add(m[0], FormatDateRange(start, start.AddDate(0, 0, 6), locale))
+	}
+
+	for _, m := range weekdayPattern.FindAllStringSubmatch(instruction, -1) {
+		target := weekdays[strings.ToLower(m[2])]
+		// Day of target within the current week.
+		inWeek := weekStart.AddDate(0, 0, (int(target)-int(format.weekStart)+7)%7)
+
+		var day time.Time
+		switch strings.ToLower(m[1]) {
+		case "this":
+			day = inWeek
+		case "last":
+			day = inWeek.AddDate(0, 0, -7)
+		case "next":
+			if format.nextIsFollowingWeek {
+				day = inWeek.AddDate(0, 0, 7)
+			} else {
+				// The coming occurrence, never today.
+				day = today.AddDate(0, 0, (int(target)-int(today.Weekday())+6)%7+1)
+			}
+		}
+		add(m[0], FormatDate(day, locale))
+	}
+
+	return subs
+}
+
+func startOfWeek(day time.Time, weekStart time.Weekday) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) - int(weekStart) + 7) % 7))
+}
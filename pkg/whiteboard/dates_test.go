@@ -0,0 +1,118 @@
+package whiteboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDate(t *testing.T) {
+	day := time.Date(2026, time.March, 4, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "en-US", want: "03/04/2026"},
+		{locale: "en-GB", want: "04/03/2026"},
+		{locale: "de-DE", want: "04.03.2026"},
+		{locale: "de", want: "04.03.2026"},
+		{locale: "en", want: "03/04/2026"},
+		{locale: "nl-NL", want: "2026-03-04"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := FormatDate(day, tt.locale); got != tt.want {
+				t.Errorf("FormatDate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveDates(t *testing.T) {
+	// A Wednesday.
+	wednesday := time.Date(2026, time.October, 14, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		locale   string
+		timezone string
+		now      time.Time
+		phrase   string
+		want     string
+	}{
+		{name: "today", locale: "en-US", now: wednesday, phrase: "today", want: "10/14/2026"},
+		{name: "today", locale: "en-GB", now: wednesday, phrase: "today", want: "14/10/2026"},
+		{name: "today", locale: "de-DE", now: wednesday, phrase: "today", want: "14.10.2026"},
+		{name: "tomorrow", locale: "en-US", now: wednesday, phrase: "tomorrow", want: "10/15/2026"},
+		{name: "yesterday", locale: "de-DE", now: wednesday, phrase: "Yesterday", want: "13.10.2026"},
+
+		// Weeks start on Sunday in the US and on Monday in the UK and Germany.
+		{name: "this week", locale: "en-US", now: wednesday, phrase: "this week's", want: "10/11/2026 – 10/17/2026"},
+		{name: "this week", locale: "en-GB", now: wednesday, phrase: "this week's", want: "12/10/2026 – 18/10/2026"},
+		{name: "this week", locale: "de-DE", now: wednesday, phrase: "this week", want: "12.10.2026 – 18.10.2026"},
+		{name: "next week", locale: "en-US", now: wednesday, phrase: "next week", want: "10/18/2026 – 10/24/2026"},
+		{name: "last week", locale: "en-GB", now: wednesday, phrase: "last week", want: "05/10/2026 – 11/10/2026"},
+
+		// "Next Friday" is the coming Friday in the US and next week's in the
+		// UK and Germany.
+		{name: "next Friday", locale: "en-US", now: wednesday, phrase: "next Friday", want: "10/16/2026"},
+		{name: "next Friday", locale: "en-GB", now: wednesday, phrase: "next Friday", want: "23/10/2026"},
+		{name: "next Friday", locale: "de-DE", now: wednesday, phrase: "next Friday", want: "23.10.2026"},
+		{name: "next Wednesday on a Wednesday", locale: "en-US", now: wednesday, phrase: "next Wednesday", want: "10/21/2026"},
+		{name: "this Friday", locale: "en-US", now: wednesday, phrase: "this Friday", want: "10/16/2026"},
+		{name: "this Friday", locale: "en-GB", now: wednesday, phrase: "this Friday", want: "16/10/2026"},
+		{name: "this Sunday", locale: "en-US", now: wednesday, phrase: "this Sunday", want: "10/11/2026"},
+		{name: "this Sunday", locale: "de-DE", now: wednesday, phrase: "this Sunday", want: "18.10.2026"},
+		{name: "last Monday", locale: "de-DE", now: wednesday, phrase: "last Monday", want: "05.10.2026"},
+
+		// The day is the board's, not the server's.
+		{name: "today in Berlin", locale: "de-DE", timezone: "Europe/Berlin", now: time.Date(2026, time.October, 14, 23, 30, 0, 0, time.UTC), phrase: "today", want: "15.10.2026"},
+		{name: "today in Los Angeles", locale: "en-US", timezone: "America/Los_Angeles", now: time.Date(2026, time.October, 15, 3, 0, 0, 0, time.UTC), phrase: "today", want: "10/14/2026"},
+		{name: "unknown time zone", locale: "en-GB", timezone: "Mars/Olympus", now: wednesday, phrase: "today", want: "14/10/2026"},
+		{name: "no locale", now: wednesday, phrase: "next Friday", want: "10/16/2026"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale+"/"+tt.name, func(t *testing.T) {
+			subs := ResolveDates("label it with "+tt.phrase+" please", tt.now, tt.timezone, tt.locale)
+			if len(subs) != 1 {
+				t.Fatalf("got %d substitutions, want 1: %+v", len(subs), subs)
+			}
+			if subs[0].Phrase != tt.phrase {
+				t.Errorf("phrase = %q, want %q", subs[0].Phrase, tt.phrase)
+			}
+			if subs[0].Value != tt.want {
+				t.Errorf("value = %q, want %q", subs[0].Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveDatesRepeatedPhrases(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 10, 0, 0, 0, time.UTC)
+	subs := ResolveDates("today, Today and tomorrow", now, "", "en-GB")
+	if len(subs) != 2 {
+		t.Fatalf("got %+v, want today and tomorrow once each", subs)
+	}
+	if subs := ResolveDates("add a box labelled Friday", now, "", "en-GB"); len(subs) != 0 {
+		t.Errorf("got %+v, want nothing for a bare weekday", subs)
+	}
+}
+
+func TestValidateLocaleAndTimezone(t *testing.T) {
+	for _, locale := range []string{"en", "en-GB", "de-DE", "fil"} {
+		if err := ValidateLocale(locale); err != nil {
+			t.Errorf("ValidateLocale(%q) = %v, want nil", locale, err)
+		}
+	}
+	for _, locale := range []string{"", "EN", "en_GB", "en-gb", "english"} {
+		if err := ValidateLocale(locale); err == nil {
+			t.Errorf("ValidateLocale(%q) = nil, want an error", locale)
+		}
+	}
+	for _, timezone := range []string{"UTC", "Europe/Berlin", "America/New_York"} {
+		if err := ValidateTimezone(timezone); err != nil {
+			t.Errorf("ValidateTimezone(%q) = %v, want nil", timezone, err)
+		}
+	}
+	if err := ValidateTimezone("Mars/Olympus"); err == nil {
+		t.Errorf("ValidateTimezone(Mars/Olympus) = nil, want an error")
+	}
+}
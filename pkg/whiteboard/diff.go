@@ -0,0 +1,233 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"draw/pkg/llm"
+)
+
+// diffEpsilon is the smallest coordinate change Diff reports; smaller ones
+// are rounding from dragging and zooming, not edits.
+const diffEpsilon = 0.01
+
+// volatileKeys are Excalidraw bookkeeping that changes on every save, so
+// Diff never reports it, and isDeleted, which it reports as a removal.
+var volatileKeys = map[string]bool{
+	"version":      true,
+	"versionNonce": true,
+	"updated":      true,
+	"seed":         true,
+	"isDeleted":    true,
+}
+
+// coordinateKeys hold positions and sizes, compared within diffEpsilon.
+var coordinateKeys = map[string]bool{
+	"x":      true,
+	"y":      true,
+	"width":  true,
+	"height": true,
+	"points": true,
+}
+
+// BoardDiff is what changed between two board states. Elements are matched
+// by ID; deleted elements count as absent, so deleting one reports it
+// removed. An element whose type changed is modified, with a "type" change.
+type BoardDiff struct {
+	Added    []llm.Element   `json:"added"`
+	Removed  []llm.Element   `json:"removed"`
+	Modified []ElementChange `json:"modified"`
+}
+
+// ElementChange lists the properties of one element that changed.
+type ElementChange struct {
+	ID      string           `json:"id"`
+	Type    string           `json:"type"`
+	Changes []PropertyChange `json:"changes"`
+}
+
+// PropertyChange is one changed property. Path names it, with nested
+// objects joined by dots, as in "label.text". Before or After is left out
+// when the property was added or removed.
+type PropertyChange struct {
+	Path   string          `json:"path"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Empty reports whether the two states were the same.
+func (d BoardDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Diff compares two board states. Added and modified elements are listed in
+// after's order, removed ones in before's, and each element's changes by
+// path. Elements without an ID can't be matched and are skipped.
+func Diff(before []llm.Element, after []llm.Element) (BoardDiff, error) {
+	diff := BoardDiff{
+		Added:    []llm.Element{},
+		Removed:  []llm.Element{},
+		Modified: []ElementChange{},
+	}
+	old := liveByID(before)
+	current := liveByID(after)
+
+	seen := make(map[string]bool, len(current))
+	for _, element := range after {
+		if _, ok := current[element.ID]; !ok || seen[element.ID] {
+			continue
+		}
+		seen[element.ID] = true
+		element = current[element.ID]
+		previous, ok := old[element.ID]
+		if !ok {
+			diff.Added = append(diff.Added, element)
+			continue
+		}
+		changes, err := elementChanges(previous, element)
+		if err != nil {
+			return BoardDiff{}, fmt.Errorf("failed to diff element %s: %w", element.ID, err)
+		}
+		if len(changes) > 0 {
+			diff.Modified = append(diff.Modified, ElementChange{ID: element.ID, Type: element.Type, Changes: changes})
+		}
+	}
+	for _, element := range before {
+		if _, ok := old[element.ID]; !ok || seen[element.ID] {
+			continue
+		}
+		seen[element.ID] = true
+		diff.Removed = append(diff.Removed, old[element.ID])
+	}
+	return diff, nil
+}
+
+// liveByID indexes the elements that aren't deleted; of elements sharing an
+// ID, the last one wins, as it does when clients load a board.
+func liveByID(elements []llm.Element) map[string]llm.Element {
+	byID := make(map[string]llm.Element, len(elements))
+	for _, element := range elements {
+		if element.ID == "" {
+			continue
+		}
+		if isDeleted(element) {
+			delete(byID, element.ID)
+			continue
+		}
+		byID[element.ID] = element
+	}
+	return byID
+}
+
+// elementChanges compares two elements as their JSON, so properties kept in
+// Extra are compared too.
+func elementChanges(before llm.Element, after llm.Element) ([]PropertyChange, error) {
+	a, err := elementObject(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := elementObject(after)
+	if err != nil {
+		return nil, err
+	}
+	var changes []PropertyChange
+	if err := diffObjects("", a, b, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func elementObject(element llm.Element) (map[string]any, error) {
+	data, err := json.Marshal(element)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// diffObjects appends the differences between two JSON objects to changes,
+// recursing into nested objects; other values are compared whole.
+func diffObjects(prefix string, before map[string]any, after map[string]any, changes *[]PropertyChange) error {
+	keys := make(map[string]bool, len(before)+len(after))
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		if prefix != "" || !volatileKeys[key] {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		a, inBefore := before[key]
+		b, inAfter := after[key]
+		if objA, ok := a.(map[string]any); ok {
+			if objB, ok := b.(map[string]any); ok {
+				if err := diffObjects(path, objA, objB, changes); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if inBefore && inAfter && sameValue(a, b, prefix == "" && coordinateKeys[key]) {
+			continue
+		}
+
+		change := PropertyChange{Path: path}
+		var err error
+		if inBefore {
+			if change.Before, err = json.Marshal(a); err != nil {
+				return err
+			}
+		}
+		if inAfter {
+			if change.After, err = json.Marshal(b); err != nil {
+				return err
+			}
+		}
+		*changes = append(*changes, change)
+	}
+	return nil
+}
+
+// sameValue compares two decoded JSON values; numbers within diffEpsilon
+// are the same when approximate is set, including inside arrays.
+func sameValue(a any, b any, approximate bool) bool {
+	if !approximate {
+		return reflect.DeepEqual(a, b)
+	}
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		return ok && math.Abs(a-b) < diffEpsilon
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !sameValue(a[i], b[i], true) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
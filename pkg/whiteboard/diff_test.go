@@ -0,0 +1,185 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+func parseElements(t *testing.T, raw string) []llm.Element {
+	t.Helper()
+	var elements []llm.Element
+	if err := json.Unmarshal([]byte(raw), &elements); err != nil {
+		t.Fatalf("failed to parse elements: %v", err)
+	}
+	return elements
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		added    []string
+		removed  []string
+		modified map[string][]string
+	}{
+		{
+			name:   "unchanged but for bookkeeping and rounding",
+			before: `[{"id":"a","type":"rectangle","x":10,"y":20,"version":1,"versionNonce":5,"updated":100,"seed":7}]`,
+			after:  `[{"id":"a","type":"rectangle","x":10.004,"y":19.999,"version":2,"versionNonce":6,"updated":200,"seed":8}]`,
+		},
+		{
+			name:    "added and removed",
+			before:  `[{"id":"a","type":"rectangle","x":0,"y":0},{"id":"b","type":"ellipse","x":0,"y":0}]`,
+			after:   `[{"id":"a","type":"rectangle","x":0,"y":0},{"id":"c","type":"diamond","x":0,"y":0}]`,
+			added:   []string{"c"},
+			removed: []string{"b"},
+		},
+		{
+			name:    "deleted counts as removed",
+			before:  `[{"id":"a","type":"rectangle","x":0,"y":0}]`,
+			after:   `[{"id":"a","type":"rectangle","x":0,"y":0,"isDeleted":true}]`,
+			removed: []string{"a"},
+		},
+		{
+			name:     "moved past epsilon",
+			before:   `[{"id":"a","type":"line","x":0,"y":0,"points":[[0,0],[100,0]]}]`,
+			after:    `[{"id":"a","type":"line","x":5,"y":0,"points":[[0,0],[100,0.5]]}]`,
+			modified: map[string][]string{"a": {"points", "x"}},
+		},
+		{
+			name:     "retyped",
+			before:   `[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":50}]`,
+			after:    `[{"id":"a","type":"ellipse","x":0,"y":0,"width":100,"height":50}]`,
+			modified: map[string][]string{"a": {"type"}},
+		},
+		{
+			name:     "label text changed",
+			before:   `[{"id":"a","type":"rectangle","x":0,"y":0,"label":{"text":"Login","fontSize":16}}]`,
+			after:    `[{"id":"a","type":"rectangle","x":0,"y":0,"label":{"text":"Sign in","fontSize":16}}]`,
+			modified: map[string][]string{"a": {"label.text"}},
+		},
+		{
+			name:     "nested customData changed",
+			before:   `[{"id":"a","type":"arrow","x":0,"y":0,"customData":{"routing":"elbow","owner":"u1"}}]`,
+			after:    `[{"id":"a","type":"arrow","x":0,"y":0,"customData":{"routing":"curved","owner":"u1"}}]`,
+			modified: map[string][]string{"a": {"customData.routing"}},
+		},
+		{
+			name:     "label added",
+			before:   `[{"id":"a","type":"rectangle","x":0,"y":0}]`,
+			after:    `[{"id":"a","type":"rectangle","x":0,"y":0,"label":{"text":"New"}}]`,
+			modified: map[string][]string{"a": {"label"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := Diff(parseElements(t, tt.before), parseElements(t, tt.after))
+			if err != nil {
+				t.Fatalf("Diff: %v", err)
+			}
+			if got := elementIDs(diff.Added); !equalStrings(got, tt.added) {
+				t.Errorf("added = %v, want %v", got, tt.added)
+			}
+			if got := elementIDs(diff.Removed); !equalStrings(got, tt.removed) {
+				t.Errorf("removed = %v, want %v", got, tt.removed)
+			}
+			if len(diff.Modified) != len(tt.modified) {
+				t.Fatalf("modified %d elements, want %d: %+v", len(diff.Modified), len(tt.modified), diff.Modified)
+			}
+			for _, change := range diff.Modified {
+				var paths []string
+				for _, property := range change.Changes {
+					paths = append(paths, property.Path)
+				}
+				if want := tt.modified[change.ID]; !equalStrings(paths, want) {
+					t.Errorf("changes to %s = %v, want %v", change.ID, paths, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffRetypedValues(t *testing.T) {
+	diff, err := Diff(
+		parseElements(t, `[{"id":"a","type":"rectangle","x":0,"y":0}]`),
+		parseElements(t, `[{"id":"a","type":"ellipse","x":0,"y":0}]`),
+	)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(diff.Modified) != 1 {
+		t.Fatalf("modified %d elements, want 1", len(diff.Modified))
+	}
+	change := diff.Modified[0]
+	if change.Type != "ellipse" {
+		t.Errorf("type = %q, want the new type ellipse", change.Type)
+	}
+	if got := string(change.Changes[0].Before); got != `"rectangle"` {
+		t.Errorf("before = %s, want \"rectangle\"", got)
+	}
+	if got := string(change.Changes[0].After); got != `"ellipse"` {
+		t.Errorf("after = %s, want \"ellipse\"", got)
+	}
+}
+
+func TestBoardDiffJSON(t *testing.T) {
+	empty, err := Diff(nil, nil)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	data, err := json.Marshal(empty)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got, want := string(data), `{"added":[],"removed":[],"modified":[]}`; got != want {
+		t.Errorf("empty diff = %s, want %s", got, want)
+	}
+
+	diff, err := Diff(
+		parseElements(t, `[{"id":"a","type":"rectangle","x":0,"y":0,"label":{"text":"Login"}}]`),
+		parseElements(t, `[{"id":"a","type":"rectangle","x":0,"y":0}]`),
+	)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	data, err = json.Marshal(diff)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"added":[],"removed":[],"modified":[{"id":"a","type":"rectangle","changes":[{"path":"label","before":{"text":"Login"}}]}]}`
+	if string(data) != want {
+		t.Errorf("diff = %s, want %s", data, want)
+	}
+
+	var decoded BoardDiff
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(decoded.Modified) != 1 || decoded.Modified[0].Changes[0].After != nil {
+		t.Errorf("decoded = %+v, want the label's removal", decoded)
+	}
+}
+
+func elementIDs(elements []llm.Element) []string {
+	var ids []string
+	for _, element := range elements {
+		ids = append(ids, element.ID)
+	}
+	return ids
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package whiteboard
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+
+	"draw/pkg/llm"
+)
+
+// ExcalidrawSource is the source exported .excalidraw files name.
+const ExcalidrawSource = "VoicePad"
+
+// Defaults Excalidraw gives new elements, used for what boards don't store.
+const (
+	defaultStrokeColor = "#1e1e1e"
+	defaultFontSize    = 20
+	// Excalifont, Excalidraw's hand-drawn font.
+	defaultFontFamily = FontExcalifont
+	// Roundness types: proportional for linear elements and diamonds,
+	// adaptive for rectangles.
+	proportionalRadius = 2
+	adaptiveRadius     = 3
+	// bindingGap is how far a bound arrow stops short of its element.
+	bindingGap = 4
+)
+
+// ExcalidrawFile is a board in the .excalidraw file format the Excalidraw
+// app opens.
+type ExcalidrawFile struct {
+	Type     string           `json:"type"`
+	Version  int              `json:"version"`
+	Source   string           `json:"source"`
+	Elements []map[string]any `json:"elements"`
+	AppState map[string]any   `json:"appState"`
+	Files    map[string]any   `json:"files"`
+}
+
+// ToExcalidrawFile wraps a board's elements in an .excalidraw file. Boards
+// hold both elements clients saved, which are complete, and ones the server
+// added in the model's shorter form, so every element is completed: labels
+// become bound text elements, start and end become bindings, and missing
+// properties get Excalidraw's defaults. Seeds and nonces are derived from
+// element IDs, so exporting a board twice gives the same file, and elements
+// that don't record when they changed are given updated. Elements are
+// written from back to front, each with a fractional index. Deleted elements
+// are left out, and images are kept without their pictures.
+func ToExcalidrawFile(elements []llm.Element, updated time.Time) (*ExcalidrawFile, error) {
+	var live []llm.Element
+	for _, element := range OrderByIndex(elements) {
+		if !isDeleted(element) {
+			live = append(live, element)
+		}
+	}
+
+	converted := make([]map[string]any, 0, len(live))
+	byID := make(map[string]map[string]any, len(live))
+	var bindings [][2]string
+	for i, element := range live {
+		if element.ID == "" {
+			element.ID = fmt.Sprintf("element-%d", i+1)
+		}
+		object, err := elementObject(element)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert element %s: %w", element.ID, err)
+		}
+		for _, key := range []string{"label", "start", "end", "children", "color", "routing"} {
+			delete(object, key)
+		}
+		completeElement(object, element, updated)
+		converted = append(converted, object)
+		byID[element.ID] = object
+
+		if element.Start != nil {
+			object["startBinding"] = map[string]any{"elementId": element.Start.ID, "focus": 0, "gap": bindingGap}
+			bindings = append(bindings, [2]string{element.Start.ID, element.ID})
+		}
+		if element.End != nil {
+			object["endBinding"] = map[string]any{"elementId": element.End.ID, "focus": 0, "gap": bindingGap}
+			bindings = append(bindings, [2]string{element.End.ID, element.ID})
+		}
+		if element.Label != nil && element.Label.Text != "" {
+			text := labelElement(element, updated)
+			converted = append(converted, text)
+			byID[element.ID+"-label"] = text
+			addBoundElement(object, text["id"].(string), "text")
+		}
+	}
+
+	for _, binding := range bindings {
+		target, ok := byID[binding[0]]
+		if !ok {
+			// Drop bindings to elements that aren't in the file.
+			arrow := byID[binding[1]]
+			for _, key := range []string{"startBinding", "endBinding"} {
+				if end, ok := arrow[key].(map[string]any); ok && end["elementId"] == binding[0] {
+					arrow[key] = nil
+				}
+			}
+			continue
+		}
+		addBoundElement(target, binding[1], "arrow")
+	}
+
+	// Labels were added after their elements without indices of their own.
+	indices := make([]string, len(converted))
+	for i, object := range converted {
+		indices[i], _ = object["index"].(string)
+	}
+	indices, err := syncIndices(indices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to index elements: %w", err)
+	}
+	for i, object := range converted {
+		object["index"] = indices[i]
+	}
+
+	return &ExcalidrawFile{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   ExcalidrawSource,
+		Elements: converted,
+		AppState: map[string]any{
+			"gridSize":            20,
+			"viewBackgroundColor": "#ffffff",
+		},
+		Files: map[string]any{},
+	}, nil
+}
+
+// completeElement fills in the properties of an Excalidraw element that
+// object lacks.
+func completeElement(object map[string]any, element llm.Element, updated time.Time) {
+	defaults := map[string]any{
+		"x":               0,
+		"y":               0,
+		"width":           0,
+		"height":          0,
+		"angle":           0,
+		"strokeColor":     defaultStrokeColor,
+		"backgroundColor": "transparent",
+		"fillStyle":       "solid",
+		"strokeWidth":     2,
+		"strokeStyle":     "solid",
+		"roughness":       1,
+		"opacity":         100,
+		"groupIds":        []any{},
+		"frameId":         nil,
+		"roundness":       nil,
+		"seed":            elementSeed(element.ID, "seed"),
+		"version":         1,
+		"versionNonce":    elementSeed(element.ID, "nonce"),
+		"isDeleted":       false,
+		"boundElements":   nil,
+		"updated":         updated.UnixMilli(),
+		"link":            nil,
+		"locked":          false,
+	}
+
+	switch element.Type {
+	case "rectangle":
+		defaults["roundness"] = map[string]any{"type": adaptiveRadius}
+	case "diamond":
+		defaults["roundness"] = map[string]any{"type": proportionalRadius}
+	case "text":
+		width, height := MeasureText(element.Text, fontSizeOf(element.FontSize), element.FontFamily)
+		defaults["width"], defaults["height"] = width, height
+		defaults["text"] = ""
+		defaults["fontSize"] = defaultFontSize
+		defaults["fontFamily"] = defaultFontFamily
+		defaults["textAlign"] = "left"
+		defaults["verticalAlign"] = "top"
+		defaults["containerId"] = nil
+		defaults["originalText"] = element.Text
+		defaults["autoResize"] = true
+		defaults["lineHeight"] = LineHeight(element.FontFamily)
+	case "arrow", "line":
+		defaults["roundness"] = map[string]any{"type": proportionalRadius}
+		defaults["points"] = [][]float64{{0, 0}, {element.Width, element.Height}}
+		defaults["lastCommittedPoint"] = nil
+		defaults["startBinding"] = nil
+		defaults["endBinding"] = nil
+		defaults["startArrowhead"] = nil
+		defaults["endArrowhead"] = nil
+		if element.Type == "arrow" {
+			defaults["endArrowhead"] = "arrow"
+			defaults["elbowed"] = false
+		}
+	case "freedraw":
+		defaults["points"] = [][]float64{}
+		defaults["pressures"] = []float64{}
+		defaults["simulatePressure"] = true
+		defaults["lastCommittedPoint"] = nil
+	case FrameType:
+		defaults["name"] = nil
+	case "image":
+		defaults["fileId"] = nil
+		defaults["status"] = "saved"
+		defaults["scale"] = []float64{1, 1}
+		defaults["crop"] = nil
+	}
+
+	for key, value := range defaults {
+		if _, ok := object[key]; !ok {
+			object[key] = value
+		}
+	}
+}
+
+// labelElement returns the text element Excalidraw keeps a label in, bound
+// to and centered on element.
+func labelElement(element llm.Element, updated time.Time) map[string]any {
+	label := element.Label
+	fontSize := fontSizeOf(label.FontSize)
+	width, height := MeasureText(label.Text, fontSize, defaultFontFamily)
+	strokeColor := label.StrokeColor
+	if strokeColor == "" {
+		strokeColor = defaultStrokeColor
+	}
+
+	text := llm.Element{
+		ID:          element.ID + "-label",
+		Type:        "text",
+		X:           element.X + element.Width/2 - width/2,
+		Y:           element.Y + element.Height/2 - height/2,
+		Width:       width,
+		Height:      height,
+		StrokeColor: strokeColor,
+		Text:        label.Text,
+		FontSize:    fontSize,
+	}
+	object := map[string]any{
+		"id":            text.ID,
+		"type":          text.Type,
+		"x":             text.X,
+		"y":             text.Y,
+		"width":         width,
+		"height":        height,
+		"strokeColor":   strokeColor,
+		"text":          label.Text,
+		"fontSize":      fontSize,
+		"textAlign":     "center",
+		"verticalAlign": "middle",
+		"containerId":   element.ID,
+	}
+	if element.FrameID != "" {
+		object["frameId"] = element.FrameID
+	}
+	completeElement(object, text, updated)
+	return object
+}
+
+// addBoundElement lists id among the elements bound to object, once.
+func addBoundElement(object map[string]any, id string, elementType string) {
+	bound, _ := object["boundElements"].([]any)
+	for _, existing := range bound {
+		if entry, ok := existing.(map[string]any); ok && entry["id"] == id {
+			return
+		}
+	}
+	object["boundElements"] = append(bound, map[string]any{"id": id, "type": elementType})
+}
+
+func fontSizeOf(fontSize float64) float64 {
+	if fontSize > 0 {
+		return fontSize
+	}
+	return defaultFontSize
+}
+
+// elementSeed derives a stable positive 31-bit number from an element ID.
+func elementSeed(id string, purpose string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(purpose + "/" + id))
+	return int64(h.Sum32()%math.MaxInt32) + 1
+}
@@ -0,0 +1,173 @@
+package whiteboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with the golden file name in testdata, or rewrites it
+// with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("failed to create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s, run with -update to create it: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file, run with -update if the change is intended:\n%s", path, got)
+	}
+}
+
+// exportBoard is a board with the elements an export has to complete: a
+// labelled shape, a bound arrow, an arrow bound to an element that is gone,
+// free text, a frame with a child, a deleted element and one saved by a
+// client with all of its properties.
+const exportBoard = `[
+	{"id":"login","type":"rectangle","x":100,"y":100,"width":160,"height":80,"label":{"text":"Login"},"index":"a0"},
+	{"id":"check","type":"diamond","x":400,"y":100,"width":160,"height":80,"backgroundColor":"#a5d8ff","label":{"text":"Valid?","fontSize":16},"index":"a1"},
+	{"id":"link","type":"arrow","x":260,"y":140,"width":140,"height":0,"start":{"id":"login"},"end":{"id":"check"},"label":{"text":"submit"},"index":"a2"},
+	{"id":"dangling","type":"arrow","x":480,"y":180,"width":0,"height":100,"start":{"id":"check"},"end":{"id":"gone"},"index":"a3"},
+	{"id":"title","type":"text","x":100,"y":40,"text":"Sign in flow","fontSize":28,"index":"a4"},
+	{"id":"group","type":"frame","x":80,"y":300,"width":400,"height":200,"name":"Later","index":"a5"},
+	{"id":"todo","type":"ellipse","x":120,"y":340,"width":120,"height":60,"frameId":"group","index":"a6"},
+	{"id":"old","type":"rectangle","x":0,"y":0,"width":10,"height":10,"isDeleted":true,"index":"a7"},
+	{"id":"saved","type":"rectangle","x":600,"y":300,"width":100,"height":100,"angle":0.5,"seed":42,"version":7,"versionNonce":99,"updated":1700000000000,"roundness":null,"groupIds":["g1"],"boundElements":null,"index":"a8"}
+]`
+
+func TestToExcalidrawFileGolden(t *testing.T) {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	file, err := ToExcalidrawFile(parseElements(t, exportBoard), updated)
+	if err != nil {
+		t.Fatalf("ToExcalidrawFile: %v", err)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	golden(t, "board.excalidraw", append(data, '\n'))
+
+	again, err := ToExcalidrawFile(parseElements(t, exportBoard), updated)
+	if err != nil {
+		t.Fatalf("ToExcalidrawFile: %v", err)
+	}
+	if data2, _ := json.MarshalIndent(again, "", "  "); !bytes.Equal(data, data2) {
+		t.Errorf("exporting the board twice gave different files")
+	}
+}
+
+// requiredFields are the properties Excalidraw expects on every element.
+var requiredFields = []string{
+	"id", "type", "x", "y", "width", "height", "angle", "strokeColor", "backgroundColor",
+	"fillStyle", "strokeWidth", "strokeStyle", "roughness", "opacity", "groupIds", "frameId",
+	"roundness", "seed", "version", "versionNonce", "isDeleted", "boundElements", "updated",
+	"link", "locked", "index",
+}
+
+func TestToExcalidrawFileStructure(t *testing.T) {
+	file, err := ToExcalidrawFile(parseElements(t, exportBoard), time.Unix(0, 0))
+	if err != nil {
+		t.Fatalf("ToExcalidrawFile: %v", err)
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded struct {
+		Type     string           `json:"type"`
+		Version  int              `json:"version"`
+		Source   string           `json:"source"`
+		Elements []map[string]any `json:"elements"`
+		AppState map[string]any   `json:"appState"`
+		Files    map[string]any   `json:"files"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Type != "excalidraw" || decoded.Version != 2 || decoded.Source != ExcalidrawSource {
+		t.Errorf("header = %q v%d from %q, want excalidraw v2 from %q", decoded.Type, decoded.Version, decoded.Source, ExcalidrawSource)
+	}
+	if decoded.AppState == nil || decoded.Files == nil {
+		t.Errorf("appState = %v, files = %v, want both present", decoded.AppState, decoded.Files)
+	}
+
+	byID := make(map[string]map[string]any)
+	var lastIndex string
+	for _, element := range decoded.Elements {
+		id, _ := element["id"].(string)
+		for _, field := range requiredFields {
+			if _, ok := element[field]; !ok {
+				t.Errorf("element %s has no %s", id, field)
+			}
+		}
+		if index, _ := element["index"].(string); index <= lastIndex {
+			t.Errorf("element %s has index %q after %q, want increasing indices", id, index, lastIndex)
+		} else {
+			lastIndex = index
+		}
+		byID[id] = element
+	}
+	if _, ok := byID["old"]; ok {
+		t.Errorf("deleted element was exported")
+	}
+	if got := byID["saved"]["seed"]; got != float64(42) {
+		t.Errorf("seed of a complete element = %v, want its own 42", got)
+	}
+
+	// Bindings and bound elements must point at each other.
+	for id, element := range byID {
+		for _, key := range []string{"startBinding", "endBinding"} {
+			binding, ok := element[key].(map[string]any)
+			if !ok {
+				continue
+			}
+			target, ok := byID[binding["elementId"].(string)]
+			if !ok {
+				t.Errorf("%s of %s points at %v, which isn't in the file", key, id, binding["elementId"])
+				continue
+			}
+			if !boundTo(target, id) {
+				t.Errorf("%s doesn't list %s among its bound elements", binding["elementId"], id)
+			}
+		}
+		if containerID, ok := element["containerId"].(string); ok {
+			if container, ok := byID[containerID]; !ok || !boundTo(container, id) {
+				t.Errorf("label %s isn't bound by its container %s", id, containerID)
+			}
+		}
+	}
+	if byID["dangling"]["endBinding"] != nil {
+		t.Errorf("binding to a missing element = %v, want null", byID["dangling"]["endBinding"])
+	}
+	for _, label := range []string{"login-label", "check-label", "link-label"} {
+		if _, ok := byID[label]; !ok {
+			t.Errorf("label element %s is missing", label)
+		}
+	}
+}
+
+func boundTo(element map[string]any, id string) bool {
+	bound, _ := element["boundElements"].([]any)
+	for _, entry := range bound {
+		if entry, ok := entry.(map[string]any); ok && entry["id"] == id {
+			return true
+		}
+	}
+	return false
+}
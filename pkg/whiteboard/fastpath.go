@@ -0,0 +1,192 @@
+package whiteboard
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard/colors"
+)
+
+// Distances for "move X up a bit", "move X up" and "move X up a lot".
+const (
+	nudgeSmall   = 20
+	nudgeDefault = 50
+	nudgeLarge   = 150
+)
+
+// Scale factors for "make X bigger" and "make X a lot bigger"; smaller is the
+// inverse.
+const (
+	scaleDefault = 1.25
+	scaleLarge   = 1.5
+)
+
+var (
+	fastMovePattern    = regexp.MustCompile(`(?i)^(?:please\s+)?(?:move|shift|nudge)\s+(.+?)\s+(up|down|left|right)(?:\s+(a\s+bit|a\s+little|a\s+lot|by\s+(\d+)(?:\s*(?:px|pixels?))?))?[.!]?$`)
+	fastResizePattern  = regexp.MustCompile(`(?i)^(?:please\s+)?make\s+(.+?)\s+(a\s+bit\s+|a\s+little\s+|a\s+lot\s+|much\s+)?(bigger|larger|smaller)[.!]?$`)
+	fastRecolorPattern = regexp.MustCompile(`(?i)^(?:please\s+)?(?:make|color|colour|turn)\s+(.+?)\s+((?:(?:light|pale|dark|deep)\s+)?[a-z]+)[.!]?$`)
+	fastDeletePattern  = regexp.MustCompile(`(?i)^(?:please\s+)?(?:delete|remove|erase)\s+(.+?)[.!]?$`)
+)
+
+// targetNouns may follow an element's label in a fast-path target, as in
+// "the pricing box".
+var targetNouns = map[string]bool{
+	"box": true, "shape": true, "rectangle": true, "square": true, "circle": true, "ellipse": true,
+	"diamond": true, "text": true, "label": true, "arrow": true, "line": true, "element": true, "node": true,
+}
+
+// FastPathAction turns simple geometry commands - move, resize, recolor or
+// delete one element named by its label - into an action without the model.
+// It reports false whenever the instruction doesn't match the grammar or its
+// target isn't exactly one element, so the caller falls back to the model.
+func FastPathAction(instruction string, board []llm.Element) (*llm.WhiteboardAction, bool) {
+	instruction = strings.TrimSpace(instruction)
+
+	if m := fastMovePattern.FindStringSubmatch(instruction); m != nil {
+		element, ok := fastPathTarget(m[1], board)
+		if !ok {
+			return nil, false
+		}
+		distance := float64(nudgeDefault)
+		switch amount := strings.Join(strings.Fields(strings.ToLower(m[3])), " "); {
+		case m[4] != "":
+			n, err := strconv.Atoi(m[4])
+			if err != nil || n <= 0 {
+				return nil, false
+			}
+			distance = float64(n)
+		case amount == "a bit" || amount == "a little":
+			distance = nudgeSmall
+		case amount == "a lot":
+			distance = nudgeLarge
+		}
+		switch strings.ToLower(m[2]) {
+		case "up":
+			element.Y -= distance
+		case "down":
+			element.Y += distance
+		case "left":
+			element.X -= distance
+		case "right":
+			element.X += distance
+		}
+		return fastPathUpdate(element), true
+	}
+
+	if m := fastResizePattern.FindStringSubmatch(instruction); m != nil {
+		element, ok := fastPathTarget(m[1], board)
+		if !ok || element.Width == 0 || element.Height == 0 || isConnector(element) {
+			return nil, false
+		}
+		scale := scaleDefault
+		switch strings.TrimSpace(strings.ToLower(m[2])) {
+		case "a lot", "much":
+			scale = scaleLarge
+		case "a bit", "a little":
+			scale = 1 + (scaleDefault-1)/2
+		}
+		if strings.EqualFold(m[3], "smaller") {
+			scale = 1 / scale
+		}
+		// Scaled about the center, so the element stays where it was.
+		width, height := math.Round(element.Width*scale), math.Round(element.Height*scale)
+		element.X -= math.Round((width - element.Width) / 2)
+		element.Y -= math.Round((height - element.Height) / 2)
+		element.Width, element.Height = width, height
+		return fastPathUpdate(element), true
+	}
+
+	if m := fastRecolorPattern.FindStringSubmatch(instruction); m != nil {
+		// Resolved from the prompt's palette, so a fast-path recolor looks
+		// like one the model would make.
+		fill, stroke, ok := colors.ResolveColor(m[2])
+		if !ok {
+			return nil, false
+		}
+		element, ok := fastPathTarget(m[1], board)
+		if !ok {
+			return nil, false
+		}
+		if isConnector(element) || element.Type == "text" {
+			element.StrokeColor = stroke
+		} else {
+			element.BackgroundColor = fill
+			element.StrokeColor = stroke
+		}
+		return fastPathUpdate(element), true
+	}
+
+	if m := fastDeletePattern.FindStringSubmatch(instruction); m != nil {
+		element, ok := fastPathTarget(m[1], board)
+		if !ok {
+			return nil, false
+		}
+		return &llm.WhiteboardAction{
+			Action:    llm.ActionDelete,
+			DeleteIDs: []string{element.ID},
+		}, true
+	}
+
+	return nil, false
+}
+
+// fastPathTarget finds the one element the target names. The target must be
+// the element's label or text, give or take a leading article and a trailing
+// noun such as "box"; anything looser is left to the model.
+func fastPathTarget(target string, board []llm.Element) (llm.Element, bool) {
+	words := tokenize(target)
+	if len(words) > 0 && (words[0] == "the" || words[0] == "this" || words[0] == "that") {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return llm.Element{}, false
+	}
+
+	var match llm.Element
+	matches := 0
+	for _, element := range board {
+		if element.ID == "" || isDeleted(element) {
+			continue
+		}
+		text := element.Text
+		if element.Label != nil && element.Label.Text != "" {
+			text = element.Label.Text
+		}
+		phrase := tokenize(text)
+		if len(phrase) == 0 {
+			continue
+		}
+		if !samePhrase(words, phrase) && !(len(words) == len(phrase)+1 && targetNouns[words[len(words)-1]] && samePhrase(words[:len(phrase)], phrase)) {
+			continue
+		}
+		match = element
+		matches++
+	}
+	return match, matches == 1
+}
+
+func samePhrase(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isConnector(element llm.Element) bool {
+	return element.Type == "arrow" || element.Type == "line"
+}
+
+func fastPathUpdate(element llm.Element) *llm.WhiteboardAction {
+	return &llm.WhiteboardAction{
+		Action:   llm.ActionUpdate,
+		Elements: []llm.Element{element},
+	}
+}
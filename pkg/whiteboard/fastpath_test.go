@@ -0,0 +1,90 @@
+package whiteboard
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+const fastPathBoard = `[
+	{"id":"api","type":"rectangle","x":100,"y":100,"width":160,"height":80,"backgroundColor":"#a5d8ff","strokeColor":"#1971c2","label":{"text":"API"}},
+	{"id":"pricing","type":"ellipse","x":400,"y":100,"width":120,"height":80,"label":{"text":"Pricing page"}},
+	{"id":"notes","type":"text","x":100,"y":300,"text":"Notes"},
+	{"id":"flow","type":"arrow","x":260,"y":140,"points":[[0,0],[140,0]],"label":{"text":"calls"}},
+	{"id":"login-1","type":"rectangle","x":0,"y":400,"width":100,"height":60,"label":{"text":"Login"}},
+	{"id":"login-2","type":"rectangle","x":200,"y":400,"width":100,"height":60,"label":{"text":"Login"}},
+	{"id":"old","type":"rectangle","x":0,"y":0,"width":100,"height":60,"label":{"text":"Old"},"isDeleted":true}
+]`
+
+// describeFastPath summarizes a fast-path action for the corpus, or returns
+// "" when the instruction falls through to the model.
+func describeFastPath(action *llm.WhiteboardAction, ok bool) string {
+	if !ok {
+		return ""
+	}
+	if action.Action == llm.ActionDelete {
+		return "delete " + strings.Join(action.DeleteIDs, ",")
+	}
+	e := action.Elements[0]
+	return fmt.Sprintf("%s %s %g,%g %gx%g %s/%s", action.Action, e.ID, e.X, e.Y, e.Width, e.Height, e.BackgroundColor, e.StrokeColor)
+}
+
+// TestFastPathCorpus lists instructions with the action the fast path takes
+// for them, or "" for those left to the model.
+func TestFastPathCorpus(t *testing.T) {
+	corpus := []struct {
+		instruction string
+		want        string
+	}{
+		// Moves.
+		{"move the API box up", "update api 100,50 160x80 #a5d8ff/#1971c2"},
+		{"nudge API left a bit", "update api 80,100 160x80 #a5d8ff/#1971c2"},
+		{"Shift the API down a lot.", "update api 100,250 160x80 #a5d8ff/#1971c2"},
+		{"please move the api right by 35px", "update api 135,100 160x80 #a5d8ff/#1971c2"},
+		{"move the pricing page up", "update pricing 400,50 120x80 /"},
+		{"move the API box up by 0 px", ""},
+		{"move the API box diagonally", ""},
+
+		// Resizes, about the center.
+		{"make the API box bigger", "update api 80,90 200x100 #a5d8ff/#1971c2"},
+		{"make API a lot larger", "update api 60,80 240x120 #a5d8ff/#1971c2"},
+		{"make the API box smaller", "update api 116,108 128x64 #a5d8ff/#1971c2"},
+		// Text and arrows have no box to scale.
+		{"make notes bigger", ""},
+		{"make the calls arrow bigger", ""},
+
+		// Recolors from the prompt palette.
+		{"make the API box red", "update api 100,100 160x80 #ffc9c9/#e03131"},
+		{"turn pricing page green", "update pricing 400,100 120x80 #b2f2bb/#2f9e44"},
+		{"color the notes blue", "update notes 100,300 0x0 /#1971c2"},
+		{"make the API box sparkly", ""},
+
+		// Deletes.
+		{"delete the API box", "delete api"},
+		{"remove the calls arrow", "delete flow"},
+		{"erase notes!", "delete notes"},
+
+		// Targets that aren't exactly one live element.
+		{"delete the login box", ""},
+		{"delete the old box", ""},
+		{"delete the database", ""},
+		{"delete the API box and the pricing page", ""},
+		{"delete everything", ""},
+
+		// Outside the grammar.
+		{"add a box called Billing", ""},
+		{"connect the API to the pricing page", ""},
+		{"undo that", ""},
+	}
+	board := parseElements(t, fastPathBoard)
+	for _, tt := range corpus {
+		t.Run(tt.instruction, func(t *testing.T) {
+			action, ok := FastPathAction(tt.instruction, board)
+			if got := describeFastPath(action, ok); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
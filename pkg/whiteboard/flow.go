@@ -0,0 +1,186 @@
+package whiteboard
+
+import (
+	"math"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard/layout"
+)
+
+// maxLayoutShifts bounds how often LayoutAdded moves a laid-out chart past
+// elements it would cover.
+const maxLayoutShifts = 20
+
+// LayoutAdded lays out the shapes an add action connects with arrows using
+// layout.LayoutFlow, replacing the coordinates the model guessed. Elements on
+// the board never move: when an added arrow joins the new shapes to one of
+// them, the chart is placed below it, or above it when the arrow points
+// into it, and otherwise where the model put it. The chart is then moved
+// down, or right, until it covers no element on the board, and arrows to
+// board elements are rerouted. Frames, bound text and lines without ends
+// are left where they are; FitFrames sizes unsized frames afterwards.
+// Actions without arrows to lay out along are returned unchanged.
+func LayoutAdded(action *llm.WhiteboardAction, board []llm.Element) *llm.WhiteboardAction {
+	if action.Action != llm.ActionAdd {
+		return action
+	}
+
+	existing := make(map[string]llm.Element, len(board))
+	for _, element := range board {
+		if element.ID != "" && !isDeleted(element) {
+			existing[element.ID] = element
+		}
+	}
+	var nodes, arrows []int
+	added := make(map[string]int)
+	for i, element := range action.Elements {
+		switch {
+		case isConnector(element):
+			if element.Start != nil && element.End != nil {
+				arrows = append(arrows, i)
+			}
+		case element.Type == FrameType || extraString(element.Extra, "containerId") != "":
+		default:
+			if element.ID != "" {
+				added[element.ID] = len(nodes)
+			}
+			nodes = append(nodes, i)
+		}
+	}
+	connected := false
+	for _, i := range arrows {
+		_, fromAdded := added[action.Elements[i].Start.ID]
+		_, toAdded := added[action.Elements[i].End.ID]
+		if fromAdded || toAdded {
+			connected = true
+			break
+		}
+	}
+	if len(nodes) == 0 || !connected {
+		return action
+	}
+
+	shapes := make([]llm.Element, len(nodes))
+	for n, i := range nodes {
+		shapes[n] = action.Elements[i]
+	}
+	connectors := make([]llm.Element, len(arrows))
+	for n, i := range arrows {
+		connectors[n] = action.Elements[i]
+	}
+	laidOut := layout.LayoutFlow(shapes, connectors)
+	shapes, connectors = laidOut[:len(nodes)], laidOut[len(nodes):]
+
+	// Anchor on the first board element an added arrow joins the chart to.
+	below := true
+	for _, arrow := range connectors {
+		from, fromBoard := existing[arrow.Start.ID]
+		to, toBoard := existing[arrow.End.ID]
+		if n, ok := added[arrow.End.ID]; ok && fromBoard {
+			shift(shapes, from.X+from.Width/2-(shapes[n].X+shapes[n].Width/2), from.Y+from.Height+layout.LayerGap-shapes[n].Y)
+			break
+		}
+		if n, ok := added[arrow.Start.ID]; ok && toBoard {
+			shift(shapes, to.X+to.Width/2-(shapes[n].X+shapes[n].Width/2), to.Y-layout.LayerGap-(shapes[n].Y+shapes[n].Height))
+			below = false
+			break
+		}
+	}
+	avoidBoard(shapes, existing, below)
+
+	for n, arrow := range connectors {
+		from, ok := shapeByID(arrow.Start.ID, shapes, added, existing)
+		if !ok {
+			continue
+		}
+		to, ok := shapeByID(arrow.End.ID, shapes, added, existing)
+		if !ok || arrow.Start.ID == arrow.End.ID {
+			continue
+		}
+		connectors[n] = layout.Route(arrow, from, to)
+	}
+
+	laid := *action
+	laid.Elements = append([]llm.Element(nil), action.Elements...)
+	for n, i := range nodes {
+		laid.Elements[i] = shapes[n]
+	}
+	for n, i := range arrows {
+		laid.Elements[i] = connectors[n]
+	}
+	return &laid
+}
+
+// avoidBoard moves shapes past the board elements their bounding box covers:
+// down when the chart hangs below an element, right otherwise.
+func avoidBoard(shapes []llm.Element, existing map[string]llm.Element, below bool) {
+	for range maxLayoutShifts {
+		minX, minY, maxX, maxY := elementBounds(shapes[0])
+		for _, shape := range shapes[1:] {
+			x1, y1, x2, y2 := elementBounds(shape)
+			minX, minY = math.Min(minX, x1), math.Min(minY, y1)
+			maxX, maxY = math.Max(maxX, x2), math.Max(maxY, y2)
+		}
+
+		pastX, pastY, overlaps := math.Inf(-1), math.Inf(-1), false
+		for _, element := range existing {
+			if isConnector(element) || element.Type == FrameType {
+				continue
+			}
+			x1, y1, x2, y2 := elementBounds(element)
+			if x1 >= maxX || x2 <= minX || y1 >= maxY || y2 <= minY {
+				continue
+			}
+			overlaps = true
+			pastX, pastY = math.Max(pastX, x2), math.Max(pastY, y2)
+		}
+		if !overlaps {
+			return
+		}
+		if below {
+			shift(shapes, 0, pastY+layout.LayerGap-minY)
+		} else {
+			shift(shapes, pastX+layout.NodeGap-minX, 0)
+		}
+	}
+}
+
+func shift(shapes []llm.Element, dx float64, dy float64) {
+	for i := range shapes {
+		shapes[i].X += dx
+		shapes[i].Y += dy
+	}
+}
+
+func shapeByID(id string, shapes []llm.Element, added map[string]int, existing map[string]llm.Element) (llm.Element, bool) {
+	if n, ok := added[id]; ok {
+		return shapes[n], true
+	}
+	element, ok := existing[id]
+	return element, ok
+}
+
+// PlaceBelow moves elements as one, so the top-left corner of their bounding
+// box sits layout.LayerGap below the elements on board, lined up with their
+// left edge. On an empty board they are left where they are.
+func PlaceBelow(elements []llm.Element, board []llm.Element) []llm.Element {
+	placed := append([]llm.Element(nil), elements...)
+	boardX, boardBottom := math.Inf(1), math.Inf(-1)
+	for _, element := range board {
+		if isDeleted(element) {
+			continue
+		}
+		x1, _, _, y2 := elementBounds(element)
+		boardX, boardBottom = math.Min(boardX, x1), math.Max(boardBottom, y2)
+	}
+	if len(placed) == 0 || math.IsInf(boardX, 1) {
+		return placed
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	for _, element := range placed {
+		x1, y1, _, _ := elementBounds(element)
+		minX, minY = math.Min(minX, x1), math.Min(minY, y1)
+	}
+	shift(placed, boardX-minX, boardBottom+layout.LayerGap-minY)
+	return placed
+}
@@ -0,0 +1,107 @@
+package whiteboard
+
+import (
+	"math"
+
+	"draw/pkg/llm"
+)
+
+// FrameType is the Excalidraw element type of frames.
+const FrameType = "frame"
+
+// framePadding is the space FitFrames leaves between a frame and its
+// children.
+const framePadding = 20
+
+// FitFrames sizes the frames an add or replace action creates without a
+// width or height to surround their children: the elements in the action
+// whose frameId names the frame, and the ones its children list names,
+// whether added by the action or on the board. Frames with a size, or
+// without children to fit, are left as they are, and actions with nothing
+// to fit are returned unchanged.
+func FitFrames(action *llm.WhiteboardAction, board []llm.Element) *llm.WhiteboardAction {
+	if action.Action != llm.ActionAdd && action.Action != llm.ActionReplace {
+		return action
+	}
+
+	byID := make(map[string]llm.Element, len(board)+len(action.Elements))
+	if action.Action == llm.ActionAdd {
+		for _, element := range board {
+			byID[element.ID] = element
+		}
+	}
+	for _, element := range action.Elements {
+		if element.ID != "" {
+			byID[element.ID] = element
+		}
+	}
+
+	var fitted *llm.WhiteboardAction
+	for i, frame := range action.Elements {
+		if frame.Type != FrameType || frame.ID == "" || (frame.Width != 0 && frame.Height != 0) {
+			continue
+		}
+		var children []llm.Element
+		for _, element := range action.Elements {
+			if element.FrameID == frame.ID {
+				children = append(children, element)
+			}
+		}
+		for _, id := range frame.Children {
+			if child, ok := byID[id]; ok && child.FrameID != frame.ID {
+				children = append(children, child)
+			}
+		}
+		if len(children) == 0 {
+			continue
+		}
+
+		minX, minY, maxX, maxY := elementBounds(children[0])
+		for _, child := range children[1:] {
+			x1, y1, x2, y2 := elementBounds(child)
+			minX, minY = math.Min(minX, x1), math.Min(minY, y1)
+			maxX, maxY = math.Max(maxX, x2), math.Max(maxY, y2)
+		}
+		if fitted == nil {
+			copied := *action
+			copied.Elements = append([]llm.Element(nil), action.Elements...)
+			fitted = &copied
+		}
+		fitted.Elements[i].X = minX - framePadding
+		fitted.Elements[i].Y = minY - framePadding
+		fitted.Elements[i].Width = maxX - minX + 2*framePadding
+		fitted.Elements[i].Height = maxY - minY + 2*framePadding
+	}
+	if fitted == nil {
+		return action
+	}
+	return fitted
+}
+
+// elementBounds returns an element's corners; arrows and lines may have a
+// negative width or height.
+func elementBounds(element llm.Element) (minX, minY, maxX, maxY float64) {
+	x1, x2 := element.X, element.X+element.Width
+	y1, y2 := element.Y, element.Y+element.Height
+	return math.Min(x1, x2), math.Min(y1, y2), math.Max(x1, x2), math.Max(y1, y2)
+}
+
+// adoptFrameChildren puts the elements each frame lists as children into
+// it, then drops the lists, which clients don't store.
+func adoptFrameChildren(elements []llm.Element) {
+	index := make(map[string]int, len(elements))
+	for i, element := range elements {
+		index[element.ID] = i
+	}
+	for i := range elements {
+		if elements[i].Type != FrameType || len(elements[i].Children) == 0 {
+			continue
+		}
+		for _, id := range elements[i].Children {
+			if j, ok := index[id]; ok && j != i {
+				elements[j].FrameID = elements[i].ID
+			}
+		}
+		elements[i].Children = nil
+	}
+}
@@ -0,0 +1,72 @@
+package whiteboard
+
+import (
+	"fmt"
+
+	"draw/pkg/llm"
+)
+
+// minGuardedBoard is the fewest elements a board needs before the share of it
+// an action touches is checked, so clearing a board of one or two elements
+// isn't held up.
+const minGuardedBoard = 3
+
+// DestructiveLimits bound how much of the board one instruction may delete or
+// rewrite before the user has to confirm it. The zero value allows anything.
+type DestructiveLimits struct {
+	// MaxDeletes is how many elements one delete may remove; 0 means any
+	// number.
+	MaxDeletes int
+	// MaxDeleteRatio is the share of the board's elements one delete may
+	// remove; 0 means any share.
+	MaxDeleteRatio float64
+	// ConfirmRewrite holds updates that change every element on the board.
+	ConfirmRewrite bool
+}
+
+// Check returns why action touches too much of board to apply without
+// confirmation, or "" when it doesn't. Clear and replace count as deleting
+// every element. Elements already deleted, and IDs not on the board, don't
+// count.
+func (l DestructiveLimits) Check(action *llm.WhiteboardAction, board []llm.Element) string {
+	live := make(map[string]bool, len(board))
+	for _, element := range board {
+		if element.ID != "" && !isDeleted(element) {
+			live[element.ID] = true
+		}
+	}
+
+	switch action.Action {
+	case llm.ActionDelete, llm.ActionClear, llm.ActionReplace:
+		removed := len(live)
+		if action.Action == llm.ActionDelete {
+			removed = countLive(action.DeleteIDs, live)
+		}
+		if l.MaxDeletes > 0 && removed > l.MaxDeletes {
+			return fmt.Sprintf("deletes %d elements, more than the %d allowed at once", removed, l.MaxDeletes)
+		}
+		if l.MaxDeleteRatio > 0 && len(live) >= minGuardedBoard && float64(removed) > l.MaxDeleteRatio*float64(len(live)) {
+			return fmt.Sprintf("deletes %d of the %d elements on the board", removed, len(live))
+		}
+	case llm.ActionUpdate:
+		ids := make([]string, 0, len(action.Elements))
+		for _, element := range action.Elements {
+			ids = append(ids, element.ID)
+		}
+		if l.ConfirmRewrite && len(live) >= minGuardedBoard && countLive(ids, live) == len(live) {
+			return fmt.Sprintf("changes all %d elements on the board", len(live))
+		}
+	}
+	return ""
+}
+
+// countLive counts the distinct ids that are in live.
+func countLive(ids []string, live map[string]bool) int {
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if live[id] {
+			seen[id] = true
+		}
+	}
+	return len(seen)
+}
@@ -0,0 +1,83 @@
+package whiteboard
+
+import (
+	"fmt"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+// guardBoard returns a board of n live rectangles, e1 to en, and a deleted
+// one, gone.
+func guardBoard(n int) []llm.Element {
+	board := make([]llm.Element, 0, n+1)
+	for i := 1; i <= n; i++ {
+		board = append(board, llm.Element{ID: fmt.Sprintf("e%d", i), Type: "rectangle"})
+	}
+	deleted := llm.Element{ID: "gone", Type: "rectangle"}
+	setExtra(&deleted, "isDeleted", []byte("true"))
+	return append(board, deleted)
+}
+
+func deleteOf(ids ...string) *llm.WhiteboardAction {
+	return &llm.WhiteboardAction{Action: llm.ActionDelete, DeleteIDs: ids}
+}
+
+func TestDestructiveLimits(t *testing.T) {
+	limits := DestructiveLimits{MaxDeletes: 3, MaxDeleteRatio: 0.5, ConfirmRewrite: true}
+	everyID := func(n int) []string {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("e%d", i+1)
+		}
+		return ids
+	}
+	rewrite := func(ids ...string) *llm.WhiteboardAction {
+		action := &llm.WhiteboardAction{Action: llm.ActionUpdate}
+		for _, id := range ids {
+			action.Elements = append(action.Elements, llm.Element{ID: id, Type: "rectangle"})
+		}
+		return action
+	}
+
+	tests := []struct {
+		name string
+		// limits replace the ones above when set.
+		limits *DestructiveLimits
+		action *llm.WhiteboardAction
+		board  int
+		held   bool
+	}{
+		{name: "at the count limit", action: deleteOf("e1", "e2", "e3"), board: 10},
+		{name: "past the count limit", action: deleteOf("e1", "e2", "e3", "e4"), board: 10, held: true},
+		{name: "duplicates count once", action: deleteOf("e1", "e1", "e2", "e2", "e3"), board: 10},
+		{name: "deleted and unknown IDs don't count", action: deleteOf("e1", "e2", "e3", "gone", "nope"), board: 10},
+		{name: "at the ratio", action: deleteOf("e1", "e2"), board: 4},
+		{name: "past the ratio", action: deleteOf("e1", "e2", "e3"), board: 4, held: true},
+		{name: "ratio ignores deleted elements", action: deleteOf("e1", "e2"), board: 3, held: true},
+		{name: "small boards skip the ratio", action: deleteOf("e1", "e2"), board: 2},
+		{name: "small boards keep the count", limits: &DestructiveLimits{MaxDeletes: 1}, action: deleteOf("e1", "e2"), board: 2, held: true},
+		{name: "clear counts the board", action: &llm.WhiteboardAction{Action: llm.ActionClear}, board: 4, held: true},
+		{name: "clear of a small board", action: &llm.WhiteboardAction{Action: llm.ActionClear}, board: 2},
+		{name: "clear of an empty board", action: &llm.WhiteboardAction{Action: llm.ActionClear}, board: 0},
+		{name: "replace counts the board", action: &llm.WhiteboardAction{Action: llm.ActionReplace}, board: 4, held: true},
+		{name: "update of every element", action: rewrite(everyID(4)...), board: 4, held: true},
+		{name: "update of all but one", action: rewrite(everyID(3)...), board: 4},
+		{name: "update of a small board", action: rewrite(everyID(2)...), board: 2},
+		{name: "rewrites allowed", limits: &DestructiveLimits{MaxDeletes: 3}, action: rewrite(everyID(4)...), board: 4},
+		{name: "zero value allows anything", limits: &DestructiveLimits{}, action: &llm.WhiteboardAction{Action: llm.ActionClear}, board: 100},
+		{name: "adds are never held", action: &llm.WhiteboardAction{Action: llm.ActionAdd, Elements: make([]llm.Element, 50)}, board: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := limits
+			if tt.limits != nil {
+				l = *tt.limits
+			}
+			reason := l.Check(tt.action, guardBoard(tt.board))
+			if held := reason != ""; held != tt.held {
+				t.Errorf("Check = %q, want held %v", reason, tt.held)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package whiteboard
+
+import (
+	"sort"
+	"strings"
+)
+
+// Intents an instruction is classified into for analytics.
+const (
+	IntentAdd     = "add"
+	IntentUpdate  = "update"
+	IntentMove    = "move"
+	IntentStyle   = "style"
+	IntentConnect = "connect"
+	IntentDelete  = "delete"
+	IntentComment = "comment"
+	IntentUndo    = "undo"
+	IntentOther   = "other"
+)
+
+// intentVerbs maps the verbs instructions usually start with to an intent.
+// The first verb found in the instruction wins.
+var intentVerbs = map[string]string{
+	"add": IntentAdd, "draw": IntentAdd, "create": IntentAdd, "insert": IntentAdd, "put": IntentAdd, "place": IntentAdd, "sketch": IntentAdd,
+	"change": IntentUpdate, "rename": IntentUpdate, "update": IntentUpdate, "edit": IntentUpdate, "replace": IntentUpdate, "set": IntentUpdate, "label": IntentUpdate, "write": IntentUpdate,
+	"move": IntentMove, "align": IntentMove, "resize": IntentMove, "shift": IntentMove, "arrange": IntentMove, "center": IntentMove, "rotate": IntentMove,
+	"bring": IntentMove, "send": IntentMove, "raise": IntentMove, "lower": IntentMove,
+	"color": IntentStyle, "colour": IntentStyle, "style": IntentStyle, "highlight": IntentStyle, "fill": IntentStyle, "bold": IntentStyle, "copy": IntentStyle,
+	"connect": IntentConnect, "link": IntentConnect, "join": IntentConnect, "arrow": IntentConnect,
+	"delete": IntentDelete, "remove": IntentDelete, "erase": IntentDelete, "clear": IntentDelete,
+	"comment": IntentComment, "note": IntentComment,
+	"undo": IntentUndo, "revert": IntentUndo,
+}
+
+// ClassifyIntent buckets an instruction by what it asks for, from the verbs
+// it uses. It works on the instruction alone, so failed instructions are
+// classified the same way as successful ones.
+func ClassifyIntent(instruction string) string {
+	if _, ok := ParseCommentIntent(instruction); ok {
+		return IntentComment
+	}
+	for _, word := range tokenize(instruction) {
+		if intent, ok := intentVerbs[word]; ok {
+			return intent
+		}
+	}
+	return IntentOther
+}
+
+// patternStopwords are dropped when normalizing instructions; they carry
+// nothing that tells two failing instructions apart.
+var patternStopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "please": true, "to": true, "of": true, "and": true,
+	"it": true, "this": true, "that": true, "on": true, "in": true, "for": true, "my": true,
+	"some": true, "can": true, "you": true, "i": true, "me": true, "us": true, "with": true,
+}
+
+// NormalizeInstruction lowercases an instruction, drops stopwords and
+// replaces numbers with "#", so "Add 3 boxes" and "add 12 boxes" match.
+func NormalizeInstruction(instruction string) []string {
+	var words []string
+	for _, word := range tokenize(instruction) {
+		if patternStopwords[word] {
+			continue
+		}
+		if strings.IndexFunc(word, func(r rune) bool { return r < '0' || r > '9' }) < 0 {
+			word = "#"
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// InstructionPattern is a group of instructions sharing a normalized n-gram.
+type InstructionPattern struct {
+	Pattern string
+	// Members are the indexes of the instructions in the group.
+	Members []int
+}
+
+// ClusterInstructions groups instructions by their most common normalized
+// bigram: each instruction joins the group of whichever of its bigrams
+// occurs in the most instructions. One-word instructions are grouped by that
+// word. Groups are returned largest first.
+func ClusterInstructions(instructions []string) []InstructionPattern {
+	grams := make([][]string, len(instructions))
+	frequency := make(map[string]int)
+	for i, instruction := range instructions {
+		grams[i] = instructionGrams(NormalizeInstruction(instruction))
+		seen := make(map[string]bool)
+		for _, gram := range grams[i] {
+			if !seen[gram] {
+				seen[gram] = true
+				frequency[gram]++
+			}
+		}
+	}
+
+	groups := make(map[string]*InstructionPattern)
+	for i, candidates := range grams {
+		best := ""
+		for _, gram := range candidates {
+			if best == "" || frequency[gram] > frequency[best] {
+				best = gram
+			}
+		}
+		if best == "" {
+			continue
+		}
+		group, ok := groups[best]
+		if !ok {
+			group = &InstructionPattern{Pattern: best}
+			groups[best] = group
+		}
+		group.Members = append(group.Members, i)
+	}
+
+	patterns := make([]InstructionPattern, 0, len(groups))
+	for _, group := range groups {
+		patterns = append(patterns, *group)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i].Members) != len(patterns[j].Members) {
+			return len(patterns[i].Members) > len(patterns[j].Members)
+		}
+		return patterns[i].Pattern < patterns[j].Pattern
+	})
+	return patterns
+}
+
+func instructionGrams(words []string) []string {
+	if len(words) == 1 {
+		return words
+	}
+	grams := make([]string, 0, len(words))
+	for i := 0; i+1 < len(words); i++ {
+		grams = append(grams, words[i]+" "+words[i+1])
+	}
+	return grams
+}
@@ -0,0 +1,146 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"draw/pkg/llm"
+)
+
+// ErrNotInvertible is returned by Invert for actions that change nothing on
+// the board, or that aren't resolved into ones that do.
+var ErrNotInvertible = errors.New("action cannot be undone")
+
+// Invert returns the action that undoes action, given the board it was
+// applied to: an add becomes a delete of the elements it created, a delete
+// an add restoring the elements it removed, and an update an update setting
+// each property it changed back to its prior value. Properties the update
+// gave an element that didn't have them are left. Clear, replace and
+// reorder are undone by restoring the whole prior board, which puts every
+// element back in its place in the stacking order.
+func Invert(action *llm.WhiteboardAction, prior []llm.Element) (*llm.WhiteboardAction, error) {
+	live := make(map[string]llm.Element, len(prior))
+	var liveElements []llm.Element
+	for _, element := range prior {
+		if element.ID != "" && !isDeleted(element) {
+			live[element.ID] = element
+			liveElements = append(liveElements, element)
+		}
+	}
+
+	switch action.Action {
+	case llm.ActionAdd:
+		ids := make([]string, 0, len(action.Elements))
+		for _, element := range action.Elements {
+			if element.ID == "" {
+				return nil, fmt.Errorf("added %s has no ID", element.Type)
+			}
+			ids = append(ids, element.ID)
+		}
+		if len(ids) == 0 {
+			return nil, ErrNotInvertible
+		}
+		return &llm.WhiteboardAction{Action: llm.ActionDelete, DeleteIDs: ids}, nil
+
+	case llm.ActionDelete:
+		var restored []llm.Element
+		for _, id := range action.DeleteIDs {
+			if element, ok := live[id]; ok {
+				restored = append(restored, element)
+			}
+		}
+		if len(restored) == 0 {
+			return nil, ErrNotInvertible
+		}
+		return &llm.WhiteboardAction{Action: llm.ActionAdd, Elements: restored}, nil
+
+	case llm.ActionUpdate:
+		var restored []llm.Element
+		for _, update := range action.Elements {
+			element, ok := live[update.ID]
+			if !ok {
+				continue
+			}
+			previous, err := priorValues(element, update)
+			if err != nil {
+				return nil, fmt.Errorf("failed to invert update of %s: %w", update.ID, err)
+			}
+			restored = append(restored, previous)
+		}
+		if len(restored) == 0 {
+			return nil, ErrNotInvertible
+		}
+		return &llm.WhiteboardAction{Action: llm.ActionUpdate, Elements: restored}, nil
+
+	case llm.ActionClear, llm.ActionReplace, llm.ActionReorder:
+		if action.Action == llm.ActionReorder {
+			// Reorder indexes the board first; the restored elements need
+			// the indices they had then to sort back into place.
+			synced, err := SyncIndices(prior)
+			if err != nil {
+				return nil, err
+			}
+			liveElements = liveElements[:0]
+			for _, element := range synced {
+				if element.ID != "" && !isDeleted(element) {
+					liveElements = append(liveElements, element)
+				}
+			}
+		}
+		if len(liveElements) > 0 {
+			return &llm.WhiteboardAction{Action: llm.ActionReplace, Elements: liveElements}, nil
+		}
+		if action.Action == llm.ActionClear {
+			return nil, ErrNotInvertible
+		}
+		return Invert(&llm.WhiteboardAction{Action: llm.ActionAdd, Elements: action.Elements}, nil)
+
+	default:
+		return nil, ErrNotInvertible
+	}
+}
+
+// priorValues returns element cut down to its ID, its type and the
+// properties update sets that it had, as an update restoring them.
+func priorValues(element llm.Element, update llm.Element) (llm.Element, error) {
+	var before, changed map[string]json.RawMessage
+	data, err := json.Marshal(element)
+	if err != nil {
+		return llm.Element{}, err
+	}
+	if err := json.Unmarshal(data, &before); err != nil {
+		return llm.Element{}, err
+	}
+	if data, err = json.Marshal(update); err != nil {
+		return llm.Element{}, err
+	}
+	if err := json.Unmarshal(data, &changed); err != nil {
+		return llm.Element{}, err
+	}
+
+	fields := map[string]json.RawMessage{"id": before["id"], "type": before["type"]}
+	for key := range changed {
+		if value, ok := before[key]; ok {
+			fields[key] = value
+		}
+	}
+	if data, err = json.Marshal(fields); err != nil {
+		return llm.Element{}, err
+	}
+	var previous llm.Element
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return llm.Element{}, err
+	}
+	return previous, nil
+}
+
+var undoIntentPattern = regexp.MustCompile(`(?i)^(?:please\s+)?(?:undo|revert|take\s+back)(?:\s+(?:that|this|it|the\s+last\s+(?:one|change|step|thing)))?(?:\s+please)?[.!]?$`)
+
+// ParseUndoIntent recognises "undo that", "undo" and "revert the last
+// change" so they can be handled without the whiteboard prompt.
+func ParseUndoIntent(instruction string) bool {
+	return undoIntentPattern.MatchString(strings.TrimSpace(instruction))
+}
@@ -0,0 +1,114 @@
+package whiteboard
+
+import (
+	"math"
+
+	"draw/pkg/llm"
+)
+
+// maxLabelWidth is how wide FitLabels lets a label's text run before
+// wrapping it, unless its shape leaves more room.
+const maxLabelWidth = 240
+
+// labelContainers are the shapes whose labels FitLabels fits.
+var labelContainers = map[string]bool{"rectangle": true, "ellipse": true, "diamond": true}
+
+// FitLabels makes the labels of the shapes an add, update or replace action
+// draws fit them, since the model guesses sizes without knowing how wide
+// text is. Label text is wrapped at maxLabelWidth, or at the width the shape
+// leaves for text when that is wider, and shapes too small for their wrapped
+// label grow, by ContainerSize, to hold it; they never shrink. Sizes the
+// model left out are left to the layout. An update is fitted to the element
+// it updates, and only when it sets a label. Stickies, which ExpandStickies
+// sizes, and arrow labels are left as they are. Actions with nothing to fit
+// are returned unchanged.
+func FitLabels(action *llm.WhiteboardAction, board []llm.Element) *llm.WhiteboardAction {
+	if action.Action != llm.ActionAdd && action.Action != llm.ActionUpdate && action.Action != llm.ActionReplace {
+		return action
+	}
+
+	var byID map[string]llm.Element
+	if action.Action == llm.ActionUpdate {
+		byID = make(map[string]llm.Element, len(board))
+		for _, element := range board {
+			if element.ID != "" && !isDeleted(element) {
+				byID[element.ID] = element
+			}
+		}
+	}
+
+	var fitted *llm.WhiteboardAction
+	for i, element := range action.Elements {
+		if element.Label == nil || element.Label.Text == "" || IsSticky(element) {
+			continue
+		}
+		shape := element
+		if action.Action == llm.ActionUpdate {
+			original, ok := byID[element.ID]
+			if !ok || IsSticky(original) {
+				continue
+			}
+			merged, err := mergeElement(original, element)
+			if err != nil {
+				continue
+			}
+			shape = merged
+		}
+		if !labelContainers[shape.Type] {
+			continue
+		}
+
+		fit := fitLabel(shape)
+		if fit.Label.Text == element.Label.Text && fit.Width == shape.Width && fit.Height == shape.Height {
+			continue
+		}
+		if fitted == nil {
+			copied := *action
+			copied.Elements = append([]llm.Element(nil), action.Elements...)
+			fitted = &copied
+		}
+		label := *element.Label
+		label.Text = fit.Label.Text
+		fitted.Elements[i].Label = &label
+		if fit.Width != shape.Width {
+			fitted.Elements[i].Width = fit.Width
+		}
+		if fit.Height != shape.Height {
+			fitted.Elements[i].Height = fit.Height
+		}
+	}
+	if fitted == nil {
+		return action
+	}
+	return fitted
+}
+
+// fitLabel wraps shape's label and grows shape to hold it.
+func fitLabel(shape llm.Element) llm.Element {
+	label := *shape.Label
+	fontSize := fontSizeOf(label.FontSize)
+	label.Text = WrapText(label.Text, fontSize, defaultFontFamily, math.Max(maxLabelWidth, labelWidth(shape.Type, shape.Width)))
+	shape.Label = &label
+
+	textWidth, textHeight := MeasureText(label.Text, fontSize, defaultFontFamily)
+	width, height := ContainerSize(shape.Type, textWidth, textHeight)
+	if shape.Width > 0 {
+		shape.Width = math.Max(shape.Width, width)
+	}
+	if shape.Height > 0 {
+		shape.Height = math.Max(shape.Height, height)
+	}
+	return shape
+}
+
+// labelWidth returns how wide text bound to a container of containerType
+// and width may run, as Excalidraw computes it.
+func labelWidth(containerType string, width float64) float64 {
+	switch containerType {
+	case "ellipse":
+		width = math.Round(width / 2 * math.Sqrt2)
+	case "diamond":
+		width = math.Round(width / 2)
+	}
+	return width - 2*boundTextPadding
+}
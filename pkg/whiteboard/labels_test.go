@@ -0,0 +1,169 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+func parseAction(t *testing.T, raw string) *llm.WhiteboardAction {
+	t.Helper()
+	var action llm.WhiteboardAction
+	if err := json.Unmarshal([]byte(raw), &action); err != nil {
+		t.Fatalf("failed to parse action: %v", err)
+	}
+	return &action
+}
+
+func TestFitLabels(t *testing.T) {
+	// "Login" is 56 by 25 at the default size, so a rectangle needs 66 by 35
+	// to hold it and an ellipse 93 by 49.
+	board := `[
+		{"id":"small","type":"rectangle","x":0,"y":0,"width":40,"height":20},
+		{"id":"big","type":"rectangle","x":0,"y":0,"width":300,"height":100,"label":{"text":"Old"}},
+		{"id":"note","type":"rectangle","x":0,"y":0,"width":40,"height":20,"customData":{"sticky":true}}
+	]`
+	tests := []struct {
+		name   string
+		action string
+		// width and height of the first element after fitting, or unchanged
+		// when the action must be returned as it is.
+		width, height float64
+		text          string
+		unchanged     bool
+	}{
+		{
+			name:   "grows a small rectangle",
+			action: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":40,"height":20,"label":{"text":"Login"}}]}`,
+			width:  66, height: 35, text: "Login",
+		},
+		{
+			name:   "grows an ellipse more",
+			action: `{"action":"add","elements":[{"id":"a","type":"ellipse","x":0,"y":0,"width":40,"height":20,"label":{"text":"Login"}}]}`,
+			width:  93, height: 49, text: "Login",
+		},
+		{
+			name:   "grows only what is too small",
+			action: `{"action":"replace","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":200,"height":20,"label":{"text":"Login"}}]}`,
+			width:  200, height: 35, text: "Login",
+		},
+		{
+			name:   "larger font",
+			action: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":40,"height":20,"label":{"text":"Login","fontSize":40}}]}`,
+			width:  122, height: 60, text: "Login",
+		},
+		{
+			name:   "multi-line label",
+			action: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":40,"height":20,"label":{"text":"Login\nor\nsign up"}}]}`,
+			width:  82, height: 85, text: "Login\nor\nsign up",
+		},
+		{
+			name:   "wraps at the maximum width",
+			action: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":40,"label":{"text":"send the password reset email to the user"}}]}`,
+			width:  208, height: 85, text: "send the password\nreset email to the\nuser",
+		},
+		{
+			name:      "a wide shape keeps the label on one line",
+			action:    `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":500,"height":40,"label":{"text":"send the password reset email to the user"}}]}`,
+			unchanged: true,
+		},
+		{
+			name:   "cjk",
+			action: `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":40,"height":20,"label":{"text":"用户登录流程图开始到结束的每一个步骤"}}]}`,
+			width:  250, height: 60, text: "用户登录流程图开始到结束\n的每一个步骤",
+		},
+		{
+			name:      "sizes left out stay out",
+			action:    `{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"label":{"text":"Login"}}]}`,
+			unchanged: true,
+		},
+		{
+			name:   "update setting a label fits the element on board",
+			action: `{"action":"update","elements":[{"id":"small","type":"rectangle","x":0,"y":0,"label":{"text":"Login"}}]}`,
+			width:  66, height: 35, text: "Login",
+		},
+		{
+			name:      "update to a shape that fits",
+			action:    `{"action":"update","elements":[{"id":"big","type":"rectangle","x":0,"y":0,"label":{"text":"Login"}}]}`,
+			unchanged: true,
+		},
+		{
+			name:      "update without a label",
+			action:    `{"action":"update","elements":[{"id":"small","type":"rectangle","x":0,"y":0,"backgroundColor":"#ffc9c9"}]}`,
+			unchanged: true,
+		},
+		{
+			name:      "update of an unknown element",
+			action:    `{"action":"update","elements":[{"id":"missing","type":"rectangle","x":0,"y":0,"label":{"text":"Login"}}]}`,
+			unchanged: true,
+		},
+		{
+			name:      "sticky",
+			action:    `{"action":"update","elements":[{"id":"note","type":"rectangle","x":0,"y":0,"label":{"text":"Login"}}]}`,
+			unchanged: true,
+		},
+		{
+			name:      "arrow label",
+			action:    `{"action":"add","elements":[{"id":"a","type":"arrow","x":0,"y":0,"width":10,"height":0,"label":{"text":"a very long arrow label that would not fit"}}]}`,
+			unchanged: true,
+		},
+		{
+			name:      "delete",
+			action:    `{"action":"delete","delete_ids":["small"]}`,
+			unchanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := parseAction(t, tt.action)
+			before, _ := json.Marshal(action)
+			fitted := FitLabels(action, parseElements(t, board))
+
+			if after, _ := json.Marshal(action); string(after) != string(before) {
+				t.Errorf("FitLabels changed the action it was given:\n%s\nwas\n%s", after, before)
+			}
+			if tt.unchanged {
+				if fitted != action {
+					t.Errorf("FitLabels returned a new action, want it unchanged: %+v", fitted.Elements)
+				}
+				return
+			}
+
+			element := fitted.Elements[0]
+			if element.Width != tt.width || element.Height != tt.height {
+				t.Errorf("size = %v x %v, want %v x %v", element.Width, element.Height, tt.width, tt.height)
+			}
+			if element.Label.Text != tt.text {
+				t.Errorf("label = %q, want %q", element.Label.Text, tt.text)
+			}
+			for _, line := range strings.Split(element.Label.Text, "\n") {
+				if width, _ := MeasureText(line, fontSizeOf(element.Label.FontSize), defaultFontFamily); width > labelWidth(element.Type, element.Width) {
+					t.Errorf("line %q is %v wide, more than the %v the shape leaves", line, width, labelWidth(element.Type, element.Width))
+				}
+			}
+		})
+	}
+}
+
+func TestFitLabelsUpdateSetsOnlySize(t *testing.T) {
+	board := parseElements(t, `[{"id":"small","type":"rectangle","x":0,"y":0,"width":40,"height":20,"backgroundColor":"#a5d8ff"}]`)
+	fitted := FitLabels(parseAction(t, `{"action":"update","elements":[{"id":"small","type":"rectangle","x":0,"y":0,"label":{"text":"Login"}}]}`), board)
+
+	data, err := json.Marshal(fitted.Elements[0])
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if _, ok := fields["backgroundColor"]; ok {
+		t.Errorf("update sets backgroundColor, want only what it set and the size: %s", data)
+	}
+	if fields["width"] != float64(66) || fields["height"] != float64(35) {
+		t.Errorf("update = %s, want a size of 66 by 35", data)
+	}
+}
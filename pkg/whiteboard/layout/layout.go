@@ -0,0 +1,346 @@
+// Package layout arranges flowcharts: shapes connected by arrows are put in
+// layers along the arrows, top to bottom, so they are evenly spaced and
+// never overlap, however the model guessed their coordinates.
+package layout
+
+import (
+	"math"
+	"sort"
+
+	"draw/pkg/llm"
+)
+
+// Spacing between laid-out shapes, and the size given to shapes without one.
+const (
+	LayerGap      = 80
+	NodeGap       = 60
+	defaultWidth  = 160
+	defaultHeight = 80
+	// ElbowGap is how far elbow arrows that go around shapes stay clear of
+	// them.
+	ElbowGap = 30
+)
+
+// LayoutFlow lays out a flowchart. elements are its shapes and arrows the
+// arrows between them; an arrow is an edge when its start and end name two
+// of the shapes by ID. Shapes are put in layers by the longest path of edges
+// leading to them, ordered within a layer to follow the shapes above them,
+// and spaced LayerGap apart vertically and NodeGap apart horizontally, with
+// each layer centered on the widest. Shapes without a size get a default
+// one. The layout keeps the top-left corner of the shapes' bounding box, so
+// the chart stays where it was put.
+//
+// The result holds the shapes, then the arrows, in the order given; arrows
+// that are edges are rerouted between their shapes, others are unchanged.
+func LayoutFlow(elements []llm.Element, arrows []llm.Element) []llm.Element {
+	result := make([]llm.Element, 0, len(elements)+len(arrows))
+	result = append(result, elements...)
+	if len(elements) == 0 {
+		return append(result, arrows...)
+	}
+	nodes := result[:len(elements)]
+
+	originX, originY := math.Inf(1), math.Inf(1)
+	for i := range nodes {
+		originX, originY = math.Min(originX, nodes[i].X), math.Min(originY, nodes[i].Y)
+		if nodes[i].Width <= 0 {
+			nodes[i].Width = defaultWidth
+		}
+		if nodes[i].Height <= 0 {
+			nodes[i].Height = defaultHeight
+		}
+	}
+
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		if node.ID != "" {
+			index[node.ID] = i
+		}
+	}
+	successors := make([][]int, len(nodes))
+	predecessors := make([][]int, len(nodes))
+	for _, edge := range acyclicEdges(edges(arrows, index), len(nodes)) {
+		successors[edge[0]] = append(successors[edge[0]], edge[1])
+		predecessors[edge[1]] = append(predecessors[edge[1]], edge[0])
+	}
+
+	layers := assignLayers(successors, predecessors)
+	orderLayers(layers, predecessors)
+	place(nodes, layers, originX, originY)
+
+	for _, arrow := range arrows {
+		from, fromOK := index[bindingID(arrow.Start)]
+		to, toOK := index[bindingID(arrow.End)]
+		if fromOK && toOK && from != to {
+			arrow = Route(arrow, nodes[from], nodes[to])
+		}
+		result = append(result, arrow)
+	}
+	return result
+}
+
+// Route points arrow from the edge of from facing to to the edge of to
+// facing from: bottom to top when to is lower, top to bottom when it is
+// higher, and side to side when they overlap vertically. The arrow's
+// routing shapes the path between the two. A straight arrow's points, if it
+// has any, become the segment between them. An elbow arrow gets Elbow's
+// waypoints, and a curved one a bend in the middle of the segment.
+func Route(arrow llm.Element, from llm.Element, to llm.Element) llm.Element {
+	fromCX, fromCY := from.X+from.Width/2, from.Y+from.Height/2
+	toCX, toCY := to.X+to.Width/2, to.Y+to.Height/2
+
+	var startX, startY, endX, endY float64
+	switch {
+	case to.Y >= from.Y+from.Height:
+		startX, startY = fromCX, from.Y+from.Height
+		endX, endY = toCX, to.Y
+	case to.Y+to.Height <= from.Y:
+		startX, startY = fromCX, from.Y
+		endX, endY = toCX, to.Y+to.Height
+	case toCX >= fromCX:
+		startX, startY = from.X+from.Width, fromCY
+		endX, endY = to.X, toCY
+	default:
+		startX, startY = from.X, fromCY
+		endX, endY = to.X+to.Width, toCY
+	}
+
+	var points [][]float64
+	switch arrow.Routing {
+	case llm.RoutingElbow:
+		points = Elbow(from, to)
+	case llm.RoutingCurved:
+		points = bend(startX, startY, endX, endY)
+	default:
+		arrow.X, arrow.Y = startX, startY
+		arrow.Width, arrow.Height = endX-startX, endY-startY
+		if len(arrow.Points) > 0 {
+			arrow.Points = [][]float64{{0, 0}, {arrow.Width, arrow.Height}}
+		}
+		return arrow
+	}
+
+	// Points are relative to the first, and the size spans them.
+	arrow.X, arrow.Y = points[0][0], points[0][1]
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, point := range points {
+		point[0], point[1] = point[0]-arrow.X, point[1]-arrow.Y
+		minX, maxX = math.Min(minX, point[0]), math.Max(maxX, point[0])
+		minY, maxY = math.Min(minY, point[1]), math.Max(maxY, point[1])
+	}
+	arrow.Points = points
+	arrow.Width, arrow.Height = maxX-minX, maxY-minY
+	return arrow
+}
+
+// Elbow returns the waypoints, in board coordinates, of a path of
+// horizontal and vertical segments from from to to that crosses neither.
+// It leaves and enters the shapes at right angles through the sides Route
+// picks, turning halfway across the gap between them; when the shapes are
+// side by side without a gap, it goes around them on the side of to, ElbowGap
+// clear of both, and enters to from that side.
+func Elbow(from llm.Element, to llm.Element) [][]float64 {
+	fromCX, fromCY := from.X+from.Width/2, from.Y+from.Height/2
+	toCX, toCY := to.X+to.Width/2, to.Y+to.Height/2
+
+	switch {
+	case to.Y >= from.Y+from.Height, to.Y+to.Height <= from.Y:
+		startY, endY := from.Y+from.Height, to.Y
+		if to.Y+to.Height <= from.Y {
+			startY, endY = from.Y, to.Y+to.Height
+		}
+		if fromCX == toCX {
+			return [][]float64{{fromCX, startY}, {toCX, endY}}
+		}
+		midY := (startY + endY) / 2
+		return [][]float64{{fromCX, startY}, {fromCX, midY}, {toCX, midY}, {toCX, endY}}
+	case toCX >= fromCX && to.X >= from.X+from.Width, toCX < fromCX && to.X+to.Width <= from.X:
+		startX, endX := from.X+from.Width, to.X
+		if toCX < fromCX {
+			startX, endX = from.X, to.X+to.Width
+		}
+		if fromCY == toCY {
+			return [][]float64{{startX, fromCY}, {endX, toCY}}
+		}
+		midX := (startX + endX) / 2
+		return [][]float64{{startX, fromCY}, {midX, fromCY}, {midX, toCY}, {endX, toCY}}
+	case toCX >= fromCX:
+		right := math.Max(from.X+from.Width, to.X+to.Width) + ElbowGap
+		return [][]float64{{from.X + from.Width, fromCY}, {right, fromCY}, {right, toCY}, {to.X + to.Width, toCY}}
+	default:
+		left := math.Min(from.X, to.X) - ElbowGap
+		return [][]float64{{from.X, fromCY}, {left, fromCY}, {left, toCY}, {to.X, toCY}}
+	}
+}
+
+// bend returns the points of a curved arrow from (x1, y1) to (x2, y2): the
+// ends and a middle point set off to the left of the way the arrow runs by
+// a fifth of its length, which clients draw as a smooth curve.
+func bend(x1, y1, x2, y2 float64) [][]float64 {
+	const offset = 0.2
+	dx, dy := x2-x1, y2-y1
+	return [][]float64{
+		{x1, y1},
+		{x1 + dx/2 + dy*offset, y1 + dy/2 - dx*offset},
+		{x2, y2},
+	}
+}
+
+// edges returns the arrows between two distinct shapes as pairs of shape
+// indexes, once each.
+func edges(arrows []llm.Element, index map[string]int) [][2]int {
+	seen := make(map[[2]int]bool)
+	var result [][2]int
+	for _, arrow := range arrows {
+		from, fromOK := index[bindingID(arrow.Start)]
+		to, toOK := index[bindingID(arrow.End)]
+		edge := [2]int{from, to}
+		if !fromOK || !toOK || from == to || seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		result = append(result, edge)
+	}
+	return result
+}
+
+// acyclicEdges drops the edges that close a cycle, found by a depth-first
+// search from the shapes in order, so loops back to an earlier step don't
+// stop the chart from being layered. The dropped arrows are still routed.
+func acyclicEdges(edges [][2]int, n int) [][2]int {
+	adjacent := make([][]int, n)
+	for i, edge := range edges {
+		adjacent[edge[0]] = append(adjacent[edge[0]], i)
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, n)
+	back := make(map[int]bool)
+	var visit func(node int)
+	visit = func(node int) {
+		state[node] = visiting
+		for _, i := range adjacent[node] {
+			switch next := edges[i][1]; state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				back[i] = true
+			}
+		}
+		state[node] = done
+	}
+	for node := range state {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+
+	result := make([][2]int, 0, len(edges))
+	for i, edge := range edges {
+		if !back[i] {
+			result = append(result, edge)
+		}
+	}
+	return result
+}
+
+// assignLayers puts each shape one layer below the lowest shape pointing
+// into it, visiting them in topological order.
+func assignLayers(successors [][]int, predecessors [][]int) [][]int {
+	layer := make([]int, len(successors))
+	remaining := make([]int, len(successors))
+	var queue []int
+	for node := range successors {
+		remaining[node] = len(predecessors[node])
+		if remaining[node] == 0 {
+			queue = append(queue, node)
+		}
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range successors[node] {
+			layer[next] = max(layer[next], layer[node]+1)
+			if remaining[next]--; remaining[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	var layers [][]int
+	for node, l := range layer {
+		for len(layers) <= l {
+			layers = append(layers, nil)
+		}
+		layers[l] = append(layers[l], node)
+	}
+	return layers
+}
+
+// orderLayers sorts each layer below the first, from the top down, by the
+// mean position of the shapes pointing into it, which untangles most
+// crossing arrows.
+func orderLayers(layers [][]int, predecessors [][]int) {
+	position := make(map[int]float64)
+	for _, layer := range layers {
+		for i, node := range layer {
+			position[node] = float64(i)
+		}
+	}
+	for _, layer := range layers[1:] {
+		barycenter := make(map[int]float64, len(layer))
+		for _, node := range layer {
+			sum := 0.0
+			for _, pred := range predecessors[node] {
+				sum += position[pred]
+			}
+			barycenter[node] = sum / float64(len(predecessors[node]))
+		}
+		sort.SliceStable(layer, func(i, j int) bool {
+			return barycenter[layer[i]] < barycenter[layer[j]]
+		})
+		for i, node := range layer {
+			position[node] = float64(i)
+		}
+	}
+}
+
+// place gives every shape a cell as wide as the widest shape and as tall as
+// the tallest in its layer, and centers it there.
+func place(nodes []llm.Element, layers [][]int, originX float64, originY float64) {
+	cellWidth, widest := 0.0, 0
+	for _, node := range nodes {
+		cellWidth = math.Max(cellWidth, node.Width)
+	}
+	for _, layer := range layers {
+		widest = max(widest, len(layer))
+	}
+	span := func(count int) float64 {
+		return float64(count)*cellWidth + float64(count-1)*NodeGap
+	}
+
+	y := originY
+	for _, layer := range layers {
+		rowHeight := 0.0
+		for _, node := range layer {
+			rowHeight = math.Max(rowHeight, nodes[node].Height)
+		}
+		x := originX + (span(widest)-span(len(layer)))/2
+		for _, node := range layer {
+			nodes[node].X = x + (cellWidth-nodes[node].Width)/2
+			nodes[node].Y = y + (rowHeight-nodes[node].Height)/2
+			x += cellWidth + NodeGap
+		}
+		y += rowHeight + LayerGap
+	}
+}
+
+func bindingID(binding *llm.ElementBinding) string {
+	if binding == nil {
+		return ""
+	}
+	return binding.ID
+}
@@ -0,0 +1,95 @@
+package whiteboard
+
+import (
+	"math"
+
+	"draw/pkg/llm"
+)
+
+// absolutePointTolerance is how close a line's first point may be to its x
+// and y for the points to be taken as absolute board coordinates.
+const absolutePointTolerance = 0.5
+
+// NormalizeLinePoints rewrites the points of the lines and arrows an add or
+// replace action creates the way clients expect them: relative to the
+// element's x and y, starting at [0, 0], with width and height spanning them.
+// Models often give the points in board coordinates instead; a first point
+// at the element's own x and y is taken to mean that. Any other offset moves
+// the element so the line stays where the points put it. Points that don't
+// validate are left for ValidateAction to report, and actions with nothing
+// to normalize are returned unchanged.
+func NormalizeLinePoints(action *llm.WhiteboardAction) *llm.WhiteboardAction {
+	if action.Action != llm.ActionAdd && action.Action != llm.ActionReplace {
+		return action
+	}
+
+	var normalized *llm.WhiteboardAction
+	for i, element := range action.Elements {
+		if !isConnector(element) || !validPoints(element.Points) {
+			continue
+		}
+		element, changed := normalizePoints(element)
+		if !changed {
+			continue
+		}
+		if normalized == nil {
+			copied := *action
+			copied.Elements = append([]llm.Element(nil), action.Elements...)
+			normalized = &copied
+		}
+		normalized.Elements[i] = element
+	}
+	if normalized == nil {
+		return action
+	}
+	return normalized
+}
+
+func normalizePoints(element llm.Element) (llm.Element, bool) {
+	origin := element.Points[0]
+	dx, dy := origin[0], origin[1]
+	switch {
+	case dx == 0 && dy == 0:
+	case math.Abs(dx-element.X) <= absolutePointTolerance && math.Abs(dy-element.Y) <= absolutePointTolerance:
+		// Absolute coordinates: the element already starts at the first point.
+		element.X, element.Y = dx, dy
+	default:
+		element.X += dx
+		element.Y += dy
+	}
+
+	points := make([][]float64, len(element.Points))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for n, point := range element.Points {
+		x, y := point[0]-dx, point[1]-dy
+		points[n] = []float64{x, y}
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	width, height := maxX-minX, maxY-minY
+
+	changed := dx != 0 || dy != 0 || element.Width != width || element.Height != height
+	element.Points = points
+	element.Width = width
+	element.Height = height
+	return element, changed
+}
+
+// validPoints reports whether points are at least two finite [x, y] pairs.
+func validPoints(points [][]float64) bool {
+	if len(points) < 2 {
+		return false
+	}
+	for _, point := range points {
+		if len(point) != 2 {
+			return false
+		}
+		for _, value := range point {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"draw/pkg/llm"
+)
+
+// ErrNoFlowchart is returned by ToMermaid for boards without shapes.
+var ErrNoFlowchart = errors.New("board has no shapes to export as a flowchart")
+
+// mermaidShapes are the brackets ToMermaid writes around each shape's text.
+var mermaidShapes = map[string][2]string{
+	"rectangle": {"[", "]"},
+	"ellipse":   {"(", ")"},
+	"diamond":   {"{", "}"},
+}
+
+// mermaidText escapes text for a quoted Mermaid label.
+var mermaidText = strings.NewReplacer(`"`, "#quot;", "\r\n", "<br>", "\n", "<br>")
+
+// ToMermaid writes the board as a Mermaid flowchart. Rectangles, ellipses
+// and diamonds become nodes with their labels as text, and arrows and lines
+// bound to two of them become links, labelled with theirs. Nodes are named
+// n1, n2 and so on from back to front, so exporting a board twice gives the
+// same text. The chart runs left to right when its links mostly do, and top to
+// bottom otherwise. Elements that can't be written, such as free text,
+// images and unbound arrows, are skipped and listed in comments at the end.
+func ToMermaid(elements []llm.Element) (string, error) {
+	elements = OrderByIndex(elements)
+	labels := make(map[string]string)
+	for _, element := range elements {
+		if containerID := extraString(element.Extra, "containerId"); containerID != "" && !isDeleted(element) {
+			labels[containerID] = element.Text
+		}
+	}
+	labelOf := func(element llm.Element) string {
+		if element.Label != nil && element.Label.Text != "" {
+			return element.Label.Text
+		}
+		return labels[element.ID]
+	}
+
+	var nodes strings.Builder
+	names := make(map[string]string)
+	shapes := make(map[string]llm.Element)
+	var skipped []string
+	for _, element := range elements {
+		if isDeleted(element) || isConnector(element) || extraString(element.Extra, "containerId") != "" {
+			continue
+		}
+		brackets, ok := mermaidShapes[element.Type]
+		if !ok || element.ID == "" {
+			skipped = append(skipped, describeSkipped(element, labelOf(element), "isn't a flowchart shape"))
+			continue
+		}
+		name := fmt.Sprintf("n%d", len(names)+1)
+		names[element.ID] = name
+		shapes[element.ID] = element
+		fmt.Fprintf(&nodes, "    %s%s\"%s\"%s\n", name, brackets[0], mermaidText.Replace(labelOf(element)), brackets[1])
+	}
+	if len(names) == 0 {
+		return "", ErrNoFlowchart
+	}
+
+	var links strings.Builder
+	var across, down float64
+	for _, element := range elements {
+		if isDeleted(element) || !isConnector(element) {
+			continue
+		}
+		start, end := arrowEnds(element)
+		from, fromOK := names[start]
+		to, toOK := names[end]
+		if !fromOK || !toOK {
+			skipped = append(skipped, describeSkipped(element, labelOf(element), "isn't bound to two shapes"))
+			continue
+		}
+		link := mermaidLink(element)
+		if label := labelOf(element); label != "" {
+			link += `|"` + mermaidText.Replace(label) + `"|`
+		}
+		fmt.Fprintf(&links, "    %s %s %s\n", from, link, to)
+
+		a, b := shapes[start], shapes[end]
+		across += math.Abs((b.X + b.Width/2) - (a.X + a.Width/2))
+		down += math.Abs((b.Y + b.Height/2) - (a.Y + a.Height/2))
+	}
+
+	var chart strings.Builder
+	if across > down {
+		chart.WriteString("flowchart LR\n")
+	} else {
+		chart.WriteString("flowchart TD\n")
+	}
+	chart.WriteString(nodes.String())
+	chart.WriteString(links.String())
+	for _, reason := range skipped {
+		fmt.Fprintf(&chart, "    %%%% skipped %s\n", reason)
+	}
+	return chart.String(), nil
+}
+
+// mermaidLink returns the link for a connector: dashed ones are dotted,
+// those drawn at Excalidraw's heaviest stroke thick, and heads follow its
+// arrowheads. Lines have none unless given some.
+func mermaidLink(element llm.Element) string {
+	startHead := arrowhead(element, "startArrowhead", false)
+	endHead := arrowhead(element, "endArrowhead", element.Type == "arrow")
+
+	body := "--"
+	switch {
+	case element.StrokeStyle == "dashed" || element.StrokeStyle == "dotted":
+		body = "-.-"
+	case element.StrokeWidth >= 4:
+		body = "=="
+	}
+	link := body
+	if startHead {
+		link = "<" + link
+	}
+	if endHead {
+		return link + ">"
+	}
+	if body == "-.-" {
+		return link
+	}
+	return link + body[:1]
+}
+
+// arrowhead reports whether a connector has a head at one end, which it does
+// by default when fallback is set.
+func arrowhead(element llm.Element, key string, fallback bool) bool {
+	raw, ok := element.Extra[key]
+	if !ok {
+		return fallback
+	}
+	var head *string
+	if json.Unmarshal(raw, &head) != nil {
+		return fallback
+	}
+	return head != nil && *head != ""
+}
+
+func describeSkipped(element llm.Element, label string, reason string) string {
+	if label == "" {
+		label = element.Text
+	}
+	if label != "" {
+		return fmt.Sprintf("%s %q: %s", element.Type, strings.ReplaceAll(label, "\n", " "), reason)
+	}
+	return fmt.Sprintf("%s: %s", element.Type, reason)
+}
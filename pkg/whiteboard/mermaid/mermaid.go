@@ -0,0 +1,388 @@
+// Package mermaid converts Mermaid flowcharts into whiteboard elements, so
+// diagrams people already keep in their docs can be put on a board.
+package mermaid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+	"draw/pkg/whiteboard/layout"
+)
+
+// ErrInvalidFlowchart is returned for sources ParseFlowchart can't read.
+var ErrInvalidFlowchart = errors.New("invalid mermaid flowchart")
+
+// Sizes of the shapes nodes become: labels are measured at labelFontSize and
+// padded, and shapes are never smaller than minWidth by minHeight.
+const (
+	labelFontSize = 20
+	labelPadding  = 40
+	minWidth      = 160
+	minHeight     = 80
+	arrowStroke   = "#1e1e1e"
+	arrowWidth    = 2
+	thickWidth    = 4
+)
+
+var headerPattern = regexp.MustCompile(`^(?i)(?:graph|flowchart)(?:\s+(TD|TB|BT|LR|RL))?\s*(?:;(.*))?$`)
+
+// ignoredStatements are Mermaid statements that style or group a chart
+// rather than draw it. Subgraphs aren't drawn, but the nodes in them are.
+var ignoredStatements = []string{"subgraph", "end", "direction", "classDef", "class", "style", "linkStyle", "click"}
+
+// linkPattern matches a link between nodes: "-->", "---", "-.->", "==>" and
+// longer variants, with an optional "|label|" after it. labelLinkPattern
+// matches the other way of labelling one, "-- label -->".
+var (
+	linkPattern      = regexp.MustCompile(`^(<?)(-{2,}|={2,}|-\.+-)(>?)(?:\s*\|([^|]*)\|)?`)
+	labelLinkPattern = regexp.MustCompile(`^(<?)(--|==|-\.)\s+(.+?)\s+(-{2,}|={2,}|\.+-)(>?)`)
+)
+
+// shapes maps the brackets around a node's text to the element it becomes,
+// longest first so "((" isn't read as "(". Shapes Excalidraw can't draw get
+// the nearest one it can.
+var shapes = []struct {
+	open, close string
+	elementType string
+}{
+	{"((", "))", "ellipse"},
+	{"([", "])", "ellipse"},
+	{"[[", "]]", "rectangle"},
+	{"[(", ")]", "rectangle"},
+	{"{{", "}}", "diamond"},
+	{"[", "]", "rectangle"},
+	{"(", ")", "ellipse"},
+	{"{", "}", "diamond"},
+	{">", "]", "rectangle"},
+}
+
+// entities are the escapes Mermaid text uses for line breaks and quotes.
+var entities = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "#quot;", `"`)
+
+type link struct {
+	label     string
+	startHead bool
+	endHead   bool
+	dotted    bool
+	thick     bool
+}
+
+type parser struct {
+	nodes   []llm.Element
+	index   map[string]int
+	arrows  []llm.Element
+	line    int
+	pending string
+	pos     int
+}
+
+// ParseFlowchart converts a Mermaid flowchart ("graph TD" or "flowchart
+// LR") into elements: nodes become labelled rectangles, ellipses or
+// diamonds for [], () and {}, and links become arrows bound to them, dashed
+// for "-.->", thick for "==>" and without a head for "---". Nodes keep their
+// Mermaid IDs. The chart is laid out with layout.LayoutFlow in the chart's
+// direction, with its top-left corner at the origin. Subgraphs, classes and
+// styles are skipped.
+func ParseFlowchart(src string) ([]llm.Element, error) {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	p := &parser{index: make(map[string]int)}
+
+	direction := ""
+	for i, line := range lines {
+		line = stripComment(line)
+		if line == "" {
+			continue
+		}
+		p.line = i + 1
+		if direction == "" {
+			match := headerPattern.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("%w: line %d: expected \"graph\" or \"flowchart\" and a direction", ErrInvalidFlowchart, p.line)
+			}
+			direction = strings.ToUpper(match[1])
+			if direction == "" {
+				direction = "TD"
+			}
+			line = match[2]
+		}
+		for _, statement := range splitStatements(line) {
+			if err := p.statement(strings.TrimSpace(statement)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if direction == "" {
+		return nil, fmt.Errorf("%w: empty source", ErrInvalidFlowchart)
+	}
+	if len(p.nodes) == 0 {
+		return nil, fmt.Errorf("%w: no nodes", ErrInvalidFlowchart)
+	}
+	return arrange(p.nodes, p.arrows, direction), nil
+}
+
+func stripComment(line string) string {
+	if i := strings.Index(line, "%%"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// splitStatements splits a line on the semicolons between statements,
+// leaving those in quoted text.
+func splitStatements(line string) []string {
+	var statements []string
+	quoted, start := false, 0
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			statements = append(statements, line[start:i])
+			start = i + 1
+		}
+	}
+	return append(statements, line[start:])
+}
+
+// statement parses one node or chain of links, as in "A[Start] --> B & C".
+func (p *parser) statement(statement string) error {
+	if statement == "" {
+		return nil
+	}
+	keyword, _, _ := strings.Cut(statement, " ")
+	for _, ignored := range ignoredStatements {
+		if keyword == ignored {
+			return nil
+		}
+	}
+
+	p.pending, p.pos = statement, 0
+	from, err := p.nodeGroup()
+	if err != nil {
+		return err
+	}
+	for {
+		p.skipSpace()
+		if p.pos == len(p.pending) {
+			return nil
+		}
+		l, err := p.link()
+		if err != nil {
+			return err
+		}
+		to, err := p.nodeGroup()
+		if err != nil {
+			return err
+		}
+		for _, a := range from {
+			for _, b := range to {
+				p.arrows = append(p.arrows, arrow(len(p.arrows), a, b, l))
+			}
+		}
+		from = to
+	}
+}
+
+// nodeGroup parses one node or several joined by "&".
+func (p *parser) nodeGroup() ([]string, error) {
+	var ids []string
+	for {
+		p.skipSpace()
+		id, err := p.node()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		p.skipSpace()
+		if !strings.HasPrefix(p.pending[p.pos:], "&") {
+			return ids, nil
+		}
+		p.pos++
+	}
+}
+
+// node parses a node ID and the shape and text after it, adding the node the
+// first time it appears; later shapes and text replace earlier ones.
+func (p *parser) node() (string, error) {
+	start := p.pos
+	for p.pos < len(p.pending) {
+		r, size := utf8.DecodeRuneInString(p.pending[p.pos:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		p.pos += size
+	}
+	id := p.pending[start:p.pos]
+	if id == "" {
+		return "", p.errorf("expected a node ID")
+	}
+
+	elementType, text := "", ""
+	rest := p.pending[p.pos:]
+	for _, shape := range shapes {
+		if !strings.HasPrefix(rest, shape.open) {
+			continue
+		}
+		body := rest[len(shape.open):]
+		var end int
+		if strings.HasPrefix(body, `"`) {
+			quote := strings.Index(body[1:], `"`)
+			if quote < 0 || !strings.HasPrefix(body[quote+2:], shape.close) {
+				return "", p.errorf("unterminated text of node %s", id)
+			}
+			text, end = body[1:quote+1], quote+2
+		} else {
+			end = strings.Index(body, shape.close)
+			if end < 0 {
+				return "", p.errorf("unterminated text of node %s", id)
+			}
+			text = strings.TrimSpace(body[:end])
+		}
+		elementType = shape.elementType
+		p.pos += len(shape.open) + end + len(shape.close)
+		break
+	}
+
+	i, ok := p.index[id]
+	if !ok {
+		i = len(p.nodes)
+		p.index[id] = i
+		p.nodes = append(p.nodes, llm.Element{ID: id, Type: "rectangle", Label: &llm.ElementLabel{Text: id}})
+	}
+	if elementType != "" {
+		p.nodes[i].Type = elementType
+		p.nodes[i].Label = &llm.ElementLabel{Text: entities.Replace(text)}
+	}
+	return id, nil
+}
+
+// link parses the link between two nodes.
+func (p *parser) link() (link, error) {
+	rest := p.pending[p.pos:]
+	if match := labelLinkPattern.FindStringSubmatch(rest); match != nil {
+		p.pos += len(match[0])
+		return link{
+			label:     strings.Trim(match[3], `"`),
+			startHead: match[1] != "",
+			endHead:   match[5] != "",
+			dotted:    match[2] == "-.",
+			thick:     match[2] == "==",
+		}, nil
+	}
+	if match := linkPattern.FindStringSubmatch(rest); match != nil {
+		p.pos += len(match[0])
+		return link{
+			label:     strings.Trim(strings.TrimSpace(match[4]), `"`),
+			startHead: match[1] != "",
+			endHead:   match[3] != "",
+			dotted:    strings.Contains(match[2], "."),
+			thick:     strings.HasPrefix(match[2], "="),
+		}, nil
+	}
+	return link{}, p.errorf("expected a link such as \"-->\"")
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.pending) && (p.pending[p.pos] == ' ' || p.pending[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidFlowchart, p.line, fmt.Sprintf(format, args...))
+}
+
+// arrow returns the arrow for the n-th link, from a to b.
+func arrow(n int, a string, b string, l link) llm.Element {
+	element := llm.Element{
+		ID:          fmt.Sprintf("edge-%d", n+1),
+		Type:        "arrow",
+		StrokeColor: arrowStroke,
+		StrokeWidth: arrowWidth,
+		Start:       &llm.ElementBinding{ID: a},
+		End:         &llm.ElementBinding{ID: b},
+	}
+	if l.thick {
+		element.StrokeWidth = thickWidth
+	}
+	if l.dotted {
+		element.StrokeStyle = "dashed"
+	}
+	if l.label != "" {
+		element.Label = &llm.ElementLabel{Text: entities.Replace(l.label)}
+	}
+	if l.startHead || !l.endHead {
+		element.Extra = make(map[string]json.RawMessage)
+		if l.startHead {
+			element.Extra["startArrowhead"] = json.RawMessage(`"arrow"`)
+		}
+		if !l.endHead {
+			element.Extra["endArrowhead"] = json.RawMessage(`null`)
+		}
+	}
+	return element
+}
+
+// arrange sizes nodes to their labels and lays the chart out in direction.
+// LayoutFlow only lays out top to bottom, so other directions are laid out
+// turned or flipped into it and turned back.
+func arrange(nodes []llm.Element, arrows []llm.Element, direction string) []llm.Element {
+	horizontal := direction == "LR" || direction == "RL"
+	for i := range nodes {
+		width, height := whiteboard.MeasureText(nodes[i].Label.Text, labelFontSize, whiteboard.FontExcalifont)
+		if nodes[i].Type == "diamond" {
+			// Only the middle of a diamond holds text.
+			width, height = width*1.5, height*1.5
+		}
+		nodes[i].Width = math.Max(minWidth, width+labelPadding)
+		nodes[i].Height = math.Max(minHeight, height+labelPadding)
+		if horizontal {
+			nodes[i].Width, nodes[i].Height = nodes[i].Height, nodes[i].Width
+		}
+	}
+
+	laidOut := layout.LayoutFlow(nodes, arrows)
+	nodes, arrows = laidOut[:len(nodes)], laidOut[len(nodes):]
+	for i := range nodes {
+		node := &nodes[i]
+		if horizontal {
+			node.X, node.Y = node.Y, node.X
+			node.Width, node.Height = node.Height, node.Width
+		}
+		if direction == "BT" {
+			node.Y = -(node.Y + node.Height)
+		}
+		if direction == "RL" {
+			node.X = -(node.X + node.Width)
+		}
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	for _, node := range nodes {
+		minX, minY = math.Min(minX, node.X), math.Min(minY, node.Y)
+	}
+	byID := make(map[string]llm.Element, len(nodes))
+	for i := range nodes {
+		nodes[i].X -= minX
+		nodes[i].Y -= minY
+		byID[nodes[i].ID] = nodes[i]
+	}
+	for i, a := range arrows {
+		from, to := byID[a.Start.ID], byID[a.End.ID]
+		if a.Start.ID == a.End.ID {
+			// A loop on one node starts and ends on its right side.
+			arrows[i].X, arrows[i].Y = from.X+from.Width, from.Y+from.Height/2
+			continue
+		}
+		arrows[i] = layout.Route(a, from, to)
+	}
+	return laidOut
+}
@@ -0,0 +1,178 @@
+package mermaid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "top down",
+			src: `flowchart TD
+    A[Start] --> B{Valid?}
+    B -->|yes| C(Done)
+    B -- no --> A`,
+			want: `flowchart TD
+    n1["Start"]
+    n2{"Valid?"}
+    n3("Done")
+    n1 --> n2
+    n2 -->|"yes"| n3
+    n2 -->|"no"| n1
+`,
+		},
+		{
+			name: "left to right with link styles",
+			src: `graph LR
+    a[Client] -.-> b[API]
+    b ==> c[(Database)]
+    c --- d[Backup]
+    b <--> a`,
+			want: `flowchart LR
+    n1["Client"]
+    n2["API"]
+    n3["Database"]
+    n4["Backup"]
+    n1 -.-> n2
+    n2 ==> n3
+    n3 --- n4
+    n2 <--> n1
+`,
+		},
+		{
+			name: "quotes and line breaks in labels",
+			src: `flowchart TD
+    A["Say #quot;hi#quot;"] -->|"two<br>lines"| B["Line one<br>line two"]`,
+			want: `flowchart TD
+    n1["Say #quot;hi#quot;"]
+    n2["Line one<br>line two"]
+    n1 -->|"two<br>lines"| n2
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, err := ParseFlowchart(tt.src)
+			if err != nil {
+				t.Fatalf("ParseFlowchart: %v", err)
+			}
+			exported, err := whiteboard.ToMermaid(elements)
+			if err != nil {
+				t.Fatalf("ToMermaid: %v", err)
+			}
+			if exported != tt.want {
+				t.Errorf("ToMermaid =\n%s\nwant\n%s", exported, tt.want)
+			}
+
+			reimported, err := ParseFlowchart(exported)
+			if err != nil {
+				t.Fatalf("ParseFlowchart of the export: %v\n%s", err, exported)
+			}
+			if got, want := graph(reimported), graph(elements); !equalStrings(got, want) {
+				t.Errorf("round trip changed the chart:\ngot  %v\nwant %v", got, want)
+			}
+
+			again, err := whiteboard.ToMermaid(reimported)
+			if err != nil {
+				t.Fatalf("ToMermaid of the reimport: %v", err)
+			}
+			if again != exported {
+				t.Errorf("exporting the reimported chart =\n%s\nwant the first export\n%s", again, exported)
+			}
+		})
+	}
+}
+
+func TestRoundTripBoard(t *testing.T) {
+	// A board drawn by hand, not imported: labels are bound text, and the
+	// elements that aren't a flowchart are left out of the round trip.
+	board := []llm.Element{
+		{ID: "box", Type: "rectangle", X: 0, Y: 0, Width: 160, Height: 80},
+		{ID: "box-text", Type: "text", Text: "Login", Extra: map[string]json.RawMessage{"containerId": json.RawMessage(`"box"`)}},
+		{ID: "check", Type: "diamond", X: 0, Y: 200, Width: 160, Height: 80, Label: &llm.ElementLabel{Text: "OK?"}},
+		{ID: "link", Type: "arrow", StrokeStyle: "dashed", Start: &llm.ElementBinding{ID: "box"}, End: &llm.ElementBinding{ID: "check"}},
+		{ID: "note", Type: "text", X: 400, Y: 0, Text: "draft"},
+		{ID: "loose", Type: "arrow", Start: &llm.ElementBinding{ID: "box"}},
+	}
+	exported, err := whiteboard.ToMermaid(board)
+	if err != nil {
+		t.Fatalf("ToMermaid: %v", err)
+	}
+	elements, err := ParseFlowchart(exported)
+	if err != nil {
+		t.Fatalf("ParseFlowchart of the export: %v\n%s", err, exported)
+	}
+	want := []string{`arrow "Login" -> "OK?" dashed`, `diamond "OK?"`, `rectangle "Login"`}
+	if got := graph(elements); !equalStrings(got, want) {
+		t.Errorf("reimported chart = %v, want %v", got, want)
+	}
+}
+
+func TestToMermaidEmpty(t *testing.T) {
+	for _, board := range [][]llm.Element{nil, {{ID: "t", Type: "text", Text: "only text"}}} {
+		if _, err := whiteboard.ToMermaid(board); !errors.Is(err, whiteboard.ErrNoFlowchart) {
+			t.Errorf("ToMermaid(%d elements) error = %v, want ErrNoFlowchart", len(board), err)
+		}
+	}
+}
+
+// graph describes a chart by its nodes and links, sorted and without layout.
+// Nodes go by their labels, as the export renames them.
+func graph(elements []llm.Element) []string {
+	labels := make(map[string]string)
+	for _, element := range elements {
+		if element.Label != nil {
+			labels[element.ID] = element.Label.Text
+		}
+	}
+	var lines []string
+	for _, element := range elements {
+		label := labels[element.ID]
+		if element.Type != "arrow" {
+			lines = append(lines, fmt.Sprintf("%s %q", element.Type, label))
+			continue
+		}
+		line := fmt.Sprintf("arrow %q -> %q", labels[element.Start.ID], labels[element.End.ID])
+		if element.StrokeStyle != "" && element.StrokeStyle != "solid" {
+			line += " " + element.StrokeStyle
+		}
+		if element.StrokeWidth >= thickWidth {
+			line += " thick"
+		}
+		for _, key := range []string{"startArrowhead", "endArrowhead"} {
+			if raw, ok := element.Extra[key]; ok {
+				line += fmt.Sprintf(" %s=%s", key, raw)
+			}
+		}
+		if label != "" {
+			line += fmt.Sprintf(" %q", label)
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+// FuzzNormalizeAction runs what parses as an add through the normalizers the
+// pipeline applies before validation.
+func FuzzNormalizeAction(f *testing.F) {
+	for _, seed := range []string{
+		`{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":50},{"id":"b","type":"arrow","x":100,"y":25,"points":[[100,25],[200,25]],"start":{"id":"a"},"end":{"id":"missing"}}]}`,
+		`{"action":"add","elements":[{"id":"a","type":"rectangle","x":0,"y":0},{"id":"a","type":"ellipse","x":10,"y":10,"backgroundColor":"light blue"}]}`,
+		`{"action":"replace","elements":[{"type":"line","x":5,"y":5,"points":[[0,0],[1e300,-1e300]]},{"type":"text","x":0,"y":0,"text":"ü 🚀"}]}`,
+		`{"action":"add","elements":[{"type":"arrow","x":0,"y":0,"points":[[3,4]],"start":{"id":""}}]}`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		action, err := llm.ParseWhiteboardAction(raw)
+		if err != nil || (action.Action != llm.ActionAdd && action.Action != llm.ActionReplace) {
+			return
+		}
+		action = NormalizeLinePoints(action)
+		action = NormalizeColors(action)
+		AssignElementIDs(action, nil, "request")
+		llm.DropBadBindings(action, llm.ValidateAction(action, nil))
+
+		ids := make(map[string]bool, len(action.Elements))
+		for i, element := range action.Elements {
+			if element.ID == "" {
+				t.Fatalf("element %d has no ID", i)
+			}
+			if ids[element.ID] {
+				t.Fatalf("element %d repeats the ID %s", i, element.ID)
+			}
+			ids[element.ID] = true
+
+			numbers := []float64{element.X, element.Y, element.Width, element.Height}
+			for _, point := range element.Points {
+				numbers = append(numbers, point...)
+			}
+			for _, n := range numbers {
+				if math.IsNaN(n) || math.IsInf(n, 0) {
+					t.Fatalf("element %d has the non-finite number %v", i, n)
+				}
+			}
+		}
+		for i, element := range action.Elements {
+			for _, binding := range []*llm.ElementBinding{element.Start, element.End} {
+				if binding != nil && binding.ID != "" && !ids[binding.ID] {
+					t.Fatalf("element %d is bound to %q, which isn't added", i, binding.ID)
+				}
+			}
+		}
+
+		data, err := json.Marshal(action)
+		if err != nil || !json.Valid(data) {
+			t.Fatalf("normalized action doesn't marshal: %v", err)
+		}
+	})
+}
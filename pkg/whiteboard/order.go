@@ -0,0 +1,415 @@
+package whiteboard
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"draw/pkg/llm"
+)
+
+// Elements are stacked in board order, from back to front, and each carries
+// a fractional index that sorts, as a plain string, the same way. Moving one
+// element only gives it a new index between its new neighbours', so the
+// others keep theirs. The indices are the base 62 keys Excalidraw uses: an
+// integer part, whose first character gives its length, then a fraction.
+
+// indexDigits are the digits of fractional indices, in order.
+const indexDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// smallestInteger is the lowest integer part, which leaves no room below
+// it for an index without a fraction.
+var smallestInteger = "A" + strings.Repeat(indexDigits[:1], 26)
+
+// errIndexRange is returned when there is no index between two others,
+// which only happens after billions of moves to one end of the board.
+var errIndexRange = errors.New("no fractional index fits between its neighbours")
+
+// SyncIndices returns the board with an index on every element, in board
+// order. Indices that already increase along the board are kept, so an
+// element saved by a client keeps the one Excalidraw gave it; elements
+// without one, or out of order, get new ones between their neighbours'.
+func SyncIndices(elements []llm.Element) ([]llm.Element, error) {
+	indices := make([]string, len(elements))
+	for i, element := range elements {
+		indices[i] = element.Index
+	}
+	indices, err := syncIndices(indices)
+	if err != nil {
+		return nil, err
+	}
+	synced := make([]llm.Element, len(elements))
+	copy(synced, elements)
+	for i := range synced {
+		synced[i].Index = indices[i]
+	}
+	return synced, nil
+}
+
+// syncIndices is SyncIndices for a list of indices.
+func syncIndices(indices []string) ([]string, error) {
+	synced := make([]string, len(indices))
+	kept := make([]bool, len(indices))
+	last := ""
+	for i, index := range indices {
+		if validIndex(index) && index > last {
+			synced[i] = index
+			kept[i] = true
+			last = index
+		}
+	}
+
+	lower := ""
+	for i := 0; i < len(synced); {
+		if kept[i] {
+			lower = synced[i]
+			i++
+			continue
+		}
+		end := i
+		for end < len(synced) && !kept[end] {
+			end++
+		}
+		upper := ""
+		if end < len(synced) {
+			upper = synced[end]
+		}
+		for ; i < end; i++ {
+			index, err := indexBetween(lower, upper)
+			if err != nil {
+				return nil, err
+			}
+			synced[i] = index
+			lower = index
+		}
+	}
+	return synced, nil
+}
+
+// OrderByIndex returns the board sorted by index, from back to front. When
+// any element lacks a valid index the board is returned in the order it is
+// stored, which is the order clients draw it in.
+func OrderByIndex(elements []llm.Element) []llm.Element {
+	for _, element := range elements {
+		if !validIndex(element.Index) {
+			return elements
+		}
+	}
+	ordered := make([]llm.Element, len(elements))
+	copy(ordered, elements)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Index < ordered[j].Index
+	})
+	return ordered
+}
+
+// Reorder moves the element with the given ID to position in the stacking
+// order: llm.PositionFront or llm.PositionBack put it above or below every
+// other element, and llm.PositionForward or llm.PositionBackward move it
+// past the element above or below it. Text bound to the element moves with
+// it. Indices are synced first, and only the moved elements get new ones.
+// The board is not modified; a new slice is returned.
+func Reorder(elements []llm.Element, id string, position string) ([]llm.Element, error) {
+	return reorder(elements, id, position, nil)
+}
+
+// reorder is Reorder, moving forward or backward past the nearest element
+// not in skip.
+func reorder(elements []llm.Element, id string, position string, skip map[string]bool) ([]llm.Element, error) {
+	synced, err := SyncIndices(elements)
+	if err != nil {
+		return nil, err
+	}
+	at := -1
+	for i, element := range synced {
+		if element.ID == id && !isDeleted(element) {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return nil, fmt.Errorf("element %q not found", id)
+	}
+
+	// The element and its bound text move as one block, and below is how
+	// many of the rest were under it.
+	var block, rest []llm.Element
+	below := 0
+	for i, element := range synced {
+		if i == at || extraString(element.Extra, "containerId") == id {
+			block = append(block, element)
+			continue
+		}
+		if i < at {
+			below++
+		}
+		rest = append(rest, element)
+	}
+	stacked := func(element llm.Element) bool {
+		return !isDeleted(element) && !skip[element.ID] && extraString(element.Extra, "containerId") == ""
+	}
+
+	insert := below
+	switch position {
+	case llm.PositionFront:
+		insert = len(rest)
+	case llm.PositionBack:
+		insert = 0
+	case llm.PositionForward:
+		for i := below; i < len(rest); i++ {
+			if stacked(rest[i]) {
+				// Stay above the text bound to the element passed.
+				insert = i + 1
+				for insert < len(rest) && extraString(rest[insert].Extra, "containerId") == rest[i].ID {
+					insert++
+				}
+				break
+			}
+		}
+	case llm.PositionBackward:
+		for i := below - 1; i >= 0; i-- {
+			if stacked(rest[i]) {
+				insert = i
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown position %q", position)
+	}
+
+	lower, upper := "", ""
+	if insert > 0 {
+		lower = rest[insert-1].Index
+	}
+	if insert < len(rest) {
+		upper = rest[insert].Index
+	}
+	for i := range block {
+		index, err := indexBetween(lower, upper)
+		if err != nil {
+			return nil, err
+		}
+		block[i].Index = index
+		lower = index
+	}
+
+	result := make([]llm.Element, 0, len(synced))
+	result = append(result, rest[:insert]...)
+	result = append(result, block...)
+	return append(result, rest[insert:]...), nil
+}
+
+// reorderAll moves each of ids to position, keeping the order they were in
+// among themselves.
+func reorderAll(elements []llm.Element, ids []string, position string) ([]llm.Element, error) {
+	at := make(map[string]int, len(elements))
+	for i, element := range elements {
+		if !isDeleted(element) {
+			at[element.ID] = i
+		}
+	}
+	moving := make([]string, 0, len(ids))
+	skip := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := at[id]; ok && !skip[id] {
+			moving = append(moving, id)
+			skip[id] = true
+		}
+	}
+	// Moving forward or to the back, the element nearest the destination
+	// goes first, so the others don't pass it. Moving to the front or
+	// backward, the element furthest from it goes first, and the others end
+	// up beyond it.
+	descending := position == llm.PositionBack || position == llm.PositionForward
+	sort.SliceStable(moving, func(i, j int) bool {
+		if descending {
+			return at[moving[i]] > at[moving[j]]
+		}
+		return at[moving[i]] < at[moving[j]]
+	})
+
+	for _, id := range moving {
+		var err error
+		if elements, err = reorder(elements, id, position, skip); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// validIndex reports whether index is a well-formed fractional index.
+func validIndex(index string) bool {
+	if index == "" || index == smallestInteger {
+		return false
+	}
+	n, ok := integerLength(index[0])
+	if !ok || len(index) < n {
+		return false
+	}
+	for i := 1; i < len(index); i++ {
+		if strings.IndexByte(indexDigits, index[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(index[n:], indexDigits[:1])
+}
+
+// integerLength returns how long an integer part starting with head is:
+// "a" starts two-character ones and each later lowercase letter one more,
+// while "Z" starts two-character negative ones and each earlier capital one
+// more.
+func integerLength(head byte) (int, bool) {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2, true
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2, true
+	}
+	return 0, false
+}
+
+// indexBetween returns an index that sorts after lower and before upper.
+// An empty lower or upper leaves that side open.
+func indexBetween(lower, upper string) (string, error) {
+	if lower != "" && upper != "" && lower >= upper {
+		return "", fmt.Errorf("index %q is not below %q", lower, upper)
+	}
+	switch {
+	case lower == "" && upper == "":
+		return "a" + indexDigits[:1], nil
+	case lower == "":
+		integer := upper[:mustIntegerLength(upper)]
+		if integer == smallestInteger {
+			return integer + midpoint("", upper[len(integer):]), nil
+		}
+		if integer < upper {
+			return integer, nil
+		}
+		return decrementInteger(integer)
+	case upper == "":
+		integer := lower[:mustIntegerLength(lower)]
+		next, err := incrementInteger(integer)
+		if err != nil {
+			return integer + midpoint(lower[len(integer):], ""), nil
+		}
+		return next, nil
+	}
+
+	lowerInteger := lower[:mustIntegerLength(lower)]
+	upperInteger := upper[:mustIntegerLength(upper)]
+	if lowerInteger == upperInteger {
+		return lowerInteger + midpoint(lower[len(lowerInteger):], upper[len(upperInteger):]), nil
+	}
+	next, err := incrementInteger(lowerInteger)
+	if err != nil {
+		return "", err
+	}
+	if next < upper {
+		return next, nil
+	}
+	return lowerInteger + midpoint(lower[len(lowerInteger):], ""), nil
+}
+
+func mustIntegerLength(index string) int {
+	n, _ := integerLength(index[0])
+	return n
+}
+
+// midpoint returns a fraction between the fractions lower and upper, the
+// latter open when empty.
+func midpoint(lower, upper string) string {
+	if upper != "" {
+		// Keep the prefix they share, reading lower padded with zeros.
+		n := 0
+		for n < len(upper) && digitAt(lower, n) == upper[n] {
+			n++
+		}
+		if n > 0 {
+			return upper[:n] + midpoint(tail(lower, n), upper[n:])
+		}
+	}
+
+	low := 0
+	if lower != "" {
+		low = strings.IndexByte(indexDigits, lower[0])
+	}
+	high := len(indexDigits)
+	if upper != "" {
+		high = strings.IndexByte(indexDigits, upper[0])
+	}
+	if high-low > 1 {
+		return string(indexDigits[(low+high+1)/2])
+	}
+	if len(upper) > 1 {
+		return upper[:1]
+	}
+	return string(indexDigits[low]) + midpoint(tail(lower, 1), "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return indexDigits[0]
+}
+
+func tail(s string, n int) string {
+	if n < len(s) {
+		return s[n:]
+	}
+	return ""
+}
+
+// incrementInteger returns the integer part after integer.
+func incrementInteger(integer string) (string, error) {
+	head, digits := integer[0], []byte(integer[1:])
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := strings.IndexByte(indexDigits, digits[i]) + 1
+		if d < len(indexDigits) {
+			digits[i] = indexDigits[d]
+			return string(head) + string(digits), nil
+		}
+		digits[i] = indexDigits[0]
+	}
+	switch head {
+	case 'Z':
+		return "a" + indexDigits[:1], nil
+	case 'z':
+		return "", errIndexRange
+	}
+	head++
+	if head > 'a' {
+		digits = append(digits, indexDigits[0])
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+	return string(head) + string(digits), nil
+}
+
+// decrementInteger returns the integer part before integer.
+func decrementInteger(integer string) (string, error) {
+	head, digits := integer[0], []byte(integer[1:])
+	top := indexDigits[len(indexDigits)-1]
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := strings.IndexByte(indexDigits, digits[i]) - 1
+		if d >= 0 {
+			digits[i] = indexDigits[d]
+			return string(head) + string(digits), nil
+		}
+		digits[i] = top
+	}
+	switch head {
+	case 'a':
+		return "Z" + string(top), nil
+	case 'A':
+		return "", errIndexRange
+	}
+	head--
+	if head < 'Z' {
+		digits = append(digits, top)
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+	return string(head) + string(digits), nil
+}
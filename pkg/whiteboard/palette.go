@@ -0,0 +1,76 @@
+package whiteboard
+
+import (
+	"strings"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard/colors"
+)
+
+// NormalizeColors snaps the colors an add, update or replace action sets to
+// the prompt's palette: hexes outside it become the nearest palette color,
+// and color names the model wrote instead of a hex, such as "light blue",
+// become the shade the palette gives them. "transparent" and values that are
+// neither are left for ValidateAction to judge, and actions with nothing to
+// normalize are returned unchanged.
+func NormalizeColors(action *llm.WhiteboardAction) *llm.WhiteboardAction {
+	switch action.Action {
+	case llm.ActionAdd, llm.ActionUpdate, llm.ActionReplace:
+	default:
+		return action
+	}
+
+	var normalized *llm.WhiteboardAction
+	for i, element := range action.Elements {
+		background, bgChanged := paletteColor(element.BackgroundColor, true)
+		stroke, strokeChanged := paletteColor(element.StrokeColor, false)
+		labelStroke, labelChanged := "", false
+		if element.Label != nil {
+			labelStroke, labelChanged = paletteColor(element.Label.StrokeColor, false)
+		}
+		if !bgChanged && !strokeChanged && !labelChanged {
+			continue
+		}
+
+		if normalized == nil {
+			copied := *action
+			copied.Elements = append([]llm.Element(nil), action.Elements...)
+			normalized = &copied
+		}
+		element.BackgroundColor = background
+		element.StrokeColor = stroke
+		if labelChanged {
+			label := *element.Label
+			label.StrokeColor = labelStroke
+			element.Label = &label
+		}
+		normalized.Elements[i] = element
+	}
+	if normalized == nil {
+		return action
+	}
+	return normalized
+}
+
+// paletteColor returns the palette color for value, a fill when fill is set
+// and a stroke otherwise, and whether that differs from value.
+func paletteColor(value string, fill bool) (string, bool) {
+	if value == "" || strings.EqualFold(value, colors.Transparent) || colors.IsPalette(value) {
+		return value, false
+	}
+	if strings.HasPrefix(value, "#") {
+		nearest, ok := colors.Nearest(value)
+		if !ok {
+			return value, false
+		}
+		return nearest, true
+	}
+	bg, stroke, ok := colors.ResolveColor(value)
+	if !ok {
+		return value, false
+	}
+	if fill {
+		return bg, true
+	}
+	return stroke, true
+}
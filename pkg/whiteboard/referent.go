@@ -0,0 +1,59 @@
+package whiteboard
+
+import (
+	"strings"
+	"unicode"
+
+	"draw/pkg/llm"
+)
+
+// ResolveReferents finds elements whose label or text is mentioned in the
+// instruction, so "the pricing box" can be handed to the model as an ID.
+func ResolveReferents(instruction string, elements []llm.Element) []llm.Referent {
+	words := tokenize(instruction)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var referents []llm.Referent
+	for _, element := range elements {
+		if element.ID == "" {
+			continue
+		}
+		text := element.Text
+		if element.Label != nil && element.Label.Text != "" {
+			text = element.Label.Text
+		}
+		phrase := tokenize(text)
+		if len(phrase) == 0 || !containsPhrase(words, phrase) {
+			continue
+		}
+		referents = append(referents, llm.Referent{
+			Phrase:    strings.Join(phrase, " "),
+			ElementID: element.ID,
+		})
+	}
+	return referents
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, word := range phrase {
+			if words[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"draw/pkg/llm"
+
+	"github.com/google/uuid"
+)
+
+// RemapElementIDs gives every element a fresh ID and rewrites references
+// between elements (arrow bindings, bound text, containers, frames) to match.
+// It returns the old-to-new ID mapping so references held outside the board,
+// such as comments, can be rewritten too.
+func RemapElementIDs(elements []llm.Element) map[string]string {
+	ids := make(map[string]string, len(elements))
+	for i := range elements {
+		if elements[i].ID == "" {
+			continue
+		}
+		newID := uuid.New().String()
+		ids[elements[i].ID] = newID
+		elements[i].ID = newID
+	}
+
+	remapReferences(elements, ids)
+	return ids
+}
+
+// elementIDNamespace scopes the IDs AssignElementIDs derives, so they can't
+// match a UUID derived the same way for anything else.
+var elementIDNamespace = uuid.MustParse("3f6c2d1e-8a4b-4f0e-9c57-6b1d2e9a7c40")
+
+// AssignElementIDs replaces the IDs the model gave the elements of an add or
+// replace action, which may repeat ones already on the board, with IDs of the
+// server's own, and rewrites the references between the added elements to
+// match. References to elements already on the board are left alone, except
+// that an added element shadows a board element whose ID it reused. The
+// IDs are derived from requestID and each element's position, so replaying
+// an instruction assigns the same ones. It returns the old-to-new mapping;
+// other actions are left unchanged and get an empty one.
+func AssignElementIDs(action *llm.WhiteboardAction, board []llm.Element, requestID string) map[string]string {
+	ids := make(map[string]string)
+	if action.Action != llm.ActionAdd && action.Action != llm.ActionReplace {
+		return ids
+	}
+
+	taken := make(map[string]bool, len(board)+len(action.Elements))
+	for _, element := range board {
+		taken[element.ID] = true
+	}
+	for i := range action.Elements {
+		element := &action.Elements[i]
+		newID := uuid.NewSHA1(elementIDNamespace, []byte(requestID+"/"+strconv.Itoa(i))).String()
+		for salt := 1; taken[newID]; salt++ {
+			newID = uuid.NewSHA1(elementIDNamespace, []byte(requestID+"/"+strconv.Itoa(i)+"/"+strconv.Itoa(salt))).String()
+		}
+		taken[newID] = true
+		if element.ID != "" {
+			ids[element.ID] = newID
+		}
+		element.ID = newID
+	}
+
+	remapReferences(action.Elements, ids)
+	return ids
+}
+
+// RemapActionReferences rewrites the IDs action refers to, such as those of
+// the elements it updates or deletes, of arrow bindings and of frames, to the
+// IDs in ids, so a later action can refer to elements an earlier one added by
+// the IDs the model gave them. The IDs of elements an add or replace action
+// adds are left for AssignElementIDs.
+func RemapActionReferences(action *llm.WhiteboardAction, ids map[string]string) {
+	if len(ids) == 0 {
+		return
+	}
+	if action.Action != llm.ActionAdd && action.Action != llm.ActionReplace {
+		for i := range action.Elements {
+			action.Elements[i].ID = remapID(ids, action.Elements[i].ID)
+		}
+	}
+	remapReferences(action.Elements, ids)
+	for i, id := range action.DeleteIDs {
+		action.DeleteIDs[i] = remapID(ids, id)
+	}
+	action.SourceID = remapID(ids, action.SourceID)
+	for i, id := range action.TargetIDs {
+		action.TargetIDs[i] = remapID(ids, id)
+	}
+}
+
+// remapReferences rewrites the references elements hold to the IDs in ids.
+func remapReferences(elements []llm.Element, ids map[string]string) {
+	for i := range elements {
+		element := &elements[i]
+		if element.Start != nil {
+			element.Start.ID = remapID(ids, element.Start.ID)
+		}
+		if element.End != nil {
+			element.End.ID = remapID(ids, element.End.ID)
+		}
+		element.FrameID = remapID(ids, element.FrameID)
+		for n, child := range element.Children {
+			element.Children[n] = remapID(ids, child)
+		}
+		remapExtra(element.Extra, ids)
+	}
+}
+
+func remapID(ids map[string]string, id string) string {
+	if newID, ok := ids[id]; ok {
+		return newID
+	}
+	return id
+}
+
+// remapExtra rewrites the Excalidraw reference fields that are carried
+// through untyped.
+func remapExtra(extra map[string]json.RawMessage, ids map[string]string) {
+	var containerID string
+	if raw, ok := extra["containerId"]; ok && json.Unmarshal(raw, &containerID) == nil && containerID != "" {
+		extra["containerId"], _ = json.Marshal(remapID(ids, containerID))
+	}
+
+	for _, key := range []string{"startBinding", "endBinding"} {
+		var binding map[string]json.RawMessage
+		raw, ok := extra[key]
+		if !ok || json.Unmarshal(raw, &binding) != nil || binding == nil {
+			continue
+		}
+		var id string
+		if json.Unmarshal(binding["elementId"], &id) == nil && id != "" {
+			binding["elementId"], _ = json.Marshal(remapID(ids, id))
+			extra[key], _ = json.Marshal(binding)
+		}
+	}
+
+	var bound []map[string]json.RawMessage
+	if raw, ok := extra["boundElements"]; ok && json.Unmarshal(raw, &bound) == nil && bound != nil {
+		for _, ref := range bound {
+			var id string
+			if json.Unmarshal(ref["id"], &id) == nil && id != "" {
+				ref["id"], _ = json.Marshal(remapID(ids, id))
+			}
+		}
+		extra["boundElements"], _ = json.Marshal(bound)
+	}
+}
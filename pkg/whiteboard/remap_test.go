@@ -0,0 +1,180 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+func TestAssignElementIDs(t *testing.T) {
+	board := parseElements(t, `[
+		{"id":"rect-1","type":"rectangle","x":0,"y":0,"width":100,"height":60},
+		{"id":"frame-1","type":"frame","x":-50,"y":-50,"width":600,"height":400}
+	]`)
+	raw := `{"action":"add","elements":[
+		{"id":"rect-1","type":"rectangle","x":300,"y":0,"width":100,"height":60,"frameId":"frame-1"},
+		{"id":"label-1","type":"text","x":310,"y":20,"text":"New","containerId":"rect-1"},
+		{"id":"arrow-1","type":"arrow","x":100,"y":30,"start":{"id":"rect-1"},"end":{"id":"rect-2"},
+			"startBinding":{"elementId":"rect-1","focus":0,"gap":4},"endBinding":{"elementId":"rect-2","focus":0,"gap":4}},
+		{"id":"rect-2","type":"rectangle","x":600,"y":0,"width":100,"height":60,
+			"boundElements":[{"id":"arrow-1","type":"arrow"}]},
+		{"id":"arrow-2","type":"arrow","x":0,"y":60,"start":{"id":"frame-1"},"end":{"id":"rect-2"}}
+	]}`
+
+	action := parseAction(t, raw)
+	ids := AssignElementIDs(action, board, "req-1")
+	if len(ids) != 5 {
+		t.Fatalf("got %d new IDs, want 5: %v", len(ids), ids)
+	}
+	taken := map[string]bool{"rect-1": true, "frame-1": true}
+	for i, element := range action.Elements {
+		if taken[element.ID] {
+			t.Errorf("element %d was given %s, which is already taken", i, element.ID)
+		}
+		taken[element.ID] = true
+	}
+
+	rect, label, arrow, rect2, arrow2 := action.Elements[0], action.Elements[1], action.Elements[2], action.Elements[3], action.Elements[4]
+	if rect.ID != ids["rect-1"] {
+		t.Errorf("reused rect-1 = %s, want %s", rect.ID, ids["rect-1"])
+	}
+	// The added rectangle shadows the board's rect-1 within the response,
+	// but the frame is only on the board, so it keeps its ID.
+	if rect.FrameID != "frame-1" {
+		t.Errorf("frameId = %q, want frame-1", rect.FrameID)
+	}
+	if got := extraString(label.Extra, "containerId"); got != rect.ID {
+		t.Errorf("label containerId = %q, want %q", got, rect.ID)
+	}
+	if arrow.Start.ID != rect.ID || arrow.End.ID != rect2.ID {
+		t.Errorf("arrow binds %s -> %s, want %s -> %s", arrow.Start.ID, arrow.End.ID, rect.ID, rect2.ID)
+	}
+	for key, want := range map[string]string{"startBinding": rect.ID, "endBinding": rect2.ID} {
+		var binding struct {
+			ElementID string  `json:"elementId"`
+			Gap       float64 `json:"gap"`
+		}
+		if err := json.Unmarshal(arrow.Extra[key], &binding); err != nil {
+			t.Fatalf("failed to parse %s: %v", key, err)
+		}
+		if binding.ElementID != want || binding.Gap != 4 {
+			t.Errorf("%s = %+v, want elementId %s and its gap kept", key, binding, want)
+		}
+	}
+	var bound []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rect2.Extra["boundElements"], &bound); err != nil {
+		t.Fatalf("failed to parse boundElements: %v", err)
+	}
+	if len(bound) != 1 || bound[0].ID != arrow.ID {
+		t.Errorf("boundElements = %+v, want %s", bound, arrow.ID)
+	}
+	if arrow2.Start.ID != "frame-1" || arrow2.End.ID != rect2.ID {
+		t.Errorf("second arrow binds %s -> %s, want frame-1 -> %s", arrow2.Start.ID, arrow2.End.ID, rect2.ID)
+	}
+
+	// Replaying the instruction assigns the same IDs; another request doesn't.
+	replay := parseAction(t, raw)
+	AssignElementIDs(replay, board, "req-1")
+	other := parseAction(t, raw)
+	AssignElementIDs(other, board, "req-2")
+	for i := range action.Elements {
+		if replay.Elements[i].ID != action.Elements[i].ID {
+			t.Errorf("replayed element %d = %s, want %s", i, replay.Elements[i].ID, action.Elements[i].ID)
+		}
+		if other.Elements[i].ID == action.Elements[i].ID {
+			t.Errorf("element %d got %s for a different request", i, other.Elements[i].ID)
+		}
+	}
+
+	// An ID the board already holds is skipped, even one assigned before.
+	crowded := append(append([]llm.Element(nil), board...), llm.Element{ID: ids["label-1"], Type: "text"})
+	again := parseAction(t, raw)
+	AssignElementIDs(again, crowded, "req-1")
+	if again.Elements[1].ID == ids["label-1"] {
+		t.Errorf("label was given %s, which the board already holds", ids["label-1"])
+	}
+	if again.Elements[0].ID != rect.ID {
+		t.Errorf("rect = %s, want %s, unaffected by the label's collision", again.Elements[0].ID, rect.ID)
+	}
+}
+
+func TestAssignElementIDsLeavesOtherActions(t *testing.T) {
+	board := parseElements(t, `[{"id":"rect-1","type":"rectangle","x":0,"y":0}]`)
+	for _, raw := range []string{
+		`{"action":"update","elements":[{"id":"rect-1","type":"rectangle","x":10,"y":0}]}`,
+		`{"action":"delete","delete_ids":["rect-1"]}`,
+	} {
+		action := parseAction(t, raw)
+		if ids := AssignElementIDs(action, board, "req-1"); len(ids) != 0 {
+			t.Errorf("%s assigned %v, want nothing", action.Action, ids)
+		}
+		if len(action.Elements) > 0 && action.Elements[0].ID != "rect-1" {
+			t.Errorf("update changed rect-1 to %s", action.Elements[0].ID)
+		}
+		if len(action.DeleteIDs) > 0 && action.DeleteIDs[0] != "rect-1" {
+			t.Errorf("delete changed rect-1 to %s", action.DeleteIDs[0])
+		}
+	}
+}
+
+func TestRemapActionReferences(t *testing.T) {
+	// ids maps the IDs the model gave elements an earlier action added.
+	ids := map[string]string{"rect-1": "new-rect", "frame-1": "new-frame"}
+	tests := []struct {
+		name string
+		raw  string
+		// ids are the element IDs, or delete IDs, the action ends up with.
+		ids []string
+	}{
+		{
+			name: "update of an added element",
+			raw:  `{"action":"update","elements":[{"id":"rect-1","type":"rectangle","x":0,"y":0},{"id":"board-1","type":"ellipse","x":0,"y":0}]}`,
+			ids:  []string{"new-rect", "board-1"},
+		},
+		{
+			name: "delete of an added element",
+			raw:  `{"action":"delete","delete_ids":["board-1","rect-1"]}`,
+			ids:  []string{"board-1", "new-rect"},
+		},
+		{
+			// The IDs of elements an add adds are left for AssignElementIDs.
+			name: "add binding to an added element",
+			raw:  `{"action":"add","elements":[{"id":"rect-1","type":"arrow","x":0,"y":0,"start":{"id":"rect-1"},"end":{"id":"board-1"},"frameId":"frame-1"}]}`,
+			ids:  []string{"rect-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := parseAction(t, tt.raw)
+			RemapActionReferences(action, ids)
+
+			got := action.DeleteIDs
+			if action.Action != llm.ActionDelete {
+				got = elementIDs(action.Elements)
+			}
+			if !equalStrings(got, tt.ids) {
+				t.Errorf("IDs = %v, want %v", got, tt.ids)
+			}
+			for _, element := range action.Elements {
+				if element.Start != nil && element.Start.ID != "new-rect" {
+					t.Errorf("start = %s, want new-rect", element.Start.ID)
+				}
+				if element.End != nil && element.End.ID != "board-1" {
+					t.Errorf("end = %s, want board-1", element.End.ID)
+				}
+				if element.FrameID != "" && element.FrameID != "new-frame" {
+					t.Errorf("frameId = %s, want new-frame", element.FrameID)
+				}
+			}
+		})
+	}
+
+	action := parseAction(t, `{"action":"transform","operation":"copy_style","source_id":"rect-1","target_ids":["board-1","frame-1"]}`)
+	RemapActionReferences(action, ids)
+	if action.SourceID != "new-rect" || !equalStrings(action.TargetIDs, []string{"board-1", "new-frame"}) {
+		t.Errorf("transform = %s -> %v, want new-rect -> [board-1 new-frame]", action.SourceID, action.TargetIDs)
+	}
+}
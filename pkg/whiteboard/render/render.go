@@ -0,0 +1,363 @@
+// Package render draws boards as SVG, for embedding them in documents
+// without the Excalidraw app.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard"
+)
+
+// Drawing settings: the margin around the board and the size of arrowheads.
+const (
+	padding    = 20
+	arrowSize  = 15
+	background = "#ffffff"
+)
+
+// fontFamilies maps Excalidraw's font numbers to CSS font stacks that end in
+// fonts every viewer has. Unknown numbers get the hand-drawn stack.
+var fontFamilies = map[int]string{
+	1: "Virgil, 'Comic Sans MS', cursive",
+	2: "Helvetica, Arial, sans-serif",
+	3: "Cascadia, 'Courier New', monospace",
+	5: "Excalifont, 'Comic Sans MS', cursive",
+	6: "Nunito, 'Segoe UI', Arial, sans-serif",
+	7: "'Lilita One', Impact, sans-serif",
+	8: "'Comic Shanns', 'Comic Sans MS', cursive",
+	9: "'Liberation Sans', Arial, sans-serif",
+}
+
+// element is the part of an Excalidraw element SVG drawing needs.
+type element struct {
+	ID              string      `json:"id"`
+	Type            string      `json:"type"`
+	X               float64     `json:"x"`
+	Y               float64     `json:"y"`
+	Width           float64     `json:"width"`
+	Height          float64     `json:"height"`
+	Angle           float64     `json:"angle"`
+	StrokeColor     string      `json:"strokeColor"`
+	BackgroundColor string      `json:"backgroundColor"`
+	StrokeWidth     float64     `json:"strokeWidth"`
+	StrokeStyle     string      `json:"strokeStyle"`
+	Opacity         float64     `json:"opacity"`
+	Roundness       *roundness  `json:"roundness"`
+	Text            string      `json:"text"`
+	FontSize        float64     `json:"fontSize"`
+	FontFamily      int         `json:"fontFamily"`
+	LineHeight      float64     `json:"lineHeight"`
+	TextAlign       string      `json:"textAlign"`
+	ContainerID     *string     `json:"containerId"`
+	Points          [][]float64 `json:"points"`
+	StartArrowhead  *string     `json:"startArrowhead"`
+	EndArrowhead    *string     `json:"endArrowhead"`
+	Name            *string     `json:"name"`
+}
+
+type roundness struct {
+	Type int `json:"type"`
+}
+
+// SVG draws a board as a self-contained SVG document. Elements are completed
+// as for an .excalidraw export, so labels and the model's shorter form draw
+// like they do on the board, and are drawn from back to front in the
+// board's stacking order. Shapes, text and arrows use their stored colors,
+// stroke widths and stroke styles; fills are drawn solid whatever their fill
+// style, and rounded arrows and lines curve through their points. Images
+// and types SVG can't draw become a dashed placeholder box. The view box is
+// the elements' bounding box with padding around it.
+func SVG(elements []llm.Element) ([]byte, error) {
+	file, err := whiteboard.ToExcalidrawFile(elements, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(file.Elements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode elements: %w", err)
+	}
+	var drawn []element
+	if err := json.Unmarshal(data, &drawn); err != nil {
+		return nil, fmt.Errorf("failed to decode elements: %w", err)
+	}
+
+	byID := make(map[string]element, len(drawn))
+	for _, e := range drawn {
+		byID[e.ID] = e
+	}
+
+	minX, minY, maxX, maxY := bounds(drawn)
+	width, height := maxX-minX+2*padding, maxY-minY+2*padding
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s %s %s %s" width="%s" height="%s">`+"\n",
+		num(minX-padding), num(minY-padding), num(width), num(height), num(width), num(height))
+	fmt.Fprintf(&svg, `  <rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+		num(minX-padding), num(minY-padding), num(width), num(height), background)
+	for _, e := range drawn {
+		var container *element
+		if e.ContainerID != nil {
+			if c, ok := byID[*e.ContainerID]; ok {
+				container = &c
+			}
+		}
+		draw(&svg, e, container)
+	}
+	svg.WriteString("</svg>\n")
+	return svg.Bytes(), nil
+}
+
+// bounds returns the box the elements cover, or an empty one at the origin
+// for a board without any.
+func bounds(elements []element) (minX, minY, maxX, maxY float64) {
+	if len(elements) == 0 {
+		return 0, 0, 0, 0
+	}
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	extend := func(x, y float64) {
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	for _, e := range elements {
+		if len(e.Points) > 0 {
+			for _, point := range e.Points {
+				if len(point) == 2 {
+					extend(e.X+point[0], e.Y+point[1])
+				}
+			}
+			continue
+		}
+		extend(e.X, e.Y)
+		extend(e.X+e.Width, e.Y+e.Height)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// draw writes one element. Labels of arrows get a background behind them, so
+// the arrow doesn't run through the text.
+func draw(svg *bytes.Buffer, e element, container *element) {
+	attrs := paint(e)
+	transform := ""
+	if e.Angle != 0 {
+		transform = fmt.Sprintf(` transform="rotate(%s %s %s)"`, num(e.Angle*180/math.Pi), num(e.X+e.Width/2), num(e.Y+e.Height/2))
+	}
+
+	switch e.Type {
+	case "rectangle":
+		radius := 0.0
+		if e.Roundness != nil {
+			radius = math.Min(32, math.Min(math.Abs(e.Width), math.Abs(e.Height))/4)
+		}
+		fmt.Fprintf(svg, `  <rect x="%s" y="%s" width="%s" height="%s" rx="%s"%s%s/>`+"\n",
+			num(e.X), num(e.Y), num(e.Width), num(e.Height), num(radius), attrs, transform)
+	case "ellipse":
+		fmt.Fprintf(svg, `  <ellipse cx="%s" cy="%s" rx="%s" ry="%s"%s%s/>`+"\n",
+			num(e.X+e.Width/2), num(e.Y+e.Height/2), num(e.Width/2), num(e.Height/2), attrs, transform)
+	case "diamond":
+		cx, cy := e.X+e.Width/2, e.Y+e.Height/2
+		fmt.Fprintf(svg, `  <polygon points="%s,%s %s,%s %s,%s %s,%s"%s%s/>`+"\n",
+			num(cx), num(e.Y), num(e.X+e.Width), num(cy), num(cx), num(e.Y+e.Height), num(e.X), num(cy), attrs, transform)
+	case "text":
+		if container != nil && (container.Type == "arrow" || container.Type == "line") {
+			fmt.Fprintf(svg, `  <rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+				num(e.X-4), num(e.Y-2), num(e.Width+8), num(e.Height+4), background)
+		}
+		drawText(svg, e, transform)
+	case "arrow", "line", "freedraw":
+		drawLinear(svg, e, transform)
+	case "frame":
+		fmt.Fprintf(svg, `  <rect x="%s" y="%s" width="%s" height="%s" rx="8" fill="none" stroke="#bbbbbb" stroke-width="1"%s/>`+"\n",
+			num(e.X), num(e.Y), num(e.Width), num(e.Height), transform)
+		if e.Name != nil && *e.Name != "" {
+			fmt.Fprintf(svg, `  <text x="%s" y="%s" font-family="%s" font-size="14" fill="#999999">%s</text>`+"\n",
+				num(e.X), num(e.Y-6), fontFamilies[2], html.EscapeString(*e.Name))
+		}
+	default:
+		drawPlaceholder(svg, e, transform)
+	}
+}
+
+// paint returns the stroke and fill attributes of a shape.
+func paint(e element) string {
+	var attrs strings.Builder
+	fill := "none"
+	if e.BackgroundColor != "" && e.BackgroundColor != "transparent" {
+		fill = e.BackgroundColor
+	}
+	fmt.Fprintf(&attrs, ` fill="%s" stroke="%s" stroke-width="%s" stroke-linejoin="round" stroke-linecap="round"`,
+		html.EscapeString(fill), html.EscapeString(strokeColor(e)), num(e.StrokeWidth))
+	switch e.StrokeStyle {
+	case "dashed":
+		fmt.Fprintf(&attrs, ` stroke-dasharray="8 %s"`, num(8+e.StrokeWidth))
+	case "dotted":
+		fmt.Fprintf(&attrs, ` stroke-dasharray="1.5 %s"`, num(6+e.StrokeWidth))
+	}
+	if e.Opacity > 0 && e.Opacity < 100 {
+		fmt.Fprintf(&attrs, ` opacity="%s"`, num(e.Opacity/100))
+	}
+	return attrs.String()
+}
+
+func strokeColor(e element) string {
+	if e.StrokeColor == "" || e.StrokeColor == "transparent" {
+		return "#1e1e1e"
+	}
+	return e.StrokeColor
+}
+
+// drawText writes text a line per tspan, aligned within its box.
+func drawText(svg *bytes.Buffer, e element, transform string) {
+	fontSize := e.FontSize
+	if fontSize <= 0 {
+		fontSize = 20
+	}
+	family, ok := fontFamilies[e.FontFamily]
+	if !ok {
+		family = fontFamilies[whiteboard.FontExcalifont]
+	}
+	lineHeight := e.LineHeight
+	if lineHeight <= 0 {
+		lineHeight = whiteboard.LineHeight(e.FontFamily)
+	}
+	anchor, x := "start", e.X
+	switch e.TextAlign {
+	case "center":
+		anchor, x = "middle", e.X+e.Width/2
+	case "right":
+		anchor, x = "end", e.X+e.Width
+	}
+	opacity := ""
+	if e.Opacity > 0 && e.Opacity < 100 {
+		opacity = fmt.Sprintf(` opacity="%s"`, num(e.Opacity/100))
+	}
+
+	fmt.Fprintf(svg, `  <text font-family="%s" font-size="%s" fill="%s" text-anchor="%s"%s%s>`,
+		family, num(fontSize), html.EscapeString(strokeColor(e)), anchor, opacity, transform)
+	for i, line := range strings.Split(e.Text, "\n") {
+		// The first baseline sits one font size below the top, roughly the
+		// ascent of Excalidraw's fonts, and later ones a line apart.
+		y := e.Y + fontSize + float64(i)*fontSize*lineHeight
+		fmt.Fprintf(svg, `<tspan x="%s" y="%s">%s</tspan>`, num(x), num(y), html.EscapeString(line))
+	}
+	svg.WriteString("</text>\n")
+}
+
+// drawLinear writes an arrow, line or freehand stroke through its points,
+// with the arrowheads it has.
+func drawLinear(svg *bytes.Buffer, e element, transform string) {
+	points := make([][2]float64, 0, len(e.Points))
+	for _, point := range e.Points {
+		if len(point) == 2 {
+			points = append(points, [2]float64{e.X + point[0], e.Y + point[1]})
+		}
+	}
+	if len(points) < 2 {
+		return
+	}
+
+	// Arrowheads point along the path where it meets them.
+	startFrom, endFrom := points[1], points[len(points)-2]
+	var path strings.Builder
+	fmt.Fprintf(&path, "M%s %s", num(points[0][0]), num(points[0][1]))
+	if e.Roundness != nil && e.Type != "freedraw" && len(points) > 2 {
+		// Rounded lines curve smoothly through their points, as a
+		// Catmull-Rom spline drawn with cubic Béziers.
+		for i := 0; i < len(points)-1; i++ {
+			p0, p1, p2, p3 := points[max(i-1, 0)], points[i], points[i+1], points[min(i+2, len(points)-1)]
+			c1 := [2]float64{p1[0] + (p2[0]-p0[0])/6, p1[1] + (p2[1]-p0[1])/6}
+			c2 := [2]float64{p2[0] - (p3[0]-p1[0])/6, p2[1] - (p3[1]-p1[1])/6}
+			fmt.Fprintf(&path, " C%s %s %s %s %s %s", num(c1[0]), num(c1[1]), num(c2[0]), num(c2[1]), num(p2[0]), num(p2[1]))
+			if i == 0 {
+				startFrom = c1
+			}
+			endFrom = c2
+		}
+	} else {
+		for _, point := range points[1:] {
+			fmt.Fprintf(&path, " L%s %s", num(point[0]), num(point[1]))
+		}
+	}
+	stroke := e
+	stroke.BackgroundColor = ""
+	attrs := paint(stroke)
+	fmt.Fprintf(svg, `  <path d="%s"%s%s/>`+"\n", path.String(), attrs, transform)
+
+	if e.StartArrowhead != nil && *e.StartArrowhead != "" {
+		drawArrowhead(svg, e, *e.StartArrowhead, startFrom, points[0])
+	}
+	if e.EndArrowhead != nil && *e.EndArrowhead != "" {
+		drawArrowhead(svg, e, *e.EndArrowhead, endFrom, points[len(points)-1])
+	}
+}
+
+// drawArrowhead writes an arrowhead at tip, pointing away from from.
+// Excalidraw's "triangle" is filled, "bar" is a cross stroke, "dot" and
+// "circle" are circles, and every other head is an open "arrow".
+func drawArrowhead(svg *bytes.Buffer, e element, head string, from [2]float64, tip [2]float64) {
+	dx, dy := tip[0]-from[0], tip[1]-from[1]
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	ux, uy := dx/length, dy/length
+	size := math.Min(arrowSize+e.StrokeWidth, length/2)
+	color := html.EscapeString(strokeColor(e))
+	// The two corners of the head, 25 degrees either side of the shaft.
+	spread := math.Tan(25 * math.Pi / 180)
+	leftX, leftY := tip[0]-size*ux+size*spread*uy, tip[1]-size*uy-size*spread*ux
+	rightX, rightY := tip[0]-size*ux-size*spread*uy, tip[1]-size*uy+size*spread*ux
+
+	switch head {
+	case "triangle", "triangle_outline":
+		fill := color
+		if head == "triangle_outline" {
+			fill = background
+		}
+		fmt.Fprintf(svg, `  <polygon points="%s,%s %s,%s %s,%s" fill="%s" stroke="%s" stroke-width="%s" stroke-linejoin="round"/>`+"\n",
+			num(tip[0]), num(tip[1]), num(leftX), num(leftY), num(rightX), num(rightY), fill, color, num(e.StrokeWidth))
+	case "bar":
+		half := size / 2
+		fmt.Fprintf(svg, `  <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s" stroke-linecap="round"/>`+"\n",
+			num(tip[0]+half*uy), num(tip[1]-half*ux), num(tip[0]-half*uy), num(tip[1]+half*ux), color, num(e.StrokeWidth))
+	case "dot", "circle", "circle_outline":
+		fill := color
+		if head == "circle_outline" {
+			fill = background
+		}
+		fmt.Fprintf(svg, `  <circle cx="%s" cy="%s" r="%s" fill="%s" stroke="%s" stroke-width="%s"/>`+"\n",
+			num(tip[0]-size/3*ux), num(tip[1]-size/3*uy), num(size/3), fill, color, num(e.StrokeWidth))
+	default:
+		fmt.Fprintf(svg, `  <polyline points="%s,%s %s,%s %s,%s" fill="none" stroke="%s" stroke-width="%s" stroke-linejoin="round" stroke-linecap="round"/>`+"\n",
+			num(leftX), num(leftY), num(tip[0]), num(tip[1]), num(rightX), num(rightY), color, num(e.StrokeWidth))
+	}
+}
+
+// drawPlaceholder writes a dashed, crossed-out box where an element SVG
+// can't show was.
+func drawPlaceholder(svg *bytes.Buffer, e element, transform string) {
+	width, height := math.Max(e.Width, 20), math.Max(e.Height, 20)
+	fmt.Fprintf(svg, `  <g%s>`+"\n", transform)
+	fmt.Fprintf(svg, `    <rect x="%s" y="%s" width="%s" height="%s" fill="#f1f3f5" stroke="#868e96" stroke-width="1" stroke-dasharray="6 4"/>`+"\n",
+		num(e.X), num(e.Y), num(width), num(height))
+	fmt.Fprintf(svg, `    <path d="M%s %s L%s %s M%s %s L%s %s" stroke="#868e96" stroke-width="1"/>`+"\n",
+		num(e.X), num(e.Y), num(e.X+width), num(e.Y+height), num(e.X+width), num(e.Y), num(e.X), num(e.Y+height))
+	svg.WriteString("  </g>\n")
+}
+
+// num formats a coordinate to two decimals, without trailing zeros.
+func num(v float64) string {
+	rounded := math.Round(v*100) / 100
+	if rounded == 0 {
+		// Drop the sign of negative zero.
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestSVGGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		board string
+	}{
+		{
+			name: "shapes",
+			board: `[
+				{"id":"r","type":"rectangle","x":0,"y":0,"width":160,"height":80,"backgroundColor":"#a5d8ff","strokeColor":"#1971c2","label":{"text":"Login"}},
+				{"id":"e","type":"ellipse","x":200,"y":0,"width":120,"height":80,"strokeStyle":"dashed","strokeWidth":4},
+				{"id":"d","type":"diamond","x":360,"y":0,"width":120,"height":100,"strokeStyle":"dotted","opacity":50,"label":{"text":"Two\nlines"}},
+				{"id":"sharp","type":"rectangle","x":0,"y":120,"width":80,"height":40,"roundness":null,"angle":0.7853981634}
+			]`,
+		},
+		{
+			name: "text",
+			board: `[
+				{"id":"t1","type":"text","x":0,"y":0,"text":"Title & <notes>","fontSize":28},
+				{"id":"t2","type":"text","x":0,"y":60,"width":200,"text":"Centered\nthen more","textAlign":"center","fontFamily":2},
+				{"id":"t3","type":"text","x":0,"y":140,"width":200,"text":"Right","textAlign":"right","fontFamily":42,"strokeColor":"#e03131"}
+			]`,
+		},
+		{
+			name: "arrows",
+			board: `[
+				{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":60},
+				{"id":"b","type":"rectangle","x":300,"y":0,"width":100,"height":60},
+				{"id":"ab","type":"arrow","x":100,"y":30,"width":200,"height":0,"points":[[0,0],[200,0]],"start":{"id":"a"},"end":{"id":"b"},"label":{"text":"calls"}},
+				{"id":"curve","type":"arrow","x":50,"y":60,"points":[[0,0],[100,80],[300,0]],"startArrowhead":"dot","endArrowhead":"triangle","strokeStyle":"dashed"},
+				{"id":"bar","type":"arrow","x":0,"y":200,"points":[[0,0],[150,0]],"roundness":null,"startArrowhead":"bar","endArrowhead":"circle_outline"},
+				{"id":"plain","type":"line","x":0,"y":240,"points":[[0,0],[150,20]],"strokeColor":"#2f9e44"}
+			]`,
+		},
+		{
+			name: "unknown types",
+			board: `[
+				{"id":"img","type":"image","x":0,"y":0,"width":120,"height":90},
+				{"id":"magic","type":"hologram","x":150,"y":0,"width":5,"height":5},
+				{"id":"f","type":"frame","x":-20,"y":-40,"width":300,"height":160,"name":"Assets"}
+			]`,
+		},
+		{name: "empty", board: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var elements []llm.Element
+			if err := json.Unmarshal([]byte(tt.board), &elements); err != nil {
+				t.Fatalf("failed to parse board: %v", err)
+			}
+			svg, err := SVG(elements)
+			if err != nil {
+				t.Fatalf("SVG: %v", err)
+			}
+			assertWellFormed(t, svg)
+
+			path := filepath.Join("testdata", strings.ReplaceAll(tt.name, " ", "_")+".svg")
+			if *update {
+				if err := os.MkdirAll("testdata", 0o755); err != nil {
+					t.Fatalf("failed to create testdata: %v", err)
+				}
+				if err := os.WriteFile(path, svg, 0o644); err != nil {
+					t.Fatalf("failed to write %s: %v", path, err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read %s, run with -update to create it: %v", path, err)
+			}
+			if !bytes.Equal(svg, want) {
+				t.Errorf("%s differs from the golden file, run with -update if the change is intended:\n%s", path, svg)
+			}
+		})
+	}
+}
+
+func TestSVGViewBox(t *testing.T) {
+	elements := []llm.Element{
+		{ID: "a", Type: "rectangle", X: -50, Y: 10, Width: 100, Height: 40},
+		{ID: "b", Type: "ellipse", X: 200, Y: 300, Width: 60, Height: 60},
+	}
+	svg, err := SVG(elements)
+	if err != nil {
+		t.Fatalf("SVG: %v", err)
+	}
+	// From (-50, 10) to (260, 360), padded on every side.
+	want := `viewBox="-70 -10 350 390" width="350" height="390"`
+	if !bytes.Contains(svg, []byte(want)) {
+		t.Errorf("SVG = %s, want %s", svg, want)
+	}
+}
+
+// assertWellFormed fails unless svg parses as XML with an svg root.
+func assertWellFormed(t *testing.T, svg []byte) {
+	t.Helper()
+	decoder := xml.NewDecoder(bytes.NewReader(svg))
+	root := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("SVG is not well-formed: %v\n%s", err, svg)
+		}
+		if start, ok := token.(xml.StartElement); ok && root == "" {
+			root = start.Name.Local
+		}
+	}
+	if root != "svg" {
+		t.Errorf("root element = %q, want svg", root)
+	}
+}
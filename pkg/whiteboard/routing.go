@@ -0,0 +1,184 @@
+package whiteboard
+
+import (
+	"encoding/json"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard/layout"
+)
+
+// RouteArrows computes the points of the arrows an add, update or replace
+// action gives a routing, with layout.Route: between the shapes they are
+// bound to, where those are in the action or on the board, and otherwise
+// between their own ends. The routing is recorded in the arrow's customData,
+// where clients keep it, and elbow arrows get sharp corners. When an update
+// moves or resizes shapes, the board's elbow and curved arrows bound to them
+// are rerouted too and added to the update. Unknown routings are dropped.
+// Actions with nothing to route are returned unchanged.
+func RouteArrows(action *llm.WhiteboardAction, board []llm.Element) *llm.WhiteboardAction {
+	if action.Action != llm.ActionAdd && action.Action != llm.ActionUpdate && action.Action != llm.ActionReplace {
+		return action
+	}
+
+	existing := make(map[string]llm.Element, len(board))
+	if action.Action != llm.ActionReplace {
+		for _, element := range board {
+			if element.ID != "" && !isDeleted(element) {
+				existing[element.ID] = element
+			}
+		}
+	}
+	// shapes holds every element where the action leaves it.
+	shapes := make(map[string]llm.Element, len(existing)+len(action.Elements))
+	for id, element := range existing {
+		shapes[id] = element
+	}
+	moved := make(map[string]bool)
+	updated := make(map[string]bool)
+	for _, element := range action.Elements {
+		if element.ID == "" {
+			continue
+		}
+		updated[element.ID] = true
+		if action.Action != llm.ActionUpdate {
+			shapes[element.ID] = element
+			continue
+		}
+		original, ok := existing[element.ID]
+		if !ok || isConnector(original) {
+			continue
+		}
+		merged, err := mergeElement(original, element)
+		if err != nil {
+			continue
+		}
+		shapes[element.ID] = merged
+		x1, y1, x2, y2 := elementBounds(original)
+		u1, v1, u2, v2 := elementBounds(merged)
+		if x1 != u1 || y1 != v1 || x2 != u2 || y2 != v2 {
+			moved[element.ID] = true
+		}
+	}
+
+	var routed *llm.WhiteboardAction
+	edit := func() *llm.WhiteboardAction {
+		if routed == nil {
+			copied := *action
+			copied.Elements = append([]llm.Element(nil), action.Elements...)
+			routed = &copied
+		}
+		return routed
+	}
+
+	for i, element := range action.Elements {
+		if element.Routing == "" || !isConnector(element) {
+			continue
+		}
+		switch element.Routing {
+		case llm.RoutingStraight, llm.RoutingElbow, llm.RoutingCurved:
+		default:
+			edit().Elements[i].Routing = ""
+			continue
+		}
+		if action.Action != llm.ActionUpdate {
+			edit().Elements[i] = routeArrow(element, element.Routing, shapes)
+			continue
+		}
+		original, ok := existing[element.ID]
+		if !ok {
+			continue
+		}
+		merged, err := mergeElement(original, element)
+		if err != nil {
+			continue
+		}
+		edit().Elements[i] = routedUpdate(element, routeArrow(merged, element.Routing, shapes))
+	}
+
+	if len(moved) > 0 {
+		for _, arrow := range board {
+			routing := routingOf(arrow)
+			if updated[arrow.ID] || isDeleted(arrow) || !isConnector(arrow) || (routing != llm.RoutingElbow && routing != llm.RoutingCurved) {
+				continue
+			}
+			start, end := arrowEnds(arrow)
+			if !moved[start] && !moved[end] {
+				continue
+			}
+			update := routedUpdate(llm.Element{ID: arrow.ID, Type: arrow.Type}, routeArrow(arrow, routing, shapes))
+			edit().Elements = append(edit().Elements, update)
+		}
+	}
+
+	if routed == nil {
+		return action
+	}
+	return routed
+}
+
+// routeArrow routes arrow between the shapes its ends are bound to. An end
+// that isn't bound to one of shapes stays where it is, as a shape of no
+// size. Arrows from a shape back to itself are left as they are.
+func routeArrow(arrow llm.Element, routing string, shapes map[string]llm.Element) llm.Element {
+	start, end := arrowEnds(arrow)
+	if start != "" && start == end {
+		return arrow
+	}
+	from, ok := shapes[start]
+	if !ok {
+		from = llm.Element{X: arrow.X, Y: arrow.Y}
+	}
+	to, ok := shapes[end]
+	if !ok {
+		to = llm.Element{X: arrow.X + arrow.Width, Y: arrow.Y + arrow.Height}
+		if n := len(arrow.Points); n >= 2 && len(arrow.Points[n-1]) == 2 {
+			to = llm.Element{X: arrow.X + arrow.Points[n-1][0], Y: arrow.Y + arrow.Points[n-1][1]}
+		}
+	}
+
+	arrow.Routing = routing
+	arrow = layout.Route(arrow, from, to)
+	if len(arrow.Points) == 0 {
+		arrow.Points = [][]float64{{0, 0}, {arrow.Width, arrow.Height}}
+	}
+
+	customData := make(map[string]json.RawMessage)
+	if raw, ok := arrow.Extra["customData"]; ok {
+		_ = json.Unmarshal(raw, &customData)
+	}
+	customData["routing"], _ = json.Marshal(routing)
+	data, _ := json.Marshal(customData)
+	setExtra(&arrow, "customData", data)
+	if routing == llm.RoutingElbow {
+		setExtra(&arrow, "roundness", json.RawMessage("null"))
+	} else {
+		setExtra(&arrow, "roundness", json.RawMessage(`{"type":2}`))
+	}
+	return arrow
+}
+
+// routedUpdate adds the geometry and routing of the routed arrow to update.
+func routedUpdate(update llm.Element, routed llm.Element) llm.Element {
+	update.X, update.Y = routed.X, routed.Y
+	update.Width, update.Height = routed.Width, routed.Height
+	update.Points = routed.Points
+	update.Routing = routed.Routing
+	setExtra(&update, "customData", routed.Extra["customData"])
+	setExtra(&update, "roundness", routed.Extra["roundness"])
+	return update
+}
+
+// routingOf returns the routing of an arrow, from the model's field or from
+// the customData it is recorded in.
+func routingOf(element llm.Element) string {
+	if element.Routing != "" {
+		return element.Routing
+	}
+	var data struct {
+		Routing string `json:"routing"`
+	}
+	if raw, ok := element.Extra["customData"]; ok && json.Unmarshal(raw, &data) == nil {
+		return data.Routing
+	}
+	return ""
+}
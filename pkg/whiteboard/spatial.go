@@ -0,0 +1,384 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard/colors"
+)
+
+// ErrNoReference is returned when no element fits a description.
+var ErrNoReference = errors.New("no element matches the description")
+
+// ErrAmbiguousReference is returned when a description fits several
+// elements equally well.
+var ErrAmbiguousReference = errors.New("several elements match the description")
+
+// tieTolerance is how far apart, in pixels, two elements must be along the
+// direction a description names for it to tell them apart.
+const tieTolerance = 10
+
+// anyShape is the type of nouns such as "one" or "shape", which name any
+// element but connectors and frames.
+const anyShape = "*"
+
+// referenceNouns are the nouns speech uses for elements, by the type they
+// name.
+var referenceNouns = map[string]string{
+	"box": "rectangle", "rectangle": "rectangle", "rect": "rectangle", "square": "rectangle",
+	"circle": "ellipse", "ellipse": "ellipse", "oval": "ellipse",
+	"diamond": "diamond", "rhombus": "diamond",
+	"text": "text", "title": "text", "heading": "text",
+	"arrow": "arrow", "connector": "arrow", "line": "line",
+	"sticky": StickyType, "note": StickyType, "postit": StickyType,
+	"frame": "frame", "group": "frame", "section": "frame",
+	"image": "image", "picture": "image", "photo": "image", "logo": "image",
+	"one": anyShape, "shape": anyShape, "element": anyShape, "thing": anyShape,
+}
+
+// spatialWords are the words for where an element is, as the direction they
+// point in, x to the right and y down. Middle has none.
+var spatialWords = map[string][2]float64{
+	"left": {-1, 0}, "leftmost": {-1, 0},
+	"right": {1, 0}, "rightmost": {1, 0},
+	"top": {0, -1}, "topmost": {0, -1}, "upper": {0, -1}, "uppermost": {0, -1}, "highest": {0, -1},
+	"bottom": {0, 1}, "bottommost": {0, 1}, "lower": {0, 1}, "lowest": {0, 1},
+	"middle": {}, "center": {}, "centre": {}, "central": {},
+}
+
+// relationWords, before a description, make it the landmark another
+// element is placed by, as in "the box next to the top circle". "And" is
+// one for "between the left box and the right one".
+var relationWords = map[string]bool{
+	"to": true, "near": true, "beside": true, "below": true, "above": true, "under": true,
+	"over": true, "behind": true, "between": true, "and": true,
+}
+
+// phrasePrepositions join a noun to the spatial words after it, as in "the
+// box on the left" or "the circle at the very top".
+var phrasePrepositions = map[string]bool{"on": true, "at": true, "in": true}
+
+// ResolveReference returns the ID of the element desc describes by its type,
+// color and place on the board, such as "the box on the left", "the top
+// circle" or "the blue one in the middle". Elements of the type and color
+// named are compared along the direction the spatial words name, with
+// "top left" naming a corner; without spatial words the description must
+// fit one element alone. ErrNoReference is returned when nothing fits, on an
+// empty board for one, and ErrAmbiguousReference when the best elements are
+// within tieTolerance of each other.
+func ResolveReference(desc string, elements []llm.Element) (string, error) {
+	ref := parseReference(tokenize(desc))
+	candidates := ref.candidates(elements)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("%w %q", ErrNoReference, desc)
+	}
+
+	var best []llm.Element
+	if ref.spatial {
+		best = ref.closest(candidates)
+	} else {
+		best = candidates
+	}
+	if len(best) > 1 {
+		return "", fmt.Errorf("%w %q: %d elements", ErrAmbiguousReference, desc, len(best))
+	}
+	return best[0].ID, nil
+}
+
+// reference is a parsed description of an element.
+type reference struct {
+	// kind is the type named, "" for none.
+	kind string
+	// bg and stroke are the color named, "" for none.
+	bg, stroke string
+	// spatial is set when the description says where the element is;
+	// direction is the way it points, zero for the middle.
+	spatial   bool
+	direction [2]float64
+}
+
+func parseReference(words []string) reference {
+	var ref reference
+	for _, word := range words {
+		if kind, ok := referenceNouns[word]; ok {
+			ref.kind = kind
+			continue
+		}
+		if direction, ok := spatialWords[word]; ok {
+			ref.spatial = true
+			ref.direction[0] += direction[0]
+			ref.direction[1] += direction[1]
+			continue
+		}
+		if bg, stroke, ok := colorWord(word); ok {
+			ref.bg, ref.stroke = bg, stroke
+		}
+	}
+	return ref
+}
+
+// colorWord resolves word as a palette color. Words that name an element or
+// a place are never colors, however close they are to one.
+func colorWord(word string) (bg, stroke string, ok bool) {
+	if _, noun := referenceNouns[word]; noun {
+		return "", "", false
+	}
+	if _, place := spatialWords[word]; place {
+		return "", "", false
+	}
+	return colors.ResolveColor(word)
+}
+
+// candidates returns the elements of the type and color ref names.
+func (ref reference) candidates(elements []llm.Element) []llm.Element {
+	var candidates []llm.Element
+	for _, element := range elements {
+		if element.ID == "" || isDeleted(element) || extraString(element.Extra, "containerId") != "" {
+			continue
+		}
+		switch ref.kind {
+		case "", anyShape:
+			if isConnector(element) || element.Type == "frame" {
+				continue
+			}
+		case StickyType:
+			if !IsSticky(element) {
+				continue
+			}
+		default:
+			if element.Type != ref.kind {
+				continue
+			}
+		}
+		if ref.bg != "" && !strings.EqualFold(element.BackgroundColor, ref.bg) && !strings.EqualFold(element.StrokeColor, ref.stroke) {
+			continue
+		}
+		candidates = append(candidates, element)
+	}
+	return candidates
+}
+
+// closest returns the candidates furthest along ref's direction, or, for the
+// middle, nearest the centre of them all, with those tied for it.
+func (ref reference) closest(candidates []llm.Element) []llm.Element {
+	centers := make([][2]float64, len(candidates))
+	var midX, midY float64
+	for i, element := range candidates {
+		x1, y1, x2, y2 := elementBounds(element)
+		centers[i] = [2]float64{(x1 + x2) / 2, (y1 + y2) / 2}
+		midX += centers[i][0] / float64(len(candidates))
+		midY += centers[i][1] / float64(len(candidates))
+	}
+
+	// Corners weigh both axes, so their scores are normalised to keep the
+	// tolerance a distance.
+	dx, dy := ref.direction[0], ref.direction[1]
+	length := math.Hypot(dx, dy)
+	score := func(center [2]float64) float64 {
+		if length == 0 {
+			return -math.Hypot(center[0]-midX, center[1]-midY)
+		}
+		return (dx*center[0] + dy*center[1]) / length
+	}
+
+	best := math.Inf(-1)
+	for _, center := range centers {
+		best = math.Max(best, score(center))
+	}
+	var closest []llm.Element
+	for i, element := range candidates {
+		if score(centers[i]) >= best-tieTolerance {
+			closest = append(closest, element)
+		}
+	}
+	return closest
+}
+
+// spatialPhrase is a description of an element by where it is, found in an
+// instruction.
+type spatialPhrase struct {
+	text string
+	// landmark is set when the instruction places something by the
+	// element rather than acting on it.
+	landmark bool
+}
+
+// spatialPhrases finds the descriptions of elements by where they are in
+// instruction: a noun with the spatial and color words before it, as in "the
+// top left box", and the ones after it, as in "the box on the left".
+func spatialPhrases(instruction string) []spatialPhrase {
+	words := tokenize(instruction)
+	var phrases []spatialPhrase
+	for i, word := range words {
+		if _, ok := referenceNouns[word]; !ok {
+			continue
+		}
+		spatial := false
+		start := i
+		for start > 0 {
+			previous := words[start-1]
+			if _, ok := spatialWords[previous]; ok {
+				spatial = true
+			} else if _, _, ok := colorWord(previous); !ok {
+				break
+			}
+			start--
+		}
+		end := i + 1
+		if end < len(words) && phrasePrepositions[words[end]] {
+			after := end + 1
+			for after < len(words) && (words[after] == "the" || words[after] == "very" || words[after] == "far") {
+				after++
+			}
+			places := after
+			for places < len(words) {
+				if _, ok := spatialWords[words[places]]; !ok {
+					break
+				}
+				places++
+			}
+			// "On the left of" places the noun by something else.
+			if places > after && (places == len(words) || words[places] != "of") {
+				spatial = true
+				end = places
+			}
+		}
+		if !spatial {
+			continue
+		}
+
+		before := start - 1
+		for before >= 0 && (words[before] == "the" || words[before] == "a" || words[before] == "an") {
+			before--
+		}
+		landmark := before >= 0 && relationWords[words[before]]
+		if before > 0 && words[before] == "of" {
+			// "Left of" and "on top of" relate; "the text of" doesn't.
+			_, landmark = spatialWords[words[before-1]]
+		}
+		phrases = append(phrases, spatialPhrase{
+			text:     strings.Join(words[start:end], " "),
+			landmark: landmark,
+		})
+	}
+	return phrases
+}
+
+// ResolveSpatialReferents finds the elements the instruction describes by
+// where they are, such as "the box on the left", so they can be handed to
+// the model as IDs alongside those ResolveReferents finds by label.
+// Descriptions that fit no element, or several, are left to the model.
+func ResolveSpatialReferents(instruction string, elements []llm.Element) []llm.Referent {
+	var referents []llm.Referent
+	for _, phrase := range spatialPhrases(instruction) {
+		id, err := ResolveReference(phrase.text, elements)
+		if err != nil {
+			continue
+		}
+		referents = append(referents, llm.Referent{Phrase: phrase.text, ElementID: id})
+	}
+	return referents
+}
+
+// CorrectSpatialTarget retargets an update or delete of one element to the
+// element the instruction describes by where it is, when the model picked
+// another element of the same type and color. It only acts when the
+// instruction describes exactly one element it acts on that way, and that
+// description fits one element; landmarks, as in "the box next to the top
+// circle", are not targets. An update is moved onto the right element with
+// the changes it makes to the wrong one, and positions shift by as much.
+// Other actions, and actions that already target the element described, are
+// returned unchanged.
+func CorrectSpatialTarget(action *llm.WhiteboardAction, board []llm.Element, instruction string) *llm.WhiteboardAction {
+	var target string
+	switch {
+	case action.Action == llm.ActionUpdate && len(action.Elements) == 1:
+		target = action.Elements[0].ID
+	case action.Action == llm.ActionDelete && len(action.DeleteIDs) == 1:
+		target = action.DeleteIDs[0]
+	default:
+		return action
+	}
+
+	var described string
+	for _, phrase := range spatialPhrases(instruction) {
+		if phrase.landmark {
+			continue
+		}
+		if described != "" {
+			return action
+		}
+		described = phrase.text
+	}
+	if described == "" {
+		return action
+	}
+	id, err := ResolveReference(described, board)
+	if err != nil || id == target {
+		return action
+	}
+
+	// The model's pick must be a plausible misreading: an element the
+	// description fits but for where it is.
+	var wrong, right llm.Element
+	for _, element := range parseReference(tokenize(described)).candidates(board) {
+		switch element.ID {
+		case target:
+			wrong = element
+		case id:
+			right = element
+		}
+	}
+	if wrong.ID == "" || right.ID == "" {
+		return action
+	}
+
+	corrected := *action
+	if action.Action == llm.ActionDelete {
+		corrected.DeleteIDs = []string{id}
+		return &corrected
+	}
+	update, err := retarget(action.Elements[0], wrong, right)
+	if err != nil {
+		return action
+	}
+	corrected.Elements = []llm.Element{update}
+	return &corrected
+}
+
+// retarget moves update from the element wrong onto right: the properties it
+// sets to what wrong already has are dropped, as the model copies those
+// along, and its position is shifted by how far right is from wrong.
+func retarget(update llm.Element, wrong llm.Element, right llm.Element) (llm.Element, error) {
+	fields, err := elementObject(update)
+	if err != nil {
+		return llm.Element{}, err
+	}
+	original, err := elementObject(wrong)
+	if err != nil {
+		return llm.Element{}, err
+	}
+	for key, value := range fields {
+		if sameValue(value, original[key], false) {
+			delete(fields, key)
+		}
+	}
+	fields["x"] = right.X + update.X - wrong.X
+	fields["y"] = right.Y + update.Y - wrong.Y
+	fields["id"] = right.ID
+	fields["type"] = right.Type
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return llm.Element{}, err
+	}
+	var retargeted llm.Element
+	if err := json.Unmarshal(data, &retargeted); err != nil {
+		return llm.Element{}, err
+	}
+	return retargeted, nil
+}
@@ -0,0 +1,235 @@
+package whiteboard
+
+import (
+	"errors"
+	"testing"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard/colors"
+)
+
+// spatialBoard has three boxes in a row, two circles stacked to their right
+// and a blue box below, with a label, an arrow and a deleted box that no
+// description should pick.
+func spatialBoard(t *testing.T) []llm.Element {
+	t.Helper()
+	blue, _, _ := colors.ResolveColor("blue")
+	board := parseElements(t, `[
+		{"id":"left-box","type":"rectangle","x":0,"y":0,"width":100,"height":60},
+		{"id":"middle-box","type":"rectangle","x":200,"y":0,"width":100,"height":60},
+		{"id":"right-box","type":"rectangle","x":400,"y":0,"width":100,"height":60},
+		{"id":"top-circle","type":"ellipse","x":600,"y":0,"width":80,"height":80},
+		{"id":"bottom-circle","type":"ellipse","x":600,"y":300,"width":80,"height":80},
+		{"id":"blue-box","type":"rectangle","x":200,"y":200,"width":100,"height":60},
+		{"id":"label","type":"text","x":-300,"y":-300,"width":40,"height":20,"text":"x","containerId":"left-box"},
+		{"id":"link","type":"arrow","x":-500,"y":0,"width":10,"height":0},
+		{"id":"ghost","type":"rectangle","x":-900,"y":0,"width":100,"height":60,"isDeleted":true}
+	]`)
+	for i := range board {
+		if board[i].ID == "blue-box" {
+			board[i].BackgroundColor = blue
+		}
+	}
+	return board
+}
+
+func TestResolveReference(t *testing.T) {
+	tests := []struct {
+		desc string
+		want string
+	}{
+		{desc: "the box on the left", want: "left-box"},
+		{desc: "the leftmost rectangle", want: "left-box"},
+		{desc: "the right box", want: "right-box"},
+		{desc: "the top circle", want: "top-circle"},
+		{desc: "the circle at the very bottom", want: "bottom-circle"},
+		{desc: "the bottom box", want: "blue-box"},
+		{desc: "the blue one", want: "blue-box"},
+		{desc: "the blue box in the middle", want: "blue-box"},
+		{desc: "the bottom right shape", want: "bottom-circle"},
+		{desc: "the top left one", want: "left-box"},
+		{desc: "the arrow", want: "link"},
+	}
+	board := spatialBoard(t)
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := ResolveReference(tt.desc, board)
+			if err != nil {
+				t.Fatalf("ResolveReference(%q): %v", tt.desc, err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveReference(%q) = %q, want %q", tt.desc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveReferenceFails(t *testing.T) {
+	tests := []struct {
+		name  string
+		desc  string
+		board string
+		want  error
+	}{
+		{name: "empty board", desc: "the box on the left", board: `[]`, want: ErrNoReference},
+		{name: "only deleted elements", desc: "the box on the left", board: `[{"id":"a","type":"rectangle","x":0,"y":0,"width":10,"height":10,"isDeleted":true}]`, want: ErrNoReference},
+		{name: "no element of the type", desc: "the left diamond", board: `[{"id":"a","type":"rectangle","x":0,"y":0,"width":10,"height":10}]`, want: ErrNoReference},
+		{name: "no element of the color", desc: "the red box", board: `[{"id":"a","type":"rectangle","x":0,"y":0,"width":10,"height":10}]`, want: ErrNoReference},
+		{name: "connectors aren't shapes", desc: "the left one", board: `[{"id":"a","type":"arrow","x":0,"y":0,"width":10,"height":0}]`, want: ErrNoReference},
+		{
+			name:  "tied on the left",
+			desc:  "the box on the left",
+			board: `[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":60},{"id":"b","type":"rectangle","x":5,"y":200,"width":100,"height":60},{"id":"c","type":"rectangle","x":400,"y":0,"width":100,"height":60}]`,
+			want:  ErrAmbiguousReference,
+		},
+		{
+			name:  "tied at the top",
+			desc:  "the top circle",
+			board: `[{"id":"a","type":"ellipse","x":0,"y":0,"width":80,"height":80},{"id":"b","type":"ellipse","x":300,"y":8,"width":80,"height":80}]`,
+			want:  ErrAmbiguousReference,
+		},
+		{
+			name:  "tied in the middle",
+			desc:  "the middle box",
+			board: `[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":100},{"id":"b","type":"rectangle","x":200,"y":0,"width":100,"height":100}]`,
+			want:  ErrAmbiguousReference,
+		},
+		{
+			name:  "several without a place",
+			desc:  "the box",
+			board: `[{"id":"a","type":"rectangle","x":0,"y":0,"width":100,"height":60},{"id":"b","type":"rectangle","x":400,"y":0,"width":100,"height":60}]`,
+			want:  ErrAmbiguousReference,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := ResolveReference(tt.desc, parseElements(t, tt.board))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ResolveReference(%q) = %q, %v, want %v", tt.desc, id, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveReferenceTieTolerance(t *testing.T) {
+	for _, tt := range []struct {
+		offset float64
+		want   error
+	}{
+		{offset: tieTolerance, want: ErrAmbiguousReference},
+		{offset: tieTolerance + 1, want: nil},
+	} {
+		board := []llm.Element{
+			{ID: "a", Type: "rectangle", X: 0, Y: 0, Width: 100, Height: 60},
+			{ID: "b", Type: "rectangle", X: tt.offset, Y: 200, Width: 100, Height: 60},
+		}
+		id, err := ResolveReference("the left box", board)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("boxes %v apart: ResolveReference = %q, %v, want %v", tt.offset, id, err, tt.want)
+		}
+		if tt.want == nil && id != "a" {
+			t.Errorf("boxes %v apart: ResolveReference = %q, want a", tt.offset, id)
+		}
+	}
+}
+
+func TestResolveSpatialReferents(t *testing.T) {
+	board := spatialBoard(t)
+	referents := ResolveSpatialReferents("move the box on the left next to the top circle", board)
+	want := []llm.Referent{
+		{Phrase: "box on the left", ElementID: "left-box"},
+		{Phrase: "top circle", ElementID: "top-circle"},
+	}
+	if len(referents) != len(want) {
+		t.Fatalf("referents = %+v, want %+v", referents, want)
+	}
+	for i := range want {
+		if referents[i] != want[i] {
+			t.Errorf("referent %d = %+v, want %+v", i, referents[i], want[i])
+		}
+	}
+
+	if got := ResolveSpatialReferents("delete the box on the left", nil); len(got) != 0 {
+		t.Errorf("referents on an empty board = %+v, want none", got)
+	}
+}
+
+func TestCorrectSpatialTarget(t *testing.T) {
+	board := spatialBoard(t)
+	tests := []struct {
+		name        string
+		action      string
+		instruction string
+		// want is the ID the action should end up targeting.
+		want string
+	}{
+		{
+			name:        "delete of the wrong box",
+			action:      `{"action":"delete","delete_ids":["right-box"]}`,
+			instruction: "delete the box on the left",
+			want:        "left-box",
+		},
+		{
+			name:        "update of the wrong circle",
+			action:      `{"action":"update","elements":[{"id":"bottom-circle","type":"ellipse","x":600,"y":300,"backgroundColor":"#ffc9c9"}]}`,
+			instruction: "make the top circle red",
+			want:        "top-circle",
+		},
+		{
+			name:        "already right",
+			action:      `{"action":"delete","delete_ids":["left-box"]}`,
+			instruction: "delete the box on the left",
+			want:        "left-box",
+		},
+		{
+			name:        "a different type isn't a misreading",
+			action:      `{"action":"delete","delete_ids":["top-circle"]}`,
+			instruction: "delete the box on the left",
+			want:        "top-circle",
+		},
+		{
+			name:        "landmarks aren't targets",
+			action:      `{"action":"delete","delete_ids":["right-box"]}`,
+			instruction: "delete the box next to the top circle",
+			want:        "right-box",
+		},
+		{
+			name:        "tied descriptions are left alone",
+			action:      `{"action":"delete","delete_ids":["blue-box"]}`,
+			instruction: "delete the top box",
+			want:        "blue-box",
+		},
+		{
+			name:        "descriptions without a place are left alone",
+			action:      `{"action":"delete","delete_ids":["right-box"]}`,
+			instruction: "delete the box",
+			want:        "right-box",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrected := CorrectSpatialTarget(parseAction(t, tt.action), board, tt.instruction)
+			got := ""
+			if corrected.Action == llm.ActionDelete {
+				got = corrected.DeleteIDs[0]
+			} else {
+				got = corrected.Elements[0].ID
+			}
+			if got != tt.want {
+				t.Errorf("target = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The update moves with the retarget and drops what it copied along.
+	corrected := CorrectSpatialTarget(parseAction(t, tests[1].action), board, tests[1].instruction)
+	update := corrected.Elements[0]
+	if update.X != 600 || update.Y != 0 || update.BackgroundColor != "#ffc9c9" {
+		t.Errorf("retargeted update = %+v, want the red fill at the top circle", update)
+	}
+
+	empty := parseAction(t, `{"action":"delete","delete_ids":["a"]}`)
+	if got := CorrectSpatialTarget(empty, nil, "delete the box on the left"); got != empty {
+		t.Errorf("correcting on an empty board returned a new action, want it unchanged")
+	}
+}
@@ -0,0 +1,336 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"draw/pkg/llm"
+)
+
+// StickyType is the element type the model uses for sticky notes. It never
+// reaches clients: ExpandStickies turns stickies into rectangles.
+const StickyType = "sticky"
+
+// Sticky note geometry. Stickies are square unless their text needs more
+// height.
+const (
+	stickySize        = 200
+	stickyPadding     = 16
+	stickyFontSize    = 20
+	stickyGap         = 24
+	stickyGridColumns = 4
+)
+
+type stickyColor struct {
+	name, fill, stroke string
+}
+
+// stickyColors is the sticky note palette, in the order cluster_stickies
+// lays out its columns. The first is the default.
+var stickyColors = []stickyColor{
+	{"yellow", "#ffec99", "#f08c00"},
+	{"orange", "#ffd8a8", "#e8590c"},
+	{"pink", "#fcc2d7", "#c2255c"},
+	{"purple", "#d0bfff", "#6741d9"},
+	{"blue", "#a5d8ff", "#1971c2"},
+	{"green", "#b2f2bb", "#2f9e44"},
+}
+
+// StickyColorNames lists the palette, for prompts and error messages.
+func StickyColorNames() []string {
+	names := make([]string, len(stickyColors))
+	for i, color := range stickyColors {
+		names[i] = color.name
+	}
+	return names
+}
+
+func lookupStickyColor(name string) (stickyColor, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return stickyColors[0], nil
+	}
+	for _, color := range stickyColors {
+		if color.name == name {
+			return color, nil
+		}
+	}
+	return stickyColor{}, fmt.Errorf("unknown sticky color %q; use one of %s", name, strings.Join(StickyColorNames(), ", "))
+}
+
+// IsSticky reports whether element is an expanded sticky note.
+func IsSticky(element llm.Element) bool {
+	var data struct {
+		Sticky bool `json:"sticky"`
+	}
+	raw, ok := element.Extra["customData"]
+	return ok && json.Unmarshal(raw, &data) == nil && data.Sticky
+}
+
+// stickyColorKey groups stickies by color: the palette name for palette
+// fills, the fill itself for anything else.
+func stickyColorKey(element llm.Element) string {
+	for _, color := range stickyColors {
+		if strings.EqualFold(element.BackgroundColor, color.fill) {
+			return color.name
+		}
+	}
+	return strings.ToLower(element.BackgroundColor)
+}
+
+// ExpandStickies rewrites the sticky elements of an add, update or replace
+// action as rounded rectangles with a palette fill and a label wrapped to fit,
+// growing them taller when the text needs it. When an add or replace creates
+// several stickies, the first one's position is kept and the rest flow into a
+// grid from there.
+// Actions without stickies are returned unchanged.
+func ExpandStickies(action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	if action.Action != llm.ActionAdd && action.Action != llm.ActionUpdate && action.Action != llm.ActionReplace {
+		return action, nil
+	}
+
+	var stickies []int
+	for i, element := range action.Elements {
+		if element.Type == StickyType {
+			stickies = append(stickies, i)
+		}
+	}
+	if len(stickies) == 0 {
+		return action, nil
+	}
+
+	byID := make(map[string]llm.Element, len(board))
+	for _, element := range board {
+		byID[element.ID] = element
+	}
+
+	expanded := *action
+	expanded.Elements = append([]llm.Element(nil), action.Elements...)
+	for _, i := range stickies {
+		element := action.Elements[i]
+		if action.Action == llm.ActionUpdate {
+			element = stickyUpdate(element, byID[element.ID])
+		}
+		color, err := lookupStickyColor(element.Color)
+		if err != nil {
+			return nil, fmt.Errorf("elements[%d]: %w", i, err)
+		}
+		expanded.Elements[i] = expandSticky(element, color)
+	}
+
+	if action.Action != llm.ActionUpdate && len(stickies) > 1 {
+		grid := make([]*llm.Element, len(stickies))
+		for n, i := range stickies {
+			grid[n] = &expanded.Elements[i]
+		}
+		layoutStickyGrid(grid)
+	}
+	return &expanded, nil
+}
+
+// stickyUpdate fills in what an update left out from the sticky it updates,
+// so changing the text doesn't also move, resize or recolor it.
+func stickyUpdate(update llm.Element, original llm.Element) llm.Element {
+	if original.ID == "" {
+		return update
+	}
+	if update.X == 0 && update.Y == 0 {
+		update.X, update.Y = original.X, original.Y
+	}
+	if update.Width == 0 {
+		update.Width = original.Width
+	}
+	if update.Color == "" && IsSticky(original) {
+		update.Color = stickyColorKey(original)
+		if _, err := lookupStickyColor(update.Color); err != nil {
+			update.Color = ""
+		}
+	}
+	if update.Text == "" && update.Label == nil && original.Label != nil {
+		update.Text = original.Label.Text
+	}
+	return update
+}
+
+func expandSticky(element llm.Element, color stickyColor) llm.Element {
+	text := element.Text
+	fontSize := element.FontSize
+	if element.Label != nil {
+		if text == "" {
+			text = element.Label.Text
+		}
+		if fontSize == 0 {
+			fontSize = element.Label.FontSize
+		}
+	}
+	if fontSize == 0 {
+		fontSize = stickyFontSize
+	}
+
+	width := element.Width
+	if width == 0 {
+		width = stickySize
+	}
+	wrapped := WrapText(text, fontSize, FontExcalifont, width-2*stickyPadding)
+	_, textHeight := MeasureText(wrapped, fontSize, FontExcalifont)
+	height := math.Max(math.Max(element.Height, stickySize), textHeight+2*stickyPadding)
+
+	sticky := element
+	sticky.Type = "rectangle"
+	sticky.Width, sticky.Height = width, height
+	sticky.BackgroundColor = color.fill
+	sticky.StrokeColor = color.stroke
+	sticky.FillStyle = "solid"
+	if sticky.StrokeWidth == 0 {
+		sticky.StrokeWidth = 1
+	}
+	sticky.Text, sticky.FontSize, sticky.Color = "", 0, ""
+	sticky.Label = &llm.ElementLabel{Text: wrapped, FontSize: fontSize, StrokeColor: "#1e1e1e"}
+	setExtra(&sticky, "roundness", json.RawMessage(`{"type":3}`))
+	setExtra(&sticky, "customData", json.RawMessage(`{"sticky":true}`))
+	return sticky
+}
+
+// layoutStickyGrid places stickies left to right, top to bottom, starting at
+// the first one's position. Each row is as tall as its tallest sticky.
+func layoutStickyGrid(stickies []*llm.Element) {
+	originX, originY := stickies[0].X, stickies[0].Y
+	columnWidth := 0.0
+	for _, sticky := range stickies {
+		columnWidth = math.Max(columnWidth, sticky.Width)
+	}
+
+	y := originY
+	for row := 0; row*stickyGridColumns < len(stickies); row++ {
+		rowHeight := 0.0
+		for col := 0; col < stickyGridColumns; col++ {
+			i := row*stickyGridColumns + col
+			if i >= len(stickies) {
+				break
+			}
+			stickies[i].X = originX + float64(col)*(columnWidth+stickyGap)
+			stickies[i].Y = y
+			rowHeight = math.Max(rowHeight, stickies[i].Height)
+		}
+		y += rowHeight + stickyGap
+	}
+}
+
+// stickyTargets returns the stickies a bulk transform applies to: targetIDs,
+// which must all be stickies, or every sticky on the board.
+func stickyTargets(board []llm.Element, targetIDs []string) ([]llm.Element, error) {
+	if len(targetIDs) == 0 {
+		var stickies []llm.Element
+		for _, element := range board {
+			if IsSticky(element) && !isDeleted(element) {
+				stickies = append(stickies, element)
+			}
+		}
+		if len(stickies) == 0 {
+			return nil, fmt.Errorf("the board has no sticky notes")
+		}
+		return stickies, nil
+	}
+
+	byID := make(map[string]llm.Element, len(board))
+	for _, element := range board {
+		byID[element.ID] = element
+	}
+	stickies := make([]llm.Element, 0, len(targetIDs))
+	for _, id := range targetIDs {
+		element, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("sticky %q not found", id)
+		}
+		if !IsSticky(element) {
+			return nil, fmt.Errorf("element %q is not a sticky note", id)
+		}
+		stickies = append(stickies, element)
+	}
+	return stickies, nil
+}
+
+// RecolorStickies gives the targeted stickies the palette color name. With
+// no targetIDs every sticky on the board is recolored.
+func RecolorStickies(board []llm.Element, targetIDs []string, name string) ([]llm.Element, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("recolor_stickies requires a color")
+	}
+	color, err := lookupStickyColor(name)
+	if err != nil {
+		return nil, err
+	}
+	stickies, err := stickyTargets(board, targetIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stickies {
+		stickies[i].BackgroundColor = color.fill
+		stickies[i].StrokeColor = color.stroke
+	}
+	return stickies, nil
+}
+
+// ClusterStickies moves the targeted stickies into one column per color,
+// ordered as the palette is, with colors outside it last. Within a column
+// stickies keep their reading order. The columns start at the top-left
+// corner of the stickies' current bounding box. With no targetIDs every
+// sticky on the board is clustered.
+func ClusterStickies(board []llm.Element, targetIDs []string) ([]llm.Element, error) {
+	stickies, err := stickyTargets(board, targetIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	originX, originY := stickies[0].X, stickies[0].Y
+	columnWidth := 0.0
+	columns := make(map[string][]llm.Element)
+	for _, sticky := range stickies {
+		originX, originY = math.Min(originX, sticky.X), math.Min(originY, sticky.Y)
+		columnWidth = math.Max(columnWidth, sticky.Width)
+		key := stickyColorKey(sticky)
+		columns[key] = append(columns[key], sticky)
+	}
+
+	var order []string
+	inPalette := make(map[string]bool, len(stickyColors))
+	for _, color := range stickyColors {
+		inPalette[color.name] = true
+		if _, ok := columns[color.name]; ok {
+			order = append(order, color.name)
+		}
+	}
+	var others []string
+	for key := range columns {
+		if !inPalette[key] {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	order = append(order, others...)
+
+	clustered := make([]llm.Element, 0, len(stickies))
+	for col, key := range order {
+		column := columns[key]
+		sort.SliceStable(column, func(i, j int) bool {
+			if column[i].Y != column[j].Y {
+				return column[i].Y < column[j].Y
+			}
+			if column[i].X != column[j].X {
+				return column[i].X < column[j].X
+			}
+			return column[i].ID < column[j].ID
+		})
+		y := originY
+		for _, sticky := range column {
+			sticky.X = originX + float64(col)*(columnWidth+stickyGap)
+			sticky.Y = y
+			y += sticky.Height + stickyGap
+			clustered = append(clustered, sticky)
+		}
+	}
+	return clustered, nil
+}
@@ -0,0 +1,242 @@
+package whiteboard
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"draw/pkg/llm"
+)
+
+func TestExpandSticky(t *testing.T) {
+	// At the default font size an Excalifont "a" is 12 pixels, so the
+	// 168-pixel text area of a default sticky fits 14 of them a line, and
+	// each line is 25 pixels high.
+	tests := []struct {
+		name    string
+		sticky  string
+		text    string
+		fill    string
+		size    string
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			sticky: `{"id":"s1","type":"sticky","x":10,"y":20,"text":"reduce onboarding friction"}`,
+			text:   "reduce\nonboarding\nfriction",
+			fill:   "#ffec99",
+			size:   "200x200",
+		},
+		{
+			name:   "palette color",
+			sticky: `{"id":"s1","type":"sticky","x":0,"y":0,"text":"ship it","color":" Green "}`,
+			text:   "ship it",
+			fill:   "#b2f2bb",
+			size:   "200x200",
+		},
+		{name: "no text", sticky: `{"id":"s1","type":"sticky","x":0,"y":0}`, text: "", fill: "#ffec99", size: "200x200"},
+		{
+			name:   "word wider than the sticky",
+			sticky: `{"id":"s1","type":"sticky","x":0,"y":0,"text":"` + strings.Repeat("a", 30) + `"}`,
+			text:   strings.Repeat("a", 14) + "\n" + strings.Repeat("a", 14) + "\naa",
+			fill:   "#ffec99",
+			size:   "200x200",
+		},
+		{
+			// Ten lines need 250 pixels plus padding: the sticky grows.
+			name:   "grows taller",
+			sticky: `{"id":"s1","type":"sticky","x":0,"y":0,"text":"1\n2\n3\n4\n5\n6\n7\n8\n9\n10"}`,
+			text:   "1\n2\n3\n4\n5\n6\n7\n8\n9\n10",
+			fill:   "#ffec99",
+			size:   "200x282",
+		},
+		{
+			name:   "wider sticky wraps later",
+			sticky: `{"id":"s1","type":"sticky","x":0,"y":0,"width":300,"text":"reduce onboarding friction"}`,
+			text:   "reduce onboarding\nfriction",
+			fill:   "#ffec99",
+			size:   "300x200",
+		},
+		{
+			name:    "unknown color",
+			sticky:  `{"id":"s1","type":"sticky","x":0,"y":0,"text":"x","color":"teal"}`,
+			wantErr: `elements[0]: unknown sticky color "teal"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := parseAction(t, `{"action":"add","elements":[`+tt.sticky+`]}`)
+			expanded, err := ExpandStickies(action, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandStickies: %v", err)
+			}
+			sticky := expanded.Elements[0]
+			if sticky.Type != "rectangle" || !IsSticky(sticky) || sticky.Text != "" || sticky.Color != "" {
+				t.Errorf("sticky = %+v, want a rectangle tagged as a sticky", sticky)
+			}
+			if sticky.Label == nil || sticky.Label.Text != tt.text {
+				t.Errorf("label = %+v, want %q", sticky.Label, tt.text)
+			}
+			if sticky.BackgroundColor != tt.fill {
+				t.Errorf("fill = %s, want %s", sticky.BackgroundColor, tt.fill)
+			}
+			if got := fmt.Sprintf("%gx%g", sticky.Width, sticky.Height); got != tt.size {
+				t.Errorf("size = %s, want %s", got, tt.size)
+			}
+			if action.Elements[0].Type != StickyType {
+				t.Error("ExpandStickies modified the action it was given")
+			}
+		})
+	}
+}
+
+func TestStickyGrid(t *testing.T) {
+	var elements []string
+	for i := 1; i <= 6; i++ {
+		text := "idea"
+		if i == 2 {
+			// Ten lines make the first row 282 pixels tall.
+			text = `1\n2\n3\n4\n5\n6\n7\n8\n9\n10`
+		}
+		elements = append(elements, fmt.Sprintf(`{"id":"s%d","type":"sticky","x":%d,"y":%d,"text":"%s"}`, i, 100*i, 50, text))
+	}
+	elements = append(elements, `{"id":"r1","type":"rectangle","x":-500,"y":-500,"width":10,"height":10}`)
+	action := parseAction(t, `{"action":"add","elements":[`+strings.Join(elements, ",")+`]}`)
+
+	expanded, err := ExpandStickies(action, nil)
+	if err != nil {
+		t.Fatalf("ExpandStickies: %v", err)
+	}
+	var got []string
+	for _, element := range expanded.Elements {
+		got = append(got, fmt.Sprintf("%s@%g,%g", element.ID, element.X, element.Y))
+	}
+	// Four columns of 200 plus a 24 gap, from the first sticky's position;
+	// the rectangle isn't a sticky and stays put.
+	want := "s1@100,50 s2@324,50 s3@548,50 s4@772,50 s5@100,356 s6@324,356 r1@-500,-500"
+	if strings.Join(got, " ") != want {
+		t.Errorf("positions = %s\nwant %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestExpandStickyUpdate(t *testing.T) {
+	board := expandedBoard(t, `{"id":"s1","type":"sticky","x":40,"y":60,"width":260,"text":"old idea","color":"pink"}`)
+
+	tests := []struct {
+		name   string
+		update string
+		want   string
+	}{
+		{name: "new text keeps the rest", update: `{"id":"s1","type":"sticky","text":"new idea"}`, want: "new idea #fcc2d7 40,60 260"},
+		{name: "new color keeps the text", update: `{"id":"s1","type":"sticky","color":"blue"}`, want: "old idea #a5d8ff 40,60 260"},
+		{name: "moved", update: `{"id":"s1","type":"sticky","x":500,"y":0}`, want: "old idea #fcc2d7 500,0 260"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expanded, err := ExpandStickies(parseAction(t, `{"action":"update","elements":[`+tt.update+`]}`), board)
+			if err != nil {
+				t.Fatalf("ExpandStickies: %v", err)
+			}
+			sticky := expanded.Elements[0]
+			got := fmt.Sprintf("%s %s %g,%g %g", sticky.Label.Text, sticky.BackgroundColor, sticky.X, sticky.Y, sticky.Width)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecolorStickies(t *testing.T) {
+	board := expandedBoard(t,
+		`{"id":"s1","type":"sticky","x":0,"y":0,"text":"a"}`,
+		`{"id":"s2","type":"sticky","x":300,"y":0,"text":"b","color":"green"}`,
+		`{"id":"r1","type":"rectangle","x":0,"y":300,"width":10,"height":10}`,
+	)
+	tests := []struct {
+		name    string
+		targets []string
+		color   string
+		want    string
+		wantErr string
+	}{
+		{name: "every sticky", color: "purple", want: "s1:#d0bfff s2:#d0bfff"},
+		{name: "targets", targets: []string{"s2"}, color: "orange", want: "s2:#ffd8a8"},
+		{name: "not a sticky", targets: []string{"r1"}, color: "orange", wantErr: `element "r1" is not a sticky note`},
+		{name: "missing", targets: []string{"s9"}, color: "orange", wantErr: `sticky "s9" not found`},
+		{name: "no color", wantErr: "recolor_stickies requires a color"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recolored, err := RecolorStickies(board, tt.targets, tt.color)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RecolorStickies: %v", err)
+			}
+			var got []string
+			for _, sticky := range recolored {
+				got = append(got, sticky.ID+":"+sticky.BackgroundColor)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("got %s, want %s", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterStickies(t *testing.T) {
+	board := expandedBoard(t,
+		`{"id":"g1","type":"sticky","x":500,"y":100,"text":"a","color":"green"}`,
+		`{"id":"y2","type":"sticky","x":0,"y":400,"text":"b"}`,
+		`{"id":"y1","type":"sticky","x":300,"y":100,"text":"c"}`,
+		`{"id":"p1","type":"sticky","x":100,"y":800,"text":"d","color":"pink"}`,
+	)
+	// Stickies made elsewhere may carry any fill; they go after the palette.
+	board[3].BackgroundColor = "#FFFFFF"
+
+	for run := 0; run < 2; run++ {
+		clustered, err := ClusterStickies(board, nil)
+		if err != nil {
+			t.Fatalf("ClusterStickies: %v", err)
+		}
+		var got []string
+		for _, sticky := range clustered {
+			got = append(got, fmt.Sprintf("%s@%g,%g", sticky.ID, sticky.X, sticky.Y))
+		}
+		// Yellow, then green, then the unknown fill; yellow in reading order.
+		want := "y1@0,100 y2@0,324 g1@224,100 p1@448,100"
+		if strings.Join(got, " ") != want {
+			t.Errorf("run %d: got %s\nwant %s", run, strings.Join(got, " "), want)
+		}
+	}
+
+	if _, err := ClusterStickies(nil, nil); err == nil {
+		t.Error("clustering a board without stickies succeeded")
+	}
+}
+
+// expandedBoard returns a board of the given elements, with stickies
+// expanded as the pipeline stores them. Each is expanded on its own, so
+// they keep their positions rather than flowing into a grid.
+func expandedBoard(t *testing.T, elements ...string) []llm.Element {
+	t.Helper()
+	var board []llm.Element
+	for _, element := range elements {
+		expanded, err := ExpandStickies(parseAction(t, `{"action":"add","elements":[`+element+`]}`), nil)
+		if err != nil {
+			t.Fatalf("ExpandStickies: %v", err)
+		}
+		board = append(board, expanded.Elements...)
+	}
+	return board
+}
@@ -0,0 +1,122 @@
+package whiteboard
+
+import (
+	"fmt"
+
+	"draw/pkg/llm"
+)
+
+// Style is the visual part of an element: everything that can be copied from
+// one element to another without moving or resizing it.
+type Style struct {
+	StrokeColor     string
+	BackgroundColor string
+	FillStyle       string
+	StrokeWidth     float64
+	StrokeStyle     string
+	Roughness       *float64
+	Opacity         *float64
+	FontSize        float64
+	FontFamily      int
+}
+
+// StyleOf captures the style of an element. For labelled shapes the font
+// size comes from the label.
+func StyleOf(element llm.Element) Style {
+	style := Style{
+		StrokeColor:     element.StrokeColor,
+		BackgroundColor: element.BackgroundColor,
+		FillStyle:       element.FillStyle,
+		StrokeWidth:     element.StrokeWidth,
+		StrokeStyle:     element.StrokeStyle,
+		Roughness:       element.Roughness,
+		Opacity:         element.Opacity,
+		FontSize:        element.FontSize,
+		FontFamily:      element.FontFamily,
+	}
+	if style.FontSize == 0 && element.Label != nil {
+		style.FontSize = element.Label.FontSize
+	}
+	return style
+}
+
+// ExtractStyle returns the style of the element with the given ID.
+func ExtractStyle(elements []llm.Element, sourceID string) (Style, error) {
+	for _, element := range elements {
+		if element.ID == sourceID {
+			return StyleOf(element), nil
+		}
+	}
+	return Style{}, fmt.Errorf("style source %q not found", sourceID)
+}
+
+// Apply returns a copy of element with the style applied. Unset style fields
+// leave the element's own values alone; geometry is never touched.
+func (s Style) Apply(element llm.Element) llm.Element {
+	if s.StrokeColor != "" {
+		element.StrokeColor = s.StrokeColor
+	}
+	if s.BackgroundColor != "" {
+		element.BackgroundColor = s.BackgroundColor
+	}
+	if s.FillStyle != "" {
+		element.FillStyle = s.FillStyle
+	}
+	if s.StrokeWidth != 0 {
+		element.StrokeWidth = s.StrokeWidth
+	}
+	if s.StrokeStyle != "" {
+		element.StrokeStyle = s.StrokeStyle
+	}
+	if s.Roughness != nil {
+		roughness := *s.Roughness
+		element.Roughness = &roughness
+	}
+	if s.Opacity != nil {
+		opacity := *s.Opacity
+		element.Opacity = &opacity
+	}
+	if s.FontFamily != 0 {
+		element.FontFamily = s.FontFamily
+	}
+	if s.FontSize != 0 {
+		if element.Label != nil {
+			label := *element.Label
+			label.FontSize = s.FontSize
+			element.Label = &label
+		} else if element.Type == "text" {
+			element.FontSize = s.FontSize
+		}
+	}
+	return element
+}
+
+// CopyStyle applies the style of sourceID to every target and returns the
+// updated targets. The source and all targets must exist.
+func CopyStyle(elements []llm.Element, sourceID string, targetIDs []string) ([]llm.Element, error) {
+	style, err := ExtractStyle(elements, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(targetIDs) == 0 {
+		return nil, fmt.Errorf("copy_style requires at least one target")
+	}
+
+	byID := make(map[string]llm.Element, len(elements))
+	for _, element := range elements {
+		byID[element.ID] = element
+	}
+
+	updated := make([]llm.Element, 0, len(targetIDs))
+	for _, id := range targetIDs {
+		if id == sourceID {
+			continue
+		}
+		target, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("style target %q not found", id)
+		}
+		updated = append(updated, style.Apply(target))
+	}
+	return updated, nil
+}
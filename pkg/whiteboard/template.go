@@ -0,0 +1,67 @@
+package whiteboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"draw/pkg/llm"
+	"draw/pkg/whiteboard/templates"
+)
+
+// templateOrigin is where a template goes on an empty board when the model
+// doesn't say, matching where the prompt starts diagrams.
+var templateOrigin = llm.Point{X: 100, Y: 100}
+
+// ExpandTemplate expands a template action into an add of the template's
+// elements, with its params filled in. The template's top-left corner goes
+// at the action's origin or, without one, below the elements on board, as
+// PlaceBelow puts them. Expansion depends on nothing but the action and the
+// board, so replaying an instruction expands it the same way. Other actions
+// are returned unchanged.
+func ExpandTemplate(action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	if action.Action != llm.ActionTemplate {
+		return action, nil
+	}
+
+	elements, err := InstantiateTemplate(action.Name, action.Params, action.Origin, board)
+	if err != nil {
+		return nil, err
+	}
+	return &llm.WhiteboardAction{
+		Action:   llm.ActionAdd,
+		Elements: elements,
+	}, nil
+}
+
+// InstantiateTemplate returns the elements of the template called name with
+// params filled in, placed as ExpandTemplate places them. The elements keep
+// the IDs the template gives them; AssignElementIDs replaces them.
+func InstantiateTemplate(name string, params map[string]string, origin *llm.Point, board []llm.Element) ([]llm.Element, error) {
+	data, err := templates.Render(name, params)
+	if err != nil {
+		return nil, err
+	}
+	var elements []llm.Element
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("failed to parse template %q: %w", name, err)
+	}
+	if len(elements) == 0 {
+		return elements, nil
+	}
+
+	at := templateOrigin
+	if origin != nil {
+		at = *origin
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	for _, element := range elements {
+		x1, y1, _, _ := elementBounds(element)
+		minX, minY = math.Min(minX, x1), math.Min(minY, y1)
+	}
+	shift(elements, at.X-minX, at.Y-minY)
+	if origin != nil {
+		return elements, nil
+	}
+	return PlaceBelow(elements, board), nil
+}
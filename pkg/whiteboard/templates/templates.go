@@ -0,0 +1,143 @@
+// Package templates is the library of diagram skeletons, such as a SWOT grid
+// or kanban columns, that a board can be started from. Each template is a
+// JSON file in this directory, embedded into the binary, so adding one takes
+// no code. It imports nothing else of ours, so the prompts can be built from
+// it.
+//
+// A template file holds a description, the params it takes with their
+// defaults, and its elements in the form the model writes them, laid out
+// from (0, 0). Params are written "{{name}}" inside the elements' strings.
+package templates
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed *.json
+var files embed.FS
+
+// ErrUnknownTemplate is returned for names no template has.
+var ErrUnknownTemplate = errors.New("unknown template")
+
+// ErrUnknownParam is returned for params a template doesn't take.
+var ErrUnknownParam = errors.New("unknown template param")
+
+// Template is a parameterized set of elements.
+type Template struct {
+	// Name is the template's file name without the extension.
+	Name        string `json:"-"`
+	Description string `json:"description"`
+	// Params maps the params the template takes to their defaults.
+	Params map[string]string `json:"params"`
+	// Elements are the template's elements, with params still in them.
+	Elements json.RawMessage `json:"elements"`
+}
+
+var paramPattern = regexp.MustCompile(`\{\{([a-z_]+)\}\}`)
+
+// library holds the embedded templates by name. A template that doesn't
+// parse is a mistake in this package, so it stops the program at startup
+// rather than failing the first instruction that asks for it.
+var library = load()
+
+func load() map[string]Template {
+	names, err := fs.Glob(files, "*.json")
+	if err != nil {
+		panic(err)
+	}
+	loaded := make(map[string]Template, len(names))
+	for _, name := range names {
+		data, err := files.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		var template Template
+		if err := json.Unmarshal(data, &template); err != nil {
+			panic(fmt.Sprintf("template %s: %v", name, err))
+		}
+		template.Name = strings.TrimSuffix(name, path.Ext(name))
+		for _, match := range paramPattern.FindAllStringSubmatch(string(template.Elements), -1) {
+			if _, ok := template.Params[match[1]]; !ok {
+				panic(fmt.Sprintf("template %s: param %q has no default", name, match[1]))
+			}
+		}
+		loaded[template.Name] = template
+	}
+	return loaded
+}
+
+// Names returns the names of the templates, sorted.
+func Names() []string {
+	names := make([]string, 0, len(library))
+	for name := range library {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the template called name.
+func Lookup(name string) (Template, bool) {
+	template, ok := library[strings.ToLower(strings.TrimSpace(name))]
+	return template, ok
+}
+
+// Render returns the elements of the template called name as a JSON array,
+// with params filled in from params and the rest left at their defaults.
+func Render(name string, params map[string]string) (json.RawMessage, error) {
+	template, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTemplate, name)
+	}
+	for param := range params {
+		if _, ok := template.Params[param]; !ok {
+			return nil, fmt.Errorf("%w %q for %s", ErrUnknownParam, param, template.Name)
+		}
+	}
+
+	rendered := paramPattern.ReplaceAllStringFunc(string(template.Elements), func(match string) string {
+		param := match[2 : len(match)-2]
+		value, ok := params[param]
+		if !ok {
+			value = template.Params[param]
+		}
+		// Params sit inside JSON strings, so values are escaped as one and
+		// spliced in without the quotes.
+		quoted, _ := json.Marshal(value)
+		return string(quoted[1 : len(quoted)-1])
+	})
+	return json.RawMessage(rendered), nil
+}
+
+// PromptSection renders the library as the prompts' TEMPLATES section.
+func PromptSection() string {
+	var sb strings.Builder
+	sb.WriteString("## TEMPLATES\n")
+	sb.WriteString("To start a common diagram, do NOT draw it element by element. Use:\n")
+	sb.WriteString(`{"action": "template", "name": "swot", "origin": {"x": 100, "y": 100}, "params": {"title": "Q3 review"}}` + "\n")
+	sb.WriteString("- Leave out origin to put it below what is on the board\n")
+	sb.WriteString("- Leave out params to keep the defaults\n")
+	sb.WriteString("Templates:\n")
+	for _, name := range Names() {
+		template := library[name]
+		fmt.Fprintf(&sb, "- %q: %s", name, template.Description)
+		if len(template.Params) > 0 {
+			params := make([]string, 0, len(template.Params))
+			for param := range template.Params {
+				params = append(params, param)
+			}
+			sort.Strings(params)
+			fmt.Fprintf(&sb, " (params: %s)", strings.Join(params, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
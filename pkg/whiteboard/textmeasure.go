@@ -0,0 +1,177 @@
+package whiteboard
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Excalidraw's font families.
+const (
+	FontVirgil         = 1
+	FontHelvetica      = 2
+	FontCascadia       = 3
+	FontExcalifont     = 5
+	FontNunito         = 6
+	FontLilitaOne      = 7
+	FontComicShanns    = 8
+	FontLiberationSans = 9
+)
+
+// boundTextPadding is the space Excalidraw keeps between a container's
+// edge and the text bound to it.
+const boundTextPadding = 5
+
+// lineHeight is the line height of the default font, as a multiple of the
+// font size.
+const lineHeight = 1.25
+
+// lineHeights are the line heights Excalidraw gives each font family;
+// families not listed use lineHeight.
+var lineHeights = map[int]float64{
+	FontHelvetica:      1.15,
+	FontCascadia:       1.2,
+	FontLilitaOne:      1.15,
+	FontLiberationSans: 1.15,
+}
+
+// helveticaWidths are the advance widths of the printable ASCII characters,
+// from space to tilde, in Helvetica, in thousandths of the font size.
+// Liberation Sans shares them, and Nunito is close enough to use them.
+var helveticaWidths = [95]uint16{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// monospaceWidth is the advance of every character of Cascadia and Comic
+// Shanns, as a multiple of the font size.
+const monospaceWidth = 0.6
+
+// LineHeight returns the line height Excalidraw uses for fontFamily, as a
+// multiple of the font size.
+func LineHeight(fontFamily int) float64 {
+	if height, ok := lineHeights[fontFamily]; ok {
+		return height
+	}
+	return lineHeight
+}
+
+// runeWidth approximates how wide r is drawn in fontFamily, as a multiple of
+// the font size. The server has no fonts to measure with, so it uses the
+// metrics of Helvetica and the monospace fonts where it has them, and for
+// the hand-drawn fonts an estimate that errs on the wide side so text fits.
+// Wide scripts and emoji are as wide in every family.
+func runeWidth(r rune, fontFamily int) float64 {
+	switch {
+	case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hangul, r) ||
+		unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+		return 1.0
+	case utf8.RuneLen(r) == 4:
+		// Emoji and other astral symbols.
+		return 1.2
+	}
+
+	switch fontFamily {
+	case FontCascadia, FontComicShanns:
+		return monospaceWidth
+	case FontHelvetica, FontNunito, FontLilitaOne, FontLiberationSans:
+		if r >= ' ' && r <= '~' {
+			return float64(helveticaWidths[r-' ']) / 1000
+		}
+	}
+
+	switch {
+	case r == ' ':
+		return 0.3
+	case strings.ContainsRune("il.,:;'!|", r):
+		return 0.3
+	case strings.ContainsRune("mwMW@", r):
+		return 0.9
+	case unicode.IsUpper(r):
+		return 0.7
+	default:
+		return 0.6
+	}
+}
+
+// MeasureText returns the size text takes up at fontSize in fontFamily,
+// with 0 meaning the default Excalifont. Lines are split on newlines only;
+// use WrapText first to fit a width.
+func MeasureText(text string, fontSize float64, fontFamily int) (width, height float64) {
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		width = math.Max(width, lineWidth(line, fontSize, fontFamily))
+	}
+	return math.Ceil(width), math.Ceil(float64(len(lines)) * fontSize * LineHeight(fontFamily))
+}
+
+func lineWidth(line string, fontSize float64, fontFamily int) float64 {
+	var width float64
+	for _, r := range line {
+		width += runeWidth(r, fontFamily)
+	}
+	return width * fontSize
+}
+
+// WrapText breaks text into lines no wider than maxWidth at fontSize in
+// fontFamily. Existing line breaks are kept, runs of spaces collapse to one,
+// and words too long for a line of their own are broken between characters,
+// as is text in scripts written without spaces, such as Chinese.
+func WrapText(text string, fontSize float64, fontFamily int, maxWidth float64) string {
+	if maxWidth <= 0 || fontSize <= 0 {
+		return text
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if lineWidth(candidate, fontSize, fontFamily) <= maxWidth {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// The word starts a new line; if it doesn't fit there either, it
+			// is broken over as many lines as it needs.
+			line = ""
+			for _, r := range word {
+				if line != "" && lineWidth(line+string(r), fontSize, fontFamily) > maxWidth {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ContainerSize returns the size a container of containerType needs to hold
+// text of the given size, as Excalidraw computes it: the text plus padding
+// for rectangles, and more for ellipses and diamonds, which only hold text in
+// the box inscribed in them.
+func ContainerSize(containerType string, textWidth, textHeight float64) (width, height float64) {
+	size := func(dimension float64) float64 {
+		dimension = math.Ceil(dimension) + 2*boundTextPadding
+		switch containerType {
+		case "ellipse":
+			return math.Round(dimension / math.Sqrt2 * 2)
+		case "diamond":
+			return 2 * dimension
+		}
+		return dimension
+	}
+	return size(textWidth), size(textHeight)
+}
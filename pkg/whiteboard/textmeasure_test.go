@@ -0,0 +1,102 @@
+package whiteboard
+
+import "testing"
+
+func TestMeasureText(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		fontSize   float64
+		fontFamily int
+		width      float64
+		height     float64
+	}{
+		// Helvetica's metrics: H 722, e 556, l 222, o 556.
+		{name: "helvetica", text: "Hello", fontSize: 20, fontFamily: FontHelvetica, width: 46, height: 23},
+		{name: "liberation sans", text: "Hello", fontSize: 20, fontFamily: FontLiberationSans, width: 46, height: 23},
+		{name: "nunito", text: "Hello", fontSize: 20, fontFamily: FontNunito, width: 46, height: 25},
+		{name: "lilita one", text: "Hello", fontSize: 20, fontFamily: FontLilitaOne, width: 46, height: 23},
+		{name: "cascadia", text: "Hello", fontSize: 20, fontFamily: FontCascadia, width: 60, height: 24},
+		{name: "comic shanns", text: "Hello", fontSize: 20, fontFamily: FontComicShanns, width: 60, height: 25},
+		{name: "excalifont", text: "Hello", fontSize: 20, fontFamily: FontExcalifont, width: 50, height: 25},
+		{name: "virgil", text: "Hello", fontSize: 20, fontFamily: FontVirgil, width: 50, height: 25},
+		{name: "default family", text: "Hello", fontSize: 20, fontFamily: 0, width: 50, height: 25},
+		{name: "scales with size", text: "Hello", fontSize: 40, fontFamily: FontCascadia, width: 120, height: 48},
+		{name: "multi-line", text: "ab\nabcd", fontSize: 10, fontFamily: FontCascadia, width: 24, height: 24},
+		{name: "trailing newline", text: "abcd\n", fontSize: 10, fontFamily: FontCascadia, width: 24, height: 24},
+		{name: "cjk", text: "你好世界", fontSize: 20, fontFamily: FontHelvetica, width: 80, height: 23},
+		{name: "cjk in a monospace font", text: "こんにちは", fontSize: 20, fontFamily: FontCascadia, width: 100, height: 24},
+		{name: "hangul", text: "안녕", fontSize: 20, fontFamily: FontExcalifont, width: 40, height: 25},
+		// G 778, o 556, space 278, then two characters a font size wide.
+		{name: "mixed scripts", text: "Go 语言", fontSize: 20, fontFamily: FontHelvetica, width: 73, height: 23},
+		{name: "emoji", text: "🙂", fontSize: 20, fontFamily: FontHelvetica, width: 24, height: 23},
+		{name: "empty", text: "", fontSize: 20, fontFamily: FontExcalifont, width: 0, height: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := MeasureText(tt.text, tt.fontSize, tt.fontFamily)
+			if width != tt.width || height != tt.height {
+				t.Errorf("MeasureText(%q, %v, %d) = %v x %v, want %v x %v", tt.text, tt.fontSize, tt.fontFamily, width, height, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestHandDrawnFontsErrWide(t *testing.T) {
+	// Without metrics for the hand-drawn fonts, text must measure at least
+	// as wide as in Helvetica, so labels fit rather than overflow.
+	for _, text := range []string{"Login", "Sign in with email", "WWW mmm", "iiii.,;", "Checkout 123"} {
+		helvetica, _ := MeasureText(text, 20, FontHelvetica)
+		for _, family := range []int{FontVirgil, FontExcalifont} {
+			if width, _ := MeasureText(text, 20, family); width < helvetica {
+				t.Errorf("MeasureText(%q) in family %d = %v, want at least Helvetica's %v", text, family, width, helvetica)
+			}
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	// Cascadia at 10 is 6 pixels a character, so 60 fits ten.
+	tests := []struct {
+		name     string
+		text     string
+		maxWidth float64
+		want     string
+	}{
+		{name: "fits", text: "short", maxWidth: 60, want: "short"},
+		{name: "between words", text: "the quick brown fox", maxWidth: 60, want: "the quick\nbrown fox"},
+		{name: "exactly full", text: "abcde fghi", maxWidth: 60, want: "abcde fghi"},
+		{name: "long word", text: "abcdefghijklmnop", maxWidth: 60, want: "abcdefghij\nklmnop"},
+		{name: "long word after others", text: "go abcdefghijklmnop", maxWidth: 60, want: "go\nabcdefghij\nklmnop"},
+		{name: "keeps line breaks", text: "one\ntwo three four", maxWidth: 60, want: "one\ntwo three\nfour"},
+		{name: "collapses spaces", text: "a   b\r\n  c", maxWidth: 60, want: "a b\nc"},
+		{name: "cjk", text: "一二三四五六七", maxWidth: 30, want: "一二三\n四五六\n七"},
+		{name: "no width", text: "the quick  brown fox", maxWidth: 0, want: "the quick  brown fox"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WrapText(tt.text, 10, FontCascadia, tt.maxWidth); got != tt.want {
+				t.Errorf("WrapText(%q, %v) = %q, want %q", tt.text, tt.maxWidth, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerSize(t *testing.T) {
+	tests := []struct {
+		containerType string
+		width, height float64
+	}{
+		{containerType: "rectangle", width: 111, height: 35},
+		{containerType: "ellipse", width: 157, height: 49},
+		{containerType: "diamond", width: 222, height: 70},
+	}
+	for _, tt := range tests {
+		width, height := ContainerSize(tt.containerType, 100.2, 25)
+		if width != tt.width || height != tt.height {
+			t.Errorf("ContainerSize(%s, 100.2, 25) = %v x %v, want %v x %v", tt.containerType, width, height, tt.width, tt.height)
+		}
+	}
+}
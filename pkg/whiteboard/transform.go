@@ -0,0 +1,35 @@
+package whiteboard
+
+import (
+	"fmt"
+
+	"draw/pkg/llm"
+)
+
+// ResolveTransform expands a transform action into the plain update clients
+// know how to apply. Other actions are returned unchanged.
+func ResolveTransform(action *llm.WhiteboardAction, board []llm.Element) (*llm.WhiteboardAction, error) {
+	if action.Action != llm.ActionTransform {
+		return action, nil
+	}
+
+	var elements []llm.Element
+	var err error
+	switch action.Operation {
+	case llm.OperationCopyStyle:
+		elements, err = CopyStyle(board, action.SourceID, action.TargetIDs)
+	case llm.OperationRecolorStickies:
+		elements, err = RecolorStickies(board, action.TargetIDs, action.Color)
+	case llm.OperationClusterStickies:
+		elements, err = ClusterStickies(board, action.TargetIDs)
+	default:
+		return nil, fmt.Errorf("unknown transform operation %q", action.Operation)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &llm.WhiteboardAction{
+		Action:   llm.ActionUpdate,
+		Elements: elements,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package whiteboard
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"draw/pkg/llm"
+)
+
+// Viewport is where clients should move their camera: a point with an
+// optional zoom, or a bounding box to fit when Width and Height are set.
+type Viewport struct {
+	X      float64  `json:"x"`
+	Y      float64  `json:"y"`
+	Zoom   *float64 `json:"zoom,omitempty"`
+	Width  *float64 `json:"width,omitempty"`
+	Height *float64 `json:"height,omitempty"`
+}
+
+// FrameViewport returns the current bounding box of a frame, so views that
+// follow a frame stay correct as it moves or resizes.
+func FrameViewport(frameID string, elements []llm.Element) (Viewport, error) {
+	for _, element := range elements {
+		if element.ID != frameID {
+			continue
+		}
+		width, height := element.Width, element.Height
+		return Viewport{
+			X:      element.X,
+			Y:      element.Y,
+			Width:  &width,
+			Height: &height,
+		}, nil
+	}
+	return Viewport{}, fmt.Errorf("frame %q is no longer on the board", frameID)
+}
+
+var navigateIntentPattern = regexp.MustCompile(`(?i)^(?:please\s+)?(?:go|jump|navigate|move|take\s+(?:us|me|everyone))\s+(?:back\s+)?to\s+(.+?)[.!]?$`)
+
+// ParseNavigateIntent recognises "go to the architecture section" and returns
+// the spoken view name. It doesn't know whether such a view exists; callers
+// match it with MatchViewName and fall back to the whiteboard prompt.
+func ParseNavigateIntent(instruction string) (string, bool) {
+	m := navigateIntentPattern.FindStringSubmatch(strings.TrimSpace(instruction))
+	if m == nil {
+		return "", false
+	}
+	return strings.TrimSpace(m[1]), true
+}
+
+// viewFillerWords are dropped before comparing spoken and saved view names.
+var viewFillerWords = map[string]bool{
+	"the": true, "a": true, "an": true, "our": true, "my": true,
+	"section": true, "view": true, "area": true, "part": true,
+}
+
+func viewNameWords(name string) []string {
+	var words []string
+	for _, word := range tokenize(name) {
+		if !viewFillerWords[word] {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// MatchViewName picks the saved view a spoken phrase refers to. An exact
+// match (ignoring filler like "the ... section") wins; otherwise a name
+// within a small edit distance is accepted as long as exactly one name is
+// that close. It returns the index into names, or -1.
+func MatchViewName(phrase string, names []string) int {
+	spoken := strings.Join(viewNameWords(phrase), " ")
+	if spoken == "" {
+		return -1
+	}
+
+	best, bestDistance, tied := -1, 0, false
+	for i, name := range names {
+		saved := strings.Join(viewNameWords(name), " ")
+		if saved == spoken {
+			return i
+		}
+		distance := levenshtein(spoken, saved)
+		if distance > maxNameDistance(saved) {
+			continue
+		}
+		switch {
+		case best == -1 || distance < bestDistance:
+			best, bestDistance, tied = i, distance, false
+		case distance == bestDistance:
+			tied = true
+		}
+	}
+	if tied {
+		return -1
+	}
+	return best
+}
+
+// maxNameDistance allows roughly one mistake per four characters, which
+// covers transcription slips like "architecure" without matching unrelated
+// short names.
+func maxNameDistance(name string) int {
+	return len([]rune(name)) / 4
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr := make([]int, len(rb)+1)
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(rb)]
+}
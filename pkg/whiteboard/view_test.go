@@ -0,0 +1,107 @@
+package whiteboard
+
+import (
+	"testing"
+)
+
+func TestFrameViewport(t *testing.T) {
+	size := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name  string
+		board string
+		want  Viewport
+		err   bool
+	}{
+		{
+			name:  "frame where it was saved",
+			board: `[{"id":"arch","type":"frame","x":100,"y":50,"width":800,"height":600}]`,
+			want:  Viewport{X: 100, Y: 50, Width: size(800.0), Height: size(600.0)},
+		},
+		{
+			name:  "frame moved and resized since",
+			board: `[{"id":"box","type":"rectangle","x":0,"y":0,"width":10,"height":10},{"id":"arch","type":"frame","x":-400,"y":1200,"width":1000,"height":300}]`,
+			want:  Viewport{X: -400, Y: 1200, Width: size(1000.0), Height: size(300.0)},
+		},
+		{
+			name:  "frame deleted",
+			board: `[{"id":"box","type":"rectangle","x":0,"y":0,"width":10,"height":10}]`,
+			err:   true,
+		},
+		{
+			name:  "empty board",
+			board: `[]`,
+			err:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FrameViewport("arch", parseElements(t, tt.board))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FrameViewport: %v", err)
+			}
+			if got.X != tt.want.X || got.Y != tt.want.Y || got.Zoom != nil ||
+				got.Width == nil || *got.Width != *tt.want.Width || got.Height == nil || *got.Height != *tt.want.Height {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNavigateIntent(t *testing.T) {
+	tests := []struct {
+		instruction string
+		want        string
+		ok          bool
+	}{
+		{instruction: "go to the architecture section", want: "the architecture section", ok: true},
+		{instruction: "Please jump to Roadmap.", want: "Roadmap", ok: true},
+		{instruction: "take everyone back to the intro", want: "the intro", ok: true},
+		{instruction: "  navigate to risks!  ", want: "risks", ok: true},
+		{instruction: "draw an arrow to the database"},
+		{instruction: "move the box to the left"},
+		{instruction: "go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.instruction, func(t *testing.T) {
+			got, ok := ParseNavigateIntent(tt.instruction)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMatchViewName(t *testing.T) {
+	names := []string{"Architecture", "Roadmap Q3", "Roadmap Q4", "Intro", "Open questions", "Ops"}
+	tests := []struct {
+		phrase string
+		want   int
+	}{
+		{phrase: "the architecture section", want: 0},
+		{phrase: "ARCHITECTURE", want: 0},
+		{phrase: "architecure", want: 0},
+		{phrase: "roadmap q4", want: 2},
+		// One slip from both roadmaps: ambiguous.
+		{phrase: "roadmap q5", want: -1},
+		{phrase: "our open questions area", want: 4},
+		{phrase: "intra", want: 3},
+		{phrase: "ops", want: 5},
+		// Names under four letters tolerate no slips.
+		{phrase: "ups", want: -1},
+		{phrase: "budget", want: -1},
+		{phrase: "the section", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phrase, func(t *testing.T) {
+			if got := MatchViewName(tt.phrase, names); got != tt.want {
+				t.Errorf("MatchViewName(%q) = %d, want %d", tt.phrase, got, tt.want)
+			}
+		})
+	}
+}